@@ -13,19 +13,33 @@ SPDX-FileCopyrightText: 2017 SAP SE or an SAP affiliate company and Gardener con
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"time"
 
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/gc"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/provider"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/provider/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/version"
+	mcmscheme "github.com/gardener/machine-controller-manager/pkg/client/clientset/versioned/scheme"
 	_ "github.com/gardener/machine-controller-manager/pkg/util/client/metrics/prometheus" // for access metric registration
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/app"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/app/options"
 	_ "github.com/gardener/machine-controller-manager/pkg/util/reflector/prometheus" // for reflector metric registration
 	_ "github.com/gardener/machine-controller-manager/pkg/util/workqueue/prometheus" // for workqueue metric registration
 	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/component-base/cli/flag"
 	"k8s.io/component-base/logs"
+	"k8s.io/klog/v2"
 )
 
 func main() {
@@ -33,13 +47,146 @@ func main() {
 	s := options.NewMCServer()
 	s.AddFlags(pflag.CommandLine)
 
+	var factoryOptions access.FactoryOptions
+	pflag.CommandLine.StringVar(&factoryOptions.Proxy.HTTPProxy, "azure-http-proxy", "", "URL of the proxy used for HTTP requests against Azure. Defaults to the HTTP_PROXY environment variable.")
+	pflag.CommandLine.StringVar(&factoryOptions.Proxy.HTTPSProxy, "azure-https-proxy", "", "URL of the proxy used for HTTPS requests against Azure. Defaults to the HTTPS_PROXY environment variable.")
+	pflag.CommandLine.StringVar(&factoryOptions.Proxy.NoProxy, "azure-no-proxy", "", "Comma-separated list of hosts which are requested without a proxy. Defaults to the NO_PROXY environment variable.")
+	pflag.CommandLine.Int32Var(&factoryOptions.MaxRetries, "azure-max-retries", 0, "Maximum number of retries of a failed request against Azure. 0 uses the default of the Azure SDK, a negative value disables retries.")
+	pflag.CommandLine.DurationVar(&factoryOptions.RetryDelay, "azure-retry-delay", 0, "Initial delay between retries of a failed request against Azure. 0 uses the default of the Azure SDK.")
+	pflag.CommandLine.DurationVar(&factoryOptions.MaxRetryDelay, "azure-max-retry-delay", 0, "Maximum delay between retries of a failed request against Azure. 0 uses the default of the Azure SDK.")
+	pflag.CommandLine.IntVar(&factoryOptions.CircuitBreakerThreshold, "azure-circuit-breaker-threshold", 0, "Number of consecutive requests for a subscription which failed with a server error or timeout, after the retries of the Azure SDK, after which further requests fail fast. 0 disables the circuit breaker.")
+	pflag.CommandLine.DurationVar(&factoryOptions.CircuitBreakerCooldown, "azure-circuit-breaker-cooldown", time.Minute, "Duration for which requests for a subscription fail fast after the circuit breaker tripped.")
+	pflag.CommandLine.BoolVar(&factoryOptions.DevAuth, "dev-auth", false, "Authenticate with the credentials of the local environment, e.g. of the Azure CLI, instead of the credentials in the secret. Only meant for local development.")
+	pflag.CommandLine.BoolVar(&factoryOptions.AuditLogging, "azure-audit-logging", false, "Log every request sent to Azure with its method, URI, status, latency and request IDs.")
+	pflag.CommandLine.StringToStringVar(&factoryOptions.APIVersions, "azure-api-versions", nil, fmt.Sprintf("API versions to use per resource type, e.g. virtualMachines=2023-03-01. Supported resource types are %v.", access.ResourceTypes))
+
+	operationTimeouts := accesshelpers.DefaultOperationTimeouts()
+	pflag.CommandLine.DurationVar(&operationTimeouts.CreateNIC, "azure-nic-create-timeout", operationTimeouts.CreateNIC, "Timeout to create a NIC.")
+	pflag.CommandLine.DurationVar(&operationTimeouts.DeleteNIC, "azure-nic-delete-timeout", operationTimeouts.DeleteNIC, "Timeout to delete a NIC.")
+	pflag.CommandLine.DurationVar(&operationTimeouts.CreateVM, "azure-vm-create-timeout", operationTimeouts.CreateVM, "Timeout to create a VM.")
+	pflag.CommandLine.DurationVar(&operationTimeouts.UpdateVM, "azure-vm-update-timeout", operationTimeouts.UpdateVM, "Timeout to update a VM.")
+	pflag.CommandLine.DurationVar(&operationTimeouts.DeleteVM, "azure-vm-delete-timeout", operationTimeouts.DeleteVM, "Timeout to delete a VM.")
+	pflag.CommandLine.DurationVar(&operationTimeouts.StartVM, "azure-vm-start-timeout", operationTimeouts.StartVM, "Timeout to start a VM.")
+	pflag.CommandLine.DurationVar(&operationTimeouts.DeallocateVM, "azure-vm-deallocate-timeout", operationTimeouts.DeallocateVM, "Timeout to deallocate a VM.")
+	pflag.CommandLine.DurationVar(&operationTimeouts.DeleteDisk, "azure-disk-delete-timeout", operationTimeouts.DeleteDisk, "Timeout to delete a disk.")
+	pflag.CommandLine.DurationVar(&operationTimeouts.DeletePublicIP, "azure-public-ip-delete-timeout", operationTimeouts.DeletePublicIP, "Timeout to delete a public IP address.")
+
+	pollingOptions := accesshelpers.DefaultPollingOptions()
+	pflag.CommandLine.DurationVar(&pollingOptions.Frequency, "azure-polling-frequency", pollingOptions.Frequency, "Interval between two polls of a long-running operation, e.g. the creation of a VM, unless Azure requests another interval. At least 1s.")
+	pflag.CommandLine.DurationVar(&pollingOptions.InitialDelay, "azure-polling-initial-delay", pollingOptions.InitialDelay, "Delay before a long-running operation is polled for the first time.")
+
+	resourceGraphQueryLimits := accesshelpers.DefaultResourceGraphQueryLimits()
+	pflag.CommandLine.Int32Var(&resourceGraphQueryLimits.PageSize, "azure-resource-graph-page-size", resourceGraphQueryLimits.PageSize, "Number of records requested per page of a resource graph query, at most 1000.")
+	pflag.CommandLine.IntVar(&resourceGraphQueryLimits.MaxResults, "azure-resource-graph-max-results", resourceGraphQueryLimits.MaxResults, "Maximum number of records processed per resource graph query, e.g. when listing machines. 0 processes all records.")
+
+	lookupCacheTTLs := helpers.DefaultLookupCacheTTLs()
+	pflag.CommandLine.DurationVar(&lookupCacheTTLs.Subnet, "azure-subnet-cache-ttl", lookupCacheTTLs.Subnet, "Duration for which a subnet is cached instead of being fetched for every machine creation. 0 disables the cache.")
+	pflag.CommandLine.DurationVar(&lookupCacheTTLs.VMImage, "azure-vm-image-cache-ttl", lookupCacheTTLs.VMImage, "Duration for which a marketplace VM image is cached instead of being fetched for every machine creation. 0 disables the cache.")
+	pflag.CommandLine.DurationVar(&lookupCacheTTLs.AgreementTerms, "azure-marketplace-agreement-cache-ttl", lookupCacheTTLs.AgreementTerms, "Duration for which accepted marketplace agreement terms are cached instead of being fetched for every machine creation. 0 disables the cache.")
+	pflag.CommandLine.DurationVar(&lookupCacheTTLs.GalleryImageVersion, "azure-gallery-image-version-cache-ttl", lookupCacheTTLs.GalleryImageVersion, "Duration for which the version, to which the latest version of a shared or community gallery image is resolved, is pinned, so that machines created during a rollout use the same version. 0 resolves the latest version for every machine creation.")
+	pflag.CommandLine.DurationVar(&lookupCacheTTLs.ListMachines, "azure-list-machines-cache-ttl", lookupCacheTTLs.ListMachines, "Duration for which the resources listed for ListMachines are cached to collapse bursts of identical queries, e.g. a few seconds. 0 disables the cache.")
+
+	deletionConcurrency := helpers.DefaultDeletionConcurrency
+	pflag.CommandLine.IntVar(&deletionConcurrency, "azure-deletion-concurrency", deletionConcurrency, "Number of resources of a machine, e.g. leftover NICs and Disks, which are deleted concurrently.")
+
+	var driverOptions provider.DriverOptions
+	pflag.CommandLine.BoolVar(&driverOptions.MachineStatusViaResourceGraph, "machine-status-via-resource-graph", false, "Determine the state of VMs for machine status checks via resource graph instead of getting every VM from the compute API.")
+	pflag.CommandLine.BoolVar(&driverOptions.ReconcileTags, "reconcile-tags", false, "Add missing or changed tags of the MachineClass to the VM, NIC and disks of existing machines during machine status checks. This requires additional Azure API calls for every status check.")
+
+	var otlpEndpoint string
+	pflag.CommandLine.StringVar(&otlpEndpoint, "otlp-endpoint", "", "URL of the OTLP/HTTP endpoint, e.g. http://localhost:4318, to which traces of the driver methods and the requests sent to Azure are exported. Tracing is disabled if empty.")
+
+	var printVersion bool
+	pflag.CommandLine.BoolVar(&printVersion, "version", false, "Print the version of the provider and of the Azure SDK it has been built with and exit.")
+
+	var debugAddress string
+	var enablePprof bool
+	pflag.CommandLine.StringVar(&debugAddress, "debug-address", "", "Address, e.g. localhost:6060, on which the statistics of the Azure clients and the in-flight requests against Azure are served at /debug/azure. Disabled if empty.")
+	pflag.CommandLine.BoolVar(&enablePprof, "enable-pprof", false, "Additionally serve the pprof profiles at /debug/pprof/ on the debug address.")
+
+	var gcOptions gc.Options
+	pflag.CommandLine.DurationVar(&gcOptions.Period, "orphan-collection-period", 0, "Period in which NICs, Disks and public IP addresses of machines which no longer exist are collected. 0 disables the collection.")
+	pflag.CommandLine.BoolVar(&gcOptions.Delete, "orphan-collection-delete", false, "Delete the collected orphaned resources instead of only reporting them.")
+
 	flag.InitFlags()
+	if printVersion {
+		fmt.Print(version.Get())
+		os.Exit(0)
+	}
 	logs.InitLogs()
 	defer logs.FlushLogs()
+	instrument.RecordBuildInfo(version.Get())
 
-	driver := provider.NewDefaultDriver(access.NewDefaultAccessFactory())
+	if err := factoryOptions.Validate(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if factoryOptions.DevAuth {
+		klog.Warning("Developer authentication is enabled, the credentials of the local environment are used instead of the credentials in the secret")
+	}
+	shutdownTracingFn, err := instrument.SetupTracing(context.Background(), otlpEndpoint)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracingFn(context.Background()); err != nil {
+			klog.Errorf("failed to shut down tracing: %v", err)
+		}
+	}()
+	factoryOptions.Tracing = len(otlpEndpoint) > 0
+	accesshelpers.SetOperationTimeouts(operationTimeouts)
+	accesshelpers.SetPollingOptions(pollingOptions)
+	accesshelpers.SetResourceGraphQueryLimits(resourceGraphQueryLimits)
+	helpers.SetLookupCacheTTLs(lookupCacheTTLs)
+	helpers.SetDeletionConcurrency(deletionConcurrency)
+	accessFactory := access.NewDefaultAccessFactoryWithOptions(factoryOptions)
+	if len(debugAddress) > 0 {
+		go serveDebugEndpoints(debugAddress, accessFactory, enablePprof)
+	}
+	machineClient, kubeClient, err := gc.NewControlClients(s.ControlKubeconfig, s.TargetKubeconfig)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	// the collector is stopped once the machine controller returns.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if gcOptions.Period > 0 {
+		collector := gc.NewCollector(accessFactory, machineClient, kubeClient, s.Namespace, gcOptions)
+		go func() {
+			if err := collector.RunWithLeaderElection(ctx, s.LeaderElection); err != nil {
+				klog.Errorf("failed to run orphan collector: %v", err)
+			}
+		}()
+	}
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(s.Namespace)})
+	driverOptions.EventRecorder = eventBroadcaster.NewRecorder(mcmscheme.Scheme, corev1.EventSource{Component: "machine-controller"})
+	driver := provider.NewDefaultDriverWithOptions(accessFactory, driverOptions)
 	if err := app.Run(s, driver); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 }
+
+// serveDebugEndpoints serves the statistics of the access factory and optionally the pprof profiles on the passed address.
+// The endpoints are meant for diagnosing goroutine leaks and slow reconciles and should not be exposed publicly.
+func serveDebugEndpoints(address string, accessFactory access.Factory, enablePprof bool) {
+	mux := http.NewServeMux()
+	if statsProvider, ok := accessFactory.(access.StatsProvider); ok {
+		mux.Handle("/debug/azure", access.NewStatsHandler(statsProvider))
+	}
+	if enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	klog.Infof("Serving debug endpoints on %s", address)
+	if err := server.ListenAndServe(); err != nil {
+		klog.Errorf("Failed to serve debug endpoints on %s: %v", address, err)
+	}
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// specctl validates the provider specs of MachineClasses without a running machine-controller, e.g. in CI pipelines
+// before a MachineClass is rolled out.
+//
+// Usage:
+//
+//	specctl validate <file>...
+//
+// Every file may contain multiple YAML or JSON documents, "-" reads from stdin. Documents which are no MachineClass are
+// skipped. specctl exits with 1 if any MachineClass is invalid.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api/validation"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/provider/helpers"
+)
+
+const (
+	exitInvalid = 1
+	exitUsage   = 2
+)
+
+const usage = `Usage: specctl validate <file>...
+
+Validates the provider specs of the MachineClasses in the passed files. "-" reads from stdin.
+`
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "validate" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+
+	valid := true
+	for _, path := range os.Args[2:] {
+		ok, err := validateFile(path, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(exitUsage)
+		}
+		valid = valid && ok
+	}
+	if !valid {
+		os.Exit(exitInvalid)
+	}
+}
+
+// validateFile validates all MachineClasses in the file at path and reports the result of every MachineClass to out.
+// It returns whether all MachineClasses are valid. An error is only returned if the file cannot be read or decoded.
+func validateFile(path string, out io.Writer) (bool, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return false, err
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+
+	valid := true
+	decoder := yaml.NewYAMLOrJSONDecoder(in, 4096)
+	for {
+		var mcc v1alpha1.MachineClass
+		if err := decoder.Decode(&mcc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return valid, nil
+			}
+			return false, err
+		}
+		if mcc.Kind != "MachineClass" {
+			continue
+		}
+		errs := validateMachineClass(&mcc)
+		if len(errs) == 0 {
+			_, _ = fmt.Fprintf(out, "%s: MachineClass %q is valid\n", path, mcc.Name)
+			continue
+		}
+		valid = false
+		_, _ = fmt.Fprintf(out, "%s: MachineClass %q is invalid:\n", path, mcc.Name)
+		for _, err := range errs {
+			_, _ = fmt.Fprintf(out, "  - %s\n", err)
+		}
+	}
+}
+
+// validateMachineClass runs the same validation as the machine-controller does for every request and returns the
+// field-level errors of the MachineClass.
+func validateMachineClass(mcc *v1alpha1.MachineClass) []string {
+	if err := validation.ValidateMachineClassProvider(mcc); err != nil {
+		return []string{err.Error()}
+	}
+	_, fieldErrs, err := helpers.DecodeMachineClassProviderSpec(mcc)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	errs := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		errs = append(errs, fieldErr.Error())
+	}
+	return errs
+}
@@ -0,0 +1,214 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// schema-gen generates the JSON schema of api.AzureProviderSpec. The schema only uses keywords which are also part of
+// the OpenAPI v3.1 schema object, hence it can be embedded into OpenAPI documents as well. The descriptions of the
+// properties are taken from the doc comments of the fields in the api package.
+//
+// It is run by `go generate` in the api package, see pkg/azure/api/generate.go.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+)
+
+// schema is the subset of a JSON schema which is required to describe api.AzureProviderSpec.
+type schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*schema          `json:"allOf,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Deprecated           bool               `json:"deprecated,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Defs                 map[string]*schema `json:"$defs,omitempty"`
+}
+
+// generator generates the schemas of struct types as definitions which are referenced by their name.
+type generator struct {
+	// fieldDocs are the doc comments of the fields per type and field name.
+	fieldDocs map[string]map[string]string
+	// typeDocs are the doc comments per type name.
+	typeDocs map[string]string
+	defs     map[string]*schema
+}
+
+func main() {
+	apiDir := flag.String("api-dir", ".", "Directory of the api package whose doc comments are used as descriptions.")
+	out := flag.String("out", "schema/azureproviderspec.schema.json", "File to which the schema is written.")
+	flag.Parse()
+
+	g, err := newGenerator(*apiDir)
+	if err != nil {
+		exitOnError(err)
+	}
+	root := g.schemaFor(reflect.TypeOf(api.AzureProviderSpec{}))
+	root.Schema = "https://json-schema.org/draft/2020-12/schema"
+	root.ID = "https://github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api/AzureProviderSpec"
+	root.Title = "AzureProviderSpec"
+	root.Defs = g.defs
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		exitOnError(err)
+	}
+	if err = os.WriteFile(*out, append(data, '\n'), 0o644); err != nil { // #nosec G306 -- the schema is no secret
+		exitOnError(err)
+	}
+}
+
+func exitOnError(err error) {
+	fmt.Fprintf(os.Stderr, "schema-gen: %v\n", err)
+	os.Exit(1)
+}
+
+func newGenerator(apiDir string) (*generator, error) {
+	g := &generator{
+		fieldDocs: make(map[string]map[string]string),
+		typeDocs:  make(map[string]string),
+		defs:      make(map[string]*schema),
+	}
+	fileSet := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fileSet, apiDir, func(info os.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			g.collectDocs(file)
+		}
+	}
+	return g, nil
+}
+
+func (g *generator) collectDocs(file *ast.File) {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			typeDoc := typeSpec.Doc
+			if typeDoc == nil {
+				typeDoc = genDecl.Doc
+			}
+			g.typeDocs[typeSpec.Name.Name] = docText(typeDoc)
+			fieldDocs := make(map[string]string)
+			for _, f := range structType.Fields.List {
+				for _, name := range f.Names {
+					fieldDocs[name.Name] = docText(f.Doc)
+				}
+			}
+			g.fieldDocs[typeSpec.Name.Name] = fieldDocs
+		}
+	}
+}
+
+func docText(commentGroup *ast.CommentGroup) string {
+	if commentGroup == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(commentGroup.Text()), " ")
+}
+
+// schemaFor returns the schema of the passed type. Struct types are added to the definitions and referenced.
+func (g *generator) schemaFor(t reflect.Type) *schema {
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		// json.RawMessage can contain any JSON value.
+		return &schema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int32, reflect.Int64:
+		return &schema{Type: "integer", Format: t.Kind().String()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			// register the definition before its properties are generated to terminate recursive types
+			def := &schema{Type: "object", Description: g.typeDocs[t.Name()]}
+			g.defs[t.Name()] = def
+			g.addProperties(def, t)
+		}
+		return &schema{Ref: "#/$defs/" + t.Name()}
+	default:
+		panic(fmt.Sprintf("unsupported kind %s of type %s", t.Kind(), t))
+	}
+}
+
+func (g *generator) addProperties(def *schema, t reflect.Type) {
+	def.Properties = make(map[string]*schema)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, omitEmpty, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		propertySchema := g.schemaFor(f.Type)
+		description := g.fieldDocs[t.Name()][f.Name]
+		if description != "" {
+			if propertySchema.Ref != "" {
+				// siblings of $ref are ignored by OpenAPI v3, hence the reference is wrapped to keep the description.
+				propertySchema = &schema{AllOf: []*schema{propertySchema}}
+			}
+			propertySchema.Description = description
+			propertySchema.Deprecated = strings.Contains(description, "Deprecated")
+		}
+		def.Properties[name] = propertySchema
+		if !omitEmpty && f.Type.Kind() != reflect.Pointer {
+			def.Required = append(def.Required, name)
+		}
+	}
+	sort.Strings(def.Required)
+}
+
+// jsonName returns the name of the property of the field in JSON and whether it is omitted if it is empty. The
+// returned bool is false if the field is not part of the JSON representation.
+func jsonName(f reflect.StructField) (string, bool, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, strings.Contains(options, "omitempty"), true
+}
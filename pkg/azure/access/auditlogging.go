@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+// auditLogQueryParameters are the query parameters whose values are logged as is. The values of all other query
+// parameters are redacted, as they could contain secrets like SAS tokens.
+var auditLogQueryParameters = []string{"api-version"}
+
+// auditLoggingPolicy is a per-retry policy which logs every request sent to Azure with its method, URI, status, latency
+// and request IDs. It uses the logger of the request context, so that the log carries the operation and machine. Neither
+// headers other than the request IDs nor bodies are logged, so that no secrets are leaked.
+type auditLoggingPolicy struct {
+	subscriptionID string
+}
+
+func (p auditLoggingPolicy) Do(req *policy.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := req.Next()
+	latency := time.Since(start)
+
+	keysAndValues := []any{
+		"subscriptionID", p.subscriptionID,
+		"method", req.Raw().Method,
+		"uri", redactURL(req.Raw().URL),
+		"latency", latency,
+	}
+	if resp != nil {
+		keysAndValues = append(keysAndValues,
+			"status", resp.StatusCode,
+			"requestID", resp.Header.Get(errors.RequestIDAzHeaderKey),
+			"correlationRequestID", resp.Header.Get(errors.CorrelationRequestIDAzHeaderKey),
+		)
+	}
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err.Error())
+	}
+	klog.FromContext(req.Raw().Context()).Info("Azure API request", keysAndValues...)
+	return resp, err
+}
+
+// redactURL returns the URL without user info and with the values of all query parameters redacted which are not
+// contained in auditLogQueryParameters.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	redacted := *u
+	redacted.User = nil
+	query := redacted.Query()
+	for key := range query {
+		if !slices.Contains(auditLogQueryParameters, key) {
+			query.Set(key, "REDACTED")
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
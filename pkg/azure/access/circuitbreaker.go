@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"context"
+	goerrors "errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+// defaultCircuitBreakerCooldown is the duration for which a tripped circuit breaker stays open if no cooldown is configured.
+const defaultCircuitBreakerCooldown = time.Minute
+
+// circuitBreaker is a per-subscription circuit breaker. It trips after a number of consecutive requests which failed with
+// a server error or timeout, after the retries of the Azure SDK have been exhausted, and then lets requests for the subscription fail fast for the cooldown, instead of having them wait for the timeouts
+// of an unavailable ARM. After the cooldown a single probe request is let through, which closes the circuit breaker again
+// on success or trips it for another cooldown on failure.
+type circuitBreaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	states    map[string]*circuitState
+	// now is used to get the current time. It can be replaced in unit tests.
+	now func() time.Time
+}
+
+type circuitState struct {
+	consecutiveFailures int
+	openUntil           time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		states:    make(map[string]*circuitState),
+		now:       time.Now,
+	}
+}
+
+// allow checks if a request for the subscription may be sent. If not, it returns the remaining duration for which the
+// circuit breaker is open.
+func (c *circuitBreaker) allow(subscriptionID string) (bool, time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	state, ok := c.states[subscriptionID]
+	if !ok || state.consecutiveFailures < c.threshold {
+		return true, 0
+	}
+	now := c.now()
+	if remaining := state.openUntil.Sub(now); remaining > 0 {
+		return false, remaining
+	}
+	// let a single probe request through and keep failing fast until its outcome is recorded
+	state.openUntil = now.Add(c.cooldown)
+	return true, 0
+}
+
+// recordResult records the outcome of a request for the subscription.
+func (c *circuitBreaker) recordResult(subscriptionID string, failed bool) {
+	c.Lock()
+	defer c.Unlock()
+	if !failed {
+		delete(c.states, subscriptionID)
+		return
+	}
+	state, ok := c.states[subscriptionID]
+	if !ok {
+		state = &circuitState{}
+		c.states[subscriptionID] = state
+	}
+	state.consecutiveFailures++
+	if state.consecutiveFailures >= c.threshold {
+		if state.consecutiveFailures == c.threshold {
+			klog.Warningf("Circuit breaker for subscription %s tripped after %d consecutive failed requests, failing requests fast for %s", subscriptionID, state.consecutiveFailures, c.cooldown)
+		}
+		state.openUntil = c.now().Add(c.cooldown)
+	}
+}
+
+// isCircuitBreakerFailure checks if the outcome of a request indicates that ARM is unavailable.
+func isCircuitBreakerFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		// requests which are canceled by the caller do not indicate an unavailability
+		return !goerrors.Is(err, context.Canceled)
+	}
+	return resp != nil && resp.StatusCode >= http.StatusInternalServerError
+}
+
+// circuitBreakerPolicy is a per-call policy which fails requests fast with an errors.CircuitOpenError while the circuit
+// breaker of the subscription is open. It has to be the last per-call policy, so that it wraps the retry policy of the
+// Azure SDK and records the outcome of a request only once, independent of the number of attempts.
+type circuitBreakerPolicy struct {
+	breaker        *circuitBreaker
+	subscriptionID string
+}
+
+func (p circuitBreakerPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if ok, remaining := p.breaker.allow(p.subscriptionID); !ok {
+		return nil, &errors.CircuitOpenError{SubscriptionID: p.subscriptionID, RetryAfter: remaining}
+	}
+	resp, err := req.Next()
+	p.breaker.recordResult(p.subscriptionID, isCircuitBreakerFailure(resp, err))
+	return resp, err
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+	breaker := newCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	allowed := func() bool {
+		ok, _ := breaker.allow(testSubscriptionID)
+		return ok
+	}
+
+	g.Expect(allowed()).To(BeTrue())
+	breaker.recordResult(testSubscriptionID, true)
+	g.Expect(allowed()).To(BeTrue())
+	// a successful request resets the consecutive failures
+	breaker.recordResult(testSubscriptionID, false)
+	breaker.recordResult(testSubscriptionID, true)
+	g.Expect(allowed()).To(BeTrue())
+	breaker.recordResult(testSubscriptionID, true)
+	g.Expect(allowed()).To(BeFalse())
+	ok, remaining := breaker.allow("other-subscription")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(remaining).To(BeZero())
+
+	// after the cooldown a single probe request is let through
+	now = now.Add(2 * time.Minute)
+	g.Expect(allowed()).To(BeTrue())
+	g.Expect(allowed()).To(BeFalse())
+	// a failed probe trips the circuit breaker again
+	breaker.recordResult(testSubscriptionID, true)
+	g.Expect(allowed()).To(BeFalse())
+	now = now.Add(2 * time.Minute)
+	g.Expect(allowed()).To(BeTrue())
+	// a successful probe closes the circuit breaker
+	breaker.recordResult(testSubscriptionID, false)
+	g.Expect(allowed()).To(BeTrue())
+	g.Expect(allowed()).To(BeTrue())
+}
+
+func TestCircuitBreakerPolicies(t *testing.T) {
+	g := NewWithT(t)
+	breaker := newCircuitBreaker(2, time.Minute)
+	transport := &fakeTransport{statusCodes: []int{http.StatusServiceUnavailable}}
+	pipeline := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:       transport,
+		Retry:           policy.RetryOptions{MaxRetries: 3, RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond},
+		PerCallPolicies: []policy.Policy{circuitBreakerPolicy{breaker: breaker, subscriptionID: testSubscriptionID}},
+	})
+
+	// the retries of a request only count as a single failure
+	for range 2 {
+		resp, err := sendTestRequest(context.Background(), pipeline)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	}
+	g.Expect(transport.requests).To(Equal(8))
+	_, err := sendTestRequest(context.Background(), pipeline)
+	g.Expect(errors.IsCircuitOpenError(err)).To(BeTrue())
+	g.Expect(transport.requests).To(Equal(8))
+}
+
+func TestIsCircuitBreakerFailure(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(isCircuitBreakerFailure(&http.Response{StatusCode: http.StatusInternalServerError}, nil)).To(BeTrue())
+	g.Expect(isCircuitBreakerFailure(&http.Response{StatusCode: http.StatusNotFound}, nil)).To(BeFalse())
+	g.Expect(isCircuitBreakerFailure(nil, context.DeadlineExceeded)).To(BeTrue())
+	g.Expect(isCircuitBreakerFailure(nil, context.Canceled)).To(BeFalse())
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultClientPoolCapacity is the maximum number of clients which are kept in a clientPool.
+const defaultClientPoolCapacity = 256
+
+// clientPool is a concurrency-safe LRU pool of clients. Clients are keyed by their kind and the identity of the
+// ConnectConfig they were created for, so that the frequently called Create/Delete paths reuse clients instead of
+// creating new ones for every call.
+type clientPool struct {
+	sync.Mutex
+	capacity int
+	lru      *list.List
+	entries  map[string]*list.Element
+}
+
+type pooledClient struct {
+	key           string
+	credentialKey string
+	client        any
+}
+
+func newClientPool(capacity int) *clientPool {
+	return &clientPool{
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// getPooledClient returns the client of the given kind for the passed ConnectConfig from the pool. If there is none, it
+// is created using the create function and added to the pool, evicting the least recently used client if the pool is full.
+// If pool is nil, a new client is created on every call.
+func getPooledClient[T any](pool *clientPool, kind string, connectConfig ConnectConfig, create func() (T, error)) (T, error) {
+	if pool == nil {
+		return create()
+	}
+
+	credentialKey := credentialCacheKey(connectConfig)
+	key := kind + "/" + credentialKey
+	pool.Lock()
+	if elem, ok := pool.entries[key]; ok {
+		pool.lru.MoveToFront(elem)
+		pool.Unlock()
+		return elem.Value.(*pooledClient).client.(T), nil
+	}
+	pool.Unlock()
+
+	// the client is created without holding the lock, as creating the credential of the client can evict clients from the pool
+	client, err := create()
+	if err != nil {
+		return client, err
+	}
+
+	pool.Lock()
+	defer pool.Unlock()
+	if elem, ok := pool.entries[key]; ok {
+		// the client has been created concurrently, use the pooled one
+		pool.lru.MoveToFront(elem)
+		return elem.Value.(*pooledClient).client.(T), nil
+	}
+	pool.entries[key] = pool.lru.PushFront(&pooledClient{key: key, credentialKey: credentialKey, client: client})
+	if pool.lru.Len() > pool.capacity {
+		oldest := pool.lru.Back()
+		pool.lru.Remove(oldest)
+		delete(pool.entries, oldest.Value.(*pooledClient).key)
+	}
+	return client, nil
+}
+
+// evictCredential evicts all clients which have been created for the credential with the given key.
+func (p *clientPool) evictCredential(credentialKey string) {
+	p.Lock()
+	defer p.Unlock()
+	for elem := p.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if pc := elem.Value.(*pooledClient); pc.credentialKey == credentialKey {
+			p.lru.Remove(elem)
+			delete(p.entries, pc.key)
+		}
+		elem = next
+	}
+}
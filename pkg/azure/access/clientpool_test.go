@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+type fakeClient struct {
+	name string
+}
+
+func TestGetPooledClient(t *testing.T) {
+	var created int
+	create := func(name string) func() (*fakeClient, error) {
+		return func() (*fakeClient, error) {
+			created++
+			return &fakeClient{name: name}, nil
+		}
+	}
+	configA := ConnectConfig{TenantID: "tenant", ClientID: "client", SubscriptionID: "subscription-a"}
+	configB := ConnectConfig{TenantID: "tenant", ClientID: "client", SubscriptionID: "subscription-b"}
+	configC := ConnectConfig{TenantID: "tenant", ClientID: "client", SubscriptionID: "subscription-c"}
+
+	tests := []struct {
+		description     string
+		kind            string
+		connectConfig   ConnectConfig
+		expectedName    string
+		expectedCreated int
+	}{
+		{description: "first call creates a client", kind: "vm", connectConfig: configA, expectedName: "a", expectedCreated: 1},
+		{description: "same kind and config reuses the pooled client", kind: "vm", connectConfig: configA, expectedName: "a", expectedCreated: 1},
+		{description: "other kind creates a new client", kind: "nic", connectConfig: configA, expectedName: "a", expectedCreated: 2},
+		{description: "other config creates a new client and evicts the least recently used one", kind: "vm", connectConfig: configB, expectedName: "b", expectedCreated: 3},
+		{description: "recently used client is still pooled", kind: "nic", connectConfig: configA, expectedName: "a", expectedCreated: 3},
+		{description: "evicted client is created again", kind: "vm", connectConfig: configA, expectedName: "a", expectedCreated: 4},
+		{description: "client of other config is pooled", kind: "vm", connectConfig: configC, expectedName: "c", expectedCreated: 5},
+	}
+
+	g := NewWithT(t)
+	pool := newClientPool(2)
+	for _, test := range tests {
+		t.Run(test.description, func(_ *testing.T) {
+			client, err := getPooledClient(pool, test.kind, test.connectConfig, create(test.expectedName))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(client.name).To(Equal(test.expectedName))
+			g.Expect(created).To(Equal(test.expectedCreated))
+			g.Expect(pool.lru.Len()).To(BeNumerically("<=", 2))
+		})
+	}
+
+	t.Run("errors are not pooled", func(_ *testing.T) {
+		_, err := getPooledClient(pool, "disk", configA, func() (*fakeClient, error) { return nil, fmt.Errorf("failed") })
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(pool.entries).To(HaveLen(2))
+	})
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"k8s.io/klog/v2"
+)
+
+// credentialCacheTTL is the duration after which a cached credential, which has not been used, is evicted from the cache.
+const credentialCacheTTL = 30 * time.Minute
+
+// credentialCache caches token credentials per identity. The credentials of the Azure SDK themselves cache the tokens
+// they acquire and refresh them before they expire, so reusing a credential across driver calls avoids fetching a new
+// token from Microsoft Entra ID for every call.
+//
+// All secret data of the ConnectConfig is part of the cache key, hence a rotated secret never hits the credential of the
+// old secret. The content of the WorkloadIdentityTokenFile is not part of the key, as the credential re-reads the file
+// whenever it acquires a token.
+//
+// Invalidation is lazy: the cache does not watch the secrets. When the secret of an identity is rotated, the credential
+// for the old secret is evicted as soon as the new secret is used for the first time, and onRotation is called, so that
+// everything created with the old credential can be evicted as well. Credentials of secrets which are not used anymore
+// are only evicted after credentialCacheTTL. As the secret is passed along with every driver call, a rotation takes
+// effect without a restart.
+type credentialCache struct {
+	sync.Mutex
+	provider TokenCredentialProvider
+	entries  map[string]*cachedCredential
+	// identities maps the key of an identity to the key of its current credential.
+	identities map[string]string
+	// onRotation is called with the key of the evicted credential if the secret of an identity has been rotated.
+	onRotation func(oldKey string)
+	// now is used to get the current time. It can be replaced in unit tests.
+	now func() time.Time
+}
+
+type cachedCredential struct {
+	credential  azcore.TokenCredential
+	identityKey string
+	lastUsed    time.Time
+}
+
+func newCredentialCache(provider TokenCredentialProvider) *credentialCache {
+	return &credentialCache{
+		provider:   provider,
+		entries:    make(map[string]*cachedCredential),
+		identities: make(map[string]string),
+		now:        time.Now,
+	}
+}
+
+// getTokenCredential returns the cached credential for the passed ConnectConfig or creates and caches a new one.
+func (c *credentialCache) getTokenCredential(connectConfig ConnectConfig) (azcore.TokenCredential, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := c.now()
+	c.evictExpired(now)
+
+	key := credentialCacheKey(connectConfig)
+	if entry, ok := c.entries[key]; ok {
+		entry.lastUsed = now
+		return entry.credential, nil
+	}
+	credential, err := c.provider(connectConfig)
+	if err != nil {
+		return nil, err
+	}
+	identityKey := credentialIdentityKey(connectConfig)
+	if oldKey, ok := c.identities[identityKey]; ok {
+		// the rotation is logged with the first token request, as only the request carries the context of the operation.
+		credential = &rotatedTokenCredential{TokenCredential: credential, connectConfig: connectConfig}
+		delete(c.entries, oldKey)
+		if c.onRotation != nil {
+			c.onRotation(oldKey)
+		}
+	}
+	c.identities[identityKey] = key
+	c.entries[key] = &cachedCredential{credential: credential, identityKey: identityKey, lastUsed: now}
+	return credential, nil
+}
+
+// rotatedTokenCredential is a credential which replaced the credential of a rotated secret. It logs the rotation with the
+// logger of the first request it acquires a token for.
+type rotatedTokenCredential struct {
+	azcore.TokenCredential
+	connectConfig ConnectConfig
+	logged        sync.Once
+}
+
+func (r *rotatedTokenCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	r.logged.Do(func() {
+		klog.FromContext(ctx).Info("Credentials have been rotated, evicted cached credential and clients", "tenantID", r.connectConfig.TenantID, "clientID", r.connectConfig.ClientID, "subscriptionID", r.connectConfig.SubscriptionID)
+	})
+	return r.TokenCredential.GetToken(ctx, options)
+}
+
+func (c *credentialCache) evictExpired(now time.Time) {
+	for key, entry := range c.entries {
+		if now.Sub(entry.lastUsed) > credentialCacheTTL {
+			delete(c.entries, key)
+			if c.identities[entry.identityKey] == key {
+				delete(c.identities, entry.identityKey)
+			}
+		}
+	}
+}
+
+// credentialIdentityKey computes the key identifying an identity independent of its secret.
+func credentialIdentityKey(connectConfig ConnectConfig) string {
+	return hashKeyParts(
+		connectConfig.TenantID,
+		connectConfig.ClientID,
+		connectConfig.SubscriptionID,
+		connectConfig.ClientOptions.Cloud.ActiveDirectoryAuthorityHost,
+		strings.Join(connectConfig.AuxiliaryTenantIDs, ","),
+	)
+}
+
+// credentialCacheKey computes the key identifying a credential. The subscription is part of the key so that credentials
+// are never shared across subscriptions, the client secret is part of it so that a rotated secret results in a new credential.
+func credentialCacheKey(connectConfig ConnectConfig) string {
+	return hashKeyParts(
+		credentialIdentityKey(connectConfig),
+		connectConfig.ClientSecret,
+		connectConfig.WorkloadIdentityTokenFile,
+	)
+}
+
+func hashKeyParts(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
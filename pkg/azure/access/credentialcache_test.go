@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	. "github.com/onsi/gomega"
+)
+
+type fakeTokenCredential struct {
+	id int
+}
+
+func (f *fakeTokenCredential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{}, nil
+}
+
+func TestCredentialCache(t *testing.T) {
+	var created int
+	provider := func(_ ConnectConfig) (azcore.TokenCredential, error) {
+		created++
+		return &fakeTokenCredential{id: created}, nil
+	}
+	connectConfig := ConnectConfig{TenantID: "tenant", ClientID: "client", SubscriptionID: "subscription", ClientSecret: "secret"}
+
+	tests := []struct {
+		description     string
+		connectConfig   ConnectConfig
+		elapsed         time.Duration
+		expectedCreated int
+	}{
+		{description: "first call creates a credential", connectConfig: connectConfig, expectedCreated: 1},
+		{description: "same identity reuses the cached credential", connectConfig: connectConfig, elapsed: credentialCacheTTL / 2, expectedCreated: 1},
+		{description: "other subscription creates a new credential", connectConfig: ConnectConfig{TenantID: "tenant", ClientID: "client", SubscriptionID: "other", ClientSecret: "secret"}, expectedCreated: 2},
+		{description: "rotated client secret creates a new credential", connectConfig: ConnectConfig{TenantID: "tenant", ClientID: "client", SubscriptionID: "subscription", ClientSecret: "rotated"}, expectedCreated: 3},
+		{description: "credential unused for longer than the TTL is evicted", connectConfig: connectConfig, elapsed: 2 * credentialCacheTTL, expectedCreated: 4},
+	}
+
+	g := NewWithT(t)
+	now := time.Now()
+	cache := newCredentialCache(provider)
+	cache.now = func() time.Time { return now }
+	for _, test := range tests {
+		t.Run(test.description, func(_ *testing.T) {
+			now = now.Add(test.elapsed)
+			credential, err := cache.getTokenCredential(test.connectConfig)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(created).To(Equal(test.expectedCreated))
+			g.Expect(credential).ToNot(BeNil())
+		})
+	}
+}
+
+func TestCredentialCacheRotation(t *testing.T) {
+	g := NewWithT(t)
+	provider := func(_ ConnectConfig) (azcore.TokenCredential, error) {
+		return &fakeTokenCredential{}, nil
+	}
+	oldConfig := ConnectConfig{TenantID: "tenant", ClientID: "client", SubscriptionID: "subscription", ClientSecret: "old"}
+	newConfig := ConnectConfig{TenantID: "tenant", ClientID: "client", SubscriptionID: "subscription", ClientSecret: "new"}
+
+	pool := newClientPool(defaultClientPoolCapacity)
+	cache := newCredentialCache(provider)
+	var rotatedKeys []string
+	cache.onRotation = func(oldKey string) {
+		rotatedKeys = append(rotatedKeys, oldKey)
+		pool.evictCredential(oldKey)
+	}
+
+	_, err := getPooledClient(pool, ResourceTypeVirtualMachines, oldConfig, func() (*fakeTokenCredential, error) {
+		credential, err := cache.getTokenCredential(oldConfig)
+		return credential.(*fakeTokenCredential), err
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pool.entries).To(HaveLen(1))
+	g.Expect(rotatedKeys).To(BeEmpty())
+
+	newCredential, err := getPooledClient(pool, ResourceTypeVirtualMachines, newConfig, func() (azcore.TokenCredential, error) {
+		return cache.getTokenCredential(newConfig)
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	// the rotation is logged with the context of the first token request
+	g.Expect(newCredential).To(BeAssignableToTypeOf(&rotatedTokenCredential{}))
+	_, err = newCredential.GetToken(context.Background(), policy.TokenRequestOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(rotatedKeys).To(ConsistOf(credentialCacheKey(oldConfig)))
+	g.Expect(cache.entries).To(HaveLen(1))
+	g.Expect(cache.entries).To(HaveKey(credentialCacheKey(newConfig)))
+	// only the client of the new credential is left in the pool
+	g.Expect(pool.entries).To(HaveLen(1))
+	g.Expect(pool.entries).To(HaveKey(ResourceTypeVirtualMachines + "/" + credentialCacheKey(newConfig)))
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// FactoryStats are the statistics of a Factory which help to diagnose goroutine leaks and slow reconciles.
+type FactoryStats struct {
+	// PooledClients is the number of clients which are currently kept in the client pool.
+	PooledClients int `json:"pooledClients"`
+	// ClientPoolCapacity is the maximum number of clients which are kept in the client pool.
+	ClientPoolCapacity int `json:"clientPoolCapacity"`
+	// CachedCredentials is the number of token credentials which are currently cached.
+	CachedCredentials int `json:"cachedCredentials"`
+	// InFlightRequests are the requests against Azure which have been sent but not yet been answered, oldest first.
+	InFlightRequests []InFlightRequest `json:"inFlightRequests"`
+}
+
+// InFlightRequest is a request against Azure which has been sent but not yet been answered.
+type InFlightRequest struct {
+	// SubscriptionID is the subscription for which the request has been sent.
+	SubscriptionID string `json:"subscriptionID"`
+	// Method is the HTTP method of the request.
+	Method string `json:"method"`
+	// URI is the URI of the request, see redactURL.
+	URI string `json:"uri"`
+	// Started is the time at which the request has been sent.
+	Started time.Time `json:"started"`
+	// Duration is the time which has passed since the request has been sent.
+	Duration string `json:"duration"`
+}
+
+// StatsProvider is implemented by factories which provide FactoryStats, e.g. the factory created by NewDefaultAccessFactoryWithOptions.
+type StatsProvider interface {
+	// Stats returns the current statistics of the factory.
+	Stats() FactoryStats
+}
+
+// Stats returns the current statistics of the factory.
+func (f defaultFactory) Stats() FactoryStats {
+	var stats FactoryStats
+	if f.clientPool != nil {
+		f.clientPool.Lock()
+		stats.PooledClients = f.clientPool.lru.Len()
+		stats.ClientPoolCapacity = f.clientPool.capacity
+		f.clientPool.Unlock()
+	}
+	if f.credentials != nil {
+		f.credentials.Lock()
+		stats.CachedCredentials = len(f.credentials.entries)
+		f.credentials.Unlock()
+	}
+	if f.inFlight != nil {
+		stats.InFlightRequests = f.inFlight.list()
+	}
+	return stats
+}
+
+// NewStatsHandler returns a http.Handler which serves the FactoryStats of the passed StatsProvider as JSON.
+func NewStatsHandler(provider StatsProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(provider.Stats()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// inFlightTracker tracks the requests against Azure which have been sent but not yet been answered.
+type inFlightTracker struct {
+	sync.Mutex
+	nextID   uint64
+	requests map[uint64]InFlightRequest
+}
+
+func newInFlightTracker() *inFlightTracker {
+	return &inFlightTracker{requests: make(map[uint64]InFlightRequest)}
+}
+
+// add tracks the passed request and returns the function which stops tracking it.
+func (t *inFlightTracker) add(request InFlightRequest) func() {
+	t.Lock()
+	defer t.Unlock()
+	id := t.nextID
+	t.nextID++
+	t.requests[id] = request
+	return func() {
+		t.Lock()
+		defer t.Unlock()
+		delete(t.requests, id)
+	}
+}
+
+// list returns the tracked requests, oldest first.
+func (t *inFlightTracker) list() []InFlightRequest {
+	t.Lock()
+	defer t.Unlock()
+	requests := make([]InFlightRequest, 0, len(t.requests))
+	for _, request := range t.requests {
+		request.Duration = time.Since(request.Started).Round(time.Millisecond).String()
+		requests = append(requests, request)
+	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Started.Before(requests[j].Started) })
+	return requests
+}
+
+// inFlightPolicy is a per-retry policy which tracks every request against Azure while it is in flight.
+type inFlightPolicy struct {
+	tracker        *inFlightTracker
+	subscriptionID string
+}
+
+func (p inFlightPolicy) Do(req *policy.Request) (*http.Response, error) {
+	done := p.tracker.add(InFlightRequest{
+		SubscriptionID: p.subscriptionID,
+		Method:         req.Raw().Method,
+		URI:            redactURL(req.Raw().URL),
+		Started:        time.Now(),
+	})
+	defer done()
+	return req.Next()
+}
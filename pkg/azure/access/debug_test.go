@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	. "github.com/onsi/gomega"
+)
+
+// blockingTransport blocks every request until it is released.
+type blockingTransport struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTransport) Do(req *http.Request) (*http.Response, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return (&fakeTransport{statusCodes: []int{http.StatusOK}}).Do(req)
+}
+
+func TestInFlightPolicy(t *testing.T) {
+	g := NewWithT(t)
+	tracker := newInFlightTracker()
+	transport := &blockingTransport{started: make(chan struct{}), release: make(chan struct{})}
+	pipeline := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:        transport,
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+		PerRetryPolicies: []policy.Policy{inFlightPolicy{tracker: tracker, subscriptionID: testSubscriptionID}},
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := sendTestRequest(context.Background(), pipeline)
+		done <- err
+	}()
+	<-transport.started
+	requests := tracker.list()
+	g.Expect(requests).To(HaveLen(1))
+	g.Expect(requests[0].SubscriptionID).To(Equal(testSubscriptionID))
+	g.Expect(requests[0].Method).To(Equal(http.MethodGet))
+	g.Expect(requests[0].URI).To(Equal("https://management.azure.com/subscriptions/" + testSubscriptionID))
+
+	close(transport.release)
+	g.Expect(<-done).To(Succeed())
+	g.Expect(tracker.list()).To(BeEmpty())
+}
+
+func TestStatsHandler(t *testing.T) {
+	g := NewWithT(t)
+	f := NewDefaultAccessFactory().(defaultFactory)
+	_, err := getPooledClient(f.clientPool, "test", ConnectConfig{SubscriptionID: testSubscriptionID}, func() (string, error) { return "client", nil })
+	g.Expect(err).ToNot(HaveOccurred())
+	done := f.inFlight.add(InFlightRequest{SubscriptionID: testSubscriptionID, Method: http.MethodGet})
+	defer done()
+
+	recorder := httptest.NewRecorder()
+	NewStatsHandler(f).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/azure", nil))
+	g.Expect(recorder.Code).To(Equal(http.StatusOK))
+	var stats FactoryStats
+	g.Expect(json.Unmarshal(recorder.Body.Bytes(), &stats)).To(Succeed())
+	g.Expect(stats.PooledClients).To(Equal(1))
+	g.Expect(stats.ClientPoolCapacity).To(Equal(defaultClientPoolCapacity))
+	g.Expect(stats.InFlightRequests).To(HaveLen(1))
+}
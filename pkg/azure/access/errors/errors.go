@@ -5,13 +5,21 @@
 package errors
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 )
@@ -19,6 +27,66 @@ import (
 const (
 	// ZonalAllocationFailedAzErrorCode is an Azure error code indicating that there is insufficient capacity in the target zone.
 	ZonalAllocationFailedAzErrorCode = "ZonalAllocationFailed"
+	// AllocationFailedAzErrorCode is an Azure error code indicating that the VM could not be allocated because there is
+	// insufficient capacity for the requested VM size.
+	AllocationFailedAzErrorCode = "AllocationFailed"
+	// QuotaExceededAzErrorCode is an Azure error code indicating that a quota of the subscription has been exceeded.
+	QuotaExceededAzErrorCode = "QuotaExceeded"
+	// OperationNotAllowedAzErrorCode is an Azure error code indicating that the operation is not allowed. Among other reasons
+	// it is returned if the operation would exceed a quota of the subscription, e.g. the regional vCPU quota.
+	OperationNotAllowedAzErrorCode = "OperationNotAllowed"
+	// SkuNotAvailableAzErrorCode is an Azure error code indicating that the requested VM size is currently not available
+	// in the location or zone.
+	SkuNotAvailableAzErrorCode = "SkuNotAvailable"
+	// ConflictAzErrorCode is an Azure error code indicating that the operation conflicts with the current state of the
+	// resource, e.g. because another operation on the resource is still in progress.
+	ConflictAzErrorCode = "Conflict"
+	// AnotherOperationInProgressAzErrorCode is an Azure error code indicating that another operation on the resource is in progress.
+	AnotherOperationInProgressAzErrorCode = "AnotherOperationInProgress"
+	// RetryableErrorAzErrorCode is an Azure error code indicating a transient failure which resolves by retrying the operation.
+	RetryableErrorAzErrorCode = "RetryableError"
+	// InternalExecutionErrorAzErrorCode is an Azure error code indicating a transient failure in the resource provider.
+	InternalExecutionErrorAzErrorCode = "InternalExecutionError"
+	// InternalServerErrorAzErrorCode is an Azure error code indicating a transient server side failure.
+	InternalServerErrorAzErrorCode = "InternalServerError"
+	// ServiceUnavailableAzErrorCode is an Azure error code indicating that the service is temporarily unavailable.
+	ServiceUnavailableAzErrorCode = "ServiceUnavailable"
+	// GatewayTimeoutAzErrorCode is an Azure error code indicating that the request timed out in Azure Resource Manager.
+	GatewayTimeoutAzErrorCode = "GatewayTimeout"
+	// AuthorizationFailedAzErrorCode is an Azure error code indicating that the identity lacks the permission for the operation.
+	AuthorizationFailedAzErrorCode = "AuthorizationFailed"
+	// LinkedAuthorizationFailedAzErrorCode is an Azure error code indicating that the identity lacks the permission for
+	// a resource which is referenced by the operation, e.g. a subnet in another resource group.
+	LinkedAuthorizationFailedAzErrorCode = "LinkedAuthorizationFailed"
+	// InvalidAuthenticationTokenAzErrorCode is an Azure error code indicating that the access token is invalid or expired.
+	InvalidAuthenticationTokenAzErrorCode = "InvalidAuthenticationToken"
+	// InvalidParameterAzErrorCode is an Azure error code indicating that a parameter of the request is invalid.
+	InvalidParameterAzErrorCode = "InvalidParameter"
+	// InvalidRequestContentAzErrorCode is an Azure error code indicating that the request body can not be processed.
+	InvalidRequestContentAzErrorCode = "InvalidRequestContent"
+	// InvalidResourceReferenceAzErrorCode is an Azure error code indicating that a resource referenced by the request does not exist.
+	InvalidResourceReferenceAzErrorCode = "InvalidResourceReference"
+	// MissingSubscriptionRegistrationAzErrorCode is an Azure error code indicating that the subscription is not registered
+	// for the resource provider.
+	MissingSubscriptionRegistrationAzErrorCode = "MissingSubscriptionRegistration"
+	// ResourceNotFoundAzErrorCode is an Azure error code indicating that the resource does not exist.
+	ResourceNotFoundAzErrorCode = "ResourceNotFound"
+	// ResourceGroupNotFoundAzErrorCode is an Azure error code indicating that the resource group does not exist.
+	ResourceGroupNotFoundAzErrorCode = "ResourceGroupNotFound"
+	// SubscriptionNotFoundAzErrorCode is an Azure error code indicating that the subscription does not exist.
+	SubscriptionNotFoundAzErrorCode = "SubscriptionNotFound"
+	// ScopeLockedAzErrorCode is an Azure error code indicating that the operation is prevented by a resource lock on the
+	// resource, its resource group or its subscription.
+	ScopeLockedAzErrorCode = "ScopeLocked"
+	// ReadOnlyDisabledSubscriptionAzErrorCode is an Azure error code indicating that the subscription is disabled and
+	// therefore only allows read operations.
+	ReadOnlyDisabledSubscriptionAzErrorCode = "ReadOnlyDisabledSubscription"
+	// RequestDisallowedByPolicyAzErrorCode is an Azure error code indicating that the request has been denied by an
+	// Azure Policy assignment.
+	RequestDisallowedByPolicyAzErrorCode = "RequestDisallowedByPolicy"
+	// policyViolationAdditionalInfoType is the type of the additional info entries of an Azure error response which
+	// describe the Azure Policy assignment that denied the request.
+	policyViolationAdditionalInfoType = "PolicyViolation"
 	// CorrelationRequestIDAzHeaderKey is the Azure API response header key whose value is a request correlation ID.
 	CorrelationRequestIDAzHeaderKey = "x-ms-correlation-request-id"
 	// RequestIDAzHeaderKey is the Azure API response header key whose value is the request ID.
@@ -27,8 +95,51 @@ const (
 	ErrorCodeAzHeaderKey = "x-ms-error-code"
 	// ClientRequestIDAzHeaderKey is the Azure API response header key whose value is the client request ID.
 	ClientRequestIDAzHeaderKey = "x-ms-client-request-id"
+	// RetryAfterHeaderKey is the response header key whose value is the number of seconds or the date after which a
+	// throttled request can be retried.
+	RetryAfterHeaderKey = "Retry-After"
+	// RetryAfterMsAzHeaderKey is the Azure API response header key whose value is the number of milliseconds after which a
+	// throttled request can be retried.
+	RetryAfterMsAzHeaderKey = "retry-after-ms"
+	// XMSRetryAfterMsAzHeaderKey is an alternative of RetryAfterMsAzHeaderKey.
+	XMSRetryAfterMsAzHeaderKey = "x-ms-retry-after-ms"
 )
 
+// azErrorCodeMapping maps Azure error codes to the codes.Code which is returned to MCM. Errors which are expected to
+// resolve by retrying the operation are mapped to codes which MCM retries after a short period (codes.Unavailable,
+// codes.Aborted and codes.DeadlineExceeded). Terminal errors are mapped to the code which describes why the operation
+// can not succeed. Azure error codes which are not contained are mapped to codes.Internal.
+// For additional information see: [https://learn.microsoft.com/en-us/azure/azure-resource-manager/troubleshooting/common-deployment-errors]
+var azErrorCodeMapping = map[string]codes.Code{
+	// retryable errors
+	ConflictAzErrorCode:                   codes.Aborted,
+	AnotherOperationInProgressAzErrorCode: codes.Aborted,
+	RetryableErrorAzErrorCode:             codes.Unavailable,
+	InternalExecutionErrorAzErrorCode:     codes.Unavailable,
+	InternalServerErrorAzErrorCode:        codes.Unavailable,
+	ServiceUnavailableAzErrorCode:         codes.Unavailable,
+	GatewayTimeoutAzErrorCode:             codes.DeadlineExceeded,
+	// capacity and quota errors
+	ZonalAllocationFailedAzErrorCode: codes.ResourceExhausted,
+	AllocationFailedAzErrorCode:      codes.ResourceExhausted,
+	QuotaExceededAzErrorCode:         codes.ResourceExhausted,
+	SkuNotAvailableAzErrorCode:       codes.ResourceExhausted,
+	// terminal errors
+	AuthorizationFailedAzErrorCode:             codes.PermissionDenied,
+	LinkedAuthorizationFailedAzErrorCode:       codes.PermissionDenied,
+	InvalidAuthenticationTokenAzErrorCode:      codes.Unauthenticated,
+	InvalidParameterAzErrorCode:                codes.InvalidArgument,
+	InvalidRequestContentAzErrorCode:           codes.InvalidArgument,
+	InvalidResourceReferenceAzErrorCode:        codes.InvalidArgument,
+	RequestDisallowedByPolicyAzErrorCode:       codes.InvalidArgument,
+	MissingSubscriptionRegistrationAzErrorCode: codes.FailedPrecondition,
+	ScopeLockedAzErrorCode:                     codes.FailedPrecondition,
+	ReadOnlyDisabledSubscriptionAzErrorCode:    codes.FailedPrecondition,
+	ResourceNotFoundAzErrorCode:                codes.NotFound,
+	ResourceGroupNotFoundAzErrorCode:           codes.NotFound,
+	SubscriptionNotFoundAzErrorCode:            codes.NotFound,
+}
+
 var (
 	// Raised https://github.com/Azure/azure-sdk-for-go/issues/21094 to prevent hard coding these here and instead
 	// use well-maintained constants defined in the Azure SDK.
@@ -78,17 +189,255 @@ func traceResponseHeaders(err error) map[string]string {
 	return headers
 }
 
-// GetMatchingErrorCode gets a matching codes.Code for the given azure error code.
+// RequestIDs are the IDs of a request to the Azure API. Azure support requires them to investigate a failed request.
+type RequestIDs struct {
+	// RequestID is the value of the x-ms-request-id response header.
+	RequestID string `json:"requestID,omitempty"`
+	// CorrelationRequestID is the value of the x-ms-correlation-request-id response header.
+	CorrelationRequestID string `json:"correlationRequestID,omitempty"`
+}
+
+func (r RequestIDs) String() string {
+	return fmt.Sprintf("RequestID: %s, CorrelationRequestID: %s", r.RequestID, r.CorrelationRequestID)
+}
+
+// GetRequestIDs returns the RequestIDs of the AZ API response which caused the error, the error can also be a status.Status
+// caused by an AZ API error. Nil is returned if the error has not been caused by an AZ API response carrying request IDs.
+func GetRequestIDs(err error) *RequestIDs {
+	respErr := asResponseError(err)
+	if respErr == nil || respErr.RawResponse == nil {
+		return nil
+	}
+	requestIDs := &RequestIDs{
+		RequestID:            respErr.RawResponse.Header.Get(RequestIDAzHeaderKey),
+		CorrelationRequestID: respErr.RawResponse.Header.Get(CorrelationRequestIDAzHeaderKey),
+	}
+	if requestIDs.RequestID == "" && requestIDs.CorrelationRequestID == "" {
+		return nil
+	}
+	return requestIDs
+}
+
+// AddRequestIDs adds the RequestIDs of the AZ API response which caused the error to the message of the error, so that
+// they are visible in the status of the machine. If the error does not carry the response, the RequestIDs of the last
+// failed response recorded in ctx are added, see WithRequestIDsRecorder. The code and the cause of a status.Status are retained.
+func AddRequestIDs(ctx context.Context, err *error) {
+	if *err == nil {
+		return
+	}
+	requestIDs := GetRequestIDsFromContext(ctx, *err)
+	if requestIDs == nil || strings.Contains((*err).Error(), requestIDs.String()) {
+		return
+	}
+	var statusErr *status.Status
+	if errors.As(*err, &statusErr) {
+		*err = status.WrapError(statusErr.Code(), fmt.Sprintf("%s [%s]", statusErr.Message(), requestIDs), statusErr.Cause())
+		return
+	}
+	*err = fmt.Errorf("%w [%s]", *err, requestIDs)
+}
+
+// GetPolicyAssignmentNames returns the names of the Azure Policy assignments which denied the request, if the error has
+// been caused by a RequestDisallowedByPolicy AZ API error. The error can also be a status.Status caused by such an error.
+func GetPolicyAssignmentNames(err error) []string {
+	respErr := asResponseError(err)
+	if respErr == nil || respErr.ErrorCode != RequestDisallowedByPolicyAzErrorCode || respErr.RawResponse == nil {
+		return nil
+	}
+	body, err := runtime.Payload(respErr.RawResponse)
+	if err != nil {
+		return nil
+	}
+	var errResp struct {
+		Error struct {
+			AdditionalInfo []struct {
+				Type string `json:"type"`
+				Info struct {
+					PolicyAssignmentName string `json:"policyAssignmentName"`
+				} `json:"info"`
+			} `json:"additionalInfo"`
+		} `json:"error"`
+	}
+	if err = json.Unmarshal(body, &errResp); err != nil {
+		return nil
+	}
+	var names []string
+	for _, additionalInfo := range errResp.Error.AdditionalInfo {
+		name := additionalInfo.Info.PolicyAssignmentName
+		if additionalInfo.Type == policyViolationAdditionalInfoType && !utils.IsEmptyString(name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ClassifyPolicyDenial converts an error caused by a RequestDisallowedByPolicy AZ API error into a status.Status with
+// codes.InvalidArgument whose message contains the names of the denying Azure Policy assignments. Retrying such an
+// operation can not succeed until the policy assignment or the machine class is changed.
+func ClassifyPolicyDenial(err *error) {
+	respErr := asResponseError(*err)
+	if respErr == nil || respErr.ErrorCode != RequestDisallowedByPolicyAzErrorCode {
+		return
+	}
+	msg := (*err).Error()
+	var statusErr *status.Status
+	if errors.As(*err, &statusErr) {
+		msg = statusErr.Message()
+	}
+	if names := GetPolicyAssignmentNames(*err); len(names) > 0 {
+		if policyMsg := fmt.Sprintf("[denied by Azure Policy assignments: %s]", strings.Join(names, ", ")); !strings.Contains(msg, policyMsg) {
+			msg = fmt.Sprintf("%s %s", msg, policyMsg)
+		}
+	}
+	*err = status.WrapError(codes.InvalidArgument, msg, respErr)
+}
+
+// asResponseError returns the AZ API error which caused the error, the error can also be a status.Status caused by an AZ API
+// error. Nil is returned if the error has not been caused by an AZ API error.
+func asResponseError(err error) *azcore.ResponseError {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr
+	}
+	var statusErr *status.Status
+	if errors.As(err, &statusErr) && errors.As(statusErr.Cause(), &respErr) {
+		return respErr
+	}
+	return nil
+}
+
+// ThrottledError is returned for requests which are not sent to Azure, because requests for the subscription are throttled
+// and the throttling does not end before the deadline of the request.
+type ThrottledError struct {
+	// SubscriptionID is the ID of the throttled subscription.
+	SubscriptionID string
+	// RetryAfter is the remaining duration of the throttling.
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("requests for subscription %s are throttled by Azure, retry after %s", e.SubscriptionID, e.RetryAfter)
+}
+
+// CircuitOpenError is returned for requests which are not sent to Azure, because the circuit breaker of the subscription
+// tripped after consecutive server errors or timeouts. It is mapped to codes.Unavailable, since Azure is considered
+// unavailable until the circuit breaker closes again.
+type CircuitOpenError struct {
+	// SubscriptionID is the ID of the subscription whose circuit breaker is open.
+	SubscriptionID string
+	// RetryAfter is the remaining duration for which the circuit breaker is open.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for subscription %s is open after consecutive failed requests to Azure Resource Manager, request has not been sent, retry after %s", e.SubscriptionID, e.RetryAfter)
+}
+
+// IsCircuitOpenError checks if error is a CircuitOpenError.
+func IsCircuitOpenError(err error) bool {
+	var circuitOpenErr *CircuitOpenError
+	return errors.As(err, &circuitOpenErr)
+}
+
+// PollingError is returned if polling a long-running operation failed before the operation completed, e.g. because the
+// timeout of the operation expired. The operation itself might still complete in Azure.
+type PollingError struct {
+	// ResumeToken is the token of the poller which can be used to resume polling the operation.
+	ResumeToken string
+	// Err is the error which occurred while polling.
+	Err error
+}
+
+func (e *PollingError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PollingError) Unwrap() error {
+	return e.Err
+}
+
+// IsThrottledAzAPIError checks if error is an AZ API error and if it is a 429 response code, or if it is a ThrottledError.
+func IsThrottledAzAPIError(err error) bool {
+	var throttledErr *ThrottledError
+	if errors.As(err, &throttledErr) {
+		return true
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// GetRetryAfter returns the duration given by the retry headers of a throttled Azure API response. It returns zero if
+// none of the headers is set or valid.
+func GetRetryAfter(header http.Header) time.Duration {
+	for _, key := range []string{RetryAfterMsAzHeaderKey, XMSRetryAfterMsAzHeaderKey} {
+		if ms, err := strconv.Atoi(header.Get(key)); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	retryAfter := header.Get(RetryAfterHeaderKey)
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(retryAfter); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// IsResourceLockedAzAPIError checks if error is an AZ API error which is caused by a resource lock or a disabled
+// subscription. Such errors do not resolve by retrying the operation, they require the lock to be removed or the
+// subscription to be enabled again.
+func IsResourceLockedAzAPIError(err error) bool {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.ErrorCode == ScopeLockedAzErrorCode || respErr.ErrorCode == ReadOnlyDisabledSubscriptionAzErrorCode
+	}
+	return false
+}
+
+// IsAllocationFailedAzAPIError checks if error is an AZ API error which is caused by insufficient capacity to
+// allocate the VM, either in the target zone or in the region.
+func IsAllocationFailedAzAPIError(err error) bool {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.ErrorCode == ZonalAllocationFailedAzErrorCode || respErr.ErrorCode == AllocationFailedAzErrorCode
+	}
+	return false
+}
+
+// GetMatchingErrorCode gets a matching codes.Code for the given error. Errors returned by the Azure API are classified
+// using azErrorCodeMapping.
 func GetMatchingErrorCode(err error) codes.Code {
+	if IsCircuitOpenError(err) || IsThrottledAzAPIError(err) {
+		return codes.Unavailable
+	}
 	var respErr *azcore.ResponseError
 	if errors.As(err, &respErr) {
-		azErrorCode := respErr.ErrorCode
-		switch azErrorCode {
-		case ZonalAllocationFailedAzErrorCode:
+		if respErr.ErrorCode == OperationNotAllowedAzErrorCode && isQuotaAzAPIError(respErr) {
 			return codes.ResourceExhausted
-		default:
-			return codes.Internal
 		}
+		if code, ok := azErrorCodeMapping[respErr.ErrorCode]; ok {
+			return code
+		}
+		return codes.Internal
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return codes.DeadlineExceeded
 	}
 	return codes.Internal
 }
+
+// isQuotaAzAPIError checks if the message of the AZ API error refers to a quota. Azure does not use a dedicated error code
+// if an operation is not allowed since it would exceed a quota, therefore the message has to be inspected.
+func isQuotaAzAPIError(respErr *azcore.ResponseError) bool {
+	if respErr.RawResponse == nil {
+		return false
+	}
+	body, err := runtime.Payload(respErr.RawResponse)
+	return err == nil && strings.Contains(strings.ToLower(string(body)), "quota")
+}
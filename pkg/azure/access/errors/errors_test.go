@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package errors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	. "github.com/onsi/gomega"
+)
+
+func TestGetMatchingErrorCode(t *testing.T) {
+	table := []struct {
+		description  string
+		statusCode   int
+		azErrorCode  string
+		message      string
+		expectedCode codes.Code
+	}{
+		{"should map ZonalAllocationFailed to ResourceExhausted", http.StatusConflict, ZonalAllocationFailedAzErrorCode, "", codes.ResourceExhausted},
+		{"should map AllocationFailed to ResourceExhausted", http.StatusConflict, AllocationFailedAzErrorCode, "", codes.ResourceExhausted},
+		{"should map QuotaExceeded to ResourceExhausted", http.StatusConflict, QuotaExceededAzErrorCode, "", codes.ResourceExhausted},
+		{"should map SkuNotAvailable to ResourceExhausted", http.StatusConflict, SkuNotAvailableAzErrorCode, "", codes.ResourceExhausted},
+		{"should map OperationNotAllowed due to a quota to ResourceExhausted", http.StatusConflict, OperationNotAllowedAzErrorCode,
+			"Operation could not be completed as it results in exceeding approved Total Regional Cores quota.", codes.ResourceExhausted},
+		{"should map other OperationNotAllowed to Internal", http.StatusConflict, OperationNotAllowedAzErrorCode, "Operation is not allowed on a disk which is attached.", codes.Internal},
+		{"should map ScopeLocked to FailedPrecondition", http.StatusConflict, ScopeLockedAzErrorCode, "", codes.FailedPrecondition},
+		{"should map throttled requests to Unavailable", http.StatusTooManyRequests, "TooManyRequests", "", codes.Unavailable},
+		{"should map Conflict to Aborted", http.StatusConflict, ConflictAzErrorCode, "", codes.Aborted},
+		{"should map RetryableError to Unavailable", http.StatusInternalServerError, RetryableErrorAzErrorCode, "", codes.Unavailable},
+		{"should map InternalExecutionError to Unavailable", http.StatusInternalServerError, InternalExecutionErrorAzErrorCode, "", codes.Unavailable},
+		{"should map GatewayTimeout to DeadlineExceeded", http.StatusGatewayTimeout, GatewayTimeoutAzErrorCode, "", codes.DeadlineExceeded},
+		{"should map AuthorizationFailed to PermissionDenied", http.StatusForbidden, AuthorizationFailedAzErrorCode, "", codes.PermissionDenied},
+		{"should map InvalidAuthenticationToken to Unauthenticated", http.StatusUnauthorized, InvalidAuthenticationTokenAzErrorCode, "", codes.Unauthenticated},
+		{"should map InvalidParameter to InvalidArgument", http.StatusBadRequest, InvalidParameterAzErrorCode, "", codes.InvalidArgument},
+		{"should map MissingSubscriptionRegistration to FailedPrecondition", http.StatusConflict, MissingSubscriptionRegistrationAzErrorCode, "", codes.FailedPrecondition},
+		{"should map RequestDisallowedByPolicy to InvalidArgument", http.StatusForbidden, RequestDisallowedByPolicyAzErrorCode, "", codes.InvalidArgument},
+		{"should map ResourceNotFound to NotFound", http.StatusNotFound, ResourceNotFoundAzErrorCode, "", codes.NotFound},
+		{"should map unknown errors to Internal", http.StatusInternalServerError, "UnknownError", "", codes.Internal},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			headers := http.Header{}
+			headers.Set(ErrorCodeAzHeaderKey, entry.azErrorCode)
+			body := `{"error":{"code":"` + entry.azErrorCode + `","message":"` + entry.message + `"}}`
+			err := runtime.NewResponseError(&http.Response{
+				StatusCode: entry.statusCode,
+				Header:     headers,
+				Body:       io.NopCloser(strings.NewReader(body)),
+			})
+			g.Expect(GetMatchingErrorCode(err)).To(Equal(entry.expectedCode))
+		})
+	}
+}
+
+func TestGetMatchingErrorCodeForNonAzAPIErrors(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(GetMatchingErrorCode(&CircuitOpenError{SubscriptionID: "subscription-0"})).To(Equal(codes.Unavailable))
+	g.Expect(GetMatchingErrorCode(&ThrottledError{SubscriptionID: "subscription-0"})).To(Equal(codes.Unavailable))
+	g.Expect(GetMatchingErrorCode(&PollingError{Err: context.DeadlineExceeded})).To(Equal(codes.DeadlineExceeded))
+	g.Expect(GetMatchingErrorCode(errors.New("test-error"))).To(Equal(codes.Internal))
+}
+
+func TestAddRequestIDs(t *testing.T) {
+	g := NewWithT(t)
+	headers := http.Header{}
+	headers.Set(ErrorCodeAzHeaderKey, InternalExecutionErrorAzErrorCode)
+	headers.Set(RequestIDAzHeaderKey, "request-id")
+	headers.Set(CorrelationRequestIDAzHeaderKey, "correlation-request-id")
+	respErr := runtime.NewResponseError(&http.Response{StatusCode: http.StatusInternalServerError, Header: headers, Body: io.NopCloser(strings.NewReader(""))})
+	expectedRequestIDs := &RequestIDs{RequestID: "request-id", CorrelationRequestID: "correlation-request-id"}
+
+	err := error(status.WrapError(codes.Unavailable, "failed to create VM", respErr))
+	g.Expect(GetRequestIDs(err)).To(Equal(expectedRequestIDs))
+	AddRequestIDs(context.Background(), &err)
+	statusErr, ok := err.(*status.Status)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(statusErr.Code()).To(Equal(codes.Unavailable))
+	g.Expect(statusErr.Message()).To(Equal("failed to create VM [RequestID: request-id, CorrelationRequestID: correlation-request-id]"))
+	g.Expect(statusErr.Cause()).To(Equal(respErr))
+	// the request IDs are only added once
+	AddRequestIDs(context.Background(), &err)
+	g.Expect(err.(*status.Status).Message()).To(Equal(statusErr.Message()))
+
+	err = respErr
+	AddRequestIDs(context.Background(), &err)
+	g.Expect(errors.Is(err, respErr)).To(BeTrue())
+	g.Expect(err.Error()).To(HaveSuffix("[RequestID: request-id, CorrelationRequestID: correlation-request-id]"))
+
+	err = status.Error(codes.InvalidArgument, "invalid provider spec")
+	g.Expect(GetRequestIDs(err)).To(BeNil())
+	AddRequestIDs(context.Background(), &err)
+	g.Expect(err.Error()).ToNot(ContainSubstring("RequestID"))
+}
+
+func TestClassifyPolicyDenial(t *testing.T) {
+	g := NewWithT(t)
+	headers := http.Header{}
+	headers.Set(ErrorCodeAzHeaderKey, RequestDisallowedByPolicyAzErrorCode)
+	body := `{"error":{"code":"RequestDisallowedByPolicy","message":"Resource 'vm-0' was disallowed by policy.","additionalInfo":[` +
+		`{"type":"PolicyViolation","info":{"policyAssignmentName":"deny-public-ip","policyAssignmentDisplayName":"Deny public IPs"}},` +
+		`{"type":"PolicyViolation","info":{"policyAssignmentName":"allowed-vm-sizes"}},` +
+		`{"type":"PolicyViolation","info":{"policyAssignmentName":"deny-public-ip"}}]}}`
+	respErr := runtime.NewResponseError(&http.Response{StatusCode: http.StatusForbidden, Header: headers, Body: io.NopCloser(strings.NewReader(body))})
+
+	err := error(status.WrapError(codes.Internal, "failed to create VM", respErr))
+	g.Expect(GetPolicyAssignmentNames(err)).To(Equal([]string{"deny-public-ip", "allowed-vm-sizes"}))
+	ClassifyPolicyDenial(&err)
+	statusErr, ok := err.(*status.Status)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(statusErr.Code()).To(Equal(codes.InvalidArgument))
+	g.Expect(statusErr.Message()).To(Equal("failed to create VM [denied by Azure Policy assignments: deny-public-ip, allowed-vm-sizes]"))
+	g.Expect(statusErr.Cause()).To(Equal(respErr))
+	// the policy assignment names are only added once
+	ClassifyPolicyDenial(&err)
+	g.Expect(err.(*status.Status).Message()).To(Equal(statusErr.Message()))
+
+	err = status.Error(codes.Internal, "failed to create VM")
+	ClassifyPolicyDenial(&err)
+	g.Expect(err.(*status.Status).Code()).To(Equal(codes.Internal))
+}
+
+func TestAddRecordedRequestIDs(t *testing.T) {
+	g := NewWithT(t)
+	headers := http.Header{}
+	headers.Set(RequestIDAzHeaderKey, "request-id")
+	headers.Set(CorrelationRequestIDAzHeaderKey, "correlation-request-id")
+
+	// without a recorder nothing is recorded
+	RecordRequestIDs(context.Background(), headers)
+	g.Expect(GetRecordedRequestIDs(context.Background())).To(BeNil())
+
+	ctx := WithRequestIDsRecorder(context.Background())
+	err := error(status.Error(codes.DeadlineExceeded, "failed to create VM"))
+	AddRequestIDs(ctx, &err)
+	g.Expect(err.Error()).ToNot(ContainSubstring("RequestID"))
+
+	RecordRequestIDs(ctx, headers)
+	g.Expect(GetRecordedRequestIDs(ctx)).To(Equal(&RequestIDs{RequestID: "request-id", CorrelationRequestID: "correlation-request-id"}))
+	AddRequestIDs(ctx, &err)
+	g.Expect(err.(*status.Status).Code()).To(Equal(codes.DeadlineExceeded))
+	g.Expect(err.(*status.Status).Message()).To(Equal("failed to create VM [RequestID: request-id, CorrelationRequestID: correlation-request-id]"))
+
+	var noErr error
+	AddRequestIDs(ctx, &noErr)
+	g.Expect(noErr).To(BeNil())
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package errors
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// requestIDsRecorder records the RequestIDs of the last failed AZ API response of all calls made with a context.
+type requestIDsRecorder struct {
+	sync.Mutex
+	last *RequestIDs
+}
+
+type requestIDsRecorderKey struct{}
+
+// WithRequestIDsRecorder returns a copy of ctx carrying a recorder for the RequestIDs of failed AZ API responses. The
+// responses of all calls made with the returned context are recorded by the pipeline policy of the access factory, so
+// that the request IDs are known even if the returned error does not carry the response, e.g. if polling timed out.
+func WithRequestIDsRecorder(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestIDsRecorderKey{}, &requestIDsRecorder{})
+}
+
+// RecordRequestIDs records the RequestIDs contained in the header of a failed AZ API response in the recorder of ctx.
+// It is a no-op if ctx does not carry a recorder or the header does not contain any request ID.
+func RecordRequestIDs(ctx context.Context, header http.Header) {
+	recorder, ok := ctx.Value(requestIDsRecorderKey{}).(*requestIDsRecorder)
+	if !ok {
+		return
+	}
+	requestIDs := &RequestIDs{
+		RequestID:            header.Get(RequestIDAzHeaderKey),
+		CorrelationRequestID: header.Get(CorrelationRequestIDAzHeaderKey),
+	}
+	if requestIDs.RequestID == "" && requestIDs.CorrelationRequestID == "" {
+		return
+	}
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.last = requestIDs
+}
+
+// GetRecordedRequestIDs returns the RequestIDs of the last failed AZ API response recorded in ctx or nil if there is none.
+func GetRecordedRequestIDs(ctx context.Context) *RequestIDs {
+	recorder, ok := ctx.Value(requestIDsRecorderKey{}).(*requestIDsRecorder)
+	if !ok {
+		return nil
+	}
+	recorder.Lock()
+	defer recorder.Unlock()
+	return recorder.last
+}
+
+// GetRequestIDsFromContext returns the RequestIDs of the AZ API response which caused the error. If the error does not
+// carry the response, the RequestIDs of the last failed response recorded in ctx are returned.
+func GetRequestIDsFromContext(ctx context.Context, err error) *RequestIDs {
+	if requestIDs := GetRequestIDs(err); requestIDs != nil {
+		return requestIDs
+	}
+	return GetRecordedRequestIDs(ctx)
+}
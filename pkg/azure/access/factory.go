@@ -5,8 +5,15 @@
 package access
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/marketplaceordering/armmarketplaceordering"
@@ -19,16 +26,235 @@ import (
 // This allows unit tests to pass their own fake provider for token credentials.
 type TokenCredentialProvider func(connectConfig ConnectConfig) (azcore.TokenCredential, error)
 
+// Resource types of the clients created by the Factory. They are used to configure API versions per resource type.
+const (
+	// ResourceTypeResourceGroups is the resource type of the client returned by Factory.GetResourceGroupsAccess.
+	ResourceTypeResourceGroups = "resourceGroups"
+	// ResourceTypeVirtualMachines is the resource type of the client returned by Factory.GetVirtualMachinesAccess.
+	ResourceTypeVirtualMachines = "virtualMachines"
+	// ResourceTypeNetworkInterfaces is the resource type of the client returned by Factory.GetNetworkInterfacesAccess.
+	ResourceTypeNetworkInterfaces = "networkInterfaces"
+	// ResourceTypePublicIPAddresses is the resource type of the client returned by Factory.GetPublicIPAddressesAccess.
+	ResourceTypePublicIPAddresses = "publicIPAddresses"
+	// ResourceTypeSubnets is the resource type of the client returned by Factory.GetSubnetAccess.
+	ResourceTypeSubnets = "subnets"
+	// ResourceTypeDisks is the resource type of the client returned by Factory.GetDisksAccess.
+	ResourceTypeDisks = "disks"
+	// ResourceTypeResourceGraph is the resource type of the client returned by Factory.GetResourceGraphAccess.
+	ResourceTypeResourceGraph = "resourceGraph"
+	// ResourceTypeVirtualMachineImages is the resource type of the client returned by Factory.GetVirtualMachineImagesAccess.
+	ResourceTypeVirtualMachineImages = "virtualMachineImages"
+	// ResourceTypeMarketplaceAgreements is the resource type of the client returned by Factory.GetMarketPlaceAgreementsAccess.
+	ResourceTypeMarketplaceAgreements = "marketplaceAgreements"
+	// ResourceTypeResourceSKUs is the resource type of the client returned by Factory.GetResourceSKUsAccess.
+	ResourceTypeResourceSKUs = "resourceSkus"
+	// ResourceTypeUsages is the resource type of the client returned by Factory.GetUsageAccess.
+	ResourceTypeUsages = "usages"
+	// ResourceTypeVirtualMachineExtensions is the resource type of the client returned by Factory.GetVirtualMachineExtensionsAccess.
+	ResourceTypeVirtualMachineExtensions = "virtualMachineExtensions"
+	// ResourceTypeSharedGalleryImageVersions is the resource type of the client returned by Factory.GetSharedGalleryImageVersionsAccess.
+	ResourceTypeSharedGalleryImageVersions = "sharedGalleryImageVersions"
+	// ResourceTypeCommunityGalleryImageVersions is the resource type of the client returned by Factory.GetCommunityGalleryImageVersionsAccess.
+	ResourceTypeCommunityGalleryImageVersions = "communityGalleryImageVersions"
+	// ResourceTypeSharedGalleryImages is the resource type of the client returned by Factory.GetSharedGalleryImagesAccess.
+	ResourceTypeSharedGalleryImages = "sharedGalleryImages"
+	// ResourceTypeCommunityGalleryImages is the resource type of the client returned by Factory.GetCommunityGalleryImagesAccess.
+	ResourceTypeCommunityGalleryImages = "communityGalleryImages"
+)
+
+// ResourceTypes contains all resource types of the clients created by the Factory.
+var ResourceTypes = []string{
+	ResourceTypeResourceGroups,
+	ResourceTypeVirtualMachines,
+	ResourceTypeNetworkInterfaces,
+	ResourceTypePublicIPAddresses,
+	ResourceTypeSubnets,
+	ResourceTypeDisks,
+	ResourceTypeResourceGraph,
+	ResourceTypeVirtualMachineImages,
+	ResourceTypeMarketplaceAgreements,
+	ResourceTypeResourceSKUs,
+	ResourceTypeUsages,
+	ResourceTypeVirtualMachineExtensions,
+	ResourceTypeSharedGalleryImageVersions,
+	ResourceTypeCommunityGalleryImageVersions,
+	ResourceTypeSharedGalleryImages,
+	ResourceTypeCommunityGalleryImages,
+}
+
 // defaultFactory implements Factory interface.
 type defaultFactory struct {
 	tokenCredentialProvider TokenCredentialProvider
+	// transport is used for all requests if set, otherwise the default transport of the Azure SDK is used.
+	transport policy.Transporter
+	// retryOptions overrides the retry behaviour of the Azure SDK for all set fields.
+	retryOptions policy.RetryOptions
+	// clientPool is used to reuse clients across calls if set.
+	clientPool *clientPool
+	// throttling is used to back off requests for throttled subscriptions if set.
+	throttling *throttlingTracker
+	// circuitBreaker is used to fail requests fast for subscriptions for which ARM is unavailable if set.
+	circuitBreaker *circuitBreaker
+	// credentials caches the token credentials if set, it is only used to report statistics.
+	credentials *credentialCache
+	// inFlight tracks the requests sent to Azure which have not yet been answered if set.
+	inFlight *inFlightTracker
+	// auditLogging enables logging of every request sent to Azure.
+	auditLogging bool
+	// tracing enables creating a span for every request sent to Azure.
+	tracing bool
+	// apiVersions are the pinned API versions per resource type.
+	apiVersions map[string]string
+}
+
+// FactoryOptions are the options which are applied to all clients created by a Factory.
+type FactoryOptions struct {
+	// Proxy configures the proxies to use for requests against Azure. If empty, the proxy environment variables are honored.
+	Proxy ProxyConfig
+	// MaxRetries is the maximum number of retries of a failed request. A value of zero uses the default of the Azure SDK,
+	// a negative value disables retries.
+	MaxRetries int32
+	// RetryDelay is the initial delay between retries. A value of zero uses the default of the Azure SDK.
+	RetryDelay time.Duration
+	// MaxRetryDelay is the maximum delay between retries. A value of zero uses the default of the Azure SDK.
+	MaxRetryDelay time.Duration
+	// CircuitBreakerThreshold is the number of consecutive requests for a subscription which failed with a server error or
+	// timeout after which further requests for the subscription fail fast. A request counts once, after the retries of the
+	// Azure SDK have been exhausted. A value of zero disables the circuit breaker.
+	CircuitBreakerThreshold int
+	// CircuitBreakerCooldown is the duration for which requests fail fast after the circuit breaker tripped.
+	CircuitBreakerCooldown time.Duration
+	// DevAuth authenticates with the credentials of the developer's environment, e.g. of the Azure CLI, instead of the
+	// credentials contained in the secret. It is meant for running the provider locally and must not be used in production.
+	DevAuth bool
+	// AuditLogging enables logging of every request sent to Azure with its method, URI, status, latency and request IDs.
+	AuditLogging bool
+	// Tracing enables creating an OpenTelemetry span for every request sent to Azure, which carries the request IDs.
+	Tracing bool
+	// APIVersions pins the API versions which are used by the clients, keyed by one of the ResourceTypes. Clients of
+	// resource types without an entry use the API version of the Azure SDK.
+	APIVersions map[string]string
+}
+
+// Validate validates the FactoryOptions.
+func (o FactoryOptions) Validate() error {
+	if o.CircuitBreakerThreshold < 0 {
+		return fmt.Errorf("circuit breaker threshold must not be negative")
+	}
+	for resourceType, apiVersion := range o.APIVersions {
+		if !slices.Contains(ResourceTypes, resourceType) {
+			return fmt.Errorf("unknown resource type %q for API version, supported resource types are %v", resourceType, ResourceTypes)
+		}
+		if len(strings.TrimSpace(apiVersion)) == 0 {
+			return fmt.Errorf("API version for resource type %q must not be empty", resourceType)
+		}
+	}
+	return nil
 }
 
-// NewDefaultAccessFactory creates a new instance of Factory.
+// NewDefaultAccessFactory creates a new instance of Factory. Token credentials and frequently used clients are cached across calls.
 func NewDefaultAccessFactory() Factory {
-	return defaultFactory{
-		tokenCredentialProvider: GetDefaultTokenCredentials,
+	return NewDefaultAccessFactoryWithOptions(FactoryOptions{})
+}
+
+// NewDefaultAccessFactoryWithOptions creates a new instance of Factory which applies the passed FactoryOptions to all
+// clients. Token credentials and frequently used clients are cached across calls.
+func NewDefaultAccessFactoryWithOptions(opts FactoryOptions) Factory {
+	pool := newClientPool(defaultClientPoolCapacity)
+	tokenCredentialProvider := GetDefaultTokenCredentials
+	if opts.DevAuth {
+		tokenCredentialProvider = GetDeveloperTokenCredentials
+	}
+	credentials := newCredentialCache(tokenCredentialProvider)
+	credentials.onRotation = pool.evictCredential
+	f := defaultFactory{
+		tokenCredentialProvider: credentials.getTokenCredential,
+		retryOptions: policy.RetryOptions{
+			MaxRetries:    opts.MaxRetries,
+			RetryDelay:    opts.RetryDelay,
+			MaxRetryDelay: opts.MaxRetryDelay,
+		},
+		clientPool:   pool,
+		credentials:  credentials,
+		inFlight:     newInFlightTracker(),
+		throttling:   newThrottlingTracker(),
+		apiVersions:  maps.Clone(opts.APIVersions),
+		auditLogging: opts.AuditLogging,
+		tracing:      opts.Tracing,
+	}
+	if opts.CircuitBreakerThreshold > 0 {
+		f.circuitBreaker = newCircuitBreaker(opts.CircuitBreakerThreshold, opts.CircuitBreakerCooldown)
+	}
+	if !opts.Proxy.IsEmpty() {
+		f.transport = newProxyTransport(opts.Proxy)
+	}
+	return f
+}
+
+// withClientOptions returns a copy of the connectConfig for the ARM clients of the resource type which uses the transport,
+// retry options and the API version of the factory, unless the connectConfig already specifies them. Requests are
+// additionally backed off while the subscription is throttled and fail fast while the circuit breaker of the subscription
+// is open. If audit logging is enabled, every request is logged and if tracing is enabled, a span is created for every
+// request. Requests are tracked while they are in flight, so that they can be reported by Stats, and the request IDs of
+// failed responses are recorded in the context of the request.
+func (f defaultFactory) withClientOptions(connectConfig ConnectConfig, resourceType string) ConnectConfig {
+	if apiVersion, ok := f.apiVersions[resourceType]; ok && len(connectConfig.ClientOptions.APIVersion) == 0 {
+		connectConfig.ClientOptions.APIVersion = apiVersion
+	}
+	if f.auditLogging {
+		connectConfig.ClientOptions.PerRetryPolicies = append(slices.Clone(connectConfig.ClientOptions.PerRetryPolicies),
+			auditLoggingPolicy{subscriptionID: connectConfig.SubscriptionID})
+	}
+	if f.tracing {
+		connectConfig.ClientOptions.PerRetryPolicies = append(slices.Clone(connectConfig.ClientOptions.PerRetryPolicies),
+			tracingPolicy{subscriptionID: connectConfig.SubscriptionID})
+	}
+	if f.inFlight != nil {
+		connectConfig.ClientOptions.PerRetryPolicies = append(slices.Clone(connectConfig.ClientOptions.PerRetryPolicies),
+			inFlightPolicy{tracker: f.inFlight, subscriptionID: connectConfig.SubscriptionID})
+	}
+	connectConfig.ClientOptions.PerRetryPolicies = append(slices.Clone(connectConfig.ClientOptions.PerRetryPolicies), requestIDsPolicy{})
+	if f.throttling != nil {
+		connectConfig.ClientOptions.PerCallPolicies = append(slices.Clone(connectConfig.ClientOptions.PerCallPolicies),
+			throttlingBackoffPolicy{tracker: f.throttling, subscriptionID: connectConfig.SubscriptionID})
+		connectConfig.ClientOptions.PerRetryPolicies = append(slices.Clone(connectConfig.ClientOptions.PerRetryPolicies),
+			throttlingRecorderPolicy{tracker: f.throttling, subscriptionID: connectConfig.SubscriptionID})
+	}
+	// the circuit breaker is the last per-call policy, so that it records the outcome of a request once around its retries.
+	if f.circuitBreaker != nil {
+		connectConfig.ClientOptions.PerCallPolicies = append(slices.Clone(connectConfig.ClientOptions.PerCallPolicies),
+			circuitBreakerPolicy{breaker: f.circuitBreaker, subscriptionID: connectConfig.SubscriptionID})
+	}
+	return f.withTransportOptions(connectConfig)
+}
+
+// withTransportOptions returns a copy of the connectConfig which uses the transport and retry options of the factory, unless
+// the connectConfig already specifies them. It is used for the token credentials, whose requests go to Microsoft Entra ID
+// and must therefore not pass the policies of the ARM clients.
+func (f defaultFactory) withTransportOptions(connectConfig ConnectConfig) ConnectConfig {
+	if f.transport != nil && connectConfig.ClientOptions.Transport == nil {
+		connectConfig.ClientOptions.Transport = f.transport
 	}
+	if f.retryOptions.MaxRetries != 0 && connectConfig.ClientOptions.Retry.MaxRetries == 0 {
+		connectConfig.ClientOptions.Retry.MaxRetries = f.retryOptions.MaxRetries
+	}
+	if f.retryOptions.RetryDelay != 0 && connectConfig.ClientOptions.Retry.RetryDelay == 0 {
+		connectConfig.ClientOptions.Retry.RetryDelay = f.retryOptions.RetryDelay
+	}
+	if f.retryOptions.MaxRetryDelay != 0 && connectConfig.ClientOptions.Retry.MaxRetryDelay == 0 {
+		connectConfig.ClientOptions.Retry.MaxRetryDelay = f.retryOptions.MaxRetryDelay
+	}
+	return connectConfig
+}
+
+// GetDeveloperTokenCredentials provides the azure token credentials of the developer's environment, e.g. of the Azure CLI
+// or the AZURE_* environment variables. Only the tenant of the ConnectConfig is used, the credentials contained in it are ignored.
+func GetDeveloperTokenCredentials(connectConfig ConnectConfig) (azcore.TokenCredential, error) {
+	return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions:              connectConfig.ClientOptions,
+		TenantID:                   connectConfig.TenantID,
+		AdditionallyAllowedTenants: connectConfig.AuxiliaryTenantIDs,
+	})
 }
 
 // GetDefaultTokenCredentials provides the azure token credentials using the ConnectConfig passed as an argument.
@@ -36,24 +262,37 @@ func GetDefaultTokenCredentials(connectConfig ConnectConfig) (azcore.TokenCreden
 	if len(connectConfig.WorkloadIdentityTokenFile) > 0 {
 		return azidentity.NewWorkloadIdentityCredential(
 			&azidentity.WorkloadIdentityCredentialOptions{
-				TenantID:      connectConfig.TenantID,
-				ClientID:      connectConfig.ClientID,
-				TokenFilePath: connectConfig.WorkloadIdentityTokenFile,
-				ClientOptions: connectConfig.ClientOptions,
+				TenantID:                   connectConfig.TenantID,
+				ClientID:                   connectConfig.ClientID,
+				TokenFilePath:              connectConfig.WorkloadIdentityTokenFile,
+				ClientOptions:              connectConfig.ClientOptions,
+				AdditionallyAllowedTenants: connectConfig.AuxiliaryTenantIDs,
 			},
 		)
 	}
 
+	if len(connectConfig.ClientSecret) == 0 {
+		// Neither a client secret nor a federated token is configured, authenticate with the managed identity of the
+		// environment the controller is running in. A client ID selects a user-assigned identity.
+		opts := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: connectConfig.ClientOptions}
+		if len(connectConfig.ClientID) > 0 {
+			opts.ID = azidentity.ClientID(connectConfig.ClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(opts)
+	}
+
 	return azidentity.NewClientSecretCredential(
 		connectConfig.TenantID,
 		connectConfig.ClientID,
 		connectConfig.ClientSecret,
-		&azidentity.ClientSecretCredentialOptions{ClientOptions: connectConfig.ClientOptions},
+		&azidentity.ClientSecretCredentialOptions{ClientOptions: connectConfig.ClientOptions, AdditionallyAllowedTenants: connectConfig.AuxiliaryTenantIDs},
 	)
 }
 
 func (f defaultFactory) GetResourceGroupsAccess(connectConfig ConnectConfig) (*armresources.ResourceGroupsClient, error) {
-	tokenCredential, err := f.tokenCredentialProvider(connectConfig)
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeResourceGroups)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -61,57 +300,163 @@ func (f defaultFactory) GetResourceGroupsAccess(connectConfig ConnectConfig) (*a
 }
 
 func (f defaultFactory) GetVirtualMachinesAccess(connectConfig ConnectConfig) (*armcompute.VirtualMachinesClient, error) {
-	tokenCredential, err := f.tokenCredentialProvider(connectConfig)
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeVirtualMachines)
+	return getPooledClient(f.clientPool, ResourceTypeVirtualMachines, connectConfig, func() (*armcompute.VirtualMachinesClient, error) {
+		tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
+		if err != nil {
+			return nil, err
+		}
+		return armcompute.NewVirtualMachinesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions, AuxiliaryTenants: connectConfig.AuxiliaryTenantIDs})
+	})
+}
+
+func (f defaultFactory) GetNetworkInterfacesAccess(connectConfig ConnectConfig) (*armnetwork.InterfacesClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeNetworkInterfaces)
+	return getPooledClient(f.clientPool, ResourceTypeNetworkInterfaces, connectConfig, func() (*armnetwork.InterfacesClient, error) {
+		tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
+		if err != nil {
+			return nil, err
+		}
+		return armnetwork.NewInterfacesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	})
+}
+
+func (f defaultFactory) GetPublicIPAddressesAccess(connectConfig ConnectConfig) (*armnetwork.PublicIPAddressesClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypePublicIPAddresses)
+	return getPooledClient(f.clientPool, ResourceTypePublicIPAddresses, connectConfig, func() (*armnetwork.PublicIPAddressesClient, error) {
+		tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
+		if err != nil {
+			return nil, err
+		}
+		return armnetwork.NewPublicIPAddressesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	})
+}
+
+func (f defaultFactory) GetSubnetAccess(connectConfig ConnectConfig) (*armnetwork.SubnetsClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeSubnets)
+	return getPooledClient(f.clientPool, ResourceTypeSubnets, connectConfig, func() (*armnetwork.SubnetsClient, error) {
+		tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
+		if err != nil {
+			return nil, err
+		}
+		return armnetwork.NewSubnetsClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	})
+}
+
+func (f defaultFactory) GetDisksAccess(connectConfig ConnectConfig) (*armcompute.DisksClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeDisks)
+	return getPooledClient(f.clientPool, ResourceTypeDisks, connectConfig, func() (*armcompute.DisksClient, error) {
+		tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
+		if err != nil {
+			return nil, err
+		}
+		return armcompute.NewDisksClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	})
+}
+
+func (f defaultFactory) GetResourceGraphAccess(connectConfig ConnectConfig) (*armresourcegraph.Client, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeResourceGraph)
+	return getPooledClient(f.clientPool, ResourceTypeResourceGraph, connectConfig, func() (*armresourcegraph.Client, error) {
+		tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
+		if err != nil {
+			return nil, err
+		}
+		return armresourcegraph.NewClient(tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	})
+}
+
+func (f defaultFactory) GetVirtualMachineImagesAccess(connectConfig ConnectConfig) (*armcompute.VirtualMachineImagesClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeVirtualMachineImages)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
-	return armcompute.NewVirtualMachinesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	return armcompute.NewVirtualMachineImagesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
 }
 
-func (f defaultFactory) GetNetworkInterfacesAccess(connectConfig ConnectConfig) (*armnetwork.InterfacesClient, error) {
-	tokenCredential, err := f.tokenCredentialProvider(connectConfig)
+func (f defaultFactory) GetMarketPlaceAgreementsAccess(connectConfig ConnectConfig) (*armmarketplaceordering.MarketplaceAgreementsClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeMarketplaceAgreements)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
-	return armnetwork.NewInterfacesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	return armmarketplaceordering.NewMarketplaceAgreementsClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
 }
 
-func (f defaultFactory) GetSubnetAccess(connectConfig ConnectConfig) (*armnetwork.SubnetsClient, error) {
-	tokenCredential, err := f.tokenCredentialProvider(connectConfig)
+func (f defaultFactory) GetResourceSKUsAccess(connectConfig ConnectConfig) (*armcompute.ResourceSKUsClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeResourceSKUs)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
-	return armnetwork.NewSubnetsClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	return armcompute.NewResourceSKUsClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
 }
 
-func (f defaultFactory) GetDisksAccess(connectConfig ConnectConfig) (*armcompute.DisksClient, error) {
-	tokenCredential, err := f.tokenCredentialProvider(connectConfig)
+func (f defaultFactory) GetUsageAccess(connectConfig ConnectConfig) (*armcompute.UsageClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeUsages)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
-	return armcompute.NewDisksClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	return armcompute.NewUsageClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
 }
 
-func (f defaultFactory) GetResourceGraphAccess(connectConfig ConnectConfig) (*armresourcegraph.Client, error) {
-	tokenCredential, err := f.tokenCredentialProvider(connectConfig)
+func (f defaultFactory) GetVirtualMachineExtensionsAccess(connectConfig ConnectConfig) (*armcompute.VirtualMachineExtensionsClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeVirtualMachineExtensions)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
-	return armresourcegraph.NewClient(tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	return armcompute.NewVirtualMachineExtensionsClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
 }
 
-func (f defaultFactory) GetVirtualMachineImagesAccess(connectConfig ConnectConfig) (*armcompute.VirtualMachineImagesClient, error) {
-	tokenCredential, err := f.tokenCredentialProvider(connectConfig)
+func (f defaultFactory) GetSharedGalleryImageVersionsAccess(connectConfig ConnectConfig) (*armcompute.SharedGalleryImageVersionsClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeSharedGalleryImageVersions)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
-	return armcompute.NewVirtualMachineImagesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	return armcompute.NewSharedGalleryImageVersionsClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
 }
 
-func (f defaultFactory) GetMarketPlaceAgreementsAccess(connectConfig ConnectConfig) (*armmarketplaceordering.MarketplaceAgreementsClient, error) {
-	tokenCredential, err := f.tokenCredentialProvider(connectConfig)
+func (f defaultFactory) GetCommunityGalleryImageVersionsAccess(connectConfig ConnectConfig) (*armcompute.CommunityGalleryImageVersionsClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeCommunityGalleryImageVersions)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
-	return armmarketplaceordering.NewMarketplaceAgreementsClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+	return armcompute.NewCommunityGalleryImageVersionsClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+}
+
+func (f defaultFactory) GetSharedGalleryImagesAccess(connectConfig ConnectConfig) (*armcompute.SharedGalleryImagesClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeSharedGalleryImages)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
+	if err != nil {
+		return nil, err
+	}
+	return armcompute.NewSharedGalleryImagesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
+}
+
+func (f defaultFactory) GetCommunityGalleryImagesAccess(connectConfig ConnectConfig) (*armcompute.CommunityGalleryImagesClient, error) {
+	credentialConfig := f.withTransportOptions(connectConfig)
+	connectConfig = f.withClientOptions(connectConfig, ResourceTypeCommunityGalleryImages)
+	tokenCredential, err := f.tokenCredentialProvider(credentialConfig)
+	if err != nil {
+		return nil, err
+	}
+	return armcompute.NewCommunityGalleryImagesClient(connectConfig.SubscriptionID, tokenCredential, &arm.ClientOptions{ClientOptions: connectConfig.ClientOptions})
 }
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	. "github.com/onsi/gomega"
+)
+
+func TestWithClientOptions(t *testing.T) {
+	tests := []struct {
+		description   string
+		opts          FactoryOptions
+		clientRetry   policy.RetryOptions
+		expectedRetry policy.RetryOptions
+	}{
+		{description: "no retry options set", expectedRetry: policy.RetryOptions{}},
+		{
+			description:   "retry options of the factory are applied",
+			opts:          FactoryOptions{MaxRetries: 5, RetryDelay: 2 * time.Second, MaxRetryDelay: time.Minute},
+			expectedRetry: policy.RetryOptions{MaxRetries: 5, RetryDelay: 2 * time.Second, MaxRetryDelay: time.Minute},
+		},
+		{
+			description:   "retry options of the connect config take precedence",
+			opts:          FactoryOptions{MaxRetries: 5, RetryDelay: 2 * time.Second},
+			clientRetry:   policy.RetryOptions{MaxRetries: -1},
+			expectedRetry: policy.RetryOptions{MaxRetries: -1, RetryDelay: 2 * time.Second},
+		},
+	}
+	g := NewWithT(t)
+	for _, test := range tests {
+		t.Run(test.description, func(_ *testing.T) {
+			f := NewDefaultAccessFactoryWithOptions(test.opts).(defaultFactory)
+			connectConfig := f.withClientOptions(ConnectConfig{ClientOptions: policy.ClientOptions{Retry: test.clientRetry}}, ResourceTypeVirtualMachines)
+			g.Expect(connectConfig.ClientOptions.Retry).To(Equal(test.expectedRetry))
+			g.Expect(connectConfig.ClientOptions.Transport).To(BeNil())
+		})
+	}
+
+	t.Run("proxy transport is applied", func(_ *testing.T) {
+		f := NewDefaultAccessFactoryWithOptions(FactoryOptions{Proxy: ProxyConfig{HTTPSProxy: "http://proxy:3128"}}).(defaultFactory)
+		connectConfig := f.withClientOptions(ConnectConfig{}, ResourceTypeVirtualMachines)
+		g.Expect(connectConfig.ClientOptions.Transport).ToNot(BeNil())
+	})
+}
+
+func TestAPIVersions(t *testing.T) {
+	g := NewWithT(t)
+	opts := FactoryOptions{APIVersions: map[string]string{ResourceTypeVirtualMachines: "2023-03-01"}}
+	g.Expect(opts.Validate()).To(Succeed())
+
+	f := NewDefaultAccessFactoryWithOptions(opts).(defaultFactory)
+	g.Expect(f.withClientOptions(ConnectConfig{}, ResourceTypeVirtualMachines).ClientOptions.APIVersion).To(Equal("2023-03-01"))
+	g.Expect(f.withClientOptions(ConnectConfig{}, ResourceTypeDisks).ClientOptions.APIVersion).To(BeEmpty())
+
+	g.Expect(FactoryOptions{APIVersions: map[string]string{"virtualMachine": "2023-03-01"}}.Validate()).To(MatchError(ContainSubstring("unknown resource type")))
+	g.Expect(FactoryOptions{APIVersions: map[string]string{ResourceTypeDisks: " "}}.Validate()).To(MatchError(ContainSubstring("must not be empty")))
+}
+
+func TestTokenCredentialsDoNotUseClientPolicies(t *testing.T) {
+	g := NewWithT(t)
+	f := NewDefaultAccessFactoryWithOptions(FactoryOptions{
+		MaxRetries:              3,
+		Proxy:                   ProxyConfig{HTTPSProxy: "http://proxy:3128"},
+		APIVersions:             map[string]string{ResourceTypeVirtualMachines: "2023-03-01"},
+		AuditLogging:            true,
+		Tracing:                 true,
+		CircuitBreakerThreshold: 5,
+	}).(defaultFactory)
+	var credentialConfig ConnectConfig
+	f.tokenCredentialProvider = func(connectConfig ConnectConfig) (azcore.TokenCredential, error) {
+		credentialConfig = connectConfig
+		return nil, errors.New("no credentials")
+	}
+
+	_, err := f.GetVirtualMachinesAccess(ConnectConfig{SubscriptionID: "subscription", TenantID: "tenant"})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(credentialConfig.ClientOptions.PerCallPolicies).To(BeEmpty())
+	g.Expect(credentialConfig.ClientOptions.PerRetryPolicies).To(BeEmpty())
+	g.Expect(credentialConfig.ClientOptions.APIVersion).To(BeEmpty())
+	g.Expect(credentialConfig.ClientOptions.Transport).ToNot(BeNil())
+	g.Expect(credentialConfig.ClientOptions.Retry.MaxRetries).To(Equal(int32(3)))
+}
@@ -18,8 +18,10 @@ import (
 )
 
 const (
+	diskGetServiceLabel    = "disk_get"
 	diskDeleteServiceLabel = "disk_delete"
 	diskCreateServiceLabel = "disk_create"
+	diskUpdateServiceLabel = "disk_update"
 
 	defaultDiskOperationTimeout = 10 * time.Minute
 )
@@ -27,16 +29,20 @@ const (
 // DeleteDisk deletes disk for passed in resourceGroup and diskName.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func DeleteDisk(ctx context.Context, client *armcompute.DisksClient, resourceGroup, diskName string) (err error) {
-	defer instrument.AZAPIMetricRecorderFn(diskDeleteServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, diskDeleteServiceLabel, &err)
+	defer recordFn()
+	defer instrument.LRODurationRecorderFn(ctx, diskDeleteServiceLabel, &err)()
 	var poller *runtime.Poller[armcompute.DisksClientDeleteResponse]
-	poller, err = client.BeginDelete(ctx, resourceGroup, diskName, nil)
+	delCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().DeleteDisk)
+	defer cancelFn()
+	poller, err = client.BeginDelete(delCtx, resourceGroup, diskName, nil)
 	if err != nil {
 		// If target Disk is not found then `BeginDelete` will not return any error. This is treated as a NO-OP and a success is returned instead.
 		// If this changes incompatibly in the future then we should explicitly handle the NotFound error.
 		errors.LogAzAPIError(err, "Failed to trigger Delete of Disk for [resourceGroup: %s, Name: %s]", resourceGroup, diskName)
 		return
 	}
-	_, err = poller.PollUntilDone(ctx, nil)
+	_, err = pollUntilDone(delCtx, poller)
 	if err != nil {
 		errors.LogAzAPIError(err, "Polling failed while waiting for Deleting for [resourceGroup: %s, Name: %s]", diskName, resourceGroup)
 	}
@@ -44,10 +50,28 @@ func DeleteDisk(ctx context.Context, client *armcompute.DisksClient, resourceGro
 	return
 }
 
+// GetDisk fetches a Disk identified by resourceGroup and disk name. If the disk does not exist then nil is returned.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func GetDisk(ctx context.Context, client *armcompute.DisksClient, resourceGroup, diskName string) (disk *armcompute.Disk, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, diskGetServiceLabel, &err)
+	defer recordFn()
+
+	resp, err := client.Get(ctx, resourceGroup, diskName, nil)
+	if err != nil {
+		if errors.IsNotFoundAzAPIError(err) {
+			return nil, nil
+		}
+		errors.LogAzAPIError(err, "Failed to get Disk [ResourceGroup: %s, Name: %s]", resourceGroup, diskName)
+		return nil, err
+	}
+	return &resp.Disk, nil
+}
+
 // CreateDisk creates a Disk given a resourceGroup and disk creation parameters.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func CreateDisk(ctx context.Context, client *armcompute.DisksClient, resourceGroup, diskName string, diskCreationParams armcompute.Disk) (disk *armcompute.Disk, err error) {
-	defer instrument.AZAPIMetricRecorderFn(diskCreateServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, diskCreateServiceLabel, &err)
+	defer recordFn()
 
 	createCtx, cancelFn := context.WithTimeout(ctx, defaultDiskOperationTimeout)
 	defer cancelFn()
@@ -56,7 +80,7 @@ func CreateDisk(ctx context.Context, client *armcompute.DisksClient, resourceGro
 		errors.LogAzAPIError(err, "Failed to trigger create of Disk [Name: %s, ResourceGroup: %s]", resourceGroup, diskName)
 		return
 	}
-	createResp, err := poller.PollUntilDone(createCtx, nil)
+	createResp, err := pollUntilDone(createCtx, poller)
 	if err != nil {
 		errors.LogAzAPIError(err, "Polling failed while waiting for create of Disk: %s for ResourceGroup: %s", diskName, resourceGroup)
 		return
@@ -65,3 +89,26 @@ func CreateDisk(ctx context.Context, client *armcompute.DisksClient, resourceGro
         klog.Infof("Successfully created Disk: %s, for ResourceGroup: %s", diskName, resourceGroup)
 	return
 }
+
+// UpdateDisk updates a Disk given a resourceGroup and disk update parameters.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func UpdateDisk(ctx context.Context, client *armcompute.DisksClient, resourceGroup, diskName string, diskUpdateParams armcompute.DiskUpdate) (disk *armcompute.Disk, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, diskUpdateServiceLabel, &err)
+	defer recordFn()
+
+	updateCtx, cancelFn := context.WithTimeout(ctx, defaultDiskOperationTimeout)
+	defer cancelFn()
+	poller, err := client.BeginUpdate(updateCtx, resourceGroup, diskName, diskUpdateParams, nil)
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to trigger update of Disk [Name: %s, ResourceGroup: %s]", diskName, resourceGroup)
+		return
+	}
+	updateResp, err := pollUntilDone(updateCtx, poller)
+	if err != nil {
+		errors.LogAzAPIError(err, "Polling failed while waiting for update of Disk: %s for ResourceGroup: %s", diskName, resourceGroup)
+		return
+	}
+	disk = &updateResp.Disk
+	klog.Infof("Successfully updated Disk: %s, for ResourceGroup: %s", diskName, resourceGroup)
+	return
+}
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+)
+
+const (
+	sharedGalleryImageVersionsListServiceLabel    = "shared_gallery_image_versions_list"
+	communityGalleryImageVersionsListServiceLabel = "community_gallery_image_versions_list"
+	sharedGalleryImageGetServiceLabel             = "shared_gallery_image_get"
+	communityGalleryImageGetServiceLabel          = "community_gallery_image_get"
+)
+
+// GetSharedGalleryImage gets an image definition in a shared gallery.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func GetSharedGalleryImage(ctx context.Context, imagesAccess *armcompute.SharedGalleryImagesClient, location, galleryUniqueName, imageName string) (image *armcompute.SharedGalleryImage, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, sharedGalleryImageGetServiceLabel, &err)
+	defer recordFn()
+
+	resp, err := imagesAccess.Get(ctx, location, galleryUniqueName, imageName, nil)
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to get shared gallery image [Location: %s, Gallery: %s, Image: %s]", location, galleryUniqueName, imageName)
+		return nil, err
+	}
+	return &resp.SharedGalleryImage, nil
+}
+
+// GetCommunityGalleryImage gets an image definition in a community gallery.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func GetCommunityGalleryImage(ctx context.Context, imagesAccess *armcompute.CommunityGalleryImagesClient, location, publicGalleryName, imageName string) (image *armcompute.CommunityGalleryImage, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, communityGalleryImageGetServiceLabel, &err)
+	defer recordFn()
+
+	resp, err := imagesAccess.Get(ctx, location, publicGalleryName, imageName, nil)
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to get community gallery image [Location: %s, Gallery: %s, Image: %s]", location, publicGalleryName, imageName)
+		return nil, err
+	}
+	return &resp.CommunityGalleryImage, nil
+}
+
+// ListSharedGalleryImageVersions lists all versions of an image in a shared gallery.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func ListSharedGalleryImageVersions(ctx context.Context, versionsAccess *armcompute.SharedGalleryImageVersionsClient, location, galleryUniqueName, imageName string) (versions []*armcompute.SharedGalleryImageVersion, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, sharedGalleryImageVersionsListServiceLabel, &err)
+	defer recordFn()
+
+	pager := versionsAccess.NewListPager(location, galleryUniqueName, imageName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			errors.LogAzAPIError(err, "Failed to list shared gallery image versions [Location: %s, Gallery: %s, Image: %s]", location, galleryUniqueName, imageName)
+			return nil, err
+		}
+		versions = append(versions, page.Value...)
+	}
+	return versions, nil
+}
+
+// ListCommunityGalleryImageVersions lists all versions of an image in a community gallery.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func ListCommunityGalleryImageVersions(ctx context.Context, versionsAccess *armcompute.CommunityGalleryImageVersionsClient, location, publicGalleryName, imageName string) (versions []*armcompute.CommunityGalleryImageVersion, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, communityGalleryImageVersionsListServiceLabel, &err)
+	defer recordFn()
+
+	pager := versionsAccess.NewListPager(location, publicGalleryName, imageName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			errors.LogAzAPIError(err, "Failed to list community gallery image versions [Location: %s, Gallery: %s, Image: %s]", location, publicGalleryName, imageName)
+			return nil, err
+		}
+		versions = append(versions, page.Value...)
+	}
+	return versions, nil
+}
@@ -22,7 +22,8 @@ const (
 // GetAgreementTerms fetches the agreement terms for the purchase plan.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func GetAgreementTerms(ctx context.Context, mktPlaceAgreementAccess *armmarketplaceordering.MarketplaceAgreementsClient, purchasePlan armcompute.PurchasePlan) (agreementTerms *armmarketplaceordering.AgreementTerms, err error) {
-	defer instrument.AZAPIMetricRecorderFn(mktPlaceAgreementGetServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, mktPlaceAgreementGetServiceLabel, &err)
+	defer recordFn()
 	resp, err := mktPlaceAgreementAccess.Get(ctx, armmarketplaceordering.OfferTypeVirtualmachine, *purchasePlan.Publisher, *purchasePlan.Product, *purchasePlan.Name, nil)
 	if err != nil {
 		errors.LogAzAPIError(err, "Failed to get marketplace agreement for PurchasePlan: %+v", purchasePlan)
@@ -35,7 +36,8 @@ func GetAgreementTerms(ctx context.Context, mktPlaceAgreementAccess *armmarketpl
 // AcceptAgreement updates the agreementTerms as accepted.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func AcceptAgreement(ctx context.Context, mktPlaceAgreementAccess *armmarketplaceordering.MarketplaceAgreementsClient, purchasePlan armcompute.PurchasePlan, existingAgreement armmarketplaceordering.AgreementTerms) (err error) {
-	defer instrument.AZAPIMetricRecorderFn(mktPlaceAgreementCreateServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, mktPlaceAgreementCreateServiceLabel, &err)
+	defer recordFn()
 	updatedAgreement := existingAgreement
 	updatedAgreement.Properties.Accepted = to.Ptr(true)
 	_, err = mktPlaceAgreementAccess.Create(ctx, armmarketplaceordering.OfferTypeVirtualmachine, *purchasePlan.Publisher, *purchasePlan.Product, *purchasePlan.Name, updatedAgreement, nil)
@@ -20,6 +20,7 @@ const (
 	nicGetServiceLabel    = "nic_get"
 	nicDeleteServiceLabel = "nic_delete"
 	nicCreateServiceLabel = "nic_create"
+	nicUpdateServiceLabel = "nic_update"
 )
 
 const (
@@ -30,10 +31,11 @@ const (
 // DeleteNIC deletes the NIC identified by a resourceGroup and nicName.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func DeleteNIC(ctx context.Context, client *armnetwork.InterfacesClient, resourceGroup, nicName string) (err error) {
-	defer instrument.AZAPIMetricRecorderFn(nicDeleteServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, nicDeleteServiceLabel, &err)
+	defer recordFn()
 
 	var poller *runtime.Poller[armnetwork.InterfacesClientDeleteResponse]
-	delCtx, cancelFn := context.WithTimeout(ctx, defaultDeleteNICTimeout)
+	delCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().DeleteNIC)
 	defer cancelFn()
 	poller, err = client.BeginDelete(delCtx, resourceGroup, nicName, nil)
 	if err != nil {
@@ -42,7 +44,7 @@ func DeleteNIC(ctx context.Context, client *armnetwork.InterfacesClient, resourc
 		errors.LogAzAPIError(err, "Failed to trigger delete of NIC [ResourceGroup: %s, Name: %s]", resourceGroup, nicName)
 		return
 	}
-	_, err = poller.PollUntilDone(delCtx, nil)
+	_, err = pollUntilDone(delCtx, poller)
 	if err != nil {
 		errors.LogAzAPIError(err, "Polling failed while waiting for Deleting of NIC [ResourceGroup: %s, Name: %s]", resourceGroup, nicName)
 	}
@@ -53,7 +55,8 @@ func DeleteNIC(ctx context.Context, client *armnetwork.InterfacesClient, resourc
 // GetNIC fetches a NIC identified by resourceGroup and nic name.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func GetNIC(ctx context.Context, client *armnetwork.InterfacesClient, resourceGroup, nicName string) (nic *armnetwork.Interface, err error) {
-	defer instrument.AZAPIMetricRecorderFn(nicGetServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, nicGetServiceLabel, &err)
+	defer recordFn()
 
 	resp, err := client.Get(ctx, resourceGroup, nicName, nil)
 	if err != nil {
@@ -69,13 +72,15 @@ func GetNIC(ctx context.Context, client *armnetwork.InterfacesClient, resourceGr
 // CreateNIC creates a NIC given the resourceGroup, nic name and NIC creation parameters.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func CreateNIC(ctx context.Context, nicAccess *armnetwork.InterfacesClient, resourceGroup string, nicParams armnetwork.Interface, nicName string) (nic *armnetwork.Interface, err error) {
-	defer instrument.AZAPIMetricRecorderFn(nicCreateServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, nicCreateServiceLabel, &err)
+	defer recordFn()
+	defer instrument.LRODurationRecorderFn(ctx, nicCreateServiceLabel, &err)()
 
 	var (
 		poller       *runtime.Poller[armnetwork.InterfacesClientCreateOrUpdateResponse]
 		creationResp armnetwork.InterfacesClientCreateOrUpdateResponse
 	)
-	createCtx, cancelFn := context.WithTimeout(ctx, defaultCreateNICTimeout)
+	createCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().CreateNIC)
 	defer cancelFn()
 
 	poller, err = nicAccess.BeginCreateOrUpdate(createCtx, resourceGroup, nicName, nicParams, nil)
@@ -83,10 +88,47 @@ func CreateNIC(ctx context.Context, nicAccess *armnetwork.InterfacesClient, reso
 		errors.LogAzAPIError(err, "Failed to trigger create of NIC [ResourceGroup: %s, Name: %s]", resourceGroup, nicName)
 		return nil, err
 	}
-	creationResp, err = poller.PollUntilDone(createCtx, nil)
+	creationResp, err = pollUntilDone(createCtx, poller)
 	if err != nil {
 		errors.LogAzAPIError(err, "Polling failed while waiting for Creation of NIC [ResourceGroup: %s, Name: %s]", resourceGroup, nicName)
+		err = withResumeToken(poller, err)
 	}
 	nic = &creationResp.Interface
 	return
 }
+
+// UpdateNICTags replaces the tags of the NIC identified by resourceGroup and nic name.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func UpdateNICTags(ctx context.Context, nicAccess *armnetwork.InterfacesClient, resourceGroup, nicName string, tags map[string]*string) (nic *armnetwork.Interface, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, nicUpdateServiceLabel, &err)
+	defer recordFn()
+
+	resp, err := nicAccess.UpdateTags(ctx, resourceGroup, nicName, armnetwork.TagsObject{Tags: tags}, nil)
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to update tags of NIC [ResourceGroup: %s, Name: %s]", resourceGroup, nicName)
+		return nil, err
+	}
+	return &resp.Interface, nil
+}
+
+// ResumeCreateNIC resumes polling the creation of a NIC which has been triggered earlier, using the resume token of its poller.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func ResumeCreateNIC(ctx context.Context, nicAccess *armnetwork.InterfacesClient, resourceGroup string, nicName string, resumeToken string) (nic *armnetwork.Interface, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, nicCreateServiceLabel, &err)
+	defer recordFn()
+
+	createCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().CreateNIC)
+	defer cancelFn()
+
+	poller, err := nicAccess.BeginCreateOrUpdate(createCtx, resourceGroup, nicName, armnetwork.Interface{}, &armnetwork.InterfacesClientBeginCreateOrUpdateOptions{ResumeToken: resumeToken})
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to resume create of NIC [ResourceGroup: %s, Name: %s]", resourceGroup, nicName)
+		return nil, err
+	}
+	creationResp, err := pollUntilDone(createCtx, poller)
+	if err != nil {
+		errors.LogAzAPIError(err, "Polling failed while waiting for resumed creation of NIC [ResourceGroup: %s, Name: %s]", resourceGroup, nicName)
+		return nil, withResumeToken(poller, err)
+	}
+	return &creationResp.Interface, nil
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+const (
+	// defaultPollingFrequency is the default interval between two polls of a long-running operation, which is also the default of the Azure SDK.
+	defaultPollingFrequency = 30 * time.Second
+	// minPollingFrequency is the minimum interval between two polls of a long-running operation supported by the Azure SDK.
+	minPollingFrequency = time.Second
+)
+
+// PollingOptions configure how the long-running operations against Azure are polled. A lower frequency and a longer initial
+// delay reduce the number of requests against Azure at the cost of noticing the completion of an operation later.
+type PollingOptions struct {
+	// Frequency is the interval between two polls, unless Azure requests another interval via the Retry-After header.
+	// It is at least one second.
+	Frequency time.Duration
+	// InitialDelay is the delay before the operation is polled for the first time, e.g. because the creation of a VM is known
+	// to take a while. 0 polls the operation immediately.
+	InitialDelay time.Duration
+}
+
+var (
+	pollingOptionsMutex sync.RWMutex
+	pollingOptions      = DefaultPollingOptions()
+)
+
+// DefaultPollingOptions returns the default PollingOptions.
+func DefaultPollingOptions() PollingOptions {
+	return PollingOptions{
+		Frequency:    defaultPollingFrequency,
+		InitialDelay: 0,
+	}
+}
+
+// SetPollingOptions sets the options to poll long-running operations. A frequency which is not set (zero) keeps its default,
+// a frequency below one second is raised to one second.
+func SetPollingOptions(options PollingOptions) {
+	frequency := durationOrDefault(options.Frequency, defaultPollingFrequency)
+	pollingOptionsMutex.Lock()
+	defer pollingOptionsMutex.Unlock()
+	pollingOptions = PollingOptions{
+		Frequency:    max(frequency, minPollingFrequency),
+		InitialDelay: max(options.InitialDelay, 0),
+	}
+}
+
+// GetPollingOptions returns the currently configured options to poll long-running operations.
+func GetPollingOptions() PollingOptions {
+	pollingOptionsMutex.RLock()
+	defer pollingOptionsMutex.RUnlock()
+	return pollingOptions
+}
+
+// pollUntilDone polls the long-running operation of the poller until it has completed as configured by PollingOptions.
+func pollUntilDone[T any](ctx context.Context, poller *runtime.Poller[T]) (T, error) {
+	options := GetPollingOptions()
+	if options.InitialDelay > 0 {
+		timer := time.NewTimer(options.InitialDelay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return poller.PollUntilDone(ctx, &runtime.PollUntilDoneOptions{Frequency: options.Frequency})
+}
+
+// withResumeToken wraps an error, which occurred while polling a long-running operation that has not yet completed, into
+// an errors.PollingError carrying the resume token of the poller. This allows callers to record the pending operation and
+// to resume polling it later instead of triggering the operation again.
+func withResumeToken[T any](poller *runtime.Poller[T], err error) error {
+	if err == nil || poller == nil || poller.Done() {
+		return err
+	}
+	resumeToken, tokenErr := poller.ResumeToken()
+	if tokenErr != nil {
+		return err
+	}
+	return &errors.PollingError{ResumeToken: resumeToken, Err: err}
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	. "github.com/onsi/gomega"
+)
+
+func TestSetPollingOptions(t *testing.T) {
+	g := NewWithT(t)
+	defer SetPollingOptions(DefaultPollingOptions())
+
+	g.Expect(GetPollingOptions()).To(Equal(DefaultPollingOptions()))
+
+	SetPollingOptions(PollingOptions{Frequency: 5 * time.Second, InitialDelay: time.Minute})
+	g.Expect(GetPollingOptions()).To(Equal(PollingOptions{Frequency: 5 * time.Second, InitialDelay: time.Minute}))
+
+	SetPollingOptions(PollingOptions{Frequency: 100 * time.Millisecond})
+	g.Expect(GetPollingOptions()).To(Equal(PollingOptions{Frequency: minPollingFrequency}))
+
+	SetPollingOptions(PollingOptions{})
+	g.Expect(GetPollingOptions()).To(Equal(DefaultPollingOptions()))
+}
+
+func TestPollUntilDoneRespectsContextDuringInitialDelay(t *testing.T) {
+	g := NewWithT(t)
+	SetPollingOptions(PollingOptions{InitialDelay: time.Hour})
+	defer SetPollingOptions(DefaultPollingOptions())
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+	// the poller is not used as the context is cancelled before the initial delay has passed.
+	_, err := pollUntilDone[struct{}](ctx, (*runtime.Poller[struct{}])(nil))
+	g.Expect(err).To(MatchError(context.Canceled))
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+)
+
+// labels used for recording prometheus metrics
+const (
+	publicIPDeleteServiceLabel = "public_ip_delete"
+)
+
+const defaultDeletePublicIPTimeout = 10 * time.Minute
+
+// DeletePublicIPAddress deletes the public IP address identified by a resourceGroup and publicIPName.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func DeletePublicIPAddress(ctx context.Context, client *armnetwork.PublicIPAddressesClient, resourceGroup, publicIPName string) (err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, publicIPDeleteServiceLabel, &err)
+	defer recordFn()
+
+	var poller *runtime.Poller[armnetwork.PublicIPAddressesClientDeleteResponse]
+	delCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().DeletePublicIP)
+	defer cancelFn()
+	poller, err = client.BeginDelete(delCtx, resourceGroup, publicIPName, nil)
+	if err != nil {
+		// Similar to NICs, `BeginDelete` does not return an error if the public IP address does not exist.
+		errors.LogAzAPIError(err, "Failed to trigger delete of public IP address [ResourceGroup: %s, Name: %s]", resourceGroup, publicIPName)
+		return
+	}
+	_, err = pollUntilDone(delCtx, poller)
+	if err != nil {
+		errors.LogAzAPIError(err, "Polling failed while waiting for Deleting of public IP address [ResourceGroup: %s, Name: %s]", resourceGroup, publicIPName)
+		return
+	}
+	klog.Infof("Successfully deleted public IP address: %s, for ResourceGroup: %s", publicIPName, resourceGroup)
+	return
+}
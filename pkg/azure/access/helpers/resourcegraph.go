@@ -7,54 +7,117 @@ package helpers
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
 )
 
 const (
 	resourceGraphQueryServiceLabel = "resource_graph_query"
+	// maxResourceGraphPageSize is the maximum number of records which resource graph returns in a single page.
+	maxResourceGraphPageSize int32 = 1000
 )
 
+// ResourceGraphQueryLimits bound the number of records which are fetched by resource graph queries.
+type ResourceGraphQueryLimits struct {
+	// PageSize is the number of records which are requested per page. It is capped at 1000, the maximum supported by resource graph.
+	PageSize int32
+	// MaxResults is the maximum number of records which are processed per query. Further pages are not requested once it
+	// has been reached. 0 means that all records are processed.
+	MaxResults int
+}
+
+var (
+	resourceGraphQueryLimitsMutex sync.RWMutex
+	resourceGraphQueryLimits      = DefaultResourceGraphQueryLimits()
+)
+
+// DefaultResourceGraphQueryLimits returns the default ResourceGraphQueryLimits.
+func DefaultResourceGraphQueryLimits() ResourceGraphQueryLimits {
+	return ResourceGraphQueryLimits{
+		PageSize:   maxResourceGraphPageSize,
+		MaxResults: 0,
+	}
+}
+
+// SetResourceGraphQueryLimits sets the limits for resource graph queries. A page size which is not set (zero) keeps its default.
+func SetResourceGraphQueryLimits(limits ResourceGraphQueryLimits) {
+	pageSize := limits.PageSize
+	if pageSize <= 0 || pageSize > maxResourceGraphPageSize {
+		pageSize = maxResourceGraphPageSize
+	}
+	resourceGraphQueryLimitsMutex.Lock()
+	defer resourceGraphQueryLimitsMutex.Unlock()
+	resourceGraphQueryLimits = ResourceGraphQueryLimits{
+		PageSize:   pageSize,
+		MaxResults: max(limits.MaxResults, 0),
+	}
+}
+
+// GetResourceGraphQueryLimits returns the currently configured limits for resource graph queries.
+func GetResourceGraphQueryLimits() ResourceGraphQueryLimits {
+	resourceGraphQueryLimitsMutex.RLock()
+	defer resourceGraphQueryLimitsMutex.RUnlock()
+	return resourceGraphQueryLimits
+}
+
 // MapperFn maps a row of result (represented as map[string]interface{}) to any type T.
 type MapperFn[T any] func(map[string]interface{}) *T
 
 // QueryAndMap fires a resource graph KUSTO query constructing it from queryTemplate and templateArgs.
 // The result of the query are then mapped using a mapperFn and the result or an error is returned.
+// The results are fetched page by page as configured by ResourceGraphQueryLimits, each page is mapped before the next one is requested.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func QueryAndMap[T any](ctx context.Context, client *armresourcegraph.Client, subscriptionID string, mapperFn MapperFn[T], queryTemplate string, templateArgs ...any) (results []T, err error) {
-	defer instrument.AZAPIMetricRecorderFn(resourceGraphQueryServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, resourceGraphQueryServiceLabel, &err)
+	defer recordFn()
 
 	query := fmt.Sprintf(queryTemplate, templateArgs...)
-	resources, err := client.Resources(ctx,
-		armresourcegraph.QueryRequest{
-			Query:         to.Ptr(query),
-			Options:       nil,
-			Subscriptions: []*string{to.Ptr(subscriptionID)},
-		}, nil)
-
-	if err != nil {
-		errors.LogAzAPIError(err, "ResourceGraphQuery failure to execute Query: %s", query)
-		return nil, err
-	}
-
-	if resources.TotalRecords == pointer.Int64(0) {
-		return results, nil
-	}
+	limits := GetResourceGraphQueryLimits()
+	var (
+		skipToken *string
+		processed int
+	)
+	for {
+		resources, err := client.Resources(ctx,
+			armresourcegraph.QueryRequest{
+				Query: to.Ptr(query),
+				Options: &armresourcegraph.QueryRequestOptions{
+					Top:       to.Ptr(limits.PageSize),
+					SkipToken: skipToken,
+				},
+				Subscriptions: []*string{to.Ptr(subscriptionID)},
+			}, nil)
+		if err != nil {
+			errors.LogAzAPIError(err, "ResourceGraphQuery failure to execute Query: %s", query)
+			return nil, err
+		}
 
-	// resourceResponse.Data is a []interface{}
-	if objSlice, ok := resources.Data.([]interface{}); ok {
-		for _, obj := range objSlice {
-			// Each obj in resourceResponse.Data is a map[string]Interface{}
-			rowElements := obj.(map[string]interface{})
-			result := mapperFn(rowElements)
-			if result != nil {
-				results = append(results, *result)
+		// resourceResponse.Data is a []interface{}
+		if objSlice, ok := resources.Data.([]interface{}); ok {
+			for _, obj := range objSlice {
+				if limits.MaxResults > 0 && processed >= limits.MaxResults {
+					klog.Warningf("ResourceGraphQuery reached the limit of %d results, remaining results are skipped. Query: %s", limits.MaxResults, query)
+					return results, nil
+				}
+				processed++
+				// Each obj in resourceResponse.Data is a map[string]Interface{}
+				rowElements := obj.(map[string]interface{})
+				result := mapperFn(rowElements)
+				if result != nil {
+					results = append(results, *result)
+				}
 			}
 		}
+
+		skipToken = resources.SkipToken
+		if pointer.StringDeref(skipToken, "") == "" {
+			return results, nil
+		}
 	}
-	return
 }
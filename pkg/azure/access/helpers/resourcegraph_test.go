@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSetResourceGraphQueryLimits(t *testing.T) {
+	g := NewWithT(t)
+	defer SetResourceGraphQueryLimits(DefaultResourceGraphQueryLimits())
+
+	g.Expect(GetResourceGraphQueryLimits()).To(Equal(DefaultResourceGraphQueryLimits()))
+
+	SetResourceGraphQueryLimits(ResourceGraphQueryLimits{PageSize: 200, MaxResults: 5000})
+	g.Expect(GetResourceGraphQueryLimits()).To(Equal(ResourceGraphQueryLimits{PageSize: 200, MaxResults: 5000}))
+
+	SetResourceGraphQueryLimits(ResourceGraphQueryLimits{PageSize: 5000, MaxResults: -1})
+	g.Expect(GetResourceGraphQueryLimits()).To(Equal(ResourceGraphQueryLimits{PageSize: maxResourceGraphPageSize, MaxResults: 0}))
+}
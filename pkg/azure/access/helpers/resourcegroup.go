@@ -19,7 +19,8 @@ const (
 // ResourceGroupExists checks if the given resourceGroup exists.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func ResourceGroupExists(ctx context.Context, client *armresources.ResourceGroupsClient, resourceGroup string) (exists bool, err error) {
-	defer instrument.AZAPIMetricRecorderFn(resourceGroupExistsServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, resourceGroupExistsServiceLabel, &err)
+	defer recordFn()
 
 	resp, err := client.CheckExistence(ctx, resourceGroup, nil)
 	if err != nil {
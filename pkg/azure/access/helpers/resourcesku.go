@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+)
+
+const (
+	resourceSKUsListServiceLabel = "resource_skus_list"
+	// virtualMachinesSKUResourceType is the resource type that Resource SKUs API uses for virtual machine sizes.
+	virtualMachinesSKUResourceType = "virtualMachines"
+)
+
+// ListVirtualMachineResourceSKUs lists all resource SKUs for virtual machines which are available in the given location.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func ListVirtualMachineResourceSKUs(ctx context.Context, skuAccess *armcompute.ResourceSKUsClient, location string) (skus []*armcompute.ResourceSKU, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, resourceSKUsListServiceLabel, &err)
+	defer recordFn()
+
+	pager := skuAccess.NewListPager(&armcompute.ResourceSKUsClientListOptions{
+		Filter: to.Ptr(fmt.Sprintf("location eq '%s'", location)),
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			errors.LogAzAPIError(err, "Failed to list resource SKUs [Location: %s]", location)
+			return nil, err
+		}
+		for _, sku := range page.Value {
+			if sku == nil || sku.ResourceType == nil || !strings.EqualFold(*sku.ResourceType, virtualMachinesSKUResourceType) {
+				continue
+			}
+			skus = append(skus, sku)
+		}
+	}
+	return skus, nil
+}
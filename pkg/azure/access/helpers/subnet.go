@@ -18,7 +18,8 @@ const subnetGetServiceLabel = "subnet_get"
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func GetSubnet(ctx context.Context, subnetAccess *armnetwork.SubnetsClient, resourceGroup, virtualNetworkName, subnetName string) (subnet *armnetwork.Subnet, err error) {
 	var subnetResp armnetwork.SubnetsClientGetResponse
-	defer instrument.AZAPIMetricRecorderFn(subnetGetServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, subnetGetServiceLabel, &err)
+	defer recordFn()
 
 	subnetResp, err = subnetAccess.Get(ctx, resourceGroup, virtualNetworkName, subnetName, nil)
 	if err != nil {
@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+// OperationTimeouts are the timeouts for the long-running operations against Azure. Each operation is additionally
+// bounded by the context passed by the caller.
+type OperationTimeouts struct {
+	// CreateNIC is the timeout to create a NIC.
+	CreateNIC time.Duration
+	// DeleteNIC is the timeout to delete a NIC.
+	DeleteNIC time.Duration
+	// CreateVM is the timeout to create a VM.
+	CreateVM time.Duration
+	// UpdateVM is the timeout to update a VM.
+	UpdateVM time.Duration
+	// DeleteVM is the timeout to delete a VM.
+	DeleteVM time.Duration
+	// StartVM is the timeout to start a VM.
+	StartVM time.Duration
+	// DeallocateVM is the timeout to deallocate a VM.
+	DeallocateVM time.Duration
+	// DeleteDisk is the timeout to delete a disk.
+	DeleteDisk time.Duration
+	// DeletePublicIP is the timeout to delete a public IP address.
+	DeletePublicIP time.Duration
+}
+
+var (
+	operationTimeoutsMutex sync.RWMutex
+	operationTimeouts      = DefaultOperationTimeouts()
+)
+
+// DefaultOperationTimeouts returns the default OperationTimeouts.
+func DefaultOperationTimeouts() OperationTimeouts {
+	return OperationTimeouts{
+		CreateNIC:      defaultCreateNICTimeout,
+		DeleteNIC:      defaultDeleteNICTimeout,
+		CreateVM:       defaultCreateVMTimeout,
+		UpdateVM:       defaultUpdateVMTimeout,
+		DeleteVM:       defaultDeleteVMTimeout,
+		StartVM:        defaultStartVMTimeout,
+		DeallocateVM:   defaultDeallocateVMTimeout,
+		DeleteDisk:     defaultDiskOperationTimeout,
+		DeletePublicIP: defaultDeletePublicIPTimeout,
+	}
+}
+
+// SetOperationTimeouts sets the timeouts for the long-running operations. Timeouts which are not set (zero) keep their default.
+func SetOperationTimeouts(timeouts OperationTimeouts) {
+	defaults := DefaultOperationTimeouts()
+	operationTimeoutsMutex.Lock()
+	defer operationTimeoutsMutex.Unlock()
+	operationTimeouts = OperationTimeouts{
+		CreateNIC:      durationOrDefault(timeouts.CreateNIC, defaults.CreateNIC),
+		DeleteNIC:      durationOrDefault(timeouts.DeleteNIC, defaults.DeleteNIC),
+		CreateVM:       durationOrDefault(timeouts.CreateVM, defaults.CreateVM),
+		UpdateVM:       durationOrDefault(timeouts.UpdateVM, defaults.UpdateVM),
+		DeleteVM:       durationOrDefault(timeouts.DeleteVM, defaults.DeleteVM),
+		StartVM:        durationOrDefault(timeouts.StartVM, defaults.StartVM),
+		DeallocateVM:   durationOrDefault(timeouts.DeallocateVM, defaults.DeallocateVM),
+		DeleteDisk:     durationOrDefault(timeouts.DeleteDisk, defaults.DeleteDisk),
+		DeletePublicIP: durationOrDefault(timeouts.DeletePublicIP, defaults.DeletePublicIP),
+	}
+}
+
+// GetOperationTimeouts returns the currently configured timeouts for the long-running operations.
+func GetOperationTimeouts() OperationTimeouts {
+	operationTimeoutsMutex.RLock()
+	defer operationTimeoutsMutex.RUnlock()
+	return operationTimeouts
+}
+
+func durationOrDefault(d, defaultDuration time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return defaultDuration
+}
@@ -0,0 +1,25 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSetOperationTimeouts(t *testing.T) {
+	g := NewWithT(t)
+	defer SetOperationTimeouts(DefaultOperationTimeouts())
+
+	g.Expect(GetOperationTimeouts()).To(Equal(DefaultOperationTimeouts()))
+
+	SetOperationTimeouts(OperationTimeouts{CreateNIC: 5 * time.Minute, DeleteDisk: 20 * time.Minute})
+	expected := DefaultOperationTimeouts()
+	expected.CreateNIC = 5 * time.Minute
+	expected.DeleteDisk = 20 * time.Minute
+	g.Expect(GetOperationTimeouts()).To(Equal(expected))
+}
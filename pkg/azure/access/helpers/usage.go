@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+)
+
+const usagesListServiceLabel = "usages_list"
+
+// ListUsages lists the current compute resource usages and their limits of the subscription in the given location.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func ListUsages(ctx context.Context, usageAccess *armcompute.UsageClient, location string) (usages []*armcompute.Usage, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, usagesListServiceLabel, &err)
+	defer recordFn()
+
+	pager := usageAccess.NewListPager(location, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			errors.LogAzAPIError(err, "Failed to list usages [Location: %s]", location)
+			return nil, err
+		}
+		for _, usage := range page.Value {
+			if usage != nil {
+				usages = append(usages, usage)
+			}
+		}
+	}
+	return usages, nil
+}
@@ -9,6 +9,7 @@ import (
 	"k8s.io/klog/v2"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
@@ -16,10 +17,13 @@ import (
 
 // labels used for recording prometheus metrics
 const (
-	vmGetServiceLabel    = "virtual_machine_get"
-	vmUpdateServiceLabel = "virtual_machine_update"
-	vmDeleteServiceLabel = "virtual_machine_delete"
-	vmCreateServiceLabel = "virtual_machine_create"
+	vmGetServiceLabel        = "virtual_machine_get"
+	vmUpdateServiceLabel     = "virtual_machine_update"
+	vmDeleteServiceLabel     = "virtual_machine_delete"
+	vmCreateServiceLabel     = "virtual_machine_create"
+	vmStartServiceLabel      = "virtual_machine_start"
+	vmDeallocateServiceLabel = "virtual_machine_deallocate"
+	vmInstanceViewLabel      = "virtual_machine_instance_view"
 )
 
 // Default timeouts for all async operations - Create/Delete/Update
@@ -29,14 +33,17 @@ const (
 	//defaultUpdateVMTimeout is the timeout required to complete an update of a VM. It is currently
 	// seen that update is relatively faster and therefore a lower timeout has been kept. This could
 	// be changed in the future depending on the metrics that we record and observe.
-	defaultUpdateVMTimeout = 10 * time.Minute
+	defaultUpdateVMTimeout     = 10 * time.Minute
+	defaultStartVMTimeout      = 10 * time.Minute
+	defaultDeallocateVMTimeout = 10 * time.Minute
 )
 
 // GetVirtualMachine gets a VirtualMachine for the given vm name and resource group.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func GetVirtualMachine(ctx context.Context, vmClient *armcompute.VirtualMachinesClient, resourceGroup, vmName string) (vm *armcompute.VirtualMachine, err error) {
 	var getResp armcompute.VirtualMachinesClientGetResponse
-	defer instrument.AZAPIMetricRecorderFn(vmGetServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmGetServiceLabel, &err)
+	defer recordFn()
 
 	getResp, err = vmClient.Get(ctx, resourceGroup, vmName, nil)
 	if err != nil {
@@ -49,22 +56,50 @@ func GetVirtualMachine(ctx context.Context, vmClient *armcompute.VirtualMachines
 	return
 }
 
+// GetVirtualMachineWithInstanceView gets a VirtualMachine together with its instance view, which reports the
+// provisioning and power state of the VM, for the given vm name and resource group.
+// If the VM does not exist then it will return nil.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func GetVirtualMachineWithInstanceView(ctx context.Context, vmClient *armcompute.VirtualMachinesClient, resourceGroup, vmName string) (vm *armcompute.VirtualMachine, err error) {
+	var getResp armcompute.VirtualMachinesClientGetResponse
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmGetServiceLabel, &err)
+	defer recordFn()
+
+	getResp, err = vmClient.Get(ctx, resourceGroup, vmName, &armcompute.VirtualMachinesClientGetOptions{Expand: to.Ptr(armcompute.InstanceViewTypesInstanceView)})
+	if err != nil {
+		if errors.IsNotFoundAzAPIError(err) {
+			return nil, nil
+		}
+		return
+	}
+	vm = &getResp.VirtualMachine
+	return
+}
+
 // DeleteVirtualMachine deletes the Virtual Machine with the give name and belonging to the passed in resource group.
 // If cascade delete is set for associated NICs and Disks then these resources will also be deleted along with the VM.
+// If forceDeletion is true then the VM is force deleted.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
-func DeleteVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachinesClient, resourceGroup, vmName string) (err error) {
-	defer instrument.AZAPIMetricRecorderFn(vmDeleteServiceLabel, &err)()
+func DeleteVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachinesClient, resourceGroup, vmName string, forceDeletion bool) (err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmDeleteServiceLabel, &err)
+	defer recordFn()
+	defer instrument.LRODurationRecorderFn(ctx, vmDeleteServiceLabel, &err)()
 
-	delCtx, cancelFn := context.WithTimeout(ctx, defaultDeleteVMTimeout)
+	delCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().DeleteVM)
 	defer cancelFn()
-	poller, err := vmAccess.BeginDelete(delCtx, resourceGroup, vmName, nil)
+	var options *armcompute.VirtualMachinesClientBeginDeleteOptions
+	if forceDeletion {
+		options = &armcompute.VirtualMachinesClientBeginDeleteOptions{ForceDeletion: to.Ptr(true)}
+	}
+	poller, err := vmAccess.BeginDelete(delCtx, resourceGroup, vmName, options)
 	if err != nil {
 		errors.LogAzAPIError(err, "Failed to trigger delete of VM [ResourceGroup: %s, VMName: %s]", resourceGroup, vmName)
 		return
 	}
-	_, err = poller.PollUntilDone(delCtx, nil)
+	_, err = pollUntilDone(delCtx, poller)
 	if err != nil {
 		errors.LogAzAPIError(err, "Polling failed while waiting for delete of VM: %s for ResourceGroup: %s", vmName, resourceGroup)
+		err = withResumeToken(poller, err)
 		return
 	}
 	klog.Infof("Successfully deleted VM: %s, for ResourceGroup: %s", vmName, resourceGroup)
@@ -74,9 +109,11 @@ func DeleteVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachi
 // CreateVirtualMachine creates a Virtual Machine given a resourceGroup and virtual machine creation parameters.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func CreateVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachinesClient, resourceGroup string, vmCreationParams armcompute.VirtualMachine) (vm *armcompute.VirtualMachine, err error) {
-	defer instrument.AZAPIMetricRecorderFn(vmCreateServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmCreateServiceLabel, &err)
+	defer recordFn()
+	defer instrument.LRODurationRecorderFn(ctx, vmCreateServiceLabel, &err)()
 
-	createCtx, cancelFn := context.WithTimeout(ctx, defaultCreateVMTimeout)
+	createCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().CreateVM)
 	defer cancelFn()
 	vmName := *vmCreationParams.Name
 	poller, err := vmAccess.BeginCreateOrUpdate(createCtx, resourceGroup, vmName, vmCreationParams, nil)
@@ -84,9 +121,34 @@ func CreateVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachi
 		errors.LogAzAPIError(err, "Failed to trigger create of VM [ResourceGroup: %s, VMName: %s]", resourceGroup, vmName)
 		return
 	}
-	createResp, err := poller.PollUntilDone(createCtx, nil)
+	createResp, err := pollUntilDone(createCtx, poller)
 	if err != nil {
 		errors.LogAzAPIError(err, "Polling failed while waiting for create of VM: %s for ResourceGroup: %s", vmName, resourceGroup)
+		err = withResumeToken(poller, err)
+		return
+	}
+	vm = &createResp.VirtualMachine
+	return
+}
+
+// ResumeCreateVirtualMachine resumes polling the creation of a Virtual Machine which has been triggered earlier, using
+// the resume token of its poller.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func ResumeCreateVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachinesClient, resourceGroup string, vmName string, resumeToken string) (vm *armcompute.VirtualMachine, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmCreateServiceLabel, &err)
+	defer recordFn()
+
+	createCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().CreateVM)
+	defer cancelFn()
+	poller, err := vmAccess.BeginCreateOrUpdate(createCtx, resourceGroup, vmName, armcompute.VirtualMachine{}, &armcompute.VirtualMachinesClientBeginCreateOrUpdateOptions{ResumeToken: resumeToken})
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to resume create of VM [ResourceGroup: %s, VMName: %s]", resourceGroup, vmName)
+		return
+	}
+	createResp, err := pollUntilDone(createCtx, poller)
+	if err != nil {
+		errors.LogAzAPIError(err, "Polling failed while waiting for resumed create of VM: %s for ResourceGroup: %s", vmName, resourceGroup)
+		err = withResumeToken(poller, err)
 		return
 	}
 	vm = &createResp.VirtualMachine
@@ -96,19 +158,106 @@ func CreateVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachi
 // SetCascadeDeleteForNICsAndDisks sets cascade deletion for NICs and Disks (OSDisk and DataDisks) associated to passed virtual machine.
 // NOTE: All calls to this Azure API are instrumented as prometheus metric.
 func SetCascadeDeleteForNICsAndDisks(ctx context.Context, vmClient *armcompute.VirtualMachinesClient, resourceGroup string, vmName string, vmUpdateParams *armcompute.VirtualMachineUpdate) (err error) {
-	defer instrument.AZAPIMetricRecorderFn(vmUpdateServiceLabel, &err)()
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmUpdateServiceLabel, &err)
+	defer recordFn()
 
-	updCtx, cancelFn := context.WithTimeout(ctx, defaultUpdateVMTimeout)
+	updCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().UpdateVM)
 	defer cancelFn()
 	poller, err := vmClient.BeginUpdate(updCtx, resourceGroup, vmName, *vmUpdateParams, nil)
 	if err != nil {
 		errors.LogAzAPIError(err, "Failed to trigger update of VM [ResourceGroup: %s, VMName: %s]", resourceGroup, vmName)
 		return
 	}
-	_, err = poller.PollUntilDone(updCtx, nil)
+	_, err = pollUntilDone(updCtx, poller)
+	if err != nil {
+		errors.LogAzAPIError(err, "Polling failed while waiting for update of VM: %s for ResourceGroup: %s", vmName, resourceGroup)
+		return
+	}
+	return
+}
+
+// UpdateVirtualMachine updates the Virtual Machine with the given name and belonging to the passed in resource group.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func UpdateVirtualMachine(ctx context.Context, vmClient *armcompute.VirtualMachinesClient, resourceGroup, vmName string, vmUpdateParams armcompute.VirtualMachineUpdate) (vm *armcompute.VirtualMachine, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmUpdateServiceLabel, &err)
+	defer recordFn()
+
+	updCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().UpdateVM)
+	defer cancelFn()
+	poller, err := vmClient.BeginUpdate(updCtx, resourceGroup, vmName, vmUpdateParams, nil)
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to trigger update of VM [ResourceGroup: %s, VMName: %s]", resourceGroup, vmName)
+		return
+	}
+	updateResp, err := pollUntilDone(updCtx, poller)
 	if err != nil {
 		errors.LogAzAPIError(err, "Polling failed while waiting for update of VM: %s for ResourceGroup: %s", vmName, resourceGroup)
 		return
 	}
+	vm = &updateResp.VirtualMachine
+	return
+}
+
+// GetVirtualMachineInstanceView gets the instance view of the VirtualMachine for the given vm name and resource group.
+// If the VM does not exist then it will return nil.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func GetVirtualMachineInstanceView(ctx context.Context, vmClient *armcompute.VirtualMachinesClient, resourceGroup, vmName string) (instanceView *armcompute.VirtualMachineInstanceView, err error) {
+	var resp armcompute.VirtualMachinesClientInstanceViewResponse
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmInstanceViewLabel, &err)
+	defer recordFn()
+
+	resp, err = vmClient.InstanceView(ctx, resourceGroup, vmName, nil)
+	if err != nil {
+		if errors.IsNotFoundAzAPIError(err) {
+			return nil, nil
+		}
+		return
+	}
+	instanceView = &resp.VirtualMachineInstanceView
+	return
+}
+
+// StartVirtualMachine starts the Virtual Machine with the given name and belonging to the passed in resource group.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func StartVirtualMachine(ctx context.Context, vmClient *armcompute.VirtualMachinesClient, resourceGroup, vmName string) (err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmStartServiceLabel, &err)
+	defer recordFn()
+
+	startCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().StartVM)
+	defer cancelFn()
+	poller, err := vmClient.BeginStart(startCtx, resourceGroup, vmName, nil)
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to trigger start of VM [ResourceGroup: %s, VMName: %s]", resourceGroup, vmName)
+		return
+	}
+	_, err = pollUntilDone(startCtx, poller)
+	if err != nil {
+		errors.LogAzAPIError(err, "Polling failed while waiting for start of VM: %s for ResourceGroup: %s", vmName, resourceGroup)
+		return
+	}
+	klog.Infof("Successfully started VM: %s, for ResourceGroup: %s", vmName, resourceGroup)
+	return
+}
+
+// DeallocateVirtualMachine stops the Virtual Machine with the given name and belonging to the passed in resource group
+// and releases its compute resources.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func DeallocateVirtualMachine(ctx context.Context, vmClient *armcompute.VirtualMachinesClient, resourceGroup, vmName string) (err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmDeallocateServiceLabel, &err)
+	defer recordFn()
+
+	deallocateCtx, cancelFn := context.WithTimeout(ctx, GetOperationTimeouts().DeallocateVM)
+	defer cancelFn()
+	poller, err := vmClient.BeginDeallocate(deallocateCtx, resourceGroup, vmName, nil)
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to trigger deallocation of VM [ResourceGroup: %s, VMName: %s]", resourceGroup, vmName)
+		return
+	}
+	_, err = pollUntilDone(deallocateCtx, poller)
+	if err != nil {
+		errors.LogAzAPIError(err, "Polling failed while waiting for deallocation of VM: %s for ResourceGroup: %s", vmName, resourceGroup)
+		return
+	}
+	klog.Infof("Successfully deallocated VM: %s, for ResourceGroup: %s", vmName, resourceGroup)
 	return
 }
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+)
+
+const (
+	vmExtensionCreateServiceLabel = "virtual_machine_extension_create"
+	// defaultCreateVMExtensionTimeout is the timeout to install a VM extension. Extensions run on the VM
+	// and installation can take a while depending on the extension, hence a generous timeout has been kept.
+	defaultCreateVMExtensionTimeout = 15 * time.Minute
+)
+
+// CreateOrUpdateVMExtension creates or updates an extension of a virtual machine.
+// NOTE: All calls to this Azure API are instrumented as prometheus metric.
+func CreateOrUpdateVMExtension(ctx context.Context, vmExtensionsAccess *armcompute.VirtualMachineExtensionsClient, resourceGroup, vmName string, extensionParams armcompute.VirtualMachineExtension) (extension *armcompute.VirtualMachineExtension, err error) {
+	ctx, recordFn := instrument.AZAPIRecorderFn(ctx, vmExtensionCreateServiceLabel, &err)
+	defer recordFn()
+
+	createCtx, cancelFn := context.WithTimeout(ctx, defaultCreateVMExtensionTimeout)
+	defer cancelFn()
+	extensionName := *extensionParams.Name
+	poller, err := vmExtensionsAccess.BeginCreateOrUpdate(createCtx, resourceGroup, vmName, extensionName, extensionParams, nil)
+	if err != nil {
+		errors.LogAzAPIError(err, "Failed to trigger create of VM extension [ResourceGroup: %s, VMName: %s, Extension: %s]", resourceGroup, vmName, extensionName)
+		return
+	}
+	createResp, err := pollUntilDone(createCtx, poller)
+	if err != nil {
+		errors.LogAzAPIError(err, "Polling failed while waiting for create of VM extension [ResourceGroup: %s, VMName: %s, Extension: %s]", resourceGroup, vmName, extensionName)
+		return
+	}
+	extension = &createResp.VirtualMachineExtension
+	return
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyConfig contains the proxy settings which are used for all requests against Azure, including the ones to acquire tokens.
+// Fields which are not set fall back to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+type ProxyConfig struct {
+	// HTTPProxy is the URL of the proxy for plain HTTP requests.
+	HTTPProxy string
+	// HTTPSProxy is the URL of the proxy for HTTPS requests.
+	HTTPSProxy string
+	// NoProxy is a comma-separated list of hosts which are requested without a proxy.
+	NoProxy string
+}
+
+// IsEmpty returns true if none of the proxy settings is set.
+func (c ProxyConfig) IsEmpty() bool {
+	return len(c.HTTPProxy) == 0 && len(c.HTTPSProxy) == 0 && len(c.NoProxy) == 0
+}
+
+// newProxyTransport creates a policy.Transporter which sends requests via the proxies given by the ProxyConfig.
+func newProxyTransport(proxyConfig ProxyConfig) policy.Transporter {
+	cfg := httpproxy.FromEnvironment()
+	if len(proxyConfig.HTTPProxy) > 0 {
+		cfg.HTTPProxy = proxyConfig.HTTPProxy
+	}
+	if len(proxyConfig.HTTPSProxy) > 0 {
+		cfg.HTTPSProxy = proxyConfig.HTTPSProxy
+	}
+	if len(proxyConfig.NoProxy) > 0 {
+		cfg.NoProxy = proxyConfig.NoProxy
+	}
+	proxyFunc := cfg.ProxyFunc()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+	return &http.Client{Transport: transport}
+}
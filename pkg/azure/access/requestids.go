@@ -0,0 +1,28 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+// requestIDsPolicy is a per-retry policy which records the request IDs of every failed response in the context of the
+// request, see errors.WithRequestIDsRecorder. This makes the request IDs available to the callers even if the error they
+// get does not carry the response, e.g. if the polling of a long-running operation timed out after a failed poll.
+// Responses with status NotFound are not recorded, as they are expected by the existence checks of the driver and would
+// otherwise be attributed to unrelated errors which occur later on.
+type requestIDsPolicy struct{}
+
+func (requestIDsPolicy) Do(req *policy.Request) (*http.Response, error) {
+	resp, err := req.Next()
+	if resp != nil && resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusNotFound {
+		errors.RecordRequestIDs(req.Raw().Context(), resp.Header)
+	}
+	return resp, err
+}
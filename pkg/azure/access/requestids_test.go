@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+func TestRequestIDsPolicy(t *testing.T) {
+	table := []struct {
+		description        string
+		statusCode         int
+		expectedRequestIDs *errors.RequestIDs
+	}{
+		{"should not record the request IDs of a successful response", http.StatusOK, nil},
+		{"should record the request IDs of a failed response", http.StatusInternalServerError, &errors.RequestIDs{RequestID: "request-id", CorrelationRequestID: "correlation-request-id"}},
+		{"should not record the request IDs of a not found response", http.StatusNotFound, nil},
+	}
+
+	g := NewWithT(t)
+	header := http.Header{}
+	header.Set(errors.RequestIDAzHeaderKey, "request-id")
+	header.Set(errors.CorrelationRequestIDAzHeaderKey, "correlation-request-id")
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			pipeline := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &policy.ClientOptions{
+				Transport:        &fakeTransport{statusCodes: []int{entry.statusCode}, header: header},
+				Retry:            policy.RetryOptions{MaxRetries: -1},
+				PerRetryPolicies: []policy.Policy{requestIDsPolicy{}},
+			})
+			ctx := errors.WithRequestIDsRecorder(context.Background())
+			_, err := sendTestRequest(ctx, pipeline)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(errors.GetRecordedRequestIDs(ctx)).To(Equal(entry.expectedRequestIDs))
+		})
+	}
+}
+
+func TestRequestIDsPolicyDoesNotAttributeNotFoundToLaterErrors(t *testing.T) {
+	g := NewWithT(t)
+	header := http.Header{}
+	header.Set(errors.RequestIDAzHeaderKey, "get-request-id")
+	header.Set(errors.CorrelationRequestIDAzHeaderKey, "get-correlation-request-id")
+	pipeline := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:        &fakeTransport{statusCodes: []int{http.StatusNotFound}, header: header},
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+		PerRetryPolicies: []policy.Policy{requestIDsPolicy{}},
+	})
+	ctx := errors.WithRequestIDsRecorder(context.Background())
+
+	// existence check of the VM
+	resp, err := sendTestRequest(ctx, pipeline)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+
+	// creation of the VM times out while polling, the error does not carry a response
+	err = status.Error(codes.DeadlineExceeded, "timed out waiting for the creation of the VM")
+	errors.AddRequestIDs(ctx, &err)
+	g.Expect(err.Error()).ToNot(ContainSubstring("get-request-id"))
+	g.Expect(err.Error()).ToNot(ContainSubstring("get-correlation-request-id"))
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+)
+
+// defaultThrottlingBackoff is the backoff which is used if a throttled response does not specify when to retry.
+const defaultThrottlingBackoff = 10 * time.Second
+
+// throttlingTracker keeps track of the subscriptions which are throttled by Azure. Azure throttles requests per
+// subscription, therefore all requests for a throttled subscription are held back until the throttling ends instead of
+// sending further requests which would only be throttled as well.
+type throttlingTracker struct {
+	sync.Mutex
+	throttledUntil map[string]time.Time
+	// now is used to get the current time. It can be replaced in unit tests.
+	now func() time.Time
+}
+
+func newThrottlingTracker() *throttlingTracker {
+	return &throttlingTracker{
+		throttledUntil: make(map[string]time.Time),
+		now:            time.Now,
+	}
+}
+
+// recordThrottling records that the subscription is throttled for the given duration.
+func (t *throttlingTracker) recordThrottling(subscriptionID string, retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = defaultThrottlingBackoff
+	}
+	t.Lock()
+	defer t.Unlock()
+	until := t.now().Add(retryAfter)
+	if until.After(t.throttledUntil[subscriptionID]) {
+		t.throttledUntil[subscriptionID] = until
+	}
+}
+
+// remainingThrottling returns the duration for which the subscription is still throttled.
+func (t *throttlingTracker) remainingThrottling(subscriptionID string) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	until, ok := t.throttledUntil[subscriptionID]
+	if !ok {
+		return 0
+	}
+	remaining := until.Sub(t.now())
+	if remaining <= 0 {
+		delete(t.throttledUntil, subscriptionID)
+		return 0
+	}
+	return remaining
+}
+
+// throttlingBackoffPolicy is a per-call policy which holds back requests while the subscription is throttled. If the
+// throttling does not end before the deadline of the request, an errors.ThrottledError is returned immediately.
+type throttlingBackoffPolicy struct {
+	tracker        *throttlingTracker
+	subscriptionID string
+}
+
+func (p throttlingBackoffPolicy) Do(req *policy.Request) (*http.Response, error) {
+	remaining := p.tracker.remainingThrottling(p.subscriptionID)
+	if remaining > 0 {
+		ctx := req.Raw().Context()
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < remaining {
+			return nil, &errors.ThrottledError{SubscriptionID: p.subscriptionID, RetryAfter: remaining}
+		}
+		klog.V(4).Infof("Requests for subscription %s are throttled, waiting %s before sending request %s %s", p.subscriptionID, remaining, req.Raw().Method, req.Raw().URL.Path)
+		waitStart := time.Now()
+		timer := time.NewTimer(remaining)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			instrument.RecordAzAPIThrottlingWait(p.subscriptionID, time.Since(waitStart))
+		case <-ctx.Done():
+			instrument.RecordAzAPIThrottlingWait(p.subscriptionID, time.Since(waitStart))
+			return nil, ctx.Err()
+		}
+	}
+	return req.Next()
+}
+
+// throttlingRecorderPolicy is a per-retry policy which records throttled responses, so that the throttlingBackoffPolicy
+// holds back further requests for the subscription. The retry of the throttled request itself is done by the retry policy
+// of the Azure SDK which respects the Retry-After header.
+type throttlingRecorderPolicy struct {
+	tracker        *throttlingTracker
+	subscriptionID string
+}
+
+func (p throttlingRecorderPolicy) Do(req *policy.Request) (*http.Response, error) {
+	resp, err := req.Next()
+	if err == nil && resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := errors.GetRetryAfter(resp.Header)
+		klog.Warningf("Request %s %s for subscription %s has been throttled, retry after %s", req.Raw().Method, req.Raw().URL.Path, p.subscriptionID, retryAfter)
+		instrument.RecordAzAPIThrottling(p.subscriptionID, retryAfter)
+		p.tracker.recordThrottling(p.subscriptionID, retryAfter)
+	}
+	return resp, err
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+const testSubscriptionID = "subscription-0"
+
+type fakeTransport struct {
+	statusCodes []int
+	header      http.Header
+	requests    int
+}
+
+func (f *fakeTransport) Do(req *http.Request) (*http.Response, error) {
+	statusCode := f.statusCodes[min(f.requests, len(f.statusCodes)-1)]
+	f.requests++
+	return &http.Response{StatusCode: statusCode, Header: f.header, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func newTestPipeline(tracker *throttlingTracker, transport *fakeTransport) runtime.Pipeline {
+	return runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:        transport,
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+		PerCallPolicies:  []policy.Policy{throttlingBackoffPolicy{tracker: tracker, subscriptionID: testSubscriptionID}},
+		PerRetryPolicies: []policy.Policy{throttlingRecorderPolicy{tracker: tracker, subscriptionID: testSubscriptionID}},
+	})
+}
+
+func sendTestRequest(ctx context.Context, pipeline runtime.Pipeline) (*http.Response, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, "https://management.azure.com/subscriptions/"+testSubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Do(req)
+}
+
+func TestThrottlingTracker(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+	tracker := newThrottlingTracker()
+	tracker.now = func() time.Time { return now }
+
+	g.Expect(tracker.remainingThrottling(testSubscriptionID)).To(BeZero())
+	tracker.recordThrottling(testSubscriptionID, 30*time.Second)
+	g.Expect(tracker.remainingThrottling(testSubscriptionID)).To(Equal(30 * time.Second))
+	g.Expect(tracker.remainingThrottling("other-subscription")).To(BeZero())
+	// a shorter throttling does not shorten a longer one
+	tracker.recordThrottling(testSubscriptionID, time.Second)
+	g.Expect(tracker.remainingThrottling(testSubscriptionID)).To(Equal(30 * time.Second))
+	now = now.Add(time.Minute)
+	g.Expect(tracker.remainingThrottling(testSubscriptionID)).To(BeZero())
+	// a missing retry duration falls back to the default
+	tracker.recordThrottling(testSubscriptionID, 0)
+	g.Expect(tracker.remainingThrottling(testSubscriptionID)).To(Equal(defaultThrottlingBackoff))
+}
+
+func TestThrottlingPolicies(t *testing.T) {
+	g := NewWithT(t)
+
+	t.Run("throttled response is recorded", func(_ *testing.T) {
+		tracker := newThrottlingTracker()
+		transport := &fakeTransport{statusCodes: []int{http.StatusTooManyRequests}, header: http.Header{errors.RetryAfterHeaderKey: []string{"120"}}}
+		resp, err := sendTestRequest(context.Background(), newTestPipeline(tracker, transport))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		g.Expect(tracker.remainingThrottling(testSubscriptionID)).To(BeNumerically(">", 110*time.Second))
+	})
+
+	t.Run("request is not sent if throttling outlasts the deadline", func(_ *testing.T) {
+		tracker := newThrottlingTracker()
+		tracker.recordThrottling(testSubscriptionID, time.Minute)
+		transport := &fakeTransport{statusCodes: []int{http.StatusOK}}
+		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second)
+		defer cancelFn()
+		_, err := sendTestRequest(ctx, newTestPipeline(tracker, transport))
+		g.Expect(errors.IsThrottledAzAPIError(err)).To(BeTrue())
+		g.Expect(transport.requests).To(BeZero())
+	})
+
+	t.Run("request is sent after the throttling ended", func(_ *testing.T) {
+		tracker := newThrottlingTracker()
+		tracker.recordThrottling(testSubscriptionID, 50*time.Millisecond)
+		transport := &fakeTransport{statusCodes: []int{http.StatusOK}}
+		start := time.Now()
+		resp, err := sendTestRequest(context.Background(), newTestPipeline(tracker, transport))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		g.Expect(transport.requests).To(Equal(1))
+		g.Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+	})
+}
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+)
+
+// tracingPolicy is a per-retry policy which creates a span for every request sent to Azure. The span carries the method,
+// the redacted URI, the status and the request IDs of the response, which are required to look the request up at Azure.
+type tracingPolicy struct {
+	subscriptionID string
+}
+
+func (p tracingPolicy) Do(req *policy.Request) (resp *http.Response, err error) {
+	ctx, endSpanFn := instrument.StartSpan(req.Raw().Context(), "azure.request "+req.Raw().Method,
+		attribute.String("azure.subscription_id", p.subscriptionID),
+		attribute.String("http.request.method", req.Raw().Method),
+		attribute.String("url.full", redactURL(req.Raw().URL)),
+	)
+	defer endSpanFn(&err)
+
+	resp, err = req.Next()
+	if resp != nil {
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.Int("http.response.status_code", resp.StatusCode),
+			attribute.String("azure.request_id", resp.Header.Get(errors.RequestIDAzHeaderKey)),
+			attribute.String("azure.correlation_request_id", resp.Header.Get(errors.CorrelationRequestIDAzHeaderKey)),
+		)
+	}
+	return
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package access
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+func TestTracingPolicy(t *testing.T) {
+	g := NewWithT(t)
+	recorder := tracetest.NewSpanRecorder()
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	header := http.Header{}
+	header.Set(errors.RequestIDAzHeaderKey, "request-id")
+	header.Set(errors.CorrelationRequestIDAzHeaderKey, "correlation-request-id")
+	transport := &fakeTransport{statusCodes: []int{http.StatusOK}, header: header}
+	pipeline := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{}, &policy.ClientOptions{
+		Transport:        transport,
+		Retry:            policy.RetryOptions{MaxRetries: -1},
+		PerRetryPolicies: []policy.Policy{tracingPolicy{subscriptionID: testSubscriptionID}},
+	})
+	_, err := sendTestRequest(context.Background(), pipeline)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	spans := recorder.Ended()
+	g.Expect(spans).To(HaveLen(1))
+	g.Expect(spans[0].Name()).To(Equal("azure.request GET"))
+	g.Expect(spans[0].Attributes()).To(ContainElements(
+		attribute.String("azure.subscription_id", testSubscriptionID),
+		attribute.Int("http.response.status_code", http.StatusOK),
+		attribute.String("azure.request_id", "request-id"),
+		attribute.String("azure.correlation_request_id", "correlation-request-id"),
+	))
+}
@@ -20,15 +20,20 @@ type ConnectConfig struct {
 	// TenantID is a unique identifier for an active directory tenant.
 	TenantID string
 	// ClientID is a unique identity assigned by azure active directory to an application.
+	// If neither ClientSecret nor WorkloadIdentityTokenFile is set, it optionally identifies a user-assigned managed identity.
 	ClientID string
 	// ClientSecret is a certificate issued for the ClientID.
-	// This field is mutually exclusive with WorkloadIdentityTokenFile.
+	// This field is mutually exclusive with WorkloadIdentityTokenFile. If both are empty, the managed identity of the
+	// environment is used for authentication.
 	ClientSecret string
 	// WorkloadIdentityTokenFile is the file containing a federated token for authentication against Azure.
 	// This field is mutually exclusive with ClientSecret.
 	WorkloadIdentityTokenFile string
 	// ClientOptions are the options to use when connecting with clients.
 	ClientOptions policy.ClientOptions
+	// AuxiliaryTenantIDs are additional tenants for which tokens are acquired and sent along with the requests of clients which support
+	// cross-tenant requests. This is e.g. required to create a VM from an image which is hosted in another tenant.
+	AuxiliaryTenantIDs []string
 }
 
 // Factory is an access factory providing methods to get facade/access for different resources.
@@ -40,6 +45,8 @@ type Factory interface {
 	GetVirtualMachinesAccess(connectConfig ConnectConfig) (*armcompute.VirtualMachinesClient, error)
 	// GetNetworkInterfacesAccess creates and returns a new instance of armnetwork.InterfacesClient.
 	GetNetworkInterfacesAccess(connectConfig ConnectConfig) (*armnetwork.InterfacesClient, error)
+	// GetPublicIPAddressesAccess creates and returns a new instance of armnetwork.PublicIPAddressesClient.
+	GetPublicIPAddressesAccess(connectConfig ConnectConfig) (*armnetwork.PublicIPAddressesClient, error)
 	// GetSubnetAccess creates and returns a new instance of armnetwork.SubnetsClient.
 	GetSubnetAccess(connectConfig ConnectConfig) (*armnetwork.SubnetsClient, error)
 	// GetDisksAccess creates and returns a new instance of armcompute.DisksClient.
@@ -50,4 +57,18 @@ type Factory interface {
 	GetVirtualMachineImagesAccess(connectConfig ConnectConfig) (*armcompute.VirtualMachineImagesClient, error)
 	// GetMarketPlaceAgreementsAccess creates and returns a new instance of armmarketplaceordering.MarketplaceAgreementsClient.
 	GetMarketPlaceAgreementsAccess(connectConfig ConnectConfig) (*armmarketplaceordering.MarketplaceAgreementsClient, error)
+	// GetResourceSKUsAccess creates and returns a new instance of armcompute.ResourceSKUsClient.
+	GetResourceSKUsAccess(connectConfig ConnectConfig) (*armcompute.ResourceSKUsClient, error)
+	// GetUsageAccess creates and returns a new instance of armcompute.UsageClient.
+	GetUsageAccess(connectConfig ConnectConfig) (*armcompute.UsageClient, error)
+	// GetVirtualMachineExtensionsAccess creates and returns a new instance of armcompute.VirtualMachineExtensionsClient.
+	GetVirtualMachineExtensionsAccess(connectConfig ConnectConfig) (*armcompute.VirtualMachineExtensionsClient, error)
+	// GetSharedGalleryImageVersionsAccess creates and returns a new instance of armcompute.SharedGalleryImageVersionsClient.
+	GetSharedGalleryImageVersionsAccess(connectConfig ConnectConfig) (*armcompute.SharedGalleryImageVersionsClient, error)
+	// GetCommunityGalleryImageVersionsAccess creates and returns a new instance of armcompute.CommunityGalleryImageVersionsClient.
+	GetCommunityGalleryImageVersionsAccess(connectConfig ConnectConfig) (*armcompute.CommunityGalleryImageVersionsClient, error)
+	// GetSharedGalleryImagesAccess creates and returns a new instance of armcompute.SharedGalleryImagesClient.
+	GetSharedGalleryImagesAccess(connectConfig ConnectConfig) (*armcompute.SharedGalleryImagesClient, error)
+	// GetCommunityGalleryImagesAccess creates and returns a new instance of armcompute.CommunityGalleryImagesClient.
+	GetCommunityGalleryImagesAccess(connectConfig ConnectConfig) (*armcompute.CommunityGalleryImagesClient, error)
 }
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package defaulting - defaulting is used to set the defaults of unset fields of the cloud specific ProviderSpec
+package defaulting
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+const (
+	// DefaultOSDiskCreateOption is the default of api.AzureOSDisk.CreateOption, the OSDisk is created from the image of the VM.
+	DefaultOSDiskCreateOption = string(armcompute.DiskCreateOptionTypesFromImage)
+	// DefaultDataDiskCaching is the default of api.AzureDataDisk.Caching.
+	DefaultDataDiskCaching = string(armcompute.CachingTypesNone)
+	// DefaultDataDiskDeleteOption is the default of api.AzureDataDisk.DeleteOption, the data disk is deleted together with the VM.
+	DefaultDataDiskDeleteOption = string(armcompute.DiskDeleteOptionTypesDelete)
+	// DefaultNICType is the default of api.AzureNetworkProfile.NicType.
+	DefaultNICType = string(armnetwork.NetworkInterfaceNicTypeStandard)
+)
+
+// AppliedDefault is a default which has been set for a field of the api.AzureProviderSpec that was not set.
+type AppliedDefault struct {
+	// Field is the path of the defaulted field, e.g. providerSpec.properties.networkProfile.nicType.
+	Field string
+	// Value is the default which has been set.
+	Value string
+}
+
+// String returns the AppliedDefault in the form <field>=<value>.
+func (d AppliedDefault) String() string {
+	return fmt.Sprintf("%s=%s", d.Field, d.Value)
+}
+
+// SetDefaultsProviderSpec sets the defaults of all unset fields of the api.AzureProviderSpec and returns the applied
+// defaults in the order in which they have been set. Fields which are already set are never changed.
+func SetDefaultsProviderSpec(spec *api.AzureProviderSpec) []AppliedDefault {
+	var applied []AppliedDefault
+	propertiesPath := field.NewPath("providerSpec").Child("properties")
+
+	applied = append(applied, setDefaultsStorageProfile(&spec.Properties.StorageProfile, propertiesPath.Child("storageProfile"))...)
+	applied = append(applied, setDefaultsNetworkProfile(&spec.Properties.NetworkProfile, propertiesPath.Child("networkProfile"))...)
+
+	return applied
+}
+
+func setDefaultsStorageProfile(storageProfile *api.AzureStorageProfile, fldPath *field.Path) []AppliedDefault {
+	var applied []AppliedDefault
+	if utils.IsEmptyString(storageProfile.OsDisk.CreateOption) {
+		storageProfile.OsDisk.CreateOption = DefaultOSDiskCreateOption
+		applied = append(applied, AppliedDefault{Field: fldPath.Child("osDisk", "createOption").String(), Value: DefaultOSDiskCreateOption})
+	}
+	for i := range storageProfile.DataDisks {
+		dataDisk := &storageProfile.DataDisks[i]
+		dataDiskPath := fldPath.Child("dataDisks").Index(i)
+		if utils.IsEmptyString(dataDisk.Caching) {
+			dataDisk.Caching = DefaultDataDiskCaching
+			applied = append(applied, AppliedDefault{Field: dataDiskPath.Child("caching").String(), Value: DefaultDataDiskCaching})
+		}
+		if dataDisk.DeleteOption == nil {
+			dataDisk.DeleteOption = to.Ptr(DefaultDataDiskDeleteOption)
+			applied = append(applied, AppliedDefault{Field: dataDiskPath.Child("deleteOption").String(), Value: DefaultDataDiskDeleteOption})
+		}
+	}
+	return applied
+}
+
+func setDefaultsNetworkProfile(networkProfile *api.AzureNetworkProfile, fldPath *field.Path) []AppliedDefault {
+	var applied []AppliedDefault
+	if networkProfile.NicType == nil {
+		networkProfile.NicType = to.Ptr(DefaultNICType)
+		applied = append(applied, AppliedDefault{Field: fldPath.Child("nicType").String(), Value: DefaultNICType})
+	}
+	return applied
+}
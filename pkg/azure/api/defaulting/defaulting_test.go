@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package defaulting
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+)
+
+func TestSetDefaultsProviderSpec(t *testing.T) {
+	g := NewWithT(t)
+
+	spec := api.AzureProviderSpec{
+		Properties: api.AzureVirtualMachineProperties{
+			StorageProfile: api.AzureStorageProfile{
+				DataDisks: []api.AzureDataDisk{
+					{Lun: 0},
+					{Lun: 1, Caching: "ReadOnly", DeleteOption: to.Ptr("Detach")},
+				},
+			},
+		},
+	}
+	applied := SetDefaultsProviderSpec(&spec)
+
+	g.Expect(applied).To(Equal([]AppliedDefault{
+		{Field: "providerSpec.properties.storageProfile.osDisk.createOption", Value: "FromImage"},
+		{Field: "providerSpec.properties.storageProfile.dataDisks[0].caching", Value: "None"},
+		{Field: "providerSpec.properties.storageProfile.dataDisks[0].deleteOption", Value: "Delete"},
+		{Field: "providerSpec.properties.networkProfile.nicType", Value: "Standard"},
+	}))
+	g.Expect(spec.Properties.StorageProfile.OsDisk.CreateOption).To(Equal(DefaultOSDiskCreateOption))
+	g.Expect(spec.Properties.StorageProfile.DataDisks[0].Caching).To(Equal(DefaultDataDiskCaching))
+	g.Expect(spec.Properties.StorageProfile.DataDisks[0].DeleteOption).To(Equal(to.Ptr(DefaultDataDiskDeleteOption)))
+	g.Expect(spec.Properties.StorageProfile.DataDisks[1].Caching).To(Equal("ReadOnly"))
+	g.Expect(spec.Properties.StorageProfile.DataDisks[1].DeleteOption).To(Equal(to.Ptr("Detach")))
+	g.Expect(*spec.Properties.NetworkProfile.NicType).To(Equal(DefaultNICType))
+
+	// defaulting an already defaulted spec does not change it
+	defaulted := spec
+	g.Expect(SetDefaultsProviderSpec(&spec)).To(BeEmpty())
+	g.Expect(spec).To(Equal(defaulted))
+}
@@ -0,0 +1,7 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+//go:generate go run ../../../hack/schema-gen -api-dir . -out schema/azureproviderspec.schema.json
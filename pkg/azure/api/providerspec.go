@@ -5,8 +5,14 @@ SPDX-License-Identifier: Apache-2.0
 */
 
 // Package api defined the schema of the Azure Provider Spec
+//
+// It is the only schema of the provider spec in this repository. The legacy pkg/apis/v1 package of the former
+// MachinePlugin is not part of this repository anymore, hence there is no converter from it. Consumers which still
+// use the legacy types can check the JSON of their provider specs using ValidateRawProviderSpec of the validation package.
 package api
 
+import "encoding/json"
+
 const (
 	// AzureClientID is a constant for a key name that is part of the Azure cloud credentials.
 	// Deprecated: Use ClientID instead.
@@ -43,6 +49,9 @@ const (
 	// WorkloadIdentityTokenFile is a constant for a key name that is part of the Azure cloud credentials.
 	// It identifies a path to a file that contains a token that can be used for authentication against Azure.
 	WorkloadIdentityTokenFile string = "workloadIdentityTokenFile"
+	// AzureFederatedTokenFile is an alternative key name for WorkloadIdentityTokenFile. It follows the naming of the
+	// AZURE_FEDERATED_TOKEN_FILE environment variable which is used for projected service account tokens.
+	AzureFederatedTokenFile string = "azureFederatedTokenFile"
 	// SubscriptionID is a constant for a key name that is part of the Azure cloud credentials.
 	SubscriptionID string = "subscriptionID"
 	// TenantID is a constant for a key name that is part of the Azure cloud credentials.
@@ -50,6 +59,12 @@ const (
 	// UserData is a constant for a key name that is part of the secret passed to Driver methods.
 	// This contains a base64 encoded custom script that is run upon start of a VM.
 	UserData string = "userData"
+	// AzureCloud is a constant for an optional key name that is part of the Azure cloud credentials. It contains the
+	// name of the cloud to connect to and is only considered if the provider spec does not set a CloudConfiguration.
+	AzureCloud string = "azureCloud"
+	// AdminPassword is a constant for a key name that is part of the secret passed to Driver methods.
+	// This contains the password of the administrator account and is only required for Windows VMs.
+	AdminPassword string = "adminPassword"
 
 	// MachineSetKindAvailabilitySet is the machine set kind for AvailabilitySet.
 	// Deprecated: Use AzureVirtualMachineProperties.AvailabilitySet instead.
@@ -57,6 +72,13 @@ const (
 	// MachineSetKindVMO is the machine set kind for VirtualMachineScaleSet Orchestration Mode VM (VMO).
 	// Deprecated: Use AzureVirtualMachineProperties.VirtualMachineScaleSet instead.
 	MachineSetKindVMO string = "vmo"
+
+	// ForceDeletionAnnotation is the annotation on a Machine which controls if its VM is force deleted. If set to "true"
+	// or "false" it takes precedence over AzureVirtualMachineProperties.ForceDeletion of the MachineClass.
+	ForceDeletionAnnotation string = "azure.machine.gardener.cloud/force-deletion"
+	// ProtectFromDeletionAnnotation is the annotation on a Machine which protects it from being deleted, e.g. to keep a
+	// quarantined node for investigation. Unless it is set to "false", DeleteMachine refuses to delete the machine.
+	ProtectFromDeletionAnnotation string = "mcm.gardener.cloud/protect-from-deletion"
 )
 
 // AzureProviderSpec is the spec to be used while parsing the calls.
@@ -65,11 +87,17 @@ type AzureProviderSpec struct {
 	Location string `json:"location,omitempty"`
 	// Tags is a map of key-value pairs that will be set on resources. Currently, the tags are shared across VM, NIC, Disks.
 	// This is not ideal and will change with https://github.com/gardener/machine-controller-manager/blob/master/docs/proposals/hotupdate-instances.md
+	// At most 49 tags are allowed since the VM additionally gets the machine name tag. The characters <>%&\?/ which Azure
+	// does not allow in tag keys are replaced with '-', e.g. kubernetes.io/role/node is set as kubernetes.io-role-node.
 	Tags map[string]string `json:"tags,omitempty"`
 	// Properties defines configuration properties for different profiles (hardware, os, network, storage, availability/virtual-machine-scale-set etc.)
 	Properties AzureVirtualMachineProperties `json:"properties,omitempty"`
 	// ResourceGroup is a container that holds related resources for an azure solution. See [https://learn.microsoft.com/en-us/azure/azure-resource-manager/management/overview#resource-groups].
 	ResourceGroup string `json:"resourceGroup,omitempty"`
+	// AdditionalResourceGroups are further resource groups in which VMs, NICs or Disks of machines might have been placed,
+	// e.g. separate resource groups for disks or NICs. These resource groups are also searched when listing machines, so
+	// that orphaned resources outside ResourceGroup are discovered as well. This is optional.
+	AdditionalResourceGroups []string `json:"additionalResourceGroups,omitempty"`
 	// SubnetInfo contains the configuration for an existing subnet.
 	SubnetInfo AzureSubnetInfo `json:"subnetInfo,omitempty"`
 	// CloudConfiguration contains config that controls which cloud to connect to
@@ -101,6 +129,10 @@ type AzureVirtualMachineProperties struct {
 	IdentityID *string `json:"identityID,omitempty"`
 	// Zone is an availability zone where the virtual machine will be created.
 	Zone *int `json:"zone,omitempty"`
+	// Zones are availability zones across which virtual machines will be spread. For every virtual machine one of the zones is
+	// picked deterministically based on the hash of the machine name. If the virtual machine can not be allocated in the picked
+	// zone due to insufficient capacity then it is created in one of the other zones instead. This field is mutually exclusive with Zone.
+	Zones []int `json:"zones,omitempty"`
 	// VirtualMachineScaleSet specifies the virtual machine scale set to be associated with the virtual machine.
 	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machine-scale-sets/]
 	// Points to note:
@@ -109,6 +141,10 @@ type AzureVirtualMachineProperties struct {
 	// 3. Only `Flexible` variant of VMSS is currently supported. It is strongly recommended that consumers turn-off any
 	// autoscaling capabilities as it interferes with the lifecycle management of MCM and auto-scaling capabilities offered by Cluster-Autoscaler.
 	VirtualMachineScaleSet *AzureSubResource `json:"virtualMachineScaleSet,omitempty"`
+	// PlatformFaultDomain specifies the fault domain of the Flexible scale set into which the virtual machine is placed.
+	// It can only be set together with VirtualMachineScaleSet and must be less than the fault domain count of the scale set.
+	// If not set then Azure spreads the virtual machines across the fault domains of the scale set.
+	PlatformFaultDomain *int32 `json:"platformFaultDomain,omitempty"`
 	// DiagnosticsProfile specifies if boot metrics are enabled and where they are stored
 	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/boot-diagnostics]
 	DiagnosticsProfile *AzureDiagnosticsProfile `json:"diagnosticsProfile,omitempty"`
@@ -116,6 +152,140 @@ type AzureVirtualMachineProperties struct {
 	MachineSet *AzureMachineSetConfig `json:"machineSet,omitempty"`
 	// SecurityProfile specifies the security profile to be used for the virtual machine.
 	SecurityProfile *AzureSecurityProfile `json:"securityProfile,omitempty"`
+	// LicenseType specifies that the image or disk that is being used was licensed on-premises (Azure Hybrid Benefit).
+	// Possible values are for e.g. Windows_Server, Windows_Client, RHEL_BYOS and SLES_BYOS.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/linux/azure-hybrid-benefit-linux]
+	LicenseType *string `json:"licenseType,omitempty"`
+	// Extensions is a list of VM extensions that are installed on the virtual machine after it has been created.
+	// Extensions are installed sequentially in the order in which they are defined.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/extensions/overview]
+	Extensions []AzureVMExtension `json:"extensions,omitempty"`
+	// ApplicationHealthProfile configures the Application Health extension which reports the health of the node to Azure.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/extensions/health-extension]
+	ApplicationHealthProfile *AzureApplicationHealthProfile `json:"applicationHealthProfile,omitempty"`
+	// AdditionalCapabilities specifies additional capabilities which are enabled or disabled on the virtual machine.
+	AdditionalCapabilities *AzureAdditionalCapabilities `json:"additionalCapabilities,omitempty"`
+	// ScheduledEventsProfile specifies the configuration of scheduled events which are surfaced to the virtual machine via the instance metadata service.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/linux/scheduled-events]
+	ScheduledEventsProfile *AzureScheduledEventsProfile `json:"scheduledEventsProfile,omitempty"`
+	// UserData configures if the userData property of the virtual machine is populated. In contrast to customData, userData
+	// is made available via the instance metadata service for the whole lifetime of the virtual machine.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/user-data]
+	UserData *AzureUserData `json:"userData,omitempty"`
+	// GalleryApplications is a list of Compute Gallery VM applications which are installed on the virtual machine.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/vm-applications]
+	GalleryApplications []AzureGalleryApplication `json:"galleryApplications,omitempty"`
+	// ForceDeletion configures if virtual machines are force deleted. Force deletion shortens the deletion of virtual
+	// machines which are stuck in deleting state, but the content of the temporary disk is lost without a graceful shutdown.
+	// It can be overridden per machine using the ForceDeletionAnnotation.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/delete#force-delete-for-vms]
+	ForceDeletion bool `json:"forceDeletion,omitempty"`
+	// DeallocateBeforeDeletion configures if virtual machines are deallocated before they are deleted. Deallocation shuts
+	// down the operating system gracefully, so that shutdown hooks (e.g. flushing local data) can complete, and billing
+	// of the compute resources stops as soon as the virtual machine is deallocated.
+	DeallocateBeforeDeletion bool `json:"deallocateBeforeDeletion,omitempty"`
+	// FailedCreationCleanup configures if the resources of a virtual machine whose creation has failed are deleted together
+	// with the machine or retained, e.g. to debug boot failures. If not set then the resources are always deleted.
+	FailedCreationCleanup *AzureFailedCreationCleanup `json:"failedCreationCleanup,omitempty"`
+	// DetectDrift configures if the status check of a machine verifies that the NIC and the OSDisk of the virtual machine
+	// still exist and that the cascade delete options and the tags still match the provider spec. Discrepancies, e.g.
+	// caused by manual modifications, are reported as warning events for the machine. Since the verification requires
+	// additional Azure API calls it is disabled by default.
+	DetectDrift bool `json:"detectDrift,omitempty"`
+}
+
+// AzureFailedCreationCleanup configures the cleanup of the resources of a virtual machine whose creation has failed.
+// Retained resources block the deletion of the machine, MCM retries the deletion until they are no longer retained.
+type AzureFailedCreationCleanup struct {
+	// Policy defines when the resources are deleted. Allowed values are "always", "never" and "on-permanent-error".
+	// With "on-permanent-error" the resources are only deleted if the creation failed with an error which does not resolve
+	// by retrying, e.g. an invalid configuration or an exhausted quota. Defaults to "always".
+	Policy string `json:"policy,omitempty"`
+	// RetentionDuration limits how long resources which are not deleted due to the Policy are retained after the creation
+	// has failed, e.g. "24h". Once it has elapsed the resources are deleted. If not set then the resources are retained
+	// until the Policy is changed.
+	RetentionDuration *string `json:"retentionDuration,omitempty"`
+}
+
+// AzureGalleryApplication specifies a Compute Gallery VM application version which is installed on the virtual machine.
+type AzureGalleryApplication struct {
+	// PackageReferenceID is the resource ID of the gallery application version, it has the form
+	// /subscriptions/{subscriptionID}/resourceGroups/{resourceGroupName}/providers/Microsoft.Compute/galleries/{galleryName}/applications/{application}/versions/{version}
+	PackageReferenceID string `json:"packageReferenceID,omitempty"`
+	// Order specifies the order in which the applications are installed.
+	Order *int32 `json:"order,omitempty"`
+	// TreatFailureAsDeploymentFailure specifies if a failure of the application installation fails the VM deployment.
+	TreatFailureAsDeploymentFailure *bool `json:"treatFailureAsDeploymentFailure,omitempty"`
+}
+
+// AzureUserData specifies how the userData property of a virtual machine is populated.
+type AzureUserData struct {
+	// Enabled configures if the userData property of the virtual machine should be populated.
+	Enabled bool `json:"enabled,omitempty"`
+	// SecretKey is the key in the secret passed to Driver methods whose value is used as userData.
+	// If not set then the same user data which is used for customData is used.
+	SecretKey *string `json:"secretKey,omitempty"`
+}
+
+// AzureScheduledEventsProfile specifies scheduled event related configurations of a virtual machine.
+type AzureScheduledEventsProfile struct {
+	// TerminateNotificationProfile specifies the configuration of the terminate scheduled event.
+	TerminateNotificationProfile *AzureTerminateNotificationProfile `json:"terminateNotificationProfile,omitempty"`
+}
+
+// AzureTerminateNotificationProfile specifies the configuration of the terminate scheduled event. If enabled, the virtual machine is
+// notified via the instance metadata service before it is deleted or evicted, which allows the node to be drained gracefully.
+type AzureTerminateNotificationProfile struct {
+	// Enabled specifies whether the terminate scheduled event is enabled.
+	Enabled bool `json:"enabled,omitempty"`
+	// NotBeforeTimeout is the time a virtual machine which is being deleted has to approve the terminate scheduled event before
+	// it is auto approved. It must be specified in ISO 8601 format and must be between 5 (PT5M) and 15 (PT15M) minutes.
+	// If not set then Azure defaults it to PT5M.
+	NotBeforeTimeout *string `json:"notBeforeTimeout,omitempty"`
+}
+
+// AzureAdditionalCapabilities specifies additional capabilities of a virtual machine.
+type AzureAdditionalCapabilities struct {
+	// HibernationEnabled enables or disables the hibernation capability on the virtual machine. Hibernation can only be enabled
+	// at creation time and requires a VM size which supports it.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/hibernate-resume]
+	HibernationEnabled *bool `json:"hibernationEnabled,omitempty"`
+}
+
+// AzureApplicationHealthProfile specifies the probe which is used by the Application Health extension.
+type AzureApplicationHealthProfile struct {
+	// Enabled configures if the Application Health extension should be installed on the virtual machine.
+	Enabled bool `json:"enabled,omitempty"`
+	// Protocol is the protocol used for the probe. Possible values are http, https and tcp.
+	Protocol string `json:"protocol,omitempty"`
+	// Port is the port used for the probe. It is required if protocol is tcp, otherwise it defaults to the port of the protocol.
+	Port *int32 `json:"port,omitempty"`
+	// RequestPath is the path on which the probe request is sent. It is required if protocol is http or https.
+	RequestPath *string `json:"requestPath,omitempty"`
+	// IntervalInSeconds is the interval between two probes. If not set then Azure defaults it to 5 seconds.
+	IntervalInSeconds *int32 `json:"intervalInSeconds,omitempty"`
+	// NumberOfProbes is the number of consecutive probes required to change the health state. If not set then Azure defaults it to 1.
+	NumberOfProbes *int32 `json:"numberOfProbes,omitempty"`
+}
+
+// AzureVMExtension describes a virtual machine extension.
+type AzureVMExtension struct {
+	// Name is the name of the extension resource. It must be unique for a virtual machine.
+	Name string `json:"name"`
+	// Publisher is the name of the extension handler publisher, e.g. Microsoft.Azure.ActiveDirectory.
+	Publisher string `json:"publisher"`
+	// Type is the type of the extension, e.g. AADSSHLoginForLinux.
+	Type string `json:"type"`
+	// TypeHandlerVersion is the version of the extension handler, e.g. 1.0.
+	TypeHandlerVersion string `json:"typeHandlerVersion"`
+	// AutoUpgradeMinorVersion indicates whether the extension should use a newer minor version if one is available at deployment time.
+	AutoUpgradeMinorVersion *bool `json:"autoUpgradeMinorVersion,omitempty"`
+	// Settings are the JSON formatted public settings for the extension.
+	Settings json.RawMessage `json:"settings,omitempty"`
+	// ProtectedSettingsSecretKey is the name of the key in the secret passed to Driver methods whose value contains
+	// the JSON formatted protected settings for the extension. Protected settings are encrypted by Azure and are never
+	// part of the provider spec since they typically contain credentials.
+	ProtectedSettingsSecretKey *string `json:"protectedSettingsSecretKey,omitempty"`
 }
 
 // AzureSecurityProfile specifies the security profile to be used for the virtual machine.
@@ -161,6 +331,15 @@ type AzureStorageProfile struct {
 	// DataDisks contains the information about disks that can be added as data-disks to a VM.
 	// See [https://learn.microsoft.com/en-us/azure/virtual-machines/managed-disks-overview#data-disk]
 	DataDisks []AzureDataDisk `json:"dataDisks,omitempty"`
+	// DataDiskNameTemplate optionally specifies a template for the names of the data disks. It must contain the placeholders
+	// {vmName} and {lun} which are replaced by the name of the VM and the lun of the data disk. It can additionally contain
+	// the placeholder {name} which is replaced by the name of the data disk, e.g. "{vmName}-{name}-{lun}".
+	// If not set then data disks are named <vmName>-<name>-<lun>-data-disk, or <vmName>-<lun>-data-disk if the data disk has no name.
+	DataDiskNameTemplate *string `json:"dataDiskNameTemplate,omitempty"`
+	// DiskControllerType specifies the disk controller type configured for the VM. Possible values are SCSI and NVMe.
+	// If not set then Azure uses SCSI or the default disk controller type of the VM size. NVMe requires a VM size and an image which support it.
+	// See [https://learn.microsoft.com/en-us/azure/virtual-machines/nvme-overview].
+	DiskControllerType *string `json:"diskControllerType,omitempty"`
 }
 
 // AzureImageReference specifies information about the image to use. You can specify information about platform images,
@@ -173,10 +352,37 @@ type AzureImageReference struct {
 	URN *string `json:"urn,omitempty"`
 	// SkipMarketplaceAgreement will prevent the extension from checking the license agreement for marketplace images.
 	SkipMarketplaceAgreement bool `json:"skipMarketplaceAgreement,omitempty"`
+	// DisableMarketplaceAgreementAcceptance prevents the extension from accepting the license agreement of marketplace images on behalf
+	// of the subscription. If the agreement has not been accepted yet then the creation of the machine fails until the operator has accepted it.
+	DisableMarketplaceAgreementAcceptance bool `json:"disableMarketplaceAgreementAcceptance,omitempty"`
 	// CommunityGalleryImageID is the id of the OS image to be used, hosted within an Azure Community Image Gallery.
 	CommunityGalleryImageID *string `json:"communityGalleryImageID,omitempty"`
 	// SharedGalleryImageID is the id of the OS image to be used, hosted within an Azure Shared Image Gallery.
 	SharedGalleryImageID *string `json:"sharedGalleryImageID,omitempty"`
+	// SubscriptionID is the subscription which hosts the managed image or gallery image referenced by ID. It only needs to be set if the image
+	// is hosted in a subscription other than the one the machine is created in and is validated against the subscription contained in ID.
+	SubscriptionID *string `json:"subscriptionID,omitempty"`
+	// TenantID is the tenant which hosts the managed image or gallery image referenced by ID. It only needs to be set if the image is hosted
+	// in a tenant other than the tenant of the credentials. The credentials are then additionally used to acquire a token for this tenant,
+	// which requires that the application is registered as a multi-tenant application and has been granted access to the image.
+	TenantID *string `json:"tenantID,omitempty"`
+	// HyperVGeneration is the Hyper-V generation of the image. Possible values are: [V1, V2].
+	// If set then it is validated against the VM size, and for marketplace images also against the image, before the VM is created.
+	HyperVGeneration *string `json:"hyperVGeneration,omitempty"`
+	// Plan optionally specifies the purchase plan of the image. If set then the plan is not discovered from the marketplace or gallery image,
+	// which saves a round-trip, and images which are derived from marketplace images, e.g. managed images, can declare their plan.
+	// The agreement for the plan is processed in the same way as for a discovered plan.
+	Plan *AzurePurchasePlan `json:"plan,omitempty"`
+}
+
+// AzurePurchasePlan specifies information about the marketplace image used to create the virtual machine.
+type AzurePurchasePlan struct {
+	// Name is the plan ID, which corresponds to the SKU of the marketplace image.
+	Name string `json:"name"`
+	// Product is the product of the image from the marketplace, which corresponds to the offer of the marketplace image.
+	Product string `json:"product"`
+	// Publisher is the publisher of the marketplace image.
+	Publisher string `json:"publisher"`
 }
 
 // AzureOSDisk specifies information about the operating system disk used by the virtual machine.
@@ -184,16 +390,35 @@ type AzureImageReference struct {
 type AzureOSDisk struct {
 	// Name is the name of the OSDisk
 	Name string `json:"name,omitempty"`
+	// NameTemplate optionally specifies a template for the name of the OSDisk. It must contain the placeholder {vmName}
+	// which is replaced by the name of the VM, e.g. "{vmName}-root". If not set then the OSDisk is named <vmName>-os-disk.
+	NameTemplate *string `json:"nameTemplate,omitempty"`
 	// Caching specifies the caching requirements. Possible values are: None, ReadOnly, ReadWrite.
 	Caching string `json:"caching,omitempty"`
 	// ManagedDisk specifies the managed disk parameters.
 	ManagedDisk AzureManagedDiskParameters `json:"managedDisk,omitempty"`
 	// DiskSizeGB is the size of an empty disk in gigabytes.
 	DiskSizeGB int32 `json:"diskSizeGB,omitempty"`
-	// CreateOption Specifies how the virtual machine should be created. Possible values are: [Attach, FromImage].
+	// CreateOption Specifies how the virtual machine should be created. Possible values are: [Attach, FromImage, Restore].
 	// Attach: This value is used when a specialized disk is used to create the virtual machine.
 	// FromImage: This value is used when an image is used to create the virtual machine.
+	// Restore: This value is used when the OSDisk is restored from a snapshot or a disk restore point, see SnapshotID and
+	// RestorePointID. The image reference and the OS profile are not used since the restored disk already contains a provisioned OS.
+	// If not set then it defaults to FromImage.
 	CreateOption string `json:"createOption,omitempty"`
+	// SnapshotID is the resource ID of a snapshot from which the OSDisk is restored. It can only be set if CreateOption is Restore.
+	SnapshotID *string `json:"snapshotID,omitempty"`
+	// RestorePointID is the resource ID of a disk restore point of a VM restore point from which the OSDisk is restored, e.g.
+	// /subscriptions/<id>/resourceGroups/<rg>/providers/Microsoft.Compute/restorePointCollections/<collection>/restorePoints/<restorePoint>/diskRestorePoints/<diskRestorePoint>.
+	// It can only be set if CreateOption is Restore and is mutually exclusive with SnapshotID.
+	RestorePointID *string `json:"restorePointID,omitempty"`
+	// Tier is the performance tier of the disk, e.g. P30. It allows to use a higher performance than the baseline performance
+	// of the disk size and is only supported for Premium SSD storage account types.
+	// See [https://learn.microsoft.com/en-us/azure/virtual-machines/disks-change-performance]
+	Tier *string `json:"tier,omitempty"`
+	// WriteAcceleratorEnabled specifies whether write accelerator should be enabled on the disk. Write accelerator is only
+	// supported for Premium storage on VM sizes which support it (M-series) and requires caching to be None or ReadOnly.
+	WriteAcceleratorEnabled *bool `json:"writeAcceleratorEnabled,omitempty"`
 }
 
 // AzureDataDisk specifies information about the data disk used by the virtual machine.
@@ -203,21 +428,43 @@ type AzureDataDisk struct {
 	// Lun specifies the logical unit number of the data disk. This value is used to identify data disks within the VM and
 	// therefore must be unique for each data disk attached to a VM.
 	Lun int32 `json:"lun"`
-	// Caching specifies the caching requirements. Possible values are: None, ReadOnly, ReadWrite.
+	// Caching specifies the caching requirements. Possible values are: None, ReadOnly, ReadWrite. If not set then it defaults to None.
 	Caching string `json:"caching,omitempty"`
-	// StorageAccountType is the storage account type for a managed disk.
+	// StorageAccountType is the storage account type for a managed disk, e.g. Premium_LRS. The zone-redundant storage account
+	// types Premium_ZRS and StandardSSD_ZRS replicate the disk across the zones of the region.
+	// See [https://learn.microsoft.com/en-us/azure/virtual-machines/disks-redundancy#zone-redundant-storage-for-managed-disks]
 	StorageAccountType string `json:"storageAccountType,omitempty"`
 	// DiskSizeGB is the size of an empty disk in gigabytes.
 	DiskSizeGB int32 `json:"diskSizeGB,omitempty"`
 	// ImageRef optionally specifies an image source
 	ImageRef *AzureImageReference `json:"imageRef,omitempty"`
+	// SnapshotID optionally specifies the resource ID of a snapshot from which the disk is copied, so that the disk starts pre-populated.
+	// This field is mutually exclusive with ImageRef.
+	SnapshotID *string `json:"snapshotID,omitempty"`
+	// MaxShares is the maximum number of VMs that can attach to the disk at the same time. A value greater than one indicates
+	// a shared disk which requires caching to be None and a storage account type which supports shared disks.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/disks-shared]
+	MaxShares *int32 `json:"maxShares,omitempty"`
+	// DeleteOption specifies what happens to the disk when the VM is deleted. Possible values are Delete and Detach.
+	// Disks with Detach survive the deletion of the machine and are neither deleted nor considered as orphans.
+	// If not set then it defaults to Delete.
+	DeleteOption *string `json:"deleteOption,omitempty"`
+	// Tier is the performance tier of the disk, e.g. P30. It allows to use a higher performance than the baseline performance
+	// of the disk size and is only supported for Premium SSD storage account types.
+	// See [https://learn.microsoft.com/en-us/azure/virtual-machines/disks-change-performance]
+	Tier *string `json:"tier,omitempty"`
+	// WriteAcceleratorEnabled specifies whether write accelerator should be enabled on the disk. Write accelerator is only
+	// supported for Premium storage on VM sizes which support it (M-series) and requires caching to be None or ReadOnly.
+	WriteAcceleratorEnabled *bool `json:"writeAcceleratorEnabled,omitempty"`
 }
 
 // AzureManagedDiskParameters is the parameters of a managed disk.
 type AzureManagedDiskParameters struct {
 	// ID is a unique resource ID.
 	ID string `json:"id,omitempty"`
-	// StorageAccountType is the storage account type for a managed disk.
+	// StorageAccountType is the storage account type for a managed disk, e.g. Premium_LRS. The zone-redundant storage account
+	// types Premium_ZRS and StandardSSD_ZRS replicate the disk across the zones of the region.
+	// See [https://learn.microsoft.com/en-us/azure/virtual-machines/disks-redundancy#zone-redundant-storage-for-managed-disks]
 	StorageAccountType string `json:"storageAccountType,omitempty"`
 	// SecurityProfile are the parameters of the encryption of the OS disk.
 	SecurityProfile *AzureDiskSecurityProfile `json:"securityProfile,omitempty"`
@@ -244,7 +491,38 @@ type AzureOSProfile struct {
 	// as a file on the Virtual Machine. See [https://azure.microsoft.com/en-us/blog/custom-data-and-cloud-init-on-windows-azure/].
 	CustomData string `json:"customData,omitempty"`
 	// LinuxConfiguration specifies the linux OS settings on the VM.
+	// This is the default OS configuration and is used unless WindowsConfiguration is set.
 	LinuxConfiguration AzureLinuxConfiguration `json:"linuxConfiguration,omitempty"`
+	// WindowsConfiguration specifies the Windows OS settings on the VM. This field is mutually exclusive with LinuxConfiguration.
+	// The password for the administrator account is not part of the provider spec, it is read from the secret passed to Driver methods.
+	WindowsConfiguration *AzureWindowsConfiguration `json:"windowsConfiguration,omitempty"`
+	// PatchSettings specifies settings related to VM guest patching. They are applied to the linux or windows configuration,
+	// whichever is used for the VM.
+	PatchSettings *AzurePatchSettings `json:"patchSettings,omitempty"`
+}
+
+// AzurePatchSettings specifies settings related to VM guest patching.
+// For additional information see: [https://learn.microsoft.com/en-us/azure/virtual-machines/automatic-vm-guest-patching]
+type AzurePatchSettings struct {
+	// PatchMode specifies the mode of VM guest patching.
+	// Possible values for linux are: [AutomaticByPlatform, ImageDefault].
+	// Possible values for windows are: [AutomaticByOS, AutomaticByPlatform, Manual].
+	PatchMode *string `json:"patchMode,omitempty"`
+	// AssessmentMode specifies the mode of VM guest patch assessment. Possible values are: [AutomaticByPlatform, ImageDefault].
+	AssessmentMode *string `json:"assessmentMode,omitempty"`
+	// BypassPlatformSafetyChecks enables customers to schedule patching without accidental upgrades.
+	// It can only be set if PatchMode is AutomaticByPlatform.
+	BypassPlatformSafetyChecks *bool `json:"bypassPlatformSafetyChecks,omitempty"`
+}
+
+// AzureWindowsConfiguration specifies the Windows operating system settings on the virtual machine.
+type AzureWindowsConfiguration struct {
+	// EnableAutomaticUpdates indicates whether Automatic Updates is enabled for the Windows virtual machine.
+	// If not set then Azure defaults it to true.
+	EnableAutomaticUpdates *bool `json:"enableAutomaticUpdates,omitempty"`
+	// TimeZone specifies the time zone of the virtual machine, e.g. "Pacific Standard Time".
+	// For possible values see: [https://learn.microsoft.com/en-us/dotnet/api/system.timezoneinfo.getsystemtimezones]
+	TimeZone *string `json:"timeZone,omitempty"`
 }
 
 // AzureLinuxConfiguration specifies the Linux operating system settings on the virtual machine.
@@ -260,6 +538,10 @@ type AzureLinuxConfiguration struct {
 type AzureSSHConfiguration struct {
 	// PublicKeys specifies a list of SSH public keys used to authenticate with linux based VMs.
 	PublicKeys AzureSSHPublicKey `json:"publicKeys,omitempty"`
+	// RequirePublicKey specifies if an SSH public key must be provided via PublicKeys. If it is not set and no public key
+	// is provided then a dummy public key is generated, since Azure requires an SSH public key for Linux VMs with
+	// password authentication disabled.
+	RequirePublicKey bool `json:"requirePublicKey,omitempty"`
 }
 
 // AzureSSHPublicKey contains information about SSH certificate public key and the path on the Linux VM where the public
@@ -270,6 +552,9 @@ type AzureSSHPublicKey struct {
 	// KeyData is the SSH public key certificate used to authenticate with the VM through ssh.
 	// The key needs to be at least 2048-bit and in ssh-rsa format.
 	KeyData string `json:"keyData,omitempty"`
+	// SecretKey is the key in the secret passed to Driver methods whose value is used as SSH public key, e.g. "sshPublicKey".
+	// This allows to rotate the key without creating a new MachineClass. This field is mutually exclusive with KeyData.
+	SecretKey *string `json:"secretKey,omitempty"`
 }
 
 // AzureNetworkProfile specifies the network interfaces of the virtual machine.
@@ -278,6 +563,19 @@ type AzureNetworkProfile struct {
 	NetworkInterfaces AzureNetworkInterfaceReference `json:"networkInterfaces,omitempty"`
 	// AcceleratedNetworking specifies whether the network interface is accelerated networking-enabled.
 	AcceleratedNetworking *bool `json:"acceleratedNetworking,omitempty"`
+	// DNSServers is an optional list of IP addresses of DNS servers that should be configured on the network interface.
+	// If not set then the DNS servers configured for the virtual network are used.
+	DNSServers []string `json:"dnsServers,omitempty"`
+	// IPForwarding specifies whether IP forwarding is enabled on the network interface. If not set then it defaults to true.
+	IPForwarding *bool `json:"ipForwarding,omitempty"`
+	// NicType specifies the type of the network interface. Possible values are Standard and Elastic. If not set then it defaults to Standard.
+	NicType *string `json:"nicType,omitempty"`
+	// AuxiliaryMode specifies the auxiliary mode of the network interface which enables Accelerated Connections.
+	// Possible values are None, AcceleratedConnections, Floating and MaxConnections. It requires AuxiliarySKU and accelerated networking.
+	// For additional information see: [https://learn.microsoft.com/en-us/azure/networking/nva-accelerated-connections]
+	AuxiliaryMode *string `json:"auxiliaryMode,omitempty"`
+	// AuxiliarySKU specifies the auxiliary SKU of the network interface. Possible values are None, A1, A2, A4 and A8. It requires AuxiliaryMode.
+	AuxiliarySKU *string `json:"auxiliarySku,omitempty"`
 }
 
 // AzureNetworkInterfaceReference describes a network interface reference.
@@ -306,17 +604,51 @@ type AzureSubnetInfo struct {
 	VnetResourceGroup *string `json:"vnetResourceGroup,omitempty"`
 	// SubnetName is the name of the subnet which is unique within a resource group.
 	SubnetName string `json:"subnetName,omitempty"`
+	// SubscriptionID is the ID of the subscription of the virtual network. This is optional. If it is not specified then
+	// the subscription of the credentials is used instead. It allows to use a virtual network of another subscription, e.g.
+	// a spoke network in a hub-spoke topology.
+	SubscriptionID *string `json:"subscriptionID,omitempty"`
+	// SubnetID is the full ARM resource ID of the subnet. It is an alternative to VnetName, VnetResourceGroup, SubnetName
+	// and SubscriptionID and is mutually exclusive with them.
+	SubnetID *string `json:"subnetID,omitempty"`
 }
 
 // AzureDiagnosticsProfile specifies boot diagnostic options
 type AzureDiagnosticsProfile struct {
 	// Enabled configures boot diagnostics to be stored or not
 	Enabled bool `json:"enabled,omitempty"`
-	// StorageURI is the URI of the storage account to use for storing console output and screenshot.
-	// If not specified azure managed storage will be used.
+	// StorageURI is the URI of the blob endpoint of the storage account to use for storing console output and screenshot,
+	// e.g. https://<account>.blob.core.windows.net/. This allows to use a storage account which complies with the storage
+	// governance policies of the subscription. If not specified azure managed storage will be used.
 	StorageURI *string `json:"storageURI,omitempty"`
 }
 
+// The supported protocols for probes of the Application Health extension.
+const (
+	// ApplicationHealthExtensionName is the name of the Application Health extension resource which is installed if it is enabled
+	// via AzureApplicationHealthProfile. This name cannot be used for extensions defined in AzureVirtualMachineProperties.Extensions.
+	ApplicationHealthExtensionName string = "ApplicationHealth"
+
+	ApplicationHealthProtocolHTTP  string = "http"
+	ApplicationHealthProtocolHTTPS string = "https"
+	ApplicationHealthProtocolTCP   string = "tcp"
+)
+
+// OSDiskCreateOptionRestore is the AzureOSDisk.CreateOption which restores the OSDisk from a snapshot or a disk restore point.
+// It is not an Azure create option but is translated into creating the disk from the source and attaching it to the VM.
+const OSDiskCreateOptionRestore string = "Restore"
+
+// The supported values for AzureFailedCreationCleanup.Policy.
+const (
+	// FailedCreationCleanupPolicyAlways deletes the resources of a virtual machine whose creation has failed.
+	FailedCreationCleanupPolicyAlways string = "always"
+	// FailedCreationCleanupPolicyNever retains the resources of a virtual machine whose creation has failed.
+	FailedCreationCleanupPolicyNever string = "never"
+	// FailedCreationCleanupPolicyOnPermanentError only deletes the resources of a virtual machine whose creation has
+	// failed with an error which does not resolve by retrying.
+	FailedCreationCleanupPolicyOnPermanentError string = "on-permanent-error"
+)
+
 // The (currently) supported values for the names of clouds to use in the CloudConfiguration.
 const (
 	CloudNameChina  string = "AzureChina"
@@ -324,9 +656,18 @@ const (
 	CloudNamePublic string = "AzurePublic"
 )
 
+// Alternative names of clouds as used by the Azure CLI (`az cloud list`). They select the same cloud as CloudNamePublic,
+// CloudNameChina and CloudNameGov respectively.
+const (
+	CloudNameAzureCloud        string = "AzureCloud"
+	CloudNameAzureChinaCloud   string = "AzureChinaCloud"
+	CloudNameAzureUSGovernment string = "AzureUSGovernment"
+)
+
 // CloudConfiguration contains detailed config for the cloud to connect to. Currently we only support selection of well-
 // known Azure-instances by name, but this could be extended in future to support private clouds.
 type CloudConfiguration struct {
-	// Name is the name of the cloud to connect to, e.g. "AzurePublic" or "AzureChina".
+	// Name is the name of the cloud to connect to, e.g. "AzurePublic" or "AzureChina". The names used by the Azure CLI,
+	// e.g. "AzureChinaCloud" or "AzureUSGovernment", are accepted as well.
 	Name string `json:"name"`
 }
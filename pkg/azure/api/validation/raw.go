@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api/defaulting"
+)
+
+// ValidateRawProviderSpec validates the raw JSON of an api.AzureProviderSpec, e.g. v1alpha1.MachineClass.ProviderSpec.Raw,
+// exactly like the machine-controller does before it processes a request. It is meant for external tools and webhooks,
+// which thereby do not depend on the Go types of the api package of a specific version.
+func ValidateRawProviderSpec(raw []byte) field.ErrorList {
+	_, allErrs, err := DecodeAndValidateRawProviderSpec(raw)
+	if err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath("providerSpec"), nil, fmt.Sprintf("cannot be decoded: %v", err))}
+	}
+	return allErrs
+}
+
+// DecodeAndValidateRawProviderSpec decodes the raw JSON into an api.AzureProviderSpec, populates the replacements of
+// deprecated fields, sets the defaults of unset fields and validates the result. The field errors of the validation
+// are returned separately, an error is only returned if the raw JSON cannot be decoded.
+func DecodeAndValidateRawProviderSpec(raw []byte) (api.AzureProviderSpec, field.ErrorList, error) {
+	var providerSpec api.AzureProviderSpec
+	if err := json.Unmarshal(raw, &providerSpec); err != nil {
+		return api.AzureProviderSpec{}, nil, err
+	}
+
+	// api.AzureVirtualMachineProperties.MachineSet has been marked as deprecated.
+	// If AzureProviderSpec still has MachineSet populated then also copy equivalent values
+	// to the VirtualMachineScaleSet and AvailabilitySet. We do the validation for fields in MachineSet
+	// here separately so that we can use the validated values to populate VirtualMachineScaleSet/AvailabilitySet.
+	// TODO: This complete `if` condition should be removed once consumers no longer use MachineSetConfig.
+	if providerSpec.Properties.MachineSet != nil {
+		if allErrs := ValidateMachineSetConfig(providerSpec.Properties.MachineSet); len(allErrs) > 0 {
+			return api.AzureProviderSpec{}, allErrs, nil
+		}
+		if providerSpec.Properties.VirtualMachineScaleSet == nil && providerSpec.Properties.MachineSet.Kind == api.MachineSetKindVMO {
+			providerSpec.Properties.VirtualMachineScaleSet = &api.AzureSubResource{ID: providerSpec.Properties.MachineSet.ID}
+		}
+		if providerSpec.Properties.AvailabilitySet == nil && providerSpec.Properties.MachineSet.Kind == api.MachineSetKindAvailabilitySet {
+			providerSpec.Properties.AvailabilitySet = &api.AzureSubResource{ID: providerSpec.Properties.MachineSet.ID}
+		}
+	}
+
+	if appliedDefaults := defaulting.SetDefaultsProviderSpec(&providerSpec); len(appliedDefaults) > 0 {
+		klog.V(4).InfoS("Applied defaults to providerSpec", "defaults", appliedDefaults)
+	}
+
+	if allErrs := ValidateProviderSpec(providerSpec); len(allErrs) > 0 {
+		return api.AzureProviderSpec{}, allErrs, nil
+	}
+	return providerSpec, nil, nil
+}
@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+)
+
+func TestValidateRawProviderSpec(t *testing.T) {
+	g := NewWithT(t)
+
+	providerSpec := testhelp.NewProviderSpecBuilder("test-rg", "test-shoot-ns", "test-worker-pool-0").WithDefaultValues().Build()
+	// createOption is defaulted before the provider spec is validated
+	providerSpec.Properties.StorageProfile.OsDisk.CreateOption = ""
+	raw, err := json.Marshal(providerSpec)
+	g.Expect(err).To(BeNil())
+	g.Expect(ValidateRawProviderSpec(raw)).To(BeEmpty())
+
+	providerSpec.Location = ""
+	raw, err = json.Marshal(providerSpec)
+	g.Expect(err).To(BeNil())
+	allErrs := ValidateRawProviderSpec(raw)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("providerSpec.location"))
+
+	allErrs = ValidateRawProviderSpec([]byte(`{"location": 1}`))
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("providerSpec"))
+}
+
+func TestDecodeAndValidateRawProviderSpecSetsDefaults(t *testing.T) {
+	g := NewWithT(t)
+
+	providerSpec := testhelp.NewProviderSpecBuilder("test-rg", "test-shoot-ns", "test-worker-pool-0").WithDefaultValues().Build()
+	providerSpec.Properties.NetworkProfile.NicType = nil
+	raw, err := json.Marshal(providerSpec)
+	g.Expect(err).To(BeNil())
+
+	decoded, allErrs, err := DecodeAndValidateRawProviderSpec(raw)
+	g.Expect(err).To(BeNil())
+	g.Expect(allErrs).To(BeEmpty())
+	g.Expect(decoded.Properties.NetworkProfile.NicType).ToNot(BeNil())
+	g.Expect(*decoded.Properties.NetworkProfile.NicType).To(Equal("Standard"))
+	g.Expect(decoded.Location).To(Equal(providerSpec.Location))
+	g.Expect(decoded).ToNot(Equal(api.AzureProviderSpec{}))
+}
@@ -8,14 +8,23 @@ SPDX-License-Identifier: Apache-2.0
 package validation
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
 	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"golang.org/x/crypto/ssh"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -24,7 +33,33 @@ import (
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
 )
 
-const providerAzure = "Azure"
+const (
+	providerAzure = "Azure"
+	// minTerminateNotBeforeTimeout and maxTerminateNotBeforeTimeout are the bounds which Azure allows for the not before timeout of the terminate scheduled event.
+	minTerminateNotBeforeTimeout = 5 * time.Minute
+	maxTerminateNotBeforeTimeout = 15 * time.Minute
+)
+
+// galleryApplicationVersionIDRegex matches resource IDs of Compute Gallery application versions.
+var galleryApplicationVersionIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/galleries/[^/]+/applications/[^/]+/versions/[^/]+$`)
+
+// snapshotIDRegex matches resource IDs of snapshots.
+var snapshotIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/snapshots/[^/]+$`)
+
+// diskRestorePointIDRegex matches resource IDs of disk restore points of VM restore points.
+var diskRestorePointIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/restorePointCollections/[^/]+/restorePoints/[^/]+/diskRestorePoints/[^/]+$`)
+
+// subnetResourceType is the ARM resource type of subnets.
+const subnetResourceType = "Microsoft.Network/virtualNetworks/subnets"
+
+// diskNameTemplateRegex matches the characters which are allowed in a disk name template besides the VM name placeholder.
+var diskNameTemplateRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]*$`)
+
+// storageAccountBlobEndpointHostRegex matches the host of the blob endpoint of a storage account in any Azure cloud.
+var storageAccountBlobEndpointHostRegex = regexp.MustCompile(`^[a-z0-9]{3,24}\.blob\.[a-z0-9.-]+$`)
+
+// iso8601DurationRegex matches ISO 8601 durations which only consist of hours, minutes and seconds, e.g. PT5M or PT1H30S.
+var iso8601DurationRegex = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
 
 // ValidateMachineClassProvider checks if the Provider in MachineClass is Azure.
 // If it is not then it will return an error indicating that this provider implementation cannot fulfill the request.
@@ -47,6 +82,7 @@ func ValidateProviderSpec(spec api.AzureProviderSpec) field.ErrorList {
 	if utils.IsEmptyString(spec.ResourceGroup) {
 		allErrs = append(allErrs, field.Required(specPath.Child("resourceGroup"), "must provide a resourceGroup"))
 	}
+	allErrs = append(allErrs, validateAdditionalResourceGroups(spec.AdditionalResourceGroups, spec.ResourceGroup, specPath.Child("additionalResourceGroups"))...)
 
 	allErrs = append(allErrs, validateSubnetInfo(spec.SubnetInfo, specPath.Child("subnetInfo"))...)
 	allErrs = append(allErrs, validateProperties(spec.Properties, specPath.Child("properties"))...)
@@ -60,21 +96,23 @@ func ValidateProviderSpec(spec api.AzureProviderSpec) field.ErrorList {
 func ValidateProviderSecret(secret *corev1.Secret) field.ErrorList {
 	var allErrs field.ErrorList
 	secretDataPath := field.NewPath("data")
-	if utils.IsEmptyString(string(secret.Data[api.ClientID])) && utils.IsEmptyString(string(secret.Data[api.AzureClientID])) && utils.IsEmptyString(string(secret.Data[api.AzureAlternativeClientID])) {
-		allErrs = append(allErrs, field.Required(secretDataPath.Child("clientID"), "must provide clientID"))
-	}
 
 	var (
 		emptyClientSecret = utils.IsEmptyString(string(secret.Data[api.ClientSecret])) &&
 			utils.IsEmptyString(string(secret.Data[api.AzureClientSecret])) &&
 			utils.IsEmptyString(string(secret.Data[api.AzureAlternativeClientSecret]))
-		emptyWorkloadIdentityTokenFile = utils.IsEmptyString(string(secret.Data[api.WorkloadIdentityTokenFile]))
+		emptyWorkloadIdentityTokenFile = utils.IsEmptyString(string(secret.Data[api.WorkloadIdentityTokenFile])) &&
+			utils.IsEmptyString(string(secret.Data[api.AzureFederatedTokenFile]))
 	)
 
 	if !emptyClientSecret && !emptyWorkloadIdentityTokenFile {
 		allErrs = append(allErrs, field.Required(secretDataPath.Child("clientSecret"), "clientSecret is mutually exclusive with workloadIdentityTokenFile"))
-	} else if emptyClientSecret && emptyWorkloadIdentityTokenFile {
-		allErrs = append(allErrs, field.Required(secretDataPath.Child("clientSecret"), "must provide clientSecret or workloadIdentityTokenFile"))
+	}
+
+	// clientID is optional if neither clientSecret nor workloadIdentityTokenFile is set, as then a managed identity is used
+	// for authentication and the clientID only selects a user-assigned identity.
+	if (!emptyClientSecret || !emptyWorkloadIdentityTokenFile) && utils.IsEmptyString(string(secret.Data[api.ClientID])) && utils.IsEmptyString(string(secret.Data[api.AzureClientID])) && utils.IsEmptyString(string(secret.Data[api.AzureAlternativeClientID])) {
+		allErrs = append(allErrs, field.Required(secretDataPath.Child("clientID"), "must provide clientID"))
 	}
 
 	if utils.IsEmptyString(string(secret.Data[api.SubscriptionID])) && utils.IsEmptyString(string(secret.Data[api.AzureSubscriptionID])) && utils.IsEmptyString(string(secret.Data[api.AzureAlternativeSubscriptionID])) {
@@ -89,6 +127,43 @@ func ValidateProviderSecret(secret *corev1.Secret) field.ErrorList {
 		allErrs = append(allErrs, field.Required(secretDataPath.Child("userData"), "must provide userData"))
 	}
 
+	if azureCloud, ok := secret.Data[api.AzureCloud]; ok {
+		allErrs = append(allErrs, validateCloudName(strings.TrimSpace(string(azureCloud)), secretDataPath.Child(api.AzureCloud))...)
+	}
+
+	return allErrs
+}
+
+// ValidateProviderSecretForCreate validates the secret data which is additionally required to create a machine using the given api.AzureProviderSpec.
+func ValidateProviderSecretForCreate(secret *corev1.Secret, spec api.AzureProviderSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	secretDataPath := field.NewPath("data")
+	if spec.Properties.OsProfile.WindowsConfiguration != nil && utils.IsEmptyString(string(secret.Data[api.AdminPassword])) {
+		allErrs = append(allErrs, field.Required(secretDataPath.Child(api.AdminPassword), "must provide adminPassword for Windows VMs"))
+	}
+	for _, extension := range spec.Properties.Extensions {
+		if extension.ProtectedSettingsSecretKey == nil {
+			continue
+		}
+		secretKey := *extension.ProtectedSettingsSecretKey
+		if protectedSettings, ok := secret.Data[secretKey]; !ok {
+			allErrs = append(allErrs, field.Required(secretDataPath.Child(secretKey), fmt.Sprintf("must provide protected settings for extension %s", extension.Name)))
+		} else if !json.Valid(protectedSettings) {
+			allErrs = append(allErrs, field.Invalid(secretDataPath.Child(secretKey), "<redacted>", fmt.Sprintf("protected settings for extension %s must be valid JSON", extension.Name)))
+		}
+	}
+	if userData := spec.Properties.UserData; userData != nil && userData.Enabled && userData.SecretKey != nil {
+		if utils.IsEmptyString(string(secret.Data[*userData.SecretKey])) {
+			allErrs = append(allErrs, field.Required(secretDataPath.Child(*userData.SecretKey), "must provide userData for the configured secret key"))
+		}
+	}
+	if secretKey := spec.Properties.OsProfile.LinuxConfiguration.SSH.PublicKeys.SecretKey; spec.Properties.OsProfile.WindowsConfiguration == nil && secretKey != nil {
+		if publicKey := secret.Data[*secretKey]; utils.IsEmptyString(string(publicKey)) {
+			allErrs = append(allErrs, field.Required(secretDataPath.Child(*secretKey), "must provide SSH public key for the configured secret key"))
+		} else if _, _, _, _, err := ssh.ParseAuthorizedKey(publicKey); err != nil {
+			allErrs = append(allErrs, field.Invalid(secretDataPath.Child(*secretKey), "<redacted>", fmt.Sprintf("SSH public key must be in authorized_keys format: %v", err)))
+		}
+	}
 	return allErrs
 }
 
@@ -108,6 +183,19 @@ func ValidateMachineSetConfig(machineSetConfig *api.AzureMachineSetConfig) field
 func validateSubnetInfo(subnetInfo api.AzureSubnetInfo, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 
+	if subnetInfo.SubnetID != nil {
+		if resourceID, err := arm.ParseResourceID(*subnetInfo.SubnetID); err != nil || !strings.EqualFold(resourceID.ResourceType.String(), subnetResourceType) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("subnetID"), *subnetInfo.SubnetID, fmt.Sprintf("must be the resource ID of a subnet of type %s", subnetResourceType)))
+		}
+		if !utils.IsEmptyString(subnetInfo.VnetName) || !utils.IsEmptyString(subnetInfo.SubnetName) || subnetInfo.VnetResourceGroup != nil || subnetInfo.SubscriptionID != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("subnetID"), "subnetID is mutually exclusive with vnetName, vnetResourceGroup, subnetName and subscriptionID"))
+		}
+		return allErrs
+	}
+
+	if subnetInfo.SubscriptionID != nil && utils.IsEmptyString(*subnetInfo.SubscriptionID) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("subscriptionID"), *subnetInfo.SubscriptionID, "must not be empty if specified"))
+	}
 	if utils.IsEmptyString(subnetInfo.VnetName) {
 		allErrs = append(allErrs, field.Required(fldPath.Child("vnetName"), "must provide vnetName"))
 	}
@@ -126,9 +214,152 @@ func validateProperties(properties api.AzureVirtualMachineProperties, fldPath *f
 	allErrs = append(allErrs, validateStorageProfile(properties.StorageProfile, fldPath.Child("storageProfile"))...)
 	// validate OSProfile
 	allErrs = append(allErrs, validateOSProfile(properties.OsProfile, fldPath.Child("osProfile"))...)
+	// validate NetworkProfile
+	allErrs = append(allErrs, validateNetworkProfile(properties.NetworkProfile, fldPath.Child("networkProfile"))...)
 	// validate availability set and vmss
 	allErrs = append(allErrs, validateAvailabilityAndScalingConfig(properties, fldPath)...)
 	allErrs = append(allErrs, validateSecurityProfile(properties.SecurityProfile, fldPath.Child("securityProfile"))...)
+	allErrs = append(allErrs, validateHyperVGenerationForSecurityType(properties, fldPath)...)
+	if properties.LicenseType != nil && utils.IsEmptyString(*properties.LicenseType) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("licenseType"), *properties.LicenseType, "licenseType must not be empty when set"))
+	}
+	allErrs = append(allErrs, validateExtensions(properties.Extensions, fldPath.Child("extensions"))...)
+	allErrs = append(allErrs, validateApplicationHealthProfile(properties.ApplicationHealthProfile, properties.Extensions, fldPath.Child("applicationHealthProfile"))...)
+	allErrs = append(allErrs, validateScheduledEventsProfile(properties.ScheduledEventsProfile, fldPath.Child("scheduledEventsProfile"))...)
+	allErrs = append(allErrs, validateDiagnosticsProfile(properties.DiagnosticsProfile, fldPath.Child("diagnosticsProfile"))...)
+	allErrs = append(allErrs, validateGalleryApplications(properties.GalleryApplications, fldPath.Child("galleryApplications"))...)
+	allErrs = append(allErrs, validateFailedCreationCleanup(properties.FailedCreationCleanup, fldPath.Child("failedCreationCleanup"))...)
+	if userData := properties.UserData; userData != nil && userData.SecretKey != nil && utils.IsEmptyString(*userData.SecretKey) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("userData", "secretKey"), *userData.SecretKey, "secretKey must not be empty when set"))
+	}
+	return allErrs
+}
+
+func validateGalleryApplications(galleryApplications []api.AzureGalleryApplication, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	packageReferenceIDs := sets.New[string]()
+	for i, galleryApplication := range galleryApplications {
+		idxPath := fldPath.Index(i)
+		packageReferenceID := galleryApplication.PackageReferenceID
+		if utils.IsEmptyString(packageReferenceID) {
+			allErrs = append(allErrs, field.Required(idxPath.Child("packageReferenceID"), "packageReferenceID must be provided"))
+			continue
+		}
+		if !galleryApplicationVersionIDRegex.MatchString(packageReferenceID) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("packageReferenceID"), packageReferenceID, "packageReferenceID must be the resource ID of a gallery application version"))
+		}
+		if packageReferenceIDs.Has(strings.ToLower(packageReferenceID)) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("packageReferenceID"), packageReferenceID))
+		}
+		packageReferenceIDs.Insert(strings.ToLower(packageReferenceID))
+		if galleryApplication.Order != nil && *galleryApplication.Order < 0 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("order"), *galleryApplication.Order, "order must not be negative"))
+		}
+	}
+	return allErrs
+}
+
+// validateDiagnosticsProfile validates that a custom storage URI is only configured for enabled boot diagnostics and
+// that it points to the blob endpoint of a storage account.
+func validateDiagnosticsProfile(diagnosticsProfile *api.AzureDiagnosticsProfile, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if diagnosticsProfile == nil || diagnosticsProfile.StorageURI == nil {
+		return allErrs
+	}
+	storageURIPath := fldPath.Child("storageURI")
+	if !diagnosticsProfile.Enabled {
+		allErrs = append(allErrs, field.Forbidden(storageURIPath, "storageURI can only be set if boot diagnostics are enabled"))
+	}
+	storageURI, err := url.Parse(*diagnosticsProfile.StorageURI)
+	if err != nil || storageURI.Scheme != "https" || !storageAccountBlobEndpointHostRegex.MatchString(storageURI.Host) {
+		allErrs = append(allErrs, field.Invalid(storageURIPath, *diagnosticsProfile.StorageURI, "storageURI must be the https blob endpoint of a storage account, e.g. https://<account>.blob.core.windows.net/"))
+	}
+	return allErrs
+}
+
+func validateFailedCreationCleanup(failedCreationCleanup *api.AzureFailedCreationCleanup, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if failedCreationCleanup == nil {
+		return allErrs
+	}
+	supportedPolicies := []string{api.FailedCreationCleanupPolicyAlways, api.FailedCreationCleanupPolicyNever, api.FailedCreationCleanupPolicyOnPermanentError}
+	if policy := failedCreationCleanup.Policy; policy != "" && !slices.Contains(supportedPolicies, policy) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("policy"), policy, supportedPolicies))
+	}
+	if retentionDuration := failedCreationCleanup.RetentionDuration; retentionDuration != nil {
+		duration, err := time.ParseDuration(*retentionDuration)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("retentionDuration"), *retentionDuration, fmt.Sprintf("retentionDuration must be a duration, e.g. 24h: %v", err)))
+		} else if duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("retentionDuration"), *retentionDuration, "retentionDuration must be positive"))
+		}
+	}
+	return allErrs
+}
+
+func validateScheduledEventsProfile(scheduledEventsProfile *api.AzureScheduledEventsProfile, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if scheduledEventsProfile == nil || scheduledEventsProfile.TerminateNotificationProfile == nil {
+		return allErrs
+	}
+	notBeforeTimeout := scheduledEventsProfile.TerminateNotificationProfile.NotBeforeTimeout
+	if notBeforeTimeout == nil {
+		return allErrs
+	}
+	timeoutFldPath := fldPath.Child("terminateNotificationProfile", "notBeforeTimeout")
+	timeout, ok := parseISO8601Duration(*notBeforeTimeout)
+	if !ok {
+		allErrs = append(allErrs, field.Invalid(timeoutFldPath, *notBeforeTimeout, "notBeforeTimeout must be an ISO 8601 duration, e.g. PT5M"))
+		return allErrs
+	}
+	if timeout < minTerminateNotBeforeTimeout || timeout > maxTerminateNotBeforeTimeout {
+		allErrs = append(allErrs, field.Invalid(timeoutFldPath, *notBeforeTimeout, fmt.Sprintf("notBeforeTimeout must be between %s and %s", minTerminateNotBeforeTimeout, maxTerminateNotBeforeTimeout)))
+	}
+	return allErrs
+}
+
+// parseISO8601Duration parses an ISO 8601 duration which only consists of hours, minutes and seconds.
+// The second return value is false if the duration cannot be parsed.
+func parseISO8601Duration(value string) (time.Duration, bool) {
+	matches := iso8601DurationRegex.FindStringSubmatch(value)
+	if matches == nil || value == "PT" {
+		return 0, false
+	}
+	var duration time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		if matches[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(matches[i+1])
+		if err != nil {
+			return 0, false
+		}
+		duration += time.Duration(n) * unit
+	}
+	return duration, true
+}
+
+func validateAdditionalResourceGroups(additionalResourceGroups []string, resourceGroup string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := sets.New[string]()
+
+	for i, rg := range additionalResourceGroups {
+		idxPath := fldPath.Index(i)
+		if utils.IsEmptyString(rg) {
+			allErrs = append(allErrs, field.Required(idxPath, "must provide a non-empty resource group"))
+			continue
+		}
+		if strings.EqualFold(rg, resourceGroup) {
+			allErrs = append(allErrs, field.Invalid(idxPath, rg, "must not be the same as resourceGroup"))
+			continue
+		}
+		if seen.Has(strings.ToLower(rg)) {
+			allErrs = append(allErrs, field.Duplicate(idxPath, rg))
+			continue
+		}
+		seen.Insert(strings.ToLower(rg))
+	}
+
 	return allErrs
 }
 
@@ -139,10 +370,15 @@ func validateCloudConfiguration(cloudConfiguration *api.CloudConfiguration, fldP
 		return allErrs
 	}
 
-	knownCloudInstances := []string{api.CloudNamePublic, api.CloudNameChina, api.CloudNameGov}
+	return validateCloudName(cloudConfiguration.Name, fldPath.Child("name"))
+}
+
+func validateCloudName(cloudName string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	knownCloudInstances := []string{api.CloudNamePublic, api.CloudNameChina, api.CloudNameGov, api.CloudNameAzureCloud, api.CloudNameAzureChinaCloud, api.CloudNameAzureUSGovernment}
 
-	if cloudName := cloudConfiguration.Name; !slices.Contains(knownCloudInstances, cloudName) {
-		allErrs = append(allErrs, field.NotSupported(fldPath.Child("name"), cloudName, knownCloudInstances))
+	if !slices.Contains(knownCloudInstances, cloudName) {
+		allErrs = append(allErrs, field.NotSupported(fldPath, cloudName, knownCloudInstances))
 	}
 
 	return allErrs
@@ -164,6 +400,20 @@ func validateSecurityProfile(securityProfile *api.AzureSecurityProfile, fldPath
 	return allErrs
 }
 
+// validateHyperVGenerationForSecurityType validates that a V1 image is not configured together with a security type. Both TrustedLaunch
+// and ConfidentialVM are only supported for generation 2 VMs.
+func validateHyperVGenerationForSecurityType(properties api.AzureVirtualMachineProperties, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	hyperVGeneration := properties.StorageProfile.ImageReference.HyperVGeneration
+	if hyperVGeneration == nil || *hyperVGeneration != string(armcompute.HyperVGenerationTypesV1) {
+		return allErrs
+	}
+	if properties.SecurityProfile != nil && !utils.IsNilOrEmptyStringPtr(properties.SecurityProfile.SecurityType) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("storageProfile", "imageReference", "hyperVGeneration"), fmt.Sprintf("security type %s is not supported for hyperVGeneration %s", *properties.SecurityProfile.SecurityType, *hyperVGeneration)))
+	}
+	return allErrs
+}
+
 func validateHardwareProfile(hwProfile api.AzureHardwareProfile, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 	if utils.IsEmptyString(hwProfile.VMSize) {
@@ -174,9 +424,32 @@ func validateHardwareProfile(hwProfile api.AzureHardwareProfile, fldPath *field.
 
 func validateStorageProfile(storageProfile api.AzureStorageProfile, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
-	allErrs = append(allErrs, validateStorageImageRef(storageProfile.ImageReference, fldPath.Child("imageReference"))...)
+	// a restored OSDisk already contains a provisioned OS, hence no image is used to create the VM.
+	if storageProfile.OsDisk.CreateOption != api.OSDiskCreateOptionRestore {
+		allErrs = append(allErrs, validateStorageImageRef(storageProfile.ImageReference, fldPath.Child("imageReference"))...)
+	}
 	allErrs = append(allErrs, validateOSDisk(storageProfile.OsDisk, fldPath.Child("osDisk"))...)
 	allErrs = append(allErrs, validateDataDisks(storageProfile.DataDisks, fldPath.Child("dataDisks"))...)
+	allErrs = append(allErrs, validateDiskControllerType(storageProfile, fldPath.Child("diskControllerType"))...)
+	allErrs = append(allErrs, validateDataDiskNameTemplate(storageProfile.DataDiskNameTemplate, fldPath.Child("dataDiskNameTemplate"))...)
+	return allErrs
+}
+
+func validateDiskControllerType(storageProfile api.AzureStorageProfile, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	diskControllerType := storageProfile.DiskControllerType
+	if diskControllerType == nil {
+		return allErrs
+	}
+	validDiskControllerTypes := stringTypesToString(armcompute.PossibleDiskControllerTypesValues())
+	if !isValidEnumString(*diskControllerType, validDiskControllerTypes) {
+		allErrs = append(allErrs, field.NotSupported(fldPath, *diskControllerType, validDiskControllerTypes))
+		return allErrs
+	}
+	// NVMe is only supported for generation 2 images.
+	if hyperVGeneration := storageProfile.ImageReference.HyperVGeneration; *diskControllerType == string(armcompute.DiskControllerTypesNVMe) && hyperVGeneration != nil && *hyperVGeneration == string(armcompute.HyperVGenerationTypesV1) {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "diskControllerType NVMe is not supported for images with hyperVGeneration V1"))
+	}
 	return allErrs
 }
 
@@ -185,6 +458,159 @@ func validateOSProfile(osProfile api.AzureOSProfile, fldPath *field.Path) field.
 	if utils.IsEmptyString(osProfile.AdminUsername) {
 		allErrs = append(allErrs, field.Required(fldPath.Child("adminUsername"), "adminUsername must be provided"))
 	}
+	// LinuxConfiguration is not a pointer and is the default, therefore it is only considered as set if it is not a zero value.
+	if windowsConfiguration := osProfile.WindowsConfiguration; windowsConfiguration != nil {
+		if osProfile.LinuxConfiguration != (api.AzureLinuxConfiguration{}) {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("linuxConfiguration|.windowsConfiguration"), "only one of linuxConfiguration and windowsConfiguration can be set"))
+		}
+		if windowsConfiguration.TimeZone != nil && utils.IsEmptyString(*windowsConfiguration.TimeZone) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("windowsConfiguration", "timeZone"), *windowsConfiguration.TimeZone, "timeZone must not be empty when set"))
+		}
+	}
+	if sshConfig := osProfile.LinuxConfiguration.SSH; osProfile.WindowsConfiguration == nil && sshConfig.RequirePublicKey &&
+		utils.IsEmptyString(sshConfig.PublicKeys.KeyData) && sshConfig.PublicKeys.SecretKey == nil {
+		allErrs = append(allErrs, field.Required(fldPath.Child("linuxConfiguration", "ssh", "publicKeys"), "must provide keyData or secretKey when requirePublicKey is set"))
+	}
+	if publicKeys := osProfile.LinuxConfiguration.SSH.PublicKeys; publicKeys.SecretKey != nil {
+		publicKeysPath := fldPath.Child("linuxConfiguration", "ssh", "publicKeys")
+		if utils.IsEmptyString(*publicKeys.SecretKey) {
+			allErrs = append(allErrs, field.Invalid(publicKeysPath.Child("secretKey"), *publicKeys.SecretKey, "secretKey must not be empty when set"))
+		}
+		if !utils.IsEmptyString(publicKeys.KeyData) {
+			allErrs = append(allErrs, field.Forbidden(publicKeysPath.Child("keyData|.secretKey"), "only one of keyData and secretKey can be set"))
+		}
+	}
+	allErrs = append(allErrs, validatePatchSettings(osProfile.PatchSettings, osProfile.WindowsConfiguration != nil, fldPath.Child("patchSettings"))...)
+	return allErrs
+}
+
+func validatePatchSettings(patchSettings *api.AzurePatchSettings, isWindows bool, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if patchSettings == nil {
+		return allErrs
+	}
+	validPatchModes := stringTypesToString(armcompute.PossibleLinuxVMGuestPatchModeValues())
+	validAssessmentModes := stringTypesToString(armcompute.PossibleLinuxPatchAssessmentModeValues())
+	if isWindows {
+		validPatchModes = stringTypesToString(armcompute.PossibleWindowsVMGuestPatchModeValues())
+		validAssessmentModes = stringTypesToString(armcompute.PossibleWindowsPatchAssessmentModeValues())
+	}
+	if patchMode := patchSettings.PatchMode; patchMode != nil && !isValidEnumString(*patchMode, validPatchModes) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("patchMode"), *patchMode, validPatchModes))
+	}
+	if assessmentMode := patchSettings.AssessmentMode; assessmentMode != nil && !isValidEnumString(*assessmentMode, validAssessmentModes) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("assessmentMode"), *assessmentMode, validAssessmentModes))
+	}
+	if patchSettings.BypassPlatformSafetyChecks != nil && (patchSettings.PatchMode == nil || *patchSettings.PatchMode != string(armcompute.LinuxVMGuestPatchModeAutomaticByPlatform)) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("bypassPlatformSafetyChecks"), "bypassPlatformSafetyChecks can only be set if patchMode is AutomaticByPlatform"))
+	}
+	return allErrs
+}
+
+func validateNetworkProfile(networkProfile api.AzureNetworkProfile, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, dnsServer := range networkProfile.DNSServers {
+		if net.ParseIP(dnsServer) == nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("dnsServers").Index(i), dnsServer, "must be a valid IP address"))
+		}
+	}
+	if nicType := networkProfile.NicType; nicType != nil {
+		validNicTypes := stringTypesToString(armnetwork.PossibleNetworkInterfaceNicTypeValues())
+		if !isValidEnumString(*nicType, validNicTypes) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("nicType"), *nicType, validNicTypes))
+		}
+	}
+	allErrs = append(allErrs, validateNICAuxiliaryModeAndSKU(networkProfile, fldPath)...)
+	return allErrs
+}
+
+func validateNICAuxiliaryModeAndSKU(networkProfile api.AzureNetworkProfile, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	auxiliaryMode, auxiliarySKU := networkProfile.AuxiliaryMode, networkProfile.AuxiliarySKU
+	if auxiliaryMode != nil {
+		validAuxiliaryModes := stringTypesToString(armnetwork.PossibleNetworkInterfaceAuxiliaryModeValues())
+		if !isValidEnumString(*auxiliaryMode, validAuxiliaryModes) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("auxiliaryMode"), *auxiliaryMode, validAuxiliaryModes))
+		}
+	}
+	if auxiliarySKU != nil {
+		validAuxiliarySKUs := stringTypesToString(armnetwork.PossibleNetworkInterfaceAuxiliarySKUValues())
+		if !isValidEnumString(*auxiliarySKU, validAuxiliarySKUs) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("auxiliarySku"), *auxiliarySKU, validAuxiliarySKUs))
+		}
+	}
+	isAuxiliaryModeEnabled := auxiliaryMode != nil && *auxiliaryMode != string(armnetwork.NetworkInterfaceAuxiliaryModeNone)
+	isAuxiliarySKUEnabled := auxiliarySKU != nil && *auxiliarySKU != string(armnetwork.NetworkInterfaceAuxiliarySKUNone)
+	if isAuxiliaryModeEnabled != isAuxiliarySKUEnabled {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("auxiliaryMode|.auxiliarySku"), "auxiliaryMode and auxiliarySku must either both be enabled or both be disabled"))
+	}
+	if isAuxiliaryModeEnabled && networkProfile.AcceleratedNetworking != nil && !*networkProfile.AcceleratedNetworking {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("auxiliaryMode"), "auxiliaryMode requires accelerated networking"))
+	}
+	return allErrs
+}
+
+func validateExtensions(extensions []api.AzureVMExtension, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := sets.New[string]()
+	for i, extension := range extensions {
+		idxPath := fldPath.Index(i)
+		if utils.IsEmptyString(extension.Name) {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), "must provide name"))
+		} else if names.Has(extension.Name) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), extension.Name))
+		} else {
+			names.Insert(extension.Name)
+		}
+		if utils.IsEmptyString(extension.Publisher) {
+			allErrs = append(allErrs, field.Required(idxPath.Child("publisher"), "must provide publisher"))
+		}
+		if utils.IsEmptyString(extension.Type) {
+			allErrs = append(allErrs, field.Required(idxPath.Child("type"), "must provide type"))
+		}
+		if utils.IsEmptyString(extension.TypeHandlerVersion) {
+			allErrs = append(allErrs, field.Required(idxPath.Child("typeHandlerVersion"), "must provide typeHandlerVersion"))
+		}
+		if extension.ProtectedSettingsSecretKey != nil && utils.IsEmptyString(*extension.ProtectedSettingsSecretKey) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("protectedSettingsSecretKey"), *extension.ProtectedSettingsSecretKey, "protectedSettingsSecretKey must not be empty when set"))
+		}
+	}
+	return allErrs
+}
+
+func validateApplicationHealthProfile(profile *api.AzureApplicationHealthProfile, extensions []api.AzureVMExtension, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if profile == nil || !profile.Enabled {
+		return allErrs
+	}
+	validProtocols := []string{api.ApplicationHealthProtocolHTTP, api.ApplicationHealthProtocolHTTPS, api.ApplicationHealthProtocolTCP}
+	if !slices.Contains(validProtocols, profile.Protocol) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("protocol"), profile.Protocol, validProtocols))
+	}
+	if profile.Protocol == api.ApplicationHealthProtocolTCP {
+		if profile.Port == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("port"), "must provide port for tcp probes"))
+		}
+		if profile.RequestPath != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("requestPath"), "requestPath is not supported for tcp probes"))
+		}
+	} else if utils.IsNilOrEmptyStringPtr(profile.RequestPath) {
+		allErrs = append(allErrs, field.Required(fldPath.Child("requestPath"), "must provide requestPath for http and https probes"))
+	}
+	if profile.Port != nil && (*profile.Port <= 0 || *profile.Port > 65535) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), *profile.Port, "port must be between 1 and 65535"))
+	}
+	if profile.IntervalInSeconds != nil && *profile.IntervalInSeconds <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("intervalInSeconds"), *profile.IntervalInSeconds, "intervalInSeconds must be positive"))
+	}
+	if profile.NumberOfProbes != nil && *profile.NumberOfProbes <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("numberOfProbes"), *profile.NumberOfProbes, "numberOfProbes must be positive"))
+	}
+	for _, extension := range extensions {
+		if extension.Name == api.ApplicationHealthExtensionName {
+			allErrs = append(allErrs, field.Forbidden(fldPath, fmt.Sprintf("extension name %s is reserved for the application health extension and cannot be used in extensions", api.ApplicationHealthExtensionName)))
+		}
+	}
 	return allErrs
 }
 
@@ -201,6 +627,28 @@ func validateStorageImageRef(imageRef api.AzureImageReference, fldPath *field.Pa
 		return append(allErrs, field.Forbidden(fldPath.Child("id|.urn|.communityGalleryImageID|.sharedGalleryImageID"), "must specify only one of image id, community gallery image id, shared gallery image id or an urn"))
 	}
 
+	allErrs = append(allErrs, validateImageRefSubscriptionAndTenant(imageRef, idIsSet, fldPath)...)
+
+	if imageRef.HyperVGeneration != nil {
+		validValues := stringTypesToString(armcompute.PossibleHyperVGenerationTypesValues())
+		if ok := isValidEnumString(*imageRef.HyperVGeneration, validValues); !ok {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("hyperVGeneration"), *imageRef.HyperVGeneration, validValues))
+		}
+	}
+
+	if plan := imageRef.Plan; plan != nil {
+		planPath := fldPath.Child("plan")
+		if utils.IsEmptyString(plan.Name) {
+			allErrs = append(allErrs, field.Required(planPath.Child("name"), "must provide the name of the purchase plan"))
+		}
+		if utils.IsEmptyString(plan.Product) {
+			allErrs = append(allErrs, field.Required(planPath.Child("product"), "must provide the product of the purchase plan"))
+		}
+		if utils.IsEmptyString(plan.Publisher) {
+			allErrs = append(allErrs, field.Required(planPath.Child("publisher"), "must provide the publisher of the purchase plan"))
+		}
+	}
+
 	if urnIsSet {
 		allErrs = append(allErrs, validateURN(*imageRef.URN, fldPath.Child("urn"))...)
 		return allErrs
@@ -209,6 +657,25 @@ func validateStorageImageRef(imageRef api.AzureImageReference, fldPath *field.Pa
 	return allErrs
 }
 
+func validateImageRefSubscriptionAndTenant(imageRef api.AzureImageReference, idIsSet bool, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if imageRef.SubscriptionID != nil {
+		if !idIsSet {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("subscriptionID"), "subscriptionID can only be set together with id"))
+		} else if !strings.HasPrefix(strings.ToLower(imageRef.ID), strings.ToLower(fmt.Sprintf("/subscriptions/%s/", *imageRef.SubscriptionID))) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("subscriptionID"), *imageRef.SubscriptionID, "image id must refer to a resource in subscriptionID"))
+		}
+	}
+	if imageRef.TenantID != nil {
+		if !idIsSet {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("tenantID"), "tenantID can only be set together with id"))
+		} else if utils.IsEmptyString(*imageRef.TenantID) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("tenantID"), *imageRef.TenantID, "tenantID must not be empty when set"))
+		}
+	}
+	return allErrs
+}
+
 func validateOSDisk(osDisk api.AzureOSDisk, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 	if utils.IsEmptyString(osDisk.CreateOption) {
@@ -218,6 +685,14 @@ func validateOSDisk(osDisk api.AzureOSDisk, fldPath *field.Path) field.ErrorList
 		allErrs = append(allErrs, field.Invalid(fldPath.Child("diskSizeGB"), osDisk.DiskSizeGB, "OSDisk size must be positive and greater than 0"))
 	}
 
+	if !utils.IsEmptyString(osDisk.ManagedDisk.StorageAccountType) {
+		allErrs = append(allErrs, validateStorageAccountType(osDisk.ManagedDisk.StorageAccountType, fldPath.Child("managedDisk", "storageAccountType"))...)
+	}
+	allErrs = append(allErrs, validateWriteAccelerator(osDisk.WriteAcceleratorEnabled, osDisk.ManagedDisk.StorageAccountType, osDisk.Caching, fldPath)...)
+	allErrs = append(allErrs, validateOSDiskNameTemplate(osDisk.NameTemplate, fldPath.Child("nameTemplate"))...)
+	allErrs = append(allErrs, validateDiskTier(osDisk.Tier, osDisk.ManagedDisk.StorageAccountType, fldPath.Child("tier"))...)
+	allErrs = append(allErrs, validateOSDiskRestoreSource(osDisk, fldPath)...)
+
 	if securityProfile := osDisk.ManagedDisk.SecurityProfile; securityProfile != nil {
 		if encryptionType := securityProfile.SecurityEncryptionType; !utils.IsNilOrEmptyStringPtr(encryptionType) {
 			validValues := stringTypesToString(armcompute.PossibleSecurityEncryptionTypesValues())
@@ -230,6 +705,30 @@ func validateOSDisk(osDisk api.AzureOSDisk, fldPath *field.Path) field.ErrorList
 	return allErrs
 }
 
+// validateOSDiskRestoreSource validates that exactly one of snapshotID and restorePointID is set if the OSDisk is restored
+// and that none of them is set otherwise.
+func validateOSDiskRestoreSource(osDisk api.AzureOSDisk, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	snapshotIDIsSet := osDisk.SnapshotID != nil
+	restorePointIDIsSet := osDisk.RestorePointID != nil
+	if osDisk.CreateOption != api.OSDiskCreateOptionRestore {
+		if snapshotIDIsSet || restorePointIDIsSet {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("snapshotID|.restorePointID"), fmt.Sprintf("can only be set if createOption is %s", api.OSDiskCreateOptionRestore)))
+		}
+		return allErrs
+	}
+	if !exactlyOneShouldBeTrue(snapshotIDIsSet, restorePointIDIsSet) {
+		return append(allErrs, field.Forbidden(fldPath.Child("snapshotID|.restorePointID"), fmt.Sprintf("must specify exactly one of snapshotID or restorePointID if createOption is %s", api.OSDiskCreateOptionRestore)))
+	}
+	if snapshotIDIsSet && !snapshotIDRegex.MatchString(*osDisk.SnapshotID) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("snapshotID"), *osDisk.SnapshotID, "snapshotID must be the resource ID of a snapshot"))
+	}
+	if restorePointIDIsSet && !diskRestorePointIDRegex.MatchString(*osDisk.RestorePointID) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("restorePointID"), *osDisk.RestorePointID, "restorePointID must be the resource ID of a disk restore point"))
+	}
+	return allErrs
+}
+
 func validateDataDisks(disks []api.AzureDataDisk, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 	if disks == nil {
@@ -251,11 +750,30 @@ func validateDataDisks(disks []api.AzureDataDisk, fldPath *field.Path) field.Err
 		}
 		if utils.IsEmptyString(disk.StorageAccountType) {
 			allErrs = append(allErrs, field.Required(fldPath.Child("storageAccountType"), "must provide storageAccountType"))
+		} else {
+			allErrs = append(allErrs, validateStorageAccountType(disk.StorageAccountType, fldPath.Child("storageAccountType"))...)
 		}
 
 		if disk.ImageRef != nil {
 			allErrs = append(allErrs, validateStorageImageRef(*disk.ImageRef, fldPath.Child("imageRef"))...)
 		}
+		if disk.SnapshotID != nil {
+			if disk.ImageRef != nil {
+				allErrs = append(allErrs, field.Forbidden(fldPath.Child("imageRef|.snapshotID"), "only one of imageRef and snapshotID can be set"))
+			}
+			if !snapshotIDRegex.MatchString(*disk.SnapshotID) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("snapshotID"), *disk.SnapshotID, "snapshotID must be the resource ID of a snapshot"))
+			}
+		}
+		allErrs = append(allErrs, validateWriteAccelerator(disk.WriteAcceleratorEnabled, disk.StorageAccountType, disk.Caching, fldPath)...)
+		allErrs = append(allErrs, validateMaxShares(disk, fldPath)...)
+		allErrs = append(allErrs, validateDiskTier(disk.Tier, disk.StorageAccountType, fldPath.Child("tier"))...)
+		if deleteOption := disk.DeleteOption; deleteOption != nil {
+			validDeleteOptions := stringTypesToString(armcompute.PossibleDiskDeleteOptionTypesValues())
+			if !isValidEnumString(*deleteOption, validDeleteOptions) {
+				allErrs = append(allErrs, field.NotSupported(fldPath.Child("deleteOption"), *deleteOption, validDeleteOptions))
+			}
+		}
 	}
 
 	for lun, numOccurrence := range luns {
@@ -267,10 +785,122 @@ func validateDataDisks(disks []api.AzureDataDisk, fldPath *field.Path) field.Err
 	return allErrs
 }
 
+// validateOSDiskNameTemplate validates that the name template contains the VM name placeholder, which keeps the OSDisk names unique,
+// and that the remaining characters are allowed in disk names.
+func validateOSDiskNameTemplate(nameTemplate *string, fldPath *field.Path) field.ErrorList {
+	return validateDiskNameTemplate(nameTemplate, []string{utils.NameTemplateVMNamePlaceholder}, nil, fldPath)
+}
+
+// validateDataDiskNameTemplate validates that the name template contains the VM name and lun placeholders, which keep the DataDisk
+// names unique, and that the remaining characters are allowed in disk names. The data disk name placeholder is optional.
+func validateDataDiskNameTemplate(nameTemplate *string, fldPath *field.Path) field.ErrorList {
+	return validateDiskNameTemplate(nameTemplate, []string{utils.NameTemplateVMNamePlaceholder, utils.DataDiskNameTemplateLunPlaceholder}, []string{utils.DataDiskNameTemplateNamePlaceholder}, fldPath)
+}
+
+func validateDiskNameTemplate(nameTemplate *string, requiredPlaceholders, optionalPlaceholders []string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if nameTemplate == nil {
+		return allErrs
+	}
+	remainder := *nameTemplate
+	for _, placeholder := range requiredPlaceholders {
+		if strings.Count(*nameTemplate, placeholder) != 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath, *nameTemplate, fmt.Sprintf("nameTemplate must contain the placeholder %s exactly once", placeholder)))
+		}
+		remainder = strings.ReplaceAll(remainder, placeholder, "")
+	}
+	for _, placeholder := range optionalPlaceholders {
+		remainder = strings.ReplaceAll(remainder, placeholder, "")
+	}
+	if len(allErrs) == 0 && !diskNameTemplateRegex.MatchString(remainder) {
+		allErrs = append(allErrs, field.Invalid(fldPath, *nameTemplate, "nameTemplate must only contain alphanumeric characters, underscores, periods and hyphens besides the placeholders"))
+	}
+	return allErrs
+}
+
+// validateStorageAccountType validates that the storage account type of a managed disk is known. This includes the zone-redundant
+// storage account types Premium_ZRS and StandardSSD_ZRS.
+func validateStorageAccountType(storageAccountType string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	validValues := stringTypesToString(armcompute.PossibleStorageAccountTypesValues())
+	if !isValidEnumString(storageAccountType, validValues) {
+		allErrs = append(allErrs, field.NotSupported(fldPath, storageAccountType, validValues))
+	}
+	return allErrs
+}
+
+// validateDiskTier validates that the performance tier is a known Premium SSD tier and that the disk uses a Premium SSD
+// storage account type, since performance tiers are not supported for other disk types.
+func validateDiskTier(tier *string, storageAccountType string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if tier == nil {
+		return allErrs
+	}
+	supportedTiers := sets.New("P1", "P2", "P3", "P4", "P6", "P10", "P15", "P20", "P30", "P40", "P50", "P60", "P70", "P80")
+	if !supportedTiers.Has(*tier) {
+		allErrs = append(allErrs, field.NotSupported(fldPath, *tier, sets.List(supportedTiers)))
+	}
+	supportedStorageAccountTypes := sets.New(
+		string(armcompute.StorageAccountTypesPremiumLRS),
+		string(armcompute.StorageAccountTypesPremiumZRS),
+	)
+	if !supportedStorageAccountTypes.Has(storageAccountType) {
+		allErrs = append(allErrs, field.Forbidden(fldPath, fmt.Sprintf("tier is only supported for storageAccountType %v", sets.List(supportedStorageAccountTypes))))
+	}
+	return allErrs
+}
+
+// validateMaxShares validates that shared disks use a storage account type which supports them and have caching disabled.
+func validateMaxShares(disk api.AzureDataDisk, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if disk.MaxShares == nil {
+		return allErrs
+	}
+	if *disk.MaxShares < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxShares"), *disk.MaxShares, "maxShares must be greater than 0"))
+		return allErrs
+	}
+	if *disk.MaxShares == 1 {
+		return allErrs
+	}
+	supportedStorageAccountTypes := sets.New(
+		string(armcompute.StorageAccountTypesPremiumLRS),
+		string(armcompute.StorageAccountTypesPremiumZRS),
+		string(armcompute.StorageAccountTypesStandardSSDLRS),
+		string(armcompute.StorageAccountTypesStandardSSDZRS),
+		string(armcompute.StorageAccountTypesPremiumV2LRS),
+		string(armcompute.StorageAccountTypesUltraSSDLRS),
+	)
+	if !supportedStorageAccountTypes.Has(disk.StorageAccountType) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("maxShares"), fmt.Sprintf("shared disks are only supported for storageAccountType %v", sets.List(supportedStorageAccountTypes))))
+	}
+	if !utils.IsEmptyString(disk.Caching) && disk.Caching != string(armcompute.CachingTypesNone) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("maxShares"), "shared disks require caching to be None"))
+	}
+	return allErrs
+}
+
+// validateWriteAccelerator validates that write accelerator is only enabled for Premium storage and with caching set to None or ReadOnly.
+// Whether the VM size supports write accelerator can only be validated against the resource SKU of the VM size when the VM is created.
+func validateWriteAccelerator(writeAcceleratorEnabled *bool, storageAccountType, caching string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if writeAcceleratorEnabled == nil || !*writeAcceleratorEnabled {
+		return allErrs
+	}
+	premiumStorageAccountTypes := []string{string(armcompute.StorageAccountTypesPremiumLRS), string(armcompute.StorageAccountTypesPremiumZRS)}
+	if !slices.Contains(premiumStorageAccountTypes, storageAccountType) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("writeAcceleratorEnabled"), fmt.Sprintf("write accelerator is only supported for storage account types %v", premiumStorageAccountTypes)))
+	}
+	if caching == string(armcompute.CachingTypesReadWrite) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("writeAcceleratorEnabled"), "write accelerator is not supported with caching ReadWrite"))
+	}
+	return allErrs
+}
+
 func validateAvailabilityAndScalingConfig(properties api.AzureVirtualMachineProperties, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 
-	isZoneConfigured := properties.Zone != nil
+	isZoneConfigured := properties.Zone != nil || len(properties.Zones) > 0
 	isAvailabilitySetConfigured := properties.AvailabilitySet != nil && !utils.IsEmptyString(properties.AvailabilitySet.ID)
 	isVirtualMachineScaleSetConfigured := properties.VirtualMachineScaleSet != nil && !utils.IsEmptyString(properties.VirtualMachineScaleSet.ID)
 
@@ -290,7 +920,39 @@ func validateAvailabilityAndScalingConfig(properties api.AzureVirtualMachineProp
 	if !exactlyOneShouldBeTrue(isZoneConfigured, isAvailabilitySetConfigured, isVirtualMachineScaleSetConfigured) {
 		allErrs = append(allErrs, field.Forbidden(fldPath.Child("zone|.availabilitySet|.virtualMachineScaleSet"), "Only one of Zone, AvailabilitySet and VirtualMachineScaleSet can be set."))
 	}
+	allErrs = append(allErrs, validateZones(properties.Zone, properties.Zones, fldPath)...)
+
+	if platformFaultDomain := properties.PlatformFaultDomain; platformFaultDomain != nil {
+		if !isVirtualMachineScaleSetConfigured {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("platformFaultDomain"), "platformFaultDomain can only be set together with virtualMachineScaleSet"))
+		}
+		// a Flexible scale set has at most 3 fault domains.
+		if *platformFaultDomain < 0 || *platformFaultDomain > 2 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("platformFaultDomain"), *platformFaultDomain, "platformFaultDomain must be between 0 and 2"))
+		}
+	}
+
+	return allErrs
+}
 
+func validateZones(zone *int, zones []int, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if zone != nil && len(zones) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("zone|.zones"), "only one of zone and zones can be set"))
+	}
+	if zone != nil && *zone <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("zone"), *zone, "zone must be a positive number"))
+	}
+	seenZones := sets.New[int]()
+	for i, z := range zones {
+		if z <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("zones").Index(i), z, "zone must be a positive number"))
+		}
+		if seenZones.Has(z) {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Child("zones").Index(i), z))
+		}
+		seenZones.Insert(z)
+	}
 	return allErrs
 }
 
@@ -304,8 +966,34 @@ func validateTags(tags map[string]string, fldPath *field.Path) field.ErrorList {
 		return append(allErrs, field.Required(fldPath.Child(clusterKeyPrefix, nodeRoleKeyPrefix), fmt.Sprintf("Tags starting with '%s' and '%s' must be set", clusterKeyPrefix, nodeRoleKeyPrefix)))
 	}
 
-	var clusterKeySet, nodeRoleKeySet bool
+	// the VM additionally gets the machine name tag, see utils.CreateVMTags
+	if maxTags := utils.MaxTagsPerResource - 1; len(tags) > maxTags {
+		allErrs = append(allErrs, field.TooMany(fldPath, len(tags), maxTags))
+	}
+	keys := make([]string, 0, len(tags))
 	for key := range tags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	// forbidden characters in tag keys are translated when the tags are set on the resources, see utils.CreateResourceTags.
+	// Hence, the translated keys are validated.
+	sanitizedKeys := make(map[string]string, len(keys))
+	for _, key := range keys {
+		sanitizedKey := utils.SanitizeTagKey(key)
+		if len(sanitizedKey) > utils.MaxTagKeyLength {
+			allErrs = append(allErrs, field.TooLong(fldPath.Key(key), key, utils.MaxTagKeyLength))
+		}
+		if otherKey, ok := sanitizedKeys[sanitizedKey]; ok {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Key(key), fmt.Sprintf("%s (translated to %q, same as for %q)", key, sanitizedKey, otherKey)))
+		}
+		sanitizedKeys[sanitizedKey] = key
+		if value := tags[key]; len(value) > utils.MaxTagValueLength {
+			allErrs = append(allErrs, field.TooLong(fldPath.Key(key), value, utils.MaxTagValueLength))
+		}
+	}
+
+	var clusterKeySet, nodeRoleKeySet bool
+	for key := range sanitizedKeys {
 		if strings.HasPrefix(key, clusterKeyPrefix) {
 			clusterKeySet = true
 		} else if strings.HasPrefix(key, nodeRoleKeyPrefix) {
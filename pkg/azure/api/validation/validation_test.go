@@ -6,6 +6,8 @@ package validation
 
 import (
 	"encoding/base64"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
@@ -20,6 +22,7 @@ import (
 	"k8s.io/utils/ptr"
 
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
 )
 
@@ -59,9 +62,15 @@ func TestValidateProviderSecret(t *testing.T) {
 			"  ", testClientSecret, "", testSubscriptionID, testTenantID, testUserData, 1,
 			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.clientID")}))),
 		},
-		{"should forbid empty clientSecret and workloadIdentityTokenFile",
-			testClientID, "", "", testSubscriptionID, testTenantID, testUserData, 1,
-			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.clientSecret")}))),
+		{"should allow empty clientSecret and workloadIdentityTokenFile to use a user-assigned managed identity",
+			testClientID, "", "", testSubscriptionID, testTenantID, testUserData, 0, nil,
+		},
+		{"should allow empty clientID, clientSecret and workloadIdentityTokenFile to use a system-assigned managed identity",
+			"", "", "", testSubscriptionID, testTenantID, testUserData, 0, nil,
+		},
+		{"should forbid empty clientID with workloadIdentityTokenFile",
+			"", "", testWorkloadIdentityTokenFile, testSubscriptionID, testTenantID, testUserData, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.clientID")}))),
 		},
 		{"should forbid setting both clientSecret and workloadIdentityTokenFile",
 			testClientID, testClientSecret, testWorkloadIdentityTokenFile, testSubscriptionID, testTenantID, testUserData, 1,
@@ -88,10 +97,8 @@ func TestValidateProviderSecret(t *testing.T) {
 			),
 		},
 		{"should forbid when all required fields are absent",
-			"", "", "", "", "", "", 5,
+			"", "", "", "", "", "", 3,
 			ConsistOf(
-				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.clientID")})),
-				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.clientSecret")})),
 				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.subscriptionID")})),
 				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.tenantID")})),
 				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.userData")})),
@@ -114,6 +121,22 @@ func TestValidateProviderSecret(t *testing.T) {
 	}
 }
 
+func TestValidateProviderSecretWithAzureFederatedTokenFile(t *testing.T) {
+	const testFederatedTokenFile = "/var/run/secrets/azure/tokens/azure-identity-token"
+
+	g := NewWithT(t)
+	t.Run("should succeed when azureFederatedTokenFile is set instead of clientSecret", func(_ *testing.T) {
+		secret := createSecret("client-id", "", "", "subscription-id", "tenant-id", "user-data")
+		secret.Data[api.AzureFederatedTokenFile] = []byte(testFederatedTokenFile)
+		g.Expect(ValidateProviderSecret(secret)).To(BeEmpty())
+	})
+	t.Run("should forbid setting both clientSecret and azureFederatedTokenFile", func(_ *testing.T) {
+		secret := createSecret("client-id", "client-secret", "", "subscription-id", "tenant-id", "user-data")
+		secret.Data[api.AzureFederatedTokenFile] = []byte(testFederatedTokenFile)
+		g.Expect(ValidateProviderSecret(secret)).To(ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data.clientSecret")}))))
+	})
+}
+
 func TestValidateSubnetInfo(t *testing.T) {
 	const (
 		testSubnetName = "test-control-ns-nodes"
@@ -163,6 +186,38 @@ func TestValidateSubnetInfo(t *testing.T) {
 
 }
 
+func TestValidateSubnetInfoWithSubscriptionAndSubnetID(t *testing.T) {
+	const testSubnetID = "/subscriptions/hub-subscription/resourceGroups/hub-rg/providers/Microsoft.Network/virtualNetworks/hub-vnet/subnets/nodes"
+	fldPath := field.NewPath("providerSpec", "subnetInfo")
+
+	table := []struct {
+		description string
+		subnetInfo  api.AzureSubnetInfo
+		matcher     gomegatypes.GomegaMatcher
+	}{
+		{"should allow a subscriptionID", api.AzureSubnetInfo{VnetName: "vnet", SubnetName: "subnet", SubscriptionID: to.Ptr("hub-subscription")}, BeEmpty()},
+		{"should forbid an empty subscriptionID",
+			api.AzureSubnetInfo{VnetName: "vnet", SubnetName: "subnet", SubscriptionID: to.Ptr(" ")},
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.subnetInfo.subscriptionID")}))),
+		},
+		{"should allow a subnetID", api.AzureSubnetInfo{SubnetID: to.Ptr(testSubnetID)}, BeEmpty()},
+		{"should forbid a subnetID which is not the ID of a subnet",
+			api.AzureSubnetInfo{SubnetID: to.Ptr("/subscriptions/hub-subscription/resourceGroups/hub-rg/providers/Microsoft.Network/virtualNetworks/hub-vnet")},
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.subnetInfo.subnetID")}))),
+		},
+		{"should forbid a subnetID together with other fields",
+			api.AzureSubnetInfo{SubnetID: to.Ptr(testSubnetID), VnetName: "vnet"},
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.subnetInfo.subnetID")}))),
+		},
+	}
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			g.Expect(validateSubnetInfo(entry.subnetInfo, fldPath)).To(entry.matcher)
+		})
+	}
+}
+
 func TestValidateHardwareProfile(t *testing.T) {
 	fldPath := field.NewPath("providerSpec.properties.hardwareProfile")
 	hwProfile := api.AzureHardwareProfile{}
@@ -275,6 +330,259 @@ func TestValidateOSProfile(t *testing.T) {
 	}
 }
 
+func TestValidateOSProfileWindowsConfiguration(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.osProfile")
+	table := []struct {
+		description          string
+		linuxConfiguration   api.AzureLinuxConfiguration
+		windowsConfiguration *api.AzureWindowsConfiguration
+		expectedErrors       int
+		matcher              gomegatypes.GomegaMatcher
+	}{
+		{"should succeed when only linuxConfiguration is set", api.AzureLinuxConfiguration{DisablePasswordAuthentication: true}, nil, 0, nil},
+		{"should succeed when only windowsConfiguration is set", api.AzureLinuxConfiguration{}, &api.AzureWindowsConfiguration{TimeZone: to.Ptr("W. Europe Standard Time")}, 0, nil},
+		{
+			"should forbid setting both linuxConfiguration and windowsConfiguration", api.AzureLinuxConfiguration{DisablePasswordAuthentication: true}, &api.AzureWindowsConfiguration{}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.osProfile.linuxConfiguration|.windowsConfiguration")}))),
+		},
+		{
+			"should forbid empty timeZone", api.AzureLinuxConfiguration{}, &api.AzureWindowsConfiguration{TimeZone: to.Ptr("")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.osProfile.windowsConfiguration.timeZone")}))),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			osProfile := api.AzureOSProfile{
+				AdminUsername:        "test-admin-user",
+				LinuxConfiguration:   entry.linuxConfiguration,
+				WindowsConfiguration: entry.windowsConfiguration,
+			}
+			errList := validateOSProfile(osProfile, fldPath)
+			g.Expect(len(errList)).To(Equal(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateOSProfileSSHPublicKeySecretKey(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.osProfile")
+	table := []struct {
+		description    string
+		publicKeys     api.AzureSSHPublicKey
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow a secret key", api.AzureSSHPublicKey{Path: "/home/core/.ssh/authorized_keys", SecretKey: to.Ptr("sshPublicKey")}, 0, nil},
+		{"should forbid an empty secret key", api.AzureSSHPublicKey{SecretKey: to.Ptr("")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.osProfile.linuxConfiguration.ssh.publicKeys.secretKey")})))},
+		{"should forbid setting both keyData and secretKey", api.AzureSSHPublicKey{KeyData: "ssh-rsa AAAA", SecretKey: to.Ptr("sshPublicKey")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.osProfile.linuxConfiguration.ssh.publicKeys.keyData|.secretKey")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			osProfile := api.AzureOSProfile{
+				AdminUsername:      "test-admin-user",
+				LinuxConfiguration: api.AzureLinuxConfiguration{SSH: api.AzureSSHConfiguration{PublicKeys: entry.publicKeys}},
+			}
+			errList := validateOSProfile(osProfile, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateOSProfileRequirePublicKey(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.osProfile")
+	table := []struct {
+		description    string
+		sshConfig      api.AzureSSHConfiguration
+		expectedErrors int
+	}{
+		{"should allow no public key if it is not required", api.AzureSSHConfiguration{}, 0},
+		{"should allow keyData if a public key is required", api.AzureSSHConfiguration{RequirePublicKey: true, PublicKeys: api.AzureSSHPublicKey{KeyData: testhelp.SSHPublicKey}}, 0},
+		{"should allow secretKey if a public key is required", api.AzureSSHConfiguration{RequirePublicKey: true, PublicKeys: api.AzureSSHPublicKey{SecretKey: to.Ptr("sshPublicKey")}}, 0},
+		{"should require a public key if it is required", api.AzureSSHConfiguration{RequirePublicKey: true}, 1},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			osProfile := api.AzureOSProfile{
+				AdminUsername:      "test-admin-user",
+				LinuxConfiguration: api.AzureLinuxConfiguration{SSH: entry.sshConfig},
+			}
+			errList := validateOSProfile(osProfile, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.expectedErrors > 0 {
+				g.Expect(errList).To(ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.osProfile.linuxConfiguration.ssh.publicKeys")}))))
+			}
+		})
+	}
+}
+
+func TestValidateProviderSecretForCreate(t *testing.T) {
+	table := []struct {
+		description          string
+		windowsConfiguration *api.AzureWindowsConfiguration
+		adminPassword        string
+		expectedErrors       int
+	}{
+		{"should not require adminPassword for Linux VMs", nil, "", 0},
+		{"should require adminPassword for Windows VMs", &api.AzureWindowsConfiguration{}, "", 1},
+		{"should succeed when adminPassword is set for Windows VMs", &api.AzureWindowsConfiguration{}, "s3cr3t-P@ssw0rd", 0},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			secret := &corev1.Secret{Data: map[string][]byte{api.AdminPassword: []byte(entry.adminPassword)}}
+			spec := api.AzureProviderSpec{Properties: api.AzureVirtualMachineProperties{OsProfile: api.AzureOSProfile{WindowsConfiguration: entry.windowsConfiguration}}}
+			g.Expect(ValidateProviderSecretForCreate(secret, spec)).To(HaveLen(entry.expectedErrors))
+		})
+	}
+}
+
+func TestValidateProviderSecretForCreateWithSSHPublicKey(t *testing.T) {
+	const sshPublicKeySecretKey = "sshPublicKey"
+	table := []struct {
+		description    string
+		secretKey      *string
+		secretData     map[string][]byte
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should not require anything if no secret key is configured", nil, nil, 0, nil},
+		{"should require the configured secret key", to.Ptr(sshPublicKeySecretKey), nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data." + sshPublicKeySecretKey)})))},
+		{"should forbid a value which is not an SSH public key", to.Ptr(sshPublicKeySecretKey), map[string][]byte{sshPublicKeySecretKey: []byte("not-a-key")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("data." + sshPublicKeySecretKey)})))},
+		{"should succeed when the configured secret key holds an SSH public key", to.Ptr(sshPublicKeySecretKey), map[string][]byte{sshPublicKeySecretKey: []byte(testhelp.SSHPublicKey + "\n")}, 0, nil},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			secret := &corev1.Secret{Data: entry.secretData}
+			spec := api.AzureProviderSpec{Properties: api.AzureVirtualMachineProperties{OsProfile: api.AzureOSProfile{
+				LinuxConfiguration: api.AzureLinuxConfiguration{SSH: api.AzureSSHConfiguration{PublicKeys: api.AzureSSHPublicKey{SecretKey: entry.secretKey}}},
+			}}}
+			errList := ValidateProviderSecretForCreate(secret, spec)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateProviderSecretForCreateWithUserData(t *testing.T) {
+	const userDataSecretKey = "nodeUserData"
+	table := []struct {
+		description    string
+		userData       *api.AzureUserData
+		secretData     map[string][]byte
+		expectedErrors int
+	}{
+		{"should not require anything if userData is not configured", nil, nil, 0},
+		{"should not require a separate secret key if none is configured", &api.AzureUserData{Enabled: true}, nil, 0},
+		{"should not require the secret key if userData is disabled", &api.AzureUserData{SecretKey: to.Ptr(userDataSecretKey)}, nil, 0},
+		{"should require the configured secret key", &api.AzureUserData{Enabled: true, SecretKey: to.Ptr(userDataSecretKey)}, nil, 1},
+		{"should succeed when the configured secret key is set", &api.AzureUserData{Enabled: true, SecretKey: to.Ptr(userDataSecretKey)}, map[string][]byte{userDataSecretKey: []byte("user-data")}, 0},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			secret := &corev1.Secret{Data: entry.secretData}
+			spec := api.AzureProviderSpec{Properties: api.AzureVirtualMachineProperties{UserData: entry.userData}}
+			errList := ValidateProviderSecretForCreate(secret, spec)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.expectedErrors > 0 {
+				g.Expect(errList).To(ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("data." + userDataSecretKey)}))))
+			}
+		})
+	}
+}
+
+func TestValidateNetworkProfile(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.networkProfile")
+	table := []struct {
+		description    string
+		dnsServers     []string
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should succeed when no dnsServers are set", nil, 0, nil},
+		{"should succeed when dnsServers are valid IP addresses", []string{"10.0.0.10", "fd00::10"}, 0, nil},
+		{
+			"should forbid dnsServers which are not IP addresses", []string{"10.0.0.10", "dns.example.com"}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.networkProfile.dnsServers[1]")}))),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateNetworkProfile(api.AzureNetworkProfile{DNSServers: entry.dnsServers}, fldPath)
+			g.Expect(len(errList)).To(Equal(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateNICTypeAndAuxiliaryMode(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.networkProfile")
+	table := []struct {
+		description           string
+		nicType               *string
+		auxiliaryMode         *string
+		auxiliarySKU          *string
+		acceleratedNetworking *bool
+		expectedErrors        int
+		matcher               gomegatypes.GomegaMatcher
+	}{
+		{"should allow no nicType and auxiliary mode", nil, nil, nil, nil, 0, nil},
+		{"should allow Elastic nicType", to.Ptr("Elastic"), nil, nil, nil, 0, nil},
+		{"should allow auxiliary mode with SKU", nil, to.Ptr("AcceleratedConnections"), to.Ptr("A2"), to.Ptr(true), 0, nil},
+		{"should allow disabled auxiliary mode and SKU", nil, to.Ptr("None"), to.Ptr("None"), to.Ptr(false), 0, nil},
+		{"should forbid unknown nicType", to.Ptr("Fancy"), nil, nil, nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.networkProfile.nicType")})))},
+		{"should forbid unknown auxiliary SKU", nil, to.Ptr("Floating"), to.Ptr("A3"), nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.networkProfile.auxiliarySku")})))},
+		{"should forbid auxiliary mode without SKU", nil, to.Ptr("MaxConnections"), nil, nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.networkProfile.auxiliaryMode|.auxiliarySku")})))},
+		{"should forbid auxiliary mode when accelerated networking is disabled", nil, to.Ptr("AcceleratedConnections"), to.Ptr("A1"), to.Ptr(false), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.networkProfile.auxiliaryMode")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			networkProfile := api.AzureNetworkProfile{
+				NicType:               entry.nicType,
+				AuxiliaryMode:         entry.auxiliaryMode,
+				AuxiliarySKU:          entry.auxiliarySKU,
+				AcceleratedNetworking: entry.acceleratedNetworking,
+			}
+			errList := validateNetworkProfile(networkProfile, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
 func TestValidateDataDisks(t *testing.T) {
 	fldPath := field.NewPath("providerSpec.properties.storageProfile.dataDisks")
 	table := []struct {
@@ -381,6 +689,80 @@ func TestValidateAvailabilityAndScalingConfig(t *testing.T) {
 	}
 }
 
+func TestValidatePlatformFaultDomain(t *testing.T) {
+	testVMScaleSet := api.AzureSubResource{ID: "vm-scale-set-1"}
+	fldPath := field.NewPath("providerSpec.properties")
+	table := []struct {
+		description         string
+		vmScaleSet          *api.AzureSubResource
+		platformFaultDomain *int32
+		expectedErrors      int
+		matcher             gomegatypes.GomegaMatcher
+	}{
+		{"should allow platformFaultDomain together with virtualMachineScaleSet", &testVMScaleSet, to.Ptr[int32](2), 0, nil},
+		{"should forbid platformFaultDomain without virtualMachineScaleSet", nil, to.Ptr[int32](0), 1,
+			ContainElement(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.platformFaultDomain")})))},
+		{"should forbid negative platformFaultDomain", &testVMScaleSet, to.Ptr[int32](-1), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.platformFaultDomain")})))},
+		{"should forbid platformFaultDomain greater than 2", &testVMScaleSet, to.Ptr[int32](3), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.platformFaultDomain")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			vmProperties := api.AzureVirtualMachineProperties{
+				Zone:                   pointer.Int(1),
+				VirtualMachineScaleSet: entry.vmScaleSet,
+				PlatformFaultDomain:    entry.platformFaultDomain,
+			}
+			if entry.vmScaleSet != nil {
+				vmProperties.Zone = nil
+			}
+			errList := validateAvailabilityAndScalingConfig(vmProperties, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateZones(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties")
+	table := []struct {
+		description    string
+		zone           *int
+		zones          []int
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow only setting of zones", nil, []int{1, 2, 3}, 0, nil},
+		{
+			"should forbid setting both zone and zones", pointer.Int(1), []int{1, 2}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.zone|.zones")}))),
+		},
+		{
+			"should forbid duplicate and non positive zones", nil, []int{1, 1, 0}, 2,
+			ConsistOf(
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeDuplicate), "Field": Equal("providerSpec.properties.zones[1]")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.zones[2]")})),
+			),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateZones(entry.zone, entry.zones, fldPath)
+			g.Expect(len(errList)).To(Equal(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
 func TestValidateStorageImageRef(t *testing.T) {
 	const (
 		testImageID                 = "storage-image-ID-test-1"
@@ -476,6 +858,39 @@ func TestValidateStorageImageRef(t *testing.T) {
 	}
 }
 
+func TestValidateStorageImageRefPlan(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.imageReference")
+	table := []struct {
+		description    string
+		plan           *api.AzurePurchasePlan
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow no plan", nil, 0, nil},
+		{"should allow a complete plan", &api.AzurePurchasePlan{Name: "greatest", Product: "gardenlinux", Publisher: "sap"}, 0, nil},
+		{"should require all fields of the plan", &api.AzurePurchasePlan{Name: "greatest"}, 2,
+			ConsistOf(
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.storageProfile.imageReference.plan.product")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.storageProfile.imageReference.plan.publisher")})),
+			)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			imageRef := api.AzureImageReference{
+				ID:   "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/images/gardenlinux",
+				Plan: entry.plan,
+			}
+			errList := validateStorageImageRef(imageRef, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
 func TestValidateCloudConfiguration(t *testing.T) {
 	fldPath := field.NewPath("providerSpec.properties.cloudConfiguration")
 	table := []struct {
@@ -487,6 +902,8 @@ func TestValidateCloudConfiguration(t *testing.T) {
 		{description: "cloud configuration is set to AzureGov", cloudConfiguration: &api.CloudConfiguration{Name: api.CloudNameGov}},
 		{description: "cloud configuration is set to AzureChina", cloudConfiguration: &api.CloudConfiguration{Name: api.CloudNameChina}},
 		{description: "cloud configuration is set to AzurePublic", cloudConfiguration: &api.CloudConfiguration{Name: api.CloudNamePublic}},
+		{description: "cloud configuration is set to AzureChinaCloud", cloudConfiguration: &api.CloudConfiguration{Name: api.CloudNameAzureChinaCloud}},
+		{description: "cloud configuration is set to AzureUSGovernment", cloudConfiguration: &api.CloudConfiguration{Name: api.CloudNameAzureUSGovernment}},
 		{
 			description:        "cloud configuration is set to an unsupported name",
 			cloudConfiguration: &api.CloudConfiguration{Name: "foo"},
@@ -542,29 +959,694 @@ func TestValidateSecurityProfile(t *testing.T) {
 	}
 }
 
-func TestValidateTags(t *testing.T) {
-	fldPath := field.NewPath("providerSpec.tags")
-	tags := map[string]string{
-		"Name":                                             "shootns",
-		"worker.gardener.cloud_pool":                       "worker-pool-0",
-		"worker.garden.sapcloud.io_group":                  "worker-pool-0",
-		"worker.gardener.cloud_cri-name":                   "containerd",
-		"worker.gardener.cloud_system-components":          "true",
-		"networking.gardener.cloud_node-local-dns-enabled": "true",
+func TestValidateLicenseType(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties")
+	licenseTypeErrMatcher := PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.licenseType")}))
+	table := []struct {
+		description string
+		licenseType *string
+		matcher     gomegatypes.GomegaMatcher
+	}{
+		{"should allow licenseType to be unset", nil, Not(ContainElement(licenseTypeErrMatcher))},
+		{"should allow a non-empty licenseType", to.Ptr("RHEL_BYOS"), Not(ContainElement(licenseTypeErrMatcher))},
+		{"should forbid an empty licenseType", to.Ptr(" "), ContainElement(licenseTypeErrMatcher)},
 	}
+
 	g := NewWithT(t)
-	errList := validateTags(tags, fldPath)
-	g.Expect(len(errList)).To(Equal(2))
-	g.Expect(errList).To(ConsistOf(
-		PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.tags.kubernetes.io-cluster-")})),
-		PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.tags.kubernetes.io-role-")})),
-	))
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateProperties(api.AzureVirtualMachineProperties{LicenseType: entry.licenseType}, fldPath)
+			g.Expect(errList).To(entry.matcher)
+		})
+	}
 }
 
-func createSecret(clientID, clientSecret, workloadIdentityTokenFile, subscriptionID, tenantID, userData string) *corev1.Secret {
-	data := make(map[string][]byte, 4)
-	if !utils.IsEmptyString(clientID) {
-		data["clientID"] = encodeAndConvertToBytes(clientID)
+func TestValidateImageRefSubscriptionAndTenant(t *testing.T) {
+	const (
+		subscriptionID = "00000000-0000-0000-0000-000000000001"
+		imageID        = "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/images/providers/Microsoft.Compute/images/gardenlinux"
+	)
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.imageReference")
+	table := []struct {
+		description    string
+		imageRef       api.AzureImageReference
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow subscriptionID and tenantID with a matching image id", api.AzureImageReference{ID: imageID, SubscriptionID: to.Ptr(subscriptionID), TenantID: to.Ptr("tenant-1")}, 0, nil},
+		{
+			"should forbid subscriptionID and tenantID without image id", api.AzureImageReference{URN: to.Ptr("sap:gardenlinux:greatest:934.8.0"), SubscriptionID: to.Ptr(subscriptionID), TenantID: to.Ptr("tenant-1")}, 2,
+			ConsistOf(
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.imageReference.subscriptionID")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.imageReference.tenantID")})),
+			),
+		},
+		{
+			"should forbid subscriptionID which does not match the image id", api.AzureImageReference{ID: imageID, SubscriptionID: to.Ptr("00000000-0000-0000-0000-000000000002")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.storageProfile.imageReference.subscriptionID")}))),
+		},
+		{
+			"should forbid an empty tenantID", api.AzureImageReference{ID: imageID, TenantID: to.Ptr("")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.storageProfile.imageReference.tenantID")}))),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateStorageImageRef(entry.imageRef, fldPath)
+			g.Expect(len(errList)).To(Equal(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateHyperVGeneration(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties")
+	hyperVGenerationField := "providerSpec.properties.storageProfile.imageReference.hyperVGeneration"
+	table := []struct {
+		description      string
+		hyperVGeneration *string
+		securityType     *string
+		matcher          gomegatypes.GomegaMatcher
+	}{
+		{"should allow hyperVGeneration to be unset", nil, nil, Not(ContainElement(PointTo(MatchFields(IgnoreExtras, Fields{"Field": Equal(hyperVGenerationField)}))))},
+		{"should allow V2 with a security type", to.Ptr("V2"), to.Ptr(string(armcompute.SecurityTypesTrustedLaunch)), Not(ContainElement(PointTo(MatchFields(IgnoreExtras, Fields{"Field": Equal(hyperVGenerationField)}))))},
+		{"should forbid an unknown hyperVGeneration", to.Ptr("V3"), nil, ContainElement(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal(hyperVGenerationField)})))},
+		{"should forbid V1 with a security type", to.Ptr("V1"), to.Ptr(string(armcompute.SecurityTypesTrustedLaunch)), ContainElement(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal(hyperVGenerationField)})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			properties := api.AzureVirtualMachineProperties{
+				StorageProfile: api.AzureStorageProfile{ImageReference: api.AzureImageReference{URN: to.Ptr("sap:gardenlinux:greatest:934.8.0"), HyperVGeneration: entry.hyperVGeneration}},
+			}
+			if entry.securityType != nil {
+				properties.SecurityProfile = &api.AzureSecurityProfile{SecurityType: entry.securityType}
+			}
+			errList := validateProperties(properties, fldPath)
+			g.Expect(errList).To(entry.matcher)
+		})
+	}
+}
+
+func TestValidateDataDiskSnapshotID(t *testing.T) {
+	const snapshotID = "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/snapshots/snapshot-0"
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.dataDisks")
+	table := []struct {
+		description    string
+		snapshotID     *string
+		imageRef       *api.AzureImageReference
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow a snapshot resource ID", to.Ptr(snapshotID), nil, 0, nil},
+		{"should forbid an ID which is not a snapshot resource ID", to.Ptr("/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/disks/disk-0"), nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.snapshotID")})))},
+		{"should forbid setting both imageRef and snapshotID", to.Ptr(snapshotID), &api.AzureImageReference{URN: to.Ptr("sap:gardenlinux:greatest:1.2.3")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.imageRef|.snapshotID")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			dataDisks := []api.AzureDataDisk{{Lun: 0, DiskSizeGB: 50, StorageAccountType: "StandardSSD_LRS", SnapshotID: entry.snapshotID, ImageRef: entry.imageRef}}
+			errList := validateDataDisks(dataDisks, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateOSDiskRestoreSource(t *testing.T) {
+	const (
+		snapshotID     = "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/snapshots/snapshot-0"
+		restorePointID = "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/restorePointCollections/rpc-0/restorePoints/rp-0/diskRestorePoints/drp-0"
+	)
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.osDisk")
+	table := []struct {
+		description    string
+		createOption   string
+		snapshotID     *string
+		restorePointID *string
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow restoring from a snapshot", api.OSDiskCreateOptionRestore, to.Ptr(snapshotID), nil, 0, nil},
+		{"should allow restoring from a disk restore point", api.OSDiskCreateOptionRestore, nil, to.Ptr(restorePointID), 0, nil},
+		{"should forbid restoring without a source", api.OSDiskCreateOptionRestore, nil, nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.osDisk.snapshotID|.restorePointID")})))},
+		{"should forbid restoring from both a snapshot and a disk restore point", api.OSDiskCreateOptionRestore, to.Ptr(snapshotID), to.Ptr(restorePointID), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.osDisk.snapshotID|.restorePointID")})))},
+		{"should forbid a snapshotID which is not a snapshot resource ID", api.OSDiskCreateOptionRestore, to.Ptr(restorePointID), nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.storageProfile.osDisk.snapshotID")})))},
+		{"should forbid a restorePointID which is not a disk restore point resource ID", api.OSDiskCreateOptionRestore, nil, to.Ptr(snapshotID), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.storageProfile.osDisk.restorePointID")})))},
+		{"should forbid a source if the OSDisk is not restored", "FromImage", to.Ptr(snapshotID), nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.osDisk.snapshotID|.restorePointID")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			osDisk := api.AzureOSDisk{DiskSizeGB: 50, CreateOption: entry.createOption, SnapshotID: entry.snapshotID, RestorePointID: entry.restorePointID}
+			errList := validateOSDisk(osDisk, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateDataDiskMaxShares(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.dataDisks")
+	table := []struct {
+		description        string
+		maxShares          int32
+		storageAccountType string
+		caching            string
+		expectedErrors     int
+		matcher            gomegatypes.GomegaMatcher
+	}{
+		{"should allow maxShares of 1 for any storage account type", 1, string(armcompute.StorageAccountTypesStandardLRS), string(armcompute.CachingTypesReadWrite), 0, nil},
+		{"should allow shared Premium disk without caching", 3, string(armcompute.StorageAccountTypesPremiumLRS), "", 0, nil},
+		{"should forbid maxShares less than 1", 0, string(armcompute.StorageAccountTypesPremiumLRS), "", 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.maxShares")})))},
+		{"should forbid shared Standard HDD disk", 2, string(armcompute.StorageAccountTypesStandardLRS), string(armcompute.CachingTypesNone), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.maxShares")})))},
+		{"should forbid shared disk with caching", 2, string(armcompute.StorageAccountTypesPremiumZRS), string(armcompute.CachingTypesReadOnly), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.maxShares")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			dataDisks := []api.AzureDataDisk{{Lun: 0, DiskSizeGB: 50, StorageAccountType: entry.storageAccountType, Caching: entry.caching, MaxShares: to.Ptr(entry.maxShares)}}
+			errList := validateDataDisks(dataDisks, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateDataDiskDeleteOption(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.dataDisks")
+	table := []struct {
+		description    string
+		deleteOption   *string
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow no delete option", nil, 0, nil},
+		{"should allow Delete", to.Ptr(string(armcompute.DiskDeleteOptionTypesDelete)), 0, nil},
+		{"should allow Detach", to.Ptr(string(armcompute.DiskDeleteOptionTypesDetach)), 0, nil},
+		{"should forbid unknown delete option", to.Ptr("Keep"), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.deleteOption")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			dataDisks := []api.AzureDataDisk{{Lun: 0, DiskSizeGB: 50, StorageAccountType: "StandardSSD_LRS", DeleteOption: entry.deleteOption}}
+			errList := validateDataDisks(dataDisks, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateStorageAccountType(t *testing.T) {
+	table := []struct {
+		description         string
+		osDiskStorageType   string
+		dataDiskStorageType string
+		expectedErrors      int
+		matcher             gomegatypes.GomegaMatcher
+	}{
+		{"should allow locally redundant storage account types", string(armcompute.StorageAccountTypesPremiumLRS), string(armcompute.StorageAccountTypesStandardSSDLRS), 0, nil},
+		{"should allow zone-redundant storage account types", string(armcompute.StorageAccountTypesPremiumZRS), string(armcompute.StorageAccountTypesStandardSSDZRS), 0, nil},
+		{"should forbid unknown OSDisk storage account type", "Standard_ZRS", string(armcompute.StorageAccountTypesStandardSSDZRS), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.storageProfile.osDisk.managedDisk.storageAccountType")})))},
+		{"should forbid unknown DataDisk storage account type", string(armcompute.StorageAccountTypesPremiumZRS), "PremiumV2_ZRS", 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.storageAccountType")})))},
+	}
+
+	g := NewWithT(t)
+	fldPath := field.NewPath("providerSpec.properties.storageProfile")
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			osDisk := api.AzureOSDisk{CreateOption: "FromImage", DiskSizeGB: 50, ManagedDisk: api.AzureManagedDiskParameters{StorageAccountType: entry.osDiskStorageType}}
+			errList := validateOSDisk(osDisk, fldPath.Child("osDisk"))
+			dataDisks := []api.AzureDataDisk{{Lun: 0, DiskSizeGB: 50, StorageAccountType: entry.dataDiskStorageType}}
+			errList = append(errList, validateDataDisks(dataDisks, fldPath.Child("dataDisks"))...)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateDiskTier(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.dataDisks.tier")
+	table := []struct {
+		description        string
+		tier               *string
+		storageAccountType string
+		expectedErrors     int
+		matcher            gomegatypes.GomegaMatcher
+	}{
+		{"should allow no tier", nil, string(armcompute.StorageAccountTypesStandardLRS), 0, nil},
+		{"should allow a Premium SSD tier for Premium_LRS", to.Ptr("P30"), string(armcompute.StorageAccountTypesPremiumLRS), 0, nil},
+		{"should allow a Premium SSD tier for Premium_ZRS", to.Ptr("P80"), string(armcompute.StorageAccountTypesPremiumZRS), 0, nil},
+		{"should forbid an unknown tier", to.Ptr("P5"), string(armcompute.StorageAccountTypesPremiumLRS), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.tier")})))},
+		{"should forbid a tier for non Premium SSD storage account types", to.Ptr("P30"), string(armcompute.StorageAccountTypesStandardSSDLRS), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.tier")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateDiskTier(entry.tier, entry.storageAccountType, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateOSDiskNameTemplate(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.osDisk.nameTemplate")
+	table := []struct {
+		description    string
+		nameTemplate   *string
+		expectedErrors int
+	}{
+		{"should allow no name template", nil, 0},
+		{"should allow name template with placeholder", to.Ptr("{vmName}-root"), 0},
+		{"should allow name template with prefix and suffix", to.Ptr("os_{vmName}.disk"), 0},
+		{"should forbid name template without placeholder", to.Ptr("root-disk"), 1},
+		{"should forbid name template with invalid characters", to.Ptr("{vmName}/root"), 1},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateOSDiskNameTemplate(entry.nameTemplate, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.expectedErrors > 0 {
+				g.Expect(errList).To(HaveEach(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.storageProfile.osDisk.nameTemplate")}))))
+			}
+		})
+	}
+}
+
+func TestValidateDataDiskNameTemplate(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.storageProfile.dataDiskNameTemplate")
+	table := []struct {
+		description    string
+		nameTemplate   *string
+		expectedErrors int
+	}{
+		{"should allow no name template", nil, 0},
+		{"should allow name template with all placeholders", to.Ptr("{vmName}-{name}-{lun}"), 0},
+		{"should allow name template without data disk name placeholder", to.Ptr("{vmName}_data{lun}"), 0},
+		{"should forbid name template without lun placeholder", to.Ptr("{vmName}-{name}"), 1},
+		{"should forbid name template without vm name placeholder", to.Ptr("{name}-{lun}"), 1},
+		{"should forbid name template with repeated vm name placeholder", to.Ptr("{vmName}-{vmName}-{lun}"), 1},
+		{"should forbid name template with invalid characters", to.Ptr("{vmName}#{lun}"), 1},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateDataDiskNameTemplate(entry.nameTemplate, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.expectedErrors > 0 {
+				g.Expect(errList).To(HaveEach(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.storageProfile.dataDiskNameTemplate")}))))
+			}
+		})
+	}
+}
+
+func TestValidateWriteAccelerator(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.storageProfile")
+	table := []struct {
+		description        string
+		storageAccountType string
+		caching            string
+		expectedErrors     int
+	}{
+		{"should allow write accelerator for Premium storage with caching None", string(armcompute.StorageAccountTypesPremiumLRS), string(armcompute.CachingTypesNone), 0},
+		{"should forbid write accelerator for non Premium storage", string(armcompute.StorageAccountTypesStandardSSDLRS), string(armcompute.CachingTypesNone), 1},
+		{"should forbid write accelerator with caching ReadWrite", string(armcompute.StorageAccountTypesPremiumZRS), string(armcompute.CachingTypesReadWrite), 1},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			osDisk := api.AzureOSDisk{
+				CreateOption:            "FromImage",
+				DiskSizeGB:              50,
+				Caching:                 entry.caching,
+				ManagedDisk:             api.AzureManagedDiskParameters{StorageAccountType: entry.storageAccountType},
+				WriteAcceleratorEnabled: to.Ptr(true),
+			}
+			errList := validateOSDisk(osDisk, fldPath.Child("osDisk"))
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.expectedErrors > 0 {
+				g.Expect(errList).To(HaveEach(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.osDisk.writeAcceleratorEnabled")}))))
+			}
+
+			dataDisks := []api.AzureDataDisk{{Lun: 0, DiskSizeGB: 50, Caching: entry.caching, StorageAccountType: entry.storageAccountType, WriteAcceleratorEnabled: to.Ptr(true)}}
+			errList = validateDataDisks(dataDisks, fldPath.Child("dataDisks"))
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.expectedErrors > 0 {
+				g.Expect(errList).To(HaveEach(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.dataDisks.writeAcceleratorEnabled")}))))
+			}
+		})
+	}
+}
+
+func TestValidatePatchSettings(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.osProfile.patchSettings")
+	table := []struct {
+		description    string
+		isWindows      bool
+		patchSettings  *api.AzurePatchSettings
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow nil patch settings", false, nil, 0, nil},
+		{"should allow valid linux patch settings", false, &api.AzurePatchSettings{PatchMode: to.Ptr("AutomaticByPlatform"), AssessmentMode: to.Ptr("AutomaticByPlatform"), BypassPlatformSafetyChecks: to.Ptr(true)}, 0, nil},
+		{"should allow valid windows patch settings", true, &api.AzurePatchSettings{PatchMode: to.Ptr("AutomaticByOS"), AssessmentMode: to.Ptr("ImageDefault")}, 0, nil},
+		{"should forbid windows patch mode for linux", false, &api.AzurePatchSettings{PatchMode: to.Ptr("AutomaticByOS")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.osProfile.patchSettings.patchMode")})))},
+		{"should forbid invalid assessment mode", true, &api.AzurePatchSettings{AssessmentMode: to.Ptr("Manual")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.osProfile.patchSettings.assessmentMode")})))},
+		{"should forbid bypassPlatformSafetyChecks if patch mode is not AutomaticByPlatform", true, &api.AzurePatchSettings{PatchMode: to.Ptr("Manual"), BypassPlatformSafetyChecks: to.Ptr(true)}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.osProfile.patchSettings.bypassPlatformSafetyChecks")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validatePatchSettings(entry.patchSettings, entry.isWindows, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateDiskControllerType(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.storageProfile")
+	table := []struct {
+		description        string
+		diskControllerType *string
+		hyperVGeneration   *string
+		expectedErrors     int
+		matcher            gomegatypes.GomegaMatcher
+	}{
+		{"should allow no disk controller type", nil, nil, 0, nil},
+		{"should allow SCSI", to.Ptr("SCSI"), to.Ptr("V1"), 0, nil},
+		{"should allow NVMe", to.Ptr("NVMe"), to.Ptr("V2"), 0, nil},
+		{"should forbid unknown disk controller type", to.Ptr("IDE"), nil, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.storageProfile.diskControllerType")})))},
+		{"should forbid NVMe for hyperVGeneration V1 images", to.Ptr("NVMe"), to.Ptr("V1"), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.storageProfile.diskControllerType")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			storageProfile := api.AzureStorageProfile{
+				ImageReference:     api.AzureImageReference{HyperVGeneration: entry.hyperVGeneration},
+				DiskControllerType: entry.diskControllerType,
+			}
+			errList := validateDiskControllerType(storageProfile, fldPath.Child("diskControllerType"))
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateScheduledEventsProfile(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.scheduledEventsProfile")
+	table := []struct {
+		description      string
+		notBeforeTimeout *string
+		expectedErrors   int
+	}{
+		{"should allow no notBeforeTimeout", nil, 0},
+		{"should allow notBeforeTimeout of 5 minutes", to.Ptr("PT5M"), 0},
+		{"should allow notBeforeTimeout of 15 minutes in seconds", to.Ptr("PT900S"), 0},
+		{"should allow notBeforeTimeout combining minutes and seconds", to.Ptr("PT7M30S"), 0},
+		{"should forbid notBeforeTimeout below 5 minutes", to.Ptr("PT4M"), 1},
+		{"should forbid notBeforeTimeout above 15 minutes", to.Ptr("PT1H"), 1},
+		{"should forbid notBeforeTimeout which is not an ISO 8601 duration", to.Ptr("10m"), 1},
+		{"should forbid empty ISO 8601 duration", to.Ptr("PT"), 1},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			scheduledEventsProfile := &api.AzureScheduledEventsProfile{
+				TerminateNotificationProfile: &api.AzureTerminateNotificationProfile{Enabled: true, NotBeforeTimeout: entry.notBeforeTimeout},
+			}
+			errList := validateScheduledEventsProfile(scheduledEventsProfile, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.expectedErrors > 0 {
+				g.Expect(errList).To(HaveEach(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.scheduledEventsProfile.terminateNotificationProfile.notBeforeTimeout")}))))
+			}
+		})
+	}
+}
+
+func TestValidateFailedCreationCleanup(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.failedCreationCleanup")
+	table := []struct {
+		description       string
+		policy            string
+		retentionDuration *string
+		expectedErrors    int
+	}{
+		{"should allow no policy", "", nil, 0},
+		{"should allow policy always", api.FailedCreationCleanupPolicyAlways, nil, 0},
+		{"should allow policy never with retention duration", api.FailedCreationCleanupPolicyNever, to.Ptr("24h"), 0},
+		{"should allow policy on-permanent-error", api.FailedCreationCleanupPolicyOnPermanentError, to.Ptr("90m"), 0},
+		{"should forbid unknown policy", "sometimes", nil, 1},
+		{"should forbid retention duration which can not be parsed", api.FailedCreationCleanupPolicyNever, to.Ptr("P1D"), 1},
+		{"should forbid retention duration which is not positive", api.FailedCreationCleanupPolicyNever, to.Ptr("0s"), 1},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			failedCreationCleanup := &api.AzureFailedCreationCleanup{Policy: entry.policy, RetentionDuration: entry.retentionDuration}
+			errList := validateFailedCreationCleanup(failedCreationCleanup, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+		})
+	}
+}
+
+func TestValidateDiagnosticsProfile(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.diagnosticsProfile")
+	table := []struct {
+		description        string
+		diagnosticsProfile *api.AzureDiagnosticsProfile
+		expectedErrors     int
+		matcher            gomegatypes.GomegaMatcher
+	}{
+		{"should allow no diagnostics profile", nil, 0, nil},
+		{"should allow managed storage", &api.AzureDiagnosticsProfile{Enabled: true}, 0, nil},
+		{"should allow a custom storage account", &api.AzureDiagnosticsProfile{Enabled: true, StorageURI: to.Ptr("https://bootdiag0.blob.core.windows.net/")}, 0, nil},
+		{"should allow a custom storage account in a sovereign cloud", &api.AzureDiagnosticsProfile{Enabled: true, StorageURI: to.Ptr("https://bootdiag0.blob.core.chinacloudapi.cn/")}, 0, nil},
+		{"should forbid a storage URI if boot diagnostics are disabled", &api.AzureDiagnosticsProfile{StorageURI: to.Ptr("https://bootdiag0.blob.core.windows.net/")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.diagnosticsProfile.storageURI")})))},
+		{"should forbid a storage URI without https", &api.AzureDiagnosticsProfile{Enabled: true, StorageURI: to.Ptr("http://bootdiag0.blob.core.windows.net/")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.diagnosticsProfile.storageURI")})))},
+		{"should forbid a storage URI which is not a blob endpoint", &api.AzureDiagnosticsProfile{Enabled: true, StorageURI: to.Ptr("https://bootdiag0.file.core.windows.net/")}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.diagnosticsProfile.storageURI")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateDiagnosticsProfile(entry.diagnosticsProfile, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateGalleryApplications(t *testing.T) {
+	const (
+		appVersionID0 = "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/galleries/gallery-0/applications/app-0/versions/1.0.0"
+		appVersionID1 = "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/galleries/gallery-0/applications/app-1/versions/2.1.0"
+	)
+	fldPath := field.NewPath("providerSpec.properties.galleryApplications")
+	table := []struct {
+		description         string
+		galleryApplications []api.AzureGalleryApplication
+		expectedErrors      int
+		matcher             gomegatypes.GomegaMatcher
+	}{
+		{"should allow no gallery applications", nil, 0, nil},
+		{"should allow valid gallery applications", []api.AzureGalleryApplication{{PackageReferenceID: appVersionID0, Order: to.Ptr[int32](1)}, {PackageReferenceID: appVersionID1, TreatFailureAsDeploymentFailure: to.Ptr(true)}}, 0, nil},
+		{"should require packageReferenceID", []api.AzureGalleryApplication{{Order: to.Ptr[int32](1)}}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.galleryApplications[0].packageReferenceID")})))},
+		{"should forbid packageReferenceID which is not an application version", []api.AzureGalleryApplication{{PackageReferenceID: "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/galleries/gallery-0/applications/app-0"}}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.galleryApplications[0].packageReferenceID")})))},
+		{"should forbid duplicate packageReferenceIDs", []api.AzureGalleryApplication{{PackageReferenceID: appVersionID0}, {PackageReferenceID: appVersionID0}}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeDuplicate), "Field": Equal("providerSpec.properties.galleryApplications[1].packageReferenceID")})))},
+		{"should forbid negative order", []api.AzureGalleryApplication{{PackageReferenceID: appVersionID0, Order: to.Ptr[int32](-1)}}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.galleryApplications[0].order")})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateGalleryApplications(entry.galleryApplications, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateExtensions(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.extensions")
+	validExtension := api.AzureVMExtension{Name: "ext-0", Publisher: "Microsoft.Azure.ActiveDirectory", Type: "AADSSHLoginForLinux", TypeHandlerVersion: "1.0"}
+	table := []struct {
+		description    string
+		extensions     []api.AzureVMExtension
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should succeed when no extensions are set", nil, 0, nil},
+		{"should succeed for a valid extension", []api.AzureVMExtension{validExtension}, 0, nil},
+		{
+			"should forbid missing required fields", []api.AzureVMExtension{{Name: "ext-0"}}, 3,
+			ConsistOf(
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.extensions[0].publisher")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.extensions[0].type")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.extensions[0].typeHandlerVersion")})),
+			),
+		},
+		{
+			"should forbid duplicate extension names", []api.AzureVMExtension{validExtension, validExtension}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeDuplicate), "Field": Equal("providerSpec.properties.extensions[1].name")}))),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateExtensions(entry.extensions, fldPath)
+			g.Expect(len(errList)).To(Equal(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateApplicationHealthProfile(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.properties.applicationHealthProfile")
+	table := []struct {
+		description    string
+		profile        *api.AzureApplicationHealthProfile
+		extensions     []api.AzureVMExtension
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should succeed when profile is not set", nil, nil, 0, nil},
+		{"should not validate a disabled profile", &api.AzureApplicationHealthProfile{Protocol: "udp"}, nil, 0, nil},
+		{"should succeed for a valid http probe", &api.AzureApplicationHealthProfile{Enabled: true, Protocol: api.ApplicationHealthProtocolHTTP, RequestPath: to.Ptr("/healthz")}, nil, 0, nil},
+		{"should succeed for a valid tcp probe", &api.AzureApplicationHealthProfile{Enabled: true, Protocol: api.ApplicationHealthProtocolTCP, Port: to.Ptr[int32](10250)}, nil, 0, nil},
+		{
+			"should forbid unsupported protocol", &api.AzureApplicationHealthProfile{Enabled: true, Protocol: "udp", Port: to.Ptr[int32](53)}, nil, 2,
+			ConsistOf(
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeNotSupported), "Field": Equal("providerSpec.properties.applicationHealthProfile.protocol")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.applicationHealthProfile.requestPath")})),
+			),
+		},
+		{
+			"should forbid tcp probe without port and with requestPath", &api.AzureApplicationHealthProfile{Enabled: true, Protocol: api.ApplicationHealthProtocolTCP, RequestPath: to.Ptr("/healthz")}, nil, 2,
+			ConsistOf(
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.properties.applicationHealthProfile.port")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.applicationHealthProfile.requestPath")})),
+			),
+		},
+		{
+			"should forbid invalid port, interval and number of probes", &api.AzureApplicationHealthProfile{Enabled: true, Protocol: api.ApplicationHealthProtocolHTTPS, RequestPath: to.Ptr("/healthz"), Port: to.Ptr[int32](70000), IntervalInSeconds: to.Ptr[int32](0), NumberOfProbes: to.Ptr[int32](-1)}, nil, 3,
+			ConsistOf(
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.applicationHealthProfile.port")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.applicationHealthProfile.intervalInSeconds")})),
+				PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.properties.applicationHealthProfile.numberOfProbes")})),
+			),
+		},
+		{
+			"should forbid an extension using the reserved name", &api.AzureApplicationHealthProfile{Enabled: true, Protocol: api.ApplicationHealthProtocolHTTP, RequestPath: to.Ptr("/healthz")},
+			[]api.AzureVMExtension{{Name: api.ApplicationHealthExtensionName}}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeForbidden), "Field": Equal("providerSpec.properties.applicationHealthProfile")}))),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateApplicationHealthProfile(entry.profile, entry.extensions, fldPath)
+			g.Expect(len(errList)).To(Equal(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.tags")
+	tags := map[string]string{
+		"Name":                                             "shootns",
+		"worker.gardener.cloud_pool":                       "worker-pool-0",
+		"worker.garden.sapcloud.io_group":                  "worker-pool-0",
+		"worker.gardener.cloud_cri-name":                   "containerd",
+		"worker.gardener.cloud_system-components":          "true",
+		"networking.gardener.cloud_node-local-dns-enabled": "true",
+	}
+	g := NewWithT(t)
+	errList := validateTags(tags, fldPath)
+	g.Expect(len(errList)).To(Equal(2))
+	g.Expect(errList).To(ConsistOf(
+		PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.tags.kubernetes.io-cluster-")})),
+		PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.tags.kubernetes.io-role-")})),
+	))
+}
+
+func createSecret(clientID, clientSecret, workloadIdentityTokenFile, subscriptionID, tenantID, userData string) *corev1.Secret {
+	data := make(map[string][]byte, 4)
+	if !utils.IsEmptyString(clientID) {
+		data["clientID"] = encodeAndConvertToBytes(clientID)
 	}
 	if !utils.IsEmptyString(clientSecret) {
 		data["clientSecret"] = encodeAndConvertToBytes(clientSecret)
@@ -595,3 +1677,88 @@ func encodeAndConvertToBytes(value string) []byte {
 	base64.StdEncoding.Encode(dst, []byte(value))
 	return dst
 }
+
+func TestValidateAdditionalResourceGroups(t *testing.T) {
+	const resourceGroup = "rg-0"
+	fldPath := field.NewPath("providerSpec.additionalResourceGroups")
+	table := []struct {
+		description              string
+		additionalResourceGroups []string
+		expectedErrors           int
+		matcher                  gomegatypes.GomegaMatcher
+	}{
+		{"should succeed when no additional resource groups are set", nil, 0, nil},
+		{"should succeed for distinct resource groups", []string{"rg-disks", "rg-nics"}, 0, nil},
+		{
+			"should forbid empty resource groups", []string{""}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeRequired), "Field": Equal("providerSpec.additionalResourceGroups[0]")}))),
+		},
+		{
+			"should forbid the primary resource group", []string{"RG-0"}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeInvalid), "Field": Equal("providerSpec.additionalResourceGroups[0]")}))),
+		},
+		{
+			"should forbid duplicate resource groups", []string{"rg-disks", "RG-DISKS"}, 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeDuplicate), "Field": Equal("providerSpec.additionalResourceGroups[1]")}))),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateAdditionalResourceGroups(entry.additionalResourceGroups, resourceGroup, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
+
+func TestValidateTagConstraints(t *testing.T) {
+	fldPath := field.NewPath("providerSpec.tags")
+	mandatoryTags := map[string]string{"kubernetes.io-cluster-shoot--project--test": "1", "kubernetes.io-role-node": "1"}
+	withMandatoryTags := func(tags map[string]string) map[string]string {
+		for k, v := range mandatoryTags {
+			tags[k] = v
+		}
+		return tags
+	}
+	tooManyTags := make(map[string]string, utils.MaxTagsPerResource)
+	for i := 0; i < utils.MaxTagsPerResource-len(mandatoryTags); i++ {
+		tooManyTags[fmt.Sprintf("tag-%d", i)] = "value"
+	}
+	table := []struct {
+		description    string
+		tags           map[string]string
+		expectedErrors int
+		matcher        gomegatypes.GomegaMatcher
+	}{
+		{"should allow the mandatory tags", withMandatoryTags(map[string]string{}), 0, nil},
+		{"should forbid more tags than Azure allows together with the machine name tag", withMandatoryTags(tooManyTags), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeTooMany), "Field": Equal("providerSpec.tags")})))},
+		{"should forbid too long tag keys", withMandatoryTags(map[string]string{strings.Repeat("k", utils.MaxTagKeyLength+1): "value"}), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeTooLong)})))},
+		{"should forbid too long tag values", withMandatoryTags(map[string]string{"key": strings.Repeat("v", utils.MaxTagValueLength+1)}), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{"Type": Equal(field.ErrorTypeTooLong), "Field": Equal("providerSpec.tags[key]")})))},
+		{"should allow tag keys with forbidden characters since they are translated", withMandatoryTags(map[string]string{"kubernetes.io/arch": "amd64"}), 0, nil},
+		{"should allow the mandatory tags with forbidden characters since they are translated", map[string]string{"kubernetes.io/cluster/shoot--project--test": "1", "kubernetes.io/role/node": "1"}, 0, nil},
+		{"should forbid tag keys which are the same once translated", withMandatoryTags(map[string]string{"kubernetes.io/arch": "amd64", "kubernetes.io-arch": "arm64"}), 1,
+			ConsistOf(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Type":     Equal(field.ErrorTypeDuplicate),
+				"Field":    Equal("providerSpec.tags[kubernetes.io/arch]"),
+				"BadValue": ContainSubstring(`"kubernetes.io-arch"`),
+			})))},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			errList := validateTags(entry.tags, fldPath)
+			g.Expect(errList).To(HaveLen(entry.expectedErrors))
+			if entry.matcher != nil {
+				g.Expect(errList).To(entry.matcher)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	mcmclientset "github.com/gardener/machine-controller-manager/pkg/client/clientset/versioned"
+	mcmoptions "github.com/gardener/machine-controller-manager/pkg/options"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/provider/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// leaderElectionLockName is the name of the lock which is acquired by the replica running the collector.
+const leaderElectionLockName = "machine-orphan-collector"
+
+// Options configure the orphan collector.
+type Options struct {
+	// Period is the period in which orphaned resources are collected. The collector is disabled if it is 0.
+	Period time.Duration
+	// Delete enables the deletion of orphaned resources. Otherwise, orphaned resources are only reported.
+	Delete bool
+}
+
+// Collector periodically searches for NICs, Disks and public IP addresses which carry the cluster and role tags of the
+// MachineClasses in a namespace but do not belong to any Machine, and reports or deletes them.
+// A resource is only considered as orphan if it is not attached and if it has been found as orphan in two consecutive
+// runs. This protects resources of machines which are being created while the collector runs.
+// If leader election is enabled, the collector only runs in the replica holding its own lock (see RunWithLeaderElection),
+// since the leader election of the machine controller is not exposed to the provider.
+type Collector struct {
+	factory       access.Factory
+	machineClient mcmclientset.Interface
+	kubeClient    kubernetes.Interface
+	namespace     string
+	opts          Options
+	// candidates are the keys of the orphaned resources which have been found in the previous run.
+	candidates sets.Set[string]
+}
+
+// orphan is a resource which does not belong to any Machine.
+type orphan struct {
+	helpers.MachineResource
+	connectConfig access.ConnectConfig
+}
+
+// NewCollector creates a new Collector for the MachineClasses and Machines in the passed namespace.
+func NewCollector(factory access.Factory, machineClient mcmclientset.Interface, kubeClient kubernetes.Interface, namespace string, opts Options) *Collector {
+	return &Collector{
+		factory:       factory,
+		machineClient: machineClient,
+		kubeClient:    kubeClient,
+		namespace:     namespace,
+		opts:          opts,
+		candidates:    sets.New[string](),
+	}
+}
+
+// NewControlClients creates the clients for the cluster hosting the Machine resources. The control kubeconfig is used
+// if it is set, falling back to the target kubeconfig as done by the machine controller.
+func NewControlClients(controlKubeconfig, targetKubeconfig string) (mcmclientset.Interface, kubernetes.Interface, error) {
+	kubeconfig := targetKubeconfig
+	switch controlKubeconfig {
+	case "":
+	case "inClusterConfig":
+		kubeconfig = ""
+	default:
+		kubeconfig = controlKubeconfig
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	config = rest.AddUserAgent(config, "machine-orphan-collector")
+	machineClient, err := mcmclientset.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return machineClient, kubeClient, nil
+}
+
+// RunWithLeaderElection runs the collector until the context is cancelled. If leader election is enabled, the collector
+// only runs while the lock in the namespace of the collector is held, so that orphans are collected by a single replica.
+// The leader election settings of the machine controller are used for the lock.
+func (c *Collector) RunWithLeaderElection(ctx context.Context, config mcmoptions.LeaderElectionConfiguration) error {
+	if !config.LeaderElect {
+		c.Run(ctx)
+		return nil
+	}
+	id, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	lock, err := resourcelock.New(config.ResourceLock, c.namespace, leaderElectionLockName, c.kubeClient.CoreV1(), c.kubeClient.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: id})
+	if err != nil {
+		return fmt.Errorf("failed to create leader election lock: %w", err)
+	}
+	logger := klog.FromContext(ctx).WithValues("namespace", c.namespace, "lock", leaderElectionLockName)
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   config.LeaseDuration.Duration,
+		RenewDeadline:   config.RenewDeadline.Duration,
+		RetryPeriod:     config.RetryPeriod.Duration,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: c.Run,
+			OnStoppedLeading: func() {
+				logger.Info("Stopped leading, orphan collector is stopped")
+			},
+		},
+	})
+	return nil
+}
+
+// Run collects orphaned resources periodically until the context is cancelled.
+func (c *Collector) Run(ctx context.Context) {
+	logger := klog.FromContext(ctx).WithValues("namespace", c.namespace)
+	ctx = klog.NewContext(ctx, logger)
+	logger.Info("Starting orphan collector", "period", c.opts.Period, "delete", c.opts.Delete)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.Collect(ctx); err != nil {
+			logger.Error(err, "Failed to collect orphaned resources")
+		}
+	}, c.opts.Period)
+}
+
+// Collect runs a single collection of orphaned resources.
+func (c *Collector) Collect(ctx context.Context) error {
+	logger := klog.FromContext(ctx)
+	machines, err := c.machineClient.MachineV1alpha1().Machines(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list machines: %w", err)
+	}
+	machineNames := sets.New[string]()
+	for _, machine := range machines.Items {
+		machineNames.Insert(strings.ToLower(machine.Name))
+	}
+	machineClasses, err := c.machineClient.MachineV1alpha1().MachineClasses(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list machine classes: %w", err)
+	}
+
+	// a resource is only an orphan if none of the MachineClasses associates it with an existing or unknown VM, since the
+	// VM name is derived from the naming conventions of the respective MachineClass.
+	orphans := make(map[string]orphan)
+	excluded := sets.New[string]()
+	for i := range machineClasses.Items {
+		machineClass := &machineClasses.Items[i]
+		secret, err := c.getMachineClassSecret(ctx, machineClass)
+		if err != nil {
+			logger.Error(err, "Skipping MachineClass for orphan collection", "machineClass", machineClass.Name)
+			continue
+		}
+		providerSpec, connectConfig, err := helpers.ExtractProviderSpecAndConnectConfig(machineClass, secret)
+		if err != nil {
+			logger.V(4).Info("Skipping MachineClass for orphan collection", "machineClass", machineClass.Name, "err", err)
+			continue
+		}
+		resources, err := helpers.ListMachineResources(ctx, c.factory, connectConfig, providerSpec)
+		if err != nil {
+			logger.Error(err, "Failed to list resources of MachineClass", "machineClass", machineClass.Name)
+			continue
+		}
+		for _, resource := range resources {
+			key := createResourceKey(connectConfig.SubscriptionID, resource)
+			if resource.Attached || utils.IsEmptyString(resource.VMName) || machineNames.Has(resource.VMName) {
+				excluded.Insert(key)
+				continue
+			}
+			if _, ok := orphans[key]; !ok {
+				orphans[key] = orphan{MachineResource: resource, connectConfig: connectConfig}
+			}
+		}
+	}
+
+	candidates := sets.New[string]()
+	for key, o := range orphans {
+		if excluded.Has(key) {
+			continue
+		}
+		candidates.Insert(key)
+		if !c.candidates.Has(key) {
+			logger.Info("Found orphaned resource, it is handled if it is still orphaned in the next run", "resource", key, "vm", o.VMName)
+			continue
+		}
+		c.handleOrphan(ctx, key, o)
+	}
+	c.candidates = candidates
+	return nil
+}
+
+func (c *Collector) handleOrphan(ctx context.Context, key string, o orphan) {
+	logger := klog.FromContext(ctx).WithValues("resource", key, "vm", o.VMName)
+	if !c.opts.Delete {
+		logger.Info("Orphaned resource is not deleted since deletion is disabled")
+		return
+	}
+	var err error
+	switch o.Type {
+	case utils.NetworkInterfacesResourceType:
+		err = c.deleteNIC(ctx, o)
+	case utils.DiskResourceType:
+		err = c.deleteDisk(ctx, o)
+	case utils.PublicIPAddressResourceType:
+		err = c.deletePublicIP(ctx, o)
+	default:
+		logger.Info("Orphaned resource is not deleted since deletion of its resource type is not supported", "type", o.Type)
+		return
+	}
+	if err != nil {
+		logger.Error(err, "Failed to delete orphaned resource")
+		return
+	}
+	logger.Info("Deleted orphaned resource")
+}
+
+func (c *Collector) deleteNIC(ctx context.Context, o orphan) error {
+	nicAccess, err := c.factory.GetNetworkInterfacesAccess(o.connectConfig)
+	if err != nil {
+		return err
+	}
+	return accesshelpers.DeleteNIC(ctx, nicAccess, o.ResourceGroup, o.Name)
+}
+
+func (c *Collector) deleteDisk(ctx context.Context, o orphan) error {
+	disksAccess, err := c.factory.GetDisksAccess(o.connectConfig)
+	if err != nil {
+		return err
+	}
+	return accesshelpers.DeleteDisk(ctx, disksAccess, o.ResourceGroup, o.Name)
+}
+
+func (c *Collector) deletePublicIP(ctx context.Context, o orphan) error {
+	publicIPAccess, err := c.factory.GetPublicIPAddressesAccess(o.connectConfig)
+	if err != nil {
+		return err
+	}
+	return accesshelpers.DeletePublicIPAddress(ctx, publicIPAccess, o.ResourceGroup, o.Name)
+}
+
+// getMachineClassSecret returns the secret of the MachineClass. As done by the machine controller, the data of the
+// credentials secret takes precedence over the data of the secret.
+func (c *Collector) getMachineClassSecret(ctx context.Context, machineClass *v1alpha1.MachineClass) (*corev1.Secret, error) {
+	secret := &corev1.Secret{Data: map[string][]byte{}}
+	for _, ref := range []*corev1.SecretReference{machineClass.SecretRef, machineClass.CredentialsSecretRef} {
+		if ref == nil {
+			continue
+		}
+		s, err := c.kubeClient.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secret %s/%s: %w", ref.Namespace, ref.Name, err)
+		}
+		for k, v := range s.Data {
+			secret.Data[k] = v
+		}
+	}
+	return secret, nil
+}
+
+func createResourceKey(subscriptionID string, resource helpers.MachineResource) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s/%s", subscriptionID, resource.ResourceGroup, resource.Type, resource.Name))
+}
@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	mcmfake "github.com/gardener/machine-controller-manager/pkg/client/clientset/versioned/fake"
+	mcmoptions "github.com/gardener/machine-controller-manager/pkg/options"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp/fakes"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+const (
+	testResourceGroupName = "test-rg"
+	testShootNs           = "test-ns"
+	testWorkerPool0Name   = "test-worker-pool-0"
+	testSecretName        = "test-secret"
+)
+
+func TestCollect(t *testing.T) {
+	const (
+		existingVMName = "vm-0"
+		orphanVMName   = "vm-1"
+	)
+	table := []struct {
+		description          string
+		deleteOrphans        bool
+		expectOrphansDeleted bool
+	}{
+		{"should only report orphaned resources if deletion is disabled", false, false},
+		{"should delete orphaned resources which have been found in two consecutive runs", true, true},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			ctx := context.Background()
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks("dd", 1).Build()
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, existingVMName).BuildAllResources())
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, orphanVMName).BuildWith(false, true, true, true, nil))
+			orphanPublicIPName := utils.CreatePublicIPName(orphanVMName)
+			clusterState.AddPublicIP(orphanPublicIPName, utils.CreateResourceTags(providerSpec.Tags), nil)
+			factory := createFakeFactory(g, clusterState)
+
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			machineClass.ObjectMeta = metav1.ObjectMeta{Namespace: testShootNs, Name: "test-machine-class"}
+			machineClass.SecretRef = &corev1.SecretReference{Namespace: testShootNs, Name: testSecretName}
+			machine := &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, existingVMName)}
+			secret := fakes.CreateProviderSecret()
+			secret.ObjectMeta = metav1.ObjectMeta{Namespace: testShootNs, Name: testSecretName}
+
+			collector := NewCollector(factory, mcmfake.NewSimpleClientset(machineClass, machine), kubefake.NewSimpleClientset(secret), testShootNs, Options{Delete: entry.deleteOrphans})
+			orphanNICName := utils.CreateNICName(orphanVMName)
+			orphanOSDiskName := utils.CreateOSDiskName(orphanVMName)
+
+			// orphans are never handled in the run in which they are found first.
+			g.Expect(collector.Collect(ctx)).To(Succeed())
+			g.Expect(clusterState.GetNIC(orphanNICName)).ToNot(BeNil())
+			g.Expect(clusterState.GetDisk(orphanOSDiskName)).ToNot(BeNil())
+			g.Expect(clusterState.GetPublicIP(orphanPublicIPName)).ToNot(BeNil())
+
+			g.Expect(collector.Collect(ctx)).To(Succeed())
+			g.Expect(clusterState.GetNIC(orphanNICName) == nil).To(Equal(entry.expectOrphansDeleted))
+			g.Expect(clusterState.GetDisk(orphanOSDiskName) == nil).To(Equal(entry.expectOrphansDeleted))
+			g.Expect(clusterState.GetPublicIP(orphanPublicIPName) == nil).To(Equal(entry.expectOrphansDeleted))
+			g.Expect(clusterState.GetNIC(utils.CreateNICName(existingVMName))).ToNot(BeNil())
+			g.Expect(clusterState.GetDisk(utils.CreateOSDiskName(existingVMName))).ToNot(BeNil())
+		})
+	}
+}
+
+func TestRunWithLeaderElection(t *testing.T) {
+	const orphanVMName = "vm-1"
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, orphanVMName).BuildWith(false, true, true, false, nil))
+	factory := createFakeFactory(g, clusterState)
+
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+	machineClass.ObjectMeta = metav1.ObjectMeta{Namespace: testShootNs, Name: "test-machine-class"}
+	machineClass.SecretRef = &corev1.SecretReference{Namespace: testShootNs, Name: testSecretName}
+	secret := fakes.CreateProviderSecret()
+	secret.ObjectMeta = metav1.ObjectMeta{Namespace: testShootNs, Name: testSecretName}
+	kubeClient := kubefake.NewSimpleClientset(secret)
+	collector := NewCollector(factory, mcmfake.NewSimpleClientset(machineClass), kubeClient, testShootNs, Options{Period: 10 * time.Millisecond, Delete: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- collector.RunWithLeaderElection(ctx, mcmoptions.LeaderElectionConfiguration{
+			LeaderElect:   true,
+			LeaseDuration: metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+			ResourceLock:  "leases",
+		})
+	}()
+
+	// the orphans are only collected by the replica holding the lock. The cluster state is only inspected once the
+	// collector has stopped, since it is not safe for concurrent use.
+	g.Eventually(func() error {
+		_, err := kubeClient.CoordinationV1().Leases(testShootNs).Get(ctx, leaderElectionLockName, metav1.GetOptions{})
+		return err
+	}).Should(Succeed())
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+	g.Eventually(done).Should(Receive(BeNil()))
+	g.Expect(clusterState.GetNIC(utils.CreateNICName(orphanVMName))).To(BeNil())
+}
+
+func createFakeFactory(g *WithT, clusterState *fakes.ClusterState) *fakes.Factory {
+	factory := fakes.NewFactory(clusterState.ProviderSpec.ResourceGroup)
+	resourceGraphAccess, err := factory.NewResourceGraphAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	nicAccess, err := factory.NewNICAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	diskAccess, err := factory.NewDiskAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	publicIPAccess, err := factory.NewPublicIPAddressAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	factory.
+		WithResourceGraphAccess(resourceGraphAccess).
+		WithNetworkInterfacesAccess(nicAccess).
+		WithDisksAccess(diskAccess).
+		WithPublicIPAddressesAccess(publicIPAccess)
+	return factory
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instrument
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/version"
+)
+
+// BuildInfo is a gauge with the constant value 1 whose labels describe the build of the provider, so that operators
+// can verify which provider build and Azure SDK are running.
+var BuildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "mcm",
+	Subsystem: "provider_azure",
+	Name:      "build_info",
+	Help:      "Build information of the provider, partitioned by version, git SHA, go version and the versions of the Azure SDK and the machine-controller-manager.",
+}, []string{"version", "git_sha", "go_version", "azcore_version", "armcompute_version", "armnetwork_version", "mcm_version"})
+
+// RecordBuildInfo sets the BuildInfo gauge vec metric for the passed build information.
+func RecordBuildInfo(info version.Info) {
+	BuildInfo.WithLabelValues(
+		info.Version,
+		info.GitSHA,
+		info.GoVersion,
+		info.ModuleVersion("github.com/Azure/azure-sdk-for-go/sdk/azcore"),
+		info.ModuleVersion("github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"),
+		info.ModuleVersion("github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"),
+		info.ModuleVersion("github.com/gardener/machine-controller-manager"),
+	).Set(1)
+}
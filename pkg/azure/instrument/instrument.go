@@ -5,18 +5,109 @@
 package instrument
 
 import (
+	"context"
 	"errors"
 	"strconv"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const prometheusProviderLabelValue = "azure"
 
+// APIThrottledRequestCount is the number of Azure API requests which have been throttled, partitioned by provider and subscription.
+var APIThrottledRequestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "mcm",
+	Subsystem: "cloud_api",
+	Name:      "throttled_requests_total",
+	Help:      "Number of Cloud Service API requests which have been throttled, partitioned by provider, and subscription.",
+}, []string{"provider", "subscription"})
+
+// APIThrottlingRetryAfter is the duration after which Azure allowed to retry throttled API requests, partitioned by provider and subscription.
+var APIThrottlingRetryAfter = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "mcm",
+	Subsystem: "cloud_api",
+	Name:      "throttling_retry_after_seconds",
+	Help:      "Duration after which throttled Cloud Service API requests may be retried as given by the Retry-After header, partitioned by provider and subscription.",
+	Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+}, []string{"provider", "subscription"})
+
+// APIThrottlingWaitDuration is the total time which API requests have been held back as the subscription was throttled,
+// partitioned by provider and subscription.
+var APIThrottlingWaitDuration = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "mcm",
+	Subsystem: "cloud_api",
+	Name:      "throttling_wait_seconds_total",
+	Help:      "Total time in seconds for which Cloud Service API requests have been held back due to throttling, partitioned by provider, and subscription.",
+}, []string{"provider", "subscription"})
+
+// RemainingQuota is the remaining quota of a subscription in a location as discovered by the pre-flight quota checks,
+// partitioned by provider, subscription, location and quota.
+var RemainingQuota = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "mcm",
+	Subsystem: "cloud_api",
+	Name:      "remaining_quota",
+	Help:      "Remaining quota of a subscription in a location as discovered by the pre-flight quota checks, partitioned by provider, subscription, location and quota.",
+}, []string{"provider", "subscription", "location", "quota"})
+
+// APIOperationDuration is the latency of Azure API operations, partitioned by provider, service and status code. In contrast
+// to the APIRequestDuration of the MCM it also contains failed operations, which allows to compute latency SLOs per status code.
+var APIOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "mcm",
+	Subsystem: "cloud_api",
+	Name:      "operation_duration_seconds",
+	Help:      "Latency of Cloud Service API operations, partitioned by provider, service and status code.",
+	// long-running operations like the creation of a VM can take several minutes.
+	Buckets: prometheus.ExponentialBuckets(0.05, 2, 15),
+}, []string{"provider", "service", "code"})
+
+const (
+	// successStatusCodeLabelValue is the status code label value of successful operations. The exact status code is not
+	// known for long-running operations, which complete with one of several 2xx status codes.
+	successStatusCodeLabelValue = "2xx"
+	// timeoutStatusCodeLabelValue is the status code label value of operations which did not complete in time.
+	timeoutStatusCodeLabelValue = "timeout"
+	// unknownStatusCodeLabelValue is the status code label value of operations which failed without a response, e.g. due to
+	// a network error.
+	unknownStatusCodeLabelValue = "unknown"
+)
+
+func init() {
+	prometheus.MustRegister(APIThrottledRequestCount)
+	prometheus.MustRegister(APIThrottlingRetryAfter)
+	prometheus.MustRegister(APIThrottlingWaitDuration)
+	prometheus.MustRegister(RemainingQuota)
+	prometheus.MustRegister(LRODuration)
+	prometheus.MustRegister(BuildInfo)
+	prometheus.MustRegister(APIOperationDuration)
+}
+
+// RecordAzAPIThrottling increments the APIThrottledRequestCount counter vec metric for the given subscription. If Azure
+// returned a Retry-After duration then it is additionally recorded in the APIThrottlingRetryAfter histogram vec metric.
+func RecordAzAPIThrottling(subscriptionID string, retryAfter time.Duration) {
+	APIThrottledRequestCount.WithLabelValues(prometheusProviderLabelValue, subscriptionID).Inc()
+	if retryAfter > 0 {
+		APIThrottlingRetryAfter.WithLabelValues(prometheusProviderLabelValue, subscriptionID).Observe(retryAfter.Seconds())
+	}
+}
+
+// RecordAzAPIThrottlingWait adds the time for which a request has been held back due to throttling to the
+// APIThrottlingWaitDuration counter vec metric for the given subscription.
+func RecordAzAPIThrottlingWait(subscriptionID string, wait time.Duration) {
+	APIThrottlingWaitDuration.WithLabelValues(prometheusProviderLabelValue, subscriptionID).Add(wait.Seconds())
+}
+
+// RecordRemainingQuota sets the RemainingQuota gauge vec metric for the given subscription, location and quota.
+func RecordRemainingQuota(subscriptionID, location, quotaName string, remaining int64) {
+	RemainingQuota.WithLabelValues(prometheusProviderLabelValue, subscriptionID, location, quotaName).Set(float64(remaining))
+}
+
 // RecordAzAPIMetric records a prometheus metric for Azure API calls.
+// * It will record the time taken for the API call in the APIOperationDuration histogram vec metric, labeled by status code.
 // * If there is an error then it will increment the APIFailedRequestCount counter vec metric.
 // * If the Azure API call is successful then it will record 2 metrics:
 //   - It will increment APIRequestCount counter vec metric.
@@ -25,6 +116,10 @@ const prometheusProviderLabelValue = "azure"
 // NOTE: If this function is called via `defer` then please keep in mind that parameters passed to defer are evaluated at the time of definition.
 // So if you have an error that is computed later in the function then ensure that you use named return parameters.
 func RecordAzAPIMetric(err error, azServiceName string, invocationTime time.Time) {
+	APIOperationDuration.
+		WithLabelValues(prometheusProviderLabelValue, azServiceName, statusCodeLabelValue(err)).
+		Observe(time.Since(invocationTime).Seconds())
+
 	if err != nil {
 		metrics.APIFailedRequestCount.
 			WithLabelValues(prometheusProviderLabelValue, azServiceName).
@@ -47,6 +142,22 @@ func RecordAzAPIMetric(err error, azServiceName string, invocationTime time.Time
 	).Observe(elapsed.Seconds())
 }
 
+// statusCodeLabelValue returns the HTTP status code of the response contained in err, successStatusCodeLabelValue if
+// there is no error or one of the other status code label values if there is no response.
+func statusCodeLabelValue(err error) string {
+	if err == nil {
+		return successStatusCodeLabelValue
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return strconv.Itoa(respErr.StatusCode)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return timeoutStatusCodeLabelValue
+	}
+	return unknownStatusCodeLabelValue
+}
+
 // RecordDriverAPIMetric records a prometheus metric capturing the total duration of a successful execution for
 // any driver method (e.g. CreateMachine, DeleteMachine etc.). In case an error is returned then a failed counter
 // metric is recorded.
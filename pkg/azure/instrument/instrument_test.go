@@ -5,9 +5,15 @@
 package instrument
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
@@ -15,6 +21,8 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/version"
 )
 
 var (
@@ -42,6 +50,7 @@ func TestAPIMetricRecorderFn(t *testing.T) {
 			defer metrics.APIRequestCount.Reset()
 			defer metrics.APIFailedRequestCount.Reset()
 			defer metrics.APIRequestDuration.Reset()
+			defer APIOperationDuration.Reset()
 			_ = deferredMetricsRecorderInvoker(tc.err != nil, false, AZAPIMetricRecorderFn)
 			if tc.err != nil {
 				g.Expect(testutil.CollectAndCount(metrics.APIRequestCount)).To(Equal(0))
@@ -116,3 +125,64 @@ func deferredMetricsRecorderInvoker(shouldReturnErr bool, isStatusErr bool, fn r
 	}
 	return
 }
+
+func TestAPIOperationDurationStatusCodes(t *testing.T) {
+	testCases := []struct {
+		name              string
+		err               error
+		expectedCodeLabel string
+	}{
+		{"assert that a successful operation is recorded with the success status code", nil, successStatusCodeLabelValue},
+		{"assert that a failed operation is recorded with the status code of the response", &azcore.ResponseError{StatusCode: http.StatusConflict}, "409"},
+		{"assert that a timed out operation is recorded with the timeout status code", fmt.Errorf("polling failed: %w", context.DeadlineExceeded), timeoutStatusCodeLabelValue},
+		{"assert that an operation which failed without response is recorded with the unknown status code", errTest, unknownStatusCodeLabelValue},
+	}
+	g := NewWithT(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(_ *testing.T) {
+			defer APIOperationDuration.Reset()
+			RecordAzAPIMetric(tc.err, serviceName, time.Now())
+			g.Expect(testutil.CollectAndCount(APIOperationDuration)).To(Equal(1))
+			g.Expect(testutil.CollectAndCount(APIOperationDuration.WithLabelValues(prometheusProviderLabelValue, serviceName, tc.expectedCodeLabel).(prometheus.Histogram))).To(Equal(1))
+		})
+	}
+}
+
+func TestRecordAzAPIThrottling(t *testing.T) {
+	const subscriptionID = "test-subscription"
+	g := NewWithT(t)
+	defer APIThrottledRequestCount.Reset()
+	defer APIThrottlingRetryAfter.Reset()
+	defer APIThrottlingWaitDuration.Reset()
+
+	RecordAzAPIThrottling(subscriptionID, 0)
+	g.Expect(testutil.ToFloat64(APIThrottledRequestCount.WithLabelValues(prometheusProviderLabelValue, subscriptionID))).To(Equal(float64(1)))
+	g.Expect(testutil.CollectAndCount(APIThrottlingRetryAfter)).To(Equal(0))
+
+	RecordAzAPIThrottling(subscriptionID, 30*time.Second)
+	g.Expect(testutil.ToFloat64(APIThrottledRequestCount.WithLabelValues(prometheusProviderLabelValue, subscriptionID))).To(Equal(float64(2)))
+	g.Expect(testutil.CollectAndCount(APIThrottlingRetryAfter)).To(Equal(1))
+
+	RecordAzAPIThrottlingWait(subscriptionID, 2*time.Second)
+	RecordAzAPIThrottlingWait(subscriptionID, 3*time.Second)
+	g.Expect(testutil.ToFloat64(APIThrottlingWaitDuration.WithLabelValues(prometheusProviderLabelValue, subscriptionID))).To(Equal(float64(5)))
+}
+
+func TestRecordRemainingQuota(t *testing.T) {
+	const subscriptionID = "test-subscription"
+	g := NewWithT(t)
+	defer RemainingQuota.Reset()
+
+	RecordRemainingQuota(subscriptionID, "westeurope", "cores", 10)
+	RecordRemainingQuota(subscriptionID, "westeurope", "cores", 4)
+	g.Expect(testutil.ToFloat64(RemainingQuota.WithLabelValues(prometheusProviderLabelValue, subscriptionID, "westeurope", "cores"))).To(Equal(float64(4)))
+}
+
+func TestRecordBuildInfo(t *testing.T) {
+	g := NewWithT(t)
+	defer BuildInfo.Reset()
+
+	RecordBuildInfo(version.Info{Version: "v0.1.0", GitSHA: "abc", GoVersion: "go1.23.0", Modules: map[string]string{"github.com/Azure/azure-sdk-for-go/sdk/azcore": "v1.9.0"}})
+	g.Expect(testutil.CollectAndCount(BuildInfo)).To(Equal(1))
+	g.Expect(testutil.ToFloat64(BuildInfo.WithLabelValues("v0.1.0", "abc", "go1.23.0", "v1.9.0", "unknown", "unknown", "unknown"))).To(Equal(float64(1)))
+}
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instrument
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unknownRegionLabelValue is the region label value of long-running operations whose context does not carry a region.
+const unknownRegionLabelValue = "unknown"
+
+// LRODuration is the wall-clock duration of successfully completed long-running Azure API operations, e.g. the creation
+// of a VM, partitioned by provider, service and region.
+var LRODuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "mcm",
+	Subsystem: "cloud_api",
+	Name:      "long_running_operation_duration_seconds",
+	Help:      "Wall-clock duration of successfully completed long-running Cloud Service API operations, partitioned by provider, service and region.",
+	Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+}, []string{"provider", "service", "region"})
+
+type regionContextKey struct{}
+
+// WithRegion returns a copy of ctx which carries the region in which the resources of the machine are managed. The region
+// is used to partition the metrics of long-running operations which are started with the returned context.
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionContextKey{}, region)
+}
+
+// regionFromContext returns the region carried by ctx or unknownRegionLabelValue if there is none.
+func regionFromContext(ctx context.Context) string {
+	if region, ok := ctx.Value(regionContextKey{}).(string); ok && len(region) > 0 {
+		return region
+	}
+	return unknownRegionLabelValue
+}
+
+// LRODurationRecorderFn returns a function which records the duration of a long-running operation in the LRODuration
+// histogram vec metric, partitioned by the region carried by ctx. Failed operations are not recorded as their duration
+// mostly depends on the point at which they failed.
+// NOTE: a pointer to an error (which itself is a fat interface pointer) is necessary to enable the callers of this function to enclose this call into a `defer` statement.
+func LRODurationRecorderFn(ctx context.Context, azServiceName string, err *error) func() {
+	invocationTime := time.Now()
+	return func() {
+		if *err != nil {
+			return
+		}
+		LRODuration.WithLabelValues(prometheusProviderLabelValue, azServiceName, regionFromContext(ctx)).Observe(time.Since(invocationTime).Seconds())
+	}
+}
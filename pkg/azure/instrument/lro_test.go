@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instrument
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLRODurationRecorderFn(t *testing.T) {
+	testCases := []struct {
+		name           string
+		ctx            context.Context
+		err            error
+		expectedRegion string
+	}{
+		{"assert that a successful operation is recorded with the region of the context", WithRegion(context.Background(), "westeurope"), nil, "westeurope"},
+		{"assert that a successful operation is recorded with the unknown region if the context carries none", context.Background(), nil, unknownRegionLabelValue},
+		{"assert that a failed operation is not recorded", WithRegion(context.Background(), "westeurope"), errTest, ""},
+	}
+	g := NewWithT(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(_ *testing.T) {
+			defer LRODuration.Reset()
+			err := tc.err
+			LRODurationRecorderFn(tc.ctx, serviceName, &err)()
+			if tc.err != nil {
+				g.Expect(testutil.CollectAndCount(LRODuration)).To(Equal(0))
+				return
+			}
+			g.Expect(testutil.CollectAndCount(LRODuration)).To(Equal(1))
+			g.Expect(testutil.CollectAndCount(LRODuration.WithLabelValues(prometheusProviderLabelValue, serviceName, tc.expectedRegion).(prometheus.Histogram))).To(Equal(1))
+		})
+	}
+}
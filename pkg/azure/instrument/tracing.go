@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instrument
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName is the name of the tracer which creates all spans of the provider.
+	tracerName = "github.com/gardener/machine-controller-manager-provider-azure"
+	// tracingServiceName is the service name which is reported for the spans of the provider.
+	tracingServiceName = "machine-controller-manager-provider-azure"
+)
+
+// SetupTracing configures the global tracer provider to export spans to the OTLP/HTTP endpoint, e.g. http://localhost:4318.
+// If no endpoint is passed then tracing is not set up and the spans created by StartSpan are discarded. The returned function
+// flushes the pending spans and shuts the tracer provider down.
+func SetupTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	if len(endpoint) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(tracingServiceName))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tracerProvider.Shutdown, nil
+}
+
+// StartSpan starts a span with the passed name as child of the span contained in ctx. It returns the context containing
+// the new span and a function which ends the span, recording the error if there is one.
+// NOTE: a pointer to an error is necessary to enable the callers of this function to enclose the returned function into a `defer` statement.
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, func(err *error)) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+	return ctx, func(err *error) {
+		if err != nil && *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(otelcodes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
+
+// AZAPIRecorderFn starts a span for an Azure API call and returns the context containing it along with a function which
+// ends the span and records a prometheus metric for the call, see AZAPIMetricRecorderFn.
+// NOTE: a pointer to an error (which itself is a fat interface pointer) is necessary to enable the callers of this function to enclose the returned function into a `defer` statement.
+func AZAPIRecorderFn(ctx context.Context, azServiceName string, err *error) (context.Context, func()) {
+	recordMetricFn := AZAPIMetricRecorderFn(azServiceName, err)
+	ctx, endSpanFn := StartSpan(ctx, "azure."+azServiceName, attribute.String("azure.service", azServiceName))
+	return ctx, func() {
+		endSpanFn(err)
+		recordMetricFn()
+	}
+}
+
+// DriverAPIRecorderFn starts a span for a driver method and returns the context containing it along with a function which
+// ends the span and records a prometheus metric for the method, see DriverAPIMetricRecorderFn.
+// NOTE: a pointer to an error (which itself is a fat interface pointer) is necessary to enable the callers of this function to enclose the returned function into a `defer` statement.
+func DriverAPIRecorderFn(ctx context.Context, operation string, err *error, attributes ...attribute.KeyValue) (context.Context, func()) {
+	recordMetricFn := DriverAPIMetricRecorderFn(operation, err)
+	ctx, endSpanFn := StartSpan(ctx, "driver."+operation, attributes...)
+	return ctx, func() {
+		endSpanFn(err)
+		recordMetricFn()
+	}
+}
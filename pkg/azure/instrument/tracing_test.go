@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package instrument
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAZAPIRecorderFnCreatesSpan(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus otelcodes.Code
+	}{
+		{"assert that the span of a failed API request records the error", errTest, otelcodes.Error},
+		{"assert that the span of a successful API request does not record an error", nil, otelcodes.Unset},
+	}
+	g := NewWithT(t)
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	for _, tc := range testCases {
+		t.Run(tc.name, func(_ *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+			parentCtx, endParentFn := StartSpan(context.Background(), "driver.test")
+			func() {
+				err := tc.err
+				ctx, recordFn := AZAPIRecorderFn(parentCtx, serviceName, &err)
+				defer recordFn()
+				g.Expect(ctx).ToNot(Equal(parentCtx))
+			}()
+			endParentFn(nil)
+
+			spans := recorder.Ended()
+			g.Expect(spans).To(HaveLen(2))
+			g.Expect(spans[0].Name()).To(Equal("azure." + serviceName))
+			g.Expect(spans[0].Parent().SpanID()).To(Equal(spans[1].SpanContext().SpanID()))
+			g.Expect(spans[0].Status().Code).To(Equal(tc.expectedStatus))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import "sync"
+
+// DefaultDeletionConcurrency is the default number of resources which are deleted concurrently, e.g. when deleting the
+// leftover NICs and Disks of a machine.
+const DefaultDeletionConcurrency = 2
+
+var (
+	deletionConcurrencyMutex sync.RWMutex
+	deletionConcurrency      = DefaultDeletionConcurrency
+)
+
+// SetDeletionConcurrency sets the number of resources which are deleted concurrently. A value which is not set (zero or
+// negative) resets it to DefaultDeletionConcurrency. A higher concurrency deletes machines with many data disks faster at
+// the cost of a higher request rate against Azure.
+func SetDeletionConcurrency(concurrency int) {
+	deletionConcurrencyMutex.Lock()
+	defer deletionConcurrencyMutex.Unlock()
+	deletionConcurrency = resolveConcurrency(concurrency, DefaultDeletionConcurrency)
+}
+
+// GetDeletionConcurrency returns the currently configured number of resources which are deleted concurrently.
+func GetDeletionConcurrency() int {
+	deletionConcurrencyMutex.RLock()
+	defer deletionConcurrencyMutex.RUnlock()
+	return deletionConcurrency
+}
+
+// resolveConcurrency returns concurrency if it is set (positive), otherwise defaultConcurrency.
+func resolveConcurrency(concurrency, defaultConcurrency int) int {
+	if concurrency <= 0 {
+		return defaultConcurrency
+	}
+	return concurrency
+}
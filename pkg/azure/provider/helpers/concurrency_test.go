@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSetDeletionConcurrency(t *testing.T) {
+	table := []struct {
+		description         string
+		concurrency         int
+		expectedConcurrency int
+	}{
+		{"should use the configured concurrency", 8, 8},
+		{"should use the default concurrency if no concurrency is configured", 0, DefaultDeletionConcurrency},
+		{"should use the default concurrency if a negative concurrency is configured", -1, DefaultDeletionConcurrency},
+	}
+
+	g := NewWithT(t)
+	defer SetDeletionConcurrency(DefaultDeletionConcurrency)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			SetDeletionConcurrency(entry.concurrency)
+			g.Expect(GetDeletionConcurrency()).To(Equal(entry.expectedConcurrency))
+		})
+	}
+}
+
+func TestResolveConcurrency(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(resolveConcurrency(5, DefaultDeletionConcurrency)).To(Equal(5))
+	g.Expect(resolveConcurrency(0, DefaultDeletionConcurrency)).To(Equal(DefaultDeletionConcurrency))
+}
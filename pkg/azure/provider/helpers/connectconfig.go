@@ -6,6 +6,7 @@ package helpers
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
 )
 
 // ValidateSecretAndCreateConnectConfig validates the secret and creates an instance of azure.ConnectConfig out of it.
@@ -31,10 +33,16 @@ func ValidateSecretAndCreateConnectConfig(secret *corev1.Secret, cloudConfigurat
 		tenantID                  = ExtractCredentialsFromData(secret.Data, api.TenantID, api.AzureTenantID)
 		clientID                  = ExtractCredentialsFromData(secret.Data, api.ClientID, api.AzureClientID)
 		clientSecret              = ExtractCredentialsFromData(secret.Data, api.ClientSecret, api.AzureClientSecret)
-		workloadIdentityTokenFile = ExtractCredentialsFromData(secret.Data, api.WorkloadIdentityTokenFile)
-		azCloudConfiguration      = DetermineAzureCloudConfiguration(cloudConfiguration)
+		workloadIdentityTokenFile = ExtractCredentialsFromData(secret.Data, api.WorkloadIdentityTokenFile, api.AzureFederatedTokenFile)
 	)
 
+	if cloudConfiguration == nil {
+		if azureCloud := ExtractCredentialsFromData(secret.Data, api.AzureCloud); len(azureCloud) > 0 {
+			cloudConfiguration = &api.CloudConfiguration{Name: azureCloud}
+		}
+	}
+	azCloudConfiguration := DetermineAzureCloudConfiguration(cloudConfiguration)
+
 	return access.ConnectConfig{
 		SubscriptionID:            subscriptionID,
 		TenantID:                  tenantID,
@@ -45,6 +53,16 @@ func ValidateSecretAndCreateConnectConfig(secret *corev1.Secret, cloudConfigurat
 	}, nil
 }
 
+// WithImageTenant returns a copy of the connectConfig which additionally authenticates against the tenant of the image, if the image is
+// hosted in a tenant other than the tenant of the credentials. The connectConfig is returned unchanged otherwise.
+func WithImageTenant(connectConfig access.ConnectConfig, imageRef api.AzureImageReference) access.ConnectConfig {
+	if utils.IsNilOrEmptyStringPtr(imageRef.TenantID) || strings.EqualFold(*imageRef.TenantID, connectConfig.TenantID) {
+		return connectConfig
+	}
+	connectConfig.AuxiliaryTenantIDs = append(slices.Clone(connectConfig.AuxiliaryTenantIDs), *imageRef.TenantID)
+	return connectConfig
+}
+
 // ExtractCredentialsFromData extracts and trims a value from the given data map. The first key that exists is being
 // returned, otherwise, the next key is tried, etc. If no key exists then an empty string is returned.
 func ExtractCredentialsFromData(data map[string][]byte, keys ...string) string {
@@ -61,11 +79,11 @@ func DetermineAzureCloudConfiguration(cloudConfiguration *api.CloudConfiguration
 	if cloudConfiguration != nil {
 		cloudConfigurationName := cloudConfiguration.Name
 		switch {
-		case strings.EqualFold(cloudConfigurationName, api.CloudNamePublic):
+		case strings.EqualFold(cloudConfigurationName, api.CloudNamePublic), strings.EqualFold(cloudConfigurationName, api.CloudNameAzureCloud):
 			return cloud.AzurePublic
-		case strings.EqualFold(cloudConfigurationName, api.CloudNameGov):
+		case strings.EqualFold(cloudConfigurationName, api.CloudNameGov), strings.EqualFold(cloudConfigurationName, api.CloudNameAzureUSGovernment):
 			return cloud.AzureGovernment
-		case strings.EqualFold(cloudConfigurationName, api.CloudNameChina):
+		case strings.EqualFold(cloudConfigurationName, api.CloudNameChina), strings.EqualFold(cloudConfigurationName, api.CloudNameAzureChinaCloud):
 			return cloud.AzureChina
 		default:
 			return cloud.AzurePublic
@@ -8,8 +8,11 @@ import (
 	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestDetermineAzureCloudConfiguration(t *testing.T) {
@@ -23,6 +26,9 @@ func TestDetermineAzureCloudConfiguration(t *testing.T) {
 		{description: "cloud configuration name set to AzurePublic", testConfiguration: &api.CloudConfiguration{Name: api.CloudNamePublic}, expectedOutput: &cloud.AzurePublic},
 		{description: "cloud configuration name set to AzureChina", testConfiguration: &api.CloudConfiguration{Name: api.CloudNameChina}, expectedOutput: &cloud.AzureChina},
 		{description: "cloud configuration name set to AzureGov", testConfiguration: &api.CloudConfiguration{Name: api.CloudNameGov}, expectedOutput: &cloud.AzureGovernment},
+		{description: "cloud configuration name set to AzureCloud", testConfiguration: &api.CloudConfiguration{Name: api.CloudNameAzureCloud}, expectedOutput: &cloud.AzurePublic},
+		{description: "cloud configuration name set to AzureChinaCloud", testConfiguration: &api.CloudConfiguration{Name: api.CloudNameAzureChinaCloud}, expectedOutput: &cloud.AzureChina},
+		{description: "cloud configuration name set to AzureUSGovernment", testConfiguration: &api.CloudConfiguration{Name: api.CloudNameAzureUSGovernment}, expectedOutput: &cloud.AzureGovernment},
 		{description: "cloud configuration not set", testConfiguration: nil, expectedOutput: &cloud.AzurePublic},
 	}
 	g := NewWithT(t)
@@ -35,3 +41,97 @@ func TestDetermineAzureCloudConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestWithImageTenant(t *testing.T) {
+	const (
+		tenantID      = "tenant-0"
+		imageTenantID = "tenant-1"
+	)
+	tests := []struct {
+		description                string
+		imageTenantID              *string
+		expectedAuxiliaryTenantIDs []string
+	}{
+		{description: "image tenant not set", imageTenantID: nil, expectedAuxiliaryTenantIDs: nil},
+		{description: "image tenant same as tenant of credentials", imageTenantID: to.Ptr(tenantID), expectedAuxiliaryTenantIDs: nil},
+		{description: "image tenant different from tenant of credentials", imageTenantID: to.Ptr(imageTenantID), expectedAuxiliaryTenantIDs: []string{imageTenantID}},
+	}
+	g := NewWithT(t)
+	for _, test := range tests {
+		t.Run(test.description, func(_ *testing.T) {
+			connectConfig := WithImageTenant(access.ConnectConfig{TenantID: tenantID}, api.AzureImageReference{ID: "image-id", TenantID: test.imageTenantID})
+			g.Expect(connectConfig.TenantID).To(Equal(tenantID))
+			g.Expect(connectConfig.AuxiliaryTenantIDs).To(Equal(test.expectedAuxiliaryTenantIDs))
+		})
+	}
+}
+
+func TestValidateSecretAndCreateConnectConfigWithWorkloadIdentity(t *testing.T) {
+	const tokenFile = "/var/run/secrets/azure/tokens/azure-identity-token"
+	tests := []struct {
+		description  string
+		tokenFileKey string
+	}{
+		{description: "token file set via workloadIdentityTokenFile", tokenFileKey: api.WorkloadIdentityTokenFile},
+		{description: "token file set via azureFederatedTokenFile", tokenFileKey: api.AzureFederatedTokenFile},
+	}
+	g := NewWithT(t)
+	for _, test := range tests {
+		t.Run(test.description, func(_ *testing.T) {
+			secret := &corev1.Secret{
+				Data: map[string][]byte{
+					api.ClientID:       []byte("client-id"),
+					api.TenantID:       []byte("tenant-id"),
+					api.SubscriptionID: []byte("subscription-id"),
+					api.UserData:       []byte("user-data"),
+					test.tokenFileKey:  []byte(tokenFile + "\n"),
+				},
+			}
+			connectConfig, err := ValidateSecretAndCreateConnectConfig(secret, nil)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(connectConfig.WorkloadIdentityTokenFile).To(Equal(tokenFile))
+			g.Expect(connectConfig.ClientSecret).To(BeEmpty())
+			g.Expect(connectConfig.ClientID).To(Equal("client-id"))
+			g.Expect(connectConfig.TenantID).To(Equal("tenant-id"))
+		})
+	}
+}
+
+func TestValidateSecretAndCreateConnectConfigWithAzureCloud(t *testing.T) {
+	tests := []struct {
+		description        string
+		azureCloud         *string
+		cloudConfiguration *api.CloudConfiguration
+		expectedCloud      cloud.Configuration
+		expectErr          bool
+	}{
+		{description: "neither azureCloud nor cloud configuration set", expectedCloud: cloud.AzurePublic},
+		{description: "azureCloud set in secret", azureCloud: to.Ptr(api.CloudNameAzureChinaCloud), expectedCloud: cloud.AzureChina},
+		{description: "cloud configuration takes precedence over azureCloud", azureCloud: to.Ptr(api.CloudNameAzureChinaCloud), cloudConfiguration: &api.CloudConfiguration{Name: api.CloudNameGov}, expectedCloud: cloud.AzureGovernment},
+		{description: "unknown azureCloud set in secret", azureCloud: to.Ptr("AzureMoonCloud"), expectErr: true},
+	}
+	g := NewWithT(t)
+	for _, test := range tests {
+		t.Run(test.description, func(_ *testing.T) {
+			secret := &corev1.Secret{
+				Data: map[string][]byte{
+					api.ClientID:       []byte("client-id"),
+					api.ClientSecret:   []byte("client-secret"),
+					api.TenantID:       []byte("tenant-id"),
+					api.SubscriptionID: []byte("subscription-id"),
+					api.UserData:       []byte("user-data"),
+				},
+			}
+			if test.azureCloud != nil {
+				secret.Data[api.AzureCloud] = []byte(*test.azureCloud)
+			}
+			connectConfig, err := ValidateSecretAndCreateConnectConfig(secret, test.cloudConfiguration)
+			if test.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(connectConfig.ClientOptions.Cloud).To(Equal(test.expectedCloud))
+		})
+	}
+}
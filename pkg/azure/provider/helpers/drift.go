@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// DetectDrift compares the VM and its NIC and OSDisk with the state which has been established by CreateMachine. It returns
+// a description of every discrepancy, e.g. a NIC or OSDisk which has been deleted or detached manually, a cascade delete
+// option which has been changed or a tag of the provider spec which is missing on the VM. Discrepancies in the cascade
+// delete options and the NIC are relevant as DeleteMachine relies on them to delete all resources of the machine.
+func DetectDrift(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vm *armcompute.VirtualMachine, machineName string) ([]string, error) {
+	var (
+		resourceGroup = providerSpec.ResourceGroup
+		vmName        = *vm.Name
+		discrepancies []string
+	)
+	if vm.Properties == nil {
+		return nil, nil
+	}
+
+	nicName := utils.CreateNICName(vmName)
+	nicRef := getNetworkInterfaceReference(vm.Properties.NetworkProfile, nicName)
+	if nicRef == nil {
+		discrepancies = append(discrepancies, fmt.Sprintf("NIC %s is not attached to the VM", nicName))
+	} else if !isNicCascadeDeleteSet(nicRef) {
+		discrepancies = append(discrepancies, fmt.Sprintf("NIC %s is not deleted together with the VM", nicName))
+	}
+	nicAccess, err := factory.GetNetworkInterfacesAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create nic access for VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	nic, err := accesshelpers.GetNIC(ctx, nicAccess, resourceGroup, nicName)
+	if err != nil {
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get NIC: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, nicName, err), err)
+	}
+	if nic == nil {
+		discrepancies = append(discrepancies, fmt.Sprintf("NIC %s does not exist", nicName))
+	}
+
+	storageProfile := vm.Properties.StorageProfile
+	if storageProfile == nil || storageProfile.OSDisk == nil || storageProfile.OSDisk.Name == nil {
+		discrepancies = append(discrepancies, "VM does not have an OSDisk")
+	} else {
+		osDiskName := *storageProfile.OSDisk.Name
+		if storageProfile.OSDisk.DeleteOption == nil || *storageProfile.OSDisk.DeleteOption != armcompute.DiskDeleteOptionTypesDelete {
+			discrepancies = append(discrepancies, fmt.Sprintf("OSDisk %s is not deleted together with the VM", osDiskName))
+		}
+		disksAccess, err := factory.GetDisksAccess(connectConfig)
+		if err != nil {
+			return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create disk access for VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+		osDisk, err := accesshelpers.GetDisk(ctx, disksAccess, resourceGroup, osDiskName)
+		if err != nil {
+			return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get OSDisk: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, osDiskName, err), err)
+		}
+		if osDisk == nil {
+			discrepancies = append(discrepancies, fmt.Sprintf("OSDisk %s does not exist", osDiskName))
+		}
+	}
+
+	discrepancies = append(discrepancies, detectDataDiskDrift(providerSpec, storageProfile, vmName)...)
+	discrepancies = append(discrepancies, detectTagDrift(utils.CreateVMTags(providerSpec.Tags, machineName), vm.Tags)...)
+	return discrepancies, nil
+}
+
+// getNetworkInterfaceReference returns the reference to the NIC with the passed name, the references of the VM either
+// contain the ID or only the name of the NIC.
+func getNetworkInterfaceReference(networkProfile *armcompute.NetworkProfile, nicName string) *armcompute.NetworkInterfaceReference {
+	if networkProfile == nil {
+		return nil
+	}
+	for _, nicRef := range networkProfile.NetworkInterfaces {
+		if nicRef.ID != nil && strings.EqualFold(getResourceNameFromID(*nicRef.ID), nicName) {
+			return nicRef
+		}
+	}
+	return nil
+}
+
+func getResourceNameFromID(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// detectDataDiskDrift checks that all data disks of the provider spec are attached to the VM with the configured delete option.
+func detectDataDiskDrift(providerSpec api.AzureProviderSpec, storageProfile *armcompute.StorageProfile, vmName string) []string {
+	var discrepancies []string
+	for _, specDataDisk := range providerSpec.Properties.StorageProfile.DataDisks {
+		diskName := utils.CreateDataDiskNameFromTemplate(vmName, specDataDisk.Name, specDataDisk.Lun, providerSpec.Properties.StorageProfile.DataDiskNameTemplate)
+		var dataDisk *armcompute.DataDisk
+		if storageProfile != nil {
+			if idx := slices.IndexFunc(storageProfile.DataDisks, func(d *armcompute.DataDisk) bool { return d.Name != nil && strings.EqualFold(*d.Name, diskName) }); idx >= 0 {
+				dataDisk = storageProfile.DataDisks[idx]
+			}
+		}
+		if dataDisk == nil {
+			discrepancies = append(discrepancies, fmt.Sprintf("DataDisk %s is not attached to the VM", diskName))
+			continue
+		}
+		expectedDeleteOption := *getDataDiskDeleteOption(specDataDisk.DeleteOption)
+		if dataDisk.DeleteOption == nil || *dataDisk.DeleteOption != expectedDeleteOption {
+			discrepancies = append(discrepancies, fmt.Sprintf("DataDisk %s does not have the delete option %s", diskName, expectedDeleteOption))
+		}
+	}
+	return discrepancies
+}
+
+// detectTagDrift checks that all expected tags are set on the VM with the expected values. Additional tags are ignored.
+func detectTagDrift(expectedTags, tags map[string]*string) []string {
+	keys := make([]string, 0, len(expectedTags))
+	for key := range expectedTags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	var discrepancies []string
+	for _, key := range keys {
+		value, ok := tags[key]
+		switch {
+		case !ok || value == nil:
+			discrepancies = append(discrepancies, fmt.Sprintf("tag %s is missing on the VM", key))
+		case *value != *expectedTags[key]:
+			discrepancies = append(discrepancies, fmt.Sprintf("tag %s has the value %q instead of %q", key, *value, *expectedTags[key]))
+		}
+	}
+	return discrepancies
+}
@@ -12,10 +12,14 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
@@ -32,6 +36,7 @@ import (
 	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
 	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api/defaulting"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api/validation"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
 )
@@ -65,43 +70,140 @@ func ExtractProviderSpecAndConnectConfig(mcc *v1alpha1.MachineClass, secret *cor
 	return providerSpec, connectConfig, nil
 }
 
-// ConstructMachineListResponse constructs response for driver.ListMachines method.
-func ConstructMachineListResponse(location string, vmNames []string) *driver.ListMachinesResponse {
+// ConstructMachineListResponse constructs response for driver.ListMachines method. The instance ID of a machine is derived
+// from the name of its VM, which differs from the machine name if it has been shortened, see utils.CreateVMName.
+func ConstructMachineListResponse(location string, machineNames []string) *driver.ListMachinesResponse {
 	listMachineRes := driver.ListMachinesResponse{}
-	instanceIDToVMNameMap := make(map[string]string, len(vmNames))
-	if len(vmNames) == 0 {
+	instanceIDToMachineNameMap := make(map[string]string, len(machineNames))
+	if len(machineNames) == 0 {
 		return &listMachineRes
 	}
-	for _, vmName := range vmNames {
-		instanceIDToVMNameMap[DeriveInstanceID(location, vmName)] = vmName
+	for _, machineName := range machineNames {
+		instanceIDToMachineNameMap[DeriveInstanceID(location, utils.CreateVMName(machineName))] = machineName
 	}
-	listMachineRes.MachineList = instanceIDToVMNameMap
+	listMachineRes.MachineList = instanceIDToMachineNameMap
 	return &listMachineRes
 }
 
 // ConstructGetMachineStatusResponse constructs response for driver.GetMachineStatus method.
-func ConstructGetMachineStatusResponse(location string, vmName string) *driver.GetMachineStatusResponse {
-	instanceID := DeriveInstanceID(location, vmName)
+func ConstructGetMachineStatusResponse(providerSpec api.AzureProviderSpec, vmName string) *driver.GetMachineStatusResponse {
+	instanceID := DeriveInstanceID(providerSpec.Location, vmName)
 	return &driver.GetMachineStatusResponse{
 		ProviderID: instanceID,
-		NodeName:   vmName,
+		NodeName:   DeriveNodeName(providerSpec, vmName),
 	}
 }
 
-// ConstructCreateMachineResponse constructs response for driver.CreateMachine method.
-func ConstructCreateMachineResponse(location string, vmName string) *driver.CreateMachineResponse {
-	instanceID := DeriveInstanceID(location, vmName)
+// ConstructCreateMachineResponse constructs response for driver.CreateMachine method. The resources which have been
+// created and the decisions which have been taken while creating the VM are recorded as LastKnownState.
+func ConstructCreateMachineResponse(ctx context.Context, providerSpec api.AzureProviderSpec, vmName string, lastKnownState *LastKnownState) *driver.CreateMachineResponse {
+	instanceID := DeriveInstanceID(providerSpec.Location, vmName)
 	return &driver.CreateMachineResponse{
+		ProviderID:     instanceID,
+		NodeName:       DeriveNodeName(providerSpec, vmName),
+		LastKnownState: lastKnownState.Encode(ctx),
+	}
+}
+
+// ConstructDeleteMachineErrorResponse constructs the response for driver.DeleteMachine method if the deletion of the VM
+// failed. If the deletion is still in progress then it is recorded as pending operation in the LastKnownState.
+func ConstructDeleteMachineErrorResponse(ctx context.Context, vmName string, err error) *driver.DeleteMachineResponse {
+	lastKnownState := &LastKnownState{}
+	lastKnownState.AddPendingOperationFromError(PendingOperationTypeDeleteVM, vmName, err)
+	return &driver.DeleteMachineResponse{LastKnownState: lastKnownState.Encode(ctx)}
+}
+
+// ConstructInitializeMachineResponse constructs response for driver.InitializeMachine method.
+func ConstructInitializeMachineResponse(providerSpec api.AzureProviderSpec, vmName string) *driver.InitializeMachineResponse {
+	instanceID := DeriveInstanceID(providerSpec.Location, vmName)
+	return &driver.InitializeMachineResponse{
 		ProviderID: instanceID,
-		NodeName:   vmName,
+		NodeName:   DeriveNodeName(providerSpec, vmName),
 	}
 }
 
+// SelectZone deterministically selects one of the zones for a VM using the hash of the VM name. This spreads VMs
+// across zones while ensuring that retries of CreateMachine for the same machine always select the same zone.
+// If no zones are passed then nil is returned.
+func SelectZone(zones []int, vmName string) *int {
+	if len(zones) == 0 {
+		return nil
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(vmName))
+	return to.Ptr(zones[hash.Sum32()%uint32(len(zones))])
+}
+
+// GetAlternateZones returns the zones other than the passed zone in which a VM can be created if it could not be allocated
+// in the passed zone. The zones are ordered starting with the zone following the passed zone, so that VMs falling back
+// from the same zone do not all end up in the same alternate zone. If the passed zone is nil then no zones are returned.
+func GetAlternateZones(zones []int, zone *int) []int {
+	if zone == nil {
+		return nil
+	}
+	index := slices.Index(zones, *zone)
+	if index < 0 {
+		return nil
+	}
+	alternateZones := make([]int, 0, len(zones)-1)
+	for i := 1; i < len(zones); i++ {
+		alternateZones = append(alternateZones, zones[(index+i)%len(zones)])
+	}
+	return alternateZones
+}
+
+// IsAllocationFailedError checks if the passed error has been caused by insufficient capacity to allocate a VM.
+func IsAllocationFailedError(err error) bool {
+	var statusErr *status.Status
+	if errors.As(err, &statusErr) && statusErr.Cause() != nil {
+		err = statusErr.Cause()
+	}
+	return accesserrors.IsAllocationFailedAzAPIError(err)
+}
+
+// DeleteUnallocatedVM deletes a VM which could not be allocated, so that it can be created again in another zone. The
+// VM is created with cascade delete options, therefore the NIC is detached from the VM before, so that it is kept and
+// can be reused for the VM in the other zone. The OS disk is deleted together with the VM.
+func DeleteUnallocatedVM(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, resourceGroup string, vmName string, nicID string) error {
+	vmAccess, err := factory.GetVirtualMachinesAccess(connectConfig)
+	if err != nil {
+		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine access to process request: [resourceGroup: %s, vmName: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	vmUpdateParams := armcompute.VirtualMachineUpdate{
+		Properties: &armcompute.VirtualMachineProperties{
+			NetworkProfile: &armcompute.NetworkProfile{
+				NetworkInterfaces: []*armcompute.NetworkInterfaceReference{
+					{
+						ID: &nicID,
+						Properties: &armcompute.NetworkInterfaceReferenceProperties{
+							DeleteOption: to.Ptr(armcompute.DeleteOptionsDetach),
+							Primary:      to.Ptr(true),
+						},
+					},
+				},
+			},
+		},
+	}
+	if _, err = accesshelpers.UpdateVirtualMachine(ctx, vmAccess, resourceGroup, vmName, vmUpdateParams); err != nil {
+		return status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to detach NIC from unallocated VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	return DeleteVirtualMachine(ctx, vmAccess, resourceGroup, vmName, false)
+}
+
 // DeriveInstanceID creates an instance ID from location and VM name.
 func DeriveInstanceID(location, vmName string) string {
 	return fmt.Sprintf("azure:///%s/%s", location, vmName)
 }
 
+// DeriveNodeName returns the name of the node which registers for the VM. The node is named after the computer name of
+// the VM, which differs from the VM name for Windows VMs with names longer than allowed by Windows, see getOSProfile.
+func DeriveNodeName(providerSpec api.AzureProviderSpec, vmName string) string {
+	if providerSpec.Properties.OsProfile.WindowsConfiguration != nil {
+		return utils.CreateWindowsComputerName(vmName)
+	}
+	return vmName
+}
+
 // Helper functions used for driver.DeleteMachine
 // ---------------------------------------------------------------------------------------------------------------------
 
@@ -113,27 +215,31 @@ func SkipDeleteMachine(ctx context.Context, factory access.Factory, connectConfi
 	}
 	resGroupExists, err := accesshelpers.ResourceGroupExists(ctx, resGroupAccess, resourceGroup)
 	if err != nil {
-		return false, status.WrapError(codes.Internal, fmt.Sprintf("failed to check if ResourceGroup %s exists, Err: %v", resourceGroup, err), err)
+		return false, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to check if ResourceGroup %s exists, Err: %v", resourceGroup, err), err)
 	}
 	return !resGroupExists, nil
 }
 
-// GetDiskNames creates disk names for all configured OSDisk and DataDisk in the provider spec.
+// GetDiskNames creates disk names for all configured OSDisk and DataDisk in the provider spec which are deleted together with the VM.
 func GetDiskNames(providerSpec api.AzureProviderSpec, vmName string) []string {
 	dataDisks := providerSpec.Properties.StorageProfile.DataDisks
 	diskNames := make([]string, 0, len(dataDisks)+1)
-	diskNames = append(diskNames, utils.CreateOSDiskName(vmName))
+	diskNames = append(diskNames, utils.CreateOSDiskNameFromTemplate(vmName, providerSpec.Properties.StorageProfile.OsDisk.NameTemplate))
 	dataDiskNames := createDataDiskNames(providerSpec, vmName)
 	diskNames = append(diskNames, dataDiskNames...)
 	return diskNames
 }
 
-// createDataDiskNames creates disk names for all configured DataDisks in the provider spec.
+// createDataDiskNames creates disk names for all configured DataDisks in the provider spec which are deleted together with the VM.
+// DataDisks which have DeleteOption set to Detach are skipped, as they need to survive the deletion of the machine.
 func createDataDiskNames(providerSpec api.AzureProviderSpec, vmName string) []string {
 	dataDisks := providerSpec.Properties.StorageProfile.DataDisks
 	diskNames := make([]string, 0, len(dataDisks))
 	for _, disk := range dataDisks {
-		diskName := utils.CreateDataDiskName(vmName, disk.Name, disk.Lun)
+		if IsDetachedOnDelete(disk) {
+			continue
+		}
+		diskName := utils.CreateDataDiskNameFromTemplate(vmName, disk.Name, disk.Lun, providerSpec.Properties.StorageProfile.DataDiskNameTemplate)
 		diskNames = append(diskNames, diskName)
 	}
 	return diskNames
@@ -141,7 +247,8 @@ func createDataDiskNames(providerSpec api.AzureProviderSpec, vmName string) []st
 
 // CheckAndDeleteLeftoverNICsAndDisks creates tasks for NIC and DISK deletion and runs them concurrently. It waits for them to complete and then returns a consolidated error if there is any.
 // This method will be called when these resources are left without an associated VM.
-func CheckAndDeleteLeftoverNICsAndDisks(ctx context.Context, factory access.Factory, vmName string, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) error {
+// At most concurrency resources are deleted concurrently, a value which is not set (zero) uses GetDeletionConcurrency.
+func CheckAndDeleteLeftoverNICsAndDisks(ctx context.Context, factory access.Factory, vmName string, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, concurrency int) error {
 	// Gather the names for NIC, OSDisk and Data Disks that needs to be checked for existence and then deleted if they exist.
 	resourceGroup := providerSpec.ResourceGroup
 	nicName := utils.CreateNICName(vmName)
@@ -161,9 +268,40 @@ func CheckAndDeleteLeftoverNICsAndDisks(ctx context.Context, factory access.Fact
 	tasks := make([]utils.Task, 0, len(diskNames)+1)
 	tasks = append(tasks, createNICDeleteTask(resourceGroup, nicName, nicAccess))
 	tasks = append(tasks, createDisksDeletionTasks(resourceGroup, diskNames, disksAccess)...)
-	combinedErr := errors.Join(utils.RunConcurrently(ctx, tasks, 2)...)
+	combinedErr := errors.Join(utils.RunConcurrently(ctx, tasks, resolveConcurrency(concurrency, GetDeletionConcurrency()))...)
+	if combinedErr != nil {
+		return status.WrapError(accesserrors.GetMatchingErrorCode(combinedErr), fmt.Sprintf("Errors during deletion of NIC/Disks associated to VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, combinedErr), combinedErr)
+	}
+	return nil
+}
+
+// CheckAndDeleteLeftoverPublicIPs deletes the public IP addresses of the VM which are no longer associated to a NIC.
+// Public IP addresses are not created by this provider, but they might have been created for the VM by other tooling
+// and are not deleted together with the VM. Public IP addresses which are still associated to another NIC are skipped.
+// At most concurrency public IP addresses are deleted concurrently, a value which is not set (zero) uses GetDeletionConcurrency.
+func CheckAndDeleteLeftoverPublicIPs(ctx context.Context, factory access.Factory, vmName, machineName string, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, concurrency int) error {
+	publicIPs, err := ListPublicIPsOfVM(ctx, factory, connectConfig, providerSpec, vmName, machineName)
+	if err != nil {
+		return err
+	}
+	if len(publicIPs) == 0 {
+		return nil
+	}
+	publicIPAccess, err := factory.GetPublicIPAddressesAccess(connectConfig)
+	if err != nil {
+		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to create public IP address access for VM: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, vmName, err), err)
+	}
+	tasks := make([]utils.Task, 0, len(publicIPs))
+	for _, publicIP := range publicIPs {
+		if publicIP.Attached {
+			klog.FromContext(ctx).Info("Public IP address is still associated, skipping its deletion", "publicIPResourceGroup", publicIP.ResourceGroup, "publicIP", publicIP.Name, "vm", vmName)
+			continue
+		}
+		tasks = append(tasks, createPublicIPDeleteTask(publicIP.ResourceGroup, publicIP.Name, publicIPAccess))
+	}
+	combinedErr := errors.Join(utils.RunConcurrently(ctx, tasks, resolveConcurrency(concurrency, GetDeletionConcurrency()))...)
 	if combinedErr != nil {
-		return status.WrapError(codes.Internal, fmt.Sprintf("Errors during deletion of NIC/Disks associated to VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), combinedErr)
+		return status.WrapError(accesserrors.GetMatchingErrorCode(combinedErr), fmt.Sprintf("Errors during deletion of public IP addresses associated to VM: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, vmName, combinedErr), combinedErr)
 	}
 	return nil
 }
@@ -172,24 +310,169 @@ func CheckAndDeleteLeftoverNICsAndDisks(ctx context.Context, factory access.Fact
 // Once that is set then it deletes the VM. This will ensure that no separate calls to delete each NIC and DISK are made as they will get deleted along with the VM in one single atomic call.
 func UpdateCascadeDeleteOptions(ctx context.Context, providerSpec api.AzureProviderSpec, vmAccess *armcompute.VirtualMachinesClient, resourceGroup string, vm *armcompute.VirtualMachine) error {
 	vmName := *vm.Name
-	vmUpdateParams := computeDeleteOptionUpdatesForNICsAndDisksIfRequired(resourceGroup, vm, providerSpec)
+	vmUpdateParams := computeDeleteOptionUpdatesForNICsAndDisksIfRequired(ctx, resourceGroup, vm, providerSpec)
 	if vmUpdateParams != nil {
 		// update the VM and set cascade delete on NIC and Disks (OSDisk and DataDisks) if not already set and then trigger VM deletion.
-		klog.V(4).Infof("Updating cascade deletion options for VM: [ResourceGroup: %s, Name: %s] resources", resourceGroup, vmName)
+		klog.FromContext(ctx).V(4).Info("Updating cascade deletion options of the resources of the VM", "vm", vmName)
 		err := accesshelpers.SetCascadeDeleteForNICsAndDisks(ctx, vmAccess, resourceGroup, vmName, vmUpdateParams)
 		if err != nil {
-			return status.WrapError(codes.Internal, fmt.Sprintf("Failed to update cascade delete of associated resources for VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+			return status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to update cascade delete of associated resources for VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+	}
+	return nil
+}
+
+// IsForceDeletionEnabled checks if the VM of the machine should be force deleted. The ForceDeletionAnnotation of the
+// machine takes precedence over the ForceDeletion property of the provider spec.
+func IsForceDeletionEnabled(ctx context.Context, providerSpec api.AzureProviderSpec, machine *v1alpha1.Machine) bool {
+	if machine != nil {
+		if value, ok := machine.Annotations[api.ForceDeletionAnnotation]; ok {
+			forceDeletion, err := strconv.ParseBool(value)
+			if err == nil {
+				return forceDeletion
+			}
+			klog.FromContext(ctx).Info("Ignoring invalid value of force deletion annotation", "machine", machine.Name, "annotation", api.ForceDeletionAnnotation, "value", value)
 		}
 	}
+	return providerSpec.Properties.ForceDeletion
+}
+
+// CheckDeletionProtection returns an error with code codes.FailedPrecondition if the machine carries the
+// api.ProtectFromDeletionAnnotation or its VM carries the utils.ProtectFromDeletionTagKey tag, unless the value is "false".
+// The VM can be nil if it does not exist.
+func CheckDeletionProtection(machine *v1alpha1.Machine, vm *armcompute.VirtualMachine) error {
+	if value, ok := machine.Annotations[api.ProtectFromDeletionAnnotation]; ok && !strings.EqualFold(value, "false") {
+		return status.Error(codes.FailedPrecondition, fmt.Sprintf("Machine: %s is protected from deletion by annotation %s, remove the annotation to delete it", machine.Name, api.ProtectFromDeletionAnnotation))
+	}
+	if vm == nil {
+		return nil
+	}
+	if value, ok := vm.Tags[utils.ProtectFromDeletionTagKey]; ok && !strings.EqualFold(pointer.StringDeref(value, ""), "false") {
+		return status.Error(codes.FailedPrecondition, fmt.Sprintf("VM: %s of Machine: %s is protected from deletion by tag %s, remove the tag to delete it", pointer.StringDeref(vm.Name, ""), machine.Name, utils.ProtectFromDeletionTagKey))
+	}
+	return nil
+}
+
+// permanentCreationFailureCodes are the codes of errors with which the creation of a VM fails and which do not resolve by
+// retrying the creation.
+var permanentCreationFailureCodes = []codes.Code{
+	codes.InvalidArgument,
+	codes.NotFound,
+	codes.AlreadyExists,
+	codes.PermissionDenied,
+	codes.ResourceExhausted,
+	codes.FailedPrecondition,
+	codes.OutOfRange,
+	codes.Unimplemented,
+	codes.Unauthenticated,
+}
+
+// CheckFailedCreationRetention returns an error with code codes.FailedPrecondition if the creation of the VM of the machine
+// has failed and its resources are retained according to the FailedCreationCleanup of the provider spec. Resources are
+// retained until the RetentionDuration has elapsed since the creation failed, or indefinitely if it is not set.
+func CheckFailedCreationRetention(ctx context.Context, providerSpec api.AzureProviderSpec, machine *v1alpha1.Machine, now time.Time) error {
+	failedCreationCleanup := providerSpec.Properties.FailedCreationCleanup
+	if failedCreationCleanup == nil || failedCreationCleanup.Policy == "" || failedCreationCleanup.Policy == api.FailedCreationCleanupPolicyAlways {
+		return nil
+	}
+	creationFailure := GetPreviousLastKnownState(ctx, machine).GetCreationFailure()
+	if creationFailure == nil {
+		return nil
+	}
+	if failedCreationCleanup.Policy == api.FailedCreationCleanupPolicyOnPermanentError &&
+		slices.ContainsFunc(permanentCreationFailureCodes, func(code codes.Code) bool { return code.String() == creationFailure.Code }) {
+		return nil
+	}
+	if failedCreationCleanup.RetentionDuration == nil {
+		return status.Error(codes.FailedPrecondition, fmt.Sprintf("resources of Machine: %s are retained since its creation failed with code %s, change the failedCreationCleanup policy to delete it", machine.Name, creationFailure.Code))
+	}
+	// the retention duration has been validated already.
+	retentionDuration, _ := time.ParseDuration(*failedCreationCleanup.RetentionDuration)
+	retainUntil := creationFailure.Time.Add(retentionDuration)
+	if !now.Before(retainUntil) {
+		return nil
+	}
+	return status.Error(codes.FailedPrecondition, fmt.Sprintf("resources of Machine: %s are retained until %s since its creation failed with code %s", machine.Name, retainUntil.Format(time.RFC3339), creationFailure.Code))
+}
+
+// DeallocateVirtualMachineBeforeDeletion deallocates the VirtualMachine if DeallocateBeforeDeletion is set in the provider
+// spec, if there is any error it will wrap it into a status.Status error.
+func DeallocateVirtualMachineBeforeDeletion(ctx context.Context, providerSpec api.AzureProviderSpec, vmAccess *armcompute.VirtualMachinesClient, vmName string) error {
+	if !providerSpec.Properties.DeallocateBeforeDeletion {
+		return nil
+	}
+	resourceGroup := providerSpec.ResourceGroup
+	klog.FromContext(ctx).Info("Deallocating VM before deleting it", "vm", vmName)
+	if err := accesshelpers.DeallocateVirtualMachine(ctx, vmAccess, resourceGroup, vmName); err != nil {
+		return status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to deallocate VM: [ResourceGroup: %s, Name: %s] before deleting it, Err: %v", resourceGroup, vmName, err), err)
+	}
 	return nil
 }
 
 // DeleteVirtualMachine deletes the VirtualMachine, if there is any error it will wrap it into a status.Status error.
-func DeleteVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachinesClient, resourceGroup string, vmName string) error {
-	klog.Infof("Deleting VM: [ResourceGroup: %s, Name: %s]", resourceGroup, vmName)
-	err := accesshelpers.DeleteVirtualMachine(ctx, vmAccess, resourceGroup, vmName)
+func DeleteVirtualMachine(ctx context.Context, vmAccess *armcompute.VirtualMachinesClient, resourceGroup string, vmName string, forceDeletion bool) error {
+	klog.FromContext(ctx).Info("Deleting VM", "vm", vmName, "forceDeletion", forceDeletion)
+	err := accesshelpers.DeleteVirtualMachine(ctx, vmAccess, resourceGroup, vmName, forceDeletion)
 	if err != nil {
-		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to delete VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		return status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to delete VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	return nil
+}
+
+// EnsureVirtualMachineIsRunning checks that the VM has been successfully provisioned and is running. A VM which is
+// stopped or deallocated is started. If the VM is not yet ready then an error with code codes.Uninitialized is returned,
+// so that the initialization is retried. If the VM is in terminal provisioning state then an error with code codes.Internal
+// is returned. This must only be used when initializing the machine, the status of a machine is checked with CheckVirtualMachineState.
+func EnsureVirtualMachineIsRunning(ctx context.Context, vmAccess *armcompute.VirtualMachinesClient, resourceGroup string, vmName string) error {
+	instanceView, err := accesshelpers.GetVirtualMachineInstanceView(ctx, vmAccess, resourceGroup, vmName)
+	if err != nil {
+		return status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get instance view of VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	if instanceView == nil {
+		return status.Error(codes.NotFound, fmt.Sprintf("VM: [ResourceGroup: %s, Name: %s] is not found", resourceGroup, vmName))
+	}
+
+	provisioningState := utils.GetProvisioningState(instanceView)
+	if strings.EqualFold(provisioningState, utils.ProvisioningStateFailed) {
+		return status.Error(codes.Internal, fmt.Sprintf("VM: [ResourceGroup: %s, Name: %s] is in terminal provisioning state %s", resourceGroup, vmName, provisioningState))
+	}
+	if !strings.EqualFold(provisioningState, utils.ProvisioningStateSucceeded) {
+		return status.Error(codes.Uninitialized, fmt.Sprintf("VM: [ResourceGroup: %s, Name: %s] has not yet been provisioned, provisioning state: %q", resourceGroup, vmName, provisioningState))
+	}
+
+	powerState := utils.GetPowerState(instanceView)
+	switch powerState {
+	case utils.PowerStateRunning:
+		return nil
+	case utils.PowerStateStopped, utils.PowerStateDeallocated:
+		klog.FromContext(ctx).Info("Starting VM as it is not running", "vm", vmName, "powerState", powerState)
+		if err = accesshelpers.StartVirtualMachine(ctx, vmAccess, resourceGroup, vmName); err != nil {
+			return status.WrapError(codes.Uninitialized, fmt.Sprintf("Failed to start VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+		return nil
+	default:
+		return status.Error(codes.Uninitialized, fmt.Sprintf("VM: [ResourceGroup: %s, Name: %s] is not yet running, power state: %q", resourceGroup, vmName, powerState))
+	}
+}
+
+// CheckVirtualMachineState checks the provisioning and power state reported by the instance view of the VM. If the VM has
+// not yet been provisioned or is not running then an error with code codes.Uninitialized is returned, so that the VM is
+// initialized (see EnsureVirtualMachineIsRunning). A VM in terminal provisioning state is only logged and no error is
+// returned, since MCM treats any other error code for an existing VM as a failure of the machine, which would block its
+// deletion. If the VM has no instance view then its state is unknown and no error is returned.
+func CheckVirtualMachineState(ctx context.Context, resourceGroup string, vm *armcompute.VirtualMachine) error {
+	if vm.Properties == nil || vm.Properties.InstanceView == nil {
+		return nil
+	}
+	vmName := pointer.StringDeref(vm.Name, "")
+	provisioningState := utils.GetProvisioningState(vm.Properties.InstanceView)
+	powerState := utils.GetPowerState(vm.Properties.InstanceView)
+	if strings.EqualFold(provisioningState, utils.ProvisioningStateFailed) {
+		klog.FromContext(ctx).Info("VM is in terminal provisioning state", "vm", vmName, "provisioningState", provisioningState, "powerState", powerState)
+		return nil
+	}
+	if !strings.EqualFold(provisioningState, utils.ProvisioningStateSucceeded) || powerState != utils.PowerStateRunning {
+		return status.Error(codes.Uninitialized, fmt.Sprintf("VM: [ResourceGroup: %s, Name: %s] is not ready, provisioning state: %q, power state: %q", resourceGroup, vmName, provisioningState, powerState))
 	}
 	return nil
 }
@@ -206,7 +489,7 @@ func CanUpdateVirtualMachine(vm *armcompute.VirtualMachine) bool {
 
 // computeDeleteOptionUpdatesForNICsAndDisksIfRequired computes changes required to set cascade delete options for NICs, OSDisk and DataDisks.
 // If there are no changes then a nil is returned. If there are changes then delta changes are captured in armcompute.VirtualMachineUpdate
-func computeDeleteOptionUpdatesForNICsAndDisksIfRequired(resourceGroup string, vm *armcompute.VirtualMachine, providerSpec api.AzureProviderSpec) *armcompute.VirtualMachineUpdate {
+func computeDeleteOptionUpdatesForNICsAndDisksIfRequired(ctx context.Context, resourceGroup string, vm *armcompute.VirtualMachine, providerSpec api.AzureProviderSpec) *armcompute.VirtualMachineUpdate {
 	var (
 		vmUpdateParams       *armcompute.VirtualMachineUpdate
 		updatedNicReferences []*armcompute.NetworkInterfaceReference
@@ -217,7 +500,7 @@ func computeDeleteOptionUpdatesForNICsAndDisksIfRequired(resourceGroup string, v
 
 	// Return early if VM does not have any properties set. This should ideally never happen.
 	if vm.Properties == nil {
-		klog.Errorf("Weird, but it seems that the VM: [ResourceGroup: %s, Name: %s] does not have Properties set, skipping computing cascade delete update computation for this VM", resourceGroup, vmName)
+		klog.FromContext(ctx).Error(nil, "VM does not have properties set, skipping the computation of cascade delete updates", "vm", vmName)
 		return vmUpdateParams
 	}
 
@@ -229,7 +512,7 @@ func computeDeleteOptionUpdatesForNICsAndDisksIfRequired(resourceGroup string, v
 	updatedDataDisks = getDataDisksToUpdate(vm.Properties.StorageProfile, dataDisksToUpdate)
 	// If there are no updates on NIC(s), OSDisk and DataDisk(s) then just return early.
 	if utils.IsSliceNilOrEmpty(updatedNicReferences) && updatedOSDisk == nil && utils.IsSliceNilOrEmpty(updatedDataDisks) {
-		klog.Infof("All configured NICs, OSDisk and DataDisks have cascade delete already set for VM: [ResourceGroup: %s, Name: %s]", resourceGroup, vmName)
+		klog.FromContext(ctx).Info("All configured NICs, OSDisk and DataDisks of the VM already have cascade delete set", "vm", vmName)
 		return vmUpdateParams
 	}
 
@@ -240,13 +523,13 @@ func computeDeleteOptionUpdatesForNICsAndDisksIfRequired(resourceGroup string, v
 	}
 
 	if !utils.IsSliceNilOrEmpty(updatedNicReferences) {
-		klog.Infof("Identified #%d NICs requiring DeleteOption updates for VM: [ResourceGroup: %s, Name: %s]", len(updatedNicReferences), resourceGroup, vmName)
+		klog.FromContext(ctx).Info("Identified NICs of the VM requiring DeleteOption updates", "vm", vmName, "nicCount", len(updatedNicReferences))
 		vmUpdateParams.Properties.NetworkProfile = &armcompute.NetworkProfile{
 			NetworkInterfaces: updatedNicReferences,
 		}
 	}
 	if updatedOSDisk != nil {
-		klog.Infof("Identified OSDisk: %s requiring DeleteOption update for VM: [ResourceGroup: %s, Name: %s]", *updatedOSDisk.Name, resourceGroup, vmName)
+		klog.FromContext(ctx).Info("Identified OSDisk of the VM requiring DeleteOption update", "vm", vmName, "osDisk", *updatedOSDisk.Name)
 		vmUpdateParams.Properties.StorageProfile.OSDisk = updatedOSDisk
 	}
 	if !utils.IsSliceNilOrEmpty(updatedDataDisks) {
@@ -330,17 +613,27 @@ func createNICDeleteTask(resourceGroup, nicName string, nicAccess *armnetwork.In
 	return utils.Task{
 		Name: fmt.Sprintf("delete-nic-[resourceGroup: %s name: %s]", resourceGroup, nicName),
 		Fn: func(ctx context.Context) error {
-			klog.Infof("Attempting to delete nic: [ResourceGroup: %s, NicName: %s] if it exists", resourceGroup, nicName)
+			klog.FromContext(ctx).Info("Attempting to delete NIC if it exists", "nic", nicName)
 			return accesshelpers.DeleteNIC(ctx, nicAccess, resourceGroup, nicName)
 		},
 	}
 }
 
+func createPublicIPDeleteTask(resourceGroup, publicIPName string, publicIPAccess *armnetwork.PublicIPAddressesClient) utils.Task {
+	return utils.Task{
+		Name: fmt.Sprintf("delete-public-ip-[resourceGroup: %s name: %s]", resourceGroup, publicIPName),
+		Fn: func(ctx context.Context) error {
+			klog.FromContext(ctx).Info("Attempting to delete public IP address", "publicIPResourceGroup", resourceGroup, "publicIP", publicIPName)
+			return accesshelpers.DeletePublicIPAddress(ctx, publicIPAccess, resourceGroup, publicIPName)
+		},
+	}
+}
+
 func createDisksDeletionTasks(resourceGroup string, diskNames []string, diskAccess *armcompute.DisksClient) []utils.Task {
 	tasks := make([]utils.Task, 0, len(diskNames))
 	for _, diskName := range diskNames {
 		taskFn := func(ctx context.Context) error {
-			klog.Infof("Attempting to delete disk: [ResourceGroup: %s, DiskName: %s] if it exists", resourceGroup, diskName)
+			klog.FromContext(ctx).Info("Attempting to delete disk if it exists", "disk", diskName)
 			return accesshelpers.DeleteDisk(ctx, diskAccess, resourceGroup, diskName)
 		}
 		tasks = append(tasks, utils.Task{
@@ -354,26 +647,69 @@ func createDisksDeletionTasks(resourceGroup string, diskNames []string, diskAcce
 // Helper functions for driver.CreateMachine
 // ---------------------------------------------------------------------------------------------------------------------
 
-// GetSubnet gets the subnet for the subnet configuration in the provider config.
+// GetSubnet gets the subnet for the subnet configuration in the provider config. Subnets rarely change, so they are cached
+// if a TTL has been configured via SetLookupCacheTTLs.
 func GetSubnet(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) (*armnetwork.Subnet, error) {
-	vnetResourceGroup := providerSpec.ResourceGroup
-	if !utils.IsNilOrEmptyStringPtr(providerSpec.SubnetInfo.VnetResourceGroup) {
-		vnetResourceGroup = *providerSpec.SubnetInfo.VnetResourceGroup
+	subscriptionID, vnetResourceGroup, vnetName, subnetName, err := getSubnetCoordinates(connectConfig.SubscriptionID, providerSpec)
+	if err != nil {
+		return nil, status.WrapError(codes.InvalidArgument, fmt.Sprintf("failed to determine subnet, Err: %v", err), err)
 	}
-	subnetAccess, err := factory.GetSubnetAccess(connectConfig)
+	cache := getSubnetCache()
+	cacheKey := createSubnetCacheKey(subscriptionID, vnetResourceGroup, vnetName, subnetName)
+	if cache != nil {
+		if subnet, ok := cache.Get(cacheKey); ok {
+			return subnet, nil
+		}
+	}
+	// the virtual network can be located in another subscription than the VM, e.g. in a hub-spoke network topology
+	subnetConnectConfig := connectConfig
+	subnetConnectConfig.SubscriptionID = subscriptionID
+	subnetAccess, err := factory.GetSubnetAccess(subnetConnectConfig)
 	if err != nil {
 		return nil, status.WrapError(codes.Internal, fmt.Sprintf("failed to create subnet access, Err: %v", err), err)
 	}
-	subnet, err := accesshelpers.GetSubnet(ctx, subnetAccess, vnetResourceGroup, providerSpec.SubnetInfo.VnetName, providerSpec.SubnetInfo.SubnetName)
+	subnet, err := accesshelpers.GetSubnet(ctx, subnetAccess, vnetResourceGroup, vnetName, subnetName)
 	if err != nil {
-		return nil, status.WrapError(codes.Internal, fmt.Sprintf("failed to get subnet: [ResourceGroup: %s, Name: %s, VNetName: %s], Err: %v", vnetResourceGroup, providerSpec.SubnetInfo.SubnetName, providerSpec.SubnetInfo.VnetName, err), err)
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to get subnet: [Subscription: %s, ResourceGroup: %s, Name: %s, VNetName: %s], Err: %v", subscriptionID, vnetResourceGroup, subnetName, vnetName, err), err)
+	}
+	klog.FromContext(ctx).Info("Retrieved subnet", "subnetSubscription", subscriptionID, "vnetResourceGroup", vnetResourceGroup, "vnet", vnetName, "subnet", subnetName)
+	if cache != nil {
+		cache.Set(cacheKey, subnet)
 	}
-	klog.Infof("Retrieved Subnet: [ResourceGroup: %s, Name:%s, VNetName: %s]", vnetResourceGroup, providerSpec.SubnetInfo.SubnetName, providerSpec.SubnetInfo.VnetName)
 	return subnet, nil
 }
 
-// CreateNICIfNotExists creates a NIC if it does not exist.
-func CreateNICIfNotExists(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, subnet *armnetwork.Subnet, nicName string) (string, error) {
+// getSubnetCoordinates determines the subscription, resource group, virtual network and name of the subnet configured in
+// the provider spec, either from the subnet ID or from the individual fields.
+func getSubnetCoordinates(defaultSubscriptionID string, providerSpec api.AzureProviderSpec) (subscriptionID, vnetResourceGroup, vnetName, subnetName string, err error) {
+	subnetInfo := providerSpec.SubnetInfo
+	if !utils.IsNilOrEmptyStringPtr(subnetInfo.SubnetID) {
+		var resourceID *arm.ResourceID
+		resourceID, err = arm.ParseResourceID(*subnetInfo.SubnetID)
+		if err != nil {
+			return
+		}
+		if resourceID.Parent == nil {
+			err = fmt.Errorf("subnet ID %s does not reference a virtual network", *subnetInfo.SubnetID)
+			return
+		}
+		return resourceID.SubscriptionID, resourceID.ResourceGroupName, resourceID.Parent.Name, resourceID.Name, nil
+	}
+
+	subscriptionID = defaultSubscriptionID
+	if !utils.IsNilOrEmptyStringPtr(subnetInfo.SubscriptionID) {
+		subscriptionID = *subnetInfo.SubscriptionID
+	}
+	vnetResourceGroup = providerSpec.ResourceGroup
+	if !utils.IsNilOrEmptyStringPtr(subnetInfo.VnetResourceGroup) {
+		vnetResourceGroup = *subnetInfo.VnetResourceGroup
+	}
+	return subscriptionID, vnetResourceGroup, subnetInfo.VnetName, subnetInfo.SubnetName, nil
+}
+
+// CreateNICIfNotExists creates a NIC if it does not exist. The NIC is tagged with the name of the machine it belongs to.
+func CreateNICIfNotExists(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, subnet *armnetwork.Subnet, vmName, machineName string) (string, error) {
+	nicName := utils.CreateNICName(vmName)
 	nicAccess, err := factory.GetNetworkInterfacesAccess(connectConfig)
 	if err != nil {
 		return "", status.WrapError(codes.Internal, fmt.Sprintf("failed to create nic access, Err: %v", err), err)
@@ -381,28 +717,30 @@ func CreateNICIfNotExists(ctx context.Context, factory access.Factory, connectCo
 	resourceGroup := providerSpec.ResourceGroup
 	existingNIC, err := accesshelpers.GetNIC(ctx, nicAccess, resourceGroup, nicName)
 	if err != nil {
-		return "", status.WrapError(codes.Internal, fmt.Sprintf("Failed to get NIC: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, nicName, err), err)
+		return "", status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get NIC: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, nicName, err), err)
 	}
 	if existingNIC != nil {
-		klog.Infof("[ResourceGroup: %s, NIC: [Name: %s, ID: %s]] exists, will skip creation of the NIC", resourceGroup, nicName, *existingNIC.ID)
+		klog.FromContext(ctx).Info("NIC exists, will skip creation of the NIC", "nic", nicName, "nicID", *existingNIC.ID)
 		return *existingNIC.ID, nil
 	}
 	// NIC is not found, create NIC
-	nicCreationParams := createNICParams(providerSpec, subnet, nicName)
+	nicCreationParams := createNICParams(providerSpec, subnet, vmName, machineName)
 	nic, err := accesshelpers.CreateNIC(ctx, nicAccess, providerSpec.ResourceGroup, nicCreationParams, nicName)
 	if err != nil {
-		return "", status.WrapError(codes.Internal, fmt.Sprintf("failed to create NIC: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, nicName, err), err)
+		return "", status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to create NIC: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, nicName, err), err)
 	}
-	klog.Infof("Successfully created NIC: [ResourceGroup: %s, NIC: [Name: %s, ID: %s]]", resourceGroup, nicName, *nic.ID)
+	klog.FromContext(ctx).Info("Successfully created NIC", "nic", nicName, "nicID", *nic.ID)
 	return *nic.ID, nil
 }
 
-func createNICParams(providerSpec api.AzureProviderSpec, subnet *armnetwork.Subnet, nicName string) armnetwork.Interface {
+func createNICParams(providerSpec api.AzureProviderSpec, subnet *armnetwork.Subnet, vmName, machineName string) armnetwork.Interface {
+	nicName := utils.CreateNICName(vmName)
 	return armnetwork.Interface{
 		Location: to.Ptr(providerSpec.Location),
 		Properties: &armnetwork.InterfacePropertiesFormat{
+			DNSSettings:                 getNICDNSSettings(providerSpec.Properties.NetworkProfile.DNSServers),
 			EnableAcceleratedNetworking: providerSpec.Properties.NetworkProfile.AcceleratedNetworking,
-			EnableIPForwarding:          to.Ptr(true),
+			EnableIPForwarding:          getIPForwarding(providerSpec.Properties.NetworkProfile.IPForwarding),
 			IPConfigurations: []*armnetwork.InterfaceIPConfiguration{
 				{
 					Name: &nicName,
@@ -412,19 +750,56 @@ func createNICParams(providerSpec api.AzureProviderSpec, subnet *armnetwork.Subn
 					},
 				},
 			},
-			NicType: to.Ptr(armnetwork.NetworkInterfaceNicTypeStandard),
+			NicType:       getNICType(providerSpec.Properties.NetworkProfile.NicType),
+			AuxiliaryMode: getNICAuxiliaryMode(providerSpec.Properties.NetworkProfile.AuxiliaryMode),
+			AuxiliarySKU:  getNICAuxiliarySKU(providerSpec.Properties.NetworkProfile.AuxiliarySKU),
 		},
-		Tags: createNICTags(providerSpec.Tags),
+		Tags: utils.CreateVMTags(providerSpec.Tags, machineName),
 		Name: &nicName,
 	}
 }
 
-func createNICTags(tags map[string]string) map[string]*string {
-	nicTags := make(map[string]*string, len(tags))
-	for k, v := range tags {
-		nicTags[k] = to.Ptr(v)
+// getIPForwarding returns if IP forwarding should be enabled on the NIC, it defaults to true if not configured.
+func getIPForwarding(ipForwarding *bool) *bool {
+	if ipForwarding == nil {
+		return to.Ptr(true)
+	}
+	return ipForwarding
+}
+
+// getNICType returns the type of the NIC, it defaults to defaulting.DefaultNICType if not configured.
+func getNICType(nicType *string) *armnetwork.NetworkInterfaceNicType {
+	if nicType == nil {
+		return to.Ptr(armnetwork.NetworkInterfaceNicType(defaulting.DefaultNICType))
+	}
+	return to.Ptr(armnetwork.NetworkInterfaceNicType(*nicType))
+}
+
+func getNICAuxiliaryMode(auxiliaryMode *string) *armnetwork.NetworkInterfaceAuxiliaryMode {
+	if auxiliaryMode == nil {
+		return nil
+	}
+	return to.Ptr(armnetwork.NetworkInterfaceAuxiliaryMode(*auxiliaryMode))
+}
+
+func getNICAuxiliarySKU(auxiliarySKU *string) *armnetwork.NetworkInterfaceAuxiliarySKU {
+	if auxiliarySKU == nil {
+		return nil
+	}
+	return to.Ptr(armnetwork.NetworkInterfaceAuxiliarySKU(*auxiliarySKU))
+}
+
+func getNICDNSSettings(dnsServers []string) *armnetwork.InterfaceDNSSettings {
+	if utils.IsSliceNilOrEmpty(dnsServers) {
+		return nil
+	}
+	nicDNSServers := make([]*string, 0, len(dnsServers))
+	for _, dnsServer := range dnsServers {
+		nicDNSServers = append(nicDNSServers, to.Ptr(dnsServer))
+	}
+	return &armnetwork.InterfaceDNSSettings{
+		DNSServers: nicDNSServers,
 	}
-	return nicTags
 }
 
 // ProcessVMImageConfiguration gets the image configuration from provider spec. If the VM image configured is a marketplace image then it will additionally do the following:
@@ -432,13 +807,51 @@ func createNICTags(tags map[string]string) map[string]*string {
 // 2. From the VM Image it checks if there is a plan.
 // 3. If there is a plan then it will check if there is an existing agreement for this plan. If an agreement does not exist then it will return an error.
 // 4. If the agreement has not been accepted yet then it will accept the agreement and update the agreement. If that fails then it will return an error.
-func ProcessVMImageConfiguration(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmName string) (imgRef armcompute.ImageReference, plan *armcompute.Plan, err error) {
-	imgRef = getImageReference(providerSpec)
+// If the acceptance of agreements is disabled then an error is returned instead.
+//
+// If a purchase plan is configured in the provider spec then it is used instead of the plan of the image, see processPurchasePlanFromSpec.
+// If a hyperVGeneration is configured for a marketplace image then it is validated against the generation of the VM image.
+// If a shared or community gallery image refers to the latest version then it is resolved to the concrete version, see ResolveLatestGalleryImageVersion.
+// The version used by a previous attempt, passed as previousImageID, is kept though, see GetPreviousGalleryImageVersion.
+// Gallery images can wrap marketplace images, in which case the purchase plan of the gallery image is returned and its agreement is processed as described above.
+func ProcessVMImageConfiguration(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmName, previousImageID string) (imgRef armcompute.ImageReference, plan *armcompute.Plan, err error) {
+	// no image is used if the OSDisk is restored.
+	if IsOSDiskRestored(providerSpec) {
+		return
+	}
+	var reused bool
+	if imgRef, reused = GetPreviousGalleryImageVersion(getImageReference(providerSpec), previousImageID); reused {
+		klog.FromContext(ctx).Info("Using gallery image version of previous attempt to create the VM", "vm", vmName, "imageID", previousImageID)
+	} else if imgRef, err = ResolveLatestGalleryImageVersion(ctx, factory, connectConfig, providerSpec.Location, imgRef); err != nil {
+		return
+	}
+
+	imageRefSpec := providerSpec.Properties.StorageProfile.ImageReference
+	if imageRefSpec.Plan != nil {
+		plan, err = processPurchasePlanFromSpec(ctx, factory, connectConfig, imageRefSpec, vmName)
+		if err != nil || imageRefSpec.URN == nil || imageRefSpec.HyperVGeneration == nil {
+			return
+		}
+		// the VM image is only retrieved to validate the hyperVGeneration, its plan is not required anymore.
+		var vmImage *armcompute.VirtualMachineImage
+		if vmImage, err = getVirtualMachineImage(ctx, factory, connectConfig, providerSpec.Location, imgRef); err != nil {
+			return
+		}
+		err = validateVMImageHyperVGeneration(*vmImage, *imageRefSpec.HyperVGeneration)
+		return
+	}
 
-	shouldCheckMarketplaceImage := providerSpec.Properties.StorageProfile.ImageReference.URN != nil && !providerSpec.Properties.StorageProfile.ImageReference.SkipMarketplaceAgreement
+	if imgRef.SharedGalleryImageID != nil || imgRef.CommunityGalleryImageID != nil {
+		plan, err = processGalleryImagePurchasePlan(ctx, factory, connectConfig, providerSpec.Location, imgRef, imageRefSpec, vmName)
+		return
+	}
+
+	isMarketplaceImage := imageRefSpec.URN != nil
+	shouldCheckAgreement := isMarketplaceImage && !imageRefSpec.SkipMarketplaceAgreement
+	shouldCheckHyperVGeneration := isMarketplaceImage && imageRefSpec.HyperVGeneration != nil
 
-	// skip checking agreement if this is not a Marketplace image or if we explicitly opt out from checking.
-	if !shouldCheckMarketplaceImage {
+	// skip getting the image if this is not a Marketplace image or if we explicitly opt out from checking and there is nothing else to validate.
+	if !shouldCheckAgreement && !shouldCheckHyperVGeneration {
 		return
 	}
 
@@ -447,9 +860,14 @@ func ProcessVMImageConfiguration(ctx context.Context, factory access.Factory, co
 	if err != nil {
 		return
 	}
-	klog.Infof("Retrieved VM Image: [VMName: %s, ID: %s]", vmName, *vmImage.ID)
-	if vmImage.Properties != nil && vmImage.Properties.Plan != nil {
-		err = checkAndAcceptAgreementIfNotAccepted(ctx, factory, connectConfig, vmName, *vmImage)
+	klog.FromContext(ctx).Info("Retrieved VM image", "vm", vmName, "imageID", *vmImage.ID)
+	if shouldCheckHyperVGeneration {
+		if err = validateVMImageHyperVGeneration(*vmImage, *imageRefSpec.HyperVGeneration); err != nil {
+			return
+		}
+	}
+	if shouldCheckAgreement && vmImage.Properties != nil && vmImage.Properties.Plan != nil {
+		err = checkAndAcceptAgreementIfNotAccepted(ctx, factory, connectConfig, vmName, *vmImage.ID, *vmImage.Properties.Plan, !imageRefSpec.DisableMarketplaceAgreementAcceptance)
 		if err != nil {
 			return
 		}
@@ -462,6 +880,57 @@ func ProcessVMImageConfiguration(ctx context.Context, factory access.Factory, co
 	return imgRef, plan, nil
 }
 
+// processPurchasePlanFromSpec returns the purchase plan configured in the provider spec. Unless SkipMarketplaceAgreement is set,
+// the agreement for the plan is checked and accepted in the same way as it is done for a plan of a marketplace image.
+func processPurchasePlanFromSpec(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, imageRefSpec api.AzureImageReference, vmName string) (*armcompute.Plan, error) {
+	planSpec := imageRefSpec.Plan
+	plan := &armcompute.Plan{
+		Name:      to.Ptr(planSpec.Name),
+		Product:   to.Ptr(planSpec.Product),
+		Publisher: to.Ptr(planSpec.Publisher),
+	}
+	if imageRefSpec.SkipMarketplaceAgreement {
+		return plan, nil
+	}
+	purchasePlan := armcompute.PurchasePlan{
+		Name:      plan.Name,
+		Product:   plan.Product,
+		Publisher: plan.Publisher,
+	}
+	if err := checkAndAcceptAgreementIfNotAccepted(ctx, factory, connectConfig, vmName, getImageIdentifier(imageRefSpec), purchasePlan, !imageRefSpec.DisableMarketplaceAgreementAcceptance); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// getImageIdentifier returns the identifier of the configured image, which is used to refer to the image in logs and errors.
+func getImageIdentifier(imageRefSpec api.AzureImageReference) string {
+	switch {
+	case !utils.IsEmptyString(imageRefSpec.ID):
+		return imageRefSpec.ID
+	case !utils.IsNilOrEmptyStringPtr(imageRefSpec.URN):
+		return *imageRefSpec.URN
+	case !utils.IsNilOrEmptyStringPtr(imageRefSpec.SharedGalleryImageID):
+		return *imageRefSpec.SharedGalleryImageID
+	case !utils.IsNilOrEmptyStringPtr(imageRefSpec.CommunityGalleryImageID):
+		return *imageRefSpec.CommunityGalleryImageID
+	default:
+		return ""
+	}
+}
+
+// validateVMImageHyperVGeneration validates that the configured hyperVGeneration matches the generation of the VM image.
+// Images which do not report a generation are not validated.
+func validateVMImageHyperVGeneration(vmImage armcompute.VirtualMachineImage, hyperVGeneration string) error {
+	if vmImage.Properties == nil || vmImage.Properties.HyperVGeneration == nil {
+		return nil
+	}
+	if imageHyperVGeneration := string(*vmImage.Properties.HyperVGeneration); !strings.EqualFold(imageHyperVGeneration, hyperVGeneration) {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("hyperVGeneration %s is configured but VM Image %s has hyperVGeneration %s", hyperVGeneration, *vmImage.ID, imageHyperVGeneration))
+	}
+	return nil
+}
+
 func getImageReference(providerSpec api.AzureProviderSpec) armcompute.ImageReference {
 	imgRefInfo := providerSpec.Properties.StorageProfile.ImageReference
 
@@ -494,7 +963,15 @@ func getImageReference(providerSpec api.AzureProviderSpec) armcompute.ImageRefer
 	}
 }
 
+// getVirtualMachineImage gets the marketplace VM image, which is cached if a TTL has been configured via SetLookupCacheTTLs.
 func getVirtualMachineImage(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, location string, imageReference armcompute.ImageReference) (*armcompute.VirtualMachineImage, error) {
+	cache := getVMImageCache()
+	cacheKey := createVMImageCacheKey(connectConfig.SubscriptionID, location, imageReference)
+	if cache != nil {
+		if vmImage, ok := cache.Get(cacheKey); ok {
+			return vmImage, nil
+		}
+	}
 	vmImagesAccess, err := factory.GetVirtualMachineImagesAccess(connectConfig)
 	if err != nil {
 		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create image access, Err: %v", err), err)
@@ -504,7 +981,10 @@ func getVirtualMachineImage(ctx context.Context, factory access.Factory, connect
 		if accesserrors.IsNotFoundAzAPIError(err) {
 			return nil, status.WrapError(codes.NotFound, fmt.Sprintf("VM Image %v does not exist", imageReference), err)
 		}
-		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to retrieve VM Image: %v", imageReference), err)
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to retrieve VM Image: %v", imageReference), err)
+	}
+	if cache != nil {
+		cache.Set(cacheKey, vmImage)
 	}
 	return vmImage, nil
 }
@@ -514,38 +994,64 @@ func getVirtualMachineImage(ctx context.Context, factory access.Factory, connect
 // NOTE: Today agreement needs to be created by the customer. However, if the agreement has not been accepted then we accept the agreement on behalf of the customer. This is not really ideal and is only done
 // for ease of consumption of garden-linux image. This should be done till the point garden-linux VM image is eventually made available as a community image. As of today community gallery is a alpha feature.
 // Once it becomes GA then we should shift to using community image for garden-linux. Then we should remove the code which accepts the agreement on behalf of the customer.
-func checkAndAcceptAgreementIfNotAccepted(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, vmName string, vmImage armcompute.VirtualMachineImage) error {
+// The imageID is the ID of the VM image or gallery image which has the purchase plan and is only used for logging.
+// If acceptAgreement is false then the agreement is not accepted on behalf of the customer and an error is returned if it has not been accepted yet.
+// Accepted agreement terms are cached if a TTL has been configured via SetLookupCacheTTLs.
+func checkAndAcceptAgreementIfNotAccepted(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, vmName string, imageID string, plan armcompute.PurchasePlan, acceptAgreement bool) error {
+	cache := getAgreementTermsCache()
+	cacheKey := createAgreementTermsCacheKey(connectConfig.SubscriptionID, plan)
+	if cache != nil {
+		if _, ok := cache.Get(cacheKey); ok {
+			return nil
+		}
+	}
 	agreementsAccess, err := factory.GetMarketPlaceAgreementsAccess(connectConfig)
 	if err != nil {
-		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to create marketplace agreement access to process request for vm-image: %s, Err: %v", *vmImage.Name, err), err)
+		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to create marketplace agreement access to process request for image: %s, Err: %v", imageID, err), err)
 	}
-	plan := *vmImage.Properties.Plan
 	agreementTerms, err := accesshelpers.GetAgreementTerms(ctx, agreementsAccess, plan)
 	if err != nil {
 		if accesserrors.IsNotFoundAzAPIError(err) {
 			return status.WrapError(codes.NotFound, fmt.Sprintf("Marketplace Image Agreement for Plan [Name: %s, Product: %s, Publisher: %s] does not exist", *plan.Name, *plan.Product, *plan.Publisher), err)
 		}
-		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to retrieve Marketplace Image Agreement for Plan [Name: %s, Product: %s, Publisher: %s]", *plan.Name, *plan.Product, *plan.Publisher), err)
+		return status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to retrieve Marketplace Image Agreement for Plan [Name: %s, Product: %s, Publisher: %s]", *plan.Name, *plan.Product, *plan.Publisher), err)
 
 	}
-	klog.Infof("Retrieved Marketplace Image Agreement for Plan [Name: %s, Product: %s, Publisher: %s]", *plan.Name, *plan.Product, *plan.Publisher)
+	klog.FromContext(ctx).Info("Retrieved marketplace image agreement", "plan", *plan.Name, "product", *plan.Product, "publisher", *plan.Publisher)
 	if agreementTerms.Properties.Accepted == nil || !*agreementTerms.Properties.Accepted {
-		err = accesshelpers.AcceptAgreement(ctx, agreementsAccess, *vmImage.Properties.Plan, *agreementTerms)
+		if !acceptAgreement {
+			return status.Error(codes.FailedPrecondition, fmt.Sprintf("Marketplace Image Agreement for Plan [Name: %s, Product: %s, Publisher: %s] of image %s has not been accepted and automatic acceptance is disabled. "+
+				"Accept the agreement for the subscription, e.g. with 'az vm image terms accept --publisher %s --offer %s --plan %s'", *plan.Name, *plan.Product, *plan.Publisher, imageID, *plan.Publisher, *plan.Product, *plan.Name))
+		}
+		err = accesshelpers.AcceptAgreement(ctx, agreementsAccess, plan, *agreementTerms)
 		if err != nil {
-			return status.WrapError(codes.Internal, fmt.Sprintf("Failed to accept agreement for [VMName: %s, VMImageID: %s, Plan: {Name: %s, Product: %s, Publisher: %s}] Err: %v", vmName, *vmImage.ID, *plan.Name, *plan.Product, *plan.Publisher, err), err)
+			return status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to accept agreement for [VMName: %s, ImageID: %s, Plan: {Name: %s, Product: %s, Publisher: %s}] Err: %v", vmName, imageID, *plan.Name, *plan.Product, *plan.Publisher, err), err)
 		}
 	}
-	klog.Infof("Successfully validated/updated agreement terms as accepted for [VMName: %s, VMImage: %s, AgreementID: %s]", vmName, *vmImage.ID, *agreementTerms.ID)
+	klog.FromContext(ctx).Info("Successfully validated/updated agreement terms as accepted", "vm", vmName, "imageID", imageID, "agreementID", *agreementTerms.ID)
+	if cache != nil {
+		cache.Set(cacheKey, agreementTerms)
+	}
+	return nil
+}
+
+// ValidateSecretForVMCreation validates that the secret contains all data which is additionally required to create a VM for the provider spec.
+func ValidateSecretForVMCreation(secret *corev1.Secret, providerSpec api.AzureProviderSpec) error {
+	if err := validation.ValidateProviderSecretForCreate(secret, providerSpec); err != nil {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("error in validating secret: %v", err))
+	}
 	return nil
 }
 
 // CreateVM gathers the VM creation parameters and invokes a call to create or update the VM.
-func CreateVM(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmImageRef armcompute.ImageReference, plan *armcompute.Plan, secret *corev1.Secret, nicID string, vmName string, imageRefDiskIDs map[DataDiskLun]DiskID) (*armcompute.VirtualMachine, error) {
-	vmAccess, err := factory.GetVirtualMachinesAccess(connectConfig)
+// If osDiskID is set then the VM is created by attaching the restored OSDisk instead of creating the OSDisk from the image.
+func CreateVM(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmImageRef armcompute.ImageReference, plan *armcompute.Plan, secret *corev1.Secret, nicID, vmName, machineName string, imageRefDiskIDs map[DataDiskLun]DiskID, osDiskID DiskID) (*armcompute.VirtualMachine, error) {
+	// the image can be hosted in another tenant in which case the VM creation request needs a token for that tenant as well.
+	vmAccess, err := factory.GetVirtualMachinesAccess(WithImageTenant(connectConfig, providerSpec.Properties.StorageProfile.ImageReference))
 	if err != nil {
 		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine access to process request: [resourceGroup: %s, vmName: %s], Err: %v", providerSpec.ResourceGroup, vmName, err), err)
 	}
-	vmCreationParams, err := createVMCreationParams(providerSpec, vmImageRef, plan, secret, nicID, vmName, imageRefDiskIDs)
+	vmCreationParams, err := createVMCreationParams(providerSpec, vmImageRef, plan, secret, nicID, vmName, machineName, imageRefDiskIDs, osDiskID)
 	if err != nil {
 		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine parameters to create VM: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, vmName, err), err)
 	}
@@ -554,30 +1060,55 @@ func CreateVM(ctx context.Context, factory access.Factory, connectConfig access.
 		errCode := accesserrors.GetMatchingErrorCode(err)
 		return nil, status.WrapError(errCode, fmt.Sprintf("Failed to create VirtualMachine: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, vmName, err), err)
 	}
-	klog.Infof("Successfully created VM: [ResourceGroup: %s, Name: %s]", providerSpec.ResourceGroup, vmName)
+	klog.FromContext(ctx).Info("Successfully created VM", "vm", vmName)
 	return vm, nil
 }
 
-// CreateDisksWithImageRef creates a disk with CreationData (e.g. ImageReference or GalleryImageReference)
-func CreateDisksWithImageRef(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmName string) (map[DataDiskLun]DiskID, error) {
+// CreateDisksWithImageRef creates the data disks which have to exist before the VM is created. These are disks with CreationData
+// (e.g. ImageReference, GalleryImageReference or a source snapshot) and shared disks. Disks which have already been created
+// by a previous attempt, as passed via createdDiskIDs, are not created again.
+func CreateDisksWithImageRef(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmName, machineName string, createdDiskIDs map[DataDiskLun]string) (map[DataDiskLun]DiskID, error) {
+	var dataDiskSpecs []api.AzureDataDisk
+	for _, specDataDisk := range providerSpec.Properties.StorageProfile.DataDisks {
+		if _, ok := createdDiskIDs[DataDiskLun(specDataDisk.Lun)]; ok {
+			continue
+		}
+		dataDiskSpecs = append(dataDiskSpecs, specDataDisk)
+	}
+	disks := make(map[DataDiskLun]DiskID, len(createdDiskIDs))
+	for lun, diskID := range createdDiskIDs {
+		disks[lun] = to.Ptr(diskID)
+	}
+	if len(dataDiskSpecs) == 0 {
+		return disks, nil
+	}
+
 	disksAccess, err := factory.GetDisksAccess(connectConfig)
 	if err != nil {
-		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create disk access for VM: [ResourceGroup: %s], Err: %v", providerSpec.ResourceGroup, err), err)
+		return disks, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create disk access for VM: [ResourceGroup: %s], Err: %v", providerSpec.ResourceGroup, err), err)
 	}
+	createdDisks, err := createPreCreatedDataDisks(ctx, factory, connectConfig, disksAccess, providerSpec, dataDiskSpecs, vmName, machineName)
+	for lun, diskID := range createdDisks {
+		disks[lun] = diskID
+	}
+	return disks, err
+}
 
+// createPreCreatedDataDisks creates those of the passed data disks which have to exist before they are attached to the VM.
+// If the creation of a disk fails then the disks which have been created so far are returned alongside the error.
+func createPreCreatedDataDisks(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, disksAccess *armcompute.DisksClient, providerSpec api.AzureProviderSpec, dataDiskSpecs []api.AzureDataDisk, vmName, machineName string) (map[DataDiskLun]DiskID, error) {
 	disks := make(map[DataDiskLun]DiskID)
-	dataDiskSpecs := providerSpec.Properties.StorageProfile.DataDisks
 	if utils.IsSliceNilOrEmpty(dataDiskSpecs) {
 		return disks, nil
 	}
 
 	for _, specDataDisk := range dataDiskSpecs {
-		// skip if dataDisk does not have imageRef property
-		if specDataDisk.ImageRef == nil {
+		// skip if dataDisk is created together with the VM
+		if !isPreCreatedDataDisk(specDataDisk) {
 			continue
 		}
-		diskName := utils.CreateDataDiskName(vmName, specDataDisk.Name, specDataDisk.Lun)
-		diskCreationParams, err := createDiskCreationParams(ctx, specDataDisk, providerSpec, factory, connectConfig)
+		diskName := utils.CreateDataDiskNameFromTemplate(vmName, specDataDisk.Name, specDataDisk.Lun, providerSpec.Properties.StorageProfile.DataDiskNameTemplate)
+		diskCreationParams, err := createDiskCreationParams(ctx, specDataDisk, providerSpec, machineName, factory, connectConfig)
 		if err != nil {
 			errCode := accesserrors.GetMatchingErrorCode(err)
 			return nil, status.WrapError(errCode, fmt.Sprintf("Failed to create disk creation params: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, diskName, err), err)
@@ -585,16 +1116,100 @@ func CreateDisksWithImageRef(ctx context.Context, factory access.Factory, connec
 		disk, err := accesshelpers.CreateDisk(ctx, disksAccess, providerSpec.ResourceGroup, diskName, diskCreationParams)
 		if err != nil {
 			errCode := accesserrors.GetMatchingErrorCode(err)
-			return nil, status.WrapError(errCode, fmt.Sprintf("Failed to create Disk: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, diskName, err), err)
+			return disks, status.WrapError(errCode, fmt.Sprintf("Failed to create Disk: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, diskName, err), err)
 		}
 		disks[DataDiskLun(specDataDisk.Lun)] = disk.ID
-		klog.Infof("Successfully created Disk: [ResourceGroup: %s, Name: %s]", providerSpec.ResourceGroup, diskName)
+		klog.FromContext(ctx).Info("Successfully created disk", "disk", diskName)
 	}
 
 	return disks, nil
 }
 
-func createDiskCreationParams(ctx context.Context, specDataDisk api.AzureDataDisk, providerSpec api.AzureProviderSpec, factory access.Factory, connectConfig access.ConnectConfig) (params armcompute.Disk, err error) {
+// IsOSDiskRestored checks if the OSDisk is restored from a snapshot or a disk restore point instead of being created from an image.
+func IsOSDiskRestored(providerSpec api.AzureProviderSpec) bool {
+	return providerSpec.Properties.StorageProfile.OsDisk.CreateOption == api.OSDiskCreateOptionRestore
+}
+
+// CreateRestoredOSDisk creates the OSDisk from the snapshot or disk restore point configured in the provider spec, so that it
+// can be attached to the VM. Nothing is created if the OSDisk is not restored or if it has already been created by a previous
+// attempt, as passed via createdOSDiskID.
+func CreateRestoredOSDisk(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmName, machineName string, createdOSDiskID string) (DiskID, error) {
+	if !IsOSDiskRestored(providerSpec) {
+		return nil, nil
+	}
+	if !utils.IsEmptyString(createdOSDiskID) {
+		return to.Ptr(createdOSDiskID), nil
+	}
+	disksAccess, err := factory.GetDisksAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create disk access for VM: [ResourceGroup: %s], Err: %v", providerSpec.ResourceGroup, err), err)
+	}
+	osDiskName := utils.CreateOSDiskNameFromTemplate(vmName, providerSpec.Properties.StorageProfile.OsDisk.NameTemplate)
+	disk, err := accesshelpers.CreateDisk(ctx, disksAccess, providerSpec.ResourceGroup, osDiskName, createRestoredOSDiskCreationParams(providerSpec, machineName))
+	if err != nil {
+		errCode := accesserrors.GetMatchingErrorCode(err)
+		return nil, status.WrapError(errCode, fmt.Sprintf("Failed to restore OSDisk: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, osDiskName, err), err)
+	}
+	klog.FromContext(ctx).Info("Successfully restored OSDisk", "osDisk", osDiskName)
+	return disk.ID, nil
+}
+
+func createRestoredOSDiskCreationParams(providerSpec api.AzureProviderSpec, machineName string) armcompute.Disk {
+	osDisk := providerSpec.Properties.StorageProfile.OsDisk
+	// a disk restore point can only be restored whereas a snapshot is copied.
+	creationData := &armcompute.CreationData{
+		CreateOption:     to.Ptr(armcompute.DiskCreateOptionCopy),
+		SourceResourceID: osDisk.SnapshotID,
+	}
+	if osDisk.RestorePointID != nil {
+		creationData = &armcompute.CreationData{
+			CreateOption:     to.Ptr(armcompute.DiskCreateOptionRestore),
+			SourceResourceID: osDisk.RestorePointID,
+		}
+	}
+	return armcompute.Disk{
+		Location: to.Ptr(providerSpec.Location),
+		Properties: &armcompute.DiskProperties{
+			CreationData: creationData,
+			DiskSizeGB:   pointer.Int32(osDisk.DiskSizeGB),
+			Tier:         osDisk.Tier,
+			OSType:       to.Ptr(getOSType(providerSpec.Properties.OsProfile)),
+		},
+		SKU: &armcompute.DiskSKU{
+			Name: to.Ptr(armcompute.DiskStorageAccountTypes(osDisk.ManagedDisk.StorageAccountType)),
+		},
+		// the OSDisk inherits the tags of the VM as it does if it is created together with the VM.
+		Tags:  utils.CreateVMTags(providerSpec.Tags, machineName),
+		Zones: getDiskZones(osDisk.ManagedDisk.StorageAccountType, providerSpec),
+	}
+}
+
+// UpdateOSDiskTier sets the performance tier of the OSDisk if one is configured. The tier can not be set in the OSDisk
+// parameters of the VM and therefore the OSDisk is updated once it has been created together with the VM.
+func UpdateOSDiskTier(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmName string) error {
+	osDisk := providerSpec.Properties.StorageProfile.OsDisk
+	if osDisk.Tier == nil {
+		return nil
+	}
+	disksAccess, err := factory.GetDisksAccess(connectConfig)
+	if err != nil {
+		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to create disk access for VM: [ResourceGroup: %s], Err: %v", providerSpec.ResourceGroup, err), err)
+	}
+	osDiskName := utils.CreateOSDiskNameFromTemplate(vmName, osDisk.NameTemplate)
+	diskUpdateParams := armcompute.DiskUpdate{
+		Properties: &armcompute.DiskUpdateProperties{
+			Tier: osDisk.Tier,
+		},
+	}
+	if _, err = accesshelpers.UpdateDisk(ctx, disksAccess, providerSpec.ResourceGroup, osDiskName, diskUpdateParams); err != nil {
+		errCode := accesserrors.GetMatchingErrorCode(err)
+		return status.WrapError(errCode, fmt.Sprintf("Failed to set performance tier %s for OSDisk: [ResourceGroup: %s, Name: %s], Err: %v", *osDisk.Tier, providerSpec.ResourceGroup, osDiskName, err), err)
+	}
+	klog.FromContext(ctx).Info("Successfully set performance tier of OSDisk", "osDisk", osDiskName, "tier", *osDisk.Tier)
+	return nil
+}
+
+func createDiskCreationParams(ctx context.Context, specDataDisk api.AzureDataDisk, providerSpec api.AzureProviderSpec, machineName string, factory access.Factory, connectConfig access.ConnectConfig) (params armcompute.Disk, err error) {
 	creationData, err := createDiskCreationData(ctx, specDataDisk, providerSpec.Location, factory, connectConfig)
 	if err != nil {
 		return armcompute.Disk{}, err
@@ -604,18 +1219,44 @@ func createDiskCreationParams(ctx context.Context, specDataDisk api.AzureDataDis
 		Properties: &armcompute.DiskProperties{
 			CreationData: creationData,
 			DiskSizeGB:   to.Ptr[int32](specDataDisk.DiskSizeGB),
-			OSType:       to.Ptr(armcompute.OperatingSystemTypesLinux),
+			MaxShares:    specDataDisk.MaxShares,
+			Tier:         specDataDisk.Tier,
+			OSType:       to.Ptr(getOSType(providerSpec.Properties.OsProfile)),
 		},
 		SKU: &armcompute.DiskSKU{
 			Name: to.Ptr(armcompute.DiskStorageAccountTypes(specDataDisk.StorageAccountType)),
 		},
-		Tags:  utils.CreateResourceTags(providerSpec.Tags),
-		Zones: getZonesFromProviderSpec(providerSpec),
+		Tags:  utils.CreateVMTags(providerSpec.Tags, machineName),
+		Zones: getDiskZones(specDataDisk.StorageAccountType, providerSpec),
 	}
 	return
 }
 
+// getDiskZones returns the zones in which a disk is created. Zone-redundant disks are replicated across the zones of the region
+// and can be attached to a VM in any zone, therefore they must not be pinned to the zone of the VM.
+func getDiskZones(storageAccountType string, providerSpec api.AzureProviderSpec) []*string {
+	if isZoneRedundantStorageAccountType(storageAccountType) {
+		return nil
+	}
+	return getZonesFromProviderSpec(providerSpec)
+}
+
+func isZoneRedundantStorageAccountType(storageAccountType string) bool {
+	return storageAccountType == string(armcompute.StorageAccountTypesPremiumZRS) || storageAccountType == string(armcompute.StorageAccountTypesStandardSSDZRS)
+}
+
 func createDiskCreationData(ctx context.Context, specDataDisk api.AzureDataDisk, location string, factory access.Factory, connectConfig access.ConnectConfig) (*armcompute.CreationData, error) {
+	if specDataDisk.SnapshotID != nil {
+		return &armcompute.CreationData{
+			CreateOption:     to.Ptr(armcompute.DiskCreateOptionCopy),
+			SourceResourceID: specDataDisk.SnapshotID,
+		}, nil
+	}
+	if specDataDisk.ImageRef == nil {
+		return &armcompute.CreationData{
+			CreateOption: to.Ptr(armcompute.DiskCreateOptionEmpty),
+		}, nil
+	}
 	creationData := &armcompute.CreationData{
 		CreateOption: to.Ptr(armcompute.DiskCreateOptionFromImage),
 	}
@@ -648,37 +1289,39 @@ func createDiskCreationData(ctx context.Context, specDataDisk api.AzureDataDisk,
 
 // LogVMCreation is a convenience method which helps to extract relevant details from the created virtual machine and logs it.
 // Today the azure create VM call is atomic only w.r.t creation of VM, OSDisk, DataDisk(s). NIC still has to be created prior to creation of the VM.
-// Therefore, this method produces a log which also prints the OSDisk, DataDisks that are created (which helps in traceability). For completeness it
-// also prints the NIC that now gets associated to this VM.
-func LogVMCreation(location, resourceGroup string, vm *armcompute.VirtualMachine) {
-	msgBuilder := strings.Builder{}
+// Therefore, this method produces a log which also contains the OSDisk, DataDisks that are created (which helps in traceability). For completeness it
+// also contains the NIC that now gets associated to this VM.
+func LogVMCreation(ctx context.Context, location string, vm *armcompute.VirtualMachine) {
 	vmName := *vm.Name
-	msgBuilder.WriteString(fmt.Sprintf("Successfully create Machine in [Location: %s, ResourceGroup: %s] with the following resources:\n", location, resourceGroup))
-	msgBuilder.WriteString(fmt.Sprintf("VirtualMachine: [ID: %s, Name: %s]\n", *vm.ID, vmName))
+	keysAndValues := []any{"location", location, "vm", vmName, "vmID", *vm.ID}
 	if !utils.IsSliceNilOrEmpty(vm.Properties.NetworkProfile.NetworkInterfaces) {
 		nic := vm.Properties.NetworkProfile.NetworkInterfaces[0]
-		msgBuilder.WriteString(fmt.Sprintf("NIC: [ID: %s, Name: %s]\n", *nic.ID, utils.CreateNICName(vmName)))
+		keysAndValues = append(keysAndValues, "nic", utils.CreateNICName(vmName), "nicID", *nic.ID)
 	}
 	if vm.Properties.StorageProfile.OSDisk != nil {
-		msgBuilder.WriteString(fmt.Sprintf("OSDisk: %s\n", *vm.Properties.StorageProfile.OSDisk.Name))
+		keysAndValues = append(keysAndValues, "osDisk", *vm.Properties.StorageProfile.OSDisk.Name)
 	}
 	if !utils.IsSliceNilOrEmpty(vm.Properties.StorageProfile.DataDisks) {
-		msgBuilder.WriteString("DataDisks: [ ")
+		dataDiskNames := make([]string, 0, len(vm.Properties.StorageProfile.DataDisks))
 		for _, dataDisk := range vm.Properties.StorageProfile.DataDisks {
-			msgBuilder.WriteString(fmt.Sprintf("{Name: %s} ", *dataDisk.Name))
+			dataDiskNames = append(dataDiskNames, *dataDisk.Name)
 		}
-		msgBuilder.WriteString(" ]")
+		keysAndValues = append(keysAndValues, "dataDisks", dataDiskNames)
 	}
-	klog.Infof("%s", msgBuilder.String())
+	klog.FromContext(ctx).Info("Successfully created machine", keysAndValues...)
 }
 
-func createVMCreationParams(providerSpec api.AzureProviderSpec, imageRef armcompute.ImageReference, plan *armcompute.Plan, secret *corev1.Secret, nicID, vmName string, imageRefDiskIDs map[DataDiskLun]DiskID) (armcompute.VirtualMachine, error) {
-	vmTags := utils.CreateResourceTags(providerSpec.Tags)
-	sshConfiguration, err := getSSHConfiguration(providerSpec.Properties.OsProfile.LinuxConfiguration.SSH)
-	if err != nil {
-		return armcompute.VirtualMachine{}, err
+func createVMCreationParams(providerSpec api.AzureProviderSpec, imageRef armcompute.ImageReference, plan *armcompute.Plan, secret *corev1.Secret, nicID, vmName, machineName string, imageRefDiskIDs map[DataDiskLun]DiskID, osDiskID DiskID) (armcompute.VirtualMachine, error) {
+	vmTags := utils.CreateVMTags(providerSpec.Tags, machineName)
+	// a restored OSDisk already contains a provisioned OS, hence the OS profile can not be applied.
+	var osProfile *armcompute.OSProfile
+	if osDiskID == nil {
+		var err error
+		if osProfile, err = getOSProfile(providerSpec.Properties.OsProfile, secret, vmName); err != nil {
+			return armcompute.VirtualMachine{}, err
+		}
 	}
-	dataDisks, err := getDataDisks(providerSpec.Properties.StorageProfile.DataDisks, vmName, imageRefDiskIDs)
+	dataDisks, err := getDataDisks(providerSpec.Properties.StorageProfile, vmName, imageRefDiskIDs)
 	if err != nil {
 		return armcompute.VirtualMachine{}, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create vm creation params, Err: %v", err), err)
 	}
@@ -701,18 +1344,11 @@ func createVMCreationParams(providerSpec api.AzureProviderSpec, imageRef armcomp
 					},
 				},
 			},
-			OSProfile: &armcompute.OSProfile{
-				AdminUsername: to.Ptr(providerSpec.Properties.OsProfile.AdminUsername),
-				ComputerName:  &vmName,
-				CustomData:    to.Ptr(base64.StdEncoding.EncodeToString(secret.Data["userData"])),
-				LinuxConfiguration: &armcompute.LinuxConfiguration{
-					DisablePasswordAuthentication: to.Ptr(providerSpec.Properties.OsProfile.LinuxConfiguration.DisablePasswordAuthentication),
-					SSH:                           sshConfiguration,
-				},
-			},
+			OSProfile: osProfile,
 			StorageProfile: &armcompute.StorageProfile{
-				DataDisks:      dataDisks,
-				ImageReference: &imageRef,
+				DataDisks:          dataDisks,
+				DiskControllerType: getDiskControllerType(providerSpec.Properties.StorageProfile.DiskControllerType),
+				ImageReference:     &imageRef,
 				OSDisk: &armcompute.OSDisk{
 					CreateOption: to.Ptr(armcompute.DiskCreateOptionTypes(providerSpec.Properties.StorageProfile.OsDisk.CreateOption)),
 					Caching:      to.Ptr(armcompute.CachingTypes(providerSpec.Properties.StorageProfile.OsDisk.Caching)),
@@ -721,12 +1357,19 @@ func createVMCreationParams(providerSpec api.AzureProviderSpec, imageRef armcomp
 					ManagedDisk: &armcompute.ManagedDiskParameters{
 						StorageAccountType: to.Ptr(armcompute.StorageAccountTypes(providerSpec.Properties.StorageProfile.OsDisk.ManagedDisk.StorageAccountType)),
 					},
-					Name: to.Ptr(utils.CreateOSDiskName(vmName)),
+					Name:                    to.Ptr(utils.CreateOSDiskNameFromTemplate(vmName, providerSpec.Properties.StorageProfile.OsDisk.NameTemplate)),
+					WriteAcceleratorEnabled: providerSpec.Properties.StorageProfile.OsDisk.WriteAcceleratorEnabled,
 				},
 			},
 			AvailabilitySet:        getAvailabilitySet(providerSpec.Properties.AvailabilitySet),
 			VirtualMachineScaleSet: getVirtualMachineScaleSet(providerSpec.Properties.VirtualMachineScaleSet),
+			PlatformFaultDomain:    providerSpec.Properties.PlatformFaultDomain,
 			DiagnosticsProfile:     getDiagnosticsProfile(providerSpec.Properties.DiagnosticsProfile),
+			LicenseType:            providerSpec.Properties.LicenseType,
+			AdditionalCapabilities: getAdditionalCapabilities(providerSpec.Properties.AdditionalCapabilities),
+			ScheduledEventsProfile: getScheduledEventsProfile(providerSpec.Properties.ScheduledEventsProfile),
+			UserData:               getUserData(providerSpec.Properties.UserData, secret),
+			ApplicationProfile:     getApplicationProfile(providerSpec.Properties.GalleryApplications),
 		},
 		Tags:     vmTags,
 		Zones:    getZonesFromProviderSpec(providerSpec),
@@ -749,6 +1392,14 @@ func createVMCreationParams(providerSpec api.AzureProviderSpec, imageRef armcomp
 			}
 		}
 	}
+	if osDiskID != nil {
+		vm.Properties.StorageProfile.ImageReference = nil
+		osDisk := vm.Properties.StorageProfile.OSDisk
+		osDisk.CreateOption = to.Ptr(armcompute.DiskCreateOptionTypesAttach)
+		osDisk.DiskSizeGB = nil
+		osDisk.ManagedDisk.ID = osDiskID
+		osDisk.OSType = to.Ptr(getOSType(providerSpec.Properties.OsProfile))
+	}
 	if diskSecurityProfile := providerSpec.Properties.StorageProfile.OsDisk.ManagedDisk.SecurityProfile; diskSecurityProfile != nil {
 		if diskSecurityProfile.SecurityEncryptionType != nil {
 			securityEncryptionType := armcompute.SecurityEncryptionTypes(*diskSecurityProfile.SecurityEncryptionType)
@@ -761,14 +1412,90 @@ func createVMCreationParams(providerSpec api.AzureProviderSpec, imageRef armcomp
 	return vm, nil
 }
 
-func getDataDisks(dataDiskSpecs []api.AzureDataDisk, vmName string, imageRefDiskIDs map[DataDiskLun]DiskID) ([]*armcompute.DataDisk, error) {
+// getOSProfile creates the OS profile of the VM. A Windows OS profile is created if WindowsConfiguration is set, else a Linux OS profile is created.
+func getOSProfile(osProfileSpec api.AzureOSProfile, secret *corev1.Secret, vmName string) (*armcompute.OSProfile, error) {
+	osProfile := &armcompute.OSProfile{
+		AdminUsername: to.Ptr(osProfileSpec.AdminUsername),
+		ComputerName:  &vmName,
+		CustomData:    to.Ptr(base64.StdEncoding.EncodeToString(secret.Data[api.UserData])),
+	}
+	if windowsConfiguration := osProfileSpec.WindowsConfiguration; windowsConfiguration != nil {
+		osProfile.ComputerName = to.Ptr(utils.CreateWindowsComputerName(vmName))
+		osProfile.AdminPassword = to.Ptr(string(secret.Data[api.AdminPassword]))
+		osProfile.WindowsConfiguration = &armcompute.WindowsConfiguration{
+			EnableAutomaticUpdates: windowsConfiguration.EnableAutomaticUpdates,
+			ProvisionVMAgent:       to.Ptr(true),
+			TimeZone:               windowsConfiguration.TimeZone,
+			PatchSettings:          getWindowsPatchSettings(osProfileSpec.PatchSettings),
+		}
+		return osProfile, nil
+	}
+	sshConfiguration, err := getSSHConfiguration(osProfileSpec.LinuxConfiguration.SSH, secret)
+	if err != nil {
+		return nil, err
+	}
+	osProfile.LinuxConfiguration = &armcompute.LinuxConfiguration{
+		DisablePasswordAuthentication: to.Ptr(osProfileSpec.LinuxConfiguration.DisablePasswordAuthentication),
+		SSH:                           sshConfiguration,
+		PatchSettings:                 getLinuxPatchSettings(osProfileSpec.PatchSettings),
+	}
+	return osProfile, nil
+}
+
+func getLinuxPatchSettings(patchSettings *api.AzurePatchSettings) *armcompute.LinuxPatchSettings {
+	if patchSettings == nil {
+		return nil
+	}
+	linuxPatchSettings := &armcompute.LinuxPatchSettings{}
+	if patchSettings.PatchMode != nil {
+		linuxPatchSettings.PatchMode = to.Ptr(armcompute.LinuxVMGuestPatchMode(*patchSettings.PatchMode))
+	}
+	if patchSettings.AssessmentMode != nil {
+		linuxPatchSettings.AssessmentMode = to.Ptr(armcompute.LinuxPatchAssessmentMode(*patchSettings.AssessmentMode))
+	}
+	if patchSettings.BypassPlatformSafetyChecks != nil {
+		linuxPatchSettings.AutomaticByPlatformSettings = &armcompute.LinuxVMGuestPatchAutomaticByPlatformSettings{
+			BypassPlatformSafetyChecksOnUserSchedule: patchSettings.BypassPlatformSafetyChecks,
+		}
+	}
+	return linuxPatchSettings
+}
+
+func getWindowsPatchSettings(patchSettings *api.AzurePatchSettings) *armcompute.PatchSettings {
+	if patchSettings == nil {
+		return nil
+	}
+	windowsPatchSettings := &armcompute.PatchSettings{}
+	if patchSettings.PatchMode != nil {
+		windowsPatchSettings.PatchMode = to.Ptr(armcompute.WindowsVMGuestPatchMode(*patchSettings.PatchMode))
+	}
+	if patchSettings.AssessmentMode != nil {
+		windowsPatchSettings.AssessmentMode = to.Ptr(armcompute.WindowsPatchAssessmentMode(*patchSettings.AssessmentMode))
+	}
+	if patchSettings.BypassPlatformSafetyChecks != nil {
+		windowsPatchSettings.AutomaticByPlatformSettings = &armcompute.WindowsVMGuestPatchAutomaticByPlatformSettings{
+			BypassPlatformSafetyChecksOnUserSchedule: patchSettings.BypassPlatformSafetyChecks,
+		}
+	}
+	return windowsPatchSettings
+}
+
+func getOSType(osProfileSpec api.AzureOSProfile) armcompute.OperatingSystemTypes {
+	if osProfileSpec.WindowsConfiguration != nil {
+		return armcompute.OperatingSystemTypesWindows
+	}
+	return armcompute.OperatingSystemTypesLinux
+}
+
+func getDataDisks(storageProfile api.AzureStorageProfile, vmName string, imageRefDiskIDs map[DataDiskLun]DiskID) ([]*armcompute.DataDisk, error) {
 	var dataDisks []*armcompute.DataDisk
+	dataDiskSpecs := storageProfile.DataDisks
 	if utils.IsSliceNilOrEmpty(dataDiskSpecs) {
 		return dataDisks, nil
 	}
 	for _, specDataDisk := range dataDiskSpecs {
-		dataDiskName := utils.CreateDataDiskName(vmName, specDataDisk.Name, specDataDisk.Lun)
-		caching := armcompute.CachingTypesNone
+		dataDiskName := utils.CreateDataDiskNameFromTemplate(vmName, specDataDisk.Name, specDataDisk.Lun, storageProfile.DataDiskNameTemplate)
+		caching := armcompute.CachingTypes(defaulting.DefaultDataDiskCaching)
 		if !utils.IsEmptyString(specDataDisk.Caching) {
 			caching = armcompute.CachingTypes(specDataDisk.Caching)
 		}
@@ -776,14 +1503,15 @@ func getDataDisks(dataDiskSpecs []api.AzureDataDisk, vmName string, imageRefDisk
 			CreateOption: to.Ptr(armcompute.DiskCreateOptionTypesEmpty),
 			Lun:          to.Ptr(specDataDisk.Lun),
 			Caching:      to.Ptr(caching),
-			DeleteOption: to.Ptr(armcompute.DiskDeleteOptionTypesDelete),
+			DeleteOption: getDataDiskDeleteOption(specDataDisk.DeleteOption),
 			DiskSizeGB:   pointer.Int32(specDataDisk.DiskSizeGB),
 			ManagedDisk: &armcompute.ManagedDiskParameters{
 				StorageAccountType: to.Ptr(armcompute.StorageAccountTypes(specDataDisk.StorageAccountType)),
 			},
-			Name: to.Ptr(dataDiskName),
+			Name:                    to.Ptr(dataDiskName),
+			WriteAcceleratorEnabled: specDataDisk.WriteAcceleratorEnabled,
 		}
-		if specDataDisk.ImageRef != nil {
+		if isPreCreatedDataDisk(specDataDisk) {
 			diskID := imageRefDiskIDs[DataDiskLun(specDataDisk.Lun)]
 			if diskID == nil {
 				return nil, fmt.Errorf("unexpected error, this cannot happen and points to a coding error: "+
@@ -798,6 +1526,25 @@ func getDataDisks(dataDiskSpecs []api.AzureDataDisk, vmName string, imageRefDisk
 	return dataDisks, nil
 }
 
+// IsDetachedOnDelete checks if the data disk is detached instead of deleted when the VM is deleted.
+func IsDetachedOnDelete(specDataDisk api.AzureDataDisk) bool {
+	return specDataDisk.DeleteOption != nil && *specDataDisk.DeleteOption == string(armcompute.DiskDeleteOptionTypesDetach)
+}
+
+func getDataDiskDeleteOption(deleteOption *string) *armcompute.DiskDeleteOptionTypes {
+	if deleteOption == nil {
+		return to.Ptr(armcompute.DiskDeleteOptionTypes(defaulting.DefaultDataDiskDeleteOption))
+	}
+	return to.Ptr(armcompute.DiskDeleteOptionTypes(*deleteOption))
+}
+
+// isPreCreatedDataDisk checks if the data disk has to be created before the VM and attached to it. This is the case
+// for disks which are created from a source (image or snapshot), for shared disks and for disks with a performance tier,
+// since none of these can be expressed in the data disk parameters of the VM.
+func isPreCreatedDataDisk(specDataDisk api.AzureDataDisk) bool {
+	return specDataDisk.ImageRef != nil || specDataDisk.SnapshotID != nil || (specDataDisk.MaxShares != nil && *specDataDisk.MaxShares > 1) || specDataDisk.Tier != nil
+}
+
 func getVMIdentity(specVMIdentityID *string) *armcompute.VirtualMachineIdentity {
 	if specVMIdentityID == nil {
 		return nil
@@ -828,14 +1575,19 @@ func getVirtualMachineScaleSet(specVMSS *api.AzureSubResource) *armcompute.SubRe
 	}
 }
 
-func getSSHConfiguration(sshSpecConfig api.AzureSSHConfiguration) (*armcompute.SSHConfiguration, error) {
+// getSSHConfiguration creates the SSH configuration of a Linux VM. The public key is taken from the provider spec or, if a secret
+// key is configured, from the secret. If no public key is provided then a dummy public key is used.
+func getSSHConfiguration(sshSpecConfig api.AzureSSHConfiguration, secret *corev1.Secret) (*armcompute.SSHConfiguration, error) {
 	var (
 		publicKey string
 		err       error
 	)
 	publicKey = sshSpecConfig.PublicKeys.KeyData
+	if secretKey := sshSpecConfig.PublicKeys.SecretKey; secretKey != nil {
+		publicKey = strings.TrimSpace(string(secret.Data[*secretKey]))
+	}
 	if utils.IsEmptyString(publicKey) {
-		publicKey, err = generateDummyPublicKey()
+		publicKey, err = getDummyPublicKey()
 		if err != nil {
 			return nil, err
 		}
@@ -850,6 +1602,27 @@ func getSSHConfiguration(sshSpecConfig api.AzureSSHConfiguration) (*armcompute.S
 	}, nil
 }
 
+// dummyPublicKey caches the dummy public key for the lifetime of the driver process. Generating a 4096-bit RSA key is slow
+// and since the private key is discarded anyway there is no benefit in generating a new key for every VM.
+var dummyPublicKey struct {
+	sync.Mutex
+	value string
+}
+
+// getDummyPublicKey returns the cached dummy public key and generates it if it does not exist yet.
+func getDummyPublicKey() (string, error) {
+	dummyPublicKey.Lock()
+	defer dummyPublicKey.Unlock()
+	if utils.IsEmptyString(dummyPublicKey.value) {
+		publicKey, err := generateDummyPublicKey()
+		if err != nil {
+			return "", err
+		}
+		dummyPublicKey.value = publicKey
+	}
+	return dummyPublicKey.value, nil
+}
+
 func generateDummyPublicKey() (string, error) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
 	if err != nil {
@@ -882,3 +1655,61 @@ func getDiagnosticsProfile(profile *api.AzureDiagnosticsProfile) *armcompute.Dia
 		},
 	}
 }
+
+func getDiskControllerType(diskControllerType *string) *armcompute.DiskControllerTypes {
+	if diskControllerType == nil {
+		return nil
+	}
+	return to.Ptr(armcompute.DiskControllerTypes(*diskControllerType))
+}
+
+func getAdditionalCapabilities(additionalCapabilities *api.AzureAdditionalCapabilities) *armcompute.AdditionalCapabilities {
+	if additionalCapabilities == nil {
+		return nil
+	}
+	return &armcompute.AdditionalCapabilities{
+		HibernationEnabled: additionalCapabilities.HibernationEnabled,
+	}
+}
+
+func getScheduledEventsProfile(scheduledEventsProfile *api.AzureScheduledEventsProfile) *armcompute.ScheduledEventsProfile {
+	if scheduledEventsProfile == nil || scheduledEventsProfile.TerminateNotificationProfile == nil {
+		return nil
+	}
+	return &armcompute.ScheduledEventsProfile{
+		TerminateNotificationProfile: &armcompute.TerminateNotificationProfile{
+			Enable:           to.Ptr(scheduledEventsProfile.TerminateNotificationProfile.Enabled),
+			NotBeforeTimeout: scheduledEventsProfile.TerminateNotificationProfile.NotBeforeTimeout,
+		},
+	}
+}
+
+// getUserData returns the base64 encoded userData of the VM if it is enabled. The user data is read from the configured
+// secret key, or from api.UserData if no secret key is configured.
+func getUserData(userDataSpec *api.AzureUserData, secret *corev1.Secret) *string {
+	if userDataSpec == nil || !userDataSpec.Enabled {
+		return nil
+	}
+	secretKey := api.UserData
+	if userDataSpec.SecretKey != nil {
+		secretKey = *userDataSpec.SecretKey
+	}
+	return to.Ptr(base64.StdEncoding.EncodeToString(secret.Data[secretKey]))
+}
+
+func getApplicationProfile(galleryApplications []api.AzureGalleryApplication) *armcompute.ApplicationProfile {
+	if len(galleryApplications) == 0 {
+		return nil
+	}
+	applicationProfile := &armcompute.ApplicationProfile{
+		GalleryApplications: make([]*armcompute.VMGalleryApplication, 0, len(galleryApplications)),
+	}
+	for _, galleryApplication := range galleryApplications {
+		applicationProfile.GalleryApplications = append(applicationProfile.GalleryApplications, &armcompute.VMGalleryApplication{
+			PackageReferenceID:              to.Ptr(galleryApplication.PackageReferenceID),
+			Order:                           galleryApplication.Order,
+			TreatFailureAsDeploymentFailure: galleryApplication.TreatFailureAsDeploymentFailure,
+		})
+	}
+	return applicationProfile
+}
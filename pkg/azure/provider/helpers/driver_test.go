@@ -5,11 +5,23 @@
 package helpers
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
 	"testing"
 
-	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
 )
 
 func TestDeriveInstanceID(t *testing.T) {
@@ -22,6 +34,36 @@ func TestDeriveInstanceID(t *testing.T) {
 	g.Expect(DeriveInstanceID(location, vmName)).To(Equal(expectedInstanceID))
 }
 
+func TestSelectZone(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(SelectZone(nil, "vm-0")).To(BeNil())
+
+	zones := []int{1, 2, 3}
+	selectedZones := make(map[int]int)
+	for i := 0; i < 30; i++ {
+		vmName := fmt.Sprintf("vm-%d", i)
+		zone := SelectZone(zones, vmName)
+		g.Expect(zone).ToNot(BeNil())
+		g.Expect(zones).To(ContainElement(*zone))
+		// selection must be stable for the same VM name
+		g.Expect(SelectZone(zones, vmName)).To(Equal(zone))
+		selectedZones[*zone]++
+	}
+	// VMs should be spread across all zones
+	g.Expect(selectedZones).To(HaveLen(len(zones)))
+}
+
+func TestGetAlternateZones(t *testing.T) {
+	g := NewWithT(t)
+	zones := []int{1, 2, 3}
+	g.Expect(GetAlternateZones(zones, nil)).To(BeEmpty())
+	g.Expect(GetAlternateZones(zones, to.Ptr(4))).To(BeEmpty())
+	g.Expect(GetAlternateZones([]int{1}, to.Ptr(1))).To(BeEmpty())
+	g.Expect(GetAlternateZones(zones, to.Ptr(1))).To(Equal([]int{2, 3}))
+	g.Expect(GetAlternateZones(zones, to.Ptr(2))).To(Equal([]int{3, 1}))
+	g.Expect(GetAlternateZones(zones, to.Ptr(3))).To(Equal([]int{1, 2}))
+}
+
 func TestGetDiskNames(t *testing.T) {
 	const (
 		vmName                = "vm-0"
@@ -57,3 +99,385 @@ func TestGetDiskNames(t *testing.T) {
 		g.Expect(actualDiskNames).To(HaveLen(entry.expectedDiskCount))
 	}
 }
+
+func TestGetDataDisksDeleteOption(t *testing.T) {
+	const vmName = "vm-0"
+	storageProfile := api.AzureStorageProfile{
+		DataDisks: []api.AzureDataDisk{
+			{Name: "kept", Lun: 0, DiskSizeGB: 10, StorageAccountType: "StandardSSD_LRS", DeleteOption: to.Ptr(string(armcompute.DiskDeleteOptionTypesDetach))},
+			{Name: "deleted", Lun: 1, DiskSizeGB: 10, StorageAccountType: "StandardSSD_LRS"},
+		},
+	}
+	g := NewWithT(t)
+
+	dataDisks, err := getDataDisks(storageProfile, vmName, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(dataDisks).To(HaveLen(2))
+	g.Expect(*dataDisks[0].DeleteOption).To(Equal(armcompute.DiskDeleteOptionTypesDetach))
+	g.Expect(*dataDisks[1].DeleteOption).To(Equal(armcompute.DiskDeleteOptionTypesDelete))
+
+	// detached data disks are not part of the disks which are deleted together with the VM
+	diskNames := GetDiskNames(api.AzureProviderSpec{Properties: api.AzureVirtualMachineProperties{StorageProfile: storageProfile}}, vmName)
+	g.Expect(diskNames).To(ConsistOf(utils.CreateOSDiskName(vmName), utils.CreateDataDiskName(vmName, "deleted", 1)))
+}
+
+func TestIsForceDeletionEnabled(t *testing.T) {
+	newMachine := func(annotations map[string]string) *v1alpha1.Machine {
+		return &v1alpha1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "vm-0", Annotations: annotations}}
+	}
+	table := []struct {
+		description         string
+		specForceDeletion   bool
+		machine             *v1alpha1.Machine
+		expectForceDeletion bool
+	}{
+		{"should not force delete by default", false, newMachine(nil), false},
+		{"should force delete if it is enabled in the provider spec", true, newMachine(nil), true},
+		{"should force delete if it is enabled by the annotation", false, newMachine(map[string]string{api.ForceDeletionAnnotation: "true"}), true},
+		{"should not force delete if it is disabled by the annotation", true, newMachine(map[string]string{api.ForceDeletionAnnotation: "false"}), false},
+		{"should ignore an invalid annotation value", true, newMachine(map[string]string{api.ForceDeletionAnnotation: "yes please"}), true},
+		{"should fall back to the provider spec if there is no machine", true, nil, true},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := api.AzureProviderSpec{Properties: api.AzureVirtualMachineProperties{ForceDeletion: entry.specForceDeletion}}
+			g.Expect(IsForceDeletionEnabled(context.Background(), providerSpec, entry.machine)).To(Equal(entry.expectForceDeletion))
+		})
+	}
+}
+
+func TestGetDummyPublicKey(t *testing.T) {
+	g := NewWithT(t)
+	publicKey, err := getDummyPublicKey()
+	g.Expect(err).To(BeNil())
+	g.Expect(publicKey).To(HavePrefix("ssh-rsa "))
+	// the dummy public key is generated only once per process
+	cachedPublicKey, err := getDummyPublicKey()
+	g.Expect(err).To(BeNil())
+	g.Expect(cachedPublicKey).To(Equal(publicKey))
+}
+
+func TestCreateVMCreationParamsWithPlatformFaultDomain(t *testing.T) {
+	const (
+		vmName                = "vm-0"
+		testResourceGroupName = "test-rg"
+		testShootNs           = "test-shoot-ns"
+		testWorkerPool0Name   = "test-worker-pool-0"
+	)
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.Zone = nil
+	providerSpec.Properties.VirtualMachineScaleSet = &api.AzureSubResource{ID: "vm-scale-set-1"}
+	providerSpec.Properties.PlatformFaultDomain = to.Ptr[int32](1)
+	secret := &corev1.Secret{Data: map[string][]byte{api.UserData: []byte(testhelp.UserData)}}
+
+	vm, err := createVMCreationParams(providerSpec, armcompute.ImageReference{}, nil, secret, "nic-id", vmName, vmName, nil, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(vm.Properties.VirtualMachineScaleSet.ID).To(Equal(to.Ptr("vm-scale-set-1")))
+	g.Expect(vm.Properties.PlatformFaultDomain).To(Equal(to.Ptr[int32](1)))
+}
+
+func TestGetOSProfile(t *testing.T) {
+	const vmName = "shoot--test-project-z1-4567c-xj5sq"
+	secret := &corev1.Secret{Data: map[string][]byte{
+		api.UserData:      []byte(testhelp.UserData),
+		api.AdminPassword: []byte("s3cr3t-P@ssw0rd"),
+	}}
+	g := NewWithT(t)
+
+	// Linux
+	osProfile, err := getOSProfile(api.AzureOSProfile{AdminUsername: "core"}, secret, vmName)
+	g.Expect(err).To(BeNil())
+	g.Expect(osProfile.LinuxConfiguration).ToNot(BeNil())
+	g.Expect(osProfile.WindowsConfiguration).To(BeNil())
+	g.Expect(osProfile.AdminPassword).To(BeNil())
+	g.Expect(*osProfile.ComputerName).To(Equal(vmName))
+
+	// Windows
+	osProfile, err = getOSProfile(api.AzureOSProfile{
+		AdminUsername:        "core",
+		WindowsConfiguration: &api.AzureWindowsConfiguration{EnableAutomaticUpdates: to.Ptr(false), TimeZone: to.Ptr("UTC")},
+	}, secret, vmName)
+	g.Expect(err).To(BeNil())
+	g.Expect(osProfile.LinuxConfiguration).To(BeNil())
+	g.Expect(osProfile.WindowsConfiguration).ToNot(BeNil())
+	g.Expect(*osProfile.WindowsConfiguration.EnableAutomaticUpdates).To(BeFalse())
+	g.Expect(*osProfile.WindowsConfiguration.TimeZone).To(Equal("UTC"))
+	g.Expect(*osProfile.AdminPassword).To(Equal("s3cr3t-P@ssw0rd"))
+	g.Expect(len(*osProfile.ComputerName)).To(BeNumerically("<=", 15))
+
+	// Linux with SSH public key from the secret
+	osProfile, err = getOSProfile(api.AzureOSProfile{
+		AdminUsername: "core",
+		LinuxConfiguration: api.AzureLinuxConfiguration{SSH: api.AzureSSHConfiguration{PublicKeys: api.AzureSSHPublicKey{
+			Path:      "/home/core/.ssh/authorized_keys",
+			SecretKey: to.Ptr("sshPublicKey"),
+		}}},
+	}, &corev1.Secret{Data: map[string][]byte{"sshPublicKey": []byte(testhelp.SSHPublicKey + "\n")}}, vmName)
+	g.Expect(err).To(BeNil())
+	g.Expect(osProfile.LinuxConfiguration.SSH.PublicKeys).To(HaveLen(1))
+	g.Expect(*osProfile.LinuxConfiguration.SSH.PublicKeys[0].KeyData).To(Equal(testhelp.SSHPublicKey))
+	g.Expect(*osProfile.LinuxConfiguration.SSH.PublicKeys[0].Path).To(Equal("/home/core/.ssh/authorized_keys"))
+
+	// Linux with patch settings
+	osProfile, err = getOSProfile(api.AzureOSProfile{
+		AdminUsername: "core",
+		PatchSettings: &api.AzurePatchSettings{PatchMode: to.Ptr("AutomaticByPlatform"), BypassPlatformSafetyChecks: to.Ptr(true)},
+	}, secret, vmName)
+	g.Expect(err).To(BeNil())
+	g.Expect(osProfile.LinuxConfiguration.PatchSettings).ToNot(BeNil())
+	g.Expect(*osProfile.LinuxConfiguration.PatchSettings.PatchMode).To(Equal(armcompute.LinuxVMGuestPatchModeAutomaticByPlatform))
+	g.Expect(osProfile.LinuxConfiguration.PatchSettings.AssessmentMode).To(BeNil())
+	g.Expect(*osProfile.LinuxConfiguration.PatchSettings.AutomaticByPlatformSettings.BypassPlatformSafetyChecksOnUserSchedule).To(BeTrue())
+
+	// Windows with patch settings
+	osProfile, err = getOSProfile(api.AzureOSProfile{
+		AdminUsername:        "core",
+		WindowsConfiguration: &api.AzureWindowsConfiguration{},
+		PatchSettings:        &api.AzurePatchSettings{PatchMode: to.Ptr("Manual"), AssessmentMode: to.Ptr("ImageDefault")},
+	}, secret, vmName)
+	g.Expect(err).To(BeNil())
+	g.Expect(osProfile.WindowsConfiguration.PatchSettings).ToNot(BeNil())
+	g.Expect(*osProfile.WindowsConfiguration.PatchSettings.PatchMode).To(Equal(armcompute.WindowsVMGuestPatchModeManual))
+	g.Expect(*osProfile.WindowsConfiguration.PatchSettings.AssessmentMode).To(Equal(armcompute.WindowsPatchAssessmentModeImageDefault))
+	g.Expect(osProfile.WindowsConfiguration.PatchSettings.AutomaticByPlatformSettings).To(BeNil())
+}
+
+func TestGetScheduledEventsProfile(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(getScheduledEventsProfile(nil)).To(BeNil())
+	g.Expect(getScheduledEventsProfile(&api.AzureScheduledEventsProfile{})).To(BeNil())
+
+	scheduledEventsProfile := getScheduledEventsProfile(&api.AzureScheduledEventsProfile{
+		TerminateNotificationProfile: &api.AzureTerminateNotificationProfile{Enabled: true, NotBeforeTimeout: to.Ptr("PT10M")},
+	})
+	g.Expect(scheduledEventsProfile).ToNot(BeNil())
+	g.Expect(scheduledEventsProfile.TerminateNotificationProfile.Enable).To(Equal(to.Ptr(true)))
+	g.Expect(scheduledEventsProfile.TerminateNotificationProfile.NotBeforeTimeout).To(Equal(to.Ptr("PT10M")))
+}
+
+func TestGetUserData(t *testing.T) {
+	const userDataSecretKey = "nodeUserData"
+	secret := &corev1.Secret{Data: map[string][]byte{
+		api.UserData:      []byte(testhelp.UserData),
+		userDataSecretKey: []byte("node-user-data"),
+	}}
+	g := NewWithT(t)
+	g.Expect(getUserData(nil, secret)).To(BeNil())
+	g.Expect(getUserData(&api.AzureUserData{SecretKey: to.Ptr(userDataSecretKey)}, secret)).To(BeNil())
+	g.Expect(getUserData(&api.AzureUserData{Enabled: true}, secret)).To(Equal(to.Ptr(base64.StdEncoding.EncodeToString([]byte(testhelp.UserData)))))
+	g.Expect(getUserData(&api.AzureUserData{Enabled: true, SecretKey: to.Ptr(userDataSecretKey)}, secret)).To(Equal(to.Ptr(base64.StdEncoding.EncodeToString([]byte("node-user-data")))))
+}
+
+func TestGetApplicationProfile(t *testing.T) {
+	const appVersionID = "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/galleries/gallery-0/applications/app-0/versions/1.0.0"
+	g := NewWithT(t)
+	g.Expect(getApplicationProfile(nil)).To(BeNil())
+
+	applicationProfile := getApplicationProfile([]api.AzureGalleryApplication{{PackageReferenceID: appVersionID, Order: to.Ptr[int32](2), TreatFailureAsDeploymentFailure: to.Ptr(true)}})
+	g.Expect(applicationProfile).ToNot(BeNil())
+	g.Expect(applicationProfile.GalleryApplications).To(HaveLen(1))
+	g.Expect(applicationProfile.GalleryApplications[0].PackageReferenceID).To(Equal(to.Ptr(appVersionID)))
+	g.Expect(applicationProfile.GalleryApplications[0].Order).To(Equal(to.Ptr[int32](2)))
+	g.Expect(applicationProfile.GalleryApplications[0].TreatFailureAsDeploymentFailure).To(Equal(to.Ptr(true)))
+}
+
+func TestCreateNICParamsIPForwarding(t *testing.T) {
+	const (
+		testResourceGroupName = "test-rg"
+		testShootNs           = "test-shoot-ns"
+		testWorkerPool0Name   = "test-worker-pool-0"
+		vmName                = "vm-0"
+	)
+	table := []struct {
+		description          string
+		ipForwarding         *bool
+		expectedIPForwarding bool
+	}{
+		{"should enable IP forwarding by default", nil, true},
+		{"should enable IP forwarding if configured", to.Ptr(true), true},
+		{"should disable IP forwarding if configured", to.Ptr(false), false},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.NetworkProfile.IPForwarding = entry.ipForwarding
+			nicParams := createNICParams(providerSpec, nil, vmName, vmName)
+			g.Expect(nicParams.Properties.EnableIPForwarding).To(Equal(to.Ptr(entry.expectedIPForwarding)))
+		})
+	}
+}
+
+func TestCreateNICParamsNICTypeAndAuxiliaryMode(t *testing.T) {
+	const (
+		testResourceGroupName = "test-rg"
+		testShootNs           = "test-shoot-ns"
+		testWorkerPool0Name   = "test-worker-pool-0"
+		vmName                = "vm-0"
+	)
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	nicParams := createNICParams(providerSpec, nil, vmName, vmName)
+	g.Expect(nicParams.Properties.NicType).To(Equal(to.Ptr(armnetwork.NetworkInterfaceNicTypeStandard)))
+	g.Expect(nicParams.Properties.AuxiliaryMode).To(BeNil())
+	g.Expect(nicParams.Properties.AuxiliarySKU).To(BeNil())
+
+	providerSpec.Properties.NetworkProfile.NicType = to.Ptr(string(armnetwork.NetworkInterfaceNicTypeElastic))
+	providerSpec.Properties.NetworkProfile.AuxiliaryMode = to.Ptr(string(armnetwork.NetworkInterfaceAuxiliaryModeAcceleratedConnections))
+	providerSpec.Properties.NetworkProfile.AuxiliarySKU = to.Ptr(string(armnetwork.NetworkInterfaceAuxiliarySKUA2))
+	nicParams = createNICParams(providerSpec, nil, vmName, vmName)
+	g.Expect(nicParams.Properties.NicType).To(Equal(to.Ptr(armnetwork.NetworkInterfaceNicTypeElastic)))
+	g.Expect(nicParams.Properties.AuxiliaryMode).To(Equal(to.Ptr(armnetwork.NetworkInterfaceAuxiliaryModeAcceleratedConnections)))
+	g.Expect(nicParams.Properties.AuxiliarySKU).To(Equal(to.Ptr(armnetwork.NetworkInterfaceAuxiliarySKUA2)))
+}
+
+func TestDataDiskFromSnapshot(t *testing.T) {
+	const (
+		vmName     = "vm-0"
+		snapshotID = "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/snapshots/snapshot-0"
+		diskID     = "/subscriptions/sub-0/resourceGroups/rg-0/providers/Microsoft.Compute/disks/vm-0-data-disk-0"
+	)
+	g := NewWithT(t)
+	specDataDisk := api.AzureDataDisk{Name: "data-disk", Lun: 0, DiskSizeGB: 20, StorageAccountType: "StandardSSD_LRS", SnapshotID: to.Ptr(snapshotID)}
+
+	creationData, err := createDiskCreationData(context.Background(), specDataDisk, "westeurope", nil, access.ConnectConfig{})
+	g.Expect(err).To(BeNil())
+	g.Expect(creationData.CreateOption).To(Equal(to.Ptr(armcompute.DiskCreateOptionCopy)))
+	g.Expect(creationData.SourceResourceID).To(Equal(to.Ptr(snapshotID)))
+
+	_, err = getDataDisks(api.AzureStorageProfile{DataDisks: []api.AzureDataDisk{specDataDisk}}, vmName, map[DataDiskLun]DiskID{})
+	g.Expect(err).ToNot(BeNil())
+	dataDisks, err := getDataDisks(api.AzureStorageProfile{DataDisks: []api.AzureDataDisk{specDataDisk}}, vmName, map[DataDiskLun]DiskID{0: to.Ptr(diskID)})
+	g.Expect(err).To(BeNil())
+	g.Expect(dataDisks).To(HaveLen(1))
+	g.Expect(dataDisks[0].CreateOption).To(Equal(to.Ptr(armcompute.DiskCreateOptionTypesAttach)))
+	g.Expect(dataDisks[0].ManagedDisk.ID).To(Equal(to.Ptr(diskID)))
+}
+
+func TestDataDiskWithTier(t *testing.T) {
+	const (
+		testResourceGroupName = "test-rg"
+		testShootNs           = "test-shoot-ns"
+		testWorkerPool0Name   = "test-worker-pool-0"
+		vmName                = "vm-0"
+	)
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	specDataDisk := api.AzureDataDisk{Name: "fast-disk", Lun: 0, DiskSizeGB: 128, StorageAccountType: "Premium_LRS", Tier: to.Ptr("P30")}
+	g.Expect(isPreCreatedDataDisk(specDataDisk)).To(BeTrue())
+
+	diskParams, err := createDiskCreationParams(context.Background(), specDataDisk, providerSpec, vmName, nil, access.ConnectConfig{})
+	g.Expect(err).To(BeNil())
+	g.Expect(diskParams.Properties.Tier).To(Equal(to.Ptr("P30")))
+	g.Expect(diskParams.Properties.DiskSizeGB).To(Equal(to.Ptr[int32](128)))
+}
+
+func TestZoneRedundantDataDisk(t *testing.T) {
+	const (
+		testResourceGroupName = "test-rg"
+		testShootNs           = "test-shoot-ns"
+		testWorkerPool0Name   = "test-worker-pool-0"
+		vmName                = "vm-0"
+	)
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.Zone = to.Ptr(2)
+
+	// locally redundant disks are created in the zone of the VM
+	diskParams, err := createDiskCreationParams(context.Background(), api.AzureDataDisk{Lun: 0, DiskSizeGB: 20, StorageAccountType: "Premium_LRS", MaxShares: to.Ptr[int32](2)}, providerSpec, vmName, nil, access.ConnectConfig{})
+	g.Expect(err).To(BeNil())
+	g.Expect(diskParams.Zones).To(Equal([]*string{to.Ptr("2")}))
+
+	// zone-redundant disks are not pinned to a zone
+	for _, storageAccountType := range []string{"Premium_ZRS", "StandardSSD_ZRS"} {
+		diskParams, err = createDiskCreationParams(context.Background(), api.AzureDataDisk{Lun: 0, DiskSizeGB: 20, StorageAccountType: storageAccountType, MaxShares: to.Ptr[int32](2)}, providerSpec, vmName, nil, access.ConnectConfig{})
+		g.Expect(err).To(BeNil())
+		g.Expect(diskParams.Zones).To(BeEmpty())
+		g.Expect(*diskParams.SKU.Name).To(Equal(armcompute.DiskStorageAccountTypes(storageAccountType)))
+	}
+}
+
+func TestSharedDataDisk(t *testing.T) {
+	const (
+		testResourceGroupName = "test-rg"
+		testShootNs           = "test-shoot-ns"
+		testWorkerPool0Name   = "test-worker-pool-0"
+		vmName                = "vm-0"
+	)
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	specDataDisk := api.AzureDataDisk{Name: "shared-disk", Lun: 0, DiskSizeGB: 20, StorageAccountType: "Premium_LRS", MaxShares: to.Ptr[int32](2)}
+	g.Expect(isPreCreatedDataDisk(specDataDisk)).To(BeTrue())
+	g.Expect(isPreCreatedDataDisk(api.AzureDataDisk{MaxShares: to.Ptr[int32](1)})).To(BeFalse())
+
+	diskParams, err := createDiskCreationParams(context.Background(), specDataDisk, providerSpec, vmName, nil, access.ConnectConfig{})
+	g.Expect(err).To(BeNil())
+	g.Expect(diskParams.Properties.MaxShares).To(Equal(to.Ptr[int32](2)))
+	g.Expect(diskParams.Properties.CreationData.CreateOption).To(Equal(to.Ptr(armcompute.DiskCreateOptionEmpty)))
+}
+
+func TestGetSubnetCoordinates(t *testing.T) {
+	const (
+		testSubscriptionID = "subscription-0"
+		testResourceGroup  = "test-rg"
+	)
+	tests := []struct {
+		description               string
+		subnetInfo                api.AzureSubnetInfo
+		expectedSubscriptionID    string
+		expectedVnetResourceGroup string
+		expectedVnetName          string
+		expectedSubnetName        string
+		expectErr                 bool
+	}{
+		{
+			description:               "subnet in the subscription and resource group of the VM",
+			subnetInfo:                api.AzureSubnetInfo{VnetName: "vnet", SubnetName: "subnet"},
+			expectedSubscriptionID:    testSubscriptionID,
+			expectedVnetResourceGroup: testResourceGroup,
+			expectedVnetName:          "vnet",
+			expectedSubnetName:        "subnet",
+		},
+		{
+			description:               "subnet in another subscription",
+			subnetInfo:                api.AzureSubnetInfo{VnetName: "vnet", SubnetName: "subnet", VnetResourceGroup: to.Ptr("hub-rg"), SubscriptionID: to.Ptr("hub-subscription")},
+			expectedSubscriptionID:    "hub-subscription",
+			expectedVnetResourceGroup: "hub-rg",
+			expectedVnetName:          "vnet",
+			expectedSubnetName:        "subnet",
+		},
+		{
+			description:               "subnet given by ID",
+			subnetInfo:                api.AzureSubnetInfo{SubnetID: to.Ptr("/subscriptions/hub-subscription/resourceGroups/hub-rg/providers/Microsoft.Network/virtualNetworks/hub-vnet/subnets/nodes")},
+			expectedSubscriptionID:    "hub-subscription",
+			expectedVnetResourceGroup: "hub-rg",
+			expectedVnetName:          "hub-vnet",
+			expectedSubnetName:        "nodes",
+		},
+		{
+			description: "invalid subnet ID",
+			subnetInfo:  api.AzureSubnetInfo{SubnetID: to.Ptr("nodes")},
+			expectErr:   true,
+		},
+	}
+	g := NewWithT(t)
+	for _, test := range tests {
+		t.Run(test.description, func(_ *testing.T) {
+			providerSpec := api.AzureProviderSpec{ResourceGroup: testResourceGroup, SubnetInfo: test.subnetInfo}
+			subscriptionID, vnetResourceGroup, vnetName, subnetName, err := getSubnetCoordinates(testSubscriptionID, providerSpec)
+			if test.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(subscriptionID).To(Equal(test.expectedSubscriptionID))
+			g.Expect(vnetResourceGroup).To(Equal(test.expectedVnetResourceGroup))
+			g.Expect(vnetName).To(Equal(test.expectedVnetName))
+			g.Expect(subnetName).To(Equal(test.expectedSubnetName))
+		})
+	}
+}
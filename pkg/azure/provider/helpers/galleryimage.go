@@ -0,0 +1,321 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+)
+
+// latestGalleryImageVersion is the version name which can be used in a gallery image ID to refer to the latest version of the image.
+const latestGalleryImageVersion = "latest"
+
+// galleryImageVersionID is a parsed shared or community gallery image version ID.
+// Shared gallery image IDs have the format: /SharedGalleries/{galleryUniqueName}/Images/{imageName}/Versions/{version}
+// Community gallery image IDs have the format: /CommunityGalleries/{publicGalleryName}/Images/{imageName}/Versions/{version}
+type galleryImageVersionID struct {
+	galleryName string
+	imageName   string
+	version     string
+}
+
+// galleryImageVersion captures the information of a gallery image version that is required to resolve the latest version.
+type galleryImageVersion struct {
+	name              string
+	excludeFromLatest bool
+}
+
+// parseGalleryImageVersionID parses a gallery image version ID where galleryKey is the segment which precedes the gallery name,
+// e.g. SharedGalleries or CommunityGalleries. The second return value is false if the ID cannot be parsed.
+func parseGalleryImageVersionID(id, galleryKey string) (galleryImageVersionID, bool) {
+	var parsedID galleryImageVersionID
+	segments := strings.Split(strings.Trim(id, "/"), "/")
+	if len(segments)%2 != 0 {
+		return parsedID, false
+	}
+	for i := 0; i < len(segments); i += 2 {
+		switch key, value := segments[i], segments[i+1]; {
+		case strings.EqualFold(key, galleryKey):
+			parsedID.galleryName = value
+		case strings.EqualFold(key, "images"):
+			parsedID.imageName = value
+		case strings.EqualFold(key, "versions"):
+			parsedID.version = value
+		}
+	}
+	return parsedID, parsedID.galleryName != "" && parsedID.imageName != "" && parsedID.version != ""
+}
+
+// ResolveLatestGalleryImageVersion replaces the version of a shared or community gallery image reference with the concrete version
+// if the version is set to latest. Azure would otherwise resolve the latest version independently for every VM.
+// The resolved version is pinned for the image for the TTL configured via SetLookupCacheTTLs, so that VMs which are created
+// during a rollout use the same version even if a new version is published in the meantime. Once the TTL has elapsed, or if
+// the cache is disabled, the latest version is resolved again and VMs created afterwards can use a newer version.
+// For all other image references the passed image reference is returned as is.
+func ResolveLatestGalleryImageVersion(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, location string, imageRef armcompute.ImageReference) (armcompute.ImageReference, error) {
+	switch {
+	case imageRef.SharedGalleryImageID != nil:
+		resolvedID, err := resolveLatestGalleryImageVersionID(ctx, connectConfig, location, *imageRef.SharedGalleryImageID, "SharedGalleries", func(id galleryImageVersionID) ([]galleryImageVersion, error) {
+			return listSharedGalleryImageVersions(ctx, factory, connectConfig, location, id)
+		})
+		if err != nil || resolvedID == "" {
+			return imageRef, err
+		}
+		return armcompute.ImageReference{SharedGalleryImageID: to.Ptr(resolvedID)}, nil
+	case imageRef.CommunityGalleryImageID != nil:
+		resolvedID, err := resolveLatestGalleryImageVersionID(ctx, connectConfig, location, *imageRef.CommunityGalleryImageID, "CommunityGalleries", func(id galleryImageVersionID) ([]galleryImageVersion, error) {
+			return listCommunityGalleryImageVersions(ctx, factory, connectConfig, location, id)
+		})
+		if err != nil || resolvedID == "" {
+			return imageRef, err
+		}
+		return armcompute.ImageReference{CommunityGalleryImageID: to.Ptr(resolvedID)}, nil
+	default:
+		return imageRef, nil
+	}
+}
+
+// GetPreviousGalleryImageVersion returns the image reference of the gallery image version which has been used by a previous
+// attempt to create the VM (see LastKnownState.ImageID), if the passed image reference refers to the latest version of the
+// same shared or community gallery image. A VM which has been left behind by the previous attempt can then be updated on
+// retry, since the image reference of an existing VM cannot be changed, also if a newer version has been published in
+// the meantime or the version pinned via the lookup cache has expired. The second return value is false otherwise.
+func GetPreviousGalleryImageVersion(imageRef armcompute.ImageReference, previousImageID string) (armcompute.ImageReference, bool) {
+	switch {
+	case imageRef.SharedGalleryImageID != nil:
+		if isSameGalleryImage(*imageRef.SharedGalleryImageID, previousImageID, "SharedGalleries") {
+			return armcompute.ImageReference{SharedGalleryImageID: to.Ptr(previousImageID)}, true
+		}
+	case imageRef.CommunityGalleryImageID != nil:
+		if isSameGalleryImage(*imageRef.CommunityGalleryImageID, previousImageID, "CommunityGalleries") {
+			return armcompute.ImageReference{CommunityGalleryImageID: to.Ptr(previousImageID)}, true
+		}
+	}
+	return imageRef, false
+}
+
+// isSameGalleryImage checks if imageID refers to the latest version of a gallery image and previousImageID to a concrete
+// version of the same gallery image.
+func isSameGalleryImage(imageID, previousImageID, galleryKey string) bool {
+	id, ok := parseGalleryImageVersionID(imageID, galleryKey)
+	if !ok || !strings.EqualFold(id.version, latestGalleryImageVersion) {
+		return false
+	}
+	previousID, ok := parseGalleryImageVersionID(previousImageID, galleryKey)
+	return ok && !strings.EqualFold(previousID.version, latestGalleryImageVersion) &&
+		strings.EqualFold(id.galleryName, previousID.galleryName) && strings.EqualFold(id.imageName, previousID.imageName)
+}
+
+// resolveLatestGalleryImageVersionID resolves the gallery image ID referring to the latest version to the ID of the concrete
+// version using the versions returned by listVersionsFn. The resolved ID is cached if a TTL has been configured via
+// SetLookupCacheTTLs. An empty ID is returned if the image ID does not refer to the latest version.
+func resolveLatestGalleryImageVersionID(ctx context.Context, connectConfig access.ConnectConfig, location, imageID, galleryKey string, listVersionsFn func(galleryImageVersionID) ([]galleryImageVersion, error)) (string, error) {
+	id, ok := parseGalleryImageVersionID(imageID, galleryKey)
+	if !ok || !strings.EqualFold(id.version, latestGalleryImageVersion) {
+		return "", nil
+	}
+	cache := getGalleryImageVersionCache()
+	cacheKey := createGalleryImageVersionCacheKey(connectConfig.SubscriptionID, location, imageID)
+	if cache != nil {
+		if resolvedID, ok := cache.Get(cacheKey); ok {
+			return resolvedID, nil
+		}
+	}
+	versions, err := listVersionsFn(id)
+	if err != nil {
+		return "", err
+	}
+	resolvedID, err := resolveGalleryImageVersionID(ctx, imageID, versions)
+	if err != nil {
+		return "", err
+	}
+	if cache != nil {
+		cache.Set(cacheKey, resolvedID)
+	}
+	return resolvedID, nil
+}
+
+func listSharedGalleryImageVersions(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, location string, id galleryImageVersionID) ([]galleryImageVersion, error) {
+	versionsAccess, err := factory.GetSharedGalleryImageVersionsAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create shared gallery image versions access, Err: %v", err), err)
+	}
+	sharedVersions, err := accesshelpers.ListSharedGalleryImageVersions(ctx, versionsAccess, location, id.galleryName, id.imageName)
+	if err != nil {
+		if accesserrors.IsNotFoundAzAPIError(err) {
+			return nil, status.WrapError(codes.NotFound, fmt.Sprintf("Shared gallery image [Gallery: %s, Image: %s] does not exist", id.galleryName, id.imageName), err)
+		}
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to list versions of shared gallery image [Gallery: %s, Image: %s], Err: %v", id.galleryName, id.imageName, err), err)
+	}
+	versions := make([]galleryImageVersion, 0, len(sharedVersions))
+	for _, v := range sharedVersions {
+		if v == nil || v.Name == nil {
+			continue
+		}
+		versions = append(versions, galleryImageVersion{
+			name:              *v.Name,
+			excludeFromLatest: v.Properties != nil && v.Properties.ExcludeFromLatest != nil && *v.Properties.ExcludeFromLatest,
+		})
+	}
+	return versions, nil
+}
+
+func listCommunityGalleryImageVersions(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, location string, id galleryImageVersionID) ([]galleryImageVersion, error) {
+	versionsAccess, err := factory.GetCommunityGalleryImageVersionsAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create community gallery image versions access, Err: %v", err), err)
+	}
+	communityVersions, err := accesshelpers.ListCommunityGalleryImageVersions(ctx, versionsAccess, location, id.galleryName, id.imageName)
+	if err != nil {
+		if accesserrors.IsNotFoundAzAPIError(err) {
+			return nil, status.WrapError(codes.NotFound, fmt.Sprintf("Community gallery image [Gallery: %s, Image: %s] does not exist", id.galleryName, id.imageName), err)
+		}
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to list versions of community gallery image [Gallery: %s, Image: %s], Err: %v", id.galleryName, id.imageName, err), err)
+	}
+	versions := make([]galleryImageVersion, 0, len(communityVersions))
+	for _, v := range communityVersions {
+		if v == nil || v.Name == nil {
+			continue
+		}
+		versions = append(versions, galleryImageVersion{
+			name:              *v.Name,
+			excludeFromLatest: v.Properties != nil && v.Properties.ExcludeFromLatest != nil && *v.Properties.ExcludeFromLatest,
+		})
+	}
+	return versions, nil
+}
+
+// resolveGalleryImageVersionID picks the highest version which is not excluded from latest and replaces the latest version in the image ID with it.
+func resolveGalleryImageVersionID(ctx context.Context, imageID string, versions []galleryImageVersion) (string, error) {
+	var latest string
+	for _, v := range versions {
+		if v.excludeFromLatest {
+			continue
+		}
+		if latest == "" || compareGalleryImageVersions(v.name, latest) > 0 {
+			latest = v.name
+		}
+	}
+	if latest == "" {
+		return "", status.Error(codes.NotFound, fmt.Sprintf("no version of gallery image %s is available to resolve the latest version", imageID))
+	}
+	resolvedID := imageID[:len(imageID)-len(latestGalleryImageVersion)] + latest
+	klog.FromContext(ctx).Info("Resolved latest version of gallery image", "imageID", imageID, "resolvedImageID", resolvedID)
+	return resolvedID, nil
+}
+
+// compareGalleryImageVersions compares two gallery image versions which have the format MajorVersion.MinorVersion.Patch.
+// It returns a negative number if a < b, zero if a == b and a positive number if a > b.
+func compareGalleryImageVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[i])
+		}
+		if aPart != bPart {
+			return aPart - bPart
+		}
+	}
+	return 0
+}
+
+// processGalleryImagePurchasePlan gets the purchase plan of a shared or community gallery image. Gallery images which are created from
+// marketplace images carry the purchase plan of the marketplace image, and a VM can only be created from such an image if the plan is passed.
+// Unless SkipMarketplaceAgreement is set, the agreement for the plan is checked and accepted in the same way as it is done for marketplace images.
+// If the gallery image does not have a purchase plan then nil is returned.
+func processGalleryImagePurchasePlan(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, location string, imageRef armcompute.ImageReference, imageRefSpec api.AzureImageReference, vmName string) (*armcompute.Plan, error) {
+	var (
+		imageID      string
+		purchasePlan *armcompute.ImagePurchasePlan
+		err          error
+	)
+	if imageRef.SharedGalleryImageID != nil {
+		imageID = *imageRef.SharedGalleryImageID
+		purchasePlan, err = getSharedGalleryImagePurchasePlan(ctx, factory, connectConfig, location, imageID)
+	} else {
+		imageID = *imageRef.CommunityGalleryImageID
+		purchasePlan, err = getCommunityGalleryImagePurchasePlan(ctx, factory, connectConfig, location, imageID)
+	}
+	if err != nil || purchasePlan == nil {
+		return nil, err
+	}
+	klog.FromContext(ctx).Info("Gallery image has a purchase plan", "vm", vmName, "imageID", imageID, "plan", *purchasePlan.Name, "product", *purchasePlan.Product, "publisher", *purchasePlan.Publisher)
+	if !imageRefSpec.SkipMarketplaceAgreement {
+		plan := armcompute.PurchasePlan{
+			Name:      purchasePlan.Name,
+			Product:   purchasePlan.Product,
+			Publisher: purchasePlan.Publisher,
+		}
+		if err = checkAndAcceptAgreementIfNotAccepted(ctx, factory, connectConfig, vmName, imageID, plan, !imageRefSpec.DisableMarketplaceAgreementAcceptance); err != nil {
+			return nil, err
+		}
+	}
+	return &armcompute.Plan{
+		Name:      purchasePlan.Name,
+		Product:   purchasePlan.Product,
+		Publisher: purchasePlan.Publisher,
+	}, nil
+}
+
+func getSharedGalleryImagePurchasePlan(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, location, imageID string) (*armcompute.ImagePurchasePlan, error) {
+	id, ok := parseGalleryImageVersionID(imageID, "SharedGalleries")
+	if !ok {
+		return nil, nil
+	}
+	imagesAccess, err := factory.GetSharedGalleryImagesAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create shared gallery images access, Err: %v", err), err)
+	}
+	image, err := accesshelpers.GetSharedGalleryImage(ctx, imagesAccess, location, id.galleryName, id.imageName)
+	if err != nil {
+		if accesserrors.IsNotFoundAzAPIError(err) {
+			return nil, status.WrapError(codes.NotFound, fmt.Sprintf("Shared gallery image [Gallery: %s, Image: %s] does not exist", id.galleryName, id.imageName), err)
+		}
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get shared gallery image [Gallery: %s, Image: %s], Err: %v", id.galleryName, id.imageName, err), err)
+	}
+	if image.Properties == nil {
+		return nil, nil
+	}
+	return image.Properties.PurchasePlan, nil
+}
+
+func getCommunityGalleryImagePurchasePlan(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, location, imageID string) (*armcompute.ImagePurchasePlan, error) {
+	id, ok := parseGalleryImageVersionID(imageID, "CommunityGalleries")
+	if !ok {
+		return nil, nil
+	}
+	imagesAccess, err := factory.GetCommunityGalleryImagesAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create community gallery images access, Err: %v", err), err)
+	}
+	image, err := accesshelpers.GetCommunityGalleryImage(ctx, imagesAccess, location, id.galleryName, id.imageName)
+	if err != nil {
+		if accesserrors.IsNotFoundAzAPIError(err) {
+			return nil, status.WrapError(codes.NotFound, fmt.Sprintf("Community gallery image [Gallery: %s, Image: %s] does not exist", id.galleryName, id.imageName), err)
+		}
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get community gallery image [Gallery: %s, Image: %s], Err: %v", id.galleryName, id.imageName, err), err)
+	}
+	if image.Properties == nil {
+		return nil, nil
+	}
+	return image.Properties.PurchasePlan, nil
+}
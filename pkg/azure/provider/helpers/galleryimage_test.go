@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	. "github.com/onsi/gomega"
+)
+
+func TestCompareGalleryImageVersions(t *testing.T) {
+	table := []struct {
+		description string
+		a           string
+		b           string
+		expected    int
+	}{
+		{"should compare versions numerically and not lexicographically", "1.10.0", "1.9.0", 1},
+		{"should consider equal versions", "1.2.3", "1.2.3", 0},
+		{"should treat missing parts as zero", "1.2", "1.2.1", -1},
+	}
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			result := compareGalleryImageVersions(entry.a, entry.b)
+			switch {
+			case entry.expected > 0:
+				g.Expect(result).To(BeNumerically(">", 0))
+			case entry.expected < 0:
+				g.Expect(result).To(BeNumerically("<", 0))
+			default:
+				g.Expect(result).To(BeZero())
+			}
+		})
+	}
+}
+
+func TestParseGalleryImageVersionID(t *testing.T) {
+	g := NewWithT(t)
+	id, ok := parseGalleryImageVersionID("/CommunityGalleries/gardenlinux-13e998fe/Images/gardenlinux/Versions/latest", "CommunityGalleries")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(id).To(Equal(galleryImageVersionID{galleryName: "gardenlinux-13e998fe", imageName: "gardenlinux", version: "latest"}))
+
+	_, ok = parseGalleryImageVersionID("/CommunityGalleries/gardenlinux-13e998fe/Images/gardenlinux", "CommunityGalleries")
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestGetPreviousGalleryImageVersion(t *testing.T) {
+	const (
+		latestSharedImageID    = "/SharedGalleries/test-gallery/Images/gardenlinux/Versions/latest"
+		previousSharedImageID  = "/SharedGalleries/test-gallery/Images/gardenlinux/Versions/1.10.0"
+		latestCommunityImageID = "/CommunityGalleries/test-gallery/Images/gardenlinux/Versions/latest"
+	)
+	table := []struct {
+		description      string
+		imageRef         armcompute.ImageReference
+		previousImageID  string
+		expectedReused   bool
+		expectedImageRef armcompute.ImageReference
+	}{
+		{"should reuse the previous version of the same shared gallery image", armcompute.ImageReference{SharedGalleryImageID: to.Ptr(latestSharedImageID)}, previousSharedImageID,
+			true, armcompute.ImageReference{SharedGalleryImageID: to.Ptr(previousSharedImageID)}},
+		{"should reuse the previous version of the same community gallery image", armcompute.ImageReference{CommunityGalleryImageID: to.Ptr(latestCommunityImageID)}, "/CommunityGalleries/test-gallery/Images/gardenlinux/Versions/1.10.0",
+			true, armcompute.ImageReference{CommunityGalleryImageID: to.Ptr("/CommunityGalleries/test-gallery/Images/gardenlinux/Versions/1.10.0")}},
+		{"should not reuse a version if there is no previous attempt", armcompute.ImageReference{SharedGalleryImageID: to.Ptr(latestSharedImageID)}, "",
+			false, armcompute.ImageReference{SharedGalleryImageID: to.Ptr(latestSharedImageID)}},
+		{"should not reuse the version of another image", armcompute.ImageReference{SharedGalleryImageID: to.Ptr(latestSharedImageID)}, "/SharedGalleries/test-gallery/Images/other/Versions/1.10.0",
+			false, armcompute.ImageReference{SharedGalleryImageID: to.Ptr(latestSharedImageID)}},
+		{"should not reuse the version of a community gallery image for a shared gallery image", armcompute.ImageReference{SharedGalleryImageID: to.Ptr(latestSharedImageID)}, "/CommunityGalleries/test-gallery/Images/gardenlinux/Versions/1.10.0",
+			false, armcompute.ImageReference{SharedGalleryImageID: to.Ptr(latestSharedImageID)}},
+		{"should not change a concrete version", armcompute.ImageReference{SharedGalleryImageID: to.Ptr("/SharedGalleries/test-gallery/Images/gardenlinux/Versions/1.11.0")}, previousSharedImageID,
+			false, armcompute.ImageReference{SharedGalleryImageID: to.Ptr("/SharedGalleries/test-gallery/Images/gardenlinux/Versions/1.11.0")}},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			imageRef, reused := GetPreviousGalleryImageVersion(entry.imageRef, entry.previousImageID)
+			g.Expect(reused).To(Equal(entry.expectedReused))
+			g.Expect(imageRef).To(Equal(entry.expectedImageRef))
+		})
+	}
+}
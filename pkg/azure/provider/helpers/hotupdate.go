@@ -0,0 +1,383 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// HotUpdatableFields are the JSON paths of the fields of api.AzureProviderSpec which can be updated in place on an
+// existing VM. A change to any other field requires the machine to be replaced. Data disks can only be added in place,
+// changing or removing an existing data disk requires the machine to be replaced as well.
+var HotUpdatableFields = []string{
+	"tags",
+	"properties.identityID",
+	"properties.storageProfile.dataDisks",
+	"properties.forceDeletion",
+	"properties.deallocateBeforeDeletion",
+}
+
+// VMSizeField is the JSON path of the VM size in api.AzureProviderSpec. It can only be updated in place if
+// HotUpdateOptions.AllowVMResize is set.
+const VMSizeField = "properties.hardwareProfile.vmSize"
+
+// HotUpdateOptions are the options for updating VMs in place.
+type HotUpdateOptions struct {
+	// AllowVMResize allows to change the VM size in place. To resize it, the VM is deallocated, resized and started
+	// again, so the machine is unavailable during the resize but keeps its disks and NIC.
+	AllowVMResize bool
+}
+
+// IsHotUpdatable checks if the changes from oldSpec to newSpec can be applied to an existing VM in place, i.e. if the
+// specs only differ in HotUpdatableFields (and VMSizeField if resizing is allowed) and no data disk has been changed or removed.
+func IsHotUpdatable(oldSpec, newSpec api.AzureProviderSpec, opts HotUpdateOptions) bool {
+	newDataDisksByLun := make(map[int32]api.AzureDataDisk, len(newSpec.Properties.StorageProfile.DataDisks))
+	for _, dataDisk := range newSpec.Properties.StorageProfile.DataDisks {
+		newDataDisksByLun[dataDisk.Lun] = dataDisk
+	}
+	for _, oldDataDisk := range oldSpec.Properties.StorageProfile.DataDisks {
+		newDataDisk, ok := newDataDisksByLun[oldDataDisk.Lun]
+		if !ok || !reflect.DeepEqual(oldDataDisk, newDataDisk) {
+			return false
+		}
+	}
+	return reflect.DeepEqual(withoutHotUpdatableFields(oldSpec, opts), withoutHotUpdatableFields(newSpec, opts))
+}
+
+func withoutHotUpdatableFields(spec api.AzureProviderSpec, opts HotUpdateOptions) api.AzureProviderSpec {
+	spec.Tags = nil
+	spec.Properties.IdentityID = nil
+	spec.Properties.StorageProfile.DataDisks = nil
+	spec.Properties.ForceDeletion = false
+	spec.Properties.DeallocateBeforeDeletion = false
+	if opts.AllowVMResize {
+		spec.Properties.HardwareProfile.VMSize = ""
+	}
+	return spec
+}
+
+// UpdateVirtualMachineInPlace updates the HotUpdatableFields of the VM to match the provider spec: the tags of the provider
+// spec are added to the VM, its NIC and its disks while tags which have been added outside of MCM are retained, the user-assigned identity is
+// replaced and data disks which are not yet attached to the VM are created and attached. Data disks attached to the VM which are not part of the provider spec (e.g. volumes attached by the CSI driver)
+// are left untouched. If the VM size has changed and resizing is allowed, the VM is deallocated, resized and started again.
+// The deallocation is recorded in the passed LastKnownState until the VM has been started, so that a VM whose start failed
+// is started by the next update. VMs which have been stopped or deallocated otherwise, e.g. by an operator or for
+// hibernation, are left stopped. It returns true if the VM has been updated.
+func UpdateVirtualMachineInPlace(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmAccess *armcompute.VirtualMachinesClient, vm *armcompute.VirtualMachine, vmName, machineName string, opts HotUpdateOptions, lastKnownState *LastKnownState) (bool, error) {
+	var (
+		resourceGroup = providerSpec.ResourceGroup
+		vmUpdate      = armcompute.VirtualMachineUpdate{Properties: &armcompute.VirtualMachineProperties{}}
+		updated       bool
+	)
+
+	resize := isVMResizeRequired(vm, providerSpec)
+	if resize && !opts.AllowVMResize {
+		return false, status.Error(codes.FailedPrecondition, fmt.Sprintf("Cannot change VM size of VM: [ResourceGroup: %s, Name: %s] in place since resizing VMs is not allowed", resourceGroup, vmName))
+	}
+	if resize {
+		vmUpdate.Properties.HardwareProfile = &armcompute.HardwareProfile{
+			VMSize: to.Ptr(armcompute.VirtualMachineSizeTypes(providerSpec.Properties.HardwareProfile.VMSize)),
+		}
+		updated = true
+	}
+
+	if vmTags, ok := mergeTags(vm.Tags, createExpectedVMTags(vm.Tags, providerSpec.Tags, machineName)); ok {
+		vmUpdate.Tags = vmTags
+		updated = true
+	}
+
+	if identity := computeVMIdentityUpdate(vm.Identity, providerSpec.Properties.IdentityID); identity != nil {
+		vmUpdate.Identity = identity
+		updated = true
+	}
+
+	dataDiskSpecsToAdd, err := getDataDiskSpecsToAdd(providerSpec, vm, vmName)
+	if err != nil {
+		return false, err
+	}
+	if len(dataDiskSpecsToAdd) > 0 {
+		dataDisks, err := createDataDisksToAdd(ctx, factory, connectConfig, providerSpec, dataDiskSpecsToAdd, vmName, machineName)
+		if err != nil {
+			return false, err
+		}
+		var attachedDataDisks []*armcompute.DataDisk
+		if vm.Properties != nil && vm.Properties.StorageProfile != nil {
+			attachedDataDisks = vm.Properties.StorageProfile.DataDisks
+		}
+		vmUpdate.Properties.StorageProfile = &armcompute.StorageProfile{
+			// the data disks of the update replace the data disks of the VM, so the attached data disks have to be passed as well
+			DataDisks: slices.Concat(attachedDataDisks, dataDisks),
+		}
+		updated = true
+	}
+
+	tagsUpdated, err := updateNICAndDiskTags(ctx, factory, connectConfig, providerSpec, vm, vmName, machineName)
+	if err != nil {
+		return false, err
+	}
+	// the VM is deallocated for a resize and therefore has to be started afterwards, also if a previous update failed to start it.
+	start := resize || lastKnownState.DeallocatedForResize
+	if !updated && !start {
+		return tagsUpdated, nil
+	}
+	if updated {
+		if resize {
+			klog.FromContext(ctx).Info("Deallocating VM to resize it", "vm", vmName, "vmSize", providerSpec.Properties.HardwareProfile.VMSize)
+			lastKnownState.DeallocatedForResize = true
+			if err = accesshelpers.DeallocateVirtualMachine(ctx, vmAccess, resourceGroup, vmName); err != nil {
+				errCode := accesserrors.GetMatchingErrorCode(err)
+				return false, status.WrapError(errCode, fmt.Sprintf("Failed to deallocate VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+			}
+		}
+		if _, err = accesshelpers.UpdateVirtualMachine(ctx, vmAccess, resourceGroup, vmName, vmUpdate); err != nil {
+			errCode := accesserrors.GetMatchingErrorCode(err)
+			return false, status.WrapError(errCode, fmt.Sprintf("Failed to update VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+	}
+	if start {
+		klog.FromContext(ctx).Info("Starting VM", "vm", vmName)
+		if err = accesshelpers.StartVirtualMachine(ctx, vmAccess, resourceGroup, vmName); err != nil {
+			errCode := accesserrors.GetMatchingErrorCode(err)
+			return false, status.WrapError(errCode, fmt.Sprintf("Failed to start VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+		lastKnownState.DeallocatedForResize = false
+	}
+	klog.FromContext(ctx).Info("Successfully updated VM in place", "vm", vmName)
+	return true, nil
+}
+
+// ReconcileTags adds missing or changed tags of the provider spec to the VM, its NIC and its disks, so that changes of
+// the tags of the provider spec are also applied to existing machines. As for UpdateVirtualMachineInPlace, tags which
+// have been added outside of MCM are retained. It returns true if the tags of any resource have been updated.
+func ReconcileTags(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vm *armcompute.VirtualMachine, vmName, machineName string) (bool, error) {
+	var (
+		resourceGroup = providerSpec.ResourceGroup
+		updated       bool
+	)
+	if vmTags, ok := mergeTags(vm.Tags, createExpectedVMTags(vm.Tags, providerSpec.Tags, machineName)); ok {
+		vmAccess, err := factory.GetVirtualMachinesAccess(connectConfig)
+		if err != nil {
+			return false, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine access for VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+		if _, err = accesshelpers.UpdateVirtualMachine(ctx, vmAccess, resourceGroup, vmName, armcompute.VirtualMachineUpdate{Tags: vmTags}); err != nil {
+			return false, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to update tags of VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+		klog.FromContext(ctx).Info("Successfully updated tags of VM", "vm", vmName)
+		updated = true
+	}
+	tagsUpdated, err := updateNICAndDiskTags(ctx, factory, connectConfig, providerSpec, vm, vmName, machineName)
+	return updated || tagsUpdated, err
+}
+
+// isVMResizeRequired checks if the VM size of the VM differs from the VM size of the provider spec.
+func isVMResizeRequired(vm *armcompute.VirtualMachine, providerSpec api.AzureProviderSpec) bool {
+	if vm.Properties == nil || vm.Properties.HardwareProfile == nil || vm.Properties.HardwareProfile.VMSize == nil {
+		return false
+	}
+	return !strings.EqualFold(string(*vm.Properties.HardwareProfile.VMSize), providerSpec.Properties.HardwareProfile.VMSize)
+}
+
+// createExpectedVMTags creates the tags which are expected on the VM. A utils.ProtectFromDeletionTagKey tag which is already
+// set on the VM is always kept with its value, since it is maintained by operators and must not be reset by an update.
+func createExpectedVMTags(actual map[string]*string, providerSpecTags map[string]string, machineName string) map[string]*string {
+	expected := utils.CreateVMTags(providerSpecTags, machineName)
+	if _, ok := actual[utils.ProtectFromDeletionTagKey]; ok {
+		delete(expected, utils.ProtectFromDeletionTagKey)
+	}
+	return expected
+}
+
+// updateNICAndDiskTags adds missing or changed tags of the provider spec to the NIC, the OSDisk and the data disks which
+// are deleted together with the VM, since they otherwise only receive the tags when they are created. This also adds the
+// machine name tag to the resources of machines which have been created before they were tagged with it. As for the VM, tags which are
+// not part of the provider spec are retained, as the tags of disks are e.g. also maintained by the CSI driver.
+// It returns true if the tags of any resource have been updated.
+func updateNICAndDiskTags(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vm *armcompute.VirtualMachine, vmName, machineName string) (bool, error) {
+	var (
+		resourceGroup = providerSpec.ResourceGroup
+		nicName       = utils.CreateNICName(vmName)
+		updated       bool
+	)
+
+	nicAccess, err := factory.GetNetworkInterfacesAccess(connectConfig)
+	if err != nil {
+		return false, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create nic access for VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	nic, err := accesshelpers.GetNIC(ctx, nicAccess, resourceGroup, nicName)
+	if err != nil {
+		return false, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get NIC: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, nicName, err), err)
+	}
+	if nic != nil {
+		if tags, ok := mergeTags(nic.Tags, utils.CreateVMTags(providerSpec.Tags, machineName)); ok {
+			if _, err = accesshelpers.UpdateNICTags(ctx, nicAccess, resourceGroup, nicName, tags); err != nil {
+				return false, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to update tags of NIC: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, nicName, err), err)
+			}
+			klog.FromContext(ctx).Info("Successfully updated tags of NIC", "nic", nicName)
+			updated = true
+		}
+	}
+
+	type diskTags struct {
+		diskName string
+		tags     map[string]*string
+	}
+	var disks []diskTags
+	if vm.Properties != nil && vm.Properties.StorageProfile != nil && vm.Properties.StorageProfile.OSDisk != nil && vm.Properties.StorageProfile.OSDisk.Name != nil {
+		// the OSDisk is created together with the VM and thereby carries the tags of the VM.
+		disks = append(disks, diskTags{diskName: *vm.Properties.StorageProfile.OSDisk.Name, tags: utils.CreateVMTags(providerSpec.Tags, machineName)})
+	}
+	for _, diskName := range createDataDiskNames(providerSpec, vmName) {
+		disks = append(disks, diskTags{diskName: diskName, tags: utils.CreateVMTags(providerSpec.Tags, machineName)})
+	}
+	disksAccess, err := factory.GetDisksAccess(connectConfig)
+	if err != nil {
+		return false, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create disk access for VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	for _, d := range disks {
+		disk, err := accesshelpers.GetDisk(ctx, disksAccess, resourceGroup, d.diskName)
+		if err != nil {
+			return false, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get Disk: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, d.diskName, err), err)
+		}
+		if disk == nil {
+			continue
+		}
+		tags, ok := mergeTags(disk.Tags, d.tags)
+		if !ok {
+			continue
+		}
+		if _, err = accesshelpers.UpdateDisk(ctx, disksAccess, resourceGroup, d.diskName, armcompute.DiskUpdate{Tags: tags}); err != nil {
+			return false, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to update tags of Disk: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, d.diskName, err), err)
+		}
+		klog.FromContext(ctx).Info("Successfully updated tags of disk", "disk", d.diskName)
+		updated = true
+	}
+	return updated, nil
+}
+
+// mergeTags adds the expected tags to the actual tags. It returns the merged tags and true if any of the expected tags
+// has been missing or had a different value.
+func mergeTags(actual, expected map[string]*string) (map[string]*string, bool) {
+	merged := make(map[string]*string, len(actual)+len(expected))
+	for k, v := range actual {
+		merged[k] = v
+	}
+	var changed bool
+	for k, v := range expected {
+		if actualValue, ok := actual[k]; !ok || pointer.StringDeref(actualValue, "") != pointer.StringDeref(v, "") {
+			merged[k] = v
+			changed = true
+		}
+	}
+	return merged, changed
+}
+
+// computeVMIdentityUpdate computes the identity update which is required to replace the user-assigned identities of
+// the VM with the identity of the provider spec. A system-assigned identity of the VM is retained, since it is not
+// managed by MCM but e.g. by platform tooling like Microsoft Defender. It returns nil if no update is required.
+func computeVMIdentityUpdate(vmIdentity *armcompute.VirtualMachineIdentity, specVMIdentityID *string) *armcompute.VirtualMachineIdentity {
+	var (
+		assignedIdentityIDs []string
+		systemAssigned      bool
+	)
+	if vmIdentity != nil {
+		for identityID := range vmIdentity.UserAssignedIdentities {
+			assignedIdentityIDs = append(assignedIdentityIDs, identityID)
+		}
+		if vmIdentity.Type != nil {
+			systemAssigned = *vmIdentity.Type == armcompute.ResourceIdentityTypeSystemAssigned || *vmIdentity.Type == armcompute.ResourceIdentityTypeSystemAssignedUserAssigned
+		}
+	}
+
+	if specVMIdentityID == nil {
+		if len(assignedIdentityIDs) == 0 {
+			return nil
+		}
+		if systemAssigned {
+			return &armcompute.VirtualMachineIdentity{Type: to.Ptr(armcompute.ResourceIdentityTypeSystemAssigned)}
+		}
+		return &armcompute.VirtualMachineIdentity{Type: to.Ptr(armcompute.ResourceIdentityTypeNone)}
+	}
+	if len(assignedIdentityIDs) == 1 && strings.EqualFold(assignedIdentityIDs[0], *specVMIdentityID) {
+		return nil
+	}
+	identity := getVMIdentity(specVMIdentityID)
+	if systemAssigned {
+		identity.Type = to.Ptr(armcompute.ResourceIdentityTypeSystemAssignedUserAssigned)
+	}
+	for _, identityID := range assignedIdentityIDs {
+		if !strings.EqualFold(identityID, *specVMIdentityID) {
+			// user-assigned identities are removed by setting them to null
+			identity.UserAssignedIdentities[identityID] = nil
+		}
+	}
+	return identity
+}
+
+// getDataDiskSpecsToAdd returns the data disks of the provider spec which are not yet attached to the VM. If a LUN of a
+// data disk of the provider spec is used by a different disk then an error is returned, as that disk cannot be replaced in place.
+func getDataDiskSpecsToAdd(providerSpec api.AzureProviderSpec, vm *armcompute.VirtualMachine, vmName string) ([]api.AzureDataDisk, error) {
+	attachedDiskNamesByLun := make(map[int32]string)
+	if vm.Properties != nil && vm.Properties.StorageProfile != nil {
+		for _, dataDisk := range vm.Properties.StorageProfile.DataDisks {
+			if dataDisk != nil && dataDisk.Lun != nil {
+				attachedDiskNamesByLun[*dataDisk.Lun] = pointer.StringDeref(dataDisk.Name, "")
+			}
+		}
+	}
+
+	var dataDiskSpecsToAdd []api.AzureDataDisk
+	storageProfile := providerSpec.Properties.StorageProfile
+	for _, specDataDisk := range storageProfile.DataDisks {
+		diskName := utils.CreateDataDiskNameFromTemplate(vmName, specDataDisk.Name, specDataDisk.Lun, storageProfile.DataDiskNameTemplate)
+		attachedDiskName, ok := attachedDiskNamesByLun[specDataDisk.Lun]
+		if !ok {
+			dataDiskSpecsToAdd = append(dataDiskSpecsToAdd, specDataDisk)
+			continue
+		}
+		if !strings.EqualFold(attachedDiskName, diskName) {
+			return nil, status.Error(codes.FailedPrecondition, fmt.Sprintf("Cannot attach data disk %s to VM: [ResourceGroup: %s, Name: %s] since LUN %d is already used by disk %s", diskName, providerSpec.ResourceGroup, vmName, specDataDisk.Lun, attachedDiskName))
+		}
+	}
+	return dataDiskSpecsToAdd, nil
+}
+
+// createDataDisksToAdd creates the data disks which have to exist before they are attached and returns the data disks
+// which have to be added to the VM.
+func createDataDisksToAdd(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, dataDiskSpecsToAdd []api.AzureDataDisk, vmName, machineName string) ([]*armcompute.DataDisk, error) {
+	imageRefDiskIDs := make(map[DataDiskLun]DiskID)
+	if slices.ContainsFunc(dataDiskSpecsToAdd, isPreCreatedDataDisk) {
+		disksAccess, err := factory.GetDisksAccess(connectConfig)
+		if err != nil {
+			return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create disk access for VM: [ResourceGroup: %s], Err: %v", providerSpec.ResourceGroup, err), err)
+		}
+		if imageRefDiskIDs, err = createPreCreatedDataDisks(ctx, factory, connectConfig, disksAccess, providerSpec, dataDiskSpecsToAdd, vmName, machineName); err != nil {
+			return nil, err
+		}
+	}
+	storageProfile := providerSpec.Properties.StorageProfile
+	storageProfile.DataDisks = dataDiskSpecsToAdd
+	dataDisks, err := getDataDisks(storageProfile, vmName, imageRefDiskIDs)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create data disks to add to VM: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, vmName, err), err)
+	}
+	return dataDisks, nil
+}
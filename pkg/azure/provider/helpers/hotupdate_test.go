@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+)
+
+func TestIsHotUpdatable(t *testing.T) {
+	newSpec := func(numDataDisks int, mutateFn func(spec *api.AzureProviderSpec)) api.AzureProviderSpec {
+		spec := testhelp.NewProviderSpecBuilder("test-rg", "test-ns", "test-pool").WithDefaultValues().WithDataDisks("dd", numDataDisks).Build()
+		if mutateFn != nil {
+			mutateFn(&spec)
+		}
+		return spec
+	}
+
+	table := []struct {
+		description string
+		oldSpec     api.AzureProviderSpec
+		newSpec     api.AzureProviderSpec
+		opts        HotUpdateOptions
+		expected    bool
+	}{
+		{"should be hot-updatable if nothing has changed", newSpec(1, nil), newSpec(1, nil), HotUpdateOptions{}, true},
+		{
+			"should be hot-updatable if tags have changed", newSpec(1, nil),
+			newSpec(1, func(spec *api.AzureProviderSpec) { spec.Tags = map[string]string{"new-tag": "value"} }), HotUpdateOptions{}, true,
+		},
+		{
+			"should be hot-updatable if the identity has changed", newSpec(1, nil),
+			newSpec(1, func(spec *api.AzureProviderSpec) { spec.Properties.IdentityID = to.Ptr("new-identity") }), HotUpdateOptions{}, true,
+		},
+		{"should be hot-updatable if a data disk has been added", newSpec(1, nil), newSpec(2, nil), HotUpdateOptions{}, true},
+		{"should not be hot-updatable if a data disk has been removed", newSpec(2, nil), newSpec(1, nil), HotUpdateOptions{}, false},
+		{
+			"should not be hot-updatable if a data disk has been changed", newSpec(1, nil),
+			newSpec(1, func(spec *api.AzureProviderSpec) { spec.Properties.StorageProfile.DataDisks[0].DiskSizeGB = 50 }), HotUpdateOptions{}, false,
+		},
+		{
+			"should not be hot-updatable if the VM size has changed", newSpec(1, nil),
+			newSpec(1, func(spec *api.AzureProviderSpec) { spec.Properties.HardwareProfile.VMSize = "Standard_D4s_v5" }), HotUpdateOptions{}, false,
+		},
+		{
+			"should be hot-updatable if the VM size has changed and resizing is allowed", newSpec(1, nil),
+			newSpec(1, func(spec *api.AzureProviderSpec) { spec.Properties.HardwareProfile.VMSize = "Standard_D4s_v5" }), HotUpdateOptions{AllowVMResize: true}, true,
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			g.Expect(IsHotUpdatable(entry.oldSpec, entry.newSpec, entry.opts)).To(Equal(entry.expected))
+		})
+	}
+}
+
+func TestComputeVMIdentityUpdate(t *testing.T) {
+	const (
+		identityID    = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/identity"
+		newIdentityID = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/new-identity"
+	)
+	g := NewWithT(t)
+
+	g.Expect(computeVMIdentityUpdate(nil, nil)).To(BeNil())
+	g.Expect(computeVMIdentityUpdate(getVMIdentity(to.Ptr(identityID)), to.Ptr(identityID))).To(BeNil())
+
+	identity := computeVMIdentityUpdate(getVMIdentity(to.Ptr(identityID)), nil)
+	g.Expect(*identity.Type).To(Equal(armcompute.ResourceIdentityTypeNone))
+
+	identity = computeVMIdentityUpdate(getVMIdentity(to.Ptr(identityID)), to.Ptr(newIdentityID))
+	g.Expect(*identity.Type).To(Equal(armcompute.ResourceIdentityTypeUserAssigned))
+	g.Expect(identity.UserAssignedIdentities).To(HaveLen(2))
+	g.Expect(identity.UserAssignedIdentities[newIdentityID]).ToNot(BeNil())
+	g.Expect(identity.UserAssignedIdentities).To(HaveKeyWithValue(identityID, BeNil()))
+
+	// a system-assigned identity of the VM is retained
+	vmIdentity := getVMIdentity(to.Ptr(identityID))
+	vmIdentity.Type = to.Ptr(armcompute.ResourceIdentityTypeSystemAssignedUserAssigned)
+	g.Expect(computeVMIdentityUpdate(vmIdentity, to.Ptr(identityID))).To(BeNil())
+
+	identity = computeVMIdentityUpdate(vmIdentity, nil)
+	g.Expect(*identity.Type).To(Equal(armcompute.ResourceIdentityTypeSystemAssigned))
+
+	identity = computeVMIdentityUpdate(vmIdentity, to.Ptr(newIdentityID))
+	g.Expect(*identity.Type).To(Equal(armcompute.ResourceIdentityTypeSystemAssignedUserAssigned))
+	g.Expect(identity.UserAssignedIdentities).To(HaveLen(2))
+	g.Expect(identity.UserAssignedIdentities[newIdentityID]).ToNot(BeNil())
+	g.Expect(identity.UserAssignedIdentities).To(HaveKeyWithValue(identityID, BeNil()))
+
+	// a VM which only has a system-assigned identity gets the user-assigned identity of the provider spec in addition
+	g.Expect(computeVMIdentityUpdate(&armcompute.VirtualMachineIdentity{Type: to.Ptr(armcompute.ResourceIdentityTypeSystemAssigned)}, nil)).To(BeNil())
+	identity = computeVMIdentityUpdate(&armcompute.VirtualMachineIdentity{Type: to.Ptr(armcompute.ResourceIdentityTypeSystemAssigned)}, to.Ptr(newIdentityID))
+	g.Expect(*identity.Type).To(Equal(armcompute.ResourceIdentityTypeSystemAssignedUserAssigned))
+	g.Expect(identity.UserAssignedIdentities).To(HaveLen(1))
+}
+
+func TestMergeTags(t *testing.T) {
+	g := NewWithT(t)
+	expectedTags := map[string]*string{"tag-0": to.Ptr("value-0"), "tag-1": to.Ptr("value-1")}
+
+	merged, changed := mergeTags(map[string]*string{"tag-0": to.Ptr("value-0"), "tag-1": to.Ptr("value-1"), "csi-tag": to.Ptr("csi-value")}, expectedTags)
+	g.Expect(changed).To(BeFalse())
+	g.Expect(merged).To(HaveLen(3))
+
+	merged, changed = mergeTags(map[string]*string{"tag-0": to.Ptr("old-value"), "csi-tag": to.Ptr("csi-value")}, expectedTags)
+	g.Expect(changed).To(BeTrue())
+	g.Expect(merged).To(Equal(map[string]*string{"tag-0": to.Ptr("value-0"), "tag-1": to.Ptr("value-1"), "csi-tag": to.Ptr("csi-value")}))
+
+	merged, changed = mergeTags(nil, expectedTags)
+	g.Expect(changed).To(BeTrue())
+	g.Expect(merged).To(Equal(expectedTags))
+}
@@ -0,0 +1,174 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+// LastKnownStateVersion is the version of the LastKnownState document which is written by this provider.
+const LastKnownStateVersion = "v1"
+
+// PendingOperationType is the type of long-running operation which had not completed when a driver call returned.
+type PendingOperationType string
+
+const (
+	// PendingOperationTypeCreateNIC is the creation of the NIC of the VM.
+	PendingOperationTypeCreateNIC PendingOperationType = "CreateNIC"
+	// PendingOperationTypeCreateVM is the creation of the VM.
+	PendingOperationTypeCreateVM PendingOperationType = "CreateVM"
+	// PendingOperationTypeDeleteVM is the deletion of the VM.
+	PendingOperationTypeDeleteVM PendingOperationType = "DeleteVM"
+)
+
+// LastKnownState is the structured document which is recorded as LastKnownState in the responses of
+// driver.CreateMachine, driver.DeleteMachine and UpdateMachine. MCM hands it back on subsequent calls for the same machine.
+type LastKnownState struct {
+	// Version is the version of the document.
+	Version string `json:"version"`
+	// CreatedResources are the resources which have been created for the VM so far.
+	CreatedResources CreatedResources `json:"createdResources"`
+	// Zone is the zone in which the VM and its resources are created.
+	Zone *int `json:"zone,omitempty"`
+	// ImageID identifies the image including the resolved version from which the VM is created.
+	ImageID string `json:"imageID,omitempty"`
+	// PendingOperations are the long-running operations which had not completed when the driver call returned.
+	PendingOperations []PendingOperation `json:"pendingOperations,omitempty"`
+	// CreationFailure is the failure of the most recent attempt to create the VM. It is not set once the VM has been created.
+	CreationFailure *CreationFailure `json:"creationFailure,omitempty"`
+	// DeallocatedForResize is set if the VM has been deallocated to resize it in place and has not been started again since.
+	DeallocatedForResize bool `json:"deallocatedForResize,omitempty"`
+}
+
+// CreationFailure captures a failed attempt to create the VM.
+type CreationFailure struct {
+	// Time is the time at which the creation failed.
+	Time time.Time `json:"time"`
+	// Code is the code of the error with which the creation failed.
+	Code string `json:"code"`
+	// RequestIDs identify the Azure API request which failed, if the creation failed due to an Azure API error.
+	RequestIDs *accesserrors.RequestIDs `json:"requestIDs,omitempty"`
+}
+
+// CreatedResources captures the IDs of the resources which have been created for a VM.
+type CreatedResources struct {
+	// NICID is the ID of the NIC.
+	NICID string `json:"nicID,omitempty"`
+	// DataDiskIDs are the IDs of the data disks which have been created before the VM, keyed by their LUN.
+	DataDiskIDs map[DataDiskLun]string `json:"dataDiskIDs,omitempty"`
+	// OSDiskID is the ID of the OSDisk if it has been restored before the VM is created.
+	OSDiskID string `json:"osDiskID,omitempty"`
+	// VMID is the ID of the VM.
+	VMID string `json:"vmID,omitempty"`
+}
+
+// PendingOperation is a long-running operation which can be resumed using its ResumeToken.
+type PendingOperation struct {
+	// Type is the type of the operation.
+	Type PendingOperationType `json:"type"`
+	// ResourceName is the name of the resource the operation is performed on.
+	ResourceName string `json:"resourceName"`
+	// ResumeToken is the token of the poller of the operation.
+	ResumeToken string `json:"resumeToken"`
+}
+
+// Encode serializes the LastKnownState. An empty string is returned if it can not be serialized, since the
+// LastKnownState is only recorded on a best-effort basis.
+func (s *LastKnownState) Encode(ctx context.Context) string {
+	if s == nil {
+		return ""
+	}
+	s.Version = LastKnownStateVersion
+	data, err := json.Marshal(s)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to serialize LastKnownState")
+		return ""
+	}
+	return string(data)
+}
+
+// DecodeLastKnownState deserializes a LastKnownState which has been recorded by a previous driver call. Nil is returned if
+// no state has been recorded or if it has been recorded by an older version of this provider as plain text.
+func DecodeLastKnownState(lastKnownState string) (*LastKnownState, error) {
+	if !strings.HasPrefix(strings.TrimSpace(lastKnownState), "{") {
+		return nil, nil
+	}
+	state := &LastKnownState{}
+	if err := json.Unmarshal([]byte(lastKnownState), state); err != nil {
+		return nil, fmt.Errorf("failed to deserialize LastKnownState: %w", err)
+	}
+	if state.Version != LastKnownStateVersion {
+		return nil, fmt.Errorf("unsupported LastKnownState version %q", state.Version)
+	}
+	return state, nil
+}
+
+// AddPendingOperationFromError records a pending operation if the passed error has been caused by a long-running
+// operation which did not complete.
+func (s *LastKnownState) AddPendingOperationFromError(opType PendingOperationType, resourceName string, err error) {
+	var pollingErr *accesserrors.PollingError
+	if !errors.As(err, &pollingErr) {
+		var statusErr *status.Status
+		if !errors.As(err, &statusErr) || !errors.As(statusErr.Cause(), &pollingErr) {
+			return
+		}
+	}
+	s.PendingOperations = append(s.PendingOperations, PendingOperation{
+		Type:         opType,
+		ResourceName: resourceName,
+		ResumeToken:  pollingErr.ResumeToken,
+	})
+}
+
+// NewCreationFailure creates a CreationFailure for the passed error with which the creation of the VM failed at the passed time.
+func NewCreationFailure(err error, failureTime time.Time) *CreationFailure {
+	code := codes.Internal
+	var statusErr *status.Status
+	if errors.As(err, &statusErr) {
+		code = statusErr.Code()
+	}
+	return &CreationFailure{Time: failureTime.UTC(), Code: code.String(), RequestIDs: accesserrors.GetRequestIDs(err)}
+}
+
+// GetDataDiskIDs converts the IDs of the created data disks so that they can be recorded in the LastKnownState.
+func GetDataDiskIDs(diskIDs map[DataDiskLun]DiskID) map[DataDiskLun]string {
+	if len(diskIDs) == 0 {
+		return nil
+	}
+	ids := make(map[DataDiskLun]string, len(diskIDs))
+	for lun, id := range diskIDs {
+		if id != nil {
+			ids[lun] = *id
+		}
+	}
+	return ids
+}
+
+// GetImageID returns an identifier of the image including its resolved version.
+func GetImageID(imageRef armcompute.ImageReference) string {
+	switch {
+	case imageRef.SharedGalleryImageID != nil:
+		return *imageRef.SharedGalleryImageID
+	case imageRef.CommunityGalleryImageID != nil:
+		return *imageRef.CommunityGalleryImageID
+	case imageRef.ID != nil:
+		return *imageRef.ID
+	case imageRef.Publisher != nil && imageRef.Offer != nil && imageRef.SKU != nil && imageRef.Version != nil:
+		return fmt.Sprintf("%s:%s:%s:%s", *imageRef.Publisher, *imageRef.Offer, *imageRef.SKU, *imageRef.Version)
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	. "github.com/onsi/gomega"
+
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+func TestEncodeAndDecodeLastKnownState(t *testing.T) {
+	g := NewWithT(t)
+	state := &LastKnownState{
+		CreatedResources: CreatedResources{
+			NICID:       "nic-id",
+			DataDiskIDs: map[DataDiskLun]string{1: "disk-id"},
+		},
+		Zone:              to.Ptr(2),
+		ImageID:           "publisher:offer:sku:1.0.0",
+		PendingOperations: []PendingOperation{{Type: PendingOperationTypeCreateVM, ResourceName: "vm-0", ResumeToken: "token"}},
+	}
+	decoded, err := DecodeLastKnownState(state.Encode(context.Background()))
+	g.Expect(err).To(BeNil())
+	g.Expect(decoded.Version).To(Equal(LastKnownStateVersion))
+	g.Expect(decoded).To(Equal(state))
+}
+
+func TestDecodeLastKnownState(t *testing.T) {
+	table := []struct {
+		description    string
+		lastKnownState string
+		expectNil      bool
+		expectErr      bool
+	}{
+		{"should return nil if no state has been recorded", "", true, false},
+		{"should return nil if a plain text state has been recorded", "Created VM in zone 1", true, false},
+		{"should fail if the state can not be deserialized", "{invalid", true, true},
+		{"should fail if the version is not supported", `{"version":"v0"}`, true, true},
+		{"should decode a supported version", `{"version":"v1","zone":1}`, false, false},
+	}
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			state, err := DecodeLastKnownState(entry.lastKnownState)
+			g.Expect(err != nil).To(Equal(entry.expectErr))
+			g.Expect(state == nil).To(Equal(entry.expectNil))
+		})
+	}
+}
+
+func TestAddPendingOperationFromError(t *testing.T) {
+	g := NewWithT(t)
+	state := &LastKnownState{}
+	state.AddPendingOperationFromError(PendingOperationTypeCreateVM, "vm-0", fmt.Errorf("some error"))
+	g.Expect(state.PendingOperations).To(BeEmpty())
+
+	pollingErr := &accesserrors.PollingError{ResumeToken: "token", Err: fmt.Errorf("polling failed")}
+	state.AddPendingOperationFromError(PendingOperationTypeCreateVM, "vm-0", status.WrapError(codes.Internal, pollingErr.Error(), pollingErr))
+	g.Expect(state.PendingOperations).To(Equal([]PendingOperation{{Type: PendingOperationTypeCreateVM, ResourceName: "vm-0", ResumeToken: "token"}}))
+}
+
+func TestGetImageID(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(GetImageID(armcompute.ImageReference{SharedGalleryImageID: to.Ptr("/SharedGalleries/g/Images/i/Versions/1.0.0")})).To(Equal("/SharedGalleries/g/Images/i/Versions/1.0.0"))
+	g.Expect(GetImageID(armcompute.ImageReference{ID: to.Ptr("image-id")})).To(Equal("image-id"))
+	g.Expect(GetImageID(armcompute.ImageReference{Publisher: to.Ptr("p"), Offer: to.Ptr("o"), SKU: to.Ptr("s"), Version: to.Ptr("1.0.0")})).To(Equal("p:o:s:1.0.0"))
+	g.Expect(GetImageID(armcompute.ImageReference{})).To(BeEmpty())
+}
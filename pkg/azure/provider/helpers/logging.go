@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+// NewMachineLogContext returns a copy of ctx whose logger adds the driver operation, the name of the machine and the
+// resource group to every log line. All helpers log via the logger of the passed context, so that the log lines of a
+// machine can be queried by these keys in log backends.
+func NewMachineLogContext(ctx context.Context, operation, machineName, resourceGroup string) context.Context {
+	logger := klog.FromContext(ctx).WithValues("operation", operation, "machine", machineName, "resourceGroup", resourceGroup)
+	return klog.NewContext(ctx, logger)
+}
+
+// errKeysAndValues appends the key/value pairs to log the error along with the request IDs of the Azure API response
+// which caused it, if there is one, to the passed key/value pairs, see accesserrors.GetRequestIDsFromContext.
+func errKeysAndValues(ctx context.Context, err error, keysAndValues ...any) []any {
+	keysAndValues = append(keysAndValues, "err", err)
+	if requestIDs := accesserrors.GetRequestIDsFromContext(ctx, err); requestIDs != nil {
+		keysAndValues = append(keysAndValues, "requestID", requestIDs.RequestID, "correlationRequestID", requestIDs.CorrelationRequestID)
+	}
+	return keysAndValues
+}
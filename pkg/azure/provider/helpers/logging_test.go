@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	. "github.com/onsi/gomega"
+
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+)
+
+func TestErrKeysAndValues(t *testing.T) {
+	g := NewWithT(t)
+	headers := http.Header{}
+	headers.Set(accesserrors.RequestIDAzHeaderKey, "request-id")
+	headers.Set(accesserrors.CorrelationRequestIDAzHeaderKey, "correlation-request-id")
+	respErr := runtime.NewResponseError(&http.Response{StatusCode: http.StatusInternalServerError, Header: headers, Body: io.NopCloser(strings.NewReader(""))})
+
+	ctx := context.Background()
+	g.Expect(errKeysAndValues(ctx, respErr, "vm", "vm-0")).To(Equal([]any{"vm", "vm-0", "err", respErr, "requestID", "request-id", "correlationRequestID", "correlation-request-id"}))
+
+	err := errors.New("test-error")
+	g.Expect(errKeysAndValues(ctx, err, "vm", "vm-0")).To(Equal([]any{"vm", "vm-0", "err", err}))
+
+	// the request IDs of the last failed response are logged if the error does not carry the response
+	ctx = accesserrors.WithRequestIDsRecorder(ctx)
+	accesserrors.RecordRequestIDs(ctx, headers)
+	g.Expect(errKeysAndValues(ctx, err, "vm", "vm-0")).To(Equal([]any{"vm", "vm-0", "err", err, "requestID", "request-id", "correlationRequestID", "correlation-request-id"}))
+}
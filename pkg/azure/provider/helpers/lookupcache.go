@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/marketplaceordering/armmarketplaceordering"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// LookupCacheTTLs are the durations for which the results of lookups, which are otherwise repeated for every machine
+// creation or in quick succession, are cached. A duration which is not set (zero) disables caching of the respective lookup.
+type LookupCacheTTLs struct {
+	// Subnet is the duration for which a subnet is cached.
+	Subnet time.Duration
+	// VMImage is the duration for which a marketplace VM image is cached.
+	VMImage time.Duration
+	// AgreementTerms is the duration for which accepted marketplace agreement terms are cached. Agreement terms which have
+	// not been accepted are never cached.
+	AgreementTerms time.Duration
+	// GalleryImageVersion is the duration for which the version, to which the latest version of a shared or community
+	// gallery image has been resolved, is pinned.
+	GalleryImageVersion time.Duration
+	// ListMachines is the duration for which the resources listed by ListMachines are cached. It is meant to be a few seconds
+	// to collapse bursts of identical resource graph queries, e.g. from multiple workers of the machine controller.
+	ListMachines time.Duration
+}
+
+var (
+	lookupCachesMutex sync.RWMutex
+	// subnetCache caches subnets, key is created using createSubnetCacheKey. It is nil if caching of subnets is disabled.
+	subnetCache *utils.TTLCache[string, *armnetwork.Subnet]
+	// vmImageCache caches marketplace VM images, key is created using createVMImageCacheKey. It is nil if caching of VM images is disabled.
+	vmImageCache *utils.TTLCache[string, *armcompute.VirtualMachineImage]
+	// agreementTermsCache caches accepted marketplace agreement terms, key is created using createAgreementTermsCacheKey.
+	// It is nil if caching of agreement terms is disabled.
+	agreementTermsCache *utils.TTLCache[string, *armmarketplaceordering.AgreementTerms]
+	// galleryImageVersionCache caches the IDs to which gallery image IDs referring to the latest version have been resolved,
+	// key is created using createGalleryImageVersionCacheKey. It is nil if caching of gallery image versions is disabled.
+	galleryImageVersionCache *utils.TTLCache[string, string]
+	// listMachinesCache caches the result entries of the resource graph query of ListMachines, key is created using
+	// createListMachinesCacheKey. It is nil if caching of ListMachines is disabled.
+	listMachinesCache *utils.TTLCache[string, []resultEntry]
+)
+
+// DefaultLookupCacheTTLs returns the default LookupCacheTTLs.
+func DefaultLookupCacheTTLs() LookupCacheTTLs {
+	return LookupCacheTTLs{
+		Subnet:              5 * time.Minute,
+		VMImage:             5 * time.Minute,
+		AgreementTerms:      5 * time.Minute,
+		GalleryImageVersion: 30 * time.Minute,
+		ListMachines:        0,
+	}
+}
+
+// SetLookupCacheTTLs (re)creates the lookup caches with the passed TTLs, previously cached entries are dropped.
+// Lookups are not cached until this function has been called.
+func SetLookupCacheTTLs(ttls LookupCacheTTLs) {
+	lookupCachesMutex.Lock()
+	defer lookupCachesMutex.Unlock()
+	subnetCache = newLookupCache[*armnetwork.Subnet](ttls.Subnet)
+	vmImageCache = newLookupCache[*armcompute.VirtualMachineImage](ttls.VMImage)
+	agreementTermsCache = newLookupCache[*armmarketplaceordering.AgreementTerms](ttls.AgreementTerms)
+	galleryImageVersionCache = newLookupCache[string](ttls.GalleryImageVersion)
+	listMachinesCache = newLookupCache[[]resultEntry](ttls.ListMachines)
+}
+
+func newLookupCache[V any](ttl time.Duration) *utils.TTLCache[string, V] {
+	if ttl <= 0 {
+		return nil
+	}
+	return utils.NewTTLCache[string, V](ttl)
+}
+
+func getSubnetCache() *utils.TTLCache[string, *armnetwork.Subnet] {
+	lookupCachesMutex.RLock()
+	defer lookupCachesMutex.RUnlock()
+	return subnetCache
+}
+
+func createSubnetCacheKey(subscriptionID, vnetResourceGroup, vnetName, subnetName string) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s/%s", subscriptionID, vnetResourceGroup, vnetName, subnetName))
+}
+
+func getVMImageCache() *utils.TTLCache[string, *armcompute.VirtualMachineImage] {
+	lookupCachesMutex.RLock()
+	defer lookupCachesMutex.RUnlock()
+	return vmImageCache
+}
+
+func getAgreementTermsCache() *utils.TTLCache[string, *armmarketplaceordering.AgreementTerms] {
+	lookupCachesMutex.RLock()
+	defer lookupCachesMutex.RUnlock()
+	return agreementTermsCache
+}
+
+// createVMImageCacheKey creates the cache key of a marketplace VM image, which is identified by its URN in a location.
+func createVMImageCacheKey(subscriptionID, location string, imageReference armcompute.ImageReference) string {
+	urn := strings.Join([]string{*imageReference.Publisher, *imageReference.Offer, *imageReference.SKU, *imageReference.Version}, ":")
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s", subscriptionID, location, urn))
+}
+
+// createAgreementTermsCacheKey creates the cache key of marketplace agreement terms, which are accepted per subscription and plan.
+func createAgreementTermsCacheKey(subscriptionID string, plan armcompute.PurchasePlan) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s/%s", subscriptionID, *plan.Publisher, *plan.Product, *plan.Name))
+}
+
+func getGalleryImageVersionCache() *utils.TTLCache[string, string] {
+	lookupCachesMutex.RLock()
+	defer lookupCachesMutex.RUnlock()
+	return galleryImageVersionCache
+}
+
+// createGalleryImageVersionCacheKey creates the cache key of a resolved gallery image ID, which is identified by the
+// gallery image ID referring to the latest version in a location.
+func createGalleryImageVersionCacheKey(subscriptionID, location, imageID string) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s", subscriptionID, location, imageID))
+}
+
+func getListMachinesCache() *utils.TTLCache[string, []resultEntry] {
+	lookupCachesMutex.RLock()
+	defer lookupCachesMutex.RUnlock()
+	return listMachinesCache
+}
+
+// createListMachinesCacheKey creates the cache key of the result of a resource graph query, which is identified by the
+// subscription and the query itself. The query contains the resource groups and tag keys it filters for.
+func createListMachinesCacheKey(subscriptionID, query string) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s", subscriptionID, query))
+}
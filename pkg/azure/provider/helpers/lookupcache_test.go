@@ -0,0 +1,250 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	. "github.com/onsi/gomega"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp/fakes"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+func TestGetSubnetCache(t *testing.T) {
+	table := []struct {
+		description       string
+		subnetCacheTTL    time.Duration
+		expectCachedFetch bool
+	}{
+		{"should fetch the subnet for every call if the cache is disabled", 0, false},
+		{"should return the cached subnet if the cache is enabled", time.Minute, true},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+	connectConfig := access.ConnectConfig{SubscriptionID: testhelp.SubscriptionID}
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	defer SetLookupCacheTTLs(LookupCacheTTLs{})
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			SetLookupCacheTTLs(LookupCacheTTLs{Subnet: entry.subnetCacheTTL})
+			clusterState := fakes.NewClusterState(providerSpec).
+				WithSubnet(testResourceGroupName, providerSpec.SubnetInfo.SubnetName, providerSpec.SubnetInfo.VnetName)
+			fakeFactory := fakes.NewFactory(testResourceGroupName)
+			subnetAccess, err := fakeFactory.NewSubnetAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			fakeFactory.WithSubnetAccess(subnetAccess)
+
+			subnet, err := GetSubnet(ctx, fakeFactory, connectConfig, providerSpec)
+			g.Expect(err).To(BeNil())
+			g.Expect(subnet).ToNot(BeNil())
+
+			// remove the subnet, only a cached subnet can be returned afterwards
+			clusterState.SubnetSpec = nil
+			cachedSubnet, err := GetSubnet(ctx, fakeFactory, connectConfig, providerSpec)
+			if entry.expectCachedFetch {
+				g.Expect(err).To(BeNil())
+				g.Expect(cachedSubnet).To(Equal(subnet))
+			} else {
+				g.Expect(err).ToNot(BeNil())
+			}
+		})
+	}
+}
+
+func TestProcessVMImageConfigurationCache(t *testing.T) {
+	table := []struct {
+		description       string
+		cacheTTLs         LookupCacheTTLs
+		expectCachedFetch bool
+	}{
+		{"should fetch the VM image and agreement terms for every call if the caches are disabled", LookupCacheTTLs{}, false},
+		{"should fetch the VM image for every call if only the agreement terms cache is enabled", LookupCacheTTLs{AgreementTerms: time.Minute}, false},
+		{"should return the cached VM image and agreement terms if the caches are enabled", LookupCacheTTLs{VMImage: time.Minute, AgreementTerms: time.Minute}, true},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+	connectConfig := access.ConnectConfig{SubscriptionID: testhelp.SubscriptionID}
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	defer SetLookupCacheTTLs(LookupCacheTTLs{})
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			SetLookupCacheTTLs(entry.cacheTTLs)
+			clusterState := fakes.NewClusterState(providerSpec).WithDefaultVMImageSpec().WithAgreementTerms(true)
+			fakeFactory := fakes.NewFactory(testResourceGroupName)
+			vmImageAccess, err := fakeFactory.NewImageAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			agreementAccess, err := fakeFactory.NewMarketPlaceAgreementAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			fakeFactory.WithVirtualMachineImagesAccess(vmImageAccess).WithMarketPlaceAgreementsAccess(agreementAccess)
+
+			_, plan, err := ProcessVMImageConfiguration(ctx, fakeFactory, connectConfig, providerSpec, "test-vm", "")
+			g.Expect(err).To(BeNil())
+			g.Expect(plan).ToNot(BeNil())
+
+			// remove the VM image and its agreement terms, only cached results can be returned afterwards
+			clusterState.VMImageSpec = nil
+			clusterState.AgreementTerms = nil
+			_, cachedPlan, err := ProcessVMImageConfiguration(ctx, fakeFactory, connectConfig, providerSpec, "test-vm", "")
+			if entry.expectCachedFetch {
+				g.Expect(err).To(BeNil())
+				g.Expect(cachedPlan).To(Equal(plan))
+			} else {
+				g.Expect(err).ToNot(BeNil())
+			}
+		})
+	}
+}
+
+func TestResolveLatestGalleryImageVersionCache(t *testing.T) {
+	const (
+		galleryName = "test-gallery"
+		imageName   = "gardenlinux"
+	)
+	table := []struct {
+		description               string
+		galleryImageVersionTTL    time.Duration
+		expectedResolvedVersionID string
+	}{
+		{"should resolve the latest version for every call if the cache is disabled", 0, "/SharedGalleries/test-gallery/Images/gardenlinux/Versions/1.11.0"},
+		{"should keep the resolved version pinned if the cache is enabled", time.Minute, "/SharedGalleries/test-gallery/Images/gardenlinux/Versions/1.10.0"},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+	connectConfig := access.ConnectConfig{SubscriptionID: testhelp.SubscriptionID}
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	imageRef := armcompute.ImageReference{SharedGalleryImageID: to.Ptr("/SharedGalleries/test-gallery/Images/gardenlinux/Versions/latest")}
+	defer SetLookupCacheTTLs(LookupCacheTTLs{})
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			SetLookupCacheTTLs(LookupCacheTTLs{GalleryImageVersion: entry.galleryImageVersionTTL})
+			clusterState := fakes.NewClusterState(providerSpec).
+				WithGalleryImage(galleryName, imageName, fakes.GalleryImageSpec{Versions: []string{"1.9.0", "1.10.0"}})
+			fakeFactory := fakes.NewFactory(testResourceGroupName)
+			versionsAccess, err := fakeFactory.NewSharedGalleryImageVersionsAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			fakeFactory.WithSharedGalleryImageVersionsAccess(versionsAccess)
+
+			resolvedImageRef, err := ResolveLatestGalleryImageVersion(ctx, fakeFactory, connectConfig, providerSpec.Location, imageRef)
+			g.Expect(err).To(BeNil())
+			g.Expect(*resolvedImageRef.SharedGalleryImageID).To(Equal("/SharedGalleries/test-gallery/Images/gardenlinux/Versions/1.10.0"))
+
+			// publish a new version, it is only used if the previously resolved version is not pinned
+			clusterState.WithGalleryImage(galleryName, imageName, fakes.GalleryImageSpec{Versions: []string{"1.9.0", "1.10.0", "1.11.0"}})
+			resolvedImageRef, err = ResolveLatestGalleryImageVersion(ctx, fakeFactory, connectConfig, providerSpec.Location, imageRef)
+			g.Expect(err).To(BeNil())
+			g.Expect(*resolvedImageRef.SharedGalleryImageID).To(Equal(entry.expectedResolvedVersionID))
+		})
+	}
+}
+
+func TestGetVMSizeResourceSKUCachesMisses(t *testing.T) {
+	const (
+		// NOTE: resource SKUs are cached per VM size, therefore the VM sizes are not used by other tests.
+		offeredVMSize    = "Standard_Miss_Test_Offered"
+		notOfferedVMSize = "Standard_Miss_Test_Not_Offered"
+		uncachedVMSize   = "Standard_Miss_Test_Uncached"
+	)
+	g := NewWithT(t)
+	ctx := context.Background()
+	connectConfig := access.ConnectConfig{SubscriptionID: testhelp.SubscriptionID}
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec).WithVMSizeResourceSKU(offeredVMSize, map[string]string{})
+	fakeFactory := fakes.NewFactory(testResourceGroupName)
+	skuAccess, err := fakeFactory.NewResourceSKUsAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	fakeFactory.WithResourceSKUsAccess(skuAccess)
+
+	_, err = GetVMSizeResourceSKU(ctx, fakeFactory, connectConfig, providerSpec.Location, notOfferedVMSize)
+	g.Expect(errors.Is(err, ErrVMSizeNotOffered)).To(BeTrue())
+
+	// listing fails from now on, only the cached miss can be returned for the VM size which is not offered
+	failingSKUAccess, err := fakeFactory.NewResourceSKUsAccessBuilder().WithClusterState(clusterState).
+		WithAPIBehaviorSpec(fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.ResourceSKUResourceType, testhelp.AccessMethodNewListPager, testhelp.InternalServerError("test-error-code"))).
+		Build()
+	g.Expect(err).To(BeNil())
+	fakeFactory.WithResourceSKUsAccess(failingSKUAccess)
+	_, err = GetVMSizeResourceSKU(ctx, fakeFactory, connectConfig, providerSpec.Location, notOfferedVMSize)
+	g.Expect(errors.Is(err, ErrVMSizeNotOffered)).To(BeTrue())
+	_, err = GetVMSizeResourceSKU(ctx, fakeFactory, connectConfig, providerSpec.Location, uncachedVMSize)
+	g.Expect(err).ToNot(BeNil())
+	g.Expect(errors.Is(err, ErrVMSizeNotOffered)).To(BeFalse())
+}
+
+func TestAgreementTermsCacheSkipsUnacceptedTerms(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	connectConfig := access.ConnectConfig{SubscriptionID: testhelp.SubscriptionID}
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.StorageProfile.ImageReference.DisableMarketplaceAgreementAcceptance = true
+	SetLookupCacheTTLs(LookupCacheTTLs{AgreementTerms: time.Minute})
+	defer SetLookupCacheTTLs(LookupCacheTTLs{})
+
+	clusterState := fakes.NewClusterState(providerSpec).WithDefaultVMImageSpec().WithAgreementTerms(false)
+	fakeFactory := fakes.NewFactory(testResourceGroupName)
+	vmImageAccess, err := fakeFactory.NewImageAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	agreementAccess, err := fakeFactory.NewMarketPlaceAgreementAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	fakeFactory.WithVirtualMachineImagesAccess(vmImageAccess).WithMarketPlaceAgreementsAccess(agreementAccess)
+
+	// agreement terms which have not been accepted must be checked again for every call
+	for range 2 {
+		_, _, err = ProcessVMImageConfiguration(ctx, fakeFactory, connectConfig, providerSpec, "test-vm", "")
+		g.Expect(err).ToNot(BeNil())
+	}
+}
+
+func TestExtractVMNamesFromVMsNICsDisksCache(t *testing.T) {
+	table := []struct {
+		description     string
+		listMachinesTTL time.Duration
+		expectedVMNames []string
+	}{
+		{"should query resource graph for every call if the cache is disabled", 0, []string{"vm-0", "vm-1"}},
+		{"should return the cached result if the cache is enabled", time.Minute, []string{"vm-0"}},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+	connectConfig := access.ConnectConfig{SubscriptionID: testhelp.SubscriptionID}
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	defer SetLookupCacheTTLs(LookupCacheTTLs{})
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			SetLookupCacheTTLs(LookupCacheTTLs{ListMachines: entry.listMachinesTTL})
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, "vm-0").BuildAllResources())
+			fakeFactory := fakes.NewFactory(testResourceGroupName)
+			resourceGraphAccess, err := fakeFactory.NewResourceGraphAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			fakeFactory.WithResourceGraphAccess(resourceGraphAccess)
+
+			vmNames, err := ExtractVMNamesFromVMsNICsDisks(ctx, fakeFactory, connectConfig, testResourceGroupName, providerSpec)
+			g.Expect(err).To(BeNil())
+			g.Expect(vmNames).To(ConsistOf("vm-0"))
+
+			// a machine which is created in the meantime is only listed if the result has not been cached
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, "vm-1").BuildAllResources())
+			vmNames, err = ExtractVMNamesFromVMsNICsDisks(ctx, fakeFactory, connectConfig, testResourceGroupName, providerSpec)
+			g.Expect(err).To(BeNil())
+			g.Expect(vmNames).To(ConsistOf(entry.expectedVMNames))
+		})
+	}
+}
@@ -5,47 +5,39 @@
 package helpers
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api/validation"
 	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
 )
 
 // DecodeAndValidateMachineClassProviderSpec decodes v1alpha1.MachineClass.ProviderSpec.Raw into api.AzureProviderSpec.
-// It also handles deprecated fields and ensures that the replacement fields are populated. A validated api.AzureProviderSpec
+// It also handles deprecated fields and ensures that the replacement fields are populated, and sets the defaults of unset
+// fields, see validation.DecodeAndValidateRawProviderSpec. A validated api.AzureProviderSpec
 // is returned. In case there is an error during unmarshalling or validation an error will be returned.
 func DecodeAndValidateMachineClassProviderSpec(mcc *v1alpha1.MachineClass) (api.AzureProviderSpec, error) {
-	var providerSpec api.AzureProviderSpec
-	// Extract providerSpec
-	if err := json.Unmarshal(mcc.ProviderSpec.Raw, &providerSpec); err != nil {
-		return api.AzureProviderSpec{}, status.Error(codes.InvalidArgument, err.Error())
+	providerSpec, fieldErrs, err := DecodeMachineClassProviderSpec(mcc)
+	if err != nil {
+		return api.AzureProviderSpec{}, err
 	}
-
-	// api.AzureVirtualMachineProperties.MachineSet has been marked as deprecated.
-	// If AzureProviderSpec still has MachineSet populated then also copy equivalent values
-	// to the VirtualMachineScaleSet and AvailabilitySet. We do the validation for fields in MachineSet
-	// here separately so that we can use the validated values to populate VirtualMachineScaleSet/AvailabilitySet.
-	// TODO: This complete `if` condition should be removed once consumers no longer use MachineSetConfig.
-	if providerSpec.Properties.MachineSet != nil {
-		if err := validation.ValidateMachineSetConfig(providerSpec.Properties.MachineSet); err != nil {
-			return api.AzureProviderSpec{}, status.Error(codes.InvalidArgument, fmt.Sprintf("error while validation providerSpec.Properties.MachineSet: %v", err))
-		}
-		if providerSpec.Properties.VirtualMachineScaleSet == nil && providerSpec.Properties.MachineSet.Kind == api.MachineSetKindVMO {
-			providerSpec.Properties.VirtualMachineScaleSet = &api.AzureSubResource{ID: providerSpec.Properties.MachineSet.ID}
-		}
-		if providerSpec.Properties.AvailabilitySet == nil && providerSpec.Properties.MachineSet.Kind == api.MachineSetKindAvailabilitySet {
-			providerSpec.Properties.AvailabilitySet = &api.AzureSubResource{ID: providerSpec.Properties.MachineSet.ID}
-		}
+	if len(fieldErrs) > 0 {
+		return api.AzureProviderSpec{}, status.Error(codes.InvalidArgument, fmt.Sprintf("error in validation of AzureProviderSpec: %v", fieldErrs))
 	}
+	return providerSpec, nil
+}
 
-	if err := validation.ValidateProviderSpec(providerSpec); err != nil {
-		return api.AzureProviderSpec{}, status.Error(codes.InvalidArgument, fmt.Sprintf("error in validation of AzureProviderSpec: %v", err))
+// DecodeMachineClassProviderSpec decodes v1alpha1.MachineClass.ProviderSpec.Raw into api.AzureProviderSpec like
+// DecodeAndValidateMachineClassProviderSpec does, but returns the individual validation errors instead of a single
+// aggregated error, e.g. to report them per field. An error is only returned if the provider spec cannot be unmarshalled.
+func DecodeMachineClassProviderSpec(mcc *v1alpha1.MachineClass) (api.AzureProviderSpec, field.ErrorList, error) {
+	providerSpec, fieldErrs, err := validation.DecodeAndValidateRawProviderSpec(mcc.ProviderSpec.Raw)
+	if err != nil {
+		return api.AzureProviderSpec{}, nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-
-	return providerSpec, nil
+	return providerSpec, fieldErrs, nil
 }
@@ -68,3 +68,28 @@ func TestDecodeMachineSetConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeMachineClassProviderSpecFieldErrors(t *testing.T) {
+	g := NewWithT(t)
+
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).
+		WithDefaultHardwareProfile().
+		WithSubnetInfo("vnet-0").
+		WithDefaultStorageProfile().
+		WithDefaultOsProfile().
+		WithDefaultTags().
+		Build()
+	providerSpec.Location = ""
+	providerSpec.Properties.HardwareProfile.VMSize = ""
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+
+	_, fieldErrs, err := DecodeMachineClassProviderSpec(machineClass)
+	g.Expect(err).To(BeNil())
+	g.Expect(fieldErrs).To(HaveLen(2))
+	g.Expect(fieldErrs[0].Field).To(Equal("providerSpec.location"))
+	g.Expect(fieldErrs[1].Field).To(Equal("providerSpec.properties.hardwareProfile.vmSize"))
+
+	_, err = DecodeAndValidateMachineClassProviderSpec(machineClass)
+	g.Expect(err).ToNot(BeNil())
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
+)
+
+const (
+	// VCPUsCapability is the name of the resource SKU capability which specifies the number of vCPUs of a VM size.
+	VCPUsCapability = "vCPUs"
+	// TotalRegionalVCPUsUsageName is the name of the usage which counts the vCPUs of all VM families of a subscription in a location.
+	TotalRegionalVCPUsUsageName = "cores"
+)
+
+// CheckVCPUQuota checks that the remaining quota of the VM family and the remaining total regional vCPU quota of the
+// subscription suffice to create a VM of the configured size. If they do not then an error with code codes.ResourceExhausted
+// is returned, so that no resources are created for a VM which cannot be created. If the quota cannot be determined then
+// the check is skipped and the VM creation is left to Azure. The remaining quotas are recorded as metrics, so that operators
+// see an upcoming exhaustion before machines fail to be created.
+func CheckVCPUQuota(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) error {
+	vmSize := providerSpec.Properties.HardwareProfile.VMSize
+	sku, err := GetVMSizeResourceSKU(ctx, factory, connectConfig, providerSpec.Location, vmSize)
+	if err != nil || sku == nil {
+		klog.FromContext(ctx).Info("Failed to determine the resource SKU, skipping quota check", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+	value, _ := GetResourceSKUCapability(sku, VCPUsCapability)
+	vCPUs, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to determine the vCPUs of the VM size, skipping quota check", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+	usageAccess, err := factory.GetUsageAccess(connectConfig)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to create usage access, skipping quota check", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+	usages, err := accesshelpers.ListUsages(ctx, usageAccess, providerSpec.Location)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to list usages, skipping quota check", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+
+	quotaNames := []string{TotalRegionalVCPUsUsageName}
+	if sku.Family != nil {
+		quotaNames = append(quotaNames, *sku.Family)
+	}
+	var exhaustedQuotas []string
+	for _, usage := range usages {
+		if usage.Name == nil || usage.Name.Value == nil || usage.CurrentValue == nil || usage.Limit == nil || !containsFold(quotaNames, *usage.Name.Value) {
+			continue
+		}
+		remaining := *usage.Limit - int64(*usage.CurrentValue)
+		instrument.RecordRemainingQuota(connectConfig.SubscriptionID, providerSpec.Location, *usage.Name.Value, remaining)
+		if remaining < vCPUs {
+			exhaustedQuotas = append(exhaustedQuotas, fmt.Sprintf("%s: %d of %d vCPUs remaining", *usage.Name.Value, max(remaining, 0), *usage.Limit))
+		}
+	}
+	if len(exhaustedQuotas) > 0 {
+		return status.Error(codes.ResourceExhausted, fmt.Sprintf("insufficient quota to create a VM of size %s with %d vCPUs in location %s: [%s]", vmSize, vCPUs, providerSpec.Location, strings.Join(exhaustedQuotas, ", ")))
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,10 +7,13 @@ package helpers
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
 	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
@@ -20,17 +23,63 @@ import (
 )
 
 const (
+	// listVmsNICsAndDisksQueryTemplate lists VMs, NICs and Disks in a single query. The resource types must not be queried
+	// separately, since the results of separate queries could be inconsistent, e.g. a machine whose VM has been deleted
+	// between the queries could be missed although its NIC or Disks still exist.
 	listVmsNICsAndDisksQueryTemplate = `
 	Resources
 	| where type =~ 'microsoft.compute/virtualmachines' or type =~ 'microsoft.network/networkinterfaces' or type =~ 'microsoft.compute/disks'
-	| where resourceGroup =~ '%s'
+	| where resourceGroup in~ (%s)
 	| extend tagKeys = bag_keys(tags)
 	| where tagKeys has '%s' and tagKeys has '%s'
-	| project type, name
+	| project type, name, tags
+	`
+	listMachineResourcesQueryTemplate = `
+	Resources
+	| where type =~ 'microsoft.network/networkinterfaces' or type =~ 'microsoft.compute/disks' or type =~ 'microsoft.network/publicipaddresses'
+	| where resourceGroup in~ (%s)
+	| extend tagKeys = bag_keys(tags)
+	| where tagKeys has '%s' and tagKeys has '%s'
+	| extend attachedTo = coalesce(tostring(properties.virtualMachine.id), tostring(managedBy), tostring(properties.ipConfiguration.id))
+	| project type, name, resourceGroup, tags, attachedTo
+	`
+	listPublicIPsOfVMQueryTemplate = `
+	Resources
+	| where type =~ 'microsoft.network/publicipaddresses'
+	| where resourceGroup in~ (%s)
+	| where tags['%s'] =~ '%s' or name =~ '%s'
+	| extend attachedTo = tostring(properties.ipConfiguration.id)
+	| project type, name, resourceGroup, tags, attachedTo
+	`
+	getVMStatusQueryTemplate = `
+	Resources
+	| where type =~ 'microsoft.compute/virtualmachines'
+	| where resourceGroup =~ '%s' and name =~ '%s'
+	| extend provisioningState = tostring(properties.provisioningState), powerState = tostring(properties.extended.instanceView.powerState.code)
+	| project type, name, provisioningState, powerState
 	`
 )
 
+// MachineResource is a NIC, Disk or public IP address which carries the tags of the machines of a provider spec.
+type MachineResource struct {
+	// Type is the type of the resource.
+	Type utils.ResourceType
+	// ResourceGroup is the resource group of the resource.
+	ResourceGroup string
+	// Name is the name of the resource.
+	Name string
+	// VMName is the name of the machine the resource belongs to, which is the name of its VM unless that has been shortened,
+	// see utils.CreateVMName. It is empty if it can not be determined or if the resource is intentionally left behind when the VM is deleted.
+	VMName string
+	// Attached indicates if the resource is attached to a VM or NIC.
+	Attached bool
+}
+
 // ExtractVMNamesFromVMsNICsDisks leverages resource graph to extract names from VMs, NICs and Disks (OS and Data disks).
+// Resources which carry the utils.MachineNameTagKey tag yield the machine name, which differs from the VM name if the VM
+// name has been shortened, see utils.CreateVMName. Next to the passed resourceGroup, the additional resource groups
+// configured in the provider spec are searched as well.
+// The result of the query is cached if a TTL has been configured via SetLookupCacheTTLs.
 func ExtractVMNamesFromVMsNICsDisks(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, resourceGroup string, providerSpec api.AzureProviderSpec) ([]string, error) {
 	rgAccess, err := factory.GetResourceGraphAccess(connectConfig)
 	if err != nil {
@@ -38,16 +87,32 @@ func ExtractVMNamesFromVMsNICsDisks(ctx context.Context, factory access.Factory,
 	}
 	vmNames := sets.New[string]()
 
-	queryTemplateArgs := prepareQueryTemplateArgs(resourceGroup, providerSpec.Tags)
-	resultEntries, err := accesshelpers.QueryAndMap[resultEntry](ctx, rgAccess, connectConfig.SubscriptionID, createVMNameMapperFn(), listVmsNICsAndDisksQueryTemplate, queryTemplateArgs...)
-	if err != nil {
-		return nil, status.WrapError(codes.Internal, fmt.Sprintf("failed to get VM names from VMs, NICs and Disks for resourceGroup :%s: error: %v", resourceGroup, err), err)
+	resourceGroups := append([]string{resourceGroup}, providerSpec.AdditionalResourceGroups...)
+	queryTemplateArgs := prepareQueryTemplateArgs(resourceGroups, providerSpec.Tags)
+	// the raw result entries are cached, as the extraction of the VM names depends on the provider spec of the caller.
+	cache := getListMachinesCache()
+	cacheKey := createListMachinesCacheKey(connectConfig.SubscriptionID, fmt.Sprintf(listVmsNICsAndDisksQueryTemplate, queryTemplateArgs...))
+	var (
+		resultEntries []resultEntry
+		cached        bool
+	)
+	if cache != nil {
+		resultEntries, cached = cache.Get(cacheKey)
+	}
+	if !cached {
+		resultEntries, err = accesshelpers.QueryAndMap[resultEntry](ctx, rgAccess, connectConfig.SubscriptionID, createVMNameMapperFn(), listVmsNICsAndDisksQueryTemplate, queryTemplateArgs...)
+		if err != nil {
+			return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to get VM names from VMs, NICs and Disks for resourceGroups :%v: error: %v", resourceGroups, err), err)
+		}
+		if cache != nil {
+			cache.Set(cacheKey, resultEntries)
+		}
 	}
 
 	if resultEntries != nil {
 		dataDiskNameSuffixes := getDataDiskNameSuffixes(providerSpec)
 		for _, re := range resultEntries {
-			vmName := re.extractVMName(dataDiskNameSuffixes)
+			vmName := re.extractVMName(providerSpec.Properties.StorageProfile, dataDiskNameSuffixes)
 			if !utils.IsEmptyString(vmName) {
 				vmNames.Insert(vmName)
 			}
@@ -56,28 +121,166 @@ func ExtractVMNamesFromVMsNICsDisks(ctx context.Context, factory access.Factory,
 	return vmNames.UnsortedList(), nil
 }
 
-func prepareQueryTemplateArgs(resourceGroup string, providerSpecTags map[string]string) []any {
+// ListMachineResources leverages resource graph to list the NICs, Disks and public IP addresses which carry the cluster
+// and role tags of the provider spec. The resources in the additional resource groups of the provider spec are listed as well.
+func ListMachineResources(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) ([]MachineResource, error) {
+	rgAccess, err := factory.GetResourceGraphAccess(connectConfig)
+	if err != nil {
+		return nil, err
+	}
+	resourceGroups := append([]string{providerSpec.ResourceGroup}, providerSpec.AdditionalResourceGroups...)
+	queryTemplateArgs := prepareQueryTemplateArgs(resourceGroups, providerSpec.Tags)
+	resultEntries, err := accesshelpers.QueryAndMap[resultEntry](ctx, rgAccess, connectConfig.SubscriptionID, createVMNameMapperFn(), listMachineResourcesQueryTemplate, queryTemplateArgs...)
+	if err != nil {
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to list NICs, Disks and public IP addresses for resourceGroups :%v: error: %v", resourceGroups, err), err)
+	}
+
+	dataDiskNameSuffixes := getDataDiskNameSuffixes(providerSpec)
+	resources := make([]MachineResource, 0, len(resultEntries))
+	for _, re := range resultEntries {
+		resourceGroup := re.resourceGroup
+		if utils.IsEmptyString(resourceGroup) {
+			resourceGroup = providerSpec.ResourceGroup
+		}
+		resources = append(resources, MachineResource{
+			Type:          re.resourceType,
+			ResourceGroup: resourceGroup,
+			Name:          re.name,
+			VMName:        re.extractVMName(providerSpec.Properties.StorageProfile, dataDiskNameSuffixes),
+			Attached:      !utils.IsEmptyString(re.attachedTo),
+		})
+	}
+	return resources, nil
+}
+
+// ListPublicIPsOfVM leverages resource graph to list the public IP addresses of a VM. A public IP address belongs to the
+// VM if it carries the machine name tag of the machine or if it is named after the VM as done by utils.CreatePublicIPName.
+// The public IP addresses in the additional resource groups of the provider spec are listed as well.
+func ListPublicIPsOfVM(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmName, machineName string) ([]MachineResource, error) {
+	rgAccess, err := factory.GetResourceGraphAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create resource graph access for VM: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, vmName, err), err)
+	}
+	resourceGroups := append([]string{providerSpec.ResourceGroup}, providerSpec.AdditionalResourceGroups...)
+	resultEntries, err := accesshelpers.QueryAndMap[resultEntry](ctx, rgAccess, connectConfig.SubscriptionID, createVMNameMapperFn(), listPublicIPsOfVMQueryTemplate,
+		createResourceGroupsQueryList(resourceGroups), utils.MachineNameTagKey, machineName, utils.CreatePublicIPName(vmName))
+	if err != nil {
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to list public IP addresses of VM: %s for resourceGroups :%v: error: %v", vmName, resourceGroups, err), err)
+	}
+
+	publicIPs := make([]MachineResource, 0, len(resultEntries))
+	for _, re := range resultEntries {
+		// the entries are associated to the VM using the same rules which are used by the orphan collection.
+		if re.resourceType != utils.PublicIPAddressResourceType || !strings.EqualFold(re.extractVMName(providerSpec.Properties.StorageProfile, nil), vmName) {
+			continue
+		}
+		resourceGroup := re.resourceGroup
+		if utils.IsEmptyString(resourceGroup) {
+			resourceGroup = providerSpec.ResourceGroup
+		}
+		publicIPs = append(publicIPs, MachineResource{
+			Type:          re.resourceType,
+			ResourceGroup: resourceGroup,
+			Name:          re.name,
+			VMName:        vmName,
+			Attached:      !utils.IsEmptyString(re.attachedTo),
+		})
+	}
+	return publicIPs, nil
+}
+
+// GetVirtualMachineStatusFromResourceGraph leverages resource graph to get the provisioning and power state of a VM. The returned VM
+// only has its name, provisioning state and an instance view with the provisioning and power state set, which suffices for
+// CheckVirtualMachineState. Resource graph lags behind the actual state, therefore nil is returned if the VM is not found or if
+// its power state is not reported yet, in which case the VM should be retrieved directly instead.
+func GetVirtualMachineStatusFromResourceGraph(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, resourceGroup, vmName string) (*armcompute.VirtualMachine, error) {
+	rgAccess, err := factory.GetResourceGraphAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create resource graph access for VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	vms, err := accesshelpers.QueryAndMap[armcompute.VirtualMachine](ctx, rgAccess, connectConfig.SubscriptionID, createVMStatusMapperFn(), getVMStatusQueryTemplate, resourceGroup, vmName)
+	if err != nil {
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to get status of VM: [ResourceGroup: %s, Name: %s] from resource graph, Err: %v", resourceGroup, vmName, err), err)
+	}
+	for _, vm := range vms {
+		if strings.EqualFold(*vm.Name, vmName) && utils.GetPowerState(vm.Properties.InstanceView) != "" {
+			return &vm, nil
+		}
+	}
+	return nil, nil
+}
+
+func createVMStatusMapperFn() accesshelpers.MapperFn[armcompute.VirtualMachine] {
+	return func(m map[string]interface{}) *armcompute.VirtualMachine {
+		name, nameKeyFound := m["name"].(string)
+		if !nameKeyFound {
+			return nil
+		}
+		provisioningState, _ := m["provisioningState"].(string)
+		powerStateCode, _ := m["powerState"].(string)
+		// resource graph reports the provisioning state as property and the power state with its status code, the instance view
+		// is constructed as it is returned by the compute API.
+		var statuses []*armcompute.InstanceViewStatus
+		if !utils.IsEmptyString(provisioningState) {
+			statuses = append(statuses, &armcompute.InstanceViewStatus{Code: to.Ptr("ProvisioningState/" + provisioningState)})
+		}
+		if !utils.IsEmptyString(powerStateCode) {
+			statuses = append(statuses, &armcompute.InstanceViewStatus{Code: to.Ptr(powerStateCode)})
+		}
+		return &armcompute.VirtualMachine{
+			Name: to.Ptr(name),
+			Properties: &armcompute.VirtualMachineProperties{
+				ProvisioningState: to.Ptr(provisioningState),
+				InstanceView:      &armcompute.VirtualMachineInstanceView{Statuses: statuses},
+			},
+		}
+	}
+}
+
+func prepareQueryTemplateArgs(resourceGroups []string, providerSpecTags map[string]string) []any {
 	// NOTE: length is 3 because in the query we have a max of 3 parameter substitutions. This should be changed if the number of parameters change to prevent unnecessary resizing.
 	templateArgs := make([]any, 0, 3)
 	// NOTE: preserve the same order as these are ordered parameters which will be used for substitution.
-	templateArgs = append(templateArgs, resourceGroup)
+	templateArgs = append(templateArgs, createResourceGroupsQueryList(resourceGroups))
+	// the tag keys are sorted to create the same query for the same tags, which allows to cache its result.
+	tagKeys := make([]string, 0, 2)
 	for k := range providerSpecTags {
-		if strings.HasPrefix(k, utils.ClusterTagPrefix) || strings.HasPrefix(k, utils.RoleTagPrefix) {
-			templateArgs = append(templateArgs, k)
+		// the resources carry the sanitized tag keys, see utils.CreateResourceTags.
+		if k = utils.SanitizeTagKey(k); strings.HasPrefix(k, utils.ClusterTagPrefix) || strings.HasPrefix(k, utils.RoleTagPrefix) {
+			tagKeys = append(tagKeys, k)
 		}
 	}
+	slices.Sort(tagKeys)
+	for _, k := range tagKeys {
+		templateArgs = append(templateArgs, k)
+	}
 	return templateArgs
 }
 
+// createResourceGroupsQueryList creates a comma separated list of quoted resource group names which can be used with the in~ operator.
+func createResourceGroupsQueryList(resourceGroups []string) string {
+	quoted := make([]string, 0, len(resourceGroups))
+	for _, rg := range resourceGroups {
+		quoted = append(quoted, fmt.Sprintf("'%s'", rg))
+	}
+	return strings.Join(quoted, ", ")
+}
+
 func createVMNameMapperFn() accesshelpers.MapperFn[resultEntry] {
 	return func(m map[string]interface{}) *resultEntry {
 		resourceName, nameKeyFound := m["name"].(string)
 		resourceType, typeKeyFound := m["type"].(string)
 		if nameKeyFound && typeKeyFound {
-			return to.Ptr(resultEntry{
+			entry := resultEntry{
 				resourceType: utils.ResourceType(resourceType),
 				name:         resourceName,
-			})
+			}
+			entry.resourceGroup, _ = m["resourceGroup"].(string)
+			entry.attachedTo, _ = m["attachedTo"].(string)
+			if tags, ok := m["tags"].(map[string]interface{}); ok {
+				entry.machineName, _ = tags[utils.MachineNameTagKey].(string)
+			}
+			return &entry
 		}
 		return nil
 	}
@@ -86,15 +289,35 @@ func createVMNameMapperFn() accesshelpers.MapperFn[resultEntry] {
 type resultEntry struct {
 	resourceType utils.ResourceType
 	name         string
+	// machineName is the value of the utils.MachineNameTagKey tag. It is empty for resources which do not have this tag.
+	machineName string
+	// resourceGroup is the resource group of the resource. It is only set if it has been projected by the query.
+	resourceGroup string
+	// attachedTo is the ID of the resource this resource is attached to. It is only set if it has been projected by the query.
+	attachedTo string
 }
 
-func (r resultEntry) extractVMName(dataDiskNameSuffixes sets.Set[string]) string {
+func (r resultEntry) extractVMName(storageProfile api.AzureStorageProfile, dataDiskNameSuffixes sets.Set[string]) string {
+	// Data disks which are detached on VM deletion are intentionally left behind and must not be considered as orphans.
+	if r.resourceType == utils.DiskResourceType && r.isDetachedDataDisk(storageProfile) {
+		return ""
+	}
+	// Resources which have the machine name tag are associated via the tag, since their names do not necessarily
+	// follow the naming conventions (e.g. the OSDisk name can be configured using a name template).
+	if !utils.IsEmptyString(r.machineName) {
+		return r.machineName
+	}
 	switch r.resourceType {
 	case utils.VirtualMachinesResourceType:
 		return r.name
 	case utils.NetworkInterfacesResourceType:
 		return utils.ExtractVMNameFromNICName(r.name)
+	case utils.PublicIPAddressResourceType:
+		return utils.ExtractVMNameFromPublicIPName(r.name)
 	case utils.DiskResourceType:
+		if storageProfile.DataDiskNameTemplate != nil || storageProfile.OsDisk.NameTemplate != nil {
+			return r.extractVMNameFromDiskNameUsingTemplates(storageProfile)
+		}
 		if strings.HasSuffix(r.name, utils.OSDiskSuffix) {
 			return utils.ExtractVMNameFromOSDiskName(r.name)
 		} else if strings.HasSuffix(r.name, utils.DataDiskSuffix) {
@@ -106,6 +329,48 @@ func (r resultEntry) extractVMName(dataDiskNameSuffixes sets.Set[string]) string
 	return ""
 }
 
+// extractVMNameFromDiskNameUsingTemplates extracts the VM name from a disk name using the configured name templates. Data disk
+// name templates are more specific than the OSDisk name template, therefore they are matched first.
+func (r resultEntry) extractVMNameFromDiskNameUsingTemplates(storageProfile api.AzureStorageProfile) string {
+	for _, dataDisk := range storageProfile.DataDisks {
+		if IsDetachedOnDelete(dataDisk) {
+			continue
+		}
+		if storageProfile.DataDiskNameTemplate != nil {
+			if vmName, ok := utils.ExtractVMNameFromDataDiskName(r.name, dataDisk.Name, dataDisk.Lun, *storageProfile.DataDiskNameTemplate); ok {
+				return vmName
+			}
+		} else if suffix := utils.GetDataDiskNameSuffix(dataDisk.Name, dataDisk.Lun); strings.HasSuffix(r.name, suffix) {
+			return r.name[:len(r.name)-len(suffix)]
+		}
+	}
+	if storageProfile.OsDisk.NameTemplate != nil {
+		if vmName, ok := utils.ExtractVMNameUsingNameTemplate(r.name, *storageProfile.OsDisk.NameTemplate); ok {
+			return vmName
+		}
+	} else if strings.HasSuffix(r.name, utils.OSDiskSuffix) {
+		return utils.ExtractVMNameFromOSDiskName(r.name)
+	}
+	return ""
+}
+
+// isDetachedDataDisk checks if the disk is a data disk which is configured to be detached when the VM is deleted.
+func (r resultEntry) isDetachedDataDisk(storageProfile api.AzureStorageProfile) bool {
+	for _, dataDisk := range storageProfile.DataDisks {
+		if !IsDetachedOnDelete(dataDisk) {
+			continue
+		}
+		if storageProfile.DataDiskNameTemplate != nil {
+			if _, ok := utils.ExtractVMNameFromDataDiskName(r.name, dataDisk.Name, dataDisk.Lun, *storageProfile.DataDiskNameTemplate); ok {
+				return true
+			}
+		} else if strings.HasSuffix(r.name, utils.GetDataDiskNameSuffix(dataDisk.Name, dataDisk.Lun)) {
+			return true
+		}
+	}
+	return false
+}
+
 func findMatchingDataDiskNameSuffix(dataDiskName string, dataDiskNameSuffixes sets.Set[string]) (string, bool) {
 	for _, suffix := range dataDiskNameSuffixes.UnsortedList() {
 		if strings.HasSuffix(dataDiskName, suffix) {
@@ -119,6 +384,9 @@ func getDataDiskNameSuffixes(providerSpec api.AzureProviderSpec) sets.Set[string
 	dataDiskNameSuffixes := sets.New[string]()
 	dataDisks := providerSpec.Properties.StorageProfile.DataDisks
 	for _, dataDisk := range dataDisks {
+		if IsDetachedOnDelete(dataDisk) {
+			continue
+		}
 		dataDiskNameSuffixes.Insert(utils.GetDataDiskNameSuffix(dataDisk.Name, dataDisk.Lun))
 	}
 	return dataDiskNameSuffixes
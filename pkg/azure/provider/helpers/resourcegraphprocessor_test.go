@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPrepareQueryTemplateArgs(t *testing.T) {
+	const (
+		clusterTag = "kubernetes.io-cluster-shoot--test"
+		roleTag    = "kubernetes.io-role-node"
+	)
+	tags := map[string]string{clusterTag: "1", roleTag: "1", "other": "value"}
+	table := []struct {
+		description    string
+		resourceGroups []string
+		expectedRGList string
+	}{
+		{"should only contain the resource group", []string{"rg-0"}, "'rg-0'"},
+		{"should contain all resource groups", []string{"rg-0", "rg-disks", "rg-nics"}, "'rg-0', 'rg-disks', 'rg-nics'"},
+	}
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			args := prepareQueryTemplateArgs(entry.resourceGroups, tags)
+			g.Expect(args).To(HaveLen(3))
+			g.Expect(args[0]).To(Equal(entry.expectedRGList))
+			g.Expect(args[1:]).To(Equal([]any{clusterTag, roleTag}))
+			g.Expect(fmt.Sprintf(listVmsNICsAndDisksQueryTemplate, args...)).To(ContainSubstring(fmt.Sprintf("resourceGroup in~ (%s)", entry.expectedRGList)))
+		})
+	}
+}
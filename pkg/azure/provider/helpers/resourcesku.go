@@ -0,0 +1,447 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+const (
+	// resourceSKUCacheTTL is the duration for which a resource SKU is cached. Capabilities of a VM size
+	// very rarely change, so a long TTL avoids listing all SKUs of a location for every machine creation.
+	resourceSKUCacheTTL = 6 * time.Hour
+	// resourceSKUMissCacheTTL is the duration for which it is remembered that no resource SKU has been found for a VM size,
+	// so that repeated lookups of VM sizes which are not offered do not list all SKUs of the location again.
+	resourceSKUMissCacheTTL = time.Minute
+	// AcceleratedNetworkingCapability is the name of the resource SKU capability which indicates if a VM size supports accelerated networking.
+	AcceleratedNetworkingCapability = "AcceleratedNetworkingEnabled"
+	// HyperVGenerationsCapability is the name of the resource SKU capability which lists the Hyper-V generations supported by a VM size, e.g. "V1,V2".
+	HyperVGenerationsCapability = "HyperVGenerations"
+	// MaxWriteAcceleratorDisksAllowedCapability is the name of the resource SKU capability which specifies the maximum number of disks
+	// with write accelerator that can be attached to a VM size. VM sizes which do not support write accelerator do not have this capability.
+	MaxWriteAcceleratorDisksAllowedCapability = "MaxWriteAcceleratorDisksAllowed"
+	// DiskControllerTypesCapability is the name of the resource SKU capability which lists the disk controller types supported by a VM size, e.g. "SCSI, NVMe".
+	// VM sizes which do not have this capability only support SCSI.
+	DiskControllerTypesCapability = "DiskControllerTypes"
+	// HibernationSupportedCapability is the name of the resource SKU capability which indicates if a VM size supports hibernation.
+	HibernationSupportedCapability = "HibernationSupported"
+	// MaxDataDiskCountCapability is the name of the resource SKU capability which specifies the maximum number of data disks
+	// that can be attached to a VM size. The LUNs of the data disks have to be lower than this number.
+	MaxDataDiskCountCapability = "MaxDataDiskCount"
+)
+
+// ErrVMSizeNotOffered is returned by GetVMSizeResourceSKU if resource SKUs are offered in a location but none for the VM size.
+var ErrVMSizeNotOffered = errors.New("VM size is not offered in location")
+
+var (
+	// vmSizeResourceSKUCache caches virtual machine resource SKUs. Key is created using createResourceSKUCacheKey.
+	vmSizeResourceSKUCache = utils.NewTTLCache[string, *armcompute.ResourceSKU](resourceSKUCacheTTL)
+	// resourceSKUMissCache records the VM sizes for which no resource SKU has recently been found and if any SKUs were
+	// listed for the location at all. Key is created using createResourceSKUCacheKey.
+	resourceSKUMissCache = utils.NewTTLCache[string, bool](resourceSKUMissCacheTTL)
+	// resourceSKUListLocks serializes the listing of resource SKUs per location, key is created using createResourceSKULocationKey.
+	resourceSKUListLocks sync.Map
+)
+
+// GetVMSizeResourceSKU gets the resource SKU for the VM size in a location. Listing SKUs returns all VM sizes of a location,
+// therefore all of them are cached in one go. If there is no SKU for the VM size but for other VM sizes then ErrVMSizeNotOffered
+// is returned. If no SKUs are listed at all, e.g. since the location is unknown, then nil is returned.
+// Concurrent lookups of a location share a single listing and misses are cached for a short time, so that lookups of
+// VM sizes which are not offered do not list all SKUs of the location every time.
+func GetVMSizeResourceSKU(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, location, vmSize string) (*armcompute.ResourceSKU, error) {
+	cacheKey := createResourceSKUCacheKey(connectConfig.SubscriptionID, location, vmSize)
+	locationKey := createResourceSKULocationKey(connectConfig.SubscriptionID, location)
+	if sku, ok, err := getCachedVMSizeResourceSKU(cacheKey, location, vmSize); ok {
+		return sku, err
+	}
+	lock, _ := resourceSKUListLocks.LoadOrStore(locationKey, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	// the SKUs might have been listed by a concurrent lookup while waiting for the lock.
+	if sku, ok, err := getCachedVMSizeResourceSKU(cacheKey, location, vmSize); ok {
+		return sku, err
+	}
+	skuAccess, err := factory.GetResourceSKUsAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("failed to create resource SKUs access, Err: %v", err), err)
+	}
+	skus, err := accesshelpers.ListVirtualMachineResourceSKUs(ctx, skuAccess, location)
+	if err != nil {
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to list resource SKUs for [Location: %s], Err: %v", location, err), err)
+	}
+	var vmSizeSKU *armcompute.ResourceSKU
+	for _, sku := range skus {
+		if sku.Name == nil {
+			continue
+		}
+		vmSizeResourceSKUCache.Set(createResourceSKUCacheKey(connectConfig.SubscriptionID, location, *sku.Name), sku)
+		if strings.EqualFold(*sku.Name, vmSize) {
+			vmSizeSKU = sku
+		}
+	}
+	if vmSizeSKU == nil {
+		resourceSKUMissCache.Set(cacheKey, len(skus) > 0)
+	}
+	if vmSizeSKU == nil && len(skus) > 0 {
+		return nil, newVMSizeNotOfferedError(location, vmSize)
+	}
+	return vmSizeSKU, nil
+}
+
+// getCachedVMSizeResourceSKU returns the cached resource SKU for the VM size or the cached miss if no resource SKU has
+// recently been found for the VM size, see GetVMSizeResourceSKU. The second return value is false if nothing is cached.
+func getCachedVMSizeResourceSKU(cacheKey, location, vmSize string) (*armcompute.ResourceSKU, bool, error) {
+	if sku, ok := vmSizeResourceSKUCache.Get(cacheKey); ok {
+		return sku, true, nil
+	}
+	hasSKUs, ok := resourceSKUMissCache.Get(cacheKey)
+	if !ok {
+		return nil, false, nil
+	}
+	if hasSKUs {
+		return nil, true, newVMSizeNotOfferedError(location, vmSize)
+	}
+	return nil, true, nil
+}
+
+func newVMSizeNotOfferedError(location, vmSize string) error {
+	return fmt.Errorf("%w [Location: %s, VMSize: %s]", ErrVMSizeNotOffered, location, vmSize)
+}
+
+// ValidateVMSizeAvailability validates that the VM size is offered in the location and in all configured zones of the VM,
+// i.e. the zone and all zones which can be selected for the VM, and that it is not restricted for the subscription. If it
+// is not available then an error with code codes.InvalidArgument is returned. If the resource SKU for the VM size cannot
+// be determined then validation is skipped and the VM creation is left to Azure.
+func ValidateVMSizeAvailability(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) error {
+	vmSize := providerSpec.Properties.HardwareProfile.VMSize
+	location := providerSpec.Location
+	sku, err := GetVMSizeResourceSKU(ctx, factory, connectConfig, location, vmSize)
+	if errors.Is(err, ErrVMSizeNotOffered) {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("VM size %s is not offered in location %s", vmSize, location))
+	}
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to determine the resource SKU, skipping availability validation", errKeysAndValues(ctx, err, "location", location, "vmSize", vmSize)...)
+		return nil
+	}
+	if sku == nil {
+		klog.FromContext(ctx).Info("SKU not found in region, skipping availability validation", "location", location, "vmSize", vmSize)
+		return nil
+	}
+	zones := getConfiguredZones(providerSpec.Properties)
+	for _, restriction := range sku.Restrictions {
+		if restriction == nil || restriction.Type == nil || restriction.RestrictionInfo == nil {
+			continue
+		}
+		reason := getRestrictionReason(restriction)
+		switch *restriction.Type {
+		case armcompute.ResourceSKURestrictionsTypeLocation:
+			if containsFoldPtr(restriction.RestrictionInfo.Locations, location) {
+				return status.Error(codes.InvalidArgument, fmt.Sprintf("VM size %s is restricted for the subscription in location %s, reason: %s", vmSize, location, reason))
+			}
+		case armcompute.ResourceSKURestrictionsTypeZone:
+			if !containsFoldPtr(restriction.RestrictionInfo.Locations, location) {
+				continue
+			}
+			for _, zone := range zones {
+				if containsFoldPtr(restriction.RestrictionInfo.Zones, zone) {
+					return status.Error(codes.InvalidArgument, fmt.Sprintf("VM size %s is restricted for the subscription in zone %s of location %s, reason: %s", vmSize, zone, location, reason))
+				}
+			}
+		}
+	}
+	if len(zones) == 0 {
+		return nil
+	}
+	for _, locationInfo := range sku.LocationInfo {
+		if locationInfo == nil || locationInfo.Location == nil || !strings.EqualFold(*locationInfo.Location, location) {
+			continue
+		}
+		for _, zone := range zones {
+			if !containsFoldPtr(locationInfo.Zones, zone) {
+				return status.Error(codes.InvalidArgument, fmt.Sprintf("VM size %s is not offered in zone %s of location %s, offered zones: %v", vmSize, zone, location, derefStrings(locationInfo.Zones)))
+			}
+		}
+	}
+	return nil
+}
+
+// getConfiguredZones returns the zone and all zones which can be selected for the VM, see SelectZone, without duplicates.
+func getConfiguredZones(properties api.AzureVirtualMachineProperties) []string {
+	var zones []string
+	if properties.Zone != nil {
+		zones = append(zones, strconv.Itoa(*properties.Zone))
+	}
+	for _, zone := range properties.Zones {
+		if z := strconv.Itoa(zone); !slices.Contains(zones, z) {
+			zones = append(zones, z)
+		}
+	}
+	return zones
+}
+
+func getRestrictionReason(restriction *armcompute.ResourceSKURestrictions) string {
+	if restriction.ReasonCode == nil {
+		return "unknown"
+	}
+	return string(*restriction.ReasonCode)
+}
+
+func derefStrings(values []*string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			result = append(result, *v)
+		}
+	}
+	return result
+}
+
+func containsFoldPtr(values []*string, value string) bool {
+	for _, v := range values {
+		if v != nil && strings.EqualFold(*v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetResourceSKUCapability returns the value of the capability with the given name. The second return value is false if the SKU does not have the capability.
+func GetResourceSKUCapability(sku *armcompute.ResourceSKU, name string) (string, bool) {
+	if sku == nil {
+		return "", false
+	}
+	for _, capability := range sku.Capabilities {
+		if capability != nil && capability.Name != nil && capability.Value != nil && strings.EqualFold(*capability.Name, name) {
+			return *capability.Value, true
+		}
+	}
+	return "", false
+}
+
+// ResolveAcceleratedNetworking determines if accelerated networking should be enabled for the NIC of a machine.
+//   - If AcceleratedNetworking is not set in the provider spec then it will be enabled if the VM size supports it.
+//   - If AcceleratedNetworking is explicitly enabled but the VM size does not support it then an error with code codes.InvalidArgument is returned.
+//     The same applies if it is not set but an AuxiliaryMode is enabled, which requires accelerated networking.
+//
+// If the resource SKU for the VM size cannot be determined then the value set in the provider spec is returned as is.
+func ResolveAcceleratedNetworking(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) (*bool, error) {
+	specAcceleratedNetworking := providerSpec.Properties.NetworkProfile.AcceleratedNetworking
+	if specAcceleratedNetworking != nil && !*specAcceleratedNetworking {
+		return specAcceleratedNetworking, nil
+	}
+	vmSize := providerSpec.Properties.HardwareProfile.VMSize
+	sku, err := GetVMSizeResourceSKU(ctx, factory, connectConfig, providerSpec.Location, vmSize)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to determine if accelerated networking is supported, will use the configured value", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return specAcceleratedNetworking, nil
+	}
+	if sku == nil {
+		klog.FromContext(ctx).Info("No resource SKU found, will use the configured value for accelerated networking", "location", providerSpec.Location, "vmSize", vmSize)
+		return specAcceleratedNetworking, nil
+	}
+	value, _ := GetResourceSKUCapability(sku, AcceleratedNetworkingCapability)
+	supported := strings.EqualFold(value, "True")
+	if auxiliaryMode := providerSpec.Properties.NetworkProfile.AuxiliaryMode; !supported && auxiliaryMode != nil && *auxiliaryMode != string(armnetwork.NetworkInterfaceAuxiliaryModeNone) {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("auxiliaryMode %s requires accelerated networking but VM size %s does not support it in location %s", *auxiliaryMode, vmSize, providerSpec.Location))
+	}
+	if specAcceleratedNetworking == nil {
+		klog.FromContext(ctx).V(4).Info("Accelerated networking is not configured, setting it to the supported value", "location", providerSpec.Location, "vmSize", vmSize, "acceleratedNetworking", supported)
+		return to.Ptr(supported), nil
+	}
+	if !supported {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("accelerated networking is enabled in the provider spec but VM size %s does not support it in location %s", vmSize, providerSpec.Location))
+	}
+	return specAcceleratedNetworking, nil
+}
+
+// ValidateHyperVGeneration validates that the VM size supports the Hyper-V generation which is configured for the image.
+// If the generation is not supported then an error with code codes.InvalidArgument is returned. If the resource SKU
+// for the VM size cannot be determined then validation is skipped and the VM creation is left to Azure.
+func ValidateHyperVGeneration(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) error {
+	hyperVGeneration := providerSpec.Properties.StorageProfile.ImageReference.HyperVGeneration
+	if hyperVGeneration == nil {
+		return nil
+	}
+	vmSize := providerSpec.Properties.HardwareProfile.VMSize
+	sku, err := GetVMSizeResourceSKU(ctx, factory, connectConfig, providerSpec.Location, vmSize)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to determine the supported hyperV generations, skipping validation", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+	value, ok := GetResourceSKUCapability(sku, HyperVGenerationsCapability)
+	if !ok {
+		klog.FromContext(ctx).Info("No supported hyperV generations found, skipping validation", "location", providerSpec.Location, "vmSize", vmSize)
+		return nil
+	}
+	for _, generation := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(generation), *hyperVGeneration) {
+			return nil
+		}
+	}
+	return status.Error(codes.InvalidArgument, fmt.Sprintf("hyperVGeneration %s is configured for the image but VM size %s only supports [%s] in location %s", *hyperVGeneration, vmSize, value, providerSpec.Location))
+}
+
+// ValidateWriteAccelerator validates that the VM size supports write accelerator for all disks which have it enabled.
+// If it is not supported or if more disks have it enabled than the VM size allows then an error with code codes.InvalidArgument is returned.
+// If the resource SKU for the VM size cannot be determined then validation is skipped and the VM creation is left to Azure.
+func ValidateWriteAccelerator(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) error {
+	numDisks := countWriteAcceleratorEnabledDisks(providerSpec.Properties.StorageProfile)
+	if numDisks == 0 {
+		return nil
+	}
+	vmSize := providerSpec.Properties.HardwareProfile.VMSize
+	sku, err := GetVMSizeResourceSKU(ctx, factory, connectConfig, providerSpec.Location, vmSize)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to determine if write accelerator is supported, skipping validation", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+	if sku == nil {
+		klog.FromContext(ctx).Info("No resource SKU found, skipping validation of write accelerator", "location", providerSpec.Location, "vmSize", vmSize)
+		return nil
+	}
+	value, ok := GetResourceSKUCapability(sku, MaxWriteAcceleratorDisksAllowedCapability)
+	if !ok {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("write accelerator is enabled for %d disk(s) but VM size %s does not support write accelerator in location %s", numDisks, vmSize, providerSpec.Location))
+	}
+	maxDisks, err := strconv.Atoi(value)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to parse capability, skipping validation of write accelerator", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize, "capability", MaxWriteAcceleratorDisksAllowedCapability, "value", value)...)
+		return nil
+	}
+	if numDisks > maxDisks {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("write accelerator is enabled for %d disk(s) but VM size %s only allows %d in location %s", numDisks, vmSize, maxDisks, providerSpec.Location))
+	}
+	return nil
+}
+
+// ValidateDiskControllerType validates that the VM size supports the disk controller type which is configured in the storage profile.
+// If the disk controller type is not supported then an error with code codes.InvalidArgument is returned. If the resource SKU
+// for the VM size cannot be determined then validation is skipped and the VM creation is left to Azure.
+func ValidateDiskControllerType(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) error {
+	diskControllerType := providerSpec.Properties.StorageProfile.DiskControllerType
+	if diskControllerType == nil {
+		return nil
+	}
+	vmSize := providerSpec.Properties.HardwareProfile.VMSize
+	sku, err := GetVMSizeResourceSKU(ctx, factory, connectConfig, providerSpec.Location, vmSize)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to determine the supported disk controller types, skipping validation", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+	if sku == nil {
+		klog.FromContext(ctx).Info("No resource SKU found, skipping validation of disk controller type", "location", providerSpec.Location, "vmSize", vmSize)
+		return nil
+	}
+	value, ok := GetResourceSKUCapability(sku, DiskControllerTypesCapability)
+	if !ok {
+		value = string(armcompute.DiskControllerTypesSCSI)
+	}
+	for _, controllerType := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(controllerType), *diskControllerType) {
+			return nil
+		}
+	}
+	return status.Error(codes.InvalidArgument, fmt.Sprintf("diskControllerType %s is configured but VM size %s only supports [%s] in location %s", *diskControllerType, vmSize, value, providerSpec.Location))
+}
+
+// ValidateHibernation validates that the VM size supports hibernation if it is enabled in the additional capabilities.
+// If hibernation is not supported then an error with code codes.InvalidArgument is returned. If the resource SKU
+// for the VM size cannot be determined then validation is skipped and the VM creation is left to Azure.
+func ValidateHibernation(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) error {
+	additionalCapabilities := providerSpec.Properties.AdditionalCapabilities
+	if additionalCapabilities == nil || additionalCapabilities.HibernationEnabled == nil || !*additionalCapabilities.HibernationEnabled {
+		return nil
+	}
+	vmSize := providerSpec.Properties.HardwareProfile.VMSize
+	sku, err := GetVMSizeResourceSKU(ctx, factory, connectConfig, providerSpec.Location, vmSize)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to determine if hibernation is supported, skipping validation", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+	if sku == nil {
+		klog.FromContext(ctx).Info("No resource SKU found, skipping validation of hibernation", "location", providerSpec.Location, "vmSize", vmSize)
+		return nil
+	}
+	if value, _ := GetResourceSKUCapability(sku, HibernationSupportedCapability); !strings.EqualFold(value, "True") {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("hibernation is enabled in the provider spec but VM size %s does not support it in location %s", vmSize, providerSpec.Location))
+	}
+	return nil
+}
+
+// ValidateDataDiskCount validates that the VM size supports the number of data disks which are configured in the storage profile
+// and that their LUNs are within the range the VM size allows. If not then an error with code codes.InvalidArgument is returned.
+// If the resource SKU for the VM size cannot be determined then validation is skipped and the VM creation is left to Azure.
+func ValidateDataDiskCount(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec) error {
+	dataDisks := providerSpec.Properties.StorageProfile.DataDisks
+	if len(dataDisks) == 0 {
+		return nil
+	}
+	vmSize := providerSpec.Properties.HardwareProfile.VMSize
+	sku, err := GetVMSizeResourceSKU(ctx, factory, connectConfig, providerSpec.Location, vmSize)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to determine the maximum number of data disks, skipping validation", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize)...)
+		return nil
+	}
+	value, ok := GetResourceSKUCapability(sku, MaxDataDiskCountCapability)
+	if !ok {
+		klog.FromContext(ctx).Info("No maximum number of data disks found, skipping validation", "location", providerSpec.Location, "vmSize", vmSize)
+		return nil
+	}
+	maxDataDisks, err := strconv.Atoi(value)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to parse capability, skipping validation of data disk count", errKeysAndValues(ctx, err, "location", providerSpec.Location, "vmSize", vmSize, "capability", MaxDataDiskCountCapability, "value", value)...)
+		return nil
+	}
+	if len(dataDisks) > maxDataDisks {
+		return status.Error(codes.InvalidArgument, fmt.Sprintf("%d data disk(s) are configured but VM size %s only allows %d in location %s", len(dataDisks), vmSize, maxDataDisks, providerSpec.Location))
+	}
+	for _, dataDisk := range dataDisks {
+		if int(dataDisk.Lun) >= maxDataDisks {
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("lun %d of data disk %s is out of range, VM size %s only allows luns from 0 to %d in location %s", dataDisk.Lun, dataDisk.Name, vmSize, maxDataDisks-1, providerSpec.Location))
+		}
+	}
+	return nil
+}
+
+func countWriteAcceleratorEnabledDisks(storageProfile api.AzureStorageProfile) int {
+	var count int
+	if enabled := storageProfile.OsDisk.WriteAcceleratorEnabled; enabled != nil && *enabled {
+		count++
+	}
+	for _, dataDisk := range storageProfile.DataDisks {
+		if enabled := dataDisk.WriteAcceleratorEnabled; enabled != nil && *enabled {
+			count++
+		}
+	}
+	return count
+}
+
+func createResourceSKUCacheKey(subscriptionID, location, vmSize string) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s", subscriptionID, location, vmSize))
+}
+
+func createResourceSKULocationKey(subscriptionID, location string) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s", subscriptionID, location))
+}
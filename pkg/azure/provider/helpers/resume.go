@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+)
+
+// GetPreviousLastKnownState returns the LastKnownState which has been recorded for the machine by a previous driver call.
+// Nil is returned if no usable state has been recorded, in which case all resources are created from scratch.
+func GetPreviousLastKnownState(ctx context.Context, machine *v1alpha1.Machine) *LastKnownState {
+	if machine == nil {
+		return nil
+	}
+	state, err := DecodeLastKnownState(machine.Status.LastKnownState)
+	if err != nil {
+		klog.FromContext(ctx).Info("Ignoring LastKnownState of machine", "machine", machine.Name, "err", err)
+		return nil
+	}
+	return state
+}
+
+// GetPendingOperation returns the pending operation of the passed type for the passed resource or nil if there is none.
+func (s *LastKnownState) GetPendingOperation(opType PendingOperationType, resourceName string) *PendingOperation {
+	if s == nil {
+		return nil
+	}
+	for i := range s.PendingOperations {
+		if s.PendingOperations[i].Type == opType && s.PendingOperations[i].ResourceName == resourceName {
+			return &s.PendingOperations[i]
+		}
+	}
+	return nil
+}
+
+// GetCreatedDataDiskIDs returns the IDs of the data disks which have been created, keyed by their LUN.
+func (s *LastKnownState) GetCreatedDataDiskIDs() map[DataDiskLun]string {
+	if s == nil {
+		return nil
+	}
+	return s.CreatedResources.DataDiskIDs
+}
+
+// GetCreatedOSDiskID returns the ID of the restored OSDisk if it has been created.
+func (s *LastKnownState) GetCreatedOSDiskID() string {
+	if s == nil {
+		return ""
+	}
+	return s.CreatedResources.OSDiskID
+}
+
+// GetCreationFailure returns the failure of the most recent attempt to create the VM.
+func (s *LastKnownState) GetCreationFailure() *CreationFailure {
+	if s == nil {
+		return nil
+	}
+	return s.CreationFailure
+}
+
+// GetZone returns the zone in which the resources of the VM have been created by a previous attempt.
+func (s *LastKnownState) GetZone() *int {
+	if s == nil {
+		return nil
+	}
+	return s.Zone
+}
+
+// GetImageID returns the ID of the image, including the resolved version, which has been used by a previous attempt.
+func (s *LastKnownState) GetImageID() string {
+	if s == nil {
+		return ""
+	}
+	return s.ImageID
+}
+
+// ResumeNICCreation returns the ID of the NIC if it has been created by a previous CreateMachine call. If the creation of
+// the NIC was still in progress then polling for it is resumed. An empty ID is returned if the NIC has to be created.
+func ResumeNICCreation(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, resourceGroup string, nicName string, previousState *LastKnownState) (string, error) {
+	if previousState == nil {
+		return "", nil
+	}
+	if previousState.CreatedResources.NICID != "" {
+		klog.FromContext(ctx).Info("NIC has been created by a previous attempt, will skip creation of the NIC", "nic", nicName)
+		return previousState.CreatedResources.NICID, nil
+	}
+	pendingOp := previousState.GetPendingOperation(PendingOperationTypeCreateNIC, nicName)
+	if pendingOp == nil {
+		return "", nil
+	}
+	nicAccess, err := factory.GetNetworkInterfacesAccess(connectConfig)
+	if err != nil {
+		return "", status.WrapError(codes.Internal, fmt.Sprintf("failed to create nic access, Err: %v", err), err)
+	}
+	klog.FromContext(ctx).Info("Resuming pending creation of NIC", "nic", nicName)
+	nic, err := accesshelpers.ResumeCreateNIC(ctx, nicAccess, resourceGroup, nicName, pendingOp.ResumeToken)
+	if err != nil {
+		if isPendingOperationError(err) {
+			return "", status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to create NIC: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, nicName, err), err)
+		}
+		klog.FromContext(ctx).Info("Failed to resume creation of NIC, will trigger its creation again", errKeysAndValues(ctx, err, "nic", nicName)...)
+		return "", nil
+	}
+	klog.FromContext(ctx).Info("Successfully created NIC", "nic", nicName, "nicID", *nic.ID)
+	return *nic.ID, nil
+}
+
+// ResumeVMCreation returns the VM if it has been created by a previous CreateMachine call. If the creation of the VM was
+// still in progress then polling for it is resumed. Nil is returned if the VM has to be created.
+func ResumeVMCreation(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, vmName string, previousState *LastKnownState) (*armcompute.VirtualMachine, error) {
+	if previousState == nil {
+		return nil, nil
+	}
+	pendingOp := previousState.GetPendingOperation(PendingOperationTypeCreateVM, vmName)
+	if previousState.CreatedResources.VMID == "" && pendingOp == nil {
+		return nil, nil
+	}
+	resourceGroup := providerSpec.ResourceGroup
+	vmAccess, err := factory.GetVirtualMachinesAccess(WithImageTenant(connectConfig, providerSpec.Properties.StorageProfile.ImageReference))
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine access to process request: [resourceGroup: %s, vmName: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	if previousState.CreatedResources.VMID != "" {
+		vm, err := accesshelpers.GetVirtualMachine(ctx, vmAccess, resourceGroup, vmName)
+		if err != nil {
+			return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+		if vm != nil {
+			klog.FromContext(ctx).Info("VM has been created by a previous attempt, will skip creation of the VM", "vm", vmName)
+			return vm, nil
+		}
+		if pendingOp == nil {
+			return nil, nil
+		}
+	}
+	klog.FromContext(ctx).Info("Resuming pending creation of VM", "vm", vmName)
+	vm, err := accesshelpers.ResumeCreateVirtualMachine(ctx, vmAccess, resourceGroup, vmName, pendingOp.ResumeToken)
+	if err != nil {
+		if isPendingOperationError(err) {
+			return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to create VirtualMachine: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		}
+		klog.FromContext(ctx).Info("Failed to resume creation of VM, will trigger its creation again", errKeysAndValues(ctx, err, "vm", vmName)...)
+		return nil, nil
+	}
+	klog.FromContext(ctx).Info("Successfully created VM", "vm", vmName)
+	return vm, nil
+}
+
+// isPendingOperationError checks if the error has been returned because the operation has not yet completed.
+func isPendingOperationError(err error) bool {
+	var pollingErr *accesserrors.PollingError
+	return errors.As(err, &pollingErr)
+}
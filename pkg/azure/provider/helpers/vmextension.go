@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helpers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
+	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	accesshelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+const (
+	applicationHealthExtensionPublisher   = "Microsoft.ManagedServices"
+	applicationHealthLinuxExtensionType   = "ApplicationHealthLinux"
+	applicationHealthWindowsExtensionType = "ApplicationHealthWindows"
+	applicationHealthExtensionVersion     = "2.0"
+)
+
+// applicationHealthSettings are the public settings of the Application Health extension.
+type applicationHealthSettings struct {
+	Protocol          string  `json:"protocol"`
+	Port              *int32  `json:"port,omitempty"`
+	RequestPath       *string `json:"requestPath,omitempty"`
+	IntervalInSeconds *int32  `json:"intervalInSeconds,omitempty"`
+	NumberOfProbes    *int32  `json:"numberOfProbes,omitempty"`
+}
+
+// InstallVMExtensions installs all extensions configured in the provider spec on the VM. Extensions are installed sequentially
+// in the order in which they are defined, as Azure does not allow concurrent extension operations on the same VM.
+// Installing an extension which already exists is a no-op, therefore it is safe to call this function again if a previous attempt failed.
+func InstallVMExtensions(ctx context.Context, factory access.Factory, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, secret *corev1.Secret, vmName string) error {
+	extensions, err := getVMExtensions(providerSpec)
+	if err != nil {
+		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to create VM extensions for VM: [ResourceGroup: %s, Name: %s], Err: %v", providerSpec.ResourceGroup, vmName, err), err)
+	}
+	if utils.IsSliceNilOrEmpty(extensions) {
+		return nil
+	}
+	vmExtensionsAccess, err := factory.GetVirtualMachineExtensionsAccess(connectConfig)
+	if err != nil {
+		return status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine extensions access to process request: [resourceGroup: %s, vmName: %s], Err: %v", providerSpec.ResourceGroup, vmName, err), err)
+	}
+	for _, extension := range extensions {
+		extensionParams := createVMExtensionParams(providerSpec, extension, secret)
+		if _, err = accesshelpers.CreateOrUpdateVMExtension(ctx, vmExtensionsAccess, providerSpec.ResourceGroup, vmName, extensionParams); err != nil {
+			errCode := accesserrors.GetMatchingErrorCode(err)
+			return status.WrapError(errCode, fmt.Sprintf("Failed to install VM extension: [ResourceGroup: %s, VMName: %s, Extension: %s], Err: %v", providerSpec.ResourceGroup, vmName, extension.Name, err), err)
+		}
+		klog.FromContext(ctx).Info("Successfully installed VM extension", "vm", vmName, "extension", extension.Name)
+	}
+	return nil
+}
+
+// getVMExtensions returns the extensions that are configured in the provider spec. If the application health profile is enabled
+// then the Application Health extension is appended, so that it is installed after all other extensions.
+func getVMExtensions(providerSpec api.AzureProviderSpec) ([]api.AzureVMExtension, error) {
+	extensions := providerSpec.Properties.Extensions
+	profile := providerSpec.Properties.ApplicationHealthProfile
+	if profile == nil || !profile.Enabled {
+		return extensions, nil
+	}
+	settings, err := json.Marshal(applicationHealthSettings{
+		Protocol:          profile.Protocol,
+		Port:              profile.Port,
+		RequestPath:       profile.RequestPath,
+		IntervalInSeconds: profile.IntervalInSeconds,
+		NumberOfProbes:    profile.NumberOfProbes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	extensionType := applicationHealthLinuxExtensionType
+	if providerSpec.Properties.OsProfile.WindowsConfiguration != nil {
+		extensionType = applicationHealthWindowsExtensionType
+	}
+	return append(slices.Clone(extensions), api.AzureVMExtension{
+		Name:                    api.ApplicationHealthExtensionName,
+		Publisher:               applicationHealthExtensionPublisher,
+		Type:                    extensionType,
+		TypeHandlerVersion:      applicationHealthExtensionVersion,
+		AutoUpgradeMinorVersion: to.Ptr(true),
+		Settings:                settings,
+	}), nil
+}
+
+func createVMExtensionParams(providerSpec api.AzureProviderSpec, extension api.AzureVMExtension, secret *corev1.Secret) armcompute.VirtualMachineExtension {
+	extensionParams := armcompute.VirtualMachineExtension{
+		Location: to.Ptr(providerSpec.Location),
+		Name:     to.Ptr(extension.Name),
+		Properties: &armcompute.VirtualMachineExtensionProperties{
+			AutoUpgradeMinorVersion: extension.AutoUpgradeMinorVersion,
+			Publisher:               to.Ptr(extension.Publisher),
+			Type:                    to.Ptr(extension.Type),
+			TypeHandlerVersion:      to.Ptr(extension.TypeHandlerVersion),
+		},
+		Tags: utils.CreateResourceTags(providerSpec.Tags),
+	}
+	// Settings and ProtectedSettings are only set when present, as a nil json.RawMessage would otherwise be serialized as null.
+	if len(extension.Settings) > 0 {
+		extensionParams.Properties.Settings = extension.Settings
+	}
+	if extension.ProtectedSettingsSecretKey != nil {
+		// secret has already been validated to contain valid JSON for this key.
+		extensionParams.Properties.ProtectedSettings = json.RawMessage(secret.Data[*extension.ProtectedSettingsSecretKey])
+	}
+	return extensionParams
+}
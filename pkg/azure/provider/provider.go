@@ -6,43 +6,142 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/gardener/machine-controller-manager/pkg/apis/machine/v1alpha1"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/driver"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
 
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access"
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
 	clienthelpers "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/helpers"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/instrument"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/provider/helpers"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
 )
 
 const (
-	createMachineOperationLabel    = "create_machine"
-	deleteMachineOperationLabel    = "delete_machine"
-	listMachinesOperationLabel     = "list_machine"
-	getMachineStatusOperationLabel = "get_machine_status"
-	getVolumeIDsOperationLabel     = "get_volume_ids"
+	createMachineOperationLabel     = "create_machine"
+	initializeMachineOperationLabel = "initialize_machine"
+	deleteMachineOperationLabel     = "delete_machine"
+	listMachinesOperationLabel      = "list_machine"
+	getMachineStatusOperationLabel  = "get_machine_status"
+	getVolumeIDsOperationLabel      = "get_volume_ids"
+	updateMachineOperationLabel     = "update_machine"
 )
 
+// MachineUpdater is implemented by drivers which can update the VM backing a machine in place. It is not yet part of
+// driver.Driver, consumers can check for it with a type assertion. Only changes to the fields in helpers.HotUpdatableFields
+// can be applied in place, helpers.IsHotUpdatable can be used to check if a change of the MachineClass qualifies.
+type MachineUpdater interface {
+	// UpdateMachine updates the VM backing the machine in place to match the MachineClass.
+	//
+	// In case of an error, this operation should return an error with one of the following status codes
+	//  - codes.NotFound if VM instance was not found.
+	//  - codes.FailedPrecondition if the VM cannot be updated in its current state or its VM size has changed without opting in to resize it.
+	//  - codes.Internal if the update failed due to errors
+	UpdateMachine(context.Context, *UpdateMachineRequest) (*UpdateMachineResponse, error)
+}
+
+// UpdateMachineRequest is the request to update the VM backing a machine in place.
+type UpdateMachineRequest struct {
+	// Machine object whose VM should be updated
+	Machine *v1alpha1.Machine
+	// MachineClass backing the machine object
+	MachineClass *v1alpha1.MachineClass
+	// Secret backing the machineClass object
+	Secret *corev1.Secret
+	// AllowVMResize opts in to resize the VM in place if its VM size has changed, see helpers.HotUpdateOptions.
+	AllowVMResize bool
+}
+
+// UpdateMachineResponse is the response to an in place update of the VM backing a machine.
+type UpdateMachineResponse struct {
+	// Updated indicates if the VM had to be updated.
+	Updated bool
+	// LastKnownState is the state of the machine after the update, it is also returned if the update fails. It has to be
+	// recorded as LastKnownState of the machine, so that a VM which has been deallocated for a resize but could not be
+	// started is started by the next update.
+	LastKnownState string
+}
+
+const (
+	// lockedDeletionInitialBackoff is the initial duration for which the deletion of a machine is not retried after it
+	// failed due to a resource lock or a disabled subscription.
+	lockedDeletionInitialBackoff = time.Minute
+	// lockedDeletionMaxBackoff is the maximum duration for which the deletion of a machine is not retried after it
+	// failed due to a resource lock or a disabled subscription.
+	lockedDeletionMaxBackoff = 30 * time.Minute
+	// deletionBlockedByResourceLockEventReason is the reason of the event which is emitted for a machine whose deletion
+	// failed due to a resource lock or a disabled subscription.
+	deletionBlockedByResourceLockEventReason = "DeletionBlockedByResourceLock"
+	// driftDetectedEventReason is the reason of the event which is emitted for a machine whose VM, NIC or OSDisk no longer
+	// match the provider spec, see api.AzureVirtualMachineProperties.DetectDrift.
+	driftDetectedEventReason = "DriftDetected"
+)
+
+// DriverOptions are the options for the default driver.
+type DriverOptions struct {
+	// EventRecorder is used to emit events for machines. No events are emitted if it is nil.
+	EventRecorder record.EventRecorder
+	// MachineStatusViaResourceGraph makes GetMachineStatus determine the state of the VM via resource graph instead of getting
+	// the VM for every call, which reduces the number of requests against the compute API. The VM is still retrieved directly
+	// if resource graph does not report it yet or if drift detection is enabled.
+	MachineStatusViaResourceGraph bool
+	// ReconcileTags makes GetMachineStatus add missing or changed tags of the provider spec to the VM, its NIC and its disks
+	// (see helpers.ReconcileTags), so that tags which have been changed in the MachineClass are applied to existing machines.
+	// Since this requires to get the VM, its NIC and its disks for every call, it is disabled by default.
+	ReconcileTags bool
+}
+
 // defaultDriver implements provider.Driver interface
 type defaultDriver struct {
-	factory access.Factory
+	factory       access.Factory
+	eventRecorder record.EventRecorder
+	// machineStatusViaResourceGraph is set from DriverOptions.MachineStatusViaResourceGraph.
+	machineStatusViaResourceGraph bool
+	// reconcileTags is set from DriverOptions.ReconcileTags.
+	reconcileTags bool
+	// lockedDeletionBackoff tracks the machines whose deletion failed due to a resource lock, so that the deletion is
+	// not retried against Azure until the backoff has passed.
+	lockedDeletionBackoff *flowcontrol.Backoff
 }
 
 // NewDefaultDriver creates a new instance of an implementation of provider.Driver. This can be mostly used by tests where we also wish to have our own polling intervals.
 func NewDefaultDriver(accessFactory access.Factory) driver.Driver {
+	return NewDefaultDriverWithOptions(accessFactory, DriverOptions{})
+}
+
+// NewDefaultDriverWithOptions creates a new instance of an implementation of provider.Driver using the passed DriverOptions.
+func NewDefaultDriverWithOptions(accessFactory access.Factory, opts DriverOptions) driver.Driver {
 	return defaultDriver{
-		factory: accessFactory,
+		factory:                       accessFactory,
+		eventRecorder:                 opts.EventRecorder,
+		machineStatusViaResourceGraph: opts.MachineStatusViaResourceGraph,
+		reconcileTags:                 opts.ReconcileTags,
+		lockedDeletionBackoff:         flowcontrol.NewBackOff(lockedDeletionInitialBackoff, lockedDeletionMaxBackoff),
 	}
 }
 
 func (d defaultDriver) ListMachines(ctx context.Context, req *driver.ListMachinesRequest) (resp *driver.ListMachinesResponse, err error) {
-	defer instrument.DriverAPIMetricRecorderFn(listMachinesOperationLabel, &err)()
+	ctx, recordFn := instrument.DriverAPIRecorderFn(ctx, listMachinesOperationLabel, &err)
+	defer recordFn()
+	ctx = accesserrors.WithRequestIDsRecorder(ctx)
+	defer accesserrors.AddRequestIDs(ctx, &err)
 	providerSpec, connectConfig, err := helpers.ExtractProviderSpecAndConnectConfig(req.MachineClass, req.Secret)
 	if err != nil {
 		return
@@ -56,69 +155,260 @@ func (d defaultDriver) ListMachines(ctx context.Context, req *driver.ListMachine
 }
 
 func (d defaultDriver) CreateMachine(ctx context.Context, req *driver.CreateMachineRequest) (resp *driver.CreateMachineResponse, err error) {
-	defer instrument.DriverAPIMetricRecorderFn(createMachineOperationLabel, &err)()
+	ctx, recordFn := instrument.DriverAPIRecorderFn(ctx, createMachineOperationLabel, &err, attribute.String("machine.name", req.Machine.Name))
+	defer recordFn()
+	ctx = accesserrors.WithRequestIDsRecorder(ctx)
+	defer accesserrors.AddRequestIDs(ctx, &err)
+	defer accesserrors.ClassifyPolicyDenial(&err)
 
 	providerSpec, connectConfig, err := helpers.ExtractProviderSpecAndConnectConfig(req.MachineClass, req.Secret)
 	if err != nil {
 		return
 	}
-	vmName := req.Machine.Name
-	nicName := utils.CreateNICName(vmName)
+	ctx = helpers.NewMachineLogContext(ctx, createMachineOperationLabel, req.Machine.Name, providerSpec.ResourceGroup)
+	ctx = instrument.WithRegion(ctx, providerSpec.Location)
+	if err = helpers.ValidateSecretForVMCreation(req.Secret, providerSpec); err != nil {
+		return
+	}
+	var (
+		machineName = req.Machine.Name
+		vmName      = utils.CreateVMName(machineName)
+		nicName     = utils.CreateNICName(vmName)
+	)
+
+	// resources which have been created by a previous attempt are not created again and pending operations are resumed.
+	previousState := helpers.GetPreviousLastKnownState(ctx, req.Machine)
+
+	// if multiple zones are configured then select the zone for this VM. All resources of the VM are then created in this zone.
+	// A zone which has been used by a previous attempt, e.g. after falling back to an alternate zone, is kept.
+	selectedZone := helpers.SelectZone(providerSpec.Properties.Zones, vmName)
+	if previousZone := previousState.GetZone(); previousZone != nil && slices.Contains(providerSpec.Properties.Zones, *previousZone) {
+		selectedZone = previousZone
+	}
+	if selectedZone != nil {
+		providerSpec.Properties.Zone = selectedZone
+	}
+
+	// record the progress, so that it is available as LastKnownState also if the creation fails.
+	lastKnownState := &helpers.LastKnownState{Zone: providerSpec.Properties.Zone}
+	defer func() {
+		if err != nil {
+			lastKnownState.CreationFailure = helpers.NewCreationFailure(err, time.Now())
+			resp = &driver.CreateMachineResponse{LastKnownState: lastKnownState.Encode(ctx)}
+		}
+	}()
+
+	if err = helpers.ValidateVMSizeAvailability(ctx, d.factory, connectConfig, providerSpec); err != nil {
+		return
+	}
+	acceleratedNetworking, err := helpers.ResolveAcceleratedNetworking(ctx, d.factory, connectConfig, providerSpec)
+	if err != nil {
+		return
+	}
+	providerSpec.Properties.NetworkProfile.AcceleratedNetworking = acceleratedNetworking
+
+	if err = helpers.ValidateHyperVGeneration(ctx, d.factory, connectConfig, providerSpec); err != nil {
+		return
+	}
+	if err = helpers.ValidateWriteAccelerator(ctx, d.factory, connectConfig, providerSpec); err != nil {
+		return
+	}
+	if err = helpers.ValidateDiskControllerType(ctx, d.factory, connectConfig, providerSpec); err != nil {
+		return
+	}
+	if err = helpers.ValidateHibernation(ctx, d.factory, connectConfig, providerSpec); err != nil {
+		return
+	}
+	if err = helpers.ValidateDataDiskCount(ctx, d.factory, connectConfig, providerSpec); err != nil {
+		return
+	}
+
+	// a VM whose creation is resumed already counts against the quota.
+	if previousState.GetPendingOperation(helpers.PendingOperationTypeCreateVM, vmName) == nil {
+		if err = helpers.CheckVCPUQuota(ctx, d.factory, connectConfig, providerSpec); err != nil {
+			return
+		}
+	}
 
-	imageReference, plan, err := helpers.ProcessVMImageConfiguration(ctx, d.factory, connectConfig, providerSpec, vmName)
+	imageReference, plan, err := helpers.ProcessVMImageConfiguration(ctx, d.factory, connectConfig, providerSpec, vmName, previousState.GetImageID())
 	if err != nil {
 		return
 	}
+	lastKnownState.ImageID = helpers.GetImageID(imageReference)
 
 	subnet, err := helpers.GetSubnet(ctx, d.factory, connectConfig, providerSpec)
 	if err != nil {
 		return
 	}
 
-	nicID, err := helpers.CreateNICIfNotExists(ctx, d.factory, connectConfig, providerSpec, subnet, nicName)
+	nicID, err := helpers.ResumeNICCreation(ctx, d.factory, connectConfig, providerSpec.ResourceGroup, nicName, previousState)
+	if err == nil && nicID == "" {
+		nicID, err = helpers.CreateNICIfNotExists(ctx, d.factory, connectConfig, providerSpec, subnet, vmName, machineName)
+	}
 	if err != nil {
+		lastKnownState.AddPendingOperationFromError(helpers.PendingOperationTypeCreateNIC, nicName, err)
 		return
 	}
+	lastKnownState.CreatedResources.NICID = nicID
 
 	// create disks with image ref since they can not be created together with the vm
 	// TODO parallelize creation with nic?
-	imageRefDiskIDs, err := helpers.CreateDisksWithImageRef(ctx, d.factory, connectConfig, providerSpec, vmName)
+	imageRefDiskIDs, err := helpers.CreateDisksWithImageRef(ctx, d.factory, connectConfig, providerSpec, vmName, machineName, previousState.GetCreatedDataDiskIDs())
+	lastKnownState.CreatedResources.DataDiskIDs = helpers.GetDataDiskIDs(imageRefDiskIDs)
 	if err != nil {
 		return
 	}
 
-	vm, err := helpers.CreateVM(ctx, d.factory, connectConfig, providerSpec, imageReference, plan, req.Secret, nicID, vmName, imageRefDiskIDs)
+	osDiskID, err := helpers.CreateRestoredOSDisk(ctx, d.factory, connectConfig, providerSpec, vmName, machineName, previousState.GetCreatedOSDiskID())
+	lastKnownState.CreatedResources.OSDiskID = pointer.StringDeref(osDiskID, "")
 	if err != nil {
 		return
 	}
 
-	resp = helpers.ConstructCreateMachineResponse(providerSpec.Location, vmName)
-	helpers.LogVMCreation(providerSpec.Location, providerSpec.ResourceGroup, vm)
+	vm, err := helpers.ResumeVMCreation(ctx, d.factory, connectConfig, providerSpec, vmName, previousState)
+	if err == nil && vm == nil {
+		vm, err = helpers.CreateVM(ctx, d.factory, connectConfig, providerSpec, imageReference, plan, req.Secret, nicID, vmName, machineName, imageRefDiskIDs, osDiskID)
+	}
+	// a VM which could not be allocated in the selected zone is created in one of the other configured zones instead. This is
+	// not possible if zonal disks have already been created for the VM in the selected zone.
+	if helpers.IsAllocationFailedError(err) && len(imageRefDiskIDs) == 0 && osDiskID == nil {
+		for _, zone := range helpers.GetAlternateZones(providerSpec.Properties.Zones, providerSpec.Properties.Zone) {
+			klog.FromContext(ctx).Info("VM could not be allocated in zone, will retry in alternate zone", "vm", vmName, "zone", *providerSpec.Properties.Zone, "alternateZone", zone, "err", err)
+			// the NIC is not zonal, it is kept when the unallocated VM is deleted and reused for the VM in the alternate zone.
+			if err = helpers.DeleteUnallocatedVM(ctx, d.factory, connectConfig, providerSpec.ResourceGroup, vmName, nicID); err != nil {
+				return
+			}
+			providerSpec.Properties.Zone = to.Ptr(zone)
+			lastKnownState.Zone = providerSpec.Properties.Zone
+			if vm, err = helpers.CreateVM(ctx, d.factory, connectConfig, providerSpec, imageReference, plan, req.Secret, nicID, vmName, machineName, imageRefDiskIDs, osDiskID); !helpers.IsAllocationFailedError(err) {
+				break
+			}
+		}
+	}
+	if err != nil {
+		lastKnownState.AddPendingOperationFromError(helpers.PendingOperationTypeCreateVM, vmName, err)
+		return
+	}
+	lastKnownState.CreatedResources.VMID = pointer.StringDeref(vm.ID, "")
+
+	if err = helpers.UpdateOSDiskTier(ctx, d.factory, connectConfig, providerSpec, vmName); err != nil {
+		return
+	}
+
+	if err = helpers.InstallVMExtensions(ctx, d.factory, connectConfig, providerSpec, req.Secret, vmName); err != nil {
+		return
+	}
+
+	resp = helpers.ConstructCreateMachineResponse(ctx, providerSpec, vmName, lastKnownState)
+	helpers.LogVMCreation(ctx, providerSpec.Location, vm)
+	return
+}
+
+func (d defaultDriver) InitializeMachine(ctx context.Context, req *driver.InitializeMachineRequest) (resp *driver.InitializeMachineResponse, err error) {
+	ctx, recordFn := instrument.DriverAPIRecorderFn(ctx, initializeMachineOperationLabel, &err, attribute.String("machine.name", req.Machine.Name))
+	defer recordFn()
+	ctx = accesserrors.WithRequestIDsRecorder(ctx)
+	defer accesserrors.AddRequestIDs(ctx, &err)
+
+	providerSpec, connectConfig, err := helpers.ExtractProviderSpecAndConnectConfig(req.MachineClass, req.Secret)
+	if err != nil {
+		return
+	}
+	ctx = helpers.NewMachineLogContext(ctx, initializeMachineOperationLabel, req.Machine.Name, providerSpec.ResourceGroup)
+	ctx = instrument.WithRegion(ctx, providerSpec.Location)
+	var (
+		resourceGroup = providerSpec.ResourceGroup
+		vmName        = utils.CreateVMName(req.Machine.Name)
+	)
+	vmAccess, err := d.factory.GetVirtualMachinesAccess(connectConfig)
+	if err != nil {
+		err = status.WrapError(codes.Uninitialized, fmt.Sprintf("Failed to create virtual machine access to process request: [ResourceGroup: %s, VMName: %s], Err: %v", resourceGroup, vmName, err), err)
+		return
+	}
+	if err = helpers.EnsureVirtualMachineIsRunning(ctx, vmAccess, resourceGroup, vmName); err != nil {
+		return
+	}
+	klog.FromContext(ctx).Info("VM is provisioned and running", "vm", vmName)
+	resp = helpers.ConstructInitializeMachineResponse(providerSpec, vmName)
 	return
 }
 
-func (d defaultDriver) InitializeMachine(_ context.Context, _ *driver.InitializeMachineRequest) (*driver.InitializeMachineResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "Azure Provider does not yet implement InitializeMachine")
+// UpdateMachine updates the fields in helpers.HotUpdatableFields of the VM backing the machine in place. If opted in,
+// the VM is also resized in place.
+func (d defaultDriver) UpdateMachine(ctx context.Context, req *UpdateMachineRequest) (resp *UpdateMachineResponse, err error) {
+	ctx, recordFn := instrument.DriverAPIRecorderFn(ctx, updateMachineOperationLabel, &err, attribute.String("machine.name", req.Machine.Name))
+	defer recordFn()
+	ctx = accesserrors.WithRequestIDsRecorder(ctx)
+	defer accesserrors.AddRequestIDs(ctx, &err)
+	defer accesserrors.ClassifyPolicyDenial(&err)
+
+	providerSpec, connectConfig, err := helpers.ExtractProviderSpecAndConnectConfig(req.MachineClass, req.Secret)
+	if err != nil {
+		return
+	}
+	ctx = helpers.NewMachineLogContext(ctx, updateMachineOperationLabel, req.Machine.Name, providerSpec.ResourceGroup)
+	var (
+		resourceGroup = providerSpec.ResourceGroup
+		vmName        = utils.CreateVMName(req.Machine.Name)
+	)
+	vmAccess, err := d.factory.GetVirtualMachinesAccess(connectConfig)
+	if err != nil {
+		err = status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine access to process request: [ResourceGroup: %s, VMName: %s], Err: %v", resourceGroup, vmName, err), err)
+		return
+	}
+	vm, err := clienthelpers.GetVirtualMachine(ctx, vmAccess, resourceGroup, vmName)
+	if err != nil {
+		err = status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		return
+	}
+	if vm == nil {
+		err = status.Error(codes.NotFound, fmt.Sprintf("VM: [ResourceGroup: %s, Name: %s] is not found", resourceGroup, vmName))
+		return
+	}
+	if !helpers.CanUpdateVirtualMachine(vm) {
+		err = status.Error(codes.FailedPrecondition, fmt.Sprintf("Cannot update VM: [ResourceGroup: %s, Name: %s]. Either the VM has provisionState set to Failed or there are one or more data disks that are marked for detachment", resourceGroup, vmName))
+		return
+	}
+
+	// the state recorded by previous calls is retained, the update only records if it has deallocated the VM.
+	lastKnownState := &helpers.LastKnownState{}
+	if previousState := helpers.GetPreviousLastKnownState(ctx, req.Machine); previousState != nil {
+		*lastKnownState = *previousState
+	}
+	updated, err := helpers.UpdateVirtualMachineInPlace(ctx, d.factory, connectConfig, providerSpec, vmAccess, vm, vmName, req.Machine.Name, helpers.HotUpdateOptions{AllowVMResize: req.AllowVMResize}, lastKnownState)
+	resp = &UpdateMachineResponse{Updated: updated, LastKnownState: lastKnownState.Encode(ctx)}
+	return
 }
 
 func (d defaultDriver) DeleteMachine(ctx context.Context, req *driver.DeleteMachineRequest) (resp *driver.DeleteMachineResponse, err error) {
-	defer instrument.DriverAPIMetricRecorderFn(deleteMachineOperationLabel, &err)()
+	ctx, recordFn := instrument.DriverAPIRecorderFn(ctx, deleteMachineOperationLabel, &err, attribute.String("machine.name", req.Machine.Name))
+	defer recordFn()
+	ctx = accesserrors.WithRequestIDsRecorder(ctx)
+	defer accesserrors.AddRequestIDs(ctx, &err)
 
 	providerSpec, connectConfig, err := helpers.ExtractProviderSpecAndConnectConfig(req.MachineClass, req.Secret)
 	if err != nil {
 		return
 	}
+	ctx = helpers.NewMachineLogContext(ctx, deleteMachineOperationLabel, req.Machine.Name, providerSpec.ResourceGroup)
+	ctx = instrument.WithRegion(ctx, providerSpec.Location)
 	var (
 		resourceGroup = providerSpec.ResourceGroup
-		vmName        = strings.ToLower(req.Machine.Name)
+		vmName        = utils.CreateVMName(req.Machine.Name)
+		backoffKey    = createLockedDeletionBackoffKey(connectConfig.SubscriptionID, resourceGroup, vmName)
 	)
+	if d.lockedDeletionBackoff.IsInBackOffSinceUpdate(backoffKey, d.lockedDeletionBackoff.Clock.Now()) {
+		err = status.Error(codes.FailedPrecondition, fmt.Sprintf("deletion of Machine [ResourceGroup: %s, Name: %s] is blocked by a resource lock or a disabled subscription, backing off for %s before retrying", resourceGroup, req.Machine.Name, d.lockedDeletionBackoff.Get(backoffKey)))
+		return
+	}
+	defer func() { d.handleLockedDeletion(ctx, req.Machine, backoffKey, err) }()
 	// Check if Deletion of the machine (VM, NIC, Disks) can be completely skipped.
 	skipDelete, err := helpers.SkipDeleteMachine(ctx, d.factory, connectConfig, resourceGroup)
 	if err != nil {
 		return
 	}
 	if skipDelete {
-		klog.Warningf("skipping delete of Machine [ResourceGroup: %s, Name: %s] since the ResourceGroup no longer exists", resourceGroup, req.Machine.Name)
+		klog.FromContext(ctx).Info("Skipping deletion of machine since the resource group no longer exists")
 		resp = &driver.DeleteMachineResponse{}
 		return
 	}
@@ -130,7 +420,19 @@ func (d defaultDriver) DeleteMachine(ctx context.Context, req *driver.DeleteMach
 	}
 	vm, err := clienthelpers.GetVirtualMachine(ctx, vmAccess, resourceGroup, vmName)
 	if err != nil {
-		err = status.WrapError(codes.Internal, fmt.Sprintf("failed to get virtual machine for VM: [resourceGroup: %s, name: %s], Err: %v", resourceGroup, vmName, err), err)
+		err = status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("failed to get virtual machine for VM: [resourceGroup: %s, name: %s], Err: %v", resourceGroup, vmName, err), err)
+		return
+	}
+	// protected machines are kept including their leftover NICs and Disks, the deletion is retried until the protection is removed.
+	if err = helpers.CheckDeletionProtection(req.Machine, vm); err != nil {
+		klog.FromContext(ctx).Info("Refusing to delete machine", "err", err)
+		return
+	}
+	// resources of a machine whose creation has failed can be retained for debugging. The LastKnownState is handed back,
+	// so that the creation failure is still known when the deletion is retried.
+	if err = helpers.CheckFailedCreationRetention(ctx, providerSpec, req.Machine, time.Now()); err != nil {
+		klog.FromContext(ctx).Info("Refusing to delete machine", "err", err)
+		resp = &driver.DeleteMachineResponse{LastKnownState: req.Machine.Status.LastKnownState}
 		return
 	}
 	/*
@@ -139,64 +441,145 @@ func (d defaultDriver) DeleteMachine(ctx context.Context, req *driver.DeleteMach
 		Once all the VirtualMachines are launched with cascade-delete enabled for NICs and Disks then this can be removed.
 	*/
 	if vm == nil {
-		klog.Infof("VirtualMachine [resourceGroup: %s, name: %s] does not exist. Skipping deletion of VirtualMachine. Checking for leftover NICs and Disks and if present delete tasks will be added.", providerSpec.ResourceGroup, vmName)
+		klog.FromContext(ctx).Info("VM does not exist, skipping its deletion and deleting leftover NICs and Disks if present", "vm", vmName)
 		// check if there are leftover NICs and Disks that needs to be deleted.
-		if err = helpers.CheckAndDeleteLeftoverNICsAndDisks(ctx, d.factory, vmName, connectConfig, providerSpec); err != nil {
+		if err = helpers.CheckAndDeleteLeftoverNICsAndDisks(ctx, d.factory, vmName, connectConfig, providerSpec, 0); err != nil {
 			return
 		}
 	} else {
+		forceDeletion := helpers.IsForceDeletionEnabled(ctx, providerSpec, req.Machine)
 		if helpers.CanUpdateVirtualMachine(vm) {
 			if err = helpers.UpdateCascadeDeleteOptions(ctx, providerSpec, vmAccess, resourceGroup, vm); err != nil {
 				return
 			}
-			if err = helpers.DeleteVirtualMachine(ctx, vmAccess, resourceGroup, vmName); err != nil {
+			if err = helpers.DeallocateVirtualMachineBeforeDeletion(ctx, providerSpec, vmAccess, vmName); err != nil {
+				return
+			}
+			if err = helpers.DeleteVirtualMachine(ctx, vmAccess, resourceGroup, vmName, forceDeletion); err != nil {
+				resp = helpers.ConstructDeleteMachineErrorResponse(ctx, vmName, err)
 				return
 			}
 		} else {
-			klog.Infof("Cannot update VM: [ResourceGroup: %s, Name: %s]. Either the VM has provisionState set to Failed or there are one or more data disks that are marked for detachment, update call to this VM will fail and therefore skipped. Will now delete the VM and all its associated resources.", resourceGroup, vmName)
-			if err = helpers.DeleteVirtualMachine(ctx, vmAccess, resourceGroup, vmName); err != nil {
+			klog.FromContext(ctx).Info("Skipping update of VM since it is in Failed provisioning state or has data disks marked for detachment, deleting the VM and all its associated resources", "vm", vmName)
+			if err = helpers.DeleteVirtualMachine(ctx, vmAccess, resourceGroup, vmName, forceDeletion); err != nil {
+				resp = helpers.ConstructDeleteMachineErrorResponse(ctx, vmName, err)
 				return
 			}
-			if err = helpers.CheckAndDeleteLeftoverNICsAndDisks(ctx, d.factory, vmName, connectConfig, providerSpec); err != nil {
+			if err = helpers.CheckAndDeleteLeftoverNICsAndDisks(ctx, d.factory, vmName, connectConfig, providerSpec, 0); err != nil {
 				return
 			}
 		}
-		klog.Infof("Successfully deleted all Machine resources[VM, NIC, Disks] for [ResourceGroup: %s, VMName: %s]", providerSpec.ResourceGroup, vmName)
+		klog.FromContext(ctx).Info("Successfully deleted all machine resources [VM, NIC, Disks]", "vm", vmName)
+	}
+	// public IP addresses are not deleted together with the VM, therefore they are always checked for.
+	if err = helpers.CheckAndDeleteLeftoverPublicIPs(ctx, d.factory, vmName, req.Machine.Name, connectConfig, providerSpec, 0); err != nil {
+		return
 	}
 	resp = &driver.DeleteMachineResponse{}
 	return
 }
 
+// handleLockedDeletion backs off the deletion of the machine if it failed due to a resource lock or a disabled
+// subscription, since retrying the deletion will fail until the lock has been removed. An event is emitted for the
+// machine, so that the reason is visible to operators. The backoff is reset once the deletion no longer fails due to a lock.
+func (d defaultDriver) handleLockedDeletion(ctx context.Context, machine *v1alpha1.Machine, backoffKey string, err error) {
+	var statusErr *status.Status
+	if errors.As(err, &statusErr) && statusErr.Cause() != nil {
+		err = statusErr.Cause()
+	}
+	if !accesserrors.IsResourceLockedAzAPIError(err) {
+		d.lockedDeletionBackoff.Reset(backoffKey)
+		return
+	}
+	d.lockedDeletionBackoff.Next(backoffKey, d.lockedDeletionBackoff.Clock.Now())
+	klog.FromContext(ctx).Info("Deletion of machine is blocked by a resource lock or a disabled subscription, backing off", "backoff", d.lockedDeletionBackoff.Get(backoffKey), "err", err)
+	if d.eventRecorder != nil {
+		d.eventRecorder.Eventf(machine, corev1.EventTypeWarning, deletionBlockedByResourceLockEventReason, "Deletion is blocked by a resource lock or a disabled subscription, will retry in %s: %v", d.lockedDeletionBackoff.Get(backoffKey), err)
+	}
+}
+
+func createLockedDeletionBackoffKey(subscriptionID, resourceGroup, vmName string) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s/%s", subscriptionID, resourceGroup, vmName))
+}
+
 func (d defaultDriver) GetMachineStatus(ctx context.Context, req *driver.GetMachineStatusRequest) (resp *driver.GetMachineStatusResponse, err error) {
-	defer instrument.DriverAPIMetricRecorderFn(getMachineStatusOperationLabel, &err)()
+	ctx, recordFn := instrument.DriverAPIRecorderFn(ctx, getMachineStatusOperationLabel, &err, attribute.String("machine.name", req.Machine.Name))
+	defer recordFn()
+	ctx = accesserrors.WithRequestIDsRecorder(ctx)
+	defer accesserrors.AddRequestIDs(ctx, &err)
 
 	providerSpec, connectConfig, err := helpers.ExtractProviderSpecAndConnectConfig(req.MachineClass, req.Secret)
 	if err != nil {
 		return nil, err
 	}
+	ctx = helpers.NewMachineLogContext(ctx, getMachineStatusOperationLabel, req.Machine.Name, providerSpec.ResourceGroup)
 
 	resourceGroup := providerSpec.ResourceGroup
-	vmName := req.Machine.Name
-	vmAccess, err := d.factory.GetVirtualMachinesAccess(connectConfig)
-	if err != nil {
-		err = status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine access to process request: [ResourceGroup: %s, VMName: %s], Err: %v", resourceGroup, vmName, err), err)
+	vmName := utils.CreateVMName(req.Machine.Name)
+	var vm *armcompute.VirtualMachine
+	// drift detection and the reconciliation of the tags require the complete VM, which is not returned by resource graph.
+	if d.machineStatusViaResourceGraph && !providerSpec.Properties.DetectDrift && !d.reconcileTags {
+		vm, err = helpers.GetVirtualMachineStatusFromResourceGraph(ctx, d.factory, connectConfig, resourceGroup, vmName)
+		if err != nil {
+			klog.FromContext(ctx).Info("Failed to get status of VM from resource graph, falling back to get the VM", "vm", vmName, "err", err)
+		}
+	}
+	if vm == nil {
+		vm, err = d.getVirtualMachineWithInstanceView(ctx, connectConfig, resourceGroup, vmName)
+		if err != nil {
+			return
+		}
+	}
+	if vm == nil {
+		err = status.Error(codes.NotFound, fmt.Sprintf("VM: [ResourceGroup: %s, Name: %s] is not found", resourceGroup, vmName))
 		return
 	}
+	// TODO: Enhance the response as proposed in [https://github.com/gardener/machine-controller-manager-provider-azure/issues/88] once that is taken up.
+	klog.FromContext(ctx).Info("VM found", "vm", vmName)
+	// The response is also returned if the VM is not ready, as MCM expects it along with codes.Uninitialized.
+	resp = helpers.ConstructGetMachineStatusResponse(providerSpec, vmName)
+	if providerSpec.Properties.DetectDrift {
+		d.reportDrift(ctx, connectConfig, providerSpec, req.Machine, vm)
+	}
+	if d.reconcileTags && helpers.CanUpdateVirtualMachine(vm) {
+		// the tags are reconciled on a best-effort basis, a failure does not fail the status check.
+		if _, reconcileErr := helpers.ReconcileTags(ctx, d.factory, connectConfig, providerSpec, vm, vmName, req.Machine.Name); reconcileErr != nil {
+			klog.FromContext(ctx).Info("Failed to reconcile tags of VM", "vm", vmName, "err", reconcileErr)
+		}
+	}
+	err = helpers.CheckVirtualMachineState(ctx, resourceGroup, vm)
+	return
+}
 
+// getVirtualMachineWithInstanceView gets the VM along with its instance view. If the VM does not exist then nil is returned.
+func (d defaultDriver) getVirtualMachineWithInstanceView(ctx context.Context, connectConfig access.ConnectConfig, resourceGroup, vmName string) (*armcompute.VirtualMachine, error) {
+	vmAccess, err := d.factory.GetVirtualMachinesAccess(connectConfig)
+	if err != nil {
+		return nil, status.WrapError(codes.Internal, fmt.Sprintf("Failed to create virtual machine access to process request: [ResourceGroup: %s, VMName: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
 	// TODO: After getting response for Query: [https://github.com/Azure/azure-sdk-for-go/issues/21031] replace this call with a more optimized variant to check if a VM exists.
-	vm, err := clienthelpers.GetVirtualMachine(ctx, vmAccess, resourceGroup, vmName)
+	vm, err := clienthelpers.GetVirtualMachineWithInstanceView(ctx, vmAccess, resourceGroup, vmName)
 	if err != nil {
-		err = status.WrapError(codes.Internal, fmt.Sprintf("Failed to get VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+		return nil, status.WrapError(accesserrors.GetMatchingErrorCode(err), fmt.Sprintf("Failed to get VM: [ResourceGroup: %s, Name: %s], Err: %v", resourceGroup, vmName, err), err)
+	}
+	return vm, nil
+}
+
+// reportDrift reports discrepancies between the VM and the provider spec as warning event for the machine. The drift
+// detection is best effort, if it fails then the status of the machine is still returned.
+func (d defaultDriver) reportDrift(ctx context.Context, connectConfig access.ConnectConfig, providerSpec api.AzureProviderSpec, machine *v1alpha1.Machine, vm *armcompute.VirtualMachine) {
+	discrepancies, err := helpers.DetectDrift(ctx, d.factory, connectConfig, providerSpec, vm, machine.Name)
+	if err != nil {
+		klog.FromContext(ctx).Info("Failed to detect drift of VM", "vm", machine.Name, "err", err)
 		return
 	}
-	if vm == nil {
-		err = status.Error(codes.NotFound, fmt.Sprintf("VM: [ResourceGroup: %s, Name: %s] is not found", resourceGroup, vmName))
+	if len(discrepancies) == 0 {
 		return
 	}
-	// TODO: Enhance the response as proposed in [https://github.com/gardener/machine-controller-manager-provider-azure/issues/88] once that is taken up.
-	klog.Infof("VM found for [Machine: %s, ResourceGroup: %s]", vmName, resourceGroup)
-	resp = helpers.ConstructGetMachineStatusResponse(providerSpec.Location, vmName)
-	return
+	klog.FromContext(ctx).Info("Detected drift of VM", "vm", machine.Name, "discrepancies", discrepancies)
+	if d.eventRecorder != nil {
+		d.eventRecorder.Eventf(machine, corev1.EventTypeWarning, driftDetectedEventReason, "VM has been modified outside of the machine controller: %s", strings.Join(discrepancies, "; "))
+	}
 }
 
 func (d defaultDriver) GetVolumeIDs(_ context.Context, request *driver.GetVolumeIDsRequest) (resp *driver.GetVolumeIDsResponse, err error) {
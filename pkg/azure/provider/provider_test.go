@@ -9,7 +9,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
@@ -20,7 +23,11 @@ import (
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/codes"
 	"github.com/gardener/machine-controller-manager/pkg/util/provider/machinecodes/status"
 	. "github.com/onsi/gomega"
+	gomegatypes "github.com/onsi/gomega/types"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	testingclock "k8s.io/utils/clock/testing"
 	"k8s.io/utils/ptr"
 
 	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
@@ -226,6 +233,342 @@ func TestDeleteMachineWhenDataDiskIsAttachedAfterVMCreation(t *testing.T) {
 	checkClusterStateFn(g, ctx, *fakeFactory, vmName, dataDiskNames)
 }
 
+func TestDeleteMachineWithDetachedDataDisk(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	// initialize cluster state
+	// ----------------------------------------------------------------------------
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks(testDataDiskName, 2).Build()
+	// the first data disk holds data which must survive the replacement of the machine
+	providerSpec.Properties.StorageProfile.DataDisks[0].DeleteOption = to.Ptr(string(armcompute.DiskDeleteOptionTypesDetach))
+	dataDiskNames := testhelp.CreateDataDiskNames(vmName, providerSpec)
+	detachedDataDiskName, deletedDataDiskName := dataDiskNames[0], dataDiskNames[1]
+
+	clusterState := fakes.NewClusterState(providerSpec)
+	m := fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildAllResources()
+	// CreateMachine sets the delete option of the data disk as configured in the provider spec
+	for _, dataDisk := range m.VM.Properties.StorageProfile.DataDisks {
+		if *dataDisk.Name == detachedDataDiskName {
+			dataDisk.DeleteOption = to.Ptr(armcompute.DiskDeleteOptionTypesDetach)
+		}
+	}
+	clusterState.AddMachineResources(m)
+
+	fakeFactory := createDefaultFakeFactoryForDeleteMachine(g, providerSpec.ResourceGroup, clusterState)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+	machine := &v1alpha1.Machine{
+		ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+	}
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory)
+	_, err = testDriver.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+
+	// evaluate cluster state post delete machine operation
+	checkClusterStateAndGetMachineResources(ctx, g, *fakeFactory, vmName, false, false, false, []string{deletedDataDiskName}, false, true)
+	checkAndGetDataDisks(ctx, g, *fakeFactory, []string{detachedDataDiskName}, true, false)
+
+	// the detached data disk must not be reported as an orphan resource
+	listMachinesResp, err := NewDefaultDriver(createDefaultFakeFactoryForListMachines(g, testResourceGroupName, clusterState, nil)).ListMachines(ctx, &driver.ListMachinesRequest{
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	g.Expect(listMachinesResp.MachineList).To(BeEmpty())
+}
+
+func TestDeleteMachineWithForceDeletion(t *testing.T) {
+	table := []struct {
+		description         string
+		specForceDeletion   bool
+		annotations         map[string]string
+		expectForceDeletion bool
+	}{
+		{"should not force delete the VM by default", false, nil, false},
+		{"should force delete the VM if it is enabled in the machine class", true, nil, true},
+		{"should force delete the VM if it is enabled by the machine annotation", false, map[string]string{api.ForceDeletionAnnotation: "true"}, true},
+	}
+
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			ctx := context.Background()
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.ForceDeletion = entry.specForceDeletion
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildAllResources())
+			fakeFactory := createDefaultFakeFactoryForDeleteMachine(g, providerSpec.ResourceGroup, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)}
+			machine.Annotations = entry.annotations
+
+			_, err = NewDefaultDriver(fakeFactory).DeleteMachine(ctx, &driver.DeleteMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			g.Expect(clusterState.GetVM(vmName)).To(BeNil())
+			if entry.expectForceDeletion {
+				g.Expect(clusterState.ForceDeletedVMNames).To(ConsistOf(vmName))
+			} else {
+				g.Expect(clusterState.ForceDeletedVMNames).To(BeEmpty())
+			}
+		})
+	}
+}
+
+func TestDeleteMachineWithDeallocateBeforeDeletion(t *testing.T) {
+	table := []struct {
+		description              string
+		deallocateBeforeDeletion bool
+		failDeallocation         bool
+		expectVMDeleted          bool
+	}{
+		{"should delete the VM after deallocating it", true, false, true},
+		{"should not delete the VM if the deallocation fails", true, true, false},
+		{"should not deallocate the VM if it is not enabled", false, true, true},
+	}
+
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			ctx := context.Background()
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.DeallocateBeforeDeletion = entry.deallocateBeforeDeletion
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildAllResources())
+			var vmAccessAPIBehaviorSpec *fakes.APIBehaviorSpec
+			if entry.failDeallocation {
+				vmAccessAPIBehaviorSpec = fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginDeallocate, testhelp.InternalServerError("test-error-code"))
+			}
+			fakeFactory := createFakeFactoryForDeleteMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, nil, vmAccessAPIBehaviorSpec, nil, nil)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+
+			_, err = NewDefaultDriver(fakeFactory).DeleteMachine(ctx, &driver.DeleteMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err == nil).To(Equal(entry.expectVMDeleted))
+			g.Expect(clusterState.GetVM(vmName) == nil).To(Equal(entry.expectVMDeleted))
+		})
+	}
+}
+
+func TestDeleteMachineWithDeletionProtection(t *testing.T) {
+	table := []struct {
+		description       string
+		annotations       map[string]string
+		vmTags            map[string]*string
+		expectVMProtected bool
+	}{
+		{"should delete an unprotected VM", nil, nil, false},
+		{"should not delete the VM if the machine is protected by annotation", map[string]string{api.ProtectFromDeletionAnnotation: "true"}, nil, true},
+		{"should not delete the VM if it is protected by tag", nil, map[string]*string{utils.ProtectFromDeletionTagKey: to.Ptr("")}, true},
+		{"should delete the VM if the protection is disabled", map[string]string{api.ProtectFromDeletionAnnotation: "false"}, map[string]*string{utils.ProtectFromDeletionTagKey: to.Ptr("false")}, false},
+	}
+
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			ctx := context.Background()
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			clusterState := fakes.NewClusterState(providerSpec)
+			machineResources := fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildAllResources()
+			for k, v := range entry.vmTags {
+				machineResources.VM.Tags[k] = v
+			}
+			clusterState.AddMachineResources(machineResources)
+			fakeFactory := createDefaultFakeFactoryForDeleteMachine(g, providerSpec.ResourceGroup, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)}
+			machine.Annotations = entry.annotations
+
+			_, err = NewDefaultDriver(fakeFactory).DeleteMachine(ctx, &driver.DeleteMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectVMProtected {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(codes.FailedPrecondition))
+				g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+				g.Expect(clusterState.GetNIC(utils.CreateNICName(vmName))).ToNot(BeNil())
+			} else {
+				g.Expect(err).To(BeNil())
+				g.Expect(clusterState.GetVM(vmName)).To(BeNil())
+			}
+		})
+	}
+}
+
+func TestDeleteMachineBlockedByResourceLock(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildAllResources())
+	vmAccessAPIBehaviorSpec := fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginDelete, testhelp.ConflictErr(accesserrors.ScopeLockedAzErrorCode))
+	lockedFactory := createFakeFactoryForDeleteMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, nil, vmAccessAPIBehaviorSpec, nil, nil)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+	req := &driver.DeleteMachineRequest{
+		Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	}
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	eventRecorder := record.NewFakeRecorder(10)
+	testDriver := defaultDriver{
+		factory:               lockedFactory,
+		eventRecorder:         eventRecorder,
+		lockedDeletionBackoff: flowcontrol.NewFakeBackOff(lockedDeletionInitialBackoff, lockedDeletionMaxBackoff, fakeClock),
+	}
+	checkFailedPrecondition := func(err error) {
+		var statusErr *status.Status
+		g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+		g.Expect(statusErr.Code()).To(Equal(codes.FailedPrecondition))
+		g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+	}
+
+	_, err = testDriver.DeleteMachine(ctx, req)
+	checkFailedPrecondition(err)
+	g.Expect(eventRecorder.Events).To(HaveLen(1))
+	g.Expect(<-eventRecorder.Events).To(ContainSubstring(deletionBlockedByResourceLockEventReason))
+
+	// the deletion is not retried against Azure while backing off, even if the lock has been removed in the meantime
+	testDriver.factory = createDefaultFakeFactoryForDeleteMachine(g, providerSpec.ResourceGroup, clusterState)
+	_, err = testDriver.DeleteMachine(ctx, req)
+	checkFailedPrecondition(err)
+	g.Expect(eventRecorder.Events).To(BeEmpty())
+
+	fakeClock.Step(lockedDeletionInitialBackoff + time.Second)
+	_, err = testDriver.DeleteMachine(ctx, req)
+	g.Expect(err).To(BeNil())
+	g.Expect(clusterState.GetVM(vmName)).To(BeNil())
+}
+
+func TestDeleteMachineWithFailedCreationCleanup(t *testing.T) {
+	table := []struct {
+		description      string
+		cleanup          *api.AzureFailedCreationCleanup
+		creationFailure  *helpers.CreationFailure
+		expectVMRetained bool
+	}{
+		{"should delete the VM if no cleanup is configured", nil, &helpers.CreationFailure{Time: time.Now(), Code: codes.Internal.String()}, false},
+		{"should delete the VM with policy always", &api.AzureFailedCreationCleanup{Policy: api.FailedCreationCleanupPolicyAlways}, &helpers.CreationFailure{Time: time.Now(), Code: codes.Internal.String()}, false},
+		{"should delete the VM with policy never if its creation did not fail", &api.AzureFailedCreationCleanup{Policy: api.FailedCreationCleanupPolicyNever}, nil, false},
+		{"should retain the VM with policy never", &api.AzureFailedCreationCleanup{Policy: api.FailedCreationCleanupPolicyNever}, &helpers.CreationFailure{Time: time.Now(), Code: codes.Internal.String()}, true},
+		{"should retain the VM with policy never until the retention duration has elapsed", &api.AzureFailedCreationCleanup{Policy: api.FailedCreationCleanupPolicyNever, RetentionDuration: to.Ptr("1h")}, &helpers.CreationFailure{Time: time.Now().Add(-30 * time.Minute), Code: codes.Internal.String()}, true},
+		{"should delete the VM with policy never once the retention duration has elapsed", &api.AzureFailedCreationCleanup{Policy: api.FailedCreationCleanupPolicyNever, RetentionDuration: to.Ptr("1h")}, &helpers.CreationFailure{Time: time.Now().Add(-2 * time.Hour), Code: codes.Internal.String()}, false},
+		{"should delete the VM with policy on-permanent-error if the creation failed permanently", &api.AzureFailedCreationCleanup{Policy: api.FailedCreationCleanupPolicyOnPermanentError}, &helpers.CreationFailure{Time: time.Now(), Code: codes.InvalidArgument.String()}, false},
+		{"should retain the VM with policy on-permanent-error if the creation did not fail permanently", &api.AzureFailedCreationCleanup{Policy: api.FailedCreationCleanupPolicyOnPermanentError}, &helpers.CreationFailure{Time: time.Now(), Code: codes.Internal.String()}, true},
+	}
+
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			ctx := context.Background()
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.FailedCreationCleanup = entry.cleanup
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildAllResources())
+			fakeFactory := createDefaultFakeFactoryForDeleteMachine(g, providerSpec.ResourceGroup, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)}
+			machine.Status.LastKnownState = (&helpers.LastKnownState{CreationFailure: entry.creationFailure}).Encode(context.Background())
+
+			resp, err := NewDefaultDriver(fakeFactory).DeleteMachine(ctx, &driver.DeleteMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectVMRetained {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(codes.FailedPrecondition))
+				g.Expect(resp.LastKnownState).To(Equal(machine.Status.LastKnownState))
+				g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+				g.Expect(clusterState.GetNIC(utils.CreateNICName(vmName))).ToNot(BeNil())
+			} else {
+				g.Expect(err).To(BeNil())
+				g.Expect(clusterState.GetVM(vmName)).To(BeNil())
+			}
+		})
+	}
+}
+
+func TestDeleteMachineWithLeftoverPublicIPs(t *testing.T) {
+	const (
+		vmName      = "vm-0"
+		otherVMName = "vm-1"
+	)
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	// initialize cluster state
+	// ----------------------------------------------------------------------------
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildAllResources())
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, otherVMName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildAllResources())
+
+	namedPublicIPName := utils.CreatePublicIPName(vmName)
+	clusterState.AddPublicIP(namedPublicIPName, utils.CreateResourceTags(providerSpec.Tags), nil)
+	// public IP addresses which do not follow the naming convention are associated via the machine name tag
+	taggedPublicIPName := "legacy-ip"
+	clusterState.AddPublicIP(taggedPublicIPName, utils.CreateVMTags(providerSpec.Tags, vmName), nil)
+	attachedPublicIPName := "attached-ip"
+	clusterState.AddPublicIP(attachedPublicIPName, utils.CreateVMTags(providerSpec.Tags, vmName), to.Ptr(fakes.CreateIPConfigurationID(testhelp.SubscriptionID, testResourceGroupName, "other-nic", "other-nic")))
+	otherPublicIPName := utils.CreatePublicIPName(otherVMName)
+	clusterState.AddPublicIP(otherPublicIPName, utils.CreateResourceTags(providerSpec.Tags), nil)
+
+	fakeFactory := createDefaultFakeFactoryForDeleteMachine(g, providerSpec.ResourceGroup, clusterState)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+	machine := &v1alpha1.Machine{
+		ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+	}
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory)
+	_, err = testDriver.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+
+	// evaluate cluster state post delete machine operation
+	checkClusterStateAndGetMachineResources(ctx, g, *fakeFactory, vmName, false, false, false, nil, false, true)
+	g.Expect(clusterState.GetPublicIP(namedPublicIPName)).To(BeNil())
+	g.Expect(clusterState.GetPublicIP(taggedPublicIPName)).To(BeNil())
+	g.Expect(clusterState.GetPublicIP(attachedPublicIPName)).ToNot(BeNil())
+	g.Expect(clusterState.GetPublicIP(otherPublicIPName)).ToNot(BeNil())
+}
+
 func TestDeleteMachineWhenVMDoesNotExist(t *testing.T) {
 	const vmName = "test-vm-0"
 	testVMID := fakes.CreateVirtualMachineID(testhelp.SubscriptionID, testResourceGroupName, vmName)
@@ -378,6 +721,42 @@ func TestDeleteVMInTerminalState(t *testing.T) {
 	}
 }
 
+func TestDeleteMachineWithoutNodeLabelWhenVMIsInTerminalState(t *testing.T) {
+	const vmName = "test-vm-0"
+	g := NewWithT(t)
+	ctx := context.Background()
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(fakes.CascadeDeleteAllResources).BuildWith(true, true, true, true, nil))
+	g.Expect(clusterState.SetVirtualMachineProvisioningState(vmName, utils.ProvisioningStateFailed)).To(BeTrue())
+
+	fakeFactory := createDefaultFakeFactoryForDeleteMachine(g, providerSpec.ResourceGroup, clusterState)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+	g.Expect(err).To(BeNil())
+	// the machine has no node label, therefore MCM gets the node name via GetMachineStatus before deleting the machine.
+	machine := &v1alpha1.Machine{
+		ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+	}
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory)
+	resp, err := testDriver.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	g.Expect(resp.NodeName).To(Equal(vmName))
+	_, err = testDriver.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	checkClusterStateAndGetMachineResources(ctx, g, *fakeFactory, vmName, false, false, false, nil, false, false)
+}
+
 func TestDeleteExistingVMWithDataDisksInDetachment(t *testing.T) {
 	const vmName = "test-vm-0"
 	g := NewWithT(t)
@@ -639,154 +1018,302 @@ func TestGetMachineStatus(t *testing.T) {
 	}
 }
 
-func TestListMachines(t *testing.T) {
-	type machineResourcesTestSpec struct {
-		vmName          string
-		vmPresent       bool
-		osDiskPresent   bool
-		dataDiskPresent bool
-		nicPresent      bool
-		// if present this will overwrite the tags that are borrowed from provider spec for this machine resource VM. Only specify this when vmPresent is true else it will never reflect.
-		vmTags map[string]string
-		// if present this will overwrite the tags that are borrowed from provider spec for this machine resource NIC. Only specify this when nicPresent is true else it will never reflect.
-		nicTags map[string]string
-		// if present this will overwrite the tags that are borrowed from provider spec for this machine resource OSDisk. Only specify this when nicPresent is true else it will never reflect.
-		osDiskTags map[string]string
-		// if present this will overwrite the tags that are borrowed from provider spec for this machine resource DataDisks. Only specify this when nicPresent is true else it will never reflect.
-		dataDiskTags map[string]string
+func TestGetMachineStatusReportsVMState(t *testing.T) {
+	const vmName = "vm-0"
+
+	table := []struct {
+		description       string
+		provisioningState string
+		powerState        string
+		expectedErrCode   *codes.Code
+	}{
+		{"should not return an error for a provisioned and running VM", "", "", nil},
+		{"should return Uninitialized for a deallocated VM", "", utils.PowerStateDeallocated, to.Ptr(codes.Uninitialized)},
+		{"should return Uninitialized for a stopped VM", "", utils.PowerStateStopped, to.Ptr(codes.Uninitialized)},
+		{"should return Uninitialized for a VM which is still being provisioned", "Creating", utils.PowerStateStarting, to.Ptr(codes.Uninitialized)},
+		{"should not return an error for a VM in terminal state", utils.ProvisioningStateFailed, "", nil},
 	}
 
-	const nonMatchingShootNs = "non-matching-shoot-ns"
+	g := NewWithT(t)
+	ctx := context.Background()
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
 
-	nonMatchingTags := map[string]string{
-		"kubernetes.io-cluster-" + nonMatchingShootNs: "1",
-		"kubernetes.io-role-node":                     "1",
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
+			if entry.provisioningState != "" {
+				g.Expect(clusterState.SetVirtualMachineProvisioningState(vmName, entry.provisioningState)).To(BeTrue())
+			}
+			if entry.powerState != "" {
+				g.Expect(clusterState.SetVirtualMachinePowerState(vmName, entry.powerState)).To(BeTrue())
+			}
+			fakeFactory := fakes.NewFactory(testResourceGroupName)
+			vmAccess, err := fakeFactory.NewVirtualMachineAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			fakeFactory.WithVirtualMachineAccess(vmAccess)
+
+			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{
+				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			}
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			resp, err := testDriver.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			// the response is returned even if the VM is not ready
+			g.Expect(resp).ToNot(BeNil())
+			g.Expect(resp.NodeName).To(Equal(vmName))
+			if entry.expectedErrCode == nil {
+				g.Expect(err).To(BeNil())
+				return
+			}
+			var statusErr *status.Status
+			g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+			g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+		})
 	}
+}
+
+func TestGetMachineStatusViaResourceGraph(t *testing.T) {
+	const vmName = "vm-0"
+	testInternalServerError := testhelp.InternalServerError("test-error-code")
 
 	table := []struct {
-		description     string
-		mrTestSpecs     []machineResourcesTestSpec
-		apiBehaviorSpec *fakes.APIBehaviorSpec
-		numDataDisks    int
-		expectedResult  []string
-		expectedErr     bool
+		description              string
+		existingVM               bool
+		powerState               string
+		resourceGraphAPIBehavior *fakes.APIBehaviorSpec
+		vmAccessAPIBehavior      *fakes.APIBehaviorSpec
+		expectedErrCode          *codes.Code
 	}{
 		{
-			"should return no result if no resources exist", nil, nil, 0, []string{}, false,
-		},
-		{
-			"should return all vm names where vms exist",
-			[]machineResourcesTestSpec{
-				{"vm-0", true, true, true, true, nil, nil, nil, nil},
-				{"vm-1", true, true, true, true, nil, nil, nil, nil},
-			}, nil, 1, []string{"vm-0", "vm-1"}, false,
-		},
-		{
-			"should return vm names only for vms where vm does not exist but a nic exists",
-			[]machineResourcesTestSpec{
-				{"vm-0", false, false, false, false, nil, nil, nil, nil},
-				{"vm-1", false, false, true, false, nil, nil, nil, nil},
-			}, nil, 1, []string{"vm-1"}, false,
+			"should not get the VM if resource graph reports it as running", true, "", nil,
+			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodGet, testInternalServerError), nil,
 		},
 		{
-			"should return vm names only for vms where vm does not exist but an OSDisk exists",
-			[]machineResourcesTestSpec{
-				{"vm-0", false, false, false, false, nil, nil, nil, nil},
-				{"vm-1", false, true, false, false, nil, nil, nil, nil},
-			}, nil, 2, []string{"vm-1"}, false,
+			"should return Uninitialized if resource graph reports the VM as deallocated", true, utils.PowerStateDeallocated, nil,
+			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodGet, testInternalServerError), to.Ptr(codes.Uninitialized),
 		},
 		{
-			"should only return vms matching mandatory provider spec tags",
-			[]machineResourcesTestSpec{
-				{"vm-0", true, true, true, true, nonMatchingTags, nonMatchingTags, nonMatchingTags, nonMatchingTags},
-				{"vm-1", true, true, true, true, nil, nil, nil, nil},
-				{"vm-2", true, true, true, false, nonMatchingTags, nonMatchingTags, nonMatchingTags, nonMatchingTags},
-				{"vm-3", true, true, true, false, nil, nil, nil, nil},
-				{"vm-4", true, true, true, true, nonMatchingTags, nil, nonMatchingTags, nil},
-			}, nil, 1, []string{"vm-1", "vm-3", "vm-4"}, false,
+			"should get the VM if resource graph fails", true, "",
+			fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.VirtualMachinesResourceType, testhelp.AccessMethodResources, testInternalServerError), nil, nil,
 		},
+		{"should get the VM to confirm that it does not exist if resource graph does not report it", false, "", nil, nil, to.Ptr(codes.NotFound)},
 	}
 
 	g := NewWithT(t)
 	ctx := context.Background()
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
 
 	for _, entry := range table {
 		t.Run(entry.description, func(_ *testing.T) {
-			// create provider spec
-			providerSpecBuilder := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues()
-			if entry.numDataDisks > 0 {
-				// Add data disks
-				providerSpecBuilder.WithDataDisks(testDataDiskName, entry.numDataDisks)
+			clusterState := fakes.NewClusterState(providerSpec)
+			if entry.existingVM {
+				clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
 			}
-			providerSpec := providerSpecBuilder.Build()
-			// initialize cluster state
+			if entry.powerState != "" {
+				g.Expect(clusterState.SetVirtualMachinePowerState(vmName, entry.powerState)).To(BeTrue())
+			}
+			fakeFactory := createDefaultFakeFactoryForListMachines(g, testResourceGroupName, clusterState, entry.resourceGraphAPIBehavior)
+			vmAccess, err := fakeFactory.NewVirtualMachineAccessBuilder().WithClusterState(clusterState).WithAPIBehaviorSpec(entry.vmAccessAPIBehavior).Build()
+			g.Expect(err).To(BeNil())
+			fakeFactory.WithVirtualMachineAccess(vmAccess)
+
+			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{
+				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			}
+
+			// Test
 			// ----------------------------------------------------------------------------
-			// create cluster state
-			clusterState := fakes.NewClusterState(providerSpec)
-			if entry.mrTestSpecs != nil {
-				for _, mrTestSpec := range entry.mrTestSpecs {
-					var testVMID *string
-					if !mrTestSpec.vmPresent {
-						testVMID = to.Ptr(fakes.CreateVirtualMachineID(testhelp.SubscriptionID, testResourceGroupName, mrTestSpec.vmName))
-					}
-					mr := fakes.NewMachineResourcesBuilder(providerSpec, mrTestSpec.vmName).BuildWith(mrTestSpec.vmPresent, mrTestSpec.nicPresent, mrTestSpec.osDiskPresent, mrTestSpec.dataDiskPresent, testVMID)
-					if mrTestSpec.vmPresent && mrTestSpec.vmTags != nil {
-						mr.VM.Tags = utils.CreateResourceTags(mrTestSpec.vmTags)
-					}
-					if mrTestSpec.nicPresent && mrTestSpec.nicTags != nil {
-						mr.NIC.Tags = utils.CreateResourceTags(mrTestSpec.nicTags)
-					}
-					if mrTestSpec.osDiskPresent && mrTestSpec.osDiskTags != nil {
-						mr.OSDisk.Tags = utils.CreateResourceTags(mrTestSpec.osDiskTags)
-					}
-					if mrTestSpec.dataDiskPresent && mrTestSpec.dataDiskTags != nil {
-						for _, dataDisk := range mr.DataDisks {
-							dataDisk.Tags = utils.CreateResourceTags(mrTestSpec.dataDiskTags)
-						}
-					}
-					clusterState.AddMachineResources(mr)
-				}
+			testDriver := NewDefaultDriverWithOptions(fakeFactory, DriverOptions{MachineStatusViaResourceGraph: true})
+			resp, err := testDriver.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode == nil {
+				g.Expect(err).To(BeNil())
+				g.Expect(resp.NodeName).To(Equal(vmName))
+				return
 			}
+			var statusErr *status.Status
+			g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+			g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+		})
+	}
+}
 
-			// create fake factory
-			fakeFactory := createDefaultFakeFactoryForListMachines(g, testResourceGroupName, clusterState, nil)
+func TestGetMachineStatusDetectsDrift(t *testing.T) {
+	const vmName = "vm-0"
+	nicName := utils.CreateNICName(vmName)
 
-			// Create machine and machine class to be used to create DeleteMachineRequest
+	table := []struct {
+		description           string
+		detectDrift           bool
+		cascadeDeleteOpts     fakes.CascadeDeleteOpts
+		modifyFn              func(clusterState *fakes.ClusterState)
+		expectedDiscrepancies []string
+	}{
+		{"should not report drift for an unmodified VM", true, fakes.CascadeDeleteAllResources, nil, nil},
+		{"should report a deleted NIC", true, fakes.CascadeDeleteAllResources,
+			func(clusterState *fakes.ClusterState) { clusterState.DeleteNIC(nicName) },
+			[]string{"NIC " + nicName + " does not exist"},
+		},
+		{"should report cascade delete options which have been changed", true, fakes.CascadeDeleteOpts{}, nil,
+			[]string{"NIC " + nicName + " is not deleted together with the VM", "OSDisk vm-0-os-disk is not deleted together with the VM"},
+		},
+		{"should report tags which have been removed from the VM", true, fakes.CascadeDeleteAllResources,
+			func(clusterState *fakes.ClusterState) {
+				delete(clusterState.MachineResourcesMap[vmName].VM.Tags, utils.MachineNameTagKey)
+			},
+			[]string{"tag " + utils.MachineNameTagKey + " is missing on the VM"},
+		},
+		{"should not detect drift if it is not enabled", false, fakes.CascadeDeleteAllResources,
+			func(clusterState *fakes.ClusterState) { clusterState.DeleteNIC(nicName) }, nil,
+		},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.DetectDrift = entry.detectDrift
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).WithCascadeDeleteOptions(entry.cascadeDeleteOpts).BuildAllResources())
+			if entry.modifyFn != nil {
+				entry.modifyFn(clusterState)
+			}
+			fakeFactory := fakes.NewFactory(testResourceGroupName)
+			vmAccess, err := fakeFactory.NewVirtualMachineAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			nicAccess, err := fakeFactory.NewNICAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			diskAccess, err := fakeFactory.NewDiskAccessBuilder().WithClusterState(clusterState).Build()
+			g.Expect(err).To(BeNil())
+			fakeFactory.WithVirtualMachineAccess(vmAccess).WithNetworkInterfacesAccess(nicAccess).WithDisksAccess(diskAccess)
 			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
 			g.Expect(err).To(BeNil())
 
 			// Test
 			// ----------------------------------------------------------------------------
-			testDriver := NewDefaultDriver(fakeFactory)
-			listMachinesResp, err := testDriver.ListMachines(ctx, &driver.ListMachinesRequest{
+			eventRecorder := record.NewFakeRecorder(10)
+			testDriver := NewDefaultDriverWithOptions(fakeFactory, DriverOptions{EventRecorder: eventRecorder})
+			_, err = testDriver.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
 				MachineClass: machineClass,
 				Secret:       fakes.CreateProviderSecret(),
 			})
-			g.Expect(err != nil).To(Equal(entry.expectedErr))
-			actualVMNames := getVMNamesFromListMachineResponse(listMachinesResp)
-			g.Expect(fakes.ActualSliceEqualsExpectedSlice(actualVMNames, entry.expectedResult)).To(BeTrue())
+			g.Expect(err).To(BeNil())
+			if entry.expectedDiscrepancies == nil {
+				g.Expect(eventRecorder.Events).To(BeEmpty())
+				return
+			}
+			g.Expect(eventRecorder.Events).To(HaveLen(1))
+			event := <-eventRecorder.Events
+			g.Expect(event).To(ContainSubstring(driftDetectedEventReason))
+			g.Expect(event).To(HaveSuffix(strings.Join(entry.expectedDiscrepancies, "; ")))
 		})
 	}
 }
 
-func TestListMachineWithInducedErrors(t *testing.T) {
+func TestGetMachineStatusReconcilesTags(t *testing.T) {
+	const vmName = "vm-0"
+
+	table := []struct {
+		description   string
+		reconcileTags bool
+		expectedTags  gomegatypes.GomegaMatcher
+	}{
+		{"should add the changed tags of the provider spec to the VM, its NIC and its disks", true, And(HaveKeyWithValue("new-tag", to.Ptr("new-value")), HaveKeyWithValue("external-tag", to.Ptr("external-value")))},
+		{"should not change the tags if the reconciliation is not enabled", false, And(Not(HaveKey("new-tag")), HaveKeyWithValue("external-tag", to.Ptr("external-value")))},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks(testDataDiskName, 1).Build()
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
+			// tags which have been added outside of MCM
+			machineResources := clusterState.MachineResourcesMap[vmName]
+			machineResources.VM.Tags["external-tag"] = to.Ptr("external-value")
+			machineResources.NIC.Tags["external-tag"] = to.Ptr("external-value")
+			machineResources.OSDisk.Tags["external-tag"] = to.Ptr("external-value")
+			for _, dataDisk := range machineResources.DataDisks {
+				dataDisk.Tags["external-tag"] = to.Ptr("external-value")
+			}
+			fakeFactory := createFakeFactoryForUpdateMachine(g, clusterState, nil)
+
+			// the tags of the MachineClass have been changed after the machine has been created
+			updatedProviderSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks(testDataDiskName, 1).Build()
+			updatedProviderSpec.Tags["new-tag"] = "new-value"
+			machineClass, err := fakes.CreateMachineClass(updatedProviderSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriverWithOptions(fakeFactory, DriverOptions{ReconcileTags: entry.reconcileTags})
+			_, err = testDriver.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			machineResources = clusterState.MachineResourcesMap[vmName]
+			g.Expect(machineResources.VM.Tags).To(entry.expectedTags)
+			g.Expect(machineResources.NIC.Tags).To(entry.expectedTags)
+			g.Expect(machineResources.OSDisk.Tags).To(entry.expectedTags)
+			g.Expect(machineResources.DataDisks).To(HaveLen(1))
+			for _, dataDisk := range machineResources.DataDisks {
+				g.Expect(dataDisk.Tags).To(entry.expectedTags)
+			}
+		})
+	}
+}
+
+func TestInitializeMachine(t *testing.T) {
 	const (
-		vmName        = "test-vm-0"
+		vmName        = "vm-0"
 		testErrorCode = "test-error-code"
 	)
 	testInternalServerError := testhelp.InternalServerError(testErrorCode)
 
 	table := []struct {
-		description     string
-		apiBehaviorSpec *fakes.APIBehaviorSpec
+		description         string
+		vmExists            bool
+		provisioningState   string
+		powerState          string
+		vmAccessAPIBehavior *fakes.APIBehaviorSpec
+		expectedErrCode     *codes.Code
+		expectedPowerState  string
 	}{
+		{"should succeed for a provisioned and running VM", true, "", "", nil, nil, ""},
+		{"should start a deallocated VM", true, "", utils.PowerStateDeallocated, nil, nil, utils.PowerStateRunning},
+		{"should start a stopped VM", true, "", utils.PowerStateStopped, nil, nil, utils.PowerStateRunning},
+		{"should return NotFound for a non-existing VM", false, "", "", nil, to.Ptr(codes.NotFound), ""},
+		{"should return Internal for a VM in terminal state", true, utils.ProvisioningStateFailed, "", nil, to.Ptr(codes.Internal), ""},
+		{"should return Uninitialized for a VM which is still being provisioned", true, "Creating", "", nil, to.Ptr(codes.Uninitialized), ""},
+		{"should return Uninitialized for a VM which is still starting", true, "", utils.PowerStateStarting, nil, to.Ptr(codes.Uninitialized), utils.PowerStateStarting},
 		{
-			"should fail listing machines when resource-graph query for VM resource type returns error",
-			fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.VirtualMachinesResourceType, testhelp.AccessMethodResources, testInternalServerError),
+			"should return the matching error code when getting the instance view fails", true, "", "",
+			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodInstanceView, testInternalServerError),
+			to.Ptr(codes.Internal), "",
 		},
 		{
-			"should fail listing machines when resource-graph query for NIC resource type returns error",
-			fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.NetworkInterfacesResourceType, testhelp.AccessMethodResources, testInternalServerError),
+			"should return Uninitialized when starting the VM fails", true, "", utils.PowerStateDeallocated,
+			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginStart, testInternalServerError),
+			to.Ptr(codes.Uninitialized), utils.PowerStateDeallocated,
 		},
 	}
 
@@ -795,468 +1322,2506 @@ func TestListMachineWithInducedErrors(t *testing.T) {
 
 	// create provider spec
 	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
-	clusterState := fakes.NewClusterState(providerSpec)
-	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildWith(true, true, true, false, nil))
 
 	for _, entry := range table {
 		t.Run(entry.description, func(_ *testing.T) {
-			// create fake factory
-			fakeFactory := createDefaultFakeFactoryForListMachines(g,
-				testResourceGroupName,
-				clusterState,
-				entry.apiBehaviorSpec,
-			)
-			// Create machine and machine class to be used to create DeleteMachineRequest
+			// initialize cluster state
+			// ----------------------------------------------------------------------------
+			clusterState := fakes.NewClusterState(providerSpec)
+			if entry.vmExists {
+				clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
+				if entry.provisioningState != "" {
+					g.Expect(clusterState.SetVirtualMachineProvisioningState(vmName, entry.provisioningState)).To(BeTrue())
+				}
+				if entry.powerState != "" {
+					g.Expect(clusterState.SetVirtualMachinePowerState(vmName, entry.powerState)).To(BeTrue())
+				}
+			}
+			// create fake factory and initialize vmAccess only
+			fakeFactory := fakes.NewFactory(testResourceGroupName)
+			vmAccess, err := fakeFactory.NewVirtualMachineAccessBuilder().WithClusterState(clusterState).WithAPIBehaviorSpec(entry.vmAccessAPIBehavior).Build()
+			g.Expect(err).To(BeNil())
+			fakeFactory.WithVirtualMachineAccess(vmAccess)
+
 			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
 			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{
+				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			}
 
 			// Test
 			// ----------------------------------------------------------------------------
 			testDriver := NewDefaultDriver(fakeFactory)
-			_, err = testDriver.ListMachines(ctx, &driver.ListMachinesRequest{
+			resp, err := testDriver.InitializeMachine(ctx, &driver.InitializeMachineRequest{
+				Machine:      machine,
 				MachineClass: machineClass,
 				Secret:       fakes.CreateProviderSecret(),
 			})
-			g.Expect(err).ToNot(BeNil())
-			checkError(g, err, testInternalServerError)
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+			} else {
+				g.Expect(err).To(BeNil())
+				g.Expect(resp.NodeName).To(Equal(vmName))
+				g.Expect(resp.ProviderID).To(Equal(helpers.DeriveInstanceID(providerSpec.Location, vmName)))
+			}
+			if entry.expectedPowerState != "" {
+				g.Expect(clusterState.MachineResourcesMap[vmName].PowerState).To(Equal(entry.expectedPowerState))
+			}
 		})
 	}
 }
 
-func TestGetVolumeIDs(t *testing.T) {
+func TestUpdateMachine(t *testing.T) {
+	const (
+		vmName     = "vm-0"
+		identityID = "/subscriptions/test-subscription/resourceGroups/test-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/test-identity"
+	)
+
 	table := []struct {
-		description                     string
-		existingAzureDiskVolSourceNames []string
-		existingAzureCSIVolHandles      []string
-		existingNonAzureCSIVolHandles   []string
-		expectedVolumeIDs               []string
+		description       string
+		vmExists          bool
+		vmInTerminalState bool
+		numDataDisks      int
+		mutateSpecFn      func(spec *api.AzureProviderSpec)
+		expectedErrCode   *codes.Code
+		expectedUpdated   bool
+		checkVMFn         func(g *WithT, machineResources fakes.MachineResources)
 	}{
-		{"should return empty volumeIDs when no pv exist", nil, nil, nil, []string{}},
-		{"should return empty volumeIDS when only non-csi vol sources are defined", nil, nil, []string{"non-az-csi-vol-1", "non-az-csi-vol-2"}, []string{}},
-		{"should return azure disk vol sources when defined", []string{"az-disk-1", "az-disk-2"}, nil, []string{"non-az-csi-vol-1"}, []string{"az-disk-1", "az-disk-2"}},
-		{"should return azure csi vol sources when defined", nil, []string{"az-csi-vol-1", "az-csi-vol-2"}, []string{"non-az-csi-vol-1"}, []string{"az-csi-vol-1", "az-csi-vol-2"}},
-		{"should return azure disk and csi vol sources when defined", []string{"az-disk-1", "az-disk-2"}, []string{"az-csi-vol-1", "az-csi-vol-2"}, []string{"non-az-csi-vol-1"}, []string{"az-disk-1", "az-disk-2", "az-csi-vol-1", "az-csi-vol-2"}},
+		{"should not update the VM if nothing has changed", true, false, 1, nil, nil, false, nil},
+		{
+			"should update the tags of the VM, its NIC and its disks", true, false, 1,
+			func(spec *api.AzureProviderSpec) { spec.Tags["new-tag"] = "new-value" }, nil, true,
+			func(g *WithT, machineResources fakes.MachineResources) {
+				g.Expect(*machineResources.VM.Tags["new-tag"]).To(Equal("new-value"))
+				g.Expect(*machineResources.VM.Tags[utils.MachineNameTagKey]).To(Equal(vmName))
+				g.Expect(*machineResources.NIC.Tags["new-tag"]).To(Equal("new-value"))
+				g.Expect(*machineResources.OSDisk.Tags["new-tag"]).To(Equal("new-value"))
+				g.Expect(*machineResources.OSDisk.Tags[utils.MachineNameTagKey]).To(Equal(vmName))
+				for _, dataDisk := range machineResources.DataDisks {
+					g.Expect(*dataDisk.Tags["new-tag"]).To(Equal("new-value"))
+				}
+			},
+		},
+		{
+			"should set the identity of the VM", true, false, 1,
+			func(spec *api.AzureProviderSpec) { spec.Properties.IdentityID = to.Ptr(identityID) }, nil, true,
+			func(g *WithT, machineResources fakes.MachineResources) {
+				g.Expect(machineResources.VM.Identity).ToNot(BeNil())
+				g.Expect(machineResources.VM.Identity.UserAssignedIdentities).To(HaveKey(identityID))
+			},
+		},
+		{
+			"should add a new data disk to the VM", true, false, 1,
+			func(spec *api.AzureProviderSpec) {
+				spec.Properties.StorageProfile.DataDisks = testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDataDisks(testDataDiskName, 2).Build().Properties.StorageProfile.DataDisks
+			}, nil, true,
+			func(g *WithT, machineResources fakes.MachineResources) {
+				g.Expect(machineResources.VM.Properties.StorageProfile.DataDisks).To(HaveLen(2))
+				g.Expect(machineResources.DataDisks).To(HaveKey(utils.CreateDataDiskName(vmName, testDataDiskName, 1)))
+			},
+		},
+		{"should return NotFound for a non-existing VM", false, false, 1, nil, to.Ptr(codes.NotFound), false, nil},
+		{
+			"should return FailedPrecondition for a VM in terminal state", true, true, 1,
+			func(spec *api.AzureProviderSpec) { spec.Tags["new-tag"] = "new-value" }, to.Ptr(codes.FailedPrecondition), false, nil,
+		},
+		{
+			"should return FailedPrecondition if the LUN of a new data disk is already used", true, false, 1,
+			func(spec *api.AzureProviderSpec) { spec.Properties.StorageProfile.DataDisks[0].Name = "other-disk" }, to.Ptr(codes.FailedPrecondition), false, nil,
+		},
 	}
 
 	g := NewWithT(t)
 	ctx := context.Background()
+
 	for _, entry := range table {
 		t.Run(entry.description, func(_ *testing.T) {
-			var pvSpecs []*corev1.PersistentVolumeSpec
-			for _, diskVolSrcName := range entry.existingAzureDiskVolSourceNames {
-				pvSpec := &corev1.PersistentVolumeSpec{
-					PersistentVolumeSource: fakes.CreateAzureDiskPVSource(testResourceGroupName, diskVolSrcName),
+			// initialize cluster state
+			// ----------------------------------------------------------------------------
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks(testDataDiskName, entry.numDataDisks).Build()
+			clusterState := fakes.NewClusterState(providerSpec)
+			if entry.vmExists {
+				clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
+				if entry.vmInTerminalState {
+					clusterState.MarkVirtualMachineInTerminalState(vmName)
 				}
-				pvSpecs = append(pvSpecs, pvSpec)
 			}
-			for _, azCSIVolHandle := range entry.existingAzureCSIVolHandles {
-				pvSpec := &corev1.PersistentVolumeSpec{
-					PersistentVolumeSource: fakes.CreateCSIPVSource(utils.AzureCSIDriverName, azCSIVolHandle),
-				}
-				pvSpecs = append(pvSpecs, pvSpec)
+			fakeFactory := createFakeFactoryForUpdateMachine(g, clusterState, nil)
+
+			// the machine class is created from the changed provider spec
+			updatedProviderSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks(testDataDiskName, entry.numDataDisks).Build()
+			if entry.mutateSpecFn != nil {
+				entry.mutateSpecFn(&updatedProviderSpec)
 			}
-			for _, nonAzCSIVolHandle := range entry.existingNonAzureCSIVolHandles {
-				pvSpec := &corev1.PersistentVolumeSpec{
-					PersistentVolumeSource: fakes.CreateCSIPVSource("test-non-az-driver", nonAzCSIVolHandle),
-				}
-				pvSpecs = append(pvSpecs, pvSpec)
+			machineClass, err := fakes.CreateMachineClass(updatedProviderSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{
+				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			}
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory).(MachineUpdater)
+			resp, err := testDriver.UpdateMachine(ctx, &UpdateMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				return
 			}
-			testDriver := NewDefaultDriver(fakes.NewFactory(testResourceGroupName))
-			resp, err := testDriver.GetVolumeIDs(ctx, &driver.GetVolumeIDsRequest{PVSpecs: pvSpecs})
 			g.Expect(err).To(BeNil())
-			g.Expect(fakes.ActualSliceEqualsExpectedSlice(resp.VolumeIDs, entry.expectedVolumeIDs))
+			g.Expect(resp.Updated).To(Equal(entry.expectedUpdated))
+			if entry.checkVMFn != nil {
+				entry.checkVMFn(g, clusterState.MachineResourcesMap[vmName])
+			}
 		})
 	}
 }
 
-// TestCreateMachineWhenPrerequisitesFail tests all cases where one or more Azure API calls made to get prerequisite
-// resources fail. Prerequisites consist of the following activities:
-// 1. Get Subnet
-// 2. Get VM Image
-// If VM Image is a marketplace image then also do the following:
-// 3. Get AgreementTerms
-// 4. If not accepted then accept and update AgreementTerms
-// If any of the above-mentioned prerequisites fail, then it should not result in creation of any resources for the machine.
-func TestCreateMachineWhenPrerequisitesFail(t *testing.T) {
+func TestUpdateMachineRetainsVMTags(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
+	// tags which have been added outside of MCM
+	vm := clusterState.MachineResourcesMap[vmName].VM
+	vm.Tags[utils.ProtectFromDeletionTagKey] = to.Ptr("true")
+	vm.Tags["external-tag"] = to.Ptr("external-value")
+	fakeFactory := createFakeFactoryForUpdateMachine(g, clusterState, nil)
+
+	// the provider spec adds a tag and would reset the protection from deletion
+	updatedProviderSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	updatedProviderSpec.Tags["new-tag"] = "new-value"
+	updatedProviderSpec.Tags[utils.ProtectFromDeletionTagKey] = "false"
+	machineClass, err := fakes.CreateMachineClass(updatedProviderSpec, to.Ptr(testResourceGroupName))
+	g.Expect(err).To(BeNil())
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory).(MachineUpdater)
+	resp, err := testDriver.UpdateMachine(ctx, &UpdateMachineRequest{
+		Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	g.Expect(resp.Updated).To(BeTrue())
+	vmTags := clusterState.MachineResourcesMap[vmName].VM.Tags
+	g.Expect(vmTags).To(HaveKeyWithValue("new-tag", to.Ptr("new-value")))
+	g.Expect(vmTags).To(HaveKeyWithValue("external-tag", to.Ptr("external-value")))
+	g.Expect(vmTags).To(HaveKeyWithValue(utils.ProtectFromDeletionTagKey, to.Ptr("true")))
+	g.Expect(vmTags).To(HaveKeyWithValue(utils.MachineNameTagKey, to.Ptr(vmName)))
+}
+
+func TestUpdateMachineWithVMResize(t *testing.T) {
 	const (
-		vmName                = "vm-0"
-		internalServerErrCode = "test-error-code"
+		vmName    = "vm-0"
+		newVMSize = "Standard_D4s_v5"
 	)
-	subnetName := fakes.CreateSubnetName(testShootNs)
-	vnetName := testShootNs
-	internalServerErr := testhelp.InternalServerError(internalServerErrCode)
+	testInternalServerError := testhelp.InternalServerError("test-error-code")
+
 	table := []struct {
-		description                        string
-		subnetName                         string
-		vnetName                           string
-		subnetResourceGroup                *string // If specified then this resource-group will be used to create a subnet resource in ClusterState, else resource group at the providerSpec will be used.
-		providerSpecVnetResourceGroup      *string // If specified this will be used to set the vnet resource group in provider spec, else resource group at the providerSpec will be used.
-		subnetExists                       bool
-		vmImageExists                      bool
-		agreementExists                    bool
-		agreementAccepted                  bool
-		vmAccessAPIBehavior                *fakes.APIBehaviorSpec
-		subnetAccessAPIBehavior            *fakes.APIBehaviorSpec
-		vmImageAccessAPIBehavior           *fakes.APIBehaviorSpec
-		mktPlaceAgreementAccessAPIBehavior *fakes.APIBehaviorSpec
-		checkErrorFn                       func(g *WithT, clusterState *fakes.ClusterState, err error)
+		description         string
+		allowVMResize       bool
+		vmAccessAPIBehavior *fakes.APIBehaviorSpec
+		expectedErrCode     *codes.Code
+		expectedVMSize      string
 	}{
+		{"should resize the VM when resizing is allowed", true, nil, nil, newVMSize},
+		{"should not resize the VM when resizing is not allowed", false, nil, to.Ptr(codes.FailedPrecondition), testhelp.VMSize},
 		{
-			"should fail machine creation when no subnet with given name exists, no resources should be created", subnetName, vnetName, nil, nil,
-			false, true, true, true,
-			nil, nil, nil, nil,
-			func(g *WithT, clusterState *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.NotFound)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusNotFound))
-				g.Expect(azRespErr.ErrorCode).To(Equal(testhelp.ErrorCodeSubnetNotFound))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
-				g.Expect(fakes.IsSubnetURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, fakes.SubnetSpec{
-					ResourceGroup: clusterState.ProviderSpec.ResourceGroup,
-					SubnetName:    subnetName,
-					VnetName:      vnetName,
-				})).To(BeTrue())
-			},
-		},
-		{
-			"should fail machine creation when subnet GET fails, no resources should be created", subnetName, vnetName, nil, nil,
-			true, true, true, true,
-			nil, fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.SubnetResourceType, testhelp.AccessMethodGet, internalServerErr), nil, nil,
-			func(g *WithT, clusterState *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
-				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
-				g.Expect(fakes.IsSubnetURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, *clusterState.SubnetSpec)).To(BeTrue())
-			},
+			"should not resize the VM when the deallocation fails", true,
+			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginDeallocate, testInternalServerError),
+			to.Ptr(codes.Internal), testhelp.VMSize,
 		},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
+			fakeFactory := createFakeFactoryForUpdateMachine(g, clusterState, entry.vmAccessAPIBehavior)
+
+			updatedProviderSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			updatedProviderSpec.Properties.HardwareProfile.VMSize = newVMSize
+			machineClass, err := fakes.CreateMachineClass(updatedProviderSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{
+				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			}
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory).(MachineUpdater)
+			resp, err := testDriver.UpdateMachine(ctx, &UpdateMachineRequest{
+				Machine:       machine,
+				MachineClass:  machineClass,
+				Secret:        fakes.CreateProviderSecret(),
+				AllowVMResize: entry.allowVMResize,
+			})
+			machineResources := clusterState.MachineResourcesMap[vmName]
+			g.Expect(string(*machineResources.VM.Properties.HardwareProfile.VMSize)).To(Equal(entry.expectedVMSize))
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				return
+			}
+			g.Expect(err).To(BeNil())
+			g.Expect(resp.Updated).To(BeTrue())
+			g.Expect(machineResources.PowerState).To(Equal(utils.PowerStateRunning))
+		})
+	}
+}
+
+func TestUpdateMachineStartsVMAfterFailedStart(t *testing.T) {
+	const (
+		vmName    = "vm-0"
+		newVMSize = "Standard_D4s_v5"
+	)
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
+	// the start of the VM after the resize fails once, the retry succeeds.
+	vmAccessAPIBehavior := fakes.NewAPIBehaviorSpec().AddIntermittentErrorResourceReaction(vmName, testhelp.AccessMethodBeginStart, testhelp.InternalServerError("test-error-code"), 1)
+	fakeFactory := createFakeFactoryForUpdateMachine(g, clusterState, vmAccessAPIBehavior)
+
+	updatedProviderSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	updatedProviderSpec.Properties.HardwareProfile.VMSize = newVMSize
+	machineClass, err := fakes.CreateMachineClass(updatedProviderSpec, to.Ptr(testResourceGroupName))
+	g.Expect(err).To(BeNil())
+	req := &UpdateMachineRequest{
+		Machine:       &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+		MachineClass:  machineClass,
+		Secret:        fakes.CreateProviderSecret(),
+		AllowVMResize: true,
+	}
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory).(MachineUpdater)
+	resp, err := testDriver.UpdateMachine(ctx, req)
+	var statusErr *status.Status
+	g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+	g.Expect(statusErr.Code()).To(Equal(codes.Internal))
+	machineResources := clusterState.MachineResourcesMap[vmName]
+	g.Expect(string(*machineResources.VM.Properties.HardwareProfile.VMSize)).To(Equal(newVMSize))
+	g.Expect(machineResources.PowerState).To(Equal(utils.PowerStateDeallocated))
+	lastKnownState, err := helpers.DecodeLastKnownState(resp.LastKnownState)
+	g.Expect(err).To(BeNil())
+	g.Expect(lastKnownState.DeallocatedForResize).To(BeTrue())
+
+	// the VM size already matches on the retry, the VM is started nevertheless since it has been deallocated for the resize.
+	req.Machine.Status.LastKnownState = resp.LastKnownState
+	resp, err = testDriver.UpdateMachine(ctx, req)
+	g.Expect(err).To(BeNil())
+	g.Expect(resp.Updated).To(BeTrue())
+	g.Expect(clusterState.MachineResourcesMap[vmName].PowerState).To(Equal(utils.PowerStateRunning))
+	lastKnownState, err = helpers.DecodeLastKnownState(resp.LastKnownState)
+	g.Expect(err).To(BeNil())
+	g.Expect(lastKnownState.DeallocatedForResize).To(BeFalse())
+}
+
+func TestUpdateMachineDoesNotStartStoppedVM(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	for _, powerState := range []string{utils.PowerStateStopped, utils.PowerStateDeallocated} {
+		t.Run(powerState, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildAllResources())
+			// the VM has been stopped on purpose, e.g. by an operator or for hibernation
+			g.Expect(clusterState.SetVirtualMachinePowerState(vmName, powerState)).To(BeTrue())
+			fakeFactory := createFakeFactoryForUpdateMachine(g, clusterState, nil)
+
+			updatedProviderSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			updatedProviderSpec.Tags["new-tag"] = "new-value"
+			machineClass, err := fakes.CreateMachineClass(updatedProviderSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory).(MachineUpdater)
+			resp, err := testDriver.UpdateMachine(ctx, &UpdateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			g.Expect(resp.Updated).To(BeTrue())
+			machineResources := clusterState.MachineResourcesMap[vmName]
+			g.Expect(machineResources.VM.Tags).To(HaveKeyWithValue("new-tag", to.Ptr("new-value")))
+			g.Expect(machineResources.PowerState).To(Equal(powerState))
+		})
+	}
+}
+
+func TestListMachines(t *testing.T) {
+	type machineResourcesTestSpec struct {
+		vmName          string
+		vmPresent       bool
+		osDiskPresent   bool
+		dataDiskPresent bool
+		nicPresent      bool
+		// if present this will overwrite the tags that are borrowed from provider spec for this machine resource VM. Only specify this when vmPresent is true else it will never reflect.
+		vmTags map[string]string
+		// if present this will overwrite the tags that are borrowed from provider spec for this machine resource NIC. Only specify this when nicPresent is true else it will never reflect.
+		nicTags map[string]string
+		// if present this will overwrite the tags that are borrowed from provider spec for this machine resource OSDisk. Only specify this when nicPresent is true else it will never reflect.
+		osDiskTags map[string]string
+		// if present this will overwrite the tags that are borrowed from provider spec for this machine resource DataDisks. Only specify this when nicPresent is true else it will never reflect.
+		dataDiskTags map[string]string
+	}
+
+	const nonMatchingShootNs = "non-matching-shoot-ns"
+
+	nonMatchingTags := map[string]string{
+		"kubernetes.io-cluster-" + nonMatchingShootNs: "1",
+		"kubernetes.io-role-node":                     "1",
+	}
+
+	table := []struct {
+		description     string
+		mrTestSpecs     []machineResourcesTestSpec
+		apiBehaviorSpec *fakes.APIBehaviorSpec
+		numDataDisks    int
+		expectedResult  []string
+		expectedErr     bool
+	}{
 		{
-			"should fail machine creation when resource group for subnet does not exist, no resources should be created", subnetName, vnetName, to.Ptr("vnet-rg"), to.Ptr("provider-spec-vnet-rg"),
-			true, true, true, true,
-			nil, nil, nil, nil,
-			func(g *WithT, clusterState *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.NotFound)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusNotFound))
-				g.Expect(azRespErr.ErrorCode).To(Equal(testhelp.ErrorCodeResourceGroupNotFound))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
-				subnetSpec := *clusterState.SubnetSpec
-				subnetSpec.ResourceGroup = "provider-spec-vnet-rg"
-				g.Expect(fakes.IsSubnetURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, subnetSpec)).To(BeTrue())
-			},
+			"should return no result if no resources exist", nil, nil, 0, []string{}, false,
 		},
 		{
-			"should fail machine creation when VM Image is not found, no resources should be created", subnetName, vnetName, nil, nil,
-			true, false, false, false,
-			nil, nil, nil, nil,
-			func(g *WithT, clusterState *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.NotFound)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusNotFound))
-				g.Expect(azRespErr.ErrorCode).To(Equal(testhelp.ErrorCodeVMImageNotFound))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
-				publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
-				g.Expect(fakes.IsVMImageURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.Location, fakes.VMImageSpec{
-					Publisher: publisher,
-					Offer:     offer,
-					SKU:       sku,
-					Version:   version,
-				})).To(BeTrue())
-			},
+			"should return all vm names where vms exist",
+			[]machineResourcesTestSpec{
+				{"vm-0", true, true, true, true, nil, nil, nil, nil},
+				{"vm-1", true, true, true, true, nil, nil, nil, nil},
+			}, nil, 1, []string{"vm-0", "vm-1"}, false,
 		},
 		{
-			"should fail machine creation when VM Image GET fails, no resources should be created", subnetName, vnetName, nil, nil,
-			true, true, true, true,
-			nil, nil, fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.VMImageResourceType, testhelp.AccessMethodGet, internalServerErr), nil,
-			func(g *WithT, clusterState *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
-				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
-				publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
-				g.Expect(fakes.IsVMImageURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.Location, fakes.VMImageSpec{
-					Publisher: publisher,
-					Offer:     offer,
-					SKU:       sku,
-					Version:   version,
-				})).To(BeTrue())
-			},
+			"should return vm names only for vms where vm does not exist but a nic exists",
+			[]machineResourcesTestSpec{
+				{"vm-0", false, false, false, false, nil, nil, nil, nil},
+				{"vm-1", false, false, true, false, nil, nil, nil, nil},
+			}, nil, 1, []string{"vm-1"}, false,
 		},
 		{
-			"should fail machine creation when there is no agreement for the VM Image, no resources should be created", subnetName, vnetName, nil, nil,
-			true, true, false, false,
-			nil, nil, nil, nil,
-			func(g *WithT, _ *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.NotFound)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusBadRequest))
-				g.Expect(azRespErr.ErrorCode).To(Equal(testhelp.ErrorCodeBadRequest))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
-				publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
-				g.Expect(fakes.IsMktPlaceAgreementURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, fakes.VMImageSpec{
-					Publisher: publisher,
-					Offer:     offer,
-					SKU:       sku,
-					Version:   version,
-				})).To(BeTrue())
-			},
+			"should return vm names only for vms where vm does not exist but an OSDisk exists",
+			[]machineResourcesTestSpec{
+				{"vm-0", false, false, false, false, nil, nil, nil, nil},
+				{"vm-1", false, true, false, false, nil, nil, nil, nil},
+			}, nil, 2, []string{"vm-1"}, false,
 		},
 		{
-			"should fail machine creation when update of agreement for VM Image fails, no resources should be created", subnetName, vnetName, nil, nil,
-			true, true, true, false,
-			nil, nil, nil, fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.MarketPlaceOrderingOfferType, testhelp.AccessMethodCreate, testhelp.InternalServerError("test-error-code")),
-			func(g *WithT, _ *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
-				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodPut))
-				publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
-				g.Expect(fakes.IsMktPlaceAgreementURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, fakes.VMImageSpec{
-					Publisher: publisher,
-					Offer:     offer,
-					SKU:       sku,
-					Version:   version,
-				})).To(BeTrue())
-			},
+			"should only return vms matching mandatory provider spec tags",
+			[]machineResourcesTestSpec{
+				{"vm-0", true, true, true, true, nonMatchingTags, nonMatchingTags, nonMatchingTags, nonMatchingTags},
+				{"vm-1", true, true, true, true, nil, nil, nil, nil},
+				{"vm-2", true, true, true, false, nonMatchingTags, nonMatchingTags, nonMatchingTags, nonMatchingTags},
+				{"vm-3", true, true, true, false, nil, nil, nil, nil},
+				{"vm-4", true, true, true, true, nonMatchingTags, nil, nonMatchingTags, nil},
+			}, nil, 1, []string{"vm-1", "vm-3", "vm-4"}, false,
 		},
 	}
 
-	g := NewWithT(t)
-	ctx := context.Background()
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			// create provider spec
+			providerSpecBuilder := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues()
+			if entry.numDataDisks > 0 {
+				// Add data disks
+				providerSpecBuilder.WithDataDisks(testDataDiskName, entry.numDataDisks)
+			}
+			providerSpec := providerSpecBuilder.Build()
+			// initialize cluster state
+			// ----------------------------------------------------------------------------
+			// create cluster state
+			clusterState := fakes.NewClusterState(providerSpec)
+			if entry.mrTestSpecs != nil {
+				for _, mrTestSpec := range entry.mrTestSpecs {
+					var testVMID *string
+					if !mrTestSpec.vmPresent {
+						testVMID = to.Ptr(fakes.CreateVirtualMachineID(testhelp.SubscriptionID, testResourceGroupName, mrTestSpec.vmName))
+					}
+					mr := fakes.NewMachineResourcesBuilder(providerSpec, mrTestSpec.vmName).BuildWith(mrTestSpec.vmPresent, mrTestSpec.nicPresent, mrTestSpec.osDiskPresent, mrTestSpec.dataDiskPresent, testVMID)
+					if mrTestSpec.vmPresent && mrTestSpec.vmTags != nil {
+						mr.VM.Tags = utils.CreateResourceTags(mrTestSpec.vmTags)
+					}
+					if mrTestSpec.nicPresent && mrTestSpec.nicTags != nil {
+						mr.NIC.Tags = utils.CreateResourceTags(mrTestSpec.nicTags)
+					}
+					if mrTestSpec.osDiskPresent && mrTestSpec.osDiskTags != nil {
+						mr.OSDisk.Tags = utils.CreateResourceTags(mrTestSpec.osDiskTags)
+					}
+					if mrTestSpec.dataDiskPresent && mrTestSpec.dataDiskTags != nil {
+						for _, dataDisk := range mr.DataDisks {
+							dataDisk.Tags = utils.CreateResourceTags(mrTestSpec.dataDiskTags)
+						}
+					}
+					clusterState.AddMachineResources(mr)
+				}
+			}
+
+			// create fake factory
+			fakeFactory := createDefaultFakeFactoryForListMachines(g, testResourceGroupName, clusterState, nil)
+
+			// Create machine and machine class to be used to create DeleteMachineRequest
+			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			listMachinesResp, err := testDriver.ListMachines(ctx, &driver.ListMachinesRequest{
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err != nil).To(Equal(entry.expectedErr))
+			actualVMNames := getVMNamesFromListMachineResponse(listMachinesResp)
+			g.Expect(fakes.ActualSliceEqualsExpectedSlice(actualVMNames, entry.expectedResult)).To(BeTrue())
+		})
+	}
+}
+
+func TestListMachinesWithOSDiskNameTemplate(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.StorageProfile.OsDisk.NameTemplate = to.Ptr("root-{vmName}")
+	clusterState := fakes.NewClusterState(providerSpec)
+	// only the OSDisk of vm-0 is left, its name does not follow the default naming convention
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, "vm-0").BuildWith(false, false, true, false, to.Ptr(fakes.CreateVirtualMachineID(testhelp.SubscriptionID, testResourceGroupName, "vm-0"))))
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, "vm-1").BuildWith(true, true, true, false, nil))
+	g.Expect(clusterState.GetDisk("root-vm-0")).ToNot(BeNil())
+
+	fakeFactory := createDefaultFakeFactoryForListMachines(g, testResourceGroupName, clusterState, nil)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+	g.Expect(err).To(BeNil())
+
+	testDriver := NewDefaultDriver(fakeFactory)
+	listMachinesResp, err := testDriver.ListMachines(ctx, &driver.ListMachinesRequest{
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	actualVMNames := getVMNamesFromListMachineResponse(listMachinesResp)
+	g.Expect(fakes.ActualSliceEqualsExpectedSlice(actualVMNames, []string{"vm-0", "vm-1"})).To(BeTrue())
+}
+
+func TestListMachinesWithDataDiskNameTemplate(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks(testDataDiskName, 2).Build()
+	providerSpec.Properties.StorageProfile.DataDiskNameTemplate = to.Ptr("{vmName}-{name}-{lun}")
+	clusterState := fakes.NewClusterState(providerSpec)
+	// only the data disks of vm-0 are left, their names do not follow the default naming convention
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, "vm-0").BuildWith(false, false, false, true, to.Ptr(fakes.CreateVirtualMachineID(testhelp.SubscriptionID, testResourceGroupName, "vm-0"))))
+	g.Expect(clusterState.GetDisk(fmt.Sprintf("vm-0-%s-1", testDataDiskName))).ToNot(BeNil())
+
+	fakeFactory := createDefaultFakeFactoryForListMachines(g, testResourceGroupName, clusterState, nil)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+	g.Expect(err).To(BeNil())
+
+	testDriver := NewDefaultDriver(fakeFactory)
+	listMachinesResp, err := testDriver.ListMachines(ctx, &driver.ListMachinesRequest{
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	actualVMNames := getVMNamesFromListMachineResponse(listMachinesResp)
+	g.Expect(fakes.ActualSliceEqualsExpectedSlice(actualVMNames, []string{"vm-0"})).To(BeTrue())
+}
+
+func TestListMachineWithInducedErrors(t *testing.T) {
+	const (
+		vmName        = "test-vm-0"
+		testErrorCode = "test-error-code"
+	)
+	testInternalServerError := testhelp.InternalServerError(testErrorCode)
+
+	table := []struct {
+		description     string
+		apiBehaviorSpec *fakes.APIBehaviorSpec
+	}{
+		{
+			"should fail listing machines when resource-graph query for VM resource type returns error",
+			fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.VirtualMachinesResourceType, testhelp.AccessMethodResources, testInternalServerError),
+		},
+		{
+			"should fail listing machines when resource-graph query for NIC resource type returns error",
+			fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.NetworkInterfacesResourceType, testhelp.AccessMethodResources, testInternalServerError),
+		},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	// create provider spec
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildWith(true, true, true, false, nil))
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			// create fake factory
+			fakeFactory := createDefaultFakeFactoryForListMachines(g,
+				testResourceGroupName,
+				clusterState,
+				entry.apiBehaviorSpec,
+			)
+			// Create machine and machine class to be used to create DeleteMachineRequest
+			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.ListMachines(ctx, &driver.ListMachinesRequest{
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).ToNot(BeNil())
+			checkError(g, err, testInternalServerError)
+		})
+	}
+}
+
+func TestGetVolumeIDs(t *testing.T) {
+	table := []struct {
+		description                     string
+		existingAzureDiskVolSourceNames []string
+		existingAzureCSIVolHandles      []string
+		existingNonAzureCSIVolHandles   []string
+		expectedVolumeIDs               []string
+	}{
+		{"should return empty volumeIDs when no pv exist", nil, nil, nil, []string{}},
+		{"should return empty volumeIDS when only non-csi vol sources are defined", nil, nil, []string{"non-az-csi-vol-1", "non-az-csi-vol-2"}, []string{}},
+		{"should return azure disk vol sources when defined", []string{"az-disk-1", "az-disk-2"}, nil, []string{"non-az-csi-vol-1"}, []string{"az-disk-1", "az-disk-2"}},
+		{"should return azure csi vol sources when defined", nil, []string{"az-csi-vol-1", "az-csi-vol-2"}, []string{"non-az-csi-vol-1"}, []string{"az-csi-vol-1", "az-csi-vol-2"}},
+		{"should return azure disk and csi vol sources when defined", []string{"az-disk-1", "az-disk-2"}, []string{"az-csi-vol-1", "az-csi-vol-2"}, []string{"non-az-csi-vol-1"}, []string{"az-disk-1", "az-disk-2", "az-csi-vol-1", "az-csi-vol-2"}},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			var pvSpecs []*corev1.PersistentVolumeSpec
+			for _, diskVolSrcName := range entry.existingAzureDiskVolSourceNames {
+				pvSpec := &corev1.PersistentVolumeSpec{
+					PersistentVolumeSource: fakes.CreateAzureDiskPVSource(testResourceGroupName, diskVolSrcName),
+				}
+				pvSpecs = append(pvSpecs, pvSpec)
+			}
+			for _, azCSIVolHandle := range entry.existingAzureCSIVolHandles {
+				pvSpec := &corev1.PersistentVolumeSpec{
+					PersistentVolumeSource: fakes.CreateCSIPVSource(utils.AzureCSIDriverName, azCSIVolHandle),
+				}
+				pvSpecs = append(pvSpecs, pvSpec)
+			}
+			for _, nonAzCSIVolHandle := range entry.existingNonAzureCSIVolHandles {
+				pvSpec := &corev1.PersistentVolumeSpec{
+					PersistentVolumeSource: fakes.CreateCSIPVSource("test-non-az-driver", nonAzCSIVolHandle),
+				}
+				pvSpecs = append(pvSpecs, pvSpec)
+			}
+			testDriver := NewDefaultDriver(fakes.NewFactory(testResourceGroupName))
+			resp, err := testDriver.GetVolumeIDs(ctx, &driver.GetVolumeIDsRequest{PVSpecs: pvSpecs})
+			g.Expect(err).To(BeNil())
+			g.Expect(fakes.ActualSliceEqualsExpectedSlice(resp.VolumeIDs, entry.expectedVolumeIDs))
+		})
+	}
+}
+
+// TestCreateMachineWhenPrerequisitesFail tests all cases where one or more Azure API calls made to get prerequisite
+// resources fail. Prerequisites consist of the following activities:
+// 1. Get Subnet
+// 2. Get VM Image
+// If VM Image is a marketplace image then also do the following:
+// 3. Get AgreementTerms
+// 4. If not accepted then accept and update AgreementTerms
+// If any of the above-mentioned prerequisites fail, then it should not result in creation of any resources for the machine.
+func TestCreateMachineWhenPrerequisitesFail(t *testing.T) {
+	const (
+		vmName                = "vm-0"
+		internalServerErrCode = "test-error-code"
+	)
+	subnetName := fakes.CreateSubnetName(testShootNs)
+	vnetName := testShootNs
+	internalServerErr := testhelp.InternalServerError(internalServerErrCode)
+	table := []struct {
+		description                        string
+		subnetName                         string
+		vnetName                           string
+		subnetResourceGroup                *string // If specified then this resource-group will be used to create a subnet resource in ClusterState, else resource group at the providerSpec will be used.
+		providerSpecVnetResourceGroup      *string // If specified this will be used to set the vnet resource group in provider spec, else resource group at the providerSpec will be used.
+		subnetExists                       bool
+		vmImageExists                      bool
+		agreementExists                    bool
+		agreementAccepted                  bool
+		vmAccessAPIBehavior                *fakes.APIBehaviorSpec
+		subnetAccessAPIBehavior            *fakes.APIBehaviorSpec
+		vmImageAccessAPIBehavior           *fakes.APIBehaviorSpec
+		mktPlaceAgreementAccessAPIBehavior *fakes.APIBehaviorSpec
+		checkErrorFn                       func(g *WithT, clusterState *fakes.ClusterState, err error)
+	}{
+		{
+			"should fail machine creation when no subnet with given name exists, no resources should be created", subnetName, vnetName, nil, nil,
+			false, true, true, true,
+			nil, nil, nil, nil,
+			func(g *WithT, clusterState *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.NotFound)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusNotFound))
+				g.Expect(azRespErr.ErrorCode).To(Equal(testhelp.ErrorCodeSubnetNotFound))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
+				g.Expect(fakes.IsSubnetURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, fakes.SubnetSpec{
+					ResourceGroup: clusterState.ProviderSpec.ResourceGroup,
+					SubnetName:    subnetName,
+					VnetName:      vnetName,
+				})).To(BeTrue())
+			},
+		},
+		{
+			"should fail machine creation when subnet GET fails, no resources should be created", subnetName, vnetName, nil, nil,
+			true, true, true, true,
+			nil, fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.SubnetResourceType, testhelp.AccessMethodGet, internalServerErr), nil, nil,
+			func(g *WithT, clusterState *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
+				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
+				g.Expect(fakes.IsSubnetURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, *clusterState.SubnetSpec)).To(BeTrue())
+			},
+		},
+		{
+			"should fail machine creation when resource group for subnet does not exist, no resources should be created", subnetName, vnetName, to.Ptr("vnet-rg"), to.Ptr("provider-spec-vnet-rg"),
+			true, true, true, true,
+			nil, nil, nil, nil,
+			func(g *WithT, clusterState *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.NotFound)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusNotFound))
+				g.Expect(azRespErr.ErrorCode).To(Equal(testhelp.ErrorCodeResourceGroupNotFound))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
+				subnetSpec := *clusterState.SubnetSpec
+				subnetSpec.ResourceGroup = "provider-spec-vnet-rg"
+				g.Expect(fakes.IsSubnetURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, subnetSpec)).To(BeTrue())
+			},
+		},
+		{
+			"should fail machine creation when VM Image is not found, no resources should be created", subnetName, vnetName, nil, nil,
+			true, false, false, false,
+			nil, nil, nil, nil,
+			func(g *WithT, clusterState *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.NotFound)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusNotFound))
+				g.Expect(azRespErr.ErrorCode).To(Equal(testhelp.ErrorCodeVMImageNotFound))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
+				publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
+				g.Expect(fakes.IsVMImageURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.Location, fakes.VMImageSpec{
+					Publisher: publisher,
+					Offer:     offer,
+					SKU:       sku,
+					Version:   version,
+				})).To(BeTrue())
+			},
+		},
+		{
+			"should fail machine creation when VM Image GET fails, no resources should be created", subnetName, vnetName, nil, nil,
+			true, true, true, true,
+			nil, nil, fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.VMImageResourceType, testhelp.AccessMethodGet, internalServerErr), nil,
+			func(g *WithT, clusterState *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
+				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
+				publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
+				g.Expect(fakes.IsVMImageURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.Location, fakes.VMImageSpec{
+					Publisher: publisher,
+					Offer:     offer,
+					SKU:       sku,
+					Version:   version,
+				})).To(BeTrue())
+			},
+		},
+		{
+			"should fail machine creation when there is no agreement for the VM Image, no resources should be created", subnetName, vnetName, nil, nil,
+			true, true, false, false,
+			nil, nil, nil, nil,
+			func(g *WithT, _ *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.NotFound)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusBadRequest))
+				g.Expect(azRespErr.ErrorCode).To(Equal(testhelp.ErrorCodeBadRequest))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
+				publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
+				g.Expect(fakes.IsMktPlaceAgreementURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, fakes.VMImageSpec{
+					Publisher: publisher,
+					Offer:     offer,
+					SKU:       sku,
+					Version:   version,
+				})).To(BeTrue())
+			},
+		},
+		{
+			"should fail machine creation when update of agreement for VM Image fails, no resources should be created", subnetName, vnetName, nil, nil,
+			true, true, true, false,
+			nil, nil, nil, fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.MarketPlaceOrderingOfferType, testhelp.AccessMethodCreate, testhelp.InternalServerError("test-error-code")),
+			func(g *WithT, _ *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
+				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodPut))
+				publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
+				g.Expect(fakes.IsMktPlaceAgreementURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, fakes.VMImageSpec{
+					Publisher: publisher,
+					Offer:     offer,
+					SKU:       sku,
+					Version:   version,
+				})).To(BeTrue())
+			},
+		},
+	}
+
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			// create provider spec
+			providerSpecBuilder := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues()
+			if entry.providerSpecVnetResourceGroup != nil {
+				providerSpecBuilder.WithSubnetInfo(*entry.providerSpecVnetResourceGroup)
+			}
+			providerSpec := providerSpecBuilder.Build()
+
+			// initialize cluster state
+			// ----------------------------------------------------------------------------
+			// create cluster state
+			clusterState := fakes.NewClusterState(providerSpec)
+			if entry.vmImageExists {
+				clusterState.WithDefaultVMImageSpec()
+			}
+			if entry.agreementExists {
+				clusterState.WithAgreementTerms(entry.agreementAccepted)
+			}
+			if entry.subnetExists {
+				vnetResourceGroup := providerSpec.ResourceGroup
+				if entry.subnetResourceGroup != nil {
+					vnetResourceGroup = *entry.subnetResourceGroup
+				}
+				clusterState.WithSubnet(vnetResourceGroup, entry.subnetName, entry.vnetName)
+			}
+			// create fake factory
+			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, entry.vmAccessAPIBehavior, entry.subnetAccessAPIBehavior, nil, entry.vmImageAccessAPIBehavior, entry.mktPlaceAgreementAccessAPIBehavior)
+
+			// Create machine and machine class to be used to create DeleteMachineRequest
+			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{
+				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			}
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			checkClusterStateAndGetMachineResources(ctx, g, *fakeFactory, vmName, false, false, false, nil, false, false)
+			if entry.checkErrorFn != nil {
+				entry.checkErrorFn(g, clusterState, err)
+			}
+		})
+	}
+}
+
+func TestCreateMachineWhenNICOrVMCreationFails(t *testing.T) {
+	const (
+		vmName                = "vm-0"
+		internalServerErrCode = "test-error-code"
+	)
+	nicName := utils.CreateNICName(vmName)
+	internalServerErr := testhelp.InternalServerError(internalServerErrCode)
+	ctx := context.Background()
+
+	table := []struct {
+		description          string
+		nicExists            bool
+		vmAccessAPIBehavior  *fakes.APIBehaviorSpec
+		nicAccessAPIBehavior *fakes.APIBehaviorSpec
+		checkClusterStateFn  func(g *WithT, ctx context.Context, clusterState *fakes.ClusterState, vmName string)
+		checkErrorFn         func(g *WithT, clusterState *fakes.ClusterState, err error)
+	}{
+		{
+			// NIC GET calls does not return error in case it is NotFound
+			"should fail machine creation with NIC GET fails, no resources should be created", true,
+			nil, fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(nicName, testhelp.AccessMethodGet, internalServerErr),
+			func(g *WithT, ctx context.Context, clusterState *fakes.ClusterState, vmName string) {
+				factory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+				checkClusterStateAndGetMachineResources(ctx, g, *factory, vmName, false, true, false, nil, false, false)
+			},
+			func(g *WithT, clusterState *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
+				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
+				g.Expect(fakes.IsNicURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.ResourceGroup, nicName)).To(BeTrue())
+			},
+		},
+		{
+			"should fail machine creation when NIC creation fails, no resources should be created", false,
+			nil, fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(nicName, testhelp.AccessMethodBeginCreateOrUpdate, internalServerErr),
+			func(g *WithT, ctx context.Context, clusterState *fakes.ClusterState, vmName string) {
+				factory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+				checkClusterStateAndGetMachineResources(ctx, g, *factory, vmName, false, false, false, nil, false, false)
+			},
+			func(g *WithT, clusterState *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
+				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodPut))
+				g.Expect(fakes.IsNicURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.ResourceGroup, nicName)).To(BeTrue())
+			},
+		},
+		{
+			"should fail machine creation when VM creation fails, only NIC resource should now exist", false,
+			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginCreateOrUpdate, internalServerErr), nil,
+			func(g *WithT, ctx context.Context, clusterState *fakes.ClusterState, vmName string) {
+				factory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+				checkClusterStateAndGetMachineResources(ctx, g, *factory, vmName, false, true, false, nil, false, false)
+			},
+			func(g *WithT, clusterState *fakes.ClusterState, err error) {
+				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
+				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
+				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
+				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodPut))
+				g.Expect(fakes.IsVMURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.ResourceGroup, vmName)).To(BeTrue())
+			},
+		},
+	}
+
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			// initialize cluster state
+			// ----------------------------------------------------------------------------
+			// create cluster state
+			clusterState := fakes.NewClusterState(providerSpec)
+			if entry.nicExists {
+				clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildWith(false, true, false, false, nil))
+			}
+			clusterState.WithDefaultVMImageSpec().WithAgreementTerms(true).WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			// create fake factory
+			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, entry.vmAccessAPIBehavior, nil, entry.nicAccessAPIBehavior, nil, nil)
+
+			// Create machine and machine class to be used to create DeleteMachineRequest
+			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{
+				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			}
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.checkClusterStateFn != nil {
+				entry.checkClusterStateFn(g, ctx, clusterState, vmName)
+			}
+			if entry.checkErrorFn != nil {
+				entry.checkErrorFn(g, clusterState, err)
+			}
+		})
+	}
+}
+
+func TestSuccessfulCreationOfMachine(t *testing.T) {
+
+	table := []struct {
+		description      string
+		withPurchasePlan bool
+		builder          *testhelp.ProviderSpecBuilder
+
+		vmImageAccess *fakes.APIBehaviorSpec
+	}{
+		{description: "should create machine successfully if purchase plan is present", withPurchasePlan: true},
+		{description: "should create machine successfully if purchase plan is not present", withPurchasePlan: false},
+		{description: "should create machine successfully with security profile", withPurchasePlan: false,
+			builder: testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).
+				WithDefaultValues().
+				WithSecurityProfile(
+					&api.AzureSecurityProfile{
+						SecurityType: ptr.To(string(armcompute.SecurityTypesConfidentialVM)),
+					}).
+				WithStorageProfile(false, ptr.To(string(armcompute.SecurityEncryptionTypesDiskWithVMGuestState))),
+		},
+		{description: "should create machine successfully with skipMarketplaceAgreement", withPurchasePlan: false,
+			builder: testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).
+				WithDefaultValues().
+				WithStorageProfile(true, nil),
+			vmImageAccess: fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.VMImageResourceType, testhelp.AccessMethodGet, fmt.Errorf("not found")),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		providerSpecBuilder := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).
+			WithDefaultValues().
+			WithDataDisks(testDataDiskName, 2)
+		t.Run(entry.description, func(_ *testing.T) {
+			// initialize cluster state
+			// ----------------------------------------------------------------------------
+			// create cluster state
+			if entry.builder != nil {
+				providerSpecBuilder = entry.builder
+			}
+			providerSpec := providerSpecBuilder.Build()
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
+			vmImageSpec := fakes.VMImageSpec{
+				Publisher:  publisher,
+				Offer:      offer,
+				SKU:        sku,
+				Version:    version,
+				PlanExists: entry.withPurchasePlan,
+			}
+			clusterState.WithVMImageSpec(vmImageSpec).WithAgreementTerms(true).WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			// create fake factory
+			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, nil, nil, nil, entry.vmImageAccess, nil)
+			// Create machine and machine class to be used to create DeleteMachineRequest
+			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+			const vmName = "vm-0"
+			g.Expect(err).To(BeNil())
+			ctx := context.Background()
+			machine := &v1alpha1.Machine{
+				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			}
+			dataDiskNames := testhelp.CreateDataDiskNames(vmName, providerSpec)
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			resp, err := testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			checkClusterStateAndGetMachineResources(ctx, g, *fakeFactory, vmName, true, true, true, dataDiskNames, true, true)
+			g.Expect(resp.NodeName).To(Equal(vmName))
+			expectedProviderID := helpers.DeriveInstanceID(providerSpec.Location, vmName)
+			g.Expect(resp.ProviderID).To(Equal(expectedProviderID))
+		})
+	}
+}
+
+func TestCreateMachineWithAcceleratedNetworkingAutoDetection(t *testing.T) {
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description                   string
+		vmSize                        string
+		specAcceleratedNetworking     *bool
+		skuAccelNetworkingCapability  string
+		expectedAcceleratedNetworking *bool
+		expectedErrCode               *codes.Code
+	}{
+		{"should enable accelerated networking when not set and VM size supports it", "Standard_AN_Test_1", nil, "True", to.Ptr(true), nil},
+		{"should disable accelerated networking when not set and VM size does not support it", "Standard_AN_Test_2", nil, "False", to.Ptr(false), nil},
+		{"should keep accelerated networking disabled when explicitly disabled", "Standard_AN_Test_3", to.Ptr(false), "True", to.Ptr(false), nil},
+		{"should fail with InvalidArgument when explicitly enabled but VM size does not support it", "Standard_AN_Test_4", to.Ptr(true), "False", nil, to.Ptr(codes.InvalidArgument)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.NetworkProfile.AcceleratedNetworking = entry.specAcceleratedNetworking
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.vmSize, map[string]string{helpers.AcceleratedNetworkingCapability: entry.skuAccelNetworkingCapability})
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				g.Expect(err).ToNot(BeNil())
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			nic := checkAndGetNIC(ctx, g, *fakeFactory, vmName, true, true)
+			g.Expect(nic.Properties.EnableAcceleratedNetworking).To(Equal(entry.expectedAcceleratedNetworking))
+		})
+	}
+}
+
+func TestCreateMachineWithAuxiliaryModeOnVMSizeWithoutAcceleratedNetworking(t *testing.T) {
+	const vmSize = "Standard_AN_Test_Aux"
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.HardwareProfile.VMSize = vmSize
+	providerSpec.Properties.NetworkProfile.AcceleratedNetworking = nil
+	providerSpec.Properties.NetworkProfile.AuxiliaryMode = to.Ptr(string(armnetwork.NetworkInterfaceAuxiliaryModeAcceleratedConnections))
+	providerSpec.Properties.NetworkProfile.AuxiliarySKU = to.Ptr(string(armnetwork.NetworkInterfaceAuxiliarySKUA2))
+
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.
+		WithDefaultVMImageSpec().
+		WithAgreementTerms(true).
+		WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+		WithVMSizeResourceSKU(vmSize, map[string]string{helpers.AcceleratedNetworkingCapability: "False"})
+	fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+	const vmName = "vm-0"
+	ctx := context.Background()
+
+	testDriver := NewDefaultDriver(fakeFactory)
+	_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+		Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	var statusErr *status.Status
+	g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+	g.Expect(statusErr.Code()).To(Equal(codes.InvalidArgument))
+	g.Expect(statusErr.Message()).To(ContainSubstring("auxiliaryMode"))
+	checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+}
+
+func TestCreateMachineWithHyperVGeneration(t *testing.T) {
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description           string
+		vmSize                string
+		specHyperVGeneration  string
+		skuHyperVGenerations  string
+		imageHyperVGeneration armcompute.HyperVGenerationTypes
+		expectedErrCode       *codes.Code
+	}{
+		{"should create VM when VM size and image support the hyperVGeneration", "Standard_HyperV_Test_1", "V2", "V1,V2", armcompute.HyperVGenerationTypesV2, nil},
+		{"should fail with InvalidArgument when VM size does not support the hyperVGeneration", "Standard_HyperV_Test_2", "V2", "V1", armcompute.HyperVGenerationTypesV2, to.Ptr(codes.InvalidArgument)},
+		{"should fail with InvalidArgument when image does not match the hyperVGeneration", "Standard_HyperV_Test_3", "V2", "V1,V2", armcompute.HyperVGenerationTypesV1, to.Ptr(codes.InvalidArgument)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.StorageProfile.ImageReference.HyperVGeneration = to.Ptr(entry.specHyperVGeneration)
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.vmSize, map[string]string{helpers.AcceleratedNetworkingCapability: "True", helpers.HyperVGenerationsCapability: entry.skuHyperVGenerations})
+			clusterState.VMImageSpec.HyperVGeneration = to.Ptr(entry.imageHyperVGeneration)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+		})
+	}
+}
+
+func TestCreateMachineWithZones(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.Zone = nil
+	providerSpec.Properties.Zones = []int{1, 2, 3}
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.
+		WithDefaultVMImageSpec().
+		WithAgreementTerms(true).
+		WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+	fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory)
+	resp, err := testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+		Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	expectedZone := helpers.SelectZone(providerSpec.Properties.Zones, vmName)
+	vm := clusterState.GetVM(vmName)
+	g.Expect(vm).ToNot(BeNil())
+	g.Expect(vm.Zones).To(Equal([]*string{to.Ptr(strconv.Itoa(*expectedZone))}))
+	lastKnownState, err := helpers.DecodeLastKnownState(resp.LastKnownState)
+	g.Expect(err).To(BeNil())
+	g.Expect(lastKnownState.Zone).To(Equal(expectedZone))
+	g.Expect(lastKnownState.CreatedResources.NICID).ToNot(BeEmpty())
+	g.Expect(lastKnownState.CreatedResources.VMID).To(Equal(*vm.ID))
+	g.Expect(lastKnownState.PendingOperations).To(BeEmpty())
+}
+
+func TestCreateMachineRecordsCreationFailure(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.
+		WithDefaultVMImageSpec().
+		WithAgreementTerms(true).
+		WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+		WithAllocationFailedZones(*providerSpec.Properties.Zone)
+	fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory)
+	resp, err := testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+		Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).ToNot(BeNil())
+	lastKnownState, err := helpers.DecodeLastKnownState(resp.LastKnownState)
+	g.Expect(err).To(BeNil())
+	g.Expect(lastKnownState.CreationFailure).ToNot(BeNil())
+	g.Expect(lastKnownState.CreationFailure.Code).To(Equal(codes.ResourceExhausted.String()))
+	g.Expect(lastKnownState.CreationFailure.Time).ToNot(BeZero())
+}
+
+func TestCreateMachineDeniedByPolicy(t *testing.T) {
+	const vmName = "vm-0"
+	table := []struct {
+		description           string
+		vmAccessAPIBehavior   *fakes.APIBehaviorSpec
+		nicAccessAPIBehavior  *fakes.APIBehaviorSpec
+		expectedVMToBeCreated bool
+	}{
+		{"should fail with InvalidArgument if the NIC creation is denied by a policy", nil,
+			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(utils.CreateNICName(vmName), testhelp.AccessMethodBeginCreateOrUpdate, testhelp.ForbiddenErr(testhelp.ErrorCodeRequestDisallowedByPolicy)), false},
+		{"should fail with InvalidArgument if the VM creation is denied by a policy",
+			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginCreateOrUpdate, testhelp.ForbiddenErr(testhelp.ErrorCodeRequestDisallowedByPolicy)), nil, false},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, entry.vmAccessAPIBehavior, nil, entry.nicAccessAPIBehavior, nil, nil)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).ToNot(BeNil())
+			var statusErr *status.Status
+			g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+			g.Expect(statusErr.Code()).To(Equal(codes.InvalidArgument))
+			g.Expect(clusterState.GetVM(vmName) != nil).To(Equal(entry.expectedVMToBeCreated))
+		})
+	}
+}
+
+func TestCreateMachineWithAlternateZoneOnAllocationFailure(t *testing.T) {
+	table := []struct {
+		description     string
+		zones           []int
+		failAllZones    bool
+		expectedErrCode *codes.Code
+	}{
+		{"should create VM in an alternate zone if the VM could not be allocated in the selected zone", []int{1, 2, 3}, false, nil},
+		{"should fail with ResourceExhausted if the VM could not be allocated in any zone", []int{1, 2, 3}, true, to.Ptr(codes.ResourceExhausted)},
+		{"should fail with ResourceExhausted if there is no alternate zone", []int{1}, false, to.Ptr(codes.ResourceExhausted)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			const vmName = "vm-0"
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.Zone = nil
+			providerSpec.Properties.Zones = entry.zones
+			selectedZone := helpers.SelectZone(entry.zones, vmName)
+			failedZones := []int{*selectedZone}
+			if entry.failAllZones {
+				failedZones = entry.zones
+			}
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithAllocationFailedZones(failedZones...)
+			// the NIC is created upfront and any further creation of it fails, which ensures that the NIC is kept and reused
+			// when the VM is created again in an alternate zone.
+			nicName := utils.CreateNICName(vmName)
+			clusterState.CreateNIC(nicName, &armnetwork.Interface{Name: to.Ptr(nicName), Location: to.Ptr(providerSpec.Location), Properties: &armnetwork.InterfacePropertiesFormat{}})
+			nicAccessAPIBehaviorSpec := fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(nicName, testhelp.AccessMethodBeginCreateOrUpdate, testhelp.InternalServerError("test-error-code"))
+			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, nil, nil, nicAccessAPIBehaviorSpec, nil, nil)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			resp, err := testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(resp).ToNot(BeNil())
+			lastKnownState, decodeErr := helpers.DecodeLastKnownState(resp.LastKnownState)
+			g.Expect(decodeErr).To(BeNil())
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				g.Expect(lastKnownState.PendingOperations).To(BeEmpty())
+				return
+			}
+			g.Expect(err).To(BeNil())
+			expectedZone := helpers.GetAlternateZones(entry.zones, selectedZone)[0]
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			g.Expect(vm.Zones).To(Equal([]*string{to.Ptr(strconv.Itoa(expectedZone))}))
+			g.Expect(lastKnownState.Zone).To(Equal(to.Ptr(expectedZone)))
+			g.Expect(lastKnownState.CreatedResources.NICID).ToNot(BeEmpty())
+			g.Expect(lastKnownState.CreatedResources.VMID).To(Equal(*vm.ID))
+			checkAndGetNIC(context.Background(), g, *fakeFactory, vmName, true, false)
+		})
+	}
+}
+
+func TestCreateMachineResumesFromLastKnownState(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	ctx := context.Background()
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.
+		WithDefaultVMImageSpec().
+		WithAgreementTerms(true).
+		WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+	machine := &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)}
+
+	resp, err := NewDefaultDriver(createDefaultFakeFactoryForCreateMachine(g, clusterState)).CreateMachine(ctx, &driver.CreateMachineRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+
+	// creating the NIC and the VM again fails, hence a retry has to skip the resources recorded in the LastKnownState.
+	testInternalServerError := testhelp.InternalServerError("test-error-code")
+	nicName := utils.CreateNICName(vmName)
+	fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState,
+		fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginCreateOrUpdate, testInternalServerError),
+		nil,
+		fakes.NewAPIBehaviorSpec().
+			AddErrorResourceReaction(nicName, testhelp.AccessMethodGet, testInternalServerError).
+			AddErrorResourceReaction(nicName, testhelp.AccessMethodBeginCreateOrUpdate, testInternalServerError),
+		nil, nil)
+	testDriver := NewDefaultDriver(fakeFactory)
+	_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).ToNot(BeNil())
+
+	machine.Status.LastKnownState = resp.LastKnownState
+	retryResp, err := testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	g.Expect(retryResp.NodeName).To(Equal(vmName))
+	g.Expect(retryResp.LastKnownState).To(Equal(resp.LastKnownState))
+}
+
+func TestCreateMachineWithWriteAccelerator(t *testing.T) {
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description     string
+		vmSize          string
+		skuCapabilities map[string]string
+		expectedErrCode *codes.Code
+	}{
+		{"should create VM with write accelerator enabled on the OS disk", "Standard_WA_Test_1", map[string]string{helpers.MaxWriteAcceleratorDisksAllowedCapability: "8"}, nil},
+		{"should fail with InvalidArgument when VM size does not support write accelerator", "Standard_WA_Test_2", map[string]string{}, to.Ptr(codes.InvalidArgument)},
+		{"should fail with InvalidArgument when VM size does not allow a write accelerated disk", "Standard_WA_Test_3", map[string]string{helpers.MaxWriteAcceleratorDisksAllowedCapability: "0"}, to.Ptr(codes.InvalidArgument)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.NetworkProfile.AcceleratedNetworking = to.Ptr(false)
+			providerSpec.Properties.StorageProfile.OsDisk.ManagedDisk.StorageAccountType = string(armcompute.StorageAccountTypesPremiumLRS)
+			providerSpec.Properties.StorageProfile.OsDisk.WriteAcceleratorEnabled = to.Ptr(true)
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.vmSize, entry.skuCapabilities)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			g.Expect(vm.Properties.StorageProfile.OSDisk.WriteAcceleratorEnabled).To(Equal(to.Ptr(true)))
+		})
+	}
+}
+
+func TestCreateMachineWithVMSizeAvailability(t *testing.T) {
+	const otherVMSize = "Standard_Avail_Test_Other"
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description     string
+		vmSize          string
+		skuVMSize       string
+		offeredZones    []*string
+		restriction     *armcompute.ResourceSKURestrictions
+		expectedErrCode *codes.Code
+	}{
+		{"should create VM if the VM size is offered in the zone", "Standard_Avail_Test_1", "Standard_Avail_Test_1", []*string{to.Ptr("1"), to.Ptr("2")}, nil, nil},
+		{"should fail with InvalidArgument if the VM size is not offered in the location", "Standard_Avail_Test_2", otherVMSize, nil, nil, to.Ptr(codes.InvalidArgument)},
+		{"should fail with InvalidArgument if the VM size is not offered in the zone", "Standard_Avail_Test_3", "Standard_Avail_Test_3", []*string{to.Ptr("2"), to.Ptr("3")}, nil, to.Ptr(codes.InvalidArgument)},
+		{"should fail with InvalidArgument if the VM size is restricted in the location", "Standard_Avail_Test_4", "Standard_Avail_Test_4", nil,
+			&armcompute.ResourceSKURestrictions{
+				Type:            to.Ptr(armcompute.ResourceSKURestrictionsTypeLocation),
+				ReasonCode:      to.Ptr(armcompute.ResourceSKURestrictionsReasonCodeNotAvailableForSubscription),
+				RestrictionInfo: &armcompute.ResourceSKURestrictionInfo{Locations: []*string{to.Ptr(testhelp.Location)}},
+			}, to.Ptr(codes.InvalidArgument)},
+		{"should fail with InvalidArgument if the VM size is restricted in the zone", "Standard_Avail_Test_5", "Standard_Avail_Test_5", nil,
+			&armcompute.ResourceSKURestrictions{
+				Type:            to.Ptr(armcompute.ResourceSKURestrictionsTypeZone),
+				ReasonCode:      to.Ptr(armcompute.ResourceSKURestrictionsReasonCodeNotAvailableForSubscription),
+				RestrictionInfo: &armcompute.ResourceSKURestrictionInfo{Locations: []*string{to.Ptr(testhelp.Location)}, Zones: []*string{to.Ptr("1")}},
+			}, to.Ptr(codes.InvalidArgument)},
+		{"should create VM if the VM size is only restricted in another zone", "Standard_Avail_Test_6", "Standard_Avail_Test_6", nil,
+			&armcompute.ResourceSKURestrictions{
+				Type:            to.Ptr(armcompute.ResourceSKURestrictionsTypeZone),
+				ReasonCode:      to.Ptr(armcompute.ResourceSKURestrictionsReasonCodeNotAvailableForSubscription),
+				RestrictionInfo: &armcompute.ResourceSKURestrictionInfo{Locations: []*string{to.Ptr(testhelp.Location)}, Zones: []*string{to.Ptr("3")}},
+			}, nil},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.NetworkProfile.AcceleratedNetworking = to.Ptr(false)
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.skuVMSize, map[string]string{})
+			sku := clusterState.ResourceSKUs[0]
+			if entry.offeredZones != nil {
+				sku.LocationInfo = []*armcompute.ResourceSKULocationInfo{{Location: to.Ptr(testhelp.Location), Zones: entry.offeredZones}}
+			}
+			if entry.restriction != nil {
+				sku.Restrictions = []*armcompute.ResourceSKURestrictions{entry.restriction}
+			}
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+		})
+	}
+}
+
+func TestCreateMachineWithZonesAvailability(t *testing.T) {
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description     string
+		vmSize          string
+		zones           []int
+		offeredZones    []string
+		expectedErrCode *codes.Code
+		expectedErrPart string
+	}{
+		{"should create VM if the VM size is offered in all zones", "Standard_Zones_Test_1", []int{1, 2, 3}, []string{"1", "2", "3"}, nil, ""},
+		{"should fail with InvalidArgument if the VM size is not offered in one of the zones", "Standard_Zones_Test_2", []int{1, 2, 3}, []string{"1", "2"}, to.Ptr(codes.InvalidArgument), "zone 3"},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.NetworkProfile.AcceleratedNetworking = to.Ptr(false)
+			providerSpec.Properties.Zone = nil
+			providerSpec.Properties.Zones = entry.zones
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.vmSize, map[string]string{}).
+				WithVMSizeResourceSKUZones(entry.vmSize, entry.offeredZones...)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				g.Expect(statusErr.Message()).To(ContainSubstring(entry.expectedErrPart))
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+		})
+	}
+}
+
+func TestCreateMachineWithLongMachineName(t *testing.T) {
+	table := []struct {
+		description    string
+		machineName    string
+		expectedVMName string
+	}{
+		{"should create VM named after the machine, shorten the data disk names and tag all resources with the machine name if the machine name has the max length", strings.Repeat("a", utils.MaxVMNameLength), strings.Repeat("a", utils.MaxVMNameLength)},
+		{"should create VM with a shortened name and tag all resources with the machine name if the machine name exceeds the max length of a VM name", strings.Repeat("b", utils.MaxVMNameLength+1), utils.ShortenName(strings.Repeat("b", utils.MaxVMNameLength+1), utils.MaxVMNameLength)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks(testDataDiskName, 2).Build()
+			// the first data disk is shared and therefore created before the VM, the second one is created together with the VM.
+			providerSpec.Properties.StorageProfile.DataDisks[0].MaxShares = to.Ptr[int32](2)
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			machine := &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, entry.machineName)}
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			resp, err := testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			g.Expect(resp.ProviderID).To(Equal(helpers.DeriveInstanceID(providerSpec.Location, entry.expectedVMName)))
+			g.Expect(resp.NodeName).To(Equal(entry.expectedVMName))
+			vm := clusterState.GetVM(entry.expectedVMName)
+			g.Expect(vm).ToNot(BeNil())
+			// the VM, the NIC and the data disks carry the machine name tag, since their names can not be associated to the machine.
+			g.Expect(vm.Tags).To(HaveKeyWithValue(utils.MachineNameTagKey, to.Ptr(entry.machineName)))
+			nic := checkAndGetNIC(ctx, g, *fakeFactory, entry.expectedVMName, true, false)
+			g.Expect(nic.Tags).To(HaveKeyWithValue(utils.MachineNameTagKey, to.Ptr(entry.machineName)))
+			g.Expect(vm.Properties.StorageProfile.DataDisks).To(HaveLen(2))
+			for _, dataDisk := range vm.Properties.StorageProfile.DataDisks {
+				g.Expect(len(*dataDisk.Name)).To(BeNumerically("<=", utils.MaxResourceNameLength))
+				g.Expect(*dataDisk.Name).To(Equal(utils.CreateDataDiskName(entry.expectedVMName, testDataDiskName, *dataDisk.Lun)))
+				disk := clusterState.GetDisk(*dataDisk.Name)
+				g.Expect(disk).ToNot(BeNil())
+				g.Expect(disk.Tags).To(HaveKeyWithValue(utils.MachineNameTagKey, to.Ptr(entry.machineName)))
+			}
+
+			// the machine is listed by its name with the provider ID of the VM.
+			listMachinesResp, err := NewDefaultDriver(createDefaultFakeFactoryForListMachines(g, testResourceGroupName, clusterState, nil)).ListMachines(ctx, &driver.ListMachinesRequest{
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			g.Expect(listMachinesResp.MachineList).To(Equal(map[string]string{resp.ProviderID: entry.machineName}))
+
+			deleteTestDriver := NewDefaultDriver(createDefaultFakeFactoryForDeleteMachine(g, testResourceGroupName, clusterState))
+			statusResp, err := deleteTestDriver.GetMachineStatus(ctx, &driver.GetMachineStatusRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			g.Expect(statusResp.ProviderID).To(Equal(resp.ProviderID))
+			_, err = deleteTestDriver.DeleteMachine(ctx, &driver.DeleteMachineRequest{
+				Machine:      machine,
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			g.Expect(clusterState.GetVM(entry.expectedVMName)).To(BeNil())
+		})
+	}
+}
+
+func TestCreateWindowsMachineNodeName(t *testing.T) {
+	table := []struct {
+		description string
+		vmName      string
+	}{
+		{"should return the VM name as node name for a Windows VM with a short name", "vm-0"},
+		{"should return the shortened computer name as node name for a Windows VM with a long name", "shoot--test-worker-pool-0-z1-5d9f8-abcde"},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.OsProfile.WindowsConfiguration = &api.AzureWindowsConfiguration{}
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			secret := fakes.CreateProviderSecret()
+			secret.Data[api.AdminPassword] = []byte("test-admin-password")
+			testDriver := NewDefaultDriver(fakeFactory)
+			resp, err := testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, entry.vmName)},
+				MachineClass: machineClass,
+				Secret:       secret,
+			})
+			g.Expect(err).To(BeNil())
+			vm := clusterState.GetVM(entry.vmName)
+			g.Expect(vm).ToNot(BeNil())
+			// the node registers with the computer name, which is shortened for long names of Windows VMs.
+			g.Expect(resp.NodeName).To(Equal(*vm.Properties.OSProfile.ComputerName))
+			g.Expect(resp.NodeName).To(Equal(utils.CreateWindowsComputerName(entry.vmName)))
+			g.Expect(len(resp.NodeName)).To(BeNumerically("<=", 15))
+		})
+	}
+}
+
+func TestCreateMachineWithTransientNICAccessBehavior(t *testing.T) {
+	const vmName = "vm-0"
+	nicName := utils.CreateNICName(vmName)
+	testInternalServerError := testhelp.InternalServerError("test-error-code")
+	table := []struct {
+		description string
+		// apiBehaviorSpecFn creates the API behavior of the NIC access, cancelFn cancels the context of the requests.
+		apiBehaviorSpecFn func(cancelFn context.CancelFunc) *fakes.APIBehaviorSpec
+		timeout           time.Duration
+		// expectedErrCodes are the expected error codes of the consecutive CreateMachine requests, nil denotes success.
+		expectedErrCodes []*codes.Code
+	}{
+		{"should succeed once the intermittent errors of the NIC creation are gone",
+			func(_ context.CancelFunc) *fakes.APIBehaviorSpec {
+				return fakes.NewAPIBehaviorSpec().AddIntermittentErrorResourceReaction(nicName, testhelp.AccessMethodBeginCreateOrUpdate, testInternalServerError, 2)
+			}, time.Minute, []*codes.Code{to.Ptr(codes.Internal), to.Ptr(codes.Internal), nil},
+		},
+		{"should succeed if the NIC creation is delayed within the timeout",
+			func(_ context.CancelFunc) *fakes.APIBehaviorSpec {
+				return fakes.NewAPIBehaviorSpec().AddDelayResourceReaction(nicName, testhelp.AccessMethodBeginCreateOrUpdate, 10*time.Millisecond, 10*time.Millisecond)
+			}, time.Minute, []*codes.Code{nil},
+		},
+		{"should fail if the NIC creation is delayed beyond the timeout",
+			func(_ context.CancelFunc) *fakes.APIBehaviorSpec {
+				return fakes.NewAPIBehaviorSpec().AddDelayResourceReaction(nicName, testhelp.AccessMethodBeginCreateOrUpdate, time.Minute, 0)
+			}, 20 * time.Millisecond, []*codes.Code{to.Ptr(codes.DeadlineExceeded)},
+		},
+		{"should fail if the request is cancelled during the NIC creation",
+			func(cancelFn context.CancelFunc) *fakes.APIBehaviorSpec {
+				return fakes.NewAPIBehaviorSpec().AddContextCancellationResourceReaction(nicName, testhelp.AccessMethodBeginCreateOrUpdate, cancelFn)
+			}, time.Minute, []*codes.Code{to.Ptr(codes.Internal)},
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			ctx, cancelFn := context.WithTimeout(context.Background(), entry.timeout)
+			defer cancelFn()
+			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, nil, nil, entry.apiBehaviorSpecFn(cancelFn), nil, nil)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			for _, expectedErrCode := range entry.expectedErrCodes {
+				_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+					Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+					MachineClass: machineClass,
+					Secret:       fakes.CreateProviderSecret(),
+				})
+				if expectedErrCode != nil {
+					var statusErr *status.Status
+					g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+					g.Expect(statusErr.Code()).To(Equal(*expectedErrCode))
+					g.Expect(clusterState.GetVM(vmName)).To(BeNil())
+					continue
+				}
+				g.Expect(err).To(BeNil())
+				g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+			}
+		})
+	}
+}
+
+func TestCreateMachineWithVCPUQuota(t *testing.T) {
+	const family = "standardDSv3Family"
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description      string
+		vmSize           string
+		familyUsage      int32
+		totalUsage       int32
+		expectedErrCode  *codes.Code
+		expectedErrParts []string
+	}{
+		{"should create VM if the family and total regional quota suffice", "Standard_Quota_Test_1", 96, 96, nil, nil},
+		{"should fail with ResourceExhausted if the family quota is exhausted", "Standard_Quota_Test_2", 98, 10, to.Ptr(codes.ResourceExhausted), []string{family + ": 2 of 100 vCPUs remaining"}},
+		{"should fail with ResourceExhausted if the total regional quota is exhausted", "Standard_Quota_Test_3", 10, 100, to.Ptr(codes.ResourceExhausted), []string{helpers.TotalRegionalVCPUsUsageName + ": 0 of 100 vCPUs remaining"}},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.NetworkProfile.AcceleratedNetworking = to.Ptr(false)
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.vmSize, map[string]string{helpers.VCPUsCapability: "4"}).
+				WithVMSizeResourceSKUFamily(entry.vmSize, family).
+				WithUsage(family, entry.familyUsage, 100).
+				WithUsage(helpers.TotalRegionalVCPUsUsageName, entry.totalUsage, 100)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				for _, part := range entry.expectedErrParts {
+					g.Expect(statusErr.Message()).To(ContainSubstring(part))
+				}
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+		})
+	}
+}
+
+func TestCreateMachineWithDiskControllerType(t *testing.T) {
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description     string
+		vmSize          string
+		skuCapabilities map[string]string
+		expectedErrCode *codes.Code
+	}{
+		{"should create VM with NVMe disk controller when VM size supports it", "Standard_DCT_Test_1", map[string]string{helpers.DiskControllerTypesCapability: "SCSI, NVMe"}, nil},
+		{"should fail with InvalidArgument when VM size only supports SCSI", "Standard_DCT_Test_2", map[string]string{helpers.DiskControllerTypesCapability: "SCSI"}, to.Ptr(codes.InvalidArgument)},
+		{"should fail with InvalidArgument when VM size does not list disk controller types", "Standard_DCT_Test_3", map[string]string{}, to.Ptr(codes.InvalidArgument)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.NetworkProfile.AcceleratedNetworking = to.Ptr(false)
+			providerSpec.Properties.StorageProfile.DiskControllerType = to.Ptr(string(armcompute.DiskControllerTypesNVMe))
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.vmSize, entry.skuCapabilities)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			g.Expect(vm.Properties.StorageProfile.DiskControllerType).To(Equal(to.Ptr(armcompute.DiskControllerTypesNVMe)))
+		})
+	}
+}
+
+func TestCreateMachineWithHibernation(t *testing.T) {
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description     string
+		vmSize          string
+		skuCapabilities map[string]string
+		expectedErrCode *codes.Code
+	}{
+		{"should create VM with hibernation enabled when VM size supports it", "Standard_HIB_Test_1", map[string]string{helpers.HibernationSupportedCapability: "True"}, nil},
+		{"should fail with InvalidArgument when VM size does not support hibernation", "Standard_HIB_Test_2", map[string]string{helpers.HibernationSupportedCapability: "False"}, to.Ptr(codes.InvalidArgument)},
+		{"should fail with InvalidArgument when VM size does not have the hibernation capability", "Standard_HIB_Test_3", map[string]string{}, to.Ptr(codes.InvalidArgument)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.NetworkProfile.AcceleratedNetworking = to.Ptr(false)
+			providerSpec.Properties.AdditionalCapabilities = &api.AzureAdditionalCapabilities{HibernationEnabled: to.Ptr(true)}
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.vmSize, entry.skuCapabilities)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			g.Expect(vm.Properties.AdditionalCapabilities).ToNot(BeNil())
+			g.Expect(vm.Properties.AdditionalCapabilities.HibernationEnabled).To(Equal(to.Ptr(true)))
+		})
+	}
+}
+
+func TestCreateMachineWithDataDiskCount(t *testing.T) {
+	// NOTE: resource SKUs are cached per VM size, therefore every entry uses a different VM size.
+	table := []struct {
+		description     string
+		vmSize          string
+		skuCapabilities map[string]string
+		numDataDisks    int
+		outOfRangeLun   *int32
+		expectedErrCode *codes.Code
+	}{
+		{"should create VM when the VM size supports the number of data disks", "Standard_DDC_Test_1", map[string]string{helpers.MaxDataDiskCountCapability: "4"}, 2, nil, nil},
+		{"should create VM when the VM size does not have the max data disk count capability", "Standard_DDC_Test_2", map[string]string{}, 2, nil, nil},
+		{"should fail with InvalidArgument when more data disks are configured than the VM size allows", "Standard_DDC_Test_3", map[string]string{helpers.MaxDataDiskCountCapability: "2"}, 3, nil, to.Ptr(codes.InvalidArgument)},
+		{"should fail with InvalidArgument when the lun of a data disk is out of range", "Standard_DDC_Test_4", map[string]string{helpers.MaxDataDiskCountCapability: "4"}, 2, to.Ptr(int32(4)), to.Ptr(codes.InvalidArgument)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().WithDataDisks(testDataDiskName, entry.numDataDisks).Build()
+			providerSpec.Properties.HardwareProfile.VMSize = entry.vmSize
+			providerSpec.Properties.NetworkProfile.AcceleratedNetworking = to.Ptr(false)
+			if entry.outOfRangeLun != nil {
+				providerSpec.Properties.StorageProfile.DataDisks[0].Lun = *entry.outOfRangeLun
+			}
+
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithVMSizeResourceSKU(entry.vmSize, entry.skuCapabilities)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+			const vmName = "vm-0"
+			ctx := context.Background()
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				checkAndGetNIC(ctx, g, *fakeFactory, vmName, false, false)
+				return
+			}
+			g.Expect(err).To(BeNil())
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			g.Expect(vm.Properties.StorageProfile.DataDisks).To(HaveLen(entry.numDataDisks))
+		})
+	}
+}
+
+func TestCreateMachineWithOSDiskTier(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.StorageProfile.OsDisk.ManagedDisk.StorageAccountType = string(armcompute.StorageAccountTypesPremiumLRS)
+	providerSpec.Properties.StorageProfile.OsDisk.Tier = to.Ptr("P30")
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.
+		WithDefaultVMImageSpec().
+		WithAgreementTerms(true).
+		WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+	fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory)
+	_, err = testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+		Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	osDisk := clusterState.GetDisk(utils.CreateOSDiskName(vmName))
+	g.Expect(osDisk).ToNot(BeNil())
+	g.Expect(osDisk.Properties.Tier).To(Equal(to.Ptr("P30")))
+}
+
+func TestCreateMachineWithLatestGalleryImageVersion(t *testing.T) {
+	const (
+		galleryName = "test-gallery"
+		imageName   = "gardenlinux"
+		vmName      = "vm-0"
+	)
+	galleryImage := fakes.GalleryImageSpec{
+		Versions:                   []string{"1.9.0", "1.10.0", "1.11.0"},
+		VersionsExcludedFromLatest: []string{"1.11.0"},
+	}
+	table := []struct {
+		description                     string
+		sharedGalleryImageID            *string
+		communityGalleryImageID         *string
+		expectedSharedGalleryImageID    *string
+		expectedCommunityGalleryImageID *string
+		expectedErrCode                 *codes.Code
+	}{
+		{
+			"should resolve latest version of a shared gallery image",
+			to.Ptr(fmt.Sprintf("/SharedGalleries/%s/Images/%s/Versions/latest", galleryName, imageName)), nil,
+			to.Ptr(fmt.Sprintf("/SharedGalleries/%s/Images/%s/Versions/1.10.0", galleryName, imageName)), nil, nil,
+		},
+		{
+			"should resolve latest version of a community gallery image",
+			nil, to.Ptr(fmt.Sprintf("/CommunityGalleries/%s/Images/%s/Versions/latest", galleryName, imageName)),
+			nil, to.Ptr(fmt.Sprintf("/CommunityGalleries/%s/Images/%s/Versions/1.10.0", galleryName, imageName)), nil,
+		},
+		{
+			"should keep a concrete version of a shared gallery image",
+			to.Ptr(fmt.Sprintf("/SharedGalleries/%s/Images/%s/Versions/1.9.0", galleryName, imageName)), nil,
+			to.Ptr(fmt.Sprintf("/SharedGalleries/%s/Images/%s/Versions/1.9.0", galleryName, imageName)), nil, nil,
+		},
+		{
+			"should fail with NotFound if the gallery image does not exist",
+			to.Ptr(fmt.Sprintf("/SharedGalleries/%s/Images/does-not-exist/Versions/latest", galleryName)), nil,
+			nil, nil, to.Ptr(codes.NotFound),
+		},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.StorageProfile.ImageReference = api.AzureImageReference{
+				SharedGalleryImageID:    entry.sharedGalleryImageID,
+				CommunityGalleryImageID: entry.communityGalleryImageID,
+			}
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithGalleryImage(galleryName, imageName, galleryImage)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			resp, err := testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				g.Expect(clusterState.GetVM(vmName)).To(BeNil())
+				return
+			}
+			g.Expect(err).To(BeNil())
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			imageRef := vm.Properties.StorageProfile.ImageReference
+			g.Expect(imageRef.SharedGalleryImageID).To(Equal(entry.expectedSharedGalleryImageID))
+			g.Expect(imageRef.CommunityGalleryImageID).To(Equal(entry.expectedCommunityGalleryImageID))
+			if entry.expectedSharedGalleryImageID != nil {
+				g.Expect(resp.LastKnownState).To(ContainSubstring(*entry.expectedSharedGalleryImageID))
+			} else {
+				g.Expect(resp.LastKnownState).To(ContainSubstring(*entry.expectedCommunityGalleryImageID))
+			}
+		})
+	}
+}
+
+func TestCreateMachineKeepsGalleryImageVersionOfPreviousAttempt(t *testing.T) {
+	const (
+		galleryName = "test-gallery"
+		imageName   = "gardenlinux"
+		vmName      = "vm-0"
+	)
+	g := NewWithT(t)
+	ctx := context.Background()
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.StorageProfile.ImageReference = api.AzureImageReference{
+		SharedGalleryImageID: to.Ptr(fmt.Sprintf("/SharedGalleries/%s/Images/%s/Versions/latest", galleryName, imageName)),
+	}
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.
+		WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+		WithGalleryImage(galleryName, imageName, fakes.GalleryImageSpec{Versions: []string{"1.9.0", "1.10.0"}})
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+	machine := &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)}
+
+	// the first attempt resolves the latest version but fails to create the VM.
+	fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState,
+		fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginCreateOrUpdate, testhelp.InternalServerError("test-error-code")),
+		nil, nil, nil, nil)
+	resp, err := NewDefaultDriver(fakeFactory).CreateMachine(ctx, &driver.CreateMachineRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).ToNot(BeNil())
+	g.Expect(clusterState.GetVM(vmName)).To(BeNil())
+	previousImageID := fmt.Sprintf("/SharedGalleries/%s/Images/%s/Versions/1.10.0", galleryName, imageName)
+	g.Expect(resp.LastKnownState).To(ContainSubstring(previousImageID))
+
+	// a newer version is published before the retry, the retry keeps the version of the first attempt nevertheless.
+	clusterState.WithGalleryImage(galleryName, imageName, fakes.GalleryImageSpec{Versions: []string{"1.9.0", "1.10.0", "1.11.0"}})
+	machine.Status.LastKnownState = resp.LastKnownState
+	retryResp, err := NewDefaultDriver(createDefaultFakeFactoryForCreateMachine(g, clusterState)).CreateMachine(ctx, &driver.CreateMachineRequest{
+		Machine:      machine,
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	vm := clusterState.GetVM(vmName)
+	g.Expect(vm).ToNot(BeNil())
+	g.Expect(vm.Properties.StorageProfile.ImageReference.SharedGalleryImageID).To(Equal(to.Ptr(previousImageID)))
+	g.Expect(retryResp.LastKnownState).To(ContainSubstring(previousImageID))
+}
+
+func TestCreateMachineWithRestoredOSDisk(t *testing.T) {
+	const vmName = "test-vm-0"
+	snapshotID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/snapshots/known-good", testhelp.SubscriptionID, testResourceGroupName)
+	restorePointID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/restorePointCollections/rpc-0/restorePoints/rp-0/diskRestorePoints/drp-0", testhelp.SubscriptionID, testResourceGroupName)
+	table := []struct {
+		description          string
+		snapshotID           *string
+		restorePointID       *string
+		expectedCreateOption armcompute.DiskCreateOption
+		expectedSourceID     string
+	}{
+		{"should copy the OSDisk from a snapshot and attach it to the VM", to.Ptr(snapshotID), nil, armcompute.DiskCreateOptionCopy, snapshotID},
+		{"should restore the OSDisk from a disk restore point and attach it to the VM", nil, to.Ptr(restorePointID), armcompute.DiskCreateOptionRestore, restorePointID},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.StorageProfile.OsDisk.CreateOption = api.OSDiskCreateOptionRestore
+			providerSpec.Properties.StorageProfile.OsDisk.SnapshotID = entry.snapshotID
+			providerSpec.Properties.StorageProfile.OsDisk.RestorePointID = entry.restorePointID
+			// the image is not used to create the VM, hence it does not have to exist.
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
+
+			// Test
+			// ----------------------------------------------------------------------------
+			testDriver := NewDefaultDriver(fakeFactory)
+			resp, err := testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+
+			osDiskName := utils.CreateOSDiskName(vmName)
+			osDisk := clusterState.CreatedDisks[osDiskName]
+			g.Expect(osDisk).ToNot(BeNil())
+			g.Expect(*osDisk.Properties.CreationData.CreateOption).To(Equal(entry.expectedCreateOption))
+			g.Expect(*osDisk.Properties.CreationData.SourceResourceID).To(Equal(entry.expectedSourceID))
+			g.Expect(resp.LastKnownState).To(ContainSubstring(*osDisk.ID))
+
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			g.Expect(vm.Properties.StorageProfile.ImageReference).To(BeNil())
+			g.Expect(vm.Properties.OSProfile).To(BeNil())
+			g.Expect(*vm.Properties.StorageProfile.OSDisk.CreateOption).To(Equal(armcompute.DiskCreateOptionTypesAttach))
+			g.Expect(vm.Properties.StorageProfile.OSDisk.ManagedDisk.ID).To(Equal(osDisk.ID))
+		})
+	}
+}
+
+func TestCreateMachineWithGalleryImagePurchasePlan(t *testing.T) {
+	const (
+		galleryName = "test-gallery"
+		imageName   = "gardenlinux"
+		vmName      = "vm-0"
+	)
+	table := []struct {
+		description              string
+		skipMarketplaceAgreement bool
+		expectAgreementAccepted  bool
+	}{
+		{"should set the purchase plan and accept the agreement for a gallery image wrapping a marketplace image", false, true},
+		{"should set the purchase plan but not accept the agreement if skipMarketplaceAgreement is set", true, false},
+	}
 
+	g := NewWithT(t)
 	for _, entry := range table {
 		t.Run(entry.description, func(_ *testing.T) {
-			// create provider spec
-			providerSpecBuilder := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues()
-			if entry.providerSpecVnetResourceGroup != nil {
-				providerSpecBuilder.WithSubnetInfo(*entry.providerSpecVnetResourceGroup)
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.StorageProfile.ImageReference = api.AzureImageReference{
+				CommunityGalleryImageID:  to.Ptr(fmt.Sprintf("/CommunityGalleries/%s/Images/%s/Versions/1.0.0", galleryName, imageName)),
+				SkipMarketplaceAgreement: entry.skipMarketplaceAgreement,
 			}
-			providerSpec := providerSpecBuilder.Build()
+			publisher, offer, sku, _ := fakes.GetDefaultVMImageParts()
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(false).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs).
+				WithGalleryImage(galleryName, imageName, fakes.GalleryImageSpec{
+					Versions:     []string{"1.0.0"},
+					PurchasePlan: &armcompute.ImagePurchasePlan{Name: to.Ptr(sku), Product: to.Ptr(offer), Publisher: to.Ptr(publisher)},
+				})
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+			g.Expect(err).To(BeNil())
 
-			// initialize cluster state
+			// Test
 			// ----------------------------------------------------------------------------
-			// create cluster state
-			clusterState := fakes.NewClusterState(providerSpec)
-			if entry.vmImageExists {
-				clusterState.WithDefaultVMImageSpec()
-			}
-			if entry.agreementExists {
-				clusterState.WithAgreementTerms(entry.agreementAccepted)
-			}
-			if entry.subnetExists {
-				vnetResourceGroup := providerSpec.ResourceGroup
-				if entry.subnetResourceGroup != nil {
-					vnetResourceGroup = *entry.subnetResourceGroup
-				}
-				clusterState.WithSubnet(vnetResourceGroup, entry.subnetName, entry.vnetName)
-			}
-			// create fake factory
-			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, entry.vmAccessAPIBehavior, entry.subnetAccessAPIBehavior, nil, entry.vmImageAccessAPIBehavior, entry.mktPlaceAgreementAccessAPIBehavior)
+			testDriver := NewDefaultDriver(fakeFactory)
+			_, err = testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+				MachineClass: machineClass,
+				Secret:       fakes.CreateProviderSecret(),
+			})
+			g.Expect(err).To(BeNil())
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			g.Expect(vm.Plan).To(Equal(&armcompute.Plan{Name: to.Ptr(sku), Product: to.Ptr(offer), Publisher: to.Ptr(publisher)}))
+			g.Expect(*clusterState.AgreementTerms.Properties.Accepted).To(Equal(entry.expectAgreementAccepted))
+		})
+	}
+}
 
-			// Create machine and machine class to be used to create DeleteMachineRequest
-			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+func TestCreateMachineWithMarketplaceAgreementAcceptanceDisabled(t *testing.T) {
+	const vmName = "vm-0"
+	table := []struct {
+		description       string
+		agreementAccepted bool
+		expectedErrCode   *codes.Code
+	}{
+		{"should create the machine if the agreement has already been accepted", true, nil},
+		{"should fail to create the machine if the agreement has not been accepted", false, to.Ptr(codes.FailedPrecondition)},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.StorageProfile.ImageReference.DisableMarketplaceAgreementAcceptance = true
+			clusterState := fakes.NewClusterState(providerSpec)
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(entry.agreementAccepted).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
 			g.Expect(err).To(BeNil())
-			machine := &v1alpha1.Machine{
-				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
-			}
+
 			// Test
 			// ----------------------------------------------------------------------------
 			testDriver := NewDefaultDriver(fakeFactory)
-			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
-				Machine:      machine,
+			_, err = testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
 				MachineClass: machineClass,
 				Secret:       fakes.CreateProviderSecret(),
 			})
-			checkClusterStateAndGetMachineResources(ctx, g, *fakeFactory, vmName, false, false, false, nil, false, false)
-			if entry.checkErrorFn != nil {
-				entry.checkErrorFn(g, clusterState, err)
+			// the agreement must never be accepted on behalf of the subscription
+			g.Expect(*clusterState.AgreementTerms.Properties.Accepted).To(Equal(entry.agreementAccepted))
+			if entry.expectedErrCode == nil {
+				g.Expect(err).To(BeNil())
+				g.Expect(clusterState.GetVM(vmName)).ToNot(BeNil())
+				return
 			}
+			g.Expect(err).ToNot(BeNil())
+			var statusErr *status.Status
+			g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+			g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+			g.Expect(statusErr.Message()).To(ContainSubstring("az vm image terms accept"))
+			g.Expect(clusterState.GetVM(vmName)).To(BeNil())
 		})
 	}
 }
 
-func TestCreateMachineWhenNICOrVMCreationFails(t *testing.T) {
-	const (
-		vmName                = "vm-0"
-		internalServerErrCode = "test-error-code"
-	)
-	nicName := utils.CreateNICName(vmName)
-	internalServerErr := testhelp.InternalServerError(internalServerErrCode)
-	ctx := context.Background()
-
+func TestCreateMachineWithPurchasePlanFromSpec(t *testing.T) {
+	const vmName = "vm-0"
+	publisher, offer, sku, _ := fakes.GetDefaultVMImageParts()
 	table := []struct {
-		description          string
-		nicExists            bool
-		vmAccessAPIBehavior  *fakes.APIBehaviorSpec
-		nicAccessAPIBehavior *fakes.APIBehaviorSpec
-		checkClusterStateFn  func(g *WithT, ctx context.Context, clusterState *fakes.ClusterState, vmName string)
-		checkErrorFn         func(g *WithT, clusterState *fakes.ClusterState, err error)
+		description string
+		imageRef    api.AzureImageReference
 	}{
-		{
-			// NIC GET calls does not return error in case it is NotFound
-			"should fail machine creation with NIC GET fails, no resources should be created", true,
-			nil, fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(nicName, testhelp.AccessMethodGet, internalServerErr),
-			func(g *WithT, ctx context.Context, clusterState *fakes.ClusterState, vmName string) {
-				factory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
-				checkClusterStateAndGetMachineResources(ctx, g, *factory, vmName, false, true, false, nil, false, false)
-			},
-			func(g *WithT, clusterState *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
-				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodGet))
-				g.Expect(fakes.IsNicURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.ResourceGroup, nicName)).To(BeTrue())
-			},
-		},
-		{
-			"should fail machine creation when NIC creation fails, no resources should be created", false,
-			nil, fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(nicName, testhelp.AccessMethodBeginCreateOrUpdate, internalServerErr),
-			func(g *WithT, ctx context.Context, clusterState *fakes.ClusterState, vmName string) {
-				factory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
-				checkClusterStateAndGetMachineResources(ctx, g, *factory, vmName, false, false, false, nil, false, false)
-			},
-			func(g *WithT, clusterState *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
-				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodPut))
-				g.Expect(fakes.IsNicURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.ResourceGroup, nicName)).To(BeTrue())
-			},
-		},
-		{
-			"should fail machine creation when VM creation fails, only NIC resource should now exist", false,
-			fakes.NewAPIBehaviorSpec().AddErrorResourceReaction(vmName, testhelp.AccessMethodBeginCreateOrUpdate, internalServerErr), nil,
-			func(g *WithT, ctx context.Context, clusterState *fakes.ClusterState, vmName string) {
-				factory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
-				checkClusterStateAndGetMachineResources(ctx, g, *factory, vmName, false, true, false, nil, false, false)
-			},
-			func(g *WithT, clusterState *fakes.ClusterState, err error) {
-				azRespErr := checkAndGetWrapperAzResponseError(g, err, codes.Internal)
-				g.Expect(azRespErr.StatusCode).To(Equal(http.StatusInternalServerError))
-				g.Expect(azRespErr.ErrorCode).To(Equal(internalServerErrCode))
-				g.Expect(azRespErr.RawResponse.Request.Method).To(Equal(http.MethodPut))
-				g.Expect(fakes.IsVMURIPath(azRespErr.RawResponse.Request.URL.Path, testhelp.SubscriptionID, clusterState.ProviderSpec.ResourceGroup, vmName)).To(BeTrue())
-			},
-		},
+		{"should use the configured plan for a marketplace image without retrieving the image", api.AzureImageReference{URN: to.Ptr(testhelp.DefaultImageRefURN)}},
+		{"should use the configured plan for a managed image derived from a marketplace image", api.AzureImageReference{ID: fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/images/gardenlinux", testhelp.SubscriptionID, testResourceGroupName)}},
 	}
 
 	g := NewWithT(t)
-	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
-
 	for _, entry := range table {
 		t.Run(entry.description, func(_ *testing.T) {
-			// initialize cluster state
-			// ----------------------------------------------------------------------------
-			// create cluster state
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.StorageProfile.ImageReference = entry.imageRef
+			providerSpec.Properties.StorageProfile.ImageReference.Plan = &api.AzurePurchasePlan{Name: sku, Product: offer, Publisher: publisher}
 			clusterState := fakes.NewClusterState(providerSpec)
-			if entry.nicExists {
-				clusterState.AddMachineResources(fakes.NewMachineResourcesBuilder(providerSpec, vmName).BuildWith(false, true, false, false, nil))
-			}
-			clusterState.WithDefaultVMImageSpec().WithAgreementTerms(true).WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
-			// create fake factory
-			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, entry.vmAccessAPIBehavior, nil, entry.nicAccessAPIBehavior, nil, nil)
-
-			// Create machine and machine class to be used to create DeleteMachineRequest
-			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(false).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			// any attempt to retrieve the VM image fails
+			vmImageAccessAPIBehaviorSpec := fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.VMImageResourceType, testhelp.AccessMethodGet, testhelp.InternalServerError("test-error-code"))
+			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, nil, nil, nil, vmImageAccessAPIBehaviorSpec, nil)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
 			g.Expect(err).To(BeNil())
-			machine := &v1alpha1.Machine{
-				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
-			}
+
 			// Test
 			// ----------------------------------------------------------------------------
 			testDriver := NewDefaultDriver(fakeFactory)
-			_, err = testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
-				Machine:      machine,
+			_, err = testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
 				MachineClass: machineClass,
 				Secret:       fakes.CreateProviderSecret(),
 			})
-			if entry.checkClusterStateFn != nil {
-				entry.checkClusterStateFn(g, ctx, clusterState, vmName)
-			}
-			if entry.checkErrorFn != nil {
-				entry.checkErrorFn(g, clusterState, err)
-			}
+			g.Expect(err).To(BeNil())
+			vm := clusterState.GetVM(vmName)
+			g.Expect(vm).ToNot(BeNil())
+			g.Expect(vm.Plan).To(Equal(&armcompute.Plan{Name: to.Ptr(sku), Product: to.Ptr(offer), Publisher: to.Ptr(publisher)}))
+			g.Expect(*clusterState.AgreementTerms.Properties.Accepted).To(BeTrue())
 		})
 	}
 }
 
-func TestSuccessfulCreationOfMachine(t *testing.T) {
-
+func TestCreateMachineWithVMExtensions(t *testing.T) {
+	const (
+		vmName                    = "vm-0"
+		extensionName             = "aad-ssh-login"
+		protectedSettingsKey      = "aadSSHLoginProtectedSettings"
+		protectedSettingsJSONData = `{"token":"s3cr3t"}`
+	)
 	table := []struct {
-		description      string
-		withPurchasePlan bool
-		builder          *testhelp.ProviderSpecBuilder
-
-		vmImageAccess *fakes.APIBehaviorSpec
+		description       string
+		protectedSettings []byte
+		expectedErrCode   *codes.Code
 	}{
-		{description: "should create machine successfully if purchase plan is present", withPurchasePlan: true},
-		{description: "should create machine successfully if purchase plan is not present", withPurchasePlan: false},
-		{description: "should create machine successfully with security profile", withPurchasePlan: false,
-			builder: testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).
-				WithDefaultValues().
-				WithSecurityProfile(
-					&api.AzureSecurityProfile{
-						SecurityType: ptr.To(string(armcompute.SecurityTypesConfidentialVM)),
-					}).
-				WithStorageProfile(false, ptr.To(string(armcompute.SecurityEncryptionTypesDiskWithVMGuestState))),
-		},
-		{description: "should create machine successfully with skipMarketplaceAgreement", withPurchasePlan: false,
-			builder: testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).
-				WithDefaultValues().
-				WithStorageProfile(true, nil),
-			vmImageAccess: fakes.NewAPIBehaviorSpec().AddErrorResourceTypeReaction(utils.VMImageResourceType, testhelp.AccessMethodGet, fmt.Errorf("not found")),
-		},
+		{"should install VM extension with settings and protected settings after VM creation", []byte(protectedSettingsJSONData), nil},
+		{"should fail with InvalidArgument if protected settings are missing in the secret", nil, to.Ptr(codes.InvalidArgument)},
 	}
 
 	g := NewWithT(t)
 	for _, entry := range table {
-		providerSpecBuilder := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).
-			WithDefaultValues().
-			WithDataDisks(testDataDiskName, 2)
 		t.Run(entry.description, func(_ *testing.T) {
-			// initialize cluster state
-			// ----------------------------------------------------------------------------
-			// create cluster state
-			if entry.builder != nil {
-				providerSpecBuilder = entry.builder
+			providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+			providerSpec.Properties.Extensions = []api.AzureVMExtension{
+				{
+					Name:                       extensionName,
+					Publisher:                  "Microsoft.Azure.ActiveDirectory",
+					Type:                       "AADSSHLoginForLinux",
+					TypeHandlerVersion:         "1.0",
+					Settings:                   []byte(`{"enabled":true}`),
+					ProtectedSettingsSecretKey: to.Ptr(protectedSettingsKey),
+				},
 			}
-			providerSpec := providerSpecBuilder.Build()
-
 			clusterState := fakes.NewClusterState(providerSpec)
-			publisher, offer, sku, version := fakes.GetDefaultVMImageParts()
-			vmImageSpec := fakes.VMImageSpec{
-				Publisher:  publisher,
-				Offer:      offer,
-				SKU:        sku,
-				Version:    version,
-				PlanExists: entry.withPurchasePlan,
-			}
-			clusterState.WithVMImageSpec(vmImageSpec).WithAgreementTerms(true).WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
-			// create fake factory
-			fakeFactory := createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g, providerSpec.ResourceGroup, clusterState, nil, nil, nil, entry.vmImageAccess, nil)
-			// Create machine and machine class to be used to create DeleteMachineRequest
-			machineClass, err := fakes.CreateMachineClass(providerSpec, to.Ptr(testResourceGroupName))
-			const vmName = "vm-0"
+			clusterState.
+				WithDefaultVMImageSpec().
+				WithAgreementTerms(true).
+				WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+			fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+			machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
 			g.Expect(err).To(BeNil())
-			ctx := context.Background()
-			machine := &v1alpha1.Machine{
-				ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName),
+			secret := fakes.CreateProviderSecret()
+			if entry.protectedSettings != nil {
+				secret.Data[protectedSettingsKey] = entry.protectedSettings
 			}
-			dataDiskNames := testhelp.CreateDataDiskNames(vmName, providerSpec)
 
 			// Test
 			// ----------------------------------------------------------------------------
 			testDriver := NewDefaultDriver(fakeFactory)
-			resp, err := testDriver.CreateMachine(ctx, &driver.CreateMachineRequest{
-				Machine:      machine,
+			_, err = testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+				Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
 				MachineClass: machineClass,
-				Secret:       fakes.CreateProviderSecret(),
+				Secret:       secret,
 			})
+			if entry.expectedErrCode != nil {
+				var statusErr *status.Status
+				g.Expect(errors.As(err, &statusErr)).To(BeTrue())
+				g.Expect(statusErr.Code()).To(Equal(*entry.expectedErrCode))
+				g.Expect(clusterState.GetVM(vmName)).To(BeNil())
+				return
+			}
 			g.Expect(err).To(BeNil())
-			checkClusterStateAndGetMachineResources(ctx, g, *fakeFactory, vmName, true, true, true, dataDiskNames, true, true)
-			g.Expect(resp.NodeName).To(Equal(vmName))
-			expectedProviderID := helpers.DeriveInstanceID(providerSpec.Location, vmName)
-			g.Expect(resp.ProviderID).To(Equal(expectedProviderID))
+			extension := clusterState.GetVMExtension(vmName, extensionName)
+			g.Expect(extension).ToNot(BeNil())
+			g.Expect(*extension.Properties.Type).To(Equal("AADSSHLoginForLinux"))
+			g.Expect(extension.Properties.Settings).To(Equal(map[string]any{"enabled": true}))
+			g.Expect(extension.Properties.ProtectedSettings).To(Equal(map[string]any{"token": "s3cr3t"}))
 		})
 	}
 }
 
+func TestCreateMachineWithApplicationHealthExtension(t *testing.T) {
+	const vmName = "vm-0"
+	g := NewWithT(t)
+	providerSpec := testhelp.NewProviderSpecBuilder(testResourceGroupName, testShootNs, testWorkerPool0Name).WithDefaultValues().Build()
+	providerSpec.Properties.ApplicationHealthProfile = &api.AzureApplicationHealthProfile{
+		Enabled:     true,
+		Protocol:    api.ApplicationHealthProtocolHTTP,
+		Port:        to.Ptr[int32](10248),
+		RequestPath: to.Ptr("/healthz"),
+	}
+	clusterState := fakes.NewClusterState(providerSpec)
+	clusterState.
+		WithDefaultVMImageSpec().
+		WithAgreementTerms(true).
+		WithSubnet(providerSpec.ResourceGroup, fakes.CreateSubnetName(testShootNs), testShootNs)
+	fakeFactory := createDefaultFakeFactoryForCreateMachine(g, clusterState)
+	machineClass, err := fakes.CreateMachineClass(providerSpec, nil)
+	g.Expect(err).To(BeNil())
+
+	// Test
+	// ----------------------------------------------------------------------------
+	testDriver := NewDefaultDriver(fakeFactory)
+	_, err = testDriver.CreateMachine(context.Background(), &driver.CreateMachineRequest{
+		Machine:      &v1alpha1.Machine{ObjectMeta: fakes.NewMachineObjectMeta(testShootNs, vmName)},
+		MachineClass: machineClass,
+		Secret:       fakes.CreateProviderSecret(),
+	})
+	g.Expect(err).To(BeNil())
+	extension := clusterState.GetVMExtension(vmName, api.ApplicationHealthExtensionName)
+	g.Expect(extension).ToNot(BeNil())
+	g.Expect(*extension.Properties.Publisher).To(Equal("Microsoft.ManagedServices"))
+	g.Expect(*extension.Properties.Type).To(Equal("ApplicationHealthLinux"))
+	g.Expect(extension.Properties.Settings).To(Equal(map[string]any{"protocol": "http", "port": float64(10248), "requestPath": "/healthz"}))
+}
+
 // unit test helper functions
 // ------------------------------------------------------------------------------------------------------
 
@@ -1383,6 +3948,21 @@ func checkAndGetDataDisks(ctx context.Context, g *WithT, factory fakes.Factory,
 	return dataDisks
 }
 
+func createFakeFactoryForUpdateMachine(g *WithT, clusterState *fakes.ClusterState, vmAccessAPIBehaviorSpec *fakes.APIBehaviorSpec) *fakes.Factory {
+	factory := fakes.NewFactory(testResourceGroupName)
+	vmAccess, err := factory.NewVirtualMachineAccessBuilder().WithClusterState(clusterState).WithAPIBehaviorSpec(vmAccessAPIBehaviorSpec).Build()
+	g.Expect(err).To(BeNil())
+	nicAccess, err := factory.NewNICAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	diskAccess, err := factory.NewDiskAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	factory.
+		WithVirtualMachineAccess(vmAccess).
+		WithNetworkInterfacesAccess(nicAccess).
+		WithDisksAccess(diskAccess)
+	return factory
+}
+
 func createDefaultFakeFactoryForDeleteMachine(g *WithT, resourceGroup string, clusterState *fakes.ClusterState) *fakes.Factory {
 	return createFakeFactoryForDeleteMachineWithAPIBehaviorSpecs(g, resourceGroup, clusterState, nil, nil, nil, nil)
 }
@@ -1402,11 +3982,17 @@ func createFakeFactoryForDeleteMachineWithAPIBehaviorSpecs(g *WithT, resourceGro
 	g.Expect(err).To(BeNil())
 	diskAccess, err := factory.NewDiskAccessBuilder().WithClusterState(clusterState).WithAPIBehaviorSpec(diskAccessAPIBehaviorSpec).Build()
 	g.Expect(err).To(BeNil())
+	resourceGraphAccess, err := factory.NewResourceGraphAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	publicIPAccess, err := factory.NewPublicIPAddressAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
 	factory.
 		WithVirtualMachineAccess(vmAccess).
 		WithResourceGroupsAccess(rgAccess).
 		WithNetworkInterfacesAccess(nicAccess).
-		WithDisksAccess(diskAccess)
+		WithDisksAccess(diskAccess).
+		WithResourceGraphAccess(resourceGraphAccess).
+		WithPublicIPAddressesAccess(publicIPAccess)
 
 	return factory
 }
@@ -1435,13 +4021,34 @@ func createFakeFactoryForCreateMachineWithAPIBehaviorSpecs(g *WithT, resourceGro
 	g.Expect(err).To(BeNil())
 	diskAccess, err := factory.NewDiskAccessBuilder().WithClusterState(clusterState).Build()
 	g.Expect(err).To(BeNil())
+	skuAccess, err := factory.NewResourceSKUsAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	usageAccess, err := factory.NewUsageAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	vmExtensionsAccess, err := factory.NewVMExtensionsAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	sharedGalleryImageVersionsAccess, err := factory.NewSharedGalleryImageVersionsAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	communityGalleryImageVersionsAccess, err := factory.NewCommunityGalleryImageVersionsAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	sharedGalleryImagesAccess, err := factory.NewSharedGalleryImagesAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
+	communityGalleryImagesAccess, err := factory.NewCommunityGalleryImagesAccessBuilder().WithClusterState(clusterState).Build()
+	g.Expect(err).To(BeNil())
 	factory.
 		WithVirtualMachineAccess(vmAccess).
 		WithVirtualMachineImagesAccess(vmImageAccess).
 		WithSubnetAccess(subnetAccess).
 		WithMarketPlaceAgreementsAccess(mktPlaceAgreementAccess).
 		WithNetworkInterfacesAccess(nicAccess).
-		WithDisksAccess(diskAccess)
+		WithDisksAccess(diskAccess).
+		WithResourceSKUsAccess(skuAccess).
+		WithUsageAccess(usageAccess).
+		WithVirtualMachineExtensionsAccess(vmExtensionsAccess).
+		WithSharedGalleryImageVersionsAccess(sharedGalleryImageVersionsAccess).
+		WithCommunityGalleryImageVersionsAccess(communityGalleryImageVersionsAccess).
+		WithSharedGalleryImagesAccess(sharedGalleryImagesAccess).
+		WithCommunityGalleryImagesAccess(communityGalleryImagesAccess)
 
 	return factory
 }
@@ -0,0 +1,436 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package armserver provides a fake Azure Resource Manager (ARM) server which speaks the REST and long-running operation
+// (LRO) semantics of ARM over HTTP. Contrary to the fakes package, which replaces the typed clients at their boundary,
+// the real azcore clients are used against this server, hence the serialization of requests and responses, the polling
+// of LROs and the parsing of errors are exercised as well.
+package armserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+)
+
+const (
+	// operationsPath is the path under which the status monitors of asynchronous operations are served.
+	operationsPath = "/operations/"
+
+	provisioningStateSucceeded = "Succeeded"
+	provisioningStateCreating  = "Creating"
+	provisioningStateUpdating  = "Updating"
+	provisioningStateDeleting  = "Deleting"
+
+	operationStatusInProgress = "InProgress"
+	operationStatusSucceeded  = "Succeeded"
+
+	errorCodeMissingAPIVersion     = "MissingApiVersionParameter"
+	errorCodeInvalidRequestContent = "InvalidRequestContent"
+	errorCodeUnsupportedMethod     = "UnsupportedHttpMethod"
+)
+
+// Server is a fake ARM server. Resources are stored as JSON objects keyed by their resource ID, hence any resource type
+// can be created, read, updated, listed and deleted with the typed clients of the Azure SDK. Resource IDs are matched
+// case-insensitively as done by ARM.
+//
+// By default all operations complete synchronously, which allows to call PollUntilDone with the default options.
+// WithAsyncOperations switches to asynchronous operations that have to be polled via their Azure-AsyncOperation header.
+type Server struct {
+	httpServer *httptest.Server
+
+	mutex           sync.Mutex
+	resources       map[string]map[string]any
+	operations      map[string]*operation
+	errorReactions  map[string]errorReaction
+	asyncOperations bool
+	inProgressPolls int
+	nextOperationID int
+}
+
+// operation is an asynchronous operation whose status monitor reports it in progress for remainingPolls polls.
+type operation struct {
+	remainingPolls int
+	// complete is called once the operation has succeeded and applies its result to the stored resources.
+	complete func()
+}
+
+// errorReaction is an error which is returned for every request with a matching method and resource ID.
+type errorReaction struct {
+	statusCode int
+	errorCode  string
+}
+
+// New creates and starts a new Server. The Server must be closed by calling Close.
+func New() *Server {
+	s := &Server{
+		resources:      make(map[string]map[string]any),
+		operations:     make(map[string]*operation),
+		errorReactions: make(map[string]errorReaction),
+	}
+	s.httpServer = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts down the Server.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// URL returns the base URL of the Server, which is used as ARM endpoint.
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+// ClientOptions returns the options to create clients of the Azure SDK which send their requests to the Server. The
+// retries of the clients are kept but their delays are shortened so that retries can be tested without waiting.
+func (s *Server) ClientOptions() *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Cloud: cloud.Configuration{
+				ActiveDirectoryAuthorityHost: s.URL() + "/",
+				Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+					cloud.ResourceManager: {Audience: s.URL(), Endpoint: s.URL()},
+				},
+			},
+			Retry: policy.RetryOptions{
+				RetryDelay:    time.Millisecond,
+				MaxRetryDelay: 10 * time.Millisecond,
+			},
+			Transport: s.httpServer.Client(),
+		},
+		DisableRPRegistration: true,
+	}
+}
+
+// WithAsyncOperations lets creations, updates, deletions and actions complete asynchronously. Their responses carry an
+// Azure-AsyncOperation header whose status monitor reports the operation in progress for inProgressPolls polls before it
+// succeeds. NOTE: pollers wait 30 seconds between polls by default, hence callers should pass a short polling frequency
+// via runtime.PollUntilDoneOptions if inProgressPolls is not zero.
+func (s *Server) WithAsyncOperations(inProgressPolls int) *Server {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.asyncOperations = true
+	s.inProgressPolls = inProgressPolls
+	return s
+}
+
+// AddErrorReaction lets all requests with the given HTTP method for the resource ID fail with the status code and the
+// ARM error code. The error is returned as ARM does: as x-ms-error-code header and as error object in the body.
+func (s *Server) AddErrorReaction(method, resourceID string, statusCode int, errorCode string) *Server {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errorReactions[createErrorReactionKey(method, resourceID)] = errorReaction{statusCode: statusCode, errorCode: errorCode}
+	return s
+}
+
+// AddResource stores the resource under the resource ID as if it had been created successfully. The resource is
+// marshalled to JSON, hence any model of the Azure SDK can be passed.
+func (s *Server) AddResource(resourceID string, resource any) error {
+	object, err := toJSONObject(resource)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.resources[normalizeResourceID(resourceID)] = withResourceMetadata(object, resourceID, provisioningStateSucceeded)
+	return nil
+}
+
+// GetResource unmarshals the resource stored under the resource ID into target. It returns false if there is no such resource.
+func (s *Server) GetResource(resourceID string, target any) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	object, ok := s.resources[normalizeResourceID(resourceID)]
+	if !ok {
+		return false, nil
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return true, err
+	}
+	return true, json.Unmarshal(data, target)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, operationsPath) {
+		s.serveOperation(w, strings.TrimPrefix(r.URL.Path, operationsPath))
+		return
+	}
+	if r.URL.Query().Get("api-version") == "" {
+		writeError(w, http.StatusBadRequest, errorCodeMissingAPIVersion, "The api-version query parameter (?api-version=) is required for all requests.")
+		return
+	}
+	resourceID := strings.TrimSuffix(r.URL.Path, "/")
+	if reaction, ok := s.errorReactions[createErrorReactionKey(r.Method, resourceID)]; ok {
+		writeError(w, reaction.statusCode, reaction.errorCode, fmt.Sprintf("Simulated error for %s %s", r.Method, resourceID))
+		return
+	}
+
+	isCollection := isCollectionPath(resourceID)
+	switch {
+	case r.Method == http.MethodGet && isCollection:
+		s.list(w, resourceID)
+	case r.Method == http.MethodGet:
+		s.get(w, resourceID)
+	case r.Method == http.MethodHead && !isCollection:
+		s.checkExistence(w, resourceID)
+	case r.Method == http.MethodPut && !isCollection:
+		s.createOrUpdate(w, r, resourceID)
+	case r.Method == http.MethodPatch && !isCollection:
+		s.update(w, r, resourceID)
+	case r.Method == http.MethodDelete && !isCollection:
+		s.delete(w, resourceID)
+	case r.Method == http.MethodPost && isCollection:
+		// actions like deallocate are posted to a path below the resource they are applied to.
+		s.action(w, resourceID[:strings.LastIndex(resourceID, "/")])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errorCodeUnsupportedMethod, fmt.Sprintf("The http method '%s' is not supported for %s", r.Method, resourceID))
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, resourceID string) {
+	object, ok := s.resources[normalizeResourceID(resourceID)]
+	if !ok {
+		writeResourceNotFound(w, resourceID)
+		return
+	}
+	writeJSON(w, http.StatusOK, object)
+}
+
+func (s *Server) list(w http.ResponseWriter, collectionID string) {
+	prefix := normalizeResourceID(collectionID) + "/"
+	ids := slices.Sorted(maps.Keys(s.resources))
+	values := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		if strings.HasPrefix(id, prefix) && !strings.Contains(strings.TrimPrefix(id, prefix), "/") {
+			values = append(values, s.resources[id])
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"value": values})
+}
+
+func (s *Server) checkExistence(w http.ResponseWriter, resourceID string) {
+	if _, ok := s.resources[normalizeResourceID(resourceID)]; !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createOrUpdate(w http.ResponseWriter, r *http.Request, resourceID string) {
+	object, ok := readJSONObject(w, r)
+	if !ok {
+		return
+	}
+	key := normalizeResourceID(resourceID)
+	statusCode, pendingState := http.StatusCreated, provisioningStateCreating
+	if _, exists := s.resources[key]; exists {
+		statusCode, pendingState = http.StatusOK, provisioningStateUpdating
+	}
+	s.writeResourceOperation(w, statusCode, withResourceMetadata(object, resourceID, provisioningStateSucceeded), key, pendingState)
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request, resourceID string) {
+	patch, ok := readJSONObject(w, r)
+	if !ok {
+		return
+	}
+	key := normalizeResourceID(resourceID)
+	existing, exists := s.resources[key]
+	if !exists {
+		writeResourceNotFound(w, resourceID)
+		return
+	}
+	updated := maps.Clone(existing)
+	for k, v := range patch {
+		// properties are merged as done by ARM, all other top-level fields like tags are replaced.
+		if properties, isObject := v.(map[string]any); k == "properties" && isObject {
+			mergedProperties := getProperties(updated)
+			maps.Copy(mergedProperties, properties)
+			updated[k] = mergedProperties
+			continue
+		}
+		updated[k] = v
+	}
+	s.writeResourceOperation(w, http.StatusOK, withResourceMetadata(updated, resourceID, provisioningStateSucceeded), key, provisioningStateUpdating)
+}
+
+// writeResourceOperation stores the resource and writes it as response of a creation or update. If operations are
+// asynchronous, the resource is stored with the pending provisioning state until its operation has succeeded.
+func (s *Server) writeResourceOperation(w http.ResponseWriter, statusCode int, object map[string]any, key, pendingState string) {
+	if !s.asyncOperations {
+		s.resources[key] = object
+		writeJSON(w, statusCode, object)
+		return
+	}
+	pending := withProvisioningState(object, pendingState)
+	s.resources[key] = pending
+	s.addAsyncOperationHeaders(w, func() { s.resources[key] = object })
+	writeJSON(w, statusCode, pending)
+}
+
+func (s *Server) delete(w http.ResponseWriter, resourceID string) {
+	key := normalizeResourceID(resourceID)
+	object, exists := s.resources[key]
+	if !exists {
+		// ARM does not fail the deletion of a resource which does not exist.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !s.asyncOperations {
+		delete(s.resources, key)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	s.resources[key] = withProvisioningState(object, provisioningStateDeleting)
+	s.addAsyncOperationHeaders(w, func() { delete(s.resources, key) })
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) action(w http.ResponseWriter, resourceID string) {
+	if _, exists := s.resources[normalizeResourceID(resourceID)]; !exists {
+		writeResourceNotFound(w, resourceID)
+		return
+	}
+	if !s.asyncOperations {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	s.addAsyncOperationHeaders(w, func() {})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// addAsyncOperationHeaders registers a new asynchronous operation and adds the headers of its status monitor to the response.
+func (s *Server) addAsyncOperationHeaders(w http.ResponseWriter, complete func()) {
+	s.nextOperationID++
+	operationID := fmt.Sprintf("%d", s.nextOperationID)
+	s.operations[operationID] = &operation{remainingPolls: s.inProgressPolls, complete: complete}
+	operationURL := s.URL() + operationsPath + operationID
+	w.Header().Set("Azure-AsyncOperation", operationURL)
+	w.Header().Set("Location", operationURL)
+}
+
+func (s *Server) serveOperation(w http.ResponseWriter, operationID string) {
+	op, ok := s.operations[operationID]
+	if !ok {
+		writeResourceNotFound(w, operationsPath+operationID)
+		return
+	}
+	if op.remainingPolls > 0 {
+		op.remainingPolls--
+		writeJSON(w, http.StatusOK, map[string]any{"status": operationStatusInProgress})
+		return
+	}
+	if op.complete != nil {
+		op.complete()
+		op.complete = nil
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": operationStatusSucceeded})
+}
+
+// isCollectionPath checks if the path denotes a collection of resources, e.g. /subscriptions/<id>/resourceGroups or
+// .../providers/Microsoft.Network/networkInterfaces. Below a resource provider namespace, and without one, resource IDs
+// consist of pairs of type and name segments, hence a path with an odd number of such segments denotes a collection.
+func isCollectionPath(path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if i := slices.IndexFunc(segments, func(segment string) bool { return strings.EqualFold(segment, "providers") }); i >= 0 && i+1 < len(segments) {
+		segments = segments[i+2:]
+	}
+	return len(segments)%2 == 1
+}
+
+// withResourceMetadata sets the ID, name, type and provisioning state of the resource as ARM does.
+func withResourceMetadata(object map[string]any, resourceID, provisioningState string) map[string]any {
+	segments := strings.Split(strings.Trim(resourceID, "/"), "/")
+	object["id"] = resourceID
+	object["name"] = segments[len(segments)-1]
+	if i := slices.IndexFunc(segments, func(segment string) bool { return strings.EqualFold(segment, "providers") }); i >= 0 && i+1 < len(segments) {
+		var typeSegments []string
+		for j := i + 2; j < len(segments); j += 2 {
+			typeSegments = append(typeSegments, segments[j])
+		}
+		object["type"] = strings.Join(append([]string{segments[i+1]}, typeSegments...), "/")
+	}
+	return withProvisioningState(object, provisioningState)
+}
+
+// withProvisioningState returns a copy of the resource with the passed provisioning state.
+func withProvisioningState(object map[string]any, provisioningState string) map[string]any {
+	result := maps.Clone(object)
+	properties := getProperties(result)
+	properties["provisioningState"] = provisioningState
+	result["properties"] = properties
+	return result
+}
+
+// getProperties returns a copy of the properties of the resource.
+func getProperties(object map[string]any) map[string]any {
+	if properties, ok := object["properties"].(map[string]any); ok {
+		return maps.Clone(properties)
+	}
+	return make(map[string]any)
+}
+
+func normalizeResourceID(resourceID string) string {
+	return strings.ToLower(strings.TrimSuffix(resourceID, "/"))
+}
+
+func createErrorReactionKey(method, resourceID string) string {
+	return strings.ToUpper(method) + " " + normalizeResourceID(resourceID)
+}
+
+func toJSONObject(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	object := make(map[string]any)
+	if err = json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func readJSONObject(w http.ResponseWriter, r *http.Request) (map[string]any, bool) {
+	object := make(map[string]any)
+	if err := json.NewDecoder(r.Body).Decode(&object); err != nil {
+		writeError(w, http.StatusBadRequest, errorCodeInvalidRequestContent, fmt.Sprintf("The request content was invalid and could not be deserialized: %v", err))
+		return nil, false
+	}
+	return object, true
+}
+
+func writeResourceNotFound(w http.ResponseWriter, resourceID string) {
+	writeError(w, http.StatusNotFound, testhelp.ErrorCodeResourceNotFound, fmt.Sprintf("The Resource '%s' was not found.", resourceID))
+}
+
+// writeError writes an error response as ARM does, see https://github.com/Azure/azure-resource-manager-rpc/blob/master/v1.0/common-api-details.md#error-response-content.
+func writeError(w http.ResponseWriter, statusCode int, errorCode, message string) {
+	w.Header().Set("x-ms-error-code", errorCode)
+	writeJSON(w, statusCode, map[string]any{
+		"error": map[string]any{"code": errorCode, "message": message},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,174 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package armserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	. "github.com/onsi/gomega"
+
+	accesserrors "github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/access/errors"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp/fakes"
+)
+
+const (
+	testResourceGroupName = "test-rg"
+	testNICName           = "vm-0-nic"
+	testVMName            = "vm-0"
+)
+
+func TestNICLifecycle(t *testing.T) {
+	table := []struct {
+		description     string
+		asyncOperations bool
+		inProgressPolls int
+	}{
+		{"should create, update, list and delete a NIC with synchronous operations", false, 0},
+		{"should create, update, list and delete a NIC with asynchronous operations", true, 0},
+		{"should create, update, list and delete a NIC with asynchronous operations which are polled", true, 2},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			server := New()
+			defer server.Close()
+			if entry.asyncOperations {
+				server.WithAsyncOperations(entry.inProgressPolls)
+			}
+			client, err := armnetwork.NewInterfacesClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, server.ClientOptions())
+			g.Expect(err).To(BeNil())
+			ctx := context.Background()
+			pollOptions := &runtime.PollUntilDoneOptions{Frequency: time.Millisecond}
+
+			poller, err := client.BeginCreateOrUpdate(ctx, testResourceGroupName, testNICName, armnetwork.Interface{
+				Location: to.Ptr("westeurope"),
+				Properties: &armnetwork.InterfacePropertiesFormat{
+					EnableAcceleratedNetworking: to.Ptr(true),
+				},
+			}, nil)
+			g.Expect(err).To(BeNil())
+			createResp, err := poller.PollUntilDone(ctx, pollOptions)
+			g.Expect(err).To(BeNil())
+			nicID := fakes.CreateNetworkInterfaceID(testhelp.SubscriptionID, testResourceGroupName, testNICName)
+			g.Expect(*createResp.ID).To(Equal(nicID))
+			g.Expect(*createResp.Name).To(Equal(testNICName))
+			g.Expect(*createResp.Type).To(Equal("Microsoft.Network/networkInterfaces"))
+			g.Expect(*createResp.Properties.ProvisioningState).To(Equal(armnetwork.ProvisioningStateSucceeded))
+			g.Expect(*createResp.Properties.EnableAcceleratedNetworking).To(BeTrue())
+
+			_, err = client.UpdateTags(ctx, testResourceGroupName, testNICName, armnetwork.TagsObject{Tags: map[string]*string{"key": to.Ptr("value")}}, nil)
+			g.Expect(err).To(BeNil())
+			getResp, err := client.Get(ctx, testResourceGroupName, testNICName, nil)
+			g.Expect(err).To(BeNil())
+			g.Expect(getResp.Tags).To(HaveKeyWithValue("key", to.Ptr("value")))
+			g.Expect(*getResp.Properties.EnableAcceleratedNetworking).To(BeTrue())
+
+			pager := client.NewListPager(testResourceGroupName, nil)
+			var names []string
+			for pager.More() {
+				page, err := pager.NextPage(ctx)
+				g.Expect(err).To(BeNil())
+				for _, nic := range page.Value {
+					names = append(names, *nic.Name)
+				}
+			}
+			g.Expect(names).To(ConsistOf(testNICName))
+
+			deletePoller, err := client.BeginDelete(ctx, testResourceGroupName, testNICName, nil)
+			g.Expect(err).To(BeNil())
+			_, err = deletePoller.PollUntilDone(ctx, pollOptions)
+			g.Expect(err).To(BeNil())
+			found, err := server.GetResource(nicID, &armnetwork.Interface{})
+			g.Expect(err).To(BeNil())
+			g.Expect(found).To(BeFalse())
+		})
+	}
+}
+
+func TestAsyncOperationInProgress(t *testing.T) {
+	g := NewWithT(t)
+	server := New().WithAsyncOperations(1)
+	defer server.Close()
+	client, err := armnetwork.NewInterfacesClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, server.ClientOptions())
+	g.Expect(err).To(BeNil())
+	ctx := context.Background()
+
+	poller, err := client.BeginCreateOrUpdate(ctx, testResourceGroupName, testNICName, armnetwork.Interface{Location: to.Ptr("westeurope")}, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(poller.Done()).To(BeFalse())
+	// the resource reports the pending provisioning state until the operation has succeeded
+	nic := armnetwork.Interface{}
+	_, err = server.GetResource(fakes.CreateNetworkInterfaceID(testhelp.SubscriptionID, testResourceGroupName, testNICName), &nic)
+	g.Expect(err).To(BeNil())
+	g.Expect(*nic.Properties.ProvisioningState).To(Equal(armnetwork.ProvisioningState("Creating")))
+
+	_, err = poller.Poll(ctx)
+	g.Expect(err).To(BeNil())
+	g.Expect(poller.Done()).To(BeFalse())
+	_, err = poller.Poll(ctx)
+	g.Expect(err).To(BeNil())
+	g.Expect(poller.Done()).To(BeTrue())
+	resp, err := poller.Result(ctx)
+	g.Expect(err).To(BeNil())
+	g.Expect(*resp.Properties.ProvisioningState).To(Equal(armnetwork.ProvisioningStateSucceeded))
+}
+
+func TestVirtualMachineAction(t *testing.T) {
+	g := NewWithT(t)
+	server := New().WithAsyncOperations(0)
+	defer server.Close()
+	vmID := fakes.CreateVirtualMachineID(testhelp.SubscriptionID, testResourceGroupName, testVMName)
+	g.Expect(server.AddResource(vmID, armcompute.VirtualMachine{Location: to.Ptr("westeurope")})).To(Succeed())
+	client, err := armcompute.NewVirtualMachinesClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, server.ClientOptions())
+	g.Expect(err).To(BeNil())
+	ctx := context.Background()
+
+	poller, err := client.BeginDeallocate(ctx, testResourceGroupName, testVMName, nil)
+	g.Expect(err).To(BeNil())
+	_, err = poller.PollUntilDone(ctx, nil)
+	g.Expect(err).To(BeNil())
+
+	_, err = client.BeginDeallocate(ctx, testResourceGroupName, "vm-1", nil)
+	g.Expect(accesserrors.IsNotFoundAzAPIError(err)).To(BeTrue())
+}
+
+func TestErrors(t *testing.T) {
+	g := NewWithT(t)
+	server := New()
+	defer server.Close()
+	nicID := fakes.CreateNetworkInterfaceID(testhelp.SubscriptionID, testResourceGroupName, testNICName)
+	server.AddErrorReaction(http.MethodDelete, nicID, http.StatusConflict, testhelp.ErrorCodeOperationNotAllowed).
+		AddErrorReaction(http.MethodGet, nicID, http.StatusInternalServerError, "InternalServerError")
+	client, err := armnetwork.NewInterfacesClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, server.ClientOptions())
+	g.Expect(err).To(BeNil())
+	ctx := context.Background()
+
+	_, err = client.Get(ctx, testResourceGroupName, "vm-1-nic", nil)
+	g.Expect(accesserrors.IsNotFoundAzAPIError(err)).To(BeTrue())
+
+	_, err = client.BeginDelete(ctx, testResourceGroupName, testNICName, nil)
+	var respErr *azcore.ResponseError
+	g.Expect(errors.As(err, &respErr)).To(BeTrue())
+	g.Expect(respErr.StatusCode).To(Equal(http.StatusConflict))
+	g.Expect(respErr.ErrorCode).To(Equal(testhelp.ErrorCodeOperationNotAllowed))
+
+	// server errors are retried by the clients before the error is returned
+	_, err = client.Get(ctx, testResourceGroupName, testNICName, nil)
+	g.Expect(errors.As(err, &respErr)).To(BeTrue())
+	g.Expect(respErr.StatusCode).To(Equal(http.StatusInternalServerError))
+	g.Expect(respErr.ErrorCode).To(Equal("InternalServerError"))
+}
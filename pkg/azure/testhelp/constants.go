@@ -23,6 +23,8 @@ const (
 	DefaultImageRefURN = "sap:gardenlinux:greatest:184.0.0"
 	// UserData is the dummy user data that is set as part of the secret
 	UserData = "dummy-user-data"
+	// SSHPublicKey is a test SSH public key in authorized_keys format.
+	SSHPublicKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIM4rxzRwDSwL2vzuMFgq8y6X/l678qHwbhkNog1QsF40"
 )
 
 // Constants for method names for different fake servers. These will be used by consumers to set API behavior on specific methods.
@@ -41,4 +43,14 @@ const (
 	AccessMethodBeginCreateOrUpdate = "BeginCreateOrUpdate"
 	// AccessMethodResources is the constant representing Resources Azure API method name in the fake server.
 	AccessMethodResources = "Resources"
+	// AccessMethodNewListPager is the constant representing NewListPager Azure API method name in the fake server.
+	AccessMethodNewListPager = "NewListPager"
+	// AccessMethodInstanceView is the constant representing InstanceView Azure API method name in the fake server.
+	AccessMethodInstanceView = "InstanceView"
+	// AccessMethodBeginStart is the constant representing BeginStart Azure API method name in the fake server.
+	AccessMethodBeginStart = "BeginStart"
+	// AccessMethodBeginDeallocate is the constant representing BeginDeallocate Azure API method name in the fake server.
+	AccessMethodBeginDeallocate = "BeginDeallocate"
+	// AccessMethodUpdateTags is the constant representing UpdateTags Azure API method name in the fake server.
+	AccessMethodUpdateTags = "UpdateTags"
 )
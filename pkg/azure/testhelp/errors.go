@@ -36,9 +36,17 @@ const (
 	// ErrorCodeReferencedResourceNotFound is the error code returned in Azure response if a referenced resource
 	// is not found to exist.
 	ErrorCodeReferencedResourceNotFound = "NotFound"
+	// ErrorCodeGalleryImageNotFound is the error code returned in Azure response if an image in a shared or community gallery is not found.
+	ErrorCodeGalleryImageNotFound = "GalleryImageNotFound"
 	// ErrorCodeAttachDiskWhileBeingDetached is the error code returned in Azure response if there is an attempt to update the DeleteOptions for
 	// associated Disks when the Disk is currently getting detached.
 	ErrorCodeAttachDiskWhileBeingDetached = "AttachDiskWhileBeingDetached"
+	// ErrorCodeZonalAllocationFailed is the error code returned in Azure response if a VM could not be allocated in the
+	// requested zone due to insufficient capacity.
+	ErrorCodeZonalAllocationFailed = "ZonalAllocationFailed"
+	// ErrorCodeRequestDisallowedByPolicy is the error code returned in Azure response if the request has been denied by an
+	// Azure Policy assignment.
+	ErrorCodeRequestDisallowedByPolicy = "RequestDisallowedByPolicy"
 )
 
 // ContextTimeoutError creates an error mimicking timeout of a context.
@@ -101,6 +109,18 @@ func InternalServerError(errorCode string) error {
 	return runtime.NewResponseError(resp)
 }
 
+// ForbiddenErr creates a forbidden error setting azure specific error code as a response header.
+func ForbiddenErr(errorCode string) error {
+	headers := http.Header{}
+	headers.Set("x-ms-error-code", errorCode)
+	resp := &http.Response{
+		Status:     "403 Forbidden",
+		StatusCode: 403,
+		Header:     headers,
+	}
+	return runtime.NewResponseError(resp)
+}
+
 // BadRequestError creates a bad request error setting azure specific error code as a response header.
 func BadRequestError(errorCode string) error {
 	headers := http.Header{}
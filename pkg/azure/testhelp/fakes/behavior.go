@@ -7,6 +7,8 @@ package fakes
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
 	"time"
 
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
@@ -23,11 +25,20 @@ type APIBehaviorSpec struct {
 }
 
 // ResourceReaction captures reaction for a resource.
-// Consumers can define a panic or a context timeout or an error for a specific resource.
+// Consumers can define a panic, a context timeout, a context cancellation, a delay or an error for a specific resource.
+// An error can also be limited to a number of invocations, after which the invocations succeed.
 type ResourceReaction struct {
 	timeoutAfter *time.Duration
 	panic        bool
 	err          error
+	// delay is the duration by which the invocation is delayed, extended by a random duration of up to jitter.
+	delay  *time.Duration
+	jitter time.Duration
+	// remainingFailures is the number of invocations which still fail with err. It is shared by all copies of the
+	// reaction and is nil if every invocation fails.
+	remainingFailures *atomic.Int64
+	// cancel cancels the context of the invocation.
+	cancel context.CancelFunc
 }
 
 // NewAPIBehaviorSpec creates a new APIBehaviorSpec.
@@ -60,6 +71,32 @@ func (s *APIBehaviorSpec) AddErrorResourceReaction(resourceName, method string,
 	return s
 }
 
+// AddDelayResourceReaction adds a delay reaction for a resource when the given method is invoked on the respective resource client.
+// The invocation is delayed by delay plus a random duration of up to jitter and then proceeds normally. If the context
+// of the invocation is done before the delay has passed, the error of the context is returned.
+func (s *APIBehaviorSpec) AddDelayResourceReaction(resourceName, method string, delay, jitter time.Duration) *APIBehaviorSpec {
+	s.initializeResourceReactionMapForResource(resourceName)
+	s.resourceReactionsByName[resourceName][method] = ResourceReaction{delay: &delay, jitter: jitter}
+	return s
+}
+
+// AddIntermittentErrorResourceReaction adds an error reaction for a resource returning the error passed as an argument for the
+// first failTimes invocations of the given method on the respective resource client. All later invocations succeed.
+func (s *APIBehaviorSpec) AddIntermittentErrorResourceReaction(resourceName, method string, err error, failTimes int) *APIBehaviorSpec {
+	s.initializeResourceReactionMapForResource(resourceName)
+	s.resourceReactionsByName[resourceName][method] = ResourceReaction{err: err, remainingFailures: newRemainingFailures(failTimes)}
+	return s
+}
+
+// AddContextCancellationResourceReaction adds a context cancellation reaction for a resource when the given method is invoked on
+// the respective resource client. The passed cancelFn is called and the error of the context is returned, hence cancelFn
+// should cancel the context which is passed to the invocation or one of its parents.
+func (s *APIBehaviorSpec) AddContextCancellationResourceReaction(resourceName, method string, cancelFn context.CancelFunc) *APIBehaviorSpec {
+	s.initializeResourceReactionMapForResource(resourceName)
+	s.resourceReactionsByName[resourceName][method] = ResourceReaction{cancel: cancelFn}
+	return s
+}
+
 // AddContextTimeoutResourceTypeReaction adds a context timeout reaction for all resources of the given resourceType.
 // Context timeout is simulated after the given timeoutAfter duration when the given method on the resource client is invoked.
 func (s *APIBehaviorSpec) AddContextTimeoutResourceTypeReaction(resourceType utils.ResourceType, method string, timeoutAfter time.Duration) *APIBehaviorSpec {
@@ -83,6 +120,36 @@ func (s *APIBehaviorSpec) AddErrorResourceTypeReaction(resourceType utils.Resour
 	return s
 }
 
+// AddDelayResourceTypeReaction adds a delay reaction for all resources of a given resourceType when a given method on the resource client is invoked.
+// The invocation is delayed as described for AddDelayResourceReaction.
+func (s *APIBehaviorSpec) AddDelayResourceTypeReaction(resourceType utils.ResourceType, method string, delay, jitter time.Duration) *APIBehaviorSpec {
+	s.initializeResourceTypeReactionMapForResource(resourceType)
+	s.resourceReactionsByType[resourceType][method] = ResourceReaction{delay: &delay, jitter: jitter}
+	return s
+}
+
+// AddIntermittentErrorResourceTypeReaction adds an error reaction for all resources of a given resourceType. The given error is
+// returned for the first failTimes invocations of the given method across all resources of the type, later invocations succeed.
+func (s *APIBehaviorSpec) AddIntermittentErrorResourceTypeReaction(resourceType utils.ResourceType, method string, err error, failTimes int) *APIBehaviorSpec {
+	s.initializeResourceTypeReactionMapForResource(resourceType)
+	s.resourceReactionsByType[resourceType][method] = ResourceReaction{err: err, remainingFailures: newRemainingFailures(failTimes)}
+	return s
+}
+
+// AddContextCancellationResourceTypeReaction adds a context cancellation reaction for all resources of a given resourceType when
+// a given method on the resource client is invoked. The context is cancelled as described for AddContextCancellationResourceReaction.
+func (s *APIBehaviorSpec) AddContextCancellationResourceTypeReaction(resourceType utils.ResourceType, method string, cancelFn context.CancelFunc) *APIBehaviorSpec {
+	s.initializeResourceTypeReactionMapForResource(resourceType)
+	s.resourceReactionsByType[resourceType][method] = ResourceReaction{cancel: cancelFn}
+	return s
+}
+
+func newRemainingFailures(failTimes int) *atomic.Int64 {
+	remainingFailures := &atomic.Int64{}
+	remainingFailures.Store(int64(failTimes))
+	return remainingFailures
+}
+
 func (s *APIBehaviorSpec) initializeResourceReactionMapForResource(resourceName string) {
 	if _, ok := s.resourceReactionsByName[resourceName]; !ok {
 		s.resourceReactionsByName[resourceName] = make(map[string]ResourceReaction)
@@ -117,9 +184,36 @@ func doSimulate(ctx context.Context, reaction *ResourceReaction, panicMsg string
 	if reaction.timeoutAfter != nil {
 		return testhelp.ContextTimeoutError(ctx, *reaction.timeoutAfter)
 	}
+	if reaction.delay != nil {
+		return delay(ctx, *reaction.delay, reaction.jitter)
+	}
+	if reaction.cancel != nil {
+		reaction.cancel()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return context.Canceled
+	}
+	if reaction.remainingFailures != nil && reaction.remainingFailures.Add(-1) < 0 {
+		return nil
+	}
 	return reaction.err
 }
 
+func delay(ctx context.Context, delay, jitter time.Duration) error {
+	if jitter > 0 {
+		delay += rand.N(jitter) // #nosec G404 -- Test only
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 func (s *APIBehaviorSpec) getResourceReaction(resourceName, method string) *ResourceReaction {
 	resourceReactionMap, ok := s.resourceReactionsByName[resourceName]
 	if !ok {
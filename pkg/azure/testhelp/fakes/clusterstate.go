@@ -6,6 +6,8 @@ package fakes
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
@@ -40,6 +42,31 @@ type ClusterState struct {
 	// SubnetSpec is the subnet spec that is used to configure all NICs.
 	// Currently, we only support one subnet as that is sufficient for unit testing.
 	SubnetSpec *SubnetSpec
+	// ResourceSKUs are the virtual machine resource SKUs that are available in the location of the ProviderSpec.
+	ResourceSKUs []*armcompute.ResourceSKU
+	// Usages are the compute resource usages in the location of the ProviderSpec.
+	Usages []*armcompute.Usage
+	// GalleryImages are the images in shared or community galleries. Key is created using createGalleryImageKey.
+	GalleryImages map[string]GalleryImageSpec
+	// PublicIPs are the public IP addresses in the resource group of the ProviderSpec, keyed by their name.
+	PublicIPs map[string]*armnetwork.PublicIPAddress
+	// ForceDeletedVMNames are the names of the VMs which have been deleted with force deletion.
+	ForceDeletedVMNames []string
+	// CreatedDisks are the disks which have been created explicitly before a VM, keyed by their name. Disks which are
+	// created together with a VM are part of the MachineResources instead.
+	CreatedDisks map[string]*armcompute.Disk
+	// AllocationFailedZones are the zones in which VMs can not be allocated due to insufficient capacity.
+	AllocationFailedZones []int
+}
+
+// GalleryImageSpec is the spec for an image in a shared or community gallery.
+type GalleryImageSpec struct {
+	// Versions are the names of all versions of the image.
+	Versions []string
+	// VersionsExcludedFromLatest are the names of the versions which should not be considered when resolving the latest version.
+	VersionsExcludedFromLatest []string
+	// PurchasePlan is the purchase plan of the image. It is set for gallery images which have been created from marketplace images.
+	PurchasePlan *armcompute.ImagePurchasePlan
 }
 
 // SubnetSpec is the spec that captures the subnet configuration.
@@ -66,6 +93,8 @@ type VMImageSpec struct {
 	OfferType armmarketplaceordering.OfferType
 	// PlanExists is a flag to indicate if the VMImageSpec has a plan.
 	PlanExists bool
+	// HyperVGeneration is the Hyper-V generation of the image. If not set then the image does not report a generation.
+	HyperVGeneration *armcompute.HyperVGenerationTypes
 }
 
 // DiskType is used as an enum type to define types of disks that can be associated to a VM.
@@ -84,7 +113,68 @@ func NewClusterState(providerSpec api.AzureProviderSpec) *ClusterState {
 	return &ClusterState{
 		ProviderSpec:        providerSpec,
 		MachineResourcesMap: make(map[string]MachineResources),
+		GalleryImages:       make(map[string]GalleryImageSpec),
+		PublicIPs:           make(map[string]*armnetwork.PublicIPAddress),
+		CreatedDisks:        make(map[string]*armcompute.Disk),
+	}
+}
+
+// AddPublicIP adds a public IP address with the passed name, tags and optional ID of the IP configuration it is associated to.
+func (c *ClusterState) AddPublicIP(publicIPName string, tags map[string]*string, ipConfigurationID *string) {
+	publicIP := &armnetwork.PublicIPAddress{
+		ID:         to.Ptr(CreatePublicIPAddressID(testhelp.SubscriptionID, c.ProviderSpec.ResourceGroup, publicIPName)),
+		Name:       to.Ptr(publicIPName),
+		Location:   to.Ptr(c.ProviderSpec.Location),
+		Tags:       tags,
+		Properties: &armnetwork.PublicIPAddressPropertiesFormat{},
+	}
+	if ipConfigurationID != nil {
+		publicIP.Properties.IPConfiguration = &armnetwork.IPConfiguration{ID: ipConfigurationID}
+	}
+	c.PublicIPs[publicIPName] = publicIP
+}
+
+// GetPublicIP gets the public IP address matching publicIPName.
+func (c *ClusterState) GetPublicIP(publicIPName string) *armnetwork.PublicIPAddress {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.PublicIPs[publicIPName]
+}
+
+// CreatePublicIP creates or updates a public IP address with the passed name and parameters in ClusterState.
+func (c *ClusterState) CreatePublicIP(publicIPName string, publicIP *armnetwork.PublicIPAddress) *armnetwork.PublicIPAddress {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	publicIP.ID = to.Ptr(CreatePublicIPAddressID(testhelp.SubscriptionID, c.ProviderSpec.ResourceGroup, publicIPName))
+	publicIP.Name = to.Ptr(publicIPName)
+	if publicIP.Properties == nil {
+		publicIP.Properties = &armnetwork.PublicIPAddressPropertiesFormat{}
 	}
+	c.PublicIPs[publicIPName] = publicIP
+	return publicIP
+}
+
+// ListPublicIPs returns all public IP addresses in ClusterState sorted by their name.
+func (c *ClusterState) ListPublicIPs() []*armnetwork.PublicIPAddress {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	publicIPNames := make([]string, 0, len(c.PublicIPs))
+	for publicIPName := range c.PublicIPs {
+		publicIPNames = append(publicIPNames, publicIPName)
+	}
+	slices.Sort(publicIPNames)
+	publicIPs := make([]*armnetwork.PublicIPAddress, 0, len(publicIPNames))
+	for _, publicIPName := range publicIPNames {
+		publicIPs = append(publicIPs, c.PublicIPs[publicIPName])
+	}
+	return publicIPs
+}
+
+// DeletePublicIP deletes the public IP address matching publicIPName.
+func (c *ClusterState) DeletePublicIP(publicIPName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.PublicIPs, publicIPName)
 }
 
 // AddMachineResources adds MachineResources against a VM/Machine name.
@@ -150,6 +240,78 @@ func (c *ClusterState) WithSubnet(resourceGroup, subnetName, vnetName string) *C
 	return c
 }
 
+// WithVMSizeResourceSKU adds a virtual machine resource SKU for the given vmSize having the passed capabilities and returns the ClusterState.
+func (c *ClusterState) WithVMSizeResourceSKU(vmSize string, capabilities map[string]string) *ClusterState {
+	skuCapabilities := make([]*armcompute.ResourceSKUCapabilities, 0, len(capabilities))
+	for name, value := range capabilities {
+		skuCapabilities = append(skuCapabilities, &armcompute.ResourceSKUCapabilities{
+			Name:  to.Ptr(name),
+			Value: to.Ptr(value),
+		})
+	}
+	c.ResourceSKUs = append(c.ResourceSKUs, &armcompute.ResourceSKU{
+		Name:         to.Ptr(vmSize),
+		ResourceType: to.Ptr("virtualMachines"),
+		Locations:    []*string{to.Ptr(c.ProviderSpec.Location)},
+		Capabilities: skuCapabilities,
+	})
+	return c
+}
+
+// WithVMSizeResourceSKUZones sets the zones of the location of the ProviderSpec in which the virtual machine resource SKU for
+// the given vmSize is offered and returns the ClusterState.
+func (c *ClusterState) WithVMSizeResourceSKUZones(vmSize string, zones ...string) *ClusterState {
+	for _, sku := range c.ResourceSKUs {
+		if *sku.Name == vmSize {
+			sku.LocationInfo = []*armcompute.ResourceSKULocationInfo{{Location: to.Ptr(c.ProviderSpec.Location), Zones: to.SliceOfPtrs(zones...)}}
+		}
+	}
+	return c
+}
+
+// WithVMSizeResourceSKUFamily sets the family of the virtual machine resource SKU for the given vmSize and returns the ClusterState.
+func (c *ClusterState) WithVMSizeResourceSKUFamily(vmSize, family string) *ClusterState {
+	for _, sku := range c.ResourceSKUs {
+		if *sku.Name == vmSize {
+			sku.Family = to.Ptr(family)
+		}
+	}
+	return c
+}
+
+// WithUsage adds a compute resource usage with the given name, current value and limit and returns the ClusterState.
+func (c *ClusterState) WithUsage(name string, currentValue int32, limit int64) *ClusterState {
+	c.Usages = append(c.Usages, &armcompute.Usage{
+		Name:         &armcompute.UsageName{Value: to.Ptr(name), LocalizedValue: to.Ptr(name)},
+		CurrentValue: to.Ptr(currentValue),
+		Limit:        to.Ptr(limit),
+		Unit:         to.Ptr("Count"),
+	})
+	return c
+}
+
+// WithAllocationFailedZones sets the zones in which VMs can not be allocated and returns the ClusterState.
+func (c *ClusterState) WithAllocationFailedZones(zones ...int) *ClusterState {
+	c.AllocationFailedZones = zones
+	return c
+}
+
+// IsAllocationFailedZone checks if VMs can not be allocated in one of the passed zones.
+func (c *ClusterState) IsAllocationFailedZone(zones []*string) bool {
+	for _, failedZone := range c.AllocationFailedZones {
+		if slices.ContainsFunc(zones, func(zone *string) bool { return zone != nil && *zone == strconv.Itoa(failedZone) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithGalleryImage initializes ClusterState with an image in a shared or community gallery.
+func (c *ClusterState) WithGalleryImage(galleryName, imageName string, spec GalleryImageSpec) *ClusterState {
+	c.GalleryImages[createGalleryImageKey(galleryName, imageName)] = spec
+	return c
+}
+
 // ----------------------------------------------------------------------------------------------------------
 
 // ResourceGroupExists checks if a passed in resourceGroupName has been configured in the ClusterState.
@@ -186,6 +348,7 @@ func (c *ClusterState) GetVirtualMachineImage(vmImageSpec VMImageSpec) *armcompu
 			},
 			ImageDeprecationStatus: &armcompute.ImageDeprecationStatus{ImageState: to.Ptr(armcompute.ImageStateActive)},
 			OSDiskImage:            &armcompute.OSDiskImage{OperatingSystem: to.Ptr(armcompute.OperatingSystemTypesLinux)},
+			HyperVGeneration:       c.VMImageSpec.HyperVGeneration,
 		},
 	}
 	if c.VMImageSpec.PlanExists {
@@ -245,6 +408,32 @@ func (c *ClusterState) MarkVirtualMachineInTerminalState(vmName string) bool {
 	return false
 }
 
+// SetVirtualMachineProvisioningState sets the provisioning state of the virtual machine.
+func (c *ClusterState) SetVirtualMachineProvisioningState(vmName string, provisioningState string) bool {
+	if machineResources, ok := c.MachineResourcesMap[vmName]; ok {
+		if machineResources.VM == nil || machineResources.VM.Properties == nil {
+			return false
+		}
+		machineResources.VM.Properties.ProvisioningState = to.Ptr(provisioningState)
+		return true
+	}
+	// There is no VM with the given vmName
+	return false
+}
+
+// SetVirtualMachinePowerState sets the power state of the virtual machine.
+func (c *ClusterState) SetVirtualMachinePowerState(vmName string, powerState string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if machineResources, ok := c.MachineResourcesMap[vmName]; ok && machineResources.VM != nil {
+		machineResources.PowerState = powerState
+		c.MachineResourcesMap[vmName] = machineResources
+		return true
+	}
+	// There is no VM with the given vmName
+	return false
+}
+
 // MarkAllDataDisksInDetachment marks all data disks that are captured as part of VirtualMachine.Properties.StorageProfile.DataDisks as currently being detached.
 func (c *ClusterState) MarkAllDataDisksInDetachment(vmName string) bool {
 	if machineResources, ok := c.MachineResourcesMap[vmName]; ok {
@@ -288,6 +477,7 @@ func (c *ClusterState) DeleteVM(vmName string) {
 	m.HandleOSDiskOnVMDelete()
 	m.HandleDataDisksOnVMDelete()
 	m.VM = nil
+	m.Extensions = nil
 
 	if !m.HasResources() {
 		delete(c.MachineResourcesMap, vmName)
@@ -315,6 +505,32 @@ func (c *ClusterState) CreateVM(resourceGroup string, vmParams armcompute.Virtua
 	return nil, err
 }
 
+// CreateVMExtension creates a new extension for the VM with the passed vmName.
+func (c *ClusterState) CreateVMExtension(vmName string, extensionParams armcompute.VirtualMachineExtension) (*armcompute.VirtualMachineExtension, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	machineResources, ok := c.MachineResourcesMap[vmName]
+	if !ok || machineResources.VM == nil {
+		return nil, testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound)
+	}
+	if machineResources.Extensions == nil {
+		machineResources.Extensions = make(map[string]*armcompute.VirtualMachineExtension)
+	}
+	extensionID := fmt.Sprintf("%s/extensions/%s", *machineResources.VM.ID, *extensionParams.Name)
+	extensionParams.ID = &extensionID
+	machineResources.Extensions[*extensionParams.Name] = &extensionParams
+	c.MachineResourcesMap[vmName] = machineResources
+	return &extensionParams, nil
+}
+
+// GetVMExtension gets the extension with the passed extensionName of the VM with the passed vmName.
+func (c *ClusterState) GetVMExtension(vmName, extensionName string) *armcompute.VirtualMachineExtension {
+	if machineResources, ok := c.MachineResourcesMap[vmName]; ok {
+		return machineResources.Extensions[extensionName]
+	}
+	return nil
+}
+
 // GetNIC gets a NIC matching the passed name if one exists.
 func (c *ClusterState) GetNIC(nicName string) *armnetwork.Interface {
 	for _, m := range c.MachineResourcesMap {
@@ -374,6 +590,17 @@ func (c *ClusterState) GetDisk(diskName string) *armcompute.Disk {
 	}
 }
 
+// CreateDisk creates a disk in the resourceGroup using diskParams and records it in CreatedDisks.
+func (c *ClusterState) CreateDisk(resourceGroup, diskName string, diskParams armcompute.Disk) *armcompute.Disk {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	disk := diskParams
+	disk.ID = to.Ptr(CreateDiskID(testhelp.SubscriptionID, resourceGroup, diskName))
+	disk.Name = to.Ptr(diskName)
+	c.CreatedDisks[diskName] = &disk
+	return &disk
+}
+
 // DeleteDisk deletes the disk matching diskName.
 func (c *ClusterState) DeleteDisk(diskName string) {
 	c.mutex.Lock()
@@ -398,52 +625,65 @@ func (c *ClusterState) DeleteDisk(diskName string) {
 	}
 }
 
-// GetVMsMatchingTagKeys returns VM names for all configured VMs in the ClusterState that has all tagKeys.
-func (c *ClusterState) GetVMsMatchingTagKeys(tagKeys []string) []string {
-	vmNames := make([]string, 0, len(c.MachineResourcesMap))
-	for vmName, mr := range c.MachineResourcesMap {
+// GetVMsMatchingTagKeys returns all configured VMs in the ClusterState that have all tagKeys.
+func (c *ClusterState) GetVMsMatchingTagKeys(tagKeys []string) []*armcompute.VirtualMachine {
+	vms := make([]*armcompute.VirtualMachine, 0, len(c.MachineResourcesMap))
+	for _, mr := range c.MachineResourcesMap {
 		if mr.VM != nil {
 			// check if all tag keys are present for this VM
 			if containsAllTagKeys(mr.VM.Tags, tagKeys) {
-				vmNames = append(vmNames, vmName)
+				vms = append(vms, mr.VM)
 			}
 		}
 	}
-	return vmNames
+	return vms
 }
 
-// GetNICNamesMatchingTagKeys returns NIC names in ClusterState that has all tagKeys.
-func (c *ClusterState) GetNICNamesMatchingTagKeys(tagKeys []string) []string {
-	nicNames := make([]string, 0, len(c.MachineResourcesMap))
+// GetNICsMatchingTagKeys returns NICs in ClusterState that have all tagKeys.
+func (c *ClusterState) GetNICsMatchingTagKeys(tagKeys []string) []*armnetwork.Interface {
+	nics := make([]*armnetwork.Interface, 0, len(c.MachineResourcesMap))
 	for _, mr := range c.MachineResourcesMap {
 		if mr.NIC != nil {
 			// check if all tag keys are present for this NIC
 			if containsAllTagKeys(mr.NIC.Tags, tagKeys) {
-				nicNames = append(nicNames, *mr.NIC.Name)
+				nics = append(nics, mr.NIC)
 			}
 		}
 	}
-	return nicNames
+	return nics
+}
+
+// GetPublicIPsMatchingTagKeys returns all public IP addresses in ClusterState that have all tagKeys.
+func (c *ClusterState) GetPublicIPsMatchingTagKeys(tagKeys []string) []*armnetwork.PublicIPAddress {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	publicIPs := make([]*armnetwork.PublicIPAddress, 0, len(c.PublicIPs))
+	for _, publicIP := range c.PublicIPs {
+		if containsAllTagKeys(publicIP.Tags, tagKeys) {
+			publicIPs = append(publicIPs, publicIP)
+		}
+	}
+	return publicIPs
 }
 
-// GetDiskNamesMatchingTagKeys returns Disk (OS and Data disk) names in ClusterState that has all tagKeys.
-func (c *ClusterState) GetDiskNamesMatchingTagKeys(tagKeys []string) []string {
-	var diskNames []string
+// GetDisksMatchingTagKeys returns Disks (OS and Data disk) in ClusterState that have all tagKeys.
+func (c *ClusterState) GetDisksMatchingTagKeys(tagKeys []string) []*armcompute.Disk {
+	var disks []*armcompute.Disk
 	for _, mr := range c.MachineResourcesMap {
 		if mr.OSDisk != nil {
 			if containsAllTagKeys(mr.OSDisk.Tags, tagKeys) {
-				diskNames = append(diskNames, *mr.OSDisk.Name)
+				disks = append(disks, mr.OSDisk)
 			}
 		}
 		if mr.DataDisks != nil {
 			for _, disk := range mr.DataDisks {
 				if containsAllTagKeys(disk.Tags, tagKeys) {
-					diskNames = append(diskNames, *disk.Name)
+					disks = append(disks, disk)
 				}
 			}
 		}
 	}
-	return diskNames
+	return disks
 }
 
 func containsAllTagKeys(resourceTags map[string]*string, tagKeys []string) bool {
@@ -498,3 +738,17 @@ func vmImageSpecURNPartsEqual(spec1 VMImageSpec, spec2 VMImageSpec) bool {
 		spec1.Publisher == spec2.Publisher &&
 		spec1.Version == spec2.Version
 }
+
+// GetGalleryImage gets the GalleryImageSpec for an image in a shared or community gallery. If the image does not exist then nil is returned.
+func (c *ClusterState) GetGalleryImage(galleryName, imageName string) *GalleryImageSpec {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if spec, ok := c.GalleryImages[createGalleryImageKey(galleryName, imageName)]; ok {
+		return &spec
+	}
+	return nil
+}
+
+func createGalleryImageKey(galleryName, imageName string) string {
+	return strings.ToLower(galleryName + "/" + imageName)
+}
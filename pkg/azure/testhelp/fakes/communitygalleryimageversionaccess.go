@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package fakes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	fakecompute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+	"golang.org/x/exp/slices"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// CommunityGalleryImageVersionsAccessBuilder is a builder for community gallery image versions access.
+type CommunityGalleryImageVersionsAccessBuilder struct {
+	clusterState    *ClusterState
+	server          fakecompute.CommunityGalleryImageVersionsServer
+	apiBehaviorSpec *APIBehaviorSpec
+}
+
+// WithClusterState initializes builder with a ClusterState.
+func (b *CommunityGalleryImageVersionsAccessBuilder) WithClusterState(clusterState *ClusterState) *CommunityGalleryImageVersionsAccessBuilder {
+	b.clusterState = clusterState
+	return b
+}
+
+// WithAPIBehaviorSpec initializes the builder with a APIBehaviorSpec.
+func (b *CommunityGalleryImageVersionsAccessBuilder) WithAPIBehaviorSpec(apiBehaviorSpec *APIBehaviorSpec) *CommunityGalleryImageVersionsAccessBuilder {
+	b.apiBehaviorSpec = apiBehaviorSpec
+	return b
+}
+
+// withNewListPager implements the NewListPager method of armcompute.CommunityGalleryImageVersionsClient and initializes the backing fake server's NewListPager method with the anonymous function implementation.
+func (b *CommunityGalleryImageVersionsAccessBuilder) withNewListPager() *CommunityGalleryImageVersionsAccessBuilder {
+	b.server.NewListPager = func(_ string, galleryName string, imageName string, _ *armcompute.CommunityGalleryImageVersionsClientListOptions) (resp azfake.PagerResponder[armcompute.CommunityGalleryImageVersionsClientListResponse]) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResourceType(context.Background(), b.clusterState.ProviderSpec.ResourceGroup, to.Ptr(utils.GalleryImageVersionResourceType), testhelp.AccessMethodNewListPager)
+			if err != nil {
+				resp.AddError(err)
+				return
+			}
+		}
+		galleryImage := b.clusterState.GetGalleryImage(galleryName, imageName)
+		if galleryImage == nil {
+			resp.AddError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeGalleryImageNotFound))
+			return
+		}
+		versions := make([]*armcompute.CommunityGalleryImageVersion, 0, len(galleryImage.Versions))
+		for _, version := range galleryImage.Versions {
+			versions = append(versions, &armcompute.CommunityGalleryImageVersion{
+				Name: to.Ptr(version),
+				Properties: &armcompute.CommunityGalleryImageVersionProperties{
+					ExcludeFromLatest: to.Ptr(slices.Contains(galleryImage.VersionsExcludedFromLatest, version)),
+				},
+			})
+		}
+		resp.AddPage(http.StatusOK, armcompute.CommunityGalleryImageVersionsClientListResponse{
+			CommunityGalleryImageVersionList: armcompute.CommunityGalleryImageVersionList{
+				Value: versions,
+			},
+		}, nil)
+		return
+	}
+	return b
+}
+
+// Build builds armcompute.CommunityGalleryImageVersionsClient.
+func (b *CommunityGalleryImageVersionsAccessBuilder) Build() (*armcompute.CommunityGalleryImageVersionsClient, error) {
+	b.withNewListPager()
+	return armcompute.NewCommunityGalleryImageVersionsClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: fakecompute.NewCommunityGalleryImageVersionsServerTransport(&b.server),
+		},
+	})
+}
@@ -90,9 +90,65 @@ func (b *DiskAccessBuilder) withBeginDelete() *DiskAccessBuilder {
 	return b
 }
 
+// withBeginUpdate implements the BeginUpdate method of armcompute.DisksClient and initializes the backing fake server's BeginUpdate method with the anonymous function implementation.
+// Currently only the update of the performance tier and of the tags is supported.
+func (b *DiskAccessBuilder) withBeginUpdate() *DiskAccessBuilder {
+	b.server.BeginUpdate = func(ctx context.Context, resourceGroupName string, diskName string, diskUpdate armcompute.DiskUpdate, _ *armcompute.DisksClientBeginUpdateOptions) (resp azfake.PollerResponder[armcompute.DisksClientUpdateResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, diskName, testhelp.AccessMethodBeginUpdate)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		disk := b.clusterState.GetDisk(diskName)
+		if disk == nil {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound))
+			return
+		}
+		if diskUpdate.Properties != nil && diskUpdate.Properties.Tier != nil {
+			if disk.Properties == nil {
+				disk.Properties = &armcompute.DiskProperties{}
+			}
+			disk.Properties.Tier = diskUpdate.Properties.Tier
+		}
+		if diskUpdate.Tags != nil {
+			disk.Tags = diskUpdate.Tags
+		}
+		resp.SetTerminalResponse(http.StatusOK, armcompute.DisksClientUpdateResponse{Disk: *disk}, nil)
+		return
+	}
+	return b
+}
+
+// withBeginCreateOrUpdate implements the BeginCreateOrUpdate method of armcompute.DisksClient and initializes the backing fake server's BeginCreateOrUpdate method with the anonymous function implementation.
+func (b *DiskAccessBuilder) withBeginCreateOrUpdate() *DiskAccessBuilder {
+	b.server.BeginCreateOrUpdate = func(ctx context.Context, resourceGroupName string, diskName string, disk armcompute.Disk, _ *armcompute.DisksClientBeginCreateOrUpdateOptions) (resp azfake.PollerResponder[armcompute.DisksClientCreateOrUpdateResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, diskName, testhelp.AccessMethodBeginCreateOrUpdate)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		createdDisk := b.clusterState.CreateDisk(resourceGroupName, diskName, disk)
+		resp.SetTerminalResponse(http.StatusOK, armcompute.DisksClientCreateOrUpdateResponse{Disk: *createdDisk}, nil)
+		return
+	}
+	return b
+}
+
 // Build builds the armcompute.DiskClient.
 func (b *DiskAccessBuilder) Build() (*armcompute.DisksClient, error) {
-	b.withGet().withBeginDelete()
+	b.withGet().withBeginDelete().withBeginUpdate().withBeginCreateOrUpdate()
 	return armcompute.NewDisksClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
 		ClientOptions: policy.ClientOptions{
 			Transport: fakecompute.NewDisksServerTransport(&b.server),
@@ -34,6 +34,8 @@ type Factory struct {
 	ResourceGroupAccess *armresources.ResourceGroupsClient
 	// InterfaceAccess provides access to network interfaces.
 	InterfaceAccess *armnetwork.InterfacesClient
+	// PublicIPAddressesAccess provides access to public IP addresses.
+	PublicIPAddressesAccess *armnetwork.PublicIPAddressesClient
 	// SubnetAccess provides access to subnets.
 	SubnetAccess *armnetwork.SubnetsClient
 	// DiskAccess provides access to disks.
@@ -44,6 +46,20 @@ type Factory struct {
 	VMImageAccess *armcompute.VirtualMachineImagesClient
 	// MarketplaceAgreementsAccess provides access to market-place ordering agreements.
 	MarketplaceAgreementsAccess *armmarketplaceordering.MarketplaceAgreementsClient
+	// ResourceSKUsAccess provides access to resource SKUs.
+	ResourceSKUsAccess *armcompute.ResourceSKUsClient
+	// UsageAccess provides access to the compute resource usages.
+	UsageAccess *armcompute.UsageClient
+	// VMExtensionsAccess provides access to virtual machine extensions.
+	VMExtensionsAccess *armcompute.VirtualMachineExtensionsClient
+	// SharedGalleryImageVersionsAccess provides access to image versions in shared galleries.
+	SharedGalleryImageVersionsAccess *armcompute.SharedGalleryImageVersionsClient
+	// CommunityGalleryImageVersionsAccess provides access to image versions in community galleries.
+	CommunityGalleryImageVersionsAccess *armcompute.CommunityGalleryImageVersionsClient
+	// SharedGalleryImagesAccess provides access to images in shared galleries.
+	SharedGalleryImagesAccess *armcompute.SharedGalleryImagesClient
+	// CommunityGalleryImagesAccess provides access to images in community galleries.
+	CommunityGalleryImagesAccess *armcompute.CommunityGalleryImagesClient
 }
 
 // Fake implementation methods of access.Factory interface.
@@ -64,6 +80,11 @@ func (f *Factory) GetNetworkInterfacesAccess(_ access.ConnectConfig) (*armnetwor
 	return f.InterfaceAccess, nil
 }
 
+// GetPublicIPAddressesAccess gets the configured public IP addresses access.
+func (f *Factory) GetPublicIPAddressesAccess(_ access.ConnectConfig) (*armnetwork.PublicIPAddressesClient, error) {
+	return f.PublicIPAddressesAccess, nil
+}
+
 // GetSubnetAccess gets the configured subnet access.
 func (f *Factory) GetSubnetAccess(_ access.ConnectConfig) (*armnetwork.SubnetsClient, error) {
 	return f.SubnetAccess, nil
@@ -89,6 +110,41 @@ func (f *Factory) GetMarketPlaceAgreementsAccess(_ access.ConnectConfig) (*armma
 	return f.MarketplaceAgreementsAccess, nil
 }
 
+// GetResourceSKUsAccess gets the configured access for resource SKUs.
+func (f *Factory) GetResourceSKUsAccess(_ access.ConnectConfig) (*armcompute.ResourceSKUsClient, error) {
+	return f.ResourceSKUsAccess, nil
+}
+
+// GetUsageAccess gets the configured access for compute resource usages.
+func (f *Factory) GetUsageAccess(_ access.ConnectConfig) (*armcompute.UsageClient, error) {
+	return f.UsageAccess, nil
+}
+
+// GetVirtualMachineExtensionsAccess gets the configured access for virtual machine extensions.
+func (f *Factory) GetVirtualMachineExtensionsAccess(_ access.ConnectConfig) (*armcompute.VirtualMachineExtensionsClient, error) {
+	return f.VMExtensionsAccess, nil
+}
+
+// GetSharedGalleryImageVersionsAccess gets the configured access for shared gallery image versions.
+func (f *Factory) GetSharedGalleryImageVersionsAccess(_ access.ConnectConfig) (*armcompute.SharedGalleryImageVersionsClient, error) {
+	return f.SharedGalleryImageVersionsAccess, nil
+}
+
+// GetCommunityGalleryImageVersionsAccess gets the configured access for community gallery image versions.
+func (f *Factory) GetCommunityGalleryImageVersionsAccess(_ access.ConnectConfig) (*armcompute.CommunityGalleryImageVersionsClient, error) {
+	return f.CommunityGalleryImageVersionsAccess, nil
+}
+
+// GetSharedGalleryImagesAccess gets the configured access for shared gallery images.
+func (f *Factory) GetSharedGalleryImagesAccess(_ access.ConnectConfig) (*armcompute.SharedGalleryImagesClient, error) {
+	return f.SharedGalleryImagesAccess, nil
+}
+
+// GetCommunityGalleryImagesAccess gets the configured access for community gallery images.
+func (f *Factory) GetCommunityGalleryImagesAccess(_ access.ConnectConfig) (*armcompute.CommunityGalleryImagesClient, error) {
+	return f.CommunityGalleryImagesAccess, nil
+}
+
 // --------------------------------------------------------------------------------------------
 // Builder methods to allow partial initialization of fake Factory.
 // --------------------------------------------------------------------------------------------
@@ -115,6 +171,13 @@ func (f *Factory) NewNICAccessBuilder() *NICAccessBuilder {
 	}
 }
 
+// NewPublicIPAddressAccessBuilder creates a new PublicIPAddressAccessBuilder.
+func (f *Factory) NewPublicIPAddressAccessBuilder() *PublicIPAddressAccessBuilder {
+	return &PublicIPAddressAccessBuilder{
+		server: fakenetwork.PublicIPAddressesServer{},
+	}
+}
+
 // NewDiskAccessBuilder creates a new DiskAccessBuilder.
 func (f *Factory) NewDiskAccessBuilder() *DiskAccessBuilder {
 	return &DiskAccessBuilder{
@@ -150,6 +213,55 @@ func (f *Factory) NewMarketPlaceAgreementAccessBuilder() *MarketPlaceAgreementAc
 	}
 }
 
+// NewResourceSKUsAccessBuilder creates a new ResourceSKUsAccessBuilder.
+func (f *Factory) NewResourceSKUsAccessBuilder() *ResourceSKUsAccessBuilder {
+	return &ResourceSKUsAccessBuilder{
+		server: fakecompute.ResourceSKUsServer{},
+	}
+}
+
+// NewUsageAccessBuilder creates a new UsageAccessBuilder.
+func (f *Factory) NewUsageAccessBuilder() *UsageAccessBuilder {
+	return &UsageAccessBuilder{
+		server: fakecompute.UsageServer{},
+	}
+}
+
+// NewVMExtensionsAccessBuilder creates a new VMExtensionsAccessBuilder.
+func (f *Factory) NewVMExtensionsAccessBuilder() *VMExtensionsAccessBuilder {
+	return &VMExtensionsAccessBuilder{
+		server: fakecompute.VirtualMachineExtensionsServer{},
+	}
+}
+
+// NewSharedGalleryImageVersionsAccessBuilder creates a new SharedGalleryImageVersionsAccessBuilder.
+func (f *Factory) NewSharedGalleryImageVersionsAccessBuilder() *SharedGalleryImageVersionsAccessBuilder {
+	return &SharedGalleryImageVersionsAccessBuilder{
+		server: fakecompute.SharedGalleryImageVersionsServer{},
+	}
+}
+
+// NewCommunityGalleryImageVersionsAccessBuilder creates a new CommunityGalleryImageVersionsAccessBuilder.
+func (f *Factory) NewCommunityGalleryImageVersionsAccessBuilder() *CommunityGalleryImageVersionsAccessBuilder {
+	return &CommunityGalleryImageVersionsAccessBuilder{
+		server: fakecompute.CommunityGalleryImageVersionsServer{},
+	}
+}
+
+// NewSharedGalleryImagesAccessBuilder creates a new SharedGalleryImagesAccessBuilder.
+func (f *Factory) NewSharedGalleryImagesAccessBuilder() *SharedGalleryImagesAccessBuilder {
+	return &SharedGalleryImagesAccessBuilder{
+		server: fakecompute.SharedGalleryImagesServer{},
+	}
+}
+
+// NewCommunityGalleryImagesAccessBuilder creates a new CommunityGalleryImagesAccessBuilder.
+func (f *Factory) NewCommunityGalleryImagesAccessBuilder() *CommunityGalleryImagesAccessBuilder {
+	return &CommunityGalleryImagesAccessBuilder{
+		server: fakecompute.CommunityGalleryImagesServer{},
+	}
+}
+
 // WithVirtualMachineAccess initializes Factory with VM access.
 func (f *Factory) WithVirtualMachineAccess(vmAccess *armcompute.VirtualMachinesClient) *Factory {
 	f.VMAccess = vmAccess
@@ -168,6 +280,12 @@ func (f *Factory) WithNetworkInterfacesAccess(nwiAccess *armnetwork.InterfacesCl
 	return f
 }
 
+// WithPublicIPAddressesAccess initializes Factory with public IP addresses access.
+func (f *Factory) WithPublicIPAddressesAccess(publicIPAccess *armnetwork.PublicIPAddressesClient) *Factory {
+	f.PublicIPAddressesAccess = publicIPAccess
+	return f
+}
+
 // WithSubnetAccess initializes Factory with Subnet access.
 func (f *Factory) WithSubnetAccess(subnetAccess *armnetwork.SubnetsClient) *Factory {
 	f.SubnetAccess = subnetAccess
@@ -197,3 +315,45 @@ func (f *Factory) WithMarketPlaceAgreementsAccess(mpaAccess *armmarketplaceorder
 	f.MarketplaceAgreementsAccess = mpaAccess
 	return f
 }
+
+// WithResourceSKUsAccess initializes Factory with Resource SKUs access.
+func (f *Factory) WithResourceSKUsAccess(skuAccess *armcompute.ResourceSKUsClient) *Factory {
+	f.ResourceSKUsAccess = skuAccess
+	return f
+}
+
+// WithUsageAccess initializes Factory with compute resource usage access.
+func (f *Factory) WithUsageAccess(usageAccess *armcompute.UsageClient) *Factory {
+	f.UsageAccess = usageAccess
+	return f
+}
+
+// WithVirtualMachineExtensionsAccess initializes Factory with VM Extensions access.
+func (f *Factory) WithVirtualMachineExtensionsAccess(vmExtensionsAccess *armcompute.VirtualMachineExtensionsClient) *Factory {
+	f.VMExtensionsAccess = vmExtensionsAccess
+	return f
+}
+
+// WithSharedGalleryImageVersionsAccess initializes Factory with shared gallery image versions access.
+func (f *Factory) WithSharedGalleryImageVersionsAccess(versionsAccess *armcompute.SharedGalleryImageVersionsClient) *Factory {
+	f.SharedGalleryImageVersionsAccess = versionsAccess
+	return f
+}
+
+// WithCommunityGalleryImageVersionsAccess initializes Factory with community gallery image versions access.
+func (f *Factory) WithCommunityGalleryImageVersionsAccess(versionsAccess *armcompute.CommunityGalleryImageVersionsClient) *Factory {
+	f.CommunityGalleryImageVersionsAccess = versionsAccess
+	return f
+}
+
+// WithSharedGalleryImagesAccess initializes Factory with shared gallery images access.
+func (f *Factory) WithSharedGalleryImagesAccess(imagesAccess *armcompute.SharedGalleryImagesClient) *Factory {
+	f.SharedGalleryImagesAccess = imagesAccess
+	return f
+}
+
+// WithCommunityGalleryImagesAccess initializes Factory with community gallery images access.
+func (f *Factory) WithCommunityGalleryImagesAccess(imagesAccess *armcompute.CommunityGalleryImagesClient) *Factory {
+	f.CommunityGalleryImagesAccess = imagesAccess
+	return f
+}
@@ -6,6 +6,7 @@ package fakes
 
 import (
 	"fmt"
+	"maps"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
@@ -41,6 +42,10 @@ type MachineResources struct {
 	DataDisks map[string]*armcompute.Disk
 	// NIC is the network interface associated to the VM.
 	NIC *armnetwork.Interface
+	// Extensions is the map of extension name to the extensions that are installed on the VM.
+	Extensions map[string]*armcompute.VirtualMachineExtension
+	// PowerState is the power state of the VM as reported in its instance view. An empty value is reported as running.
+	PowerState string
 }
 
 // CascadeDeleteOpts captures the cascade delete options for NIC, OSDisk and DataDisk.
@@ -187,8 +192,10 @@ func updateMachineResourcesFromVMParams(spec api.AzureProviderSpec, resourceGrou
 		}
 		machineResources.NIC.Properties.VirtualMachine.ID = newVM.ID
 	}
-	osDisk := createDiskResource(spec, utils.CreateOSDiskName(vmName), newVM.ID, newVM.Plan)
-	dataDisks := createDataDiskResources(spec, newVM.ID, vmName)
+	osDisk := createDiskResource(spec, utils.CreateOSDiskNameFromTemplate(vmName, spec.Properties.StorageProfile.OsDisk.NameTemplate), newVM.ID, newVM.Plan)
+	// the OSDisk is implicitly created with the VM and therefore inherits the tags of the VM.
+	osDisk.Tags = newVM.Tags
+	dataDisks := createDataDiskResources(spec, newVM.ID, vmName, newVM.Tags)
 	machineResources.OSDisk = osDisk
 	machineResources.DataDisks = dataDisks
 }
@@ -271,13 +278,14 @@ func (b *MachineResourcesBuilder) createMachineResources(createVM, createNIC, cr
 		vmID = vm.ID
 	}
 	if createNIC {
-		nic = createNICResource(b.spec, vmID, utils.CreateNICName(b.vmName))
+		nic = createNICResource(b.spec, vmID, b.vmName)
 	}
 	if createOSDisk {
-		osDisk = createDiskResource(b.spec, utils.CreateOSDiskName(b.vmName), vmID, b.plan)
+		osDisk = createDiskResource(b.spec, utils.CreateOSDiskNameFromTemplate(b.vmName, b.spec.Properties.StorageProfile.OsDisk.NameTemplate), vmID, b.plan)
+		osDisk.Tags = utils.CreateVMTags(b.spec.Tags, b.vmName)
 	}
 	if createDataDisks {
-		dataDisks = createDataDiskResources(b.spec, vmID, b.vmName)
+		dataDisks = createDataDiskResources(b.spec, vmID, b.vmName, utils.CreateVMTags(b.spec.Tags, b.vmName))
 	}
 	return MachineResources{
 		Name:      b.vmName,
@@ -288,17 +296,21 @@ func (b *MachineResourcesBuilder) createMachineResources(createVM, createNIC, cr
 	}
 }
 
-func createDataDiskResources(spec api.AzureProviderSpec, vmID *string, vmName string) map[string]*armcompute.Disk {
+func createDataDiskResources(spec api.AzureProviderSpec, vmID *string, vmName string, vmTags map[string]*string) map[string]*armcompute.Disk {
 	specDataDisks := spec.Properties.StorageProfile.DataDisks
 	dataDisks := make(map[string]*armcompute.Disk, len(specDataDisks))
 	for _, specDataDisk := range specDataDisks {
-		diskName := utils.CreateDataDiskName(vmName, specDataDisk.Name, specDataDisk.Lun)
-		dataDisks[diskName] = createDiskResource(spec, diskName, vmID, nil)
+		diskName := utils.CreateDataDiskNameFromTemplate(vmName, specDataDisk.Name, specDataDisk.Lun, spec.Properties.StorageProfile.DataDiskNameTemplate)
+		dataDisk := createDiskResource(spec, diskName, vmID, nil)
+		// the data disks which are implicitly created with the VM inherit the tags of the VM as the OSDisk does.
+		dataDisk.Tags = maps.Clone(vmTags)
+		dataDisks[diskName] = dataDisk
 	}
 	return dataDisks
 }
 
-func createNICResource(spec api.AzureProviderSpec, vmID *string, nicName string) *armnetwork.Interface {
+func createNICResource(spec api.AzureProviderSpec, vmID *string, vmName string) *armnetwork.Interface {
+	nicName := utils.CreateNICName(vmName)
 	ipConfigID := CreateIPConfigurationID(testhelp.SubscriptionID, spec.ResourceGroup, nicName, nicName)
 	interfaceID := CreateNetworkInterfaceID(testhelp.SubscriptionID, spec.ResourceGroup, nicName)
 
@@ -306,7 +318,7 @@ func createNICResource(spec api.AzureProviderSpec, vmID *string, nicName string)
 		Location: &spec.Location,
 		Properties: &armnetwork.InterfacePropertiesFormat{
 			EnableAcceleratedNetworking: spec.Properties.NetworkProfile.AcceleratedNetworking,
-			EnableIPForwarding:          to.Ptr(true),
+			EnableIPForwarding:          to.Ptr(spec.Properties.NetworkProfile.IPForwarding == nil || *spec.Properties.NetworkProfile.IPForwarding),
 			IPConfigurations: []*armnetwork.InterfaceIPConfiguration{
 				{
 					ID:         &ipConfigID,
@@ -318,7 +330,7 @@ func createNICResource(spec api.AzureProviderSpec, vmID *string, nicName string)
 			NicType:        to.Ptr(armnetwork.NetworkInterfaceNicTypeStandard),
 			VirtualMachine: &armnetwork.SubResource{ID: vmID},
 		},
-		Tags: utils.CreateResourceTags(spec.Tags),
+		Tags: utils.CreateVMTags(spec.Tags, vmName),
 		ID:   &interfaceID,
 		Name: &nicName,
 		Type: to.Ptr("Microsoft.Network/networkInterfaces"),
@@ -359,12 +371,12 @@ func createVMResource(spec api.AzureProviderSpec, vmName string, plan *armcomput
 					ManagedDisk: &armcompute.ManagedDiskParameters{
 						StorageAccountType: to.Ptr(armcompute.StorageAccountTypes(spec.Properties.StorageProfile.OsDisk.ManagedDisk.StorageAccountType)),
 					},
-					Name:   to.Ptr(utils.CreateOSDiskName(vmName)),
+					Name:   to.Ptr(utils.CreateOSDiskNameFromTemplate(vmName, spec.Properties.StorageProfile.OsDisk.NameTemplate)),
 					OSType: to.Ptr(armcompute.OperatingSystemTypesLinux),
 				},
 			},
 		},
-		Tags:  utils.CreateResourceTags(spec.Tags),
+		Tags:  utils.CreateVMTags(spec.Tags, vmName),
 		Zones: []*string{to.Ptr("1")},
 		Name:  to.Ptr(vmName),
 		ID:    to.Ptr(id),
@@ -426,7 +438,7 @@ func createDataDisks(spec api.AzureProviderSpec, vmName string, deleteOption *ar
 	}
 	dataDisks := make([]*armcompute.DataDisk, 0, len(specDataDisks))
 	for _, disk := range specDataDisks {
-		diskName := utils.CreateDataDiskName(vmName, disk.Name, disk.Lun)
+		diskName := utils.CreateDataDiskNameFromTemplate(vmName, disk.Name, disk.Lun, spec.Properties.StorageProfile.DataDiskNameTemplate)
 		d := createDataDisk(disk.Lun, armcompute.CachingTypes(disk.Caching), deleteOption, disk.DiskSizeGB, armcompute.StorageAccountTypes(disk.StorageAccountType), diskName)
 		dataDisks = append(dataDisks, d)
 	}
@@ -110,9 +110,35 @@ func (b *NICAccessBuilder) withBeginCreateOrUpdate() *NICAccessBuilder {
 	return b
 }
 
+// withUpdateTags implements the UpdateTags method of armnetwork.InterfacesClient and initializes the backing fake server's UpdateTags method with the anonymous function implementation.
+func (b *NICAccessBuilder) withUpdateTags() *NICAccessBuilder {
+	b.server.UpdateTags = func(ctx context.Context, resourceGroupName string, nicName string, parameters armnetwork.TagsObject, _ *armnetwork.InterfacesClientUpdateTagsOptions) (resp azfake.Responder[armnetwork.InterfacesClientUpdateTagsResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, nicName, testhelp.AccessMethodUpdateTags)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		nic := b.clusterState.GetNIC(nicName)
+		if nic == nil {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound))
+			return
+		}
+		nic.Tags = parameters.Tags
+		resp.SetResponse(http.StatusOK, armnetwork.InterfacesClientUpdateTagsResponse{Interface: *nic}, nil)
+		return
+	}
+	return b
+}
+
 // Build builds armnetwork.InterfacesClient.
 func (b *NICAccessBuilder) Build() (*armnetwork.InterfacesClient, error) {
-	b.withGet().withBeginDelete().withBeginCreateOrUpdate()
+	b.withGet().withBeginDelete().withBeginCreateOrUpdate().withUpdateTags()
 	return armnetwork.NewInterfacesClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
 		ClientOptions: policy.ClientOptions{
 			Transport: fakenetwork.NewInterfacesServerTransport(&b.server),
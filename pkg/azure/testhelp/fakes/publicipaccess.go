@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package fakes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	fakenetwork "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4/fake"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// PublicIPAddressAccessBuilder is a builder for public IP address access.
+type PublicIPAddressAccessBuilder struct {
+	clusterState    *ClusterState
+	server          fakenetwork.PublicIPAddressesServer
+	apiBehaviorSpec *APIBehaviorSpec
+}
+
+// WithClusterState initializes builder with a ClusterState.
+func (b *PublicIPAddressAccessBuilder) WithClusterState(clusterState *ClusterState) *PublicIPAddressAccessBuilder {
+	b.clusterState = clusterState
+	return b
+}
+
+// WithAPIBehaviorSpec initializes the builder with a APIBehaviorSpec.
+func (b *PublicIPAddressAccessBuilder) WithAPIBehaviorSpec(apiBehaviorSpec *APIBehaviorSpec) *PublicIPAddressAccessBuilder {
+	b.apiBehaviorSpec = apiBehaviorSpec
+	return b
+}
+
+// withGet implements the Get method of armnetwork.PublicIPAddressesClient and initializes the backing fake server's Get method with the anonymous function implementation.
+func (b *PublicIPAddressAccessBuilder) withGet() *PublicIPAddressAccessBuilder {
+	b.server.Get = func(ctx context.Context, resourceGroupName string, publicIPName string, _ *armnetwork.PublicIPAddressesClientGetOptions) (resp azfake.Responder[armnetwork.PublicIPAddressesClientGetResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, publicIPName, testhelp.AccessMethodGet)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		publicIP := b.clusterState.GetPublicIP(publicIPName)
+		if publicIP == nil {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound))
+			return
+		}
+		resp.SetResponse(http.StatusOK, armnetwork.PublicIPAddressesClientGetResponse{PublicIPAddress: *publicIP}, nil)
+		return
+	}
+	return b
+}
+
+// withBeginDelete implements the BeginDelete method of armnetwork.PublicIPAddressesClient and initializes the backing fake server's BeginDelete method with the anonymous function implementation.
+func (b *PublicIPAddressAccessBuilder) withBeginDelete() *PublicIPAddressAccessBuilder {
+	b.server.BeginDelete = func(ctx context.Context, resourceGroupName string, publicIPName string, _ *armnetwork.PublicIPAddressesClientBeginDeleteOptions) (resp azfake.PollerResponder[armnetwork.PublicIPAddressesClientDeleteResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, publicIPName, testhelp.AccessMethodBeginDelete)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		// Azure API public IP deletion does not fail if the public IP does not exist. It still returns 200 Ok.
+		publicIP := b.clusterState.GetPublicIP(publicIPName)
+		if publicIP != nil && publicIP.Properties != nil && publicIP.Properties.IPConfiguration != nil {
+			errResp.SetError(testhelp.ConflictErr(testhelp.ErrorCodeOperationNotAllowed))
+			return
+		}
+		b.clusterState.DeletePublicIP(publicIPName)
+		resp.SetTerminalResponse(http.StatusOK, armnetwork.PublicIPAddressesClientDeleteResponse{}, nil)
+		return
+	}
+	return b
+}
+
+// withBeginCreateOrUpdate implements the BeginCreateOrUpdate method of armnetwork.PublicIPAddressesClient and initializes the backing fake server's BeginCreateOrUpdate method with the anonymous function implementation.
+func (b *PublicIPAddressAccessBuilder) withBeginCreateOrUpdate() *PublicIPAddressAccessBuilder {
+	b.server.BeginCreateOrUpdate = func(ctx context.Context, resourceGroupName string, publicIPName string, parameters armnetwork.PublicIPAddress, _ *armnetwork.PublicIPAddressesClientBeginCreateOrUpdateOptions) (resp azfake.PollerResponder[armnetwork.PublicIPAddressesClientCreateOrUpdateResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, publicIPName, testhelp.AccessMethodBeginCreateOrUpdate)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		publicIP := b.clusterState.CreatePublicIP(publicIPName, &parameters)
+		resp.SetTerminalResponse(http.StatusOK, armnetwork.PublicIPAddressesClientCreateOrUpdateResponse{PublicIPAddress: *publicIP}, nil)
+		return
+	}
+	return b
+}
+
+// withUpdateTags implements the UpdateTags method of armnetwork.PublicIPAddressesClient and initializes the backing fake server's UpdateTags method with the anonymous function implementation.
+func (b *PublicIPAddressAccessBuilder) withUpdateTags() *PublicIPAddressAccessBuilder {
+	b.server.UpdateTags = func(ctx context.Context, resourceGroupName string, publicIPName string, parameters armnetwork.TagsObject, _ *armnetwork.PublicIPAddressesClientUpdateTagsOptions) (resp azfake.Responder[armnetwork.PublicIPAddressesClientUpdateTagsResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, publicIPName, testhelp.AccessMethodUpdateTags)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		publicIP := b.clusterState.GetPublicIP(publicIPName)
+		if publicIP == nil {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound))
+			return
+		}
+		publicIP.Tags = parameters.Tags
+		resp.SetResponse(http.StatusOK, armnetwork.PublicIPAddressesClientUpdateTagsResponse{PublicIPAddress: *publicIP}, nil)
+		return
+	}
+	return b
+}
+
+// withNewListPager implements the NewListPager method of armnetwork.PublicIPAddressesClient and initializes the backing fake server's NewListPager method with the anonymous function implementation.
+func (b *PublicIPAddressAccessBuilder) withNewListPager() *PublicIPAddressAccessBuilder {
+	b.server.NewListPager = func(resourceGroupName string, _ *armnetwork.PublicIPAddressesClientListOptions) (resp azfake.PagerResponder[armnetwork.PublicIPAddressesClientListResponse]) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResourceType(context.Background(), resourceGroupName, to.Ptr(utils.PublicIPAddressResourceType), testhelp.AccessMethodNewListPager)
+			if err != nil {
+				resp.AddError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			resp.AddError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		resp.AddPage(http.StatusOK, armnetwork.PublicIPAddressesClientListResponse{
+			PublicIPAddressListResult: armnetwork.PublicIPAddressListResult{
+				Value: b.clusterState.ListPublicIPs(),
+			},
+		}, nil)
+		return
+	}
+	return b
+}
+
+// Build builds armnetwork.PublicIPAddressesClient.
+func (b *PublicIPAddressAccessBuilder) Build() (*armnetwork.PublicIPAddressesClient, error) {
+	b.withGet().withBeginDelete().withBeginCreateOrUpdate().withUpdateTags().withNewListPager()
+	return armnetwork.NewPublicIPAddressesClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Transport: fakenetwork.NewPublicIPAddressesServerTransport(&b.server),
+		},
+	})
+}
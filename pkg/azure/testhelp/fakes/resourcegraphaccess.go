@@ -13,6 +13,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
 	fakeresourcegraph "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph/fake"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
@@ -59,32 +60,38 @@ func (b *ResourceGraphAccessBuilder) withResources() *ResourceGraphAccessBuilder
 		// When a non-existent resource group is passed in the resource graph query
 		// then it does not error out instead it returns 0 results.
 		//-----------------------------------------------------------------------
-		resTypeToVMNames := make(map[string][]string)
+		resTypeToResources := make(map[string][]resourceGraphEntry)
 		if query.Query != nil {
 			tagsToMatch := b.getProviderSpecTagKeysToMatch()
 			for _, resType := range foundResourceTypes {
 				switch resType {
 				case utils.VirtualMachinesResourceType:
-					vmNames := b.clusterState.GetVMsMatchingTagKeys(tagsToMatch)
-					if !utils.IsSliceNilOrEmpty(vmNames) {
-						resTypeToVMNames[string(resType)] = vmNames
+					vms := b.clusterState.GetVMsMatchingTagKeys(tagsToMatch)
+					for _, vm := range vms {
+						provisioningState, powerState := b.getVMStates(*vm.Name)
+						resTypeToResources[string(resType)] = append(resTypeToResources[string(resType)], resourceGraphEntry{name: *vm.Name, tags: vm.Tags, provisioningState: provisioningState, powerState: powerState})
 					}
 				case utils.NetworkInterfacesResourceType:
-					vmNames := b.clusterState.GetNICNamesMatchingTagKeys(tagsToMatch)
-					if !utils.IsSliceNilOrEmpty(vmNames) {
-						resTypeToVMNames[string(resType)] = vmNames
+					nics := b.clusterState.GetNICsMatchingTagKeys(tagsToMatch)
+					for _, nic := range nics {
+						resTypeToResources[string(resType)] = append(resTypeToResources[string(resType)], resourceGraphEntry{name: *nic.Name, tags: nic.Tags, attachedTo: getNICAttachedVMID(nic)})
 					}
 				case utils.DiskResourceType:
-					vmNames := b.clusterState.GetDiskNamesMatchingTagKeys(tagsToMatch)
-					if !utils.IsSliceNilOrEmpty(vmNames) {
-						resTypeToVMNames[string(resType)] = vmNames
+					disks := b.clusterState.GetDisksMatchingTagKeys(tagsToMatch)
+					for _, disk := range disks {
+						resTypeToResources[string(resType)] = append(resTypeToResources[string(resType)], resourceGraphEntry{name: *disk.Name, tags: disk.Tags, attachedTo: disk.ManagedBy})
+					}
+				case utils.PublicIPAddressResourceType:
+					publicIPs := b.clusterState.GetPublicIPsMatchingTagKeys(tagsToMatch)
+					for _, publicIP := range publicIPs {
+						resTypeToResources[string(resType)] = append(resTypeToResources[string(resType)], resourceGraphEntry{name: *publicIP.Name, tags: publicIP.Tags, attachedTo: getPublicIPAttachedIPConfigurationID(publicIP)})
 					}
 				}
 			}
 		}
 		// create the response
 		// currently the fake implementation does not have paging support. This means the Count is also the TotalRecords.
-		queryResp := createResourcesResponse(resTypeToVMNames)
+		queryResp := createResourcesResponse(resTypeToResources)
 		resp.SetResponse(http.StatusOK, queryResp, nil)
 		return
 	}
@@ -99,7 +106,7 @@ func getResourceTypes(query armresourcegraph.QueryRequest) []utils.ResourceType
 	if query.Query == nil {
 		return foundResourceTypes
 	}
-	resourceTypesToMatch := []utils.ResourceType{utils.VirtualMachinesResourceType, utils.NetworkInterfacesResourceType, utils.DiskResourceType}
+	resourceTypesToMatch := []utils.ResourceType{utils.VirtualMachinesResourceType, utils.NetworkInterfacesResourceType, utils.DiskResourceType, utils.PublicIPAddressResourceType}
 	for _, resType := range resourceTypesToMatch {
 		if strings.Contains(*query.Query, string(resType)) {
 			foundResourceTypes = append(foundResourceTypes, resType)
@@ -111,29 +118,79 @@ func getResourceTypes(query armresourcegraph.QueryRequest) []utils.ResourceType
 func (b *ResourceGraphAccessBuilder) getProviderSpecTagKeysToMatch() []string {
 	tagKeys := make([]string, 0, 2)
 	for k := range b.clusterState.ProviderSpec.Tags {
-		if strings.HasPrefix(k, utils.ClusterTagPrefix) || strings.HasPrefix(k, utils.RoleTagPrefix) {
+		if k = utils.SanitizeTagKey(k); strings.HasPrefix(k, utils.ClusterTagPrefix) || strings.HasPrefix(k, utils.RoleTagPrefix) {
 			tagKeys = append(tagKeys, k)
 		}
 	}
 	return tagKeys
 }
 
-func createResourcesResponse(resTypeToVMNames map[string][]string) armresourcegraph.ClientResourcesResponse {
-	body := make([]interface{}, 0, len(resTypeToVMNames))
-	for resType, vmNames := range resTypeToVMNames {
-		for _, vmName := range vmNames {
+// getVMStates returns the provisioning state and the power state status code of the VM as they are reported by resource graph.
+func (b *ResourceGraphAccessBuilder) getVMStates(vmName string) (provisioningState *string, powerState *string) {
+	machineResources, ok := b.clusterState.MachineResourcesMap[vmName]
+	if !ok {
+		return nil, nil
+	}
+	instanceView := createInstanceView(machineResources)
+	return to.Ptr(utils.GetProvisioningState(instanceView)), to.Ptr("PowerState/" + utils.GetPowerState(instanceView))
+}
+
+// resourceGraphEntry is a resource which is returned by the fake resource graph query.
+type resourceGraphEntry struct {
+	name       string
+	tags       map[string]*string
+	attachedTo *string
+	// provisioningState and powerState are only set for VMs.
+	provisioningState *string
+	powerState        *string
+}
+
+func getNICAttachedVMID(nic *armnetwork.Interface) *string {
+	if nic.Properties == nil || nic.Properties.VirtualMachine == nil {
+		return nil
+	}
+	return nic.Properties.VirtualMachine.ID
+}
+
+func getPublicIPAttachedIPConfigurationID(publicIP *armnetwork.PublicIPAddress) *string {
+	if publicIP.Properties == nil || publicIP.Properties.IPConfiguration == nil {
+		return nil
+	}
+	return publicIP.Properties.IPConfiguration.ID
+}
+
+func createResourcesResponse(resTypeToResources map[string][]resourceGraphEntry) armresourcegraph.ClientResourcesResponse {
+	body := make([]interface{}, 0, len(resTypeToResources))
+	for resType, resources := range resTypeToResources {
+		for _, resource := range resources {
 			entry := make(map[string]interface{})
 			entry["type"] = resType
-			entry["name"] = vmName
+			entry["name"] = resource.name
+			tags := make(map[string]interface{}, len(resource.tags))
+			for k, v := range resource.tags {
+				if v != nil {
+					tags[k] = *v
+				}
+			}
+			entry["tags"] = tags
+			if resource.attachedTo != nil {
+				entry["attachedTo"] = *resource.attachedTo
+			}
+			if resource.provisioningState != nil {
+				entry["provisioningState"] = *resource.provisioningState
+			}
+			if resource.powerState != nil {
+				entry["powerState"] = *resource.powerState
+			}
 			body = append(body, entry)
 		}
 	}
 	return armresourcegraph.ClientResourcesResponse{
 		QueryResponse: armresourcegraph.QueryResponse{
-			Count:           pointer.Int64(int64(len(resTypeToVMNames))),
+			Count:           pointer.Int64(int64(len(resTypeToResources))),
 			Data:            body,
 			ResultTruncated: to.Ptr(armresourcegraph.ResultTruncatedFalse),
-			TotalRecords:    pointer.Int64(int64(len(resTypeToVMNames))),
+			TotalRecords:    pointer.Int64(int64(len(resTypeToResources))),
 		},
 	}
 }
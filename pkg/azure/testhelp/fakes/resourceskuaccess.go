@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package fakes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	fakecompute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// ResourceSKUsAccessBuilder is a builder for Resource SKUs access.
+type ResourceSKUsAccessBuilder struct {
+	clusterState    *ClusterState
+	server          fakecompute.ResourceSKUsServer
+	apiBehaviorSpec *APIBehaviorSpec
+}
+
+// WithClusterState initializes builder with a ClusterState.
+func (b *ResourceSKUsAccessBuilder) WithClusterState(clusterState *ClusterState) *ResourceSKUsAccessBuilder {
+	b.clusterState = clusterState
+	return b
+}
+
+// WithAPIBehaviorSpec initializes the builder with a APIBehaviorSpec.
+func (b *ResourceSKUsAccessBuilder) WithAPIBehaviorSpec(apiBehaviorSpec *APIBehaviorSpec) *ResourceSKUsAccessBuilder {
+	b.apiBehaviorSpec = apiBehaviorSpec
+	return b
+}
+
+// withNewListPager implements the NewListPager method of armcompute.ResourceSKUsClient and initializes the backing fake server's NewListPager method with the anonymous function implementation.
+func (b *ResourceSKUsAccessBuilder) withNewListPager() *ResourceSKUsAccessBuilder {
+	b.server.NewListPager = func(_ *armcompute.ResourceSKUsClientListOptions) (resp azfake.PagerResponder[armcompute.ResourceSKUsClientListResponse]) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResourceType(context.Background(), b.clusterState.ProviderSpec.ResourceGroup, to.Ptr(utils.ResourceSKUResourceType), testhelp.AccessMethodNewListPager)
+			if err != nil {
+				resp.AddError(err)
+				return
+			}
+		}
+		resp.AddPage(http.StatusOK, armcompute.ResourceSKUsClientListResponse{
+			ResourceSKUsResult: armcompute.ResourceSKUsResult{
+				Value: b.clusterState.ResourceSKUs,
+			},
+		}, nil)
+		return
+	}
+	return b
+}
+
+// Build builds armcompute.ResourceSKUsClient.
+func (b *ResourceSKUsAccessBuilder) Build() (*armcompute.ResourceSKUsClient, error) {
+	b.withNewListPager()
+	return armcompute.NewResourceSKUsClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: fakecompute.NewResourceSKUsServerTransport(&b.server),
+		},
+	})
+}
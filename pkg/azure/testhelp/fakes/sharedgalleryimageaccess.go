@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package fakes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	fakecompute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// SharedGalleryImagesAccessBuilder is a builder for shared gallery images access.
+type SharedGalleryImagesAccessBuilder struct {
+	clusterState    *ClusterState
+	server          fakecompute.SharedGalleryImagesServer
+	apiBehaviorSpec *APIBehaviorSpec
+}
+
+// WithClusterState initializes builder with a ClusterState.
+func (b *SharedGalleryImagesAccessBuilder) WithClusterState(clusterState *ClusterState) *SharedGalleryImagesAccessBuilder {
+	b.clusterState = clusterState
+	return b
+}
+
+// WithAPIBehaviorSpec initializes the builder with a APIBehaviorSpec.
+func (b *SharedGalleryImagesAccessBuilder) WithAPIBehaviorSpec(apiBehaviorSpec *APIBehaviorSpec) *SharedGalleryImagesAccessBuilder {
+	b.apiBehaviorSpec = apiBehaviorSpec
+	return b
+}
+
+// withGet implements the Get method of armcompute.SharedGalleryImagesClient and initializes the backing fake server's Get method with the anonymous function implementation.
+func (b *SharedGalleryImagesAccessBuilder) withGet() *SharedGalleryImagesAccessBuilder {
+	b.server.Get = func(ctx context.Context, location string, galleryName string, imageName string, _ *armcompute.SharedGalleryImagesClientGetOptions) (resp azfake.Responder[armcompute.SharedGalleryImagesClientGetResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResourceType(ctx, b.clusterState.ProviderSpec.ResourceGroup, to.Ptr(utils.GalleryImageResourceType), testhelp.AccessMethodGet)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		galleryImage := b.clusterState.GetGalleryImage(galleryName, imageName)
+		if galleryImage == nil {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeGalleryImageNotFound))
+			return
+		}
+		resp.SetResponse(http.StatusOK, armcompute.SharedGalleryImagesClientGetResponse{
+			SharedGalleryImage: armcompute.SharedGalleryImage{
+				Location: to.Ptr(location),
+				Name:     to.Ptr(imageName),
+				Properties: &armcompute.SharedGalleryImageProperties{
+					PurchasePlan: galleryImage.PurchasePlan,
+				},
+			},
+		}, nil)
+		return
+	}
+	return b
+}
+
+// Build builds armcompute.SharedGalleryImagesClient.
+func (b *SharedGalleryImagesAccessBuilder) Build() (*armcompute.SharedGalleryImagesClient, error) {
+	b.withGet()
+	return armcompute.NewSharedGalleryImagesClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: fakecompute.NewSharedGalleryImagesServerTransport(&b.server),
+		},
+	})
+}
@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package fakes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	fakecompute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+	"golang.org/x/exp/slices"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// SharedGalleryImageVersionsAccessBuilder is a builder for shared gallery image versions access.
+type SharedGalleryImageVersionsAccessBuilder struct {
+	clusterState    *ClusterState
+	server          fakecompute.SharedGalleryImageVersionsServer
+	apiBehaviorSpec *APIBehaviorSpec
+}
+
+// WithClusterState initializes builder with a ClusterState.
+func (b *SharedGalleryImageVersionsAccessBuilder) WithClusterState(clusterState *ClusterState) *SharedGalleryImageVersionsAccessBuilder {
+	b.clusterState = clusterState
+	return b
+}
+
+// WithAPIBehaviorSpec initializes the builder with a APIBehaviorSpec.
+func (b *SharedGalleryImageVersionsAccessBuilder) WithAPIBehaviorSpec(apiBehaviorSpec *APIBehaviorSpec) *SharedGalleryImageVersionsAccessBuilder {
+	b.apiBehaviorSpec = apiBehaviorSpec
+	return b
+}
+
+// withNewListPager implements the NewListPager method of armcompute.SharedGalleryImageVersionsClient and initializes the backing fake server's NewListPager method with the anonymous function implementation.
+func (b *SharedGalleryImageVersionsAccessBuilder) withNewListPager() *SharedGalleryImageVersionsAccessBuilder {
+	b.server.NewListPager = func(_ string, galleryName string, imageName string, _ *armcompute.SharedGalleryImageVersionsClientListOptions) (resp azfake.PagerResponder[armcompute.SharedGalleryImageVersionsClientListResponse]) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResourceType(context.Background(), b.clusterState.ProviderSpec.ResourceGroup, to.Ptr(utils.GalleryImageVersionResourceType), testhelp.AccessMethodNewListPager)
+			if err != nil {
+				resp.AddError(err)
+				return
+			}
+		}
+		galleryImage := b.clusterState.GetGalleryImage(galleryName, imageName)
+		if galleryImage == nil {
+			resp.AddError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeGalleryImageNotFound))
+			return
+		}
+		versions := make([]*armcompute.SharedGalleryImageVersion, 0, len(galleryImage.Versions))
+		for _, version := range galleryImage.Versions {
+			versions = append(versions, &armcompute.SharedGalleryImageVersion{
+				Name: to.Ptr(version),
+				Properties: &armcompute.SharedGalleryImageVersionProperties{
+					ExcludeFromLatest: to.Ptr(slices.Contains(galleryImage.VersionsExcludedFromLatest, version)),
+				},
+			})
+		}
+		resp.AddPage(http.StatusOK, armcompute.SharedGalleryImageVersionsClientListResponse{
+			SharedGalleryImageVersionList: armcompute.SharedGalleryImageVersionList{
+				Value: versions,
+			},
+		}, nil)
+		return
+	}
+	return b
+}
+
+// Build builds armcompute.SharedGalleryImageVersionsClient.
+func (b *SharedGalleryImageVersionsAccessBuilder) Build() (*armcompute.SharedGalleryImageVersionsClient, error) {
+	b.withNewListPager()
+	return armcompute.NewSharedGalleryImageVersionsClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: fakecompute.NewSharedGalleryImageVersionsServerTransport(&b.server),
+		},
+	})
+}
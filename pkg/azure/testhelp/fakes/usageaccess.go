@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package fakes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	fakecompute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/utils"
+)
+
+// UsageAccessBuilder is a builder for compute resource usage access.
+type UsageAccessBuilder struct {
+	clusterState    *ClusterState
+	server          fakecompute.UsageServer
+	apiBehaviorSpec *APIBehaviorSpec
+}
+
+// WithClusterState initializes builder with a ClusterState.
+func (b *UsageAccessBuilder) WithClusterState(clusterState *ClusterState) *UsageAccessBuilder {
+	b.clusterState = clusterState
+	return b
+}
+
+// WithAPIBehaviorSpec initializes the builder with a APIBehaviorSpec.
+func (b *UsageAccessBuilder) WithAPIBehaviorSpec(apiBehaviorSpec *APIBehaviorSpec) *UsageAccessBuilder {
+	b.apiBehaviorSpec = apiBehaviorSpec
+	return b
+}
+
+// withNewListPager implements the NewListPager method of armcompute.UsageClient and initializes the backing fake server's NewListPager method with the anonymous function implementation.
+func (b *UsageAccessBuilder) withNewListPager() *UsageAccessBuilder {
+	b.server.NewListPager = func(_ string, _ *armcompute.UsageClientListOptions) (resp azfake.PagerResponder[armcompute.UsageClientListResponse]) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResourceType(context.Background(), b.clusterState.ProviderSpec.ResourceGroup, to.Ptr(utils.UsageResourceType), testhelp.AccessMethodNewListPager)
+			if err != nil {
+				resp.AddError(err)
+				return
+			}
+		}
+		resp.AddPage(http.StatusOK, armcompute.UsageClientListResponse{
+			ListUsagesResult: armcompute.ListUsagesResult{
+				Value: b.clusterState.Usages,
+			},
+		}, nil)
+		return
+	}
+	return b
+}
+
+// Build builds armcompute.UsageClient.
+func (b *UsageAccessBuilder) Build() (*armcompute.UsageClient, error) {
+	b.withNewListPager()
+	return armcompute.NewUsageClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: fakecompute.NewUsageServerTransport(&b.server),
+		},
+	})
+}
@@ -46,6 +46,16 @@ func CreateNetworkInterfaceID(subscriptionID, resourceGroup, nicName string) str
 	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkInterfaces/%s", subscriptionID, resourceGroup, nicName)
 }
 
+// CreateDiskID creates an azure representation of disk ID.
+func CreateDiskID(subscriptionID, resourceGroup, diskName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/disks/%s", subscriptionID, resourceGroup, diskName)
+}
+
+// CreatePublicIPAddressID creates an azure representation of public IP address ID.
+func CreatePublicIPAddressID(subscriptionID, resourceGroup, publicIPName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/publicIPAddresses/%s", subscriptionID, resourceGroup, publicIPName)
+}
+
 // CreateIPConfigurationID creates an azure representation of IP configuration ID.
 func CreateIPConfigurationID(subscriptionID, resourceGroup, nicName, ipConfigName string) string {
 	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkInterfaces/%s/ipConfigurations/%s", subscriptionID, resourceGroup, nicName, ipConfigName)
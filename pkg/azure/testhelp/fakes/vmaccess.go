@@ -7,10 +7,12 @@ package fakes
 import (
 	"context"
 	"net/http"
+	"slices"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
 	fakecompute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
@@ -38,7 +40,7 @@ func (b *VMAccessBuilder) WithAPIBehaviorSpec(apiBehaviorSpec *APIBehaviorSpec)
 
 // withGet implements the Get method of armcompute.VirtualMachinesClient and initializes the backing fake server's Get method with the anonymous function implementation.
 func (b *VMAccessBuilder) withGet() *VMAccessBuilder {
-	b.server.Get = func(ctx context.Context, resourceGroupName string, vmName string, _ *armcompute.VirtualMachinesClientGetOptions) (resp azfake.Responder[armcompute.VirtualMachinesClientGetResponse], errResp azfake.ErrorResponder) {
+	b.server.Get = func(ctx context.Context, resourceGroupName string, vmName string, options *armcompute.VirtualMachinesClientGetOptions) (resp azfake.Responder[armcompute.VirtualMachinesClientGetResponse], errResp azfake.ErrorResponder) {
 		if b.apiBehaviorSpec != nil {
 			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, vmName, testhelp.AccessMethodGet)
 			if err != nil {
@@ -55,7 +57,13 @@ func (b *VMAccessBuilder) withGet() *VMAccessBuilder {
 			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound))
 			return
 		}
-		vmResp := armcompute.VirtualMachinesClientGetResponse{VirtualMachine: *machineResources.VM}
+		vm := *machineResources.VM
+		if options != nil && options.Expand != nil && *options.Expand == armcompute.InstanceViewTypesInstanceView && vm.Properties != nil {
+			properties := *vm.Properties
+			properties.InstanceView = createInstanceView(machineResources)
+			vm.Properties = &properties
+		}
+		vmResp := armcompute.VirtualMachinesClientGetResponse{VirtualMachine: vm}
 		resp.SetResponse(http.StatusOK, vmResp, nil)
 		return
 	}
@@ -64,7 +72,7 @@ func (b *VMAccessBuilder) withGet() *VMAccessBuilder {
 
 // withBeginDelete implements the BeingDelete method of armcompute.VirtualMachinesClient and initializes the backing fake server's BeginDelete method with the anonymous function implementation.
 func (b *VMAccessBuilder) withBeginDelete() *VMAccessBuilder {
-	b.server.BeginDelete = func(ctx context.Context, resourceGroupName string, vmName string, _ *armcompute.VirtualMachinesClientBeginDeleteOptions) (resp azfake.PollerResponder[armcompute.VirtualMachinesClientDeleteResponse], errResp azfake.ErrorResponder) {
+	b.server.BeginDelete = func(ctx context.Context, resourceGroupName string, vmName string, options *armcompute.VirtualMachinesClientBeginDeleteOptions) (resp azfake.PollerResponder[armcompute.VirtualMachinesClientDeleteResponse], errResp azfake.ErrorResponder) {
 		if b.apiBehaviorSpec != nil {
 			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, vmName, testhelp.AccessMethodBeginDelete)
 			if err != nil {
@@ -77,6 +85,9 @@ func (b *VMAccessBuilder) withBeginDelete() *VMAccessBuilder {
 			return
 		}
 
+		if options != nil && options.ForceDeletion != nil && *options.ForceDeletion {
+			b.clusterState.ForceDeletedVMNames = append(b.clusterState.ForceDeletedVMNames, vmName)
+		}
 		b.clusterState.DeleteVM(vmName)
 		// Azure API VM deletion does not fail if the VM does not exist. It still returns 200 Ok.
 		resp.SetTerminalResponse(200, armcompute.VirtualMachinesClientDeleteResponse{}, nil)
@@ -105,6 +116,11 @@ func (b *VMAccessBuilder) withBeginCreateOrUpdate() *VMAccessBuilder {
 			errResp.SetError(err)
 			return
 		}
+		// similar to Azure, a VM which could not be allocated is left behind in a failed state.
+		if b.clusterState.IsAllocationFailedZone(parameters.Zones) {
+			errResp.SetError(testhelp.ConflictErr(testhelp.ErrorCodeZonalAllocationFailed))
+			return
+		}
 		resp.SetTerminalResponse(http.StatusOK, armcompute.VirtualMachinesClientCreateOrUpdateResponse{VirtualMachine: *vm}, nil)
 		return
 	}
@@ -136,12 +152,24 @@ func (b *VMAccessBuilder) withBeginUpdate() *VMAccessBuilder {
 			return
 		}
 
-		// NOTE: Currently we are only using update API to set cascade delete option for NIC and Disks.
-		// So to avoid complexity, we will restrict it to only updating cascade delete options only.
+		// NOTE: The update API is used to set cascade delete option for NIC and Disks and to update the tags, the identity
+		// and to add data disks in place. So to avoid complexity, we will restrict it to only these updates.
 		// If in future the usage changes then changes should also be done here to reflect that.
-		b.updateNICCascadeDeleteOption(vmName, updateParams.Properties.NetworkProfile)
-		b.updateOSDiskCascadeDeleteOption(vmName, updateParams.Properties.StorageProfile)
-		b.updatedDataDisksCascadeDeleteOption(vmName, updateParams.Properties.StorageProfile)
+		if updateParams.Properties != nil {
+			if updateParams.Properties.HardwareProfile != nil {
+				machineResources.VM.Properties.HardwareProfile = updateParams.Properties.HardwareProfile
+			}
+			b.addDataDisks(vmName, updateParams.Properties.StorageProfile)
+			b.updateNICCascadeDeleteOption(vmName, updateParams.Properties.NetworkProfile)
+			b.updateOSDiskCascadeDeleteOption(vmName, updateParams.Properties.StorageProfile)
+			b.updatedDataDisksCascadeDeleteOption(vmName, updateParams.Properties.StorageProfile)
+		}
+		if updateParams.Tags != nil {
+			machineResources.VM.Tags = updateParams.Tags
+		}
+		if updateParams.Identity != nil {
+			machineResources.VM.Identity = updateIdentity(machineResources.VM.Identity, updateParams.Identity)
+		}
 
 		// Get the updated VM
 		m := b.clusterState.MachineResourcesMap[vmName]
@@ -151,6 +179,50 @@ func (b *VMAccessBuilder) withBeginUpdate() *VMAccessBuilder {
 	return b
 }
 
+// addDataDisks adds the data disks of the update which are not yet attached to the VM and creates the disks which are
+// created together with the VM.
+func (b *VMAccessBuilder) addDataDisks(vmName string, storageProfile *armcompute.StorageProfile) {
+	if storageProfile == nil {
+		return
+	}
+	m := b.clusterState.MachineResourcesMap[vmName]
+	for _, dataDisk := range storageProfile.DataDisks {
+		diskName := *dataDisk.Name
+		if slices.ContainsFunc(m.VM.Properties.StorageProfile.DataDisks, func(d *armcompute.DataDisk) bool { return *d.Name == diskName }) {
+			continue
+		}
+		m.VM.Properties.StorageProfile.DataDisks = append(m.VM.Properties.StorageProfile.DataDisks, dataDisk)
+		if *dataDisk.CreateOption == armcompute.DiskCreateOptionTypesEmpty {
+			if m.DataDisks == nil {
+				m.DataDisks = make(map[string]*armcompute.Disk)
+			}
+			m.DataDisks[diskName] = createDiskResource(b.clusterState.ProviderSpec, diskName, m.VM.ID, nil)
+		}
+	}
+	b.clusterState.MachineResourcesMap[vmName] = m
+}
+
+// updateIdentity applies the identity update to the identity of the VM. User-assigned identities with a nil value are removed.
+func updateIdentity(identity *armcompute.VirtualMachineIdentity, identityUpdate *armcompute.VirtualMachineIdentity) *armcompute.VirtualMachineIdentity {
+	if identityUpdate.Type != nil && *identityUpdate.Type == armcompute.ResourceIdentityTypeNone {
+		return nil
+	}
+	updated := &armcompute.VirtualMachineIdentity{Type: identityUpdate.Type, UserAssignedIdentities: make(map[string]*armcompute.UserAssignedIdentitiesValue)}
+	if identity != nil {
+		for id, value := range identity.UserAssignedIdentities {
+			updated.UserAssignedIdentities[id] = value
+		}
+	}
+	for id, value := range identityUpdate.UserAssignedIdentities {
+		if value == nil {
+			delete(updated.UserAssignedIdentities, id)
+		} else {
+			updated.UserAssignedIdentities[id] = value
+		}
+	}
+	return updated
+}
+
 func (b *VMAccessBuilder) updateNICCascadeDeleteOption(vmName string, nwProfile *armcompute.NetworkProfile) {
 	var deleteOpt *armcompute.DeleteOptions
 	if nwProfile != nil {
@@ -197,9 +269,101 @@ func (b *VMAccessBuilder) updatedDataDisksCascadeDeleteOption(vmName string, sto
 	}
 }
 
+// withInstanceView implements the InstanceView method of armcompute.VirtualMachinesClient and initializes the backing fake server's InstanceView method with the anonymous function implementation.
+func (b *VMAccessBuilder) withInstanceView() *VMAccessBuilder {
+	b.server.InstanceView = func(ctx context.Context, resourceGroupName string, vmName string, _ *armcompute.VirtualMachinesClientInstanceViewOptions) (resp azfake.Responder[armcompute.VirtualMachinesClientInstanceViewResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, vmName, testhelp.AccessMethodInstanceView)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		machineResources, existing := b.clusterState.MachineResourcesMap[vmName]
+		if !existing || machineResources.VM == nil {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound))
+			return
+		}
+		resp.SetResponse(http.StatusOK, armcompute.VirtualMachinesClientInstanceViewResponse{VirtualMachineInstanceView: *createInstanceView(machineResources)}, nil)
+		return
+	}
+	return b
+}
+
+// createInstanceView creates the instance view of the VM. The provisioning state is taken from the VM (defaulting to Succeeded)
+// and the power state from MachineResources.PowerState (defaulting to running).
+func createInstanceView(machineResources MachineResources) *armcompute.VirtualMachineInstanceView {
+	provisioningState := utils.ProvisioningStateSucceeded
+	if machineResources.VM.Properties != nil && machineResources.VM.Properties.ProvisioningState != nil {
+		provisioningState = *machineResources.VM.Properties.ProvisioningState
+	}
+	powerState := utils.PowerStateRunning
+	if machineResources.PowerState != "" {
+		powerState = machineResources.PowerState
+	}
+	return &armcompute.VirtualMachineInstanceView{
+		Statuses: []*armcompute.InstanceViewStatus{
+			{Code: to.Ptr("ProvisioningState/" + provisioningState)},
+			{Code: to.Ptr("PowerState/" + powerState)},
+		},
+	}
+}
+
+// withBeginStart implements the BeginStart method of armcompute.VirtualMachinesClient and initializes the backing fake server's BeginStart method with the anonymous function implementation.
+func (b *VMAccessBuilder) withBeginStart() *VMAccessBuilder {
+	b.server.BeginStart = func(ctx context.Context, resourceGroupName string, vmName string, _ *armcompute.VirtualMachinesClientBeginStartOptions) (resp azfake.PollerResponder[armcompute.VirtualMachinesClientStartResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, vmName, testhelp.AccessMethodBeginStart)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		if !b.clusterState.SetVirtualMachinePowerState(vmName, utils.PowerStateRunning) {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound))
+			return
+		}
+		resp.SetTerminalResponse(http.StatusOK, armcompute.VirtualMachinesClientStartResponse{}, nil)
+		return
+	}
+	return b
+}
+
+// withBeginDeallocate implements the BeginDeallocate method of armcompute.VirtualMachinesClient and initializes the backing fake server's BeginDeallocate method with the anonymous function implementation.
+func (b *VMAccessBuilder) withBeginDeallocate() *VMAccessBuilder {
+	b.server.BeginDeallocate = func(ctx context.Context, resourceGroupName string, vmName string, _ *armcompute.VirtualMachinesClientBeginDeallocateOptions) (resp azfake.PollerResponder[armcompute.VirtualMachinesClientDeallocateResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, vmName, testhelp.AccessMethodBeginDeallocate)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		if !b.clusterState.SetVirtualMachinePowerState(vmName, utils.PowerStateDeallocated) {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceNotFound))
+			return
+		}
+		resp.SetTerminalResponse(http.StatusOK, armcompute.VirtualMachinesClientDeallocateResponse{}, nil)
+		return
+	}
+	return b
+}
+
 // Build builds armcompute.VirtualMachinesClient.
 func (b *VMAccessBuilder) Build() (*armcompute.VirtualMachinesClient, error) {
-	b.withGet().withBeginDelete().withBeginUpdate().withBeginCreateOrUpdate()
+	b.withGet().withBeginDelete().withBeginUpdate().withBeginCreateOrUpdate().withInstanceView().withBeginStart().withBeginDeallocate()
 	return armcompute.NewVirtualMachinesClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
 		ClientOptions: azcore.ClientOptions{
 			Transport: fakecompute.NewVirtualMachinesServerTransport(&b.server),
@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package fakes
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	fakecompute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+
+	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/testhelp"
+)
+
+// VMExtensionsAccessBuilder is a builder for VM Extensions access.
+type VMExtensionsAccessBuilder struct {
+	clusterState    *ClusterState
+	server          fakecompute.VirtualMachineExtensionsServer
+	apiBehaviorSpec *APIBehaviorSpec
+}
+
+// WithClusterState initializes builder with a ClusterState.
+func (b *VMExtensionsAccessBuilder) WithClusterState(clusterState *ClusterState) *VMExtensionsAccessBuilder {
+	b.clusterState = clusterState
+	return b
+}
+
+// WithAPIBehaviorSpec initializes the builder with a APIBehaviorSpec.
+func (b *VMExtensionsAccessBuilder) WithAPIBehaviorSpec(apiBehaviorSpec *APIBehaviorSpec) *VMExtensionsAccessBuilder {
+	b.apiBehaviorSpec = apiBehaviorSpec
+	return b
+}
+
+// withBeginCreateOrUpdate implements the BeginCreateOrUpdate method of armcompute.VirtualMachineExtensionsClient and initializes the backing fake server's BeginCreateOrUpdate method with the anonymous function implementation.
+func (b *VMExtensionsAccessBuilder) withBeginCreateOrUpdate() *VMExtensionsAccessBuilder {
+	b.server.BeginCreateOrUpdate = func(ctx context.Context, resourceGroupName string, vmName string, vmExtensionName string, extensionParameters armcompute.VirtualMachineExtension, _ *armcompute.VirtualMachineExtensionsClientBeginCreateOrUpdateOptions) (resp azfake.PollerResponder[armcompute.VirtualMachineExtensionsClientCreateOrUpdateResponse], errResp azfake.ErrorResponder) {
+		if b.apiBehaviorSpec != nil {
+			err := b.apiBehaviorSpec.SimulateForResource(ctx, resourceGroupName, vmExtensionName, testhelp.AccessMethodBeginCreateOrUpdate)
+			if err != nil {
+				errResp.SetError(err)
+				return
+			}
+		}
+		if b.clusterState.ProviderSpec.ResourceGroup != resourceGroupName {
+			errResp.SetError(testhelp.ResourceNotFoundErr(testhelp.ErrorCodeResourceGroupNotFound))
+			return
+		}
+		extensionParameters.Name = &vmExtensionName
+		extension, err := b.clusterState.CreateVMExtension(vmName, extensionParameters)
+		if err != nil {
+			errResp.SetError(err)
+			return
+		}
+		resp.SetTerminalResponse(http.StatusOK, armcompute.VirtualMachineExtensionsClientCreateOrUpdateResponse{VirtualMachineExtension: *extension}, nil)
+		return
+	}
+	return b
+}
+
+// Build builds armcompute.VirtualMachineExtensionsClient.
+func (b *VMExtensionsAccessBuilder) Build() (*armcompute.VirtualMachineExtensionsClient, error) {
+	b.withBeginCreateOrUpdate()
+	return armcompute.NewVirtualMachineExtensionsClient(testhelp.SubscriptionID, &azfake.TokenCredential{}, &arm.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: fakecompute.NewVirtualMachineExtensionsServerTransport(&b.server),
+		},
+	})
+}
@@ -192,7 +192,7 @@ func (b *ProviderSpecBuilder) Build() api.AzureProviderSpec {
 func CreateDataDiskNames(vmName string, spec api.AzureProviderSpec) []string {
 	var diskNames []string
 	for _, specDataDisk := range spec.Properties.StorageProfile.DataDisks {
-		diskNames = append(diskNames, utils.CreateDataDiskName(vmName, specDataDisk.Name, specDataDisk.Lun))
+		diskNames = append(diskNames, utils.CreateDataDiskNameFromTemplate(vmName, specDataDisk.Name, specDataDisk.Lun, spec.Properties.StorageProfile.DataDiskNameTemplate))
 	}
 	return diskNames
 }
@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLCache is a simple thread-safe in-memory cache where each entry expires after a fixed time-to-live.
+// Expired entries are not eagerly evicted, they are removed on the next lookup of their key.
+type TTLCache[K comparable, V any] struct {
+	mutex   sync.RWMutex
+	ttl     time.Duration
+	entries map[K]ttlCacheEntry[V]
+	// nowFn allows unit tests to control the passage of time.
+	nowFn func() time.Time
+}
+
+type ttlCacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// NewTTLCache creates a new TTLCache whose entries expire after ttl.
+func NewTTLCache[K comparable, V any](ttl time.Duration) *TTLCache[K, V] {
+	return &TTLCache[K, V]{
+		ttl:     ttl,
+		entries: make(map[K]ttlCacheEntry[V]),
+		nowFn:   time.Now,
+	}
+}
+
+// Get returns the value cached against key. The second return value is false if there is no entry or if the entry has expired.
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mutex.RLock()
+	entry, ok := c.entries[key]
+	c.mutex.RUnlock()
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if c.nowFn().After(entry.expiresAt) {
+		c.Delete(key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// Set caches value against key, replacing any existing entry.
+func (c *TTLCache[K, V]) Set(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = ttlCacheEntry[V]{value: value, expiresAt: c.nowFn().Add(c.ttl)}
+}
+
+// Delete removes the entry for key if there is one.
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, key)
+}
+
+// Purge removes all entries from the cache.
+func (c *TTLCache[K, V]) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[K]ttlCacheEntry[V])
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestTTLCache(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+	cache := NewTTLCache[string, int](time.Minute)
+	cache.nowFn = func() time.Time { return now }
+
+	_, ok := cache.Get("a")
+	g.Expect(ok).To(BeFalse())
+
+	cache.Set("a", 1)
+	value, ok := cache.Get("a")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(value).To(Equal(1))
+
+	// move past the TTL, entry should have expired
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.Get("a")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(cache.entries).To(BeEmpty())
+
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Delete("b")
+	_, ok = cache.Get("b")
+	g.Expect(ok).To(BeFalse())
+	cache.Purge()
+	_, ok = cache.Get("c")
+	g.Expect(ok).To(BeFalse())
+}
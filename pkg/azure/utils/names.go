@@ -5,7 +5,11 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"regexp"
+	"strings"
 )
 
 const (
@@ -13,15 +17,53 @@ const (
 	NICSuffix = "-nic"
 	// OSDiskSuffix is the suffix for OSDisk names.
 	OSDiskSuffix = "-os-disk"
+	// PublicIPSuffix is the suffix for the names of public IP addresses which belong to a VM.
+	PublicIPSuffix = "-public-ip"
+	// NameTemplateVMNamePlaceholder is the placeholder in an OSDisk or DataDisk name template which is replaced by the VM name.
+	NameTemplateVMNamePlaceholder = "{vmName}"
+	// DataDiskNameTemplateNamePlaceholder is the placeholder in a DataDisk name template which is replaced by the data disk name specified in the provider spec.
+	DataDiskNameTemplateNamePlaceholder = "{name}"
+	// DataDiskNameTemplateLunPlaceholder is the placeholder in a DataDisk name template which is replaced by the lun of the data disk.
+	DataDiskNameTemplateLunPlaceholder = "{lun}"
 	//DataDiskSuffix is the suffix for Data disk names.
 	DataDiskSuffix = "-data-disk"
 	// AzureCSIDriverName is the name of the CSI driver name for Azure provider
 	AzureCSIDriverName = "disk.csi.azure.com"
+	// windowsComputerNameMaxLength is the max length of a computer name that is allowed by Windows (NetBIOS name).
+	windowsComputerNameMaxLength = 15
+	// windowsComputerNameHashLength is the number of characters of the hash that is used as a suffix for truncated computer names.
+	windowsComputerNameHashLength = 6
+	// MaxVMNameLength is the max length of a VM name that is allowed by Azure.
+	MaxVMNameLength = 64
+	// MaxResourceNameLength is the max length of a NIC, Disk or public IP address name that is allowed by Azure.
+	MaxResourceNameLength = 80
+	// shortenedNameHashLength is the number of characters of the hash that is used as a suffix for shortened resource names.
+	shortenedNameHashLength = 8
 )
 
+// ShortenName returns the name unchanged if it does not exceed maxLength. Longer names are truncated and suffixed with
+// '-' and a short hash of the complete name, which keeps names unique that only differ after the truncated prefix.
+// The result is deterministic, hence the same name is created again for the same input, e.g. to delete the resource.
+// A shortened name no longer ends with the suffix of its resource type, so the VM name can not be extracted from it.
+func ShortenName(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+	hash := sha256.Sum256([]byte(name))
+	// trailing separators of the truncated prefix are trimmed to not create a sequence of separators.
+	prefix := strings.TrimRight(name[:maxLength-shortenedNameHashLength-1], "-.")
+	return prefix + "-" + hex.EncodeToString(hash[:])[:shortenedNameHashLength]
+}
+
+// CreateVMName creates the name of the VM which backs the machine with the given name. Machine names which exceed
+// MaxVMNameLength are shortened using ShortenName, the machine name is then only retained in the MachineNameTagKey tag.
+func CreateVMName(machineName string) string {
+	return ShortenName(strings.ToLower(machineName), MaxVMNameLength)
+}
+
 // CreateNICName creates a NIC name given a VM name
 func CreateNICName(vmName string) string {
-	return fmt.Sprintf("%s%s", vmName, NICSuffix)
+	return ShortenName(fmt.Sprintf("%s%s", vmName, NICSuffix), MaxResourceNameLength)
 }
 
 // ExtractVMNameFromNICName extracts VM Name from NIC name
@@ -29,6 +71,20 @@ func ExtractVMNameFromNICName(nicName string) string {
 	return nicName[:len(nicName)-len(NICSuffix)]
 }
 
+// CreatePublicIPName creates the name of the public IP address of a VM given the VM name.
+func CreatePublicIPName(vmName string) string {
+	return ShortenName(fmt.Sprintf("%s%s", vmName, PublicIPSuffix), MaxResourceNameLength)
+}
+
+// ExtractVMNameFromPublicIPName extracts the VM name from a public IP address name. An empty string is returned if the
+// name does not follow the naming convention of CreatePublicIPName.
+func ExtractVMNameFromPublicIPName(publicIPName string) string {
+	if !strings.HasSuffix(publicIPName, PublicIPSuffix) {
+		return ""
+	}
+	return publicIPName[:len(publicIPName)-len(PublicIPSuffix)]
+}
+
 // ExtractVMNameFromOSDiskName extracts VM name from OSDisk name
 func ExtractVMNameFromOSDiskName(osDiskName string) string {
 	return osDiskName[:len(osDiskName)-len(OSDiskSuffix)]
@@ -36,14 +92,61 @@ func ExtractVMNameFromOSDiskName(osDiskName string) string {
 
 // CreateOSDiskName creates OSDisk name from VM name
 func CreateOSDiskName(vmName string) string {
-	return fmt.Sprintf("%s%s", vmName, OSDiskSuffix)
+	return ShortenName(fmt.Sprintf("%s%s", vmName, OSDiskSuffix), MaxResourceNameLength)
 }
 
-// CreateDataDiskName creates a name for a DataDisk using VM name and data disk name specified in the provider Spec
+// CreateOSDiskNameFromTemplate creates OSDisk name from VM name using the name template. If the name template is not set
+// then the default OSDisk name created by CreateOSDiskName is returned.
+func CreateOSDiskNameFromTemplate(vmName string, nameTemplate *string) string {
+	if nameTemplate == nil {
+		return CreateOSDiskName(vmName)
+	}
+	return ShortenName(strings.ReplaceAll(*nameTemplate, NameTemplateVMNamePlaceholder, vmName), MaxResourceNameLength)
+}
+
+// CreateDataDiskName creates a name for a DataDisk using VM name and data disk name specified in the provider Spec.
+// Names which exceed MaxResourceNameLength are shortened using ShortenName.
 func CreateDataDiskName(vmName, diskName string, lun int32) string {
 	prefix := vmName
 	suffix := GetDataDiskNameSuffix(diskName, lun)
-	return fmt.Sprintf("%s%s", prefix, suffix)
+	return ShortenName(fmt.Sprintf("%s%s", prefix, suffix), MaxResourceNameLength)
+}
+
+// CreateDataDiskNameFromTemplate creates a name for a DataDisk using the name template. If the name template is not set
+// then the default DataDisk name created by CreateDataDiskName is returned.
+func CreateDataDiskNameFromTemplate(vmName, diskName string, lun int32, nameTemplate *string) string {
+	if nameTemplate == nil {
+		return CreateDataDiskName(vmName, diskName, lun)
+	}
+	return ShortenName(strings.ReplaceAll(resolveDataDiskNameTemplate(*nameTemplate, diskName, lun), NameTemplateVMNamePlaceholder, vmName), MaxResourceNameLength)
+}
+
+// ExtractVMNameFromDataDiskName extracts the VM name from a DataDisk name which has been created using the name template
+// for the data disk with the given name and lun. The second return value is false if the DataDisk name does not match.
+func ExtractVMNameFromDataDiskName(dataDiskName, diskName string, lun int32, nameTemplate string) (string, bool) {
+	return ExtractVMNameUsingNameTemplate(dataDiskName, resolveDataDiskNameTemplate(nameTemplate, diskName, lun))
+}
+
+// ExtractVMNameUsingNameTemplate extracts the VM name from a resource name which has been created using the name template.
+// The second return value is false if the resource name does not match the name template.
+func ExtractVMNameUsingNameTemplate(resourceName, nameTemplate string) (string, bool) {
+	parts := strings.SplitN(nameTemplate, NameTemplateVMNamePlaceholder, 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	nameRegex, err := regexp.Compile(fmt.Sprintf("^%s(.+)%s$", regexp.QuoteMeta(parts[0]), regexp.QuoteMeta(parts[1])))
+	if err != nil {
+		return "", false
+	}
+	matches := nameRegex.FindStringSubmatch(resourceName)
+	if matches == nil {
+		return "", false
+	}
+	return matches[1], true
+}
+
+func resolveDataDiskNameTemplate(nameTemplate, diskName string, lun int32) string {
+	return strings.NewReplacer(DataDiskNameTemplateNamePlaceholder, diskName, DataDiskNameTemplateLunPlaceholder, fmt.Sprintf("%d", lun)).Replace(nameTemplate)
 }
 
 // GetDataDiskNameSuffix creates the suffix based on an optional data disk name and required lun fields.
@@ -58,3 +161,14 @@ func getDataDiskInfix(diskName string, lun int32) string {
 	}
 	return fmt.Sprintf("%s-%d", diskName, lun)
 }
+
+// CreateWindowsComputerName creates a computer name for a Windows VM. Windows restricts computer names to 15 characters,
+// therefore longer VM names are truncated and suffixed with a short hash of the complete VM name to keep them unique.
+func CreateWindowsComputerName(vmName string) string {
+	if len(vmName) <= windowsComputerNameMaxLength {
+		return vmName
+	}
+	hash := sha256.Sum256([]byte(vmName))
+	prefixLength := windowsComputerNameMaxLength - windowsComputerNameHashLength
+	return vmName[:prefixLength] + hex.EncodeToString(hash[:])[:windowsComputerNameHashLength]
+}
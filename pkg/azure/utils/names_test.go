@@ -6,6 +6,7 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/api"
@@ -19,6 +20,51 @@ func TestCreateOSDiskName(t *testing.T) {
 	g.Expect(CreateOSDiskName(vmName)).To(Equal(fmt.Sprintf("%s-os-disk", vmName)))
 }
 
+func TestCreateOSDiskNameFromTemplate(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(CreateOSDiskNameFromTemplate(vmName, nil)).To(Equal(CreateOSDiskName(vmName)))
+	nameTemplate := "{vmName}-root"
+	g.Expect(CreateOSDiskNameFromTemplate(vmName, &nameTemplate)).To(Equal(fmt.Sprintf("%s-root", vmName)))
+}
+
+func TestCreateDataDiskNameFromTemplate(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(CreateDataDiskNameFromTemplate(vmName, "dd1", 1, nil)).To(Equal(CreateDataDiskName(vmName, "dd1", 1)))
+	nameTemplate := "{vmName}-{name}-{lun}"
+	g.Expect(CreateDataDiskNameFromTemplate(vmName, "dd1", 1, &nameTemplate)).To(Equal(fmt.Sprintf("%s-dd1-1", vmName)))
+	nameTemplate = "data-{lun}-{vmName}"
+	g.Expect(CreateDataDiskNameFromTemplate(vmName, "dd1", 2, &nameTemplate)).To(Equal(fmt.Sprintf("data-2-%s", vmName)))
+}
+
+func TestExtractVMNameUsingNameTemplate(t *testing.T) {
+	table := []struct {
+		description    string
+		resourceName   string
+		nameTemplate   string
+		expectedVMName string
+		expectedMatch  bool
+	}{
+		{"should extract vm name with suffix template", vmName + "-root", "{vmName}-root", vmName, true},
+		{"should extract vm name with prefix template", "root." + vmName, "root.{vmName}", vmName, true},
+		{"should not match name with different suffix", vmName + "-os-disk", "{vmName}-root", "", false},
+		{"should not match template without placeholder", vmName + "-root", "root", "", false},
+	}
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			actualVMName, ok := ExtractVMNameUsingNameTemplate(entry.resourceName, entry.nameTemplate)
+			g.Expect(ok).To(Equal(entry.expectedMatch))
+			g.Expect(actualVMName).To(Equal(entry.expectedVMName))
+		})
+	}
+
+	actualVMName, ok := ExtractVMNameFromDataDiskName(fmt.Sprintf("%s-dd1-3", vmName), "dd1", 3, "{vmName}-{name}-{lun}")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(actualVMName).To(Equal(vmName))
+	_, ok = ExtractVMNameFromDataDiskName(fmt.Sprintf("%s-dd1-3", vmName), "dd1", 2, "{vmName}-{name}-{lun}")
+	g.Expect(ok).To(BeFalse())
+}
+
 func TestCreateNICName(t *testing.T) {
 	g := NewWithT(t)
 	g.Expect(CreateNICName(vmName)).To(Equal(fmt.Sprintf("%s-nic", vmName)))
@@ -63,3 +109,79 @@ func TestExtractVMNameFromOSDiskName(t *testing.T) {
 	g := NewWithT(t)
 	g.Expect(ExtractVMNameFromOSDiskName(nicName)).To(Equal(vmName))
 }
+
+func TestExtractVMNameFromPublicIPName(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(ExtractVMNameFromPublicIPName(CreatePublicIPName(vmName))).To(Equal(vmName))
+	g.Expect(ExtractVMNameFromPublicIPName("shoot--test-project-z1-4567c-xj5sq-pip")).To(BeEmpty())
+}
+
+func TestCreateWindowsComputerName(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(CreateWindowsComputerName("vm-0")).To(Equal("vm-0"))
+	computerName := CreateWindowsComputerName(vmName)
+	g.Expect(computerName).To(HaveLen(15))
+	g.Expect(computerName).To(HavePrefix(vmName[:9]))
+	g.Expect(computerName).ToNot(Equal(CreateWindowsComputerName(vmName + "a")))
+}
+
+func TestShortenName(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(ShortenName(vmName, MaxResourceNameLength)).To(Equal(vmName))
+	exactName := strings.Repeat("a", MaxResourceNameLength)
+	g.Expect(ShortenName(exactName, MaxResourceNameLength)).To(Equal(exactName))
+
+	longName := strings.Repeat("a", MaxResourceNameLength) + "-1-data-disk"
+	shortenedName := ShortenName(longName, MaxResourceNameLength)
+	g.Expect(shortenedName).To(HaveLen(MaxResourceNameLength))
+	g.Expect(shortenedName).To(HavePrefix(longName[:MaxResourceNameLength-9]))
+	g.Expect(shortenedName).To(MatchRegexp(`-[0-9a-f]{8}$`))
+	// the shortened name is deterministic
+	g.Expect(ShortenName(longName, MaxResourceNameLength)).To(Equal(shortenedName))
+	// names which only differ after the truncated prefix are not shortened to the same name
+	g.Expect(ShortenName(strings.Repeat("a", MaxResourceNameLength)+"-2-data-disk", MaxResourceNameLength)).ToNot(Equal(shortenedName))
+	// trailing separators of the truncated prefix are trimmed
+	g.Expect(ShortenName(strings.Repeat("a", MaxResourceNameLength-10)+"-.-"+vmName, MaxResourceNameLength)).To(MatchRegexp(`^a+-[0-9a-f]{8}$`))
+}
+
+func TestCreateVMName(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(CreateVMName(vmName)).To(Equal(vmName))
+	maxLengthName := strings.Repeat("m", MaxVMNameLength)
+	g.Expect(CreateVMName(maxLengthName)).To(Equal(maxLengthName))
+
+	// machine names which share the prefix of the max length of a VM name are shortened to distinct VM names.
+	vmNames := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		machineName := fmt.Sprintf("%s-%d", maxLengthName, i)
+		name := CreateVMName(machineName)
+		g.Expect(name).To(HaveLen(MaxVMNameLength))
+		g.Expect(name).To(Equal(CreateVMName(machineName)))
+		g.Expect(vmNames).ToNot(HaveKey(name))
+		vmNames[name] = struct{}{}
+	}
+	g.Expect(vmNames).ToNot(HaveKey(maxLengthName))
+}
+
+func TestCreateResourceNamesForLongVMName(t *testing.T) {
+	g := NewWithT(t)
+	longVMName := strings.Repeat("v", MaxVMNameLength)
+	// the names of resources with a fixed suffix do not exceed the max length for VM names within the limit
+	g.Expect(CreateNICName(longVMName)).To(Equal(longVMName + NICSuffix))
+	g.Expect(CreateOSDiskName(longVMName)).To(Equal(longVMName + OSDiskSuffix))
+	g.Expect(CreatePublicIPName(longVMName)).To(Equal(longVMName + PublicIPSuffix))
+
+	dataDiskNames := make(map[string]struct{})
+	for lun := int32(0); lun < 10; lun++ {
+		for _, diskName := range []string{"", "etcd", "containerd"} {
+			dataDiskName := CreateDataDiskName(longVMName, diskName, lun)
+			g.Expect(len(dataDiskName)).To(BeNumerically("<=", MaxResourceNameLength))
+			g.Expect(dataDiskNames).ToNot(HaveKey(dataDiskName))
+			dataDiskNames[dataDiskName] = struct{}{}
+		}
+	}
+	nameTemplate := "{vmName}-{name}-{lun}-with-a-long-name-template"
+	g.Expect(len(CreateDataDiskNameFromTemplate(longVMName, "etcd", 1, &nameTemplate))).To(BeNumerically("<=", MaxResourceNameLength))
+	osDiskNameTemplate := "{vmName}-os-disk-with-a-long-name-template"
+	g.Expect(len(CreateOSDiskNameFromTemplate(longVMName, &osDiskNameTemplate))).To(BeNumerically("<=", MaxResourceNameLength))
+}
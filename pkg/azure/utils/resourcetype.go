@@ -14,6 +14,8 @@ const (
 	NetworkInterfacesResourceType ResourceType = "microsoft.network/networkinterfaces" //as defined in azure
 	// DiskResourceType is a type used by Azure to represent disks (both OS and Data disks)
 	DiskResourceType ResourceType = "microsoft.compute/disks"
+	// PublicIPAddressResourceType is a type used by Azure to represent public IP address resources.
+	PublicIPAddressResourceType ResourceType = "microsoft.network/publicipaddresses"
 	// VMImageResourceType is a type used by Azure to represent VM Image resources.
 	// This is not defined in azure, however we have created this to allow defining API behavior for VM Images.
 	VMImageResourceType ResourceType = "microsoft.compute/vmimage"
@@ -21,4 +23,14 @@ const (
 	MarketPlaceOrderingOfferType ResourceType = "microsoft.marketplaceordering/offertypes"
 	// SubnetResourceType is a type used by Azure to represent subnet resources.
 	SubnetResourceType ResourceType = "microsoft.network/virtualnetworks/subnets"
+	// ResourceSKUResourceType is a type used by Azure to represent resource SKUs.
+	ResourceSKUResourceType ResourceType = "microsoft.compute/skus"
+	// UsageResourceType is a type used by Azure to represent compute resource usages.
+	UsageResourceType ResourceType = "microsoft.compute/locations/usages"
+	// VMExtensionResourceType is a type used by Azure to represent virtual machine extension resources.
+	VMExtensionResourceType ResourceType = "microsoft.compute/virtualmachines/extensions"
+	// GalleryImageResourceType is a type used by Azure to represent image definitions in a compute gallery.
+	GalleryImageResourceType ResourceType = "microsoft.compute/galleries/images"
+	// GalleryImageVersionResourceType is a type used by Azure to represent image versions in a compute gallery.
+	GalleryImageVersionResourceType ResourceType = "microsoft.compute/galleries/images/versions"
 )
@@ -4,20 +4,61 @@
 
 package utils
 
-import "github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+)
 
 const (
 	// ClusterTagPrefix is a prefix for a mandatory cluster tag on resources
 	ClusterTagPrefix = "kubernetes.io-cluster-"
 	// RoleTagPrefix is a prefix for a mandatory role tag on resources
 	RoleTagPrefix = "kubernetes.io-role-"
+	// MachineNameTagKey is the key of the tag which holds the name of the machine a resource belongs to. It is set on
+	// the VM, the NIC and the disks of the machine, which associates them to the machine even if their names have been
+	// shortened, see ShortenName and CreateVMName.
+	MachineNameTagKey = "machine.gardener.cloud-name"
+	// ProtectFromDeletionTagKey is the key of the tag which protects a VM from being deleted by DeleteMachine. Azure does
+	// not allow '/' in tag names, hence the tag is the counterpart of api.ProtectFromDeletionAnnotation with '-' instead.
+	ProtectFromDeletionTagKey = "mcm.gardener.cloud-protect-from-deletion"
+
+	// MaxTagsPerResource is the maximum number of tags which Azure allows on a resource.
+	MaxTagsPerResource = 50
+	// MaxTagKeyLength is the maximum length of a tag key which Azure allows.
+	MaxTagKeyLength = 512
+	// MaxTagValueLength is the maximum length of a tag value which Azure allows.
+	MaxTagValueLength = 256
+	// TagKeyForbiddenCharacters are the characters which Azure does not allow in tag keys.
+	TagKeyForbiddenCharacters = `<>%&\?/`
 )
 
+// SanitizeTagKey replaces all characters which Azure does not allow in tag keys with '-', e.g. kubernetes.io/role/node
+// becomes kubernetes.io-role-node. This is the same translation which is used for the mandatory cluster and role tags.
+func SanitizeTagKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(TagKeyForbiddenCharacters, r) {
+			return '-'
+		}
+		return r
+	}, key)
+}
+
 // CreateResourceTags changes the tag value to be a pointer to string. Azure APIs require tags to be represented as map[string]*string
+// The tag keys are sanitized using SanitizeTagKey, since Azure rejects resources with tag keys containing forbidden characters.
 func CreateResourceTags(tags map[string]string) map[string]*string {
 	vmTags := make(map[string]*string, len(tags))
 	for k, v := range tags {
-		vmTags[k] = to.Ptr(v)
+		vmTags[SanitizeTagKey(k)] = to.Ptr(v)
 	}
 	return vmTags
 }
+
+// CreateVMTags creates the tags for a VM and the resources which belong to it. In addition to the tags from the provider
+// spec it contains the machine name tag, which allows to associate the VM, the NIC and the disks to the machine even if
+// they are not named after the machine.
+func CreateVMTags(tags map[string]string, machineName string) map[string]*string {
+	vmTags := CreateResourceTags(tags)
+	vmTags[MachineNameTagKey] = to.Ptr(machineName)
+	return vmTags
+}
@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+)
+
+func TestSanitizeTagKey(t *testing.T) {
+	table := []struct {
+		description string
+		key         string
+		expectedKey string
+	}{
+		{"should keep valid keys", "kubernetes.io-role-node", "kubernetes.io-role-node"},
+		{"should replace slashes like for the mandatory tags", "kubernetes.io/role/node", "kubernetes.io-role-node"},
+		{"should replace all forbidden characters", `a<b>c%d&e\f?g/h`, "a-b-c-d-e-f-g-h"},
+	}
+
+	g := NewWithT(t)
+	for _, entry := range table {
+		t.Run(entry.description, func(_ *testing.T) {
+			g.Expect(SanitizeTagKey(entry.key)).To(Equal(entry.expectedKey))
+		})
+	}
+}
+
+func TestCreateResourceTagsSanitizesKeys(t *testing.T) {
+	g := NewWithT(t)
+	tags := CreateResourceTags(map[string]string{"kubernetes.io/role/node": "1", "name": "test"})
+	g.Expect(tags).To(HaveLen(2))
+	g.Expect(tags).To(HaveKeyWithValue("kubernetes.io-role-node", PointTo(Equal("1"))))
+	g.Expect(tags).To(HaveKeyWithValue("name", PointTo(Equal("test"))))
+}
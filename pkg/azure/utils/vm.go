@@ -4,13 +4,64 @@
 
 package utils
 
-import "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+)
 
 const (
 	// ProvisioningStateFailed is the provisioning state of the VM set by the provider indicating that the VM is in terminal state.
 	ProvisioningStateFailed = "Failed"
+	// ProvisioningStateSucceeded is the provisioning state of the VM set by the provider indicating that the VM has been successfully provisioned.
+	ProvisioningStateSucceeded = "Succeeded"
+)
+
+// Power states of a VM as reported in the statuses of its instance view.
+const (
+	// PowerStateStarting indicates that the VM is being started.
+	PowerStateStarting = "starting"
+	// PowerStateRunning indicates that the VM is running.
+	PowerStateRunning = "running"
+	// PowerStateStopping indicates that the VM is being stopped.
+	PowerStateStopping = "stopping"
+	// PowerStateStopped indicates that the VM is stopped but still allocated.
+	PowerStateStopped = "stopped"
+	// PowerStateDeallocating indicates that the VM is being deallocated.
+	PowerStateDeallocating = "deallocating"
+	// PowerStateDeallocated indicates that the VM is stopped and its compute resources have been released.
+	PowerStateDeallocated = "deallocated"
 )
 
+const (
+	provisioningStateStatusCodePrefix = "ProvisioningState/"
+	powerStateStatusCodePrefix        = "PowerState/"
+)
+
+// GetProvisioningState returns the provisioning state from the statuses of the passed instance view.
+// An empty string is returned if the instance view does not report a provisioning state.
+func GetProvisioningState(instanceView *armcompute.VirtualMachineInstanceView) string {
+	return getInstanceViewStatus(instanceView, provisioningStateStatusCodePrefix)
+}
+
+// GetPowerState returns the power state from the statuses of the passed instance view.
+// An empty string is returned if the instance view does not report a power state.
+func GetPowerState(instanceView *armcompute.VirtualMachineInstanceView) string {
+	return getInstanceViewStatus(instanceView, powerStateStatusCodePrefix)
+}
+
+func getInstanceViewStatus(instanceView *armcompute.VirtualMachineInstanceView, codePrefix string) string {
+	if instanceView == nil {
+		return ""
+	}
+	for _, s := range instanceView.Statuses {
+		if s != nil && s.Code != nil && strings.HasPrefix(*s.Code, codePrefix) {
+			return strings.TrimPrefix(*s.Code, codePrefix)
+		}
+	}
+	return ""
+}
+
 // DataDisksMarkedForDetachment checks if there is at least DataDisk that is marked for detachment.
 // If there are no DataDisk(s) configured then it will return false.
 func DataDisksMarkedForDetachment(vm *armcompute.VirtualMachine) bool {
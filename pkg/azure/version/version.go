@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package version provides the build information of the provider.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+var (
+	// Version is the version of the provider. It is set at build time via
+	// -ldflags "-X github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/version.Version=<version>".
+	Version = "unknown"
+	// GitSHA is the git commit the provider has been built from. It is set at build time via
+	// -ldflags "-X github.com/gardener/machine-controller-manager-provider-azure/pkg/azure/version.GitSHA=<sha>".
+	// If it is not set, the VCS revision recorded by the go toolchain is used, if there is one.
+	GitSHA = ""
+)
+
+// modules are the dependencies whose versions are reported as part of the Info, as they determine how the provider
+// talks to Azure and to the machine-controller-manager.
+var modules = []string{
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore",
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity",
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5",
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4",
+	"github.com/gardener/machine-controller-manager",
+}
+
+// Info is the build information of the provider.
+type Info struct {
+	// Version is the version of the provider.
+	Version string
+	// GitSHA is the git commit the provider has been built from, empty if unknown.
+	GitSHA string
+	// GoVersion is the version of the go toolchain the provider has been built with.
+	GoVersion string
+	// Modules maps the paths of the modules contained in modules to their versions, e.g. of the Azure SDK.
+	Modules map[string]string
+}
+
+// Get returns the build information of the provider.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GitSHA:    GitSHA,
+		GoVersion: runtime.Version(),
+		Modules:   make(map[string]string, len(modules)),
+	}
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, dep := range buildInfo.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		for _, module := range modules {
+			if dep.Path == module {
+				info.Modules[module] = dep.Version
+			}
+		}
+	}
+	if len(info.GitSHA) == 0 {
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" {
+				info.GitSHA = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// ModuleVersion returns the version of the module with the passed path or "unknown" if it is not known.
+func (i Info) ModuleVersion(path string) string {
+	if v, ok := i.Modules[path]; ok {
+		return v
+	}
+	return "unknown"
+}
+
+// String returns the build information in a human-readable form, one line per item.
+func (i Info) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Version: %s\n", i.Version)
+	fmt.Fprintf(&sb, "GitSHA: %s\n", i.GitSHA)
+	fmt.Fprintf(&sb, "GoVersion: %s\n", i.GoVersion)
+	paths := make([]string, 0, len(i.Modules))
+	for path := range i.Modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&sb, "%s: %s\n", path, i.Modules[path])
+	}
+	return sb.String()
+}
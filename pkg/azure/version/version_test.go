@@ -0,0 +1,26 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package version
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestInfo(t *testing.T) {
+	g := NewWithT(t)
+	info := Info{
+		Version:   "v0.1.0",
+		GitSHA:    "abc",
+		GoVersion: "go1.23.0",
+		Modules:   map[string]string{"b": "v2.0.0", "a": "v1.0.0"},
+	}
+	g.Expect(info.String()).To(Equal("Version: v0.1.0\nGitSHA: abc\nGoVersion: go1.23.0\na: v1.0.0\nb: v2.0.0\n"))
+	g.Expect(info.ModuleVersion("a")).To(Equal("v1.0.0"))
+	g.Expect(info.ModuleVersion("c")).To(Equal("unknown"))
+
+	g.Expect(Get().Version).To(Equal(Version))
+}